@@ -0,0 +1,70 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/capture"
+)
+
+// NewAnalyzeCaptureCommand creates a command which analyzes traffic captures written by --proxy-capture-file
+func NewAnalyzeCaptureCommand() *cobra.Command {
+	top := 20
+	cmd := &cobra.Command{
+		Use:   "analyze-capture FILE...",
+		Short: "Analyze traffic captures offline for capacity planning",
+		Long: `Analyze traffic captures written by --proxy-capture-file, and print top users, top resources,
+watch churn and retryable error rates of each upstream cluster. Gzip compressed captures are
+detected automatically, "-" reads from stdin.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			readers := []io.Reader{}
+			for _, name := range args {
+				if name == "-" {
+					readers = append(readers, cmd.InOrStdin())
+					continue
+				}
+				f, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				readers = append(readers, f)
+			}
+			report, err := capture.Analyze(readers, top)
+			if err != nil {
+				return fmt.Errorf("failed to analyze captures: %v", err)
+			}
+			return report.Print(cmd.OutOrStdout())
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().IntVar(&top, "top", top, "The number of entries to show in each ranking, zero means all.")
+
+	// do not inherit usage of root command, which prints all server flags
+	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
+		fmt.Fprintf(cmd.OutOrStderr(), "Usage:\n  %s\n\nFlags:\n%s", cmd.UseLine(), cmd.Flags().FlagUsages())
+		return nil
+	})
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n\nUsage:\n  %s\n\nFlags:\n%s", cmd.Long, cmd.UseLine(), cmd.Flags().FlagUsages())
+	})
+	return cmd
+}
@@ -33,13 +33,15 @@ import (
 	"github.com/kubewharf/kubegateway/pkg/apis/generated/openapi"
 	gatewayinformers "github.com/kubewharf/kubegateway/pkg/client/informers"
 	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
 	controlplaneserver "github.com/kubewharf/kubegateway/pkg/gateway/controlplane"
 	controlplaneadmission "github.com/kubewharf/kubegateway/pkg/gateway/controlplane/admission/initializer"
 )
 
-// CreateControlPlaneConfig creates all the resources for running the API server, but runs none of them
-func CreateControlPlaneConfig(s *options.ControlPlaneServerRunOptions) (*controlplaneserver.Config, error) {
-	config, err := buildControlPlaneConfig(s)
+// CreateControlPlaneConfig creates all the resources for running the API server, but runs none of them.
+// UpstreamClusters are admitted against clusterDefaults, which are shared with the proxy.
+func CreateControlPlaneConfig(s *options.ControlPlaneServerRunOptions, clusterDefaults *clusters.ClusterDefaults) (*controlplaneserver.Config, error) {
+	config, err := buildControlPlaneConfig(s, clusterDefaults)
 	if err != nil {
 		return nil, err
 	}
@@ -61,7 +63,7 @@ func CreateControlPlaneConfig(s *options.ControlPlaneServerRunOptions) (*control
 	return config, nil
 }
 
-func buildControlPlaneConfig(o *options.ControlPlaneServerRunOptions) (serverConfig *controlplaneserver.Config, lastErr error) {
+func buildControlPlaneConfig(o *options.ControlPlaneServerRunOptions, clusterDefaults *clusters.ClusterDefaults) (serverConfig *controlplaneserver.Config, lastErr error) {
 	recommendedConfig := apiserver.NewRecommendedConfig(scheme.Scheme, scheme.Codecs)
 	recommendedConfig.Config.MergedResourceConfig = controlplaneserver.DefaultAPIResourceConfigSource()
 	//TODO: fix openapi.GetOpenAPIDefinitions
@@ -89,7 +91,7 @@ func buildControlPlaneConfig(o *options.ControlPlaneServerRunOptions) (serverCon
 	}
 	gatewayInformer := gatewayinformers.NewSharedInformerFactory(gatewayClient, 0)
 	pluginInitializers := []admission.PluginInitializer{
-		controlplaneadmission.New(gatewayClient, gatewayInformer),
+		controlplaneadmission.New(gatewayClient, gatewayInformer, clusterDefaults),
 	}
 
 	if lastErr = o.ApplyTo(recommendedConfig, nil, controlplaneserver.DefaultAPIResourceConfigSource(), pluginInitializers...); lastErr != nil {
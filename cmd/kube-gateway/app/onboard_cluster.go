@@ -0,0 +1,117 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/onboarding"
+)
+
+// NewOnboardClusterCommand creates a command which generates an UpstreamCluster manifest from kubeconfig of a new cluster
+func NewOnboardClusterCommand() *cobra.Command {
+	var (
+		kubeconfig        string
+		kubeContext       string
+		probe             = true
+		discoverEndpoints bool
+		inlineCredentials bool
+		timeout           = 30 * time.Second
+	)
+	cmd := &cobra.Command{
+		Use:   "onboard-cluster NAME",
+		Short: "Generate an UpstreamCluster manifest from kubeconfig of a new cluster",
+		Long: `Generate a validated UpstreamCluster manifest named NAME from kubeconfig of a new cluster, with
+endpoints, CA, client credentials and flow controls recommended by a quick probe of cluster size.
+UpstreamCluster has no secret reference, so client credentials have to be embedded into the manifest,
+which is refused unless --inline-credentials is passed, treat the output as a secret then.
+Exec credential plugins and auth providers are not supported.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !inlineCredentials {
+				// fail before touching the cluster
+				return fmt.Errorf("the manifest embeds the client key or bearer token of kubeconfig, pass --inline-credentials to print it")
+			}
+			rules := clientcmd.NewDefaultClientConfigLoadingRules()
+			rules.ExplicitPath = kubeconfig
+			config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext}).ClientConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig: %v", err)
+			}
+			config.Timeout = timeout
+			client, err := kubernetes.NewForConfig(config)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			size := onboarding.ClusterSize{}
+			if probe {
+				size, err = onboarding.ProbeClusterSize(ctx, client)
+				if err != nil {
+					return fmt.Errorf("failed to probe cluster size: %v", err)
+				}
+			}
+			var endpoints []string
+			if discoverEndpoints {
+				endpoints, err = onboarding.DiscoverEndpoints(ctx, client, config.Host)
+				if err != nil {
+					return fmt.Errorf("failed to discover endpoints: %v", err)
+				}
+			}
+			cluster, err := onboarding.NewUpstreamCluster(args[0], config, endpoints, onboarding.Recommend(size), inlineCredentials)
+			if err != nil {
+				return err
+			}
+			data, err := yaml.Marshal(cluster)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if probe {
+				fmt.Fprintf(out, "# probed cluster size: nodes=%d pods=%d\n", size.Nodes, size.Pods)
+			}
+			_, err = out.Write(data)
+			return err
+		},
+		SilenceUsage: true,
+	}
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", kubeconfig, "Path to kubeconfig of the new cluster, empty means the default loading rules.")
+	cmd.Flags().StringVar(&kubeContext, "context", kubeContext, "The kubeconfig context to use, empty means the current context.")
+	cmd.Flags().BoolVar(&probe, "probe", probe, "Probe the number of nodes and pods to recommend flow controls, otherwise recommend the smallest ones.")
+	cmd.Flags().BoolVar(&discoverEndpoints, "discover-endpoints", discoverEndpoints, "Use endpoints of the kubernetes service as servers instead of the server of kubeconfig.")
+	cmd.Flags().BoolVar(&inlineCredentials, "inline-credentials", inlineCredentials, ""+
+		"Allow embedding the client key or bearer token of kubeconfig into the printed manifest, it is required since UpstreamCluster has no secret reference.")
+	cmd.Flags().DurationVar(&timeout, "timeout", timeout, "The maximum duration to probe the cluster.")
+
+	// do not inherit usage of root command, which prints all server flags
+	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
+		fmt.Fprintf(cmd.OutOrStderr(), "Usage:\n  %s\n\nFlags:\n%s", cmd.UseLine(), cmd.Flags().FlagUsages())
+		return nil
+	})
+	cmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s\n\nUsage:\n  %s\n\nFlags:\n%s", cmd.Long, cmd.UseLine(), cmd.Flags().FlagUsages())
+	})
+	return cmd
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/spf13/pflag"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+
+	configv1alpha1 "github.com/kubewharf/kubegateway/pkg/gateway/apis/config/v1alpha1"
+	proxyoptions "github.com/kubewharf/kubegateway/pkg/gateway/proxy/options"
+)
+
+// NewOptionsFromConfigFile creates options from the configuration file, and then
+// flags explicitly set in args override the values from file.
+func NewOptionsFromConfigFile(path string, args []string) (*Options, error) {
+	cfg, err := configv1alpha1.LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	o := NewOptions()
+	if err := o.ApplyConfiguration(cfg); err != nil {
+		return nil, err
+	}
+
+	// parse command line again with a clean flag set bound to new options,
+	// other flags such as global flags have already been parsed
+	fs := pflag.NewFlagSet("kube-gateway", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	for _, f := range o.Flags().FlagSets {
+		fs.AddFlagSet(f)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	o.ConfigFile = path
+	return o, nil
+}
+
+// ApplyConfiguration applies the defaulted configuration to options
+func (o *Options) ApplyConfiguration(cfg *configv1alpha1.GatewayConfiguration) error {
+	if len(cfg.FeatureGates) > 0 {
+		if err := utilfeature.DefaultMutableFeatureGate.SetFromMap(cfg.FeatureGates); err != nil {
+			return err
+		}
+	}
+
+	controlplane := o.ControlPlane
+	if secureServing := controlplane.SecureServing; secureServing != nil {
+		listener := cfg.Listeners.ControlPlane
+		if listener.BindAddress != nil {
+			ip := net.ParseIP(*listener.BindAddress)
+			if ip == nil {
+				return fmt.Errorf("invalid control plane bind address %q", *listener.BindAddress)
+			}
+			secureServing.BindAddress = ip
+		}
+		if listener.Port != nil {
+			secureServing.BindPort = *listener.Port
+		}
+		if listener.ReusePort != nil {
+			secureServing.ReusePort = *listener.ReusePort
+		}
+		if len(listener.OtherPorts) > 0 {
+			secureServing.OtherPorts = listener.OtherPorts
+		}
+		if len(listener.CertFile) > 0 {
+			secureServing.ServerCert.CertKey.CertFile = listener.CertFile
+			secureServing.ServerCert.CertKey.KeyFile = listener.KeyFile
+		}
+	}
+	if len(cfg.Listeners.Proxy.Ports) > 0 {
+		o.Proxy.SecureServing.Ports = cfg.Listeners.Proxy.Ports
+	}
+	if len(cfg.Listeners.Proxy.InternalH2CBindAddress) > 0 {
+		o.Proxy.InternalListener.H2CBindAddress = cfg.Listeners.Proxy.InternalH2CBindAddress
+	}
+
+	if len(cfg.Authentication.ClientCAFile) > 0 && controlplane.Authentication != nil && controlplane.Authentication.ClientCert != nil {
+		controlplane.Authentication.ClientCert.ClientCA = cfg.Authentication.ClientCAFile
+	}
+	if cfg.Authentication.TokenSuccessCacheTTL != nil {
+		o.Proxy.Authentication.TokenSuccessCacheTTL = cfg.Authentication.TokenSuccessCacheTTL.Duration
+	}
+	if cfg.Authentication.TokenFailureCacheTTL != nil {
+		o.Proxy.Authentication.TokenFailureCacheTTL = cfg.Authentication.TokenFailureCacheTTL.Duration
+	}
+	for _, issuer := range cfg.Authentication.OIDCIssuers {
+		o.Proxy.Authentication.OIDC.AdditionalIssuers = append(o.Proxy.Authentication.OIDC.AdditionalIssuers, proxyoptions.OIDCIssuerOptions{
+			IssuerURL:      issuer.IssuerURL,
+			ClientID:       issuer.ClientID,
+			CAFile:         issuer.CAFile,
+			UsernameClaim:  issuer.UsernameClaim,
+			UsernamePrefix: issuer.UsernamePrefix,
+			GroupsClaim:    issuer.GroupsClaim,
+			GroupsPrefix:   issuer.GroupsPrefix,
+			SigningAlgs:    issuer.SigningAlgs,
+			RequiredClaims: issuer.RequiredClaims,
+		})
+	}
+
+	if len(cfg.Authorization.Modes) > 0 && controlplane.Authorization != nil {
+		controlplane.Authorization.Modes = cfg.Authorization.Modes
+	}
+	if cfg.Authorization.CacheAuthorizedTTL != nil {
+		o.Proxy.Authorization.CacheAuthorizedTTL = cfg.Authorization.CacheAuthorizedTTL.Duration
+	}
+	if cfg.Authorization.CacheUnauthorizedTTL != nil {
+		o.Proxy.Authorization.CacheUnauthorizedTTL = cfg.Authorization.CacheUnauthorizedTTL.Duration
+	}
+
+	if cfg.FlowControl.MaxInflightRequestsPerCluster != nil {
+		o.Proxy.ResourceBudget.MaxInflightRequestsPerCluster = *cfg.FlowControl.MaxInflightRequestsPerCluster
+	}
+	if cfg.FlowControl.MaxPendingDialsPerCluster != nil {
+		o.Proxy.ResourceBudget.MaxPendingDialsPerCluster = *cfg.FlowControl.MaxPendingDialsPerCluster
+	}
+
+	if cfg.Logging.EnableProxyAccessLog != nil {
+		o.Proxy.Logging.EnableProxyAccessLog = *cfg.Logging.EnableProxyAccessLog
+	}
+
+	if len(cfg.Metrics.ShowHiddenMetricsForVersion) > 0 {
+		controlplane.ShowHiddenMetricsForVersion = cfg.Metrics.ShowHiddenMetricsForVersion
+	}
+
+	if len(cfg.Fleet.Hostname) > 0 {
+		o.Proxy.Fleet.Hostname = cfg.Fleet.Hostname
+		o.Proxy.Fleet.Clusters = cfg.Fleet.Clusters
+	}
+	if cfg.Fleet.Timeout != nil {
+		o.Proxy.Fleet.Timeout = cfg.Fleet.Timeout.Duration
+	}
+
+	if cfg.Runtime.GOMAXPROCS != nil {
+		o.Proxy.Runtime.GOMAXPROCS = *cfg.Runtime.GOMAXPROCS
+	}
+	if cfg.Runtime.GCPercent != nil {
+		o.Proxy.Runtime.GCPercent = *cfg.Runtime.GCPercent
+	}
+	if cfg.Runtime.MemoryLimit != nil {
+		o.Proxy.Runtime.MemoryLimit = cfg.Runtime.MemoryLimit.String()
+	}
+	if cfg.Runtime.CopyBufferSize != nil {
+		o.Proxy.Runtime.CopyBufferSize = *cfg.Runtime.CopyBufferSize
+	}
+	return nil
+}
@@ -21,12 +21,17 @@ import (
 type Options struct {
 	ControlPlane *ControlPlaneServerRunOptions
 	Proxy        *ProxyOptions
+	Preflight    *PreflightOptions
+
+	// ConfigFile is the path of versioned GatewayConfiguration file
+	ConfigFile string
 }
 
 func NewOptions() *Options {
 	return &Options{
 		ControlPlane: NewControlPlaneServerRunOptions(),
 		Proxy:        NewProxyOptions(),
+		Preflight:    NewPreflightOptions(),
 	}
 }
 
@@ -40,5 +45,9 @@ func (o *Options) Flags() cliflag.NamedFlagSets {
 		fss.Order = append(fss.Order, k)
 		fss.FlagSets[k] = v
 	}
+	o.Preflight.AddFlags(fss.FlagSet("preflight"))
+	fss.FlagSet("global").StringVar(&o.ConfigFile, "config", o.ConfigFile, ""+
+		"The path to the GatewayConfiguration file (apiVersion: config.kubegateway.io/v1alpha1). "+
+		"Flags explicitly set on command line override the values in this file.")
 	return fss
 }
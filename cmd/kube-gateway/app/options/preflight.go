@@ -0,0 +1,97 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/preflight"
+)
+
+type PreflightOptions struct {
+	// Only runs preflight checks and exits with a report instead of serving
+	Only bool
+	// UpstreamClusterFiles are UpstreamCluster manifests to check
+	UpstreamClusterFiles []string
+	RequiredClusters     []string
+	Timeout              time.Duration
+}
+
+func NewPreflightOptions() *PreflightOptions {
+	return &PreflightOptions{
+		Timeout: 5 * time.Second,
+	}
+}
+
+func (o *PreflightOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("--preflight-timeout must be greater than 0"))
+	}
+	if len(o.RequiredClusters) > 0 && len(o.UpstreamClusterFiles) == 0 {
+		errs = append(errs, fmt.Errorf("--preflight-required-clusters requires --preflight-upstream-cluster-files"))
+	}
+	return errs
+}
+
+func (o *PreflightOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Only, "preflight-only", o.Only, ""+
+		"If true, run preflight checks, print a JSON report to stdout and exit instead of serving. "+
+		"The exit code is non-zero if any check failed, it is intended for CI of config changes.")
+	fs.StringSliceVar(&o.UpstreamClusterFiles, "preflight-upstream-cluster-files", o.UpstreamClusterFiles, ""+
+		"A list of UpstreamCluster manifest files in yaml or json format whose endpoints and TLS material "+
+		"are verified by preflight checks.")
+	fs.StringSliceVar(&o.RequiredClusters, "preflight-required-clusters", o.RequiredClusters, ""+
+		"A list of upstream cluster names which must have at least one reachable endpoint, "+
+		"otherwise preflight checks fail and the gateway does not start.")
+	fs.DurationVar(&o.Timeout, "preflight-timeout", o.Timeout,
+		"The timeout of resolving and dialing each upstream endpoint in preflight checks.")
+}
+
+// ToPreflightConfig collects TLS material configured by options and upstream clusters to check
+func (o *Options) ToPreflightConfig() (preflight.Config, error) {
+	cfg := preflight.Config{
+		RequiredClusters: o.Preflight.RequiredClusters,
+		Timeout:          o.Preflight.Timeout,
+	}
+	if secureServing := o.ControlPlane.SecureServing; secureServing != nil {
+		if certKey := secureServing.ServerCert.CertKey; len(certKey.CertFile) > 0 {
+			cfg.ServingCerts = append(cfg.ServingCerts, preflight.CertKeyPair{CertFile: certKey.CertFile, KeyFile: certKey.KeyFile})
+		}
+	}
+	if authn := o.ControlPlane.Authentication; authn != nil && authn.ClientCert != nil && len(authn.ClientCert.ClientCA) > 0 {
+		cfg.CAFiles = append(cfg.CAFiles, authn.ClientCert.ClientCA)
+	}
+	for _, issuer := range o.Proxy.Authentication.OIDC.Issuers() {
+		if len(issuer.CAFile) > 0 {
+			cfg.CAFiles = append(cfg.CAFiles, issuer.CAFile)
+		}
+	}
+	clusters, err := preflight.LoadUpstreamClusters(o.Preflight.UpstreamClusterFiles)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Clusters = clusters
+	return cfg, nil
+}
@@ -22,20 +22,108 @@ import (
 )
 
 type ProxyOptions struct {
-	Authentication *proxyoptions.AuthenticationOptions
-	Authorization  *proxyoptions.AuthorizationOptions
-	SecureServing  *proxyoptions.SecureServingOptions
-	ProcessInfo    *genericoptions.ProcessInfo
-	Logging        *proxyoptions.LoggingOptions
+	Authentication     *proxyoptions.AuthenticationOptions
+	Authorization      *proxyoptions.AuthorizationOptions
+	SecureServing      *proxyoptions.SecureServingOptions
+	ProcessInfo        *genericoptions.ProcessInfo
+	Logging            *proxyoptions.LoggingOptions
+	Fleet              *proxyoptions.FleetOptions
+	ResourceBudget     *proxyoptions.ResourceBudgetOptions
+	UpstreamRetry      *proxyoptions.UpstreamRetryOptions
+	RateLimitExemption *proxyoptions.RateLimitExemptionOptions
+	CORS               *proxyoptions.CORSOptions
+	UpstreamTimeout    *proxyoptions.UpstreamTimeoutOptions
+	UpstreamProbe      *proxyoptions.UpstreamProbeOptions
+	EndpointState      *proxyoptions.EndpointStateOptions
+	TrafficCapture     *proxyoptions.TrafficCaptureOptions
+	UpstreamRedirect   *proxyoptions.UpstreamRedirectOptions
+	URLRewrite         *proxyoptions.URLRewriteOptions
+	UpstreamAuth       *proxyoptions.UpstreamAuthOptions
+	UpstreamCredential *proxyoptions.UpstreamCredentialOptions
+	WildcardHost       *proxyoptions.WildcardHostOptions
+	RequestPriority    *proxyoptions.RequestPriorityOptions
+	LoadShedding       *proxyoptions.LoadSheddingOptions
+	TLSPolicy          *proxyoptions.TLSPolicyOptions
+	RateLimitHeaders   *proxyoptions.RateLimitHeadersOptions
+	StreamingBandwidth *proxyoptions.StreamingBandwidthOptions
+	InternalListener   *proxyoptions.InternalListenerOptions
+	UpstreamPrewarm    *proxyoptions.UpstreamPrewarmOptions
+	Drain              *proxyoptions.DrainOptions
+	RequestValidation  *proxyoptions.RequestValidationOptions
+	ExpiredRV          *proxyoptions.ExpiredResourceVersionOptions
+	AdaptiveTimeout    *proxyoptions.AdaptiveTimeoutOptions
+	MetricsCardinality *proxyoptions.MetricsCardinalityOptions
+	UpstreamCanary     *proxyoptions.UpstreamCanaryOptions
+	ResponseHeader     *proxyoptions.ResponseHeaderOptions
+	VirtualCluster     *proxyoptions.VirtualClusterOptions
+	Panic              *proxyoptions.PanicOptions
+	Coordination       *proxyoptions.CoordinationOptions
+	AutoProfile        *proxyoptions.AutoProfileOptions
+	Runtime            *proxyoptions.RuntimeOptions
+	AbuseReport        *proxyoptions.AbuseReportOptions
+	LongRunning        *proxyoptions.LongRunningOptions
+	ClusterCeiling     *proxyoptions.ClusterCeilingOptions
+	Expression         *proxyoptions.ExpressionOptions
+	DiscoveryCache     *proxyoptions.DiscoveryCacheOptions
+	Maintenance        *proxyoptions.MaintenanceOptions
+	HealthCheck        *proxyoptions.HealthCheckOptions
+	UserAgentRule      *proxyoptions.UserAgentRuleOptions
+	CostEstimation     *proxyoptions.CostEstimationOptions
+	Notification       *proxyoptions.NotificationOptions
+	NoRoute            *proxyoptions.NoRouteOptions
 }
 
 func NewProxyOptions() *ProxyOptions {
 	return &ProxyOptions{
-		Authentication: proxyoptions.NewAuthenticationOptions(),
-		Authorization:  proxyoptions.NewAuthorizationOptions(),
-		SecureServing:  proxyoptions.NewSecureServingOptions(),
-		ProcessInfo:    genericoptions.NewProcessInfo("kube-gateway-proxy", "kube-system"),
-		Logging:        proxyoptions.NewLoggingOptions(),
+		Authentication:     proxyoptions.NewAuthenticationOptions(),
+		Authorization:      proxyoptions.NewAuthorizationOptions(),
+		SecureServing:      proxyoptions.NewSecureServingOptions(),
+		ProcessInfo:        genericoptions.NewProcessInfo("kube-gateway-proxy", "kube-system"),
+		Logging:            proxyoptions.NewLoggingOptions(),
+		Fleet:              proxyoptions.NewFleetOptions(),
+		ResourceBudget:     proxyoptions.NewResourceBudgetOptions(),
+		UpstreamRetry:      proxyoptions.NewUpstreamRetryOptions(),
+		RateLimitExemption: proxyoptions.NewRateLimitExemptionOptions(),
+		CORS:               proxyoptions.NewCORSOptions(),
+		UpstreamTimeout:    proxyoptions.NewUpstreamTimeoutOptions(),
+		UpstreamProbe:      proxyoptions.NewUpstreamProbeOptions(),
+		EndpointState:      proxyoptions.NewEndpointStateOptions(),
+		TrafficCapture:     proxyoptions.NewTrafficCaptureOptions(),
+		UpstreamRedirect:   proxyoptions.NewUpstreamRedirectOptions(),
+		URLRewrite:         proxyoptions.NewURLRewriteOptions(),
+		UpstreamAuth:       proxyoptions.NewUpstreamAuthOptions(),
+		UpstreamCredential: proxyoptions.NewUpstreamCredentialOptions(),
+		WildcardHost:       proxyoptions.NewWildcardHostOptions(),
+		RequestPriority:    proxyoptions.NewRequestPriorityOptions(),
+		LoadShedding:       proxyoptions.NewLoadSheddingOptions(),
+		TLSPolicy:          proxyoptions.NewTLSPolicyOptions(),
+		RateLimitHeaders:   proxyoptions.NewRateLimitHeadersOptions(),
+		StreamingBandwidth: proxyoptions.NewStreamingBandwidthOptions(),
+		InternalListener:   proxyoptions.NewInternalListenerOptions(),
+		UpstreamPrewarm:    proxyoptions.NewUpstreamPrewarmOptions(),
+		Drain:              proxyoptions.NewDrainOptions(),
+		RequestValidation:  proxyoptions.NewRequestValidationOptions(),
+		ExpiredRV:          proxyoptions.NewExpiredResourceVersionOptions(),
+		AdaptiveTimeout:    proxyoptions.NewAdaptiveTimeoutOptions(),
+		MetricsCardinality: proxyoptions.NewMetricsCardinalityOptions(),
+		UpstreamCanary:     proxyoptions.NewUpstreamCanaryOptions(),
+		ResponseHeader:     proxyoptions.NewResponseHeaderOptions(),
+		VirtualCluster:     proxyoptions.NewVirtualClusterOptions(),
+		Panic:              proxyoptions.NewPanicOptions(),
+		Coordination:       proxyoptions.NewCoordinationOptions(),
+		AutoProfile:        proxyoptions.NewAutoProfileOptions(),
+		Runtime:            proxyoptions.NewRuntimeOptions(),
+		AbuseReport:        proxyoptions.NewAbuseReportOptions(),
+		LongRunning:        proxyoptions.NewLongRunningOptions(),
+		ClusterCeiling:     proxyoptions.NewClusterCeilingOptions(),
+		Expression:         proxyoptions.NewExpressionOptions(),
+		DiscoveryCache:     proxyoptions.NewDiscoveryCacheOptions(),
+		Maintenance:        proxyoptions.NewMaintenanceOptions(),
+		HealthCheck:        proxyoptions.NewHealthCheckOptions(),
+		UserAgentRule:      proxyoptions.NewUserAgentRuleOptions(),
+		CostEstimation:     proxyoptions.NewCostEstimationOptions(),
+		Notification:       proxyoptions.NewNotificationOptions(),
+		NoRoute:            proxyoptions.NewNoRouteOptions(),
 	}
 }
 
@@ -46,5 +134,49 @@ func (s *ProxyOptions) Flags() (fss cliflag.NamedFlagSets) {
 	s.Authorization.AddFlags(fs)
 	s.SecureServing.AddFlags(fs)
 	s.Logging.AddFlags(fs)
+	s.Fleet.AddFlags(fs)
+	s.ResourceBudget.AddFlags(fs)
+	s.UpstreamRetry.AddFlags(fs)
+	s.RateLimitExemption.AddFlags(fs)
+	s.CORS.AddFlags(fs)
+	s.UpstreamTimeout.AddFlags(fs)
+	s.UpstreamProbe.AddFlags(fs)
+	s.EndpointState.AddFlags(fs)
+	s.TrafficCapture.AddFlags(fs)
+	s.UpstreamRedirect.AddFlags(fs)
+	s.URLRewrite.AddFlags(fs)
+	s.UpstreamAuth.AddFlags(fs)
+	s.UpstreamCredential.AddFlags(fs)
+	s.WildcardHost.AddFlags(fs)
+	s.RequestPriority.AddFlags(fs)
+	s.LoadShedding.AddFlags(fs)
+	s.TLSPolicy.AddFlags(fs)
+	s.RateLimitHeaders.AddFlags(fs)
+	s.StreamingBandwidth.AddFlags(fs)
+	s.InternalListener.AddFlags(fs)
+	s.UpstreamPrewarm.AddFlags(fs)
+	s.Drain.AddFlags(fs)
+	s.RequestValidation.AddFlags(fs)
+	s.ExpiredRV.AddFlags(fs)
+	s.AdaptiveTimeout.AddFlags(fs)
+	s.MetricsCardinality.AddFlags(fs)
+	s.UpstreamCanary.AddFlags(fs)
+	s.ResponseHeader.AddFlags(fs)
+	s.VirtualCluster.AddFlags(fs)
+	s.Panic.AddFlags(fs)
+	s.Coordination.AddFlags(fs)
+	s.AutoProfile.AddFlags(fs)
+	s.Runtime.AddFlags(fs)
+	s.AbuseReport.AddFlags(fs)
+	s.LongRunning.AddFlags(fs)
+	s.ClusterCeiling.AddFlags(fs)
+	s.Expression.AddFlags(fs)
+	s.DiscoveryCache.AddFlags(fs)
+	s.Maintenance.AddFlags(fs)
+	s.HealthCheck.AddFlags(fs)
+	s.UserAgentRule.AddFlags(fs)
+	s.CostEstimation.AddFlags(fs)
+	s.Notification.AddFlags(fs)
+	s.NoRoute.AddFlags(fs)
 	return
 }
@@ -37,6 +37,50 @@ func (o *ProxyOptions) Validate(controlplane *ControlPlaneServerRunOptions) []er
 	errs = append(errs, o.Authentication.Validate()...)
 	errs = append(errs, o.Authorization.Validate()...)
 	errs = append(errs, o.SecureServing.ValidateWith(*controlplane.SecureServing)...)
+	errs = append(errs, o.Fleet.Validate()...)
+	errs = append(errs, o.ResourceBudget.Validate()...)
+	errs = append(errs, o.UpstreamRetry.Validate()...)
+	errs = append(errs, o.RateLimitExemption.Validate()...)
+	errs = append(errs, o.CORS.Validate()...)
+	errs = append(errs, o.UpstreamTimeout.Validate()...)
+	errs = append(errs, o.UpstreamProbe.Validate()...)
+	errs = append(errs, o.EndpointState.Validate()...)
+	errs = append(errs, o.TrafficCapture.Validate()...)
+	errs = append(errs, o.UpstreamRedirect.Validate()...)
+	errs = append(errs, o.URLRewrite.Validate()...)
+	errs = append(errs, o.UpstreamAuth.Validate()...)
+	errs = append(errs, o.UpstreamCredential.Validate()...)
+	errs = append(errs, o.WildcardHost.Validate()...)
+	errs = append(errs, o.RequestPriority.Validate()...)
+	errs = append(errs, o.LoadShedding.Validate()...)
+	errs = append(errs, o.TLSPolicy.Validate()...)
+	errs = append(errs, o.RateLimitHeaders.Validate()...)
+	errs = append(errs, o.StreamingBandwidth.Validate()...)
+	errs = append(errs, o.InternalListener.Validate()...)
+	errs = append(errs, o.UpstreamPrewarm.Validate()...)
+	errs = append(errs, o.Drain.Validate()...)
+	errs = append(errs, o.RequestValidation.Validate()...)
+	errs = append(errs, o.ExpiredRV.Validate()...)
+	errs = append(errs, o.AdaptiveTimeout.Validate()...)
+	errs = append(errs, o.MetricsCardinality.Validate()...)
+	errs = append(errs, o.UpstreamCanary.Validate()...)
+	errs = append(errs, o.ResponseHeader.Validate()...)
+	errs = append(errs, o.VirtualCluster.Validate()...)
+	errs = append(errs, o.Panic.Validate()...)
+	errs = append(errs, o.Coordination.ValidateWith(o.UpstreamProbe.ReplicaName)...)
+	errs = append(errs, o.AutoProfile.Validate()...)
+	errs = append(errs, o.Runtime.Validate()...)
+	errs = append(errs, o.AbuseReport.Validate()...)
+	errs = append(errs, o.LongRunning.Validate()...)
+	errs = append(errs, o.ClusterCeiling.Validate()...)
+	errs = append(errs, o.Expression.Validate()...)
+	errs = append(errs, o.DiscoveryCache.Validate()...)
+	errs = append(errs, o.Maintenance.Validate()...)
+	errs = append(errs, o.HealthCheck.Validate()...)
+	errs = append(errs, o.UserAgentRule.Validate()...)
+	errs = append(errs, o.CostEstimation.Validate()...)
+	errs = append(errs, o.Notification.Validate()...)
+	errs = append(errs, o.NoRoute.Validate()...)
 	return errs
 }
 
@@ -44,5 +88,6 @@ func (o *Options) Validate() []error {
 	var errs []error
 	errs = append(errs, o.ControlPlane.Validate()...)
 	errs = append(errs, o.Proxy.Validate(o.ControlPlane)...)
+	errs = append(errs, o.Preflight.Validate()...)
 	return errs
 }
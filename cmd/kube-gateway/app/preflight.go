@@ -0,0 +1,52 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/cmd/kube-gateway/app/options"
+	"github.com/kubewharf/kubegateway/pkg/gateway/preflight"
+)
+
+// runPreflight verifies configuration before serving, the report is written to out in --preflight-only mode
+func runPreflight(o *options.Options, out io.Writer) error {
+	cfg, err := o.ToPreflightConfig()
+	if err != nil {
+		return fmt.Errorf("preflight: %v", err)
+	}
+	report := preflight.Run(context.Background(), cfg)
+	if o.Preflight.Only {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	}
+	if report.Passed {
+		klog.Infof("[preflight] all %d checks passed", len(report.Results))
+		return nil
+	}
+	failures := report.Failures()
+	for _, failure := range failures {
+		klog.Errorf("[preflight] check %s failed, target=%q message=%q", failure.Check, failure.Target, failure.Message)
+	}
+	return fmt.Errorf("%d of %d preflight checks failed", len(failures), len(report.Results))
+}
@@ -17,14 +17,10 @@ package app
 import (
 	"bytes"
 	"log"
-	"net/http"
 
 	"github.com/kubewharf/apiserver-runtime/pkg/scheme"
 	apiserver "github.com/kubewharf/apiserver-runtime/pkg/server"
 	recommendedoptions "github.com/kubewharf/apiserver-runtime/pkg/server/options"
-	genericapifilters "k8s.io/apiserver/pkg/endpoints/filters"
-	genericapiserver "k8s.io/apiserver/pkg/server"
-	genericfilters "k8s.io/apiserver/pkg/server/filters"
 	_ "k8s.io/component-base/metrics/prometheus/workqueue" // for workqueue metric registration
 	"k8s.io/klog"
 	"k8s.io/kube-openapi/pkg/common"
@@ -33,10 +29,10 @@ import (
 	"github.com/kubewharf/kubegateway/pkg/clusters"
 	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
 	controlplaneserver "github.com/kubewharf/kubegateway/pkg/gateway/controlplane"
-	gatewayfilters "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/filters"
-	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/embedded"
 	proxyserver "github.com/kubewharf/kubegateway/pkg/gateway/proxy"
 	proxydispatcher "github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
 	nativeopenapi "github.com/kubewharf/kubegateway/staging/src/k8s.io/openapi/generated/openapi"
 )
 
@@ -44,6 +40,7 @@ func CreateProxyConfig(
 	o *options.ProxyOptions,
 	controlplaneOptions *options.ControlPlaneServerRunOptions,
 	controlplaneServerConfig *controlplaneserver.Config,
+	clusterDefaults *clusters.ClusterDefaults,
 ) (serverConfig *proxyserver.Config, lastErr error) {
 	recommendedConfig := apiserver.NewRecommendedConfig(scheme.Scheme, scheme.Codecs)
 	// NOTE: set loopback client config ortherwise error will occur when creating a new generic apiserver
@@ -62,12 +59,95 @@ func CreateProxyConfig(
 	controlplaneServerConfig.RecommendedConfig.SecureServing.ErrorLog = log.New(proxyHTTPErrorLogWriter{}, "", 0)
 	log.SetOutput(proxyHTTPErrorLogWriter{})
 
+	// defaults of upstream clusters must be filled before upstream cluster controller is created
+	o.ResourceBudget.ApplyTo(clusterDefaults)
+	o.ClusterCeiling.ApplyTo(clusterDefaults, controlplaneServerConfig.RecommendedConfig.LoopbackClientset)
+	o.Expression.ApplyTo(clusterDefaults)
+	o.HealthCheck.ApplyTo(clusterDefaults)
+	o.UpstreamTimeout.ApplyTo(clusterDefaults, controlplaneOptions.ServerRun.RequestTimeout)
+	o.ResponseHeader.ApplyTo(clusterDefaults)
+	o.UpstreamPrewarm.ApplyTo(clusterDefaults)
+	o.UpstreamRedirect.ApplyTo(clusterDefaults)
+	o.UpstreamAuth.ApplyTo(clusterDefaults)
+	if lastErr = o.UpstreamCredential.ApplyTo(clusterDefaults); lastErr != nil {
+		return
+	}
+	o.MetricsCardinality.ApplyTo()
+	o.Panic.ApplyTo()
+	o.AutoProfile.ApplyTo()
+	if lastErr = o.Runtime.ApplyTo(); lastErr != nil {
+		return
+	}
+	if lastErr = o.LongRunning.ApplyTo(&recommendedConfig.Config); lastErr != nil {
+		return
+	}
+	if lastErr = o.CORS.ApplyTo(); lastErr != nil {
+		return
+	}
+	if lastErr = o.WildcardHost.ApplyTo(); lastErr != nil {
+		return
+	}
+	if lastErr = o.Notification.ApplyTo(); lastErr != nil {
+		return
+	}
+	if lastErr = o.UserAgentRule.ApplyTo(); lastErr != nil {
+		return
+	}
+	if lastErr = o.VirtualCluster.ApplyTo(); lastErr != nil {
+		return
+	}
+
 	// create upstream controller
-	clusterController := controllers.NewUpstreamClusterController(controlplaneServerConfig.ExtraConfig.GatewaySharedInformerFactory.Proxy().V1alpha1().UpstreamClusters())
+	clusterController := controllers.NewUpstreamClusterController(controlplaneServerConfig.ExtraConfig.GatewaySharedInformerFactory.Proxy().V1alpha1().UpstreamClusters(), clusterDefaults)
+	o.UpstreamProbe.ApplyTo(clusterController, controlplaneServerConfig.ExtraConfig.GatewayClientset)
+	o.UpstreamCanary.ApplyTo(clusterController, o.SecureServing.Ports)
+	if lastErr = o.EndpointState.ApplyTo(clusterController); lastErr != nil {
+		return
+	}
+	if lastErr = o.Maintenance.ApplyTo(clusterController); lastErr != nil {
+		return
+	}
+	if lastErr = o.TLSPolicy.ApplyTo(clusterController, o.SecureServing.Ports); lastErr != nil {
+		return
+	}
 	// Dynamic SNI for upstream cluster
 	recommendedConfig.Config.SecureServing.DynamicClientConfig = clusterController
 	// Proxy handler
-	recommendedConfig.Config.BuildHandlerChainFunc = buildProxyHandlerChainFunc(clusterController, o.Logging.EnableProxyAccessLog)
+	fleet := o.Fleet.ToFleetRoute()
+	drain := o.Drain.ToDrainPolicy()
+	recorder, lastErr := o.TrafficCapture.ApplyTo(&recommendedConfig.Config)
+	if lastErr != nil {
+		return
+	}
+	recommendedConfig.Config.BuildHandlerChainFunc = embedded.NewHandlerChainFunc(clusterController, embedded.DispatchConfig{
+		Config: proxydispatcher.Config{
+			EnableAccessLog:        o.Logging.EnableProxyAccessLog,
+			Fleet:                  fleet,
+			Retry:                  o.UpstreamRetry.ToRetryPolicy(),
+			Exemption:              o.RateLimitExemption.ToRateLimitExemption(),
+			Rewrite:                o.URLRewrite.ToURLRewritePolicy(),
+			Priority:               o.RequestPriority.ToPriorityPolicy(),
+			Shedding:               o.LoadShedding.ToLoadSheddingPolicy(),
+			RateLimitHeaders:       o.RateLimitHeaders.ToRateLimitHeaders(),
+			Bandwidth:              o.StreamingBandwidth.ToBandwidthPolicy(),
+			DisableSplice:          o.StreamingBandwidth.SpliceDisabled(),
+			Drain:                  drain,
+			ExpiredResourceVersion: o.ExpiredRV.ToExpiredResourceVersionPolicy(),
+			AdaptiveTimeout:        o.AdaptiveTimeout.ToAdaptiveTimeoutPolicy(),
+			Abuse:                  o.AbuseReport.ToAbuseReporter(controlplaneServerConfig.RecommendedConfig.LoopbackClientset),
+			DiscoveryCache:         o.DiscoveryCache.ToDiscoveryCachePolicy(),
+			CostEstimation:         o.CostEstimation.ToCostEstimationPolicy(),
+			NoRoute:                o.NoRoute.ToNoRoutePolicy(),
+		},
+		Recorder:   recorder,
+		Validation: o.RequestValidation.ToRequestValidation(),
+	})
+
+	// requests to fleet hostname are only authenticated by gateway-level authenticators, members authorize them
+	var clientProvider clusters.ClientProvider = clusterController
+	if fleet != nil {
+		clientProvider = clusters.NewFleetClientProvider(clusterController, fleet.Hostname, fleet.Clusters)
+	}
 
 	// Proxy authentication
 	if lastErr = o.Authentication.ApplyTo(
@@ -75,14 +155,14 @@ func CreateProxyConfig(
 		recommendedConfig.SecureServing,
 		recommendedConfig.OpenAPIConfig,
 		clusterController,
-		clusterController,
+		clientProvider,
 		controlplaneOptions.Authentication,
 	); lastErr != nil {
 		return
 	}
 
 	// Proxy authorization
-	if lastErr = o.Authorization.ApplyTo(&recommendedConfig.Config, clusterController); lastErr != nil {
+	if lastErr = o.Authorization.ApplyTo(&recommendedConfig.Config, clientProvider); lastErr != nil {
 		return
 	}
 
@@ -96,6 +176,9 @@ func CreateProxyConfig(
 		RecommendedConfig: recommendedConfig,
 		ExtraConfig: proxyserver.ExtraConfig{
 			UpstreamClusterController: clusterController,
+			InternalH2CBindAddress:    o.InternalListener.H2CBindAddress,
+			Coordinator:               o.Coordination.ToCoordinator(controlplaneServerConfig.RecommendedConfig.LoopbackClientset, o.UpstreamProbe.ReplicaName),
+			Drain:                     drain,
 		},
 	}
 	return serverConfig, nil
@@ -110,36 +193,6 @@ func buildProxyRecommenedOptions(o *options.ProxyOptions, controlplaneOptions *o
 	return recommenedOptions
 }
 
-func buildProxyHandlerChainFunc(clusterManager clusters.Manager, enableAccessLog bool) func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
-	return func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
-		// new gateway handler chain
-		handler := gatewayfilters.WithDispatcher(apiHandler, proxydispatcher.NewDispatcher(clusterManager, enableAccessLog))
-		// without impersonation log
-		handler = gatewayfilters.WithNoLoggingImpersonation(handler, c.Authorization.Authorizer, c.Serializer)
-		// new gateway handler chain, add impersonator userInfo
-		handler = gatewayfilters.WithImpersonator(handler)
-		handler = genericapifilters.WithAudit(handler, c.AuditBackend, c.AuditPolicyChecker, c.LongRunningFunc)
-		failedHandler := genericapifilters.Unauthorized(c.Serializer, c.Authentication.SupportsBasicAuth)
-		failedHandler = genericapifilters.WithFailedAuthenticationAudit(failedHandler, c.AuditBackend, c.AuditPolicyChecker)
-		handler = genericapifilters.WithAuthentication(handler, c.Authentication.Authenticator, failedHandler, c.Authentication.APIAudiences)
-		handler = genericfilters.WithCORS(handler, c.CorsAllowedOriginList, nil, nil, nil, "true")
-		// disabel timeout, let upstream cluster handle it
-		// handler = gatewayfilters.WithTimeoutForNonLongRunningRequests(handler, c.LongRunningFunc, c.RequestTimeout)
-		handler = genericfilters.WithWaitGroup(handler, c.LongRunningFunc, c.HandlerChainWaitGroup)
-		// new gateway handler chain
-		handler = gatewayfilters.WithPreProcessingMetrics(handler)
-		handler = gatewayfilters.WithExtraRequestInfo(handler, &request.ExtraRequestInfoFactory{})
-		handler = gatewayfilters.WithTerminationMetrics(handler)
-		handler = genericapifilters.WithRequestInfo(handler, c.RequestInfoResolver)
-		if c.SecureServing != nil && !c.SecureServing.DisableHTTP2 && c.GoawayChance > 0 {
-			handler = genericfilters.WithProbabilisticGoaway(handler, c.GoawayChance)
-		}
-		handler = genericapifilters.WithCacheControl(handler)
-		handler = gatewayfilters.WithNoLoggingPanicRecovery(handler)
-		return handler
-	}
-}
-
 func GetNativeOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return nativeopenapi.GetOpenAPIDefinitions(ref)
 }
@@ -153,6 +206,6 @@ func (writer proxyHTTPErrorLogWriter) Write(data []byte) (n int, err error) {
 	if bytes.HasPrefix(data, []byte("http: TLS handshake error from")) {
 		return 0, nil
 	}
-	klog.InfoDepth(4, string(data))
+	klog.InfoDepth(4, redact.Text(string(data)))
 	return len(data), nil
 }
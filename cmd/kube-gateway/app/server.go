@@ -19,6 +19,7 @@ package app
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -36,6 +37,9 @@ import (
 	"github.com/kubewharf/apiserver-runtime/pkg/server"
 
 	"github.com/kubewharf/kubegateway/cmd/kube-gateway/app/options"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
 )
 
 const (
@@ -56,6 +60,15 @@ cluster's shared state through which all other components interact.`,
 			verflag.PrintAndExitIfRequested()
 			utilflag.PrintFlags(cmd.Flags())
 
+			// load options from config file and let command line flags override it
+			if len(s.ConfigFile) > 0 {
+				configured, err := options.NewOptionsFromConfigFile(s.ConfigFile, os.Args[1:])
+				if err != nil {
+					return err
+				}
+				s = configured
+			}
+
 			// set default options
 			err := s.Complete()
 			if err != nil {
@@ -67,11 +80,22 @@ cluster's shared state through which all other components interact.`,
 				return utilerrors.NewAggregate(errs)
 			}
 
+			// verify TLS material and upstream endpoints before serving
+			if err := runPreflight(s, cmd.OutOrStdout()); err != nil {
+				return err
+			}
+			if s.Preflight.Only {
+				return nil
+			}
+
 			return Run(s, genericapiserver.SetupSignalHandler())
 		},
 		SilenceUsage: true,
 	}
 
+	cmd.AddCommand(NewAnalyzeCaptureCommand())
+	cmd.AddCommand(NewOnboardClusterCommand())
+
 	fs := cmd.Flags()
 	namedFlagSets := s.Flags()
 	verflag.AddFlags(namedFlagSets.FlagSet("global"))
@@ -99,6 +123,7 @@ cluster's shared state through which all other components interact.`,
 func Run(completeOptions *options.Options, stopCh <-chan struct{}) error {
 	// To help debugging, immediately log version
 	klog.Infof("Version: %+v", version.Get())
+	gatewayfeatures.RecordMetrics()
 
 	server, err := CreateKubeGatewayServer(completeOptions, stopCh)
 	if err != nil {
@@ -111,7 +136,9 @@ func Run(completeOptions *options.Options, stopCh <-chan struct{}) error {
 
 // CreateKubeGatewayServer creates the apiservers connected via delegation.
 func CreateKubeGatewayServer(o *options.Options, stopCh <-chan struct{}) (*server.GenericServer, error) {
-	controlPlaneConfig, err := CreateControlPlaneConfig(o.ControlPlane)
+	// defaults of upstream clusters are filled by proxy options, control plane admits UpstreamClusters against them
+	clusterDefaults := clusters.NewClusterDefaults()
+	controlPlaneConfig, err := CreateControlPlaneConfig(o.ControlPlane, clusterDefaults)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +146,7 @@ func CreateKubeGatewayServer(o *options.Options, stopCh <-chan struct{}) (*serve
 	if err != nil {
 		return nil, err
 	}
-	proxyConfig, err := CreateProxyConfig(o.Proxy, o.ControlPlane, controlPlaneConfig)
+	proxyConfig, err := CreateProxyConfig(o.Proxy, o.ControlPlane, controlPlaneConfig, clusterDefaults)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +155,22 @@ func CreateKubeGatewayServer(o *options.Options, stopCh <-chan struct{}) (*serve
 		return nil, err
 	}
 
+	if o.ControlPlane.Features != nil && o.ControlPlane.Features.EnableProfiling {
+		// pprof handlers are installed by generic apiserver, add gateway debugging handlers
+		gatewaydebug.Install(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux)
+	}
+	gatewaydebug.InstallEndpointScores(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, proxyConfig.ExtraConfig.UpstreamClusterController)
+	gatewaydebug.InstallPanics(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, gatewaydebug.DefaultPanicPolicy)
+	gatewaydebug.InstallAutoProfiles(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, gatewaydebug.DefaultAutoProfiler)
+	gatewaydebug.InstallSoftState(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, proxyConfig.ExtraConfig.UpstreamClusterController)
+	gatewaydebug.InstallEndpointBatch(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, proxyConfig.ExtraConfig.UpstreamClusterController)
+	gatewaydebug.InstallTunables(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux)
+	gatewaydebug.InstallRejections(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux)
+	if gatewayfeatures.Enabled(gatewayfeatures.FaultInjection) {
+		klog.Warningf("feature gate %s is enabled, faults can be injected by %s", gatewayfeatures.FaultInjection, gatewaydebug.FaultsPath)
+		gatewaydebug.InstallFaultInjection(controlPlaneServer.GenericAPIServer.Handler.NonGoRestfulMux, proxyConfig.ExtraConfig.UpstreamClusterController)
+	}
+
 	controlPlaneServer.AddSidecarServers(proxyServer)
 	return controlPlaneServer, nil
 }
@@ -0,0 +1,89 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// embedded-proxy is a minimal binary embedding kube-gateway proxy as a library. It proxies
+// requests to upstream clusters defined in the gateway control plane by hostname, clients are
+// authenticated by a static token file and all authenticated requests are allowed.
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	"k8s.io/apiserver/pkg/authentication/token/tokenfile"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	"github.com/kubewharf/kubegateway/pkg/gateway/embedded"
+	proxydispatcher "github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+func main() {
+	klog.InitFlags(nil)
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig of gateway control plane which serves UpstreamCluster objects.")
+	tokenFile := flag.String("token-auth-file", "", "Path to the static token file which authenticates clients.")
+	bindAddress := flag.String("bind-address", ":9443", "The address to serve proxy.")
+	certFile := flag.String("tls-cert-file", "", "Path to the serving certificate.")
+	keyFile := flag.String("tls-private-key-file", "", "Path to the serving private key.")
+	flag.Parse()
+
+	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	if err != nil {
+		klog.Fatalf("failed to load kubeconfig: %v", err)
+	}
+	client, err := gatewayclientset.NewForConfig(config)
+	if err != nil {
+		klog.Fatalf("failed to create gateway client: %v", err)
+	}
+	tokens, err := tokenfile.NewCSV(*tokenFile)
+	if err != nil {
+		klog.Fatalf("failed to load token file: %v", err)
+	}
+
+	gateway, err := embedded.New(client,
+		embedded.WithAuthenticator(bearertoken.New(tokens)),
+		embedded.WithAuthorizer(authorizerfactory.NewAlwaysAllowAuthorizer()),
+		embedded.WithDispatchConfig(embedded.DispatchConfig{Config: proxydispatcher.Config{EnableAccessLog: true}}),
+	)
+	if err != nil {
+		klog.Fatalf("failed to create gateway: %v", err)
+	}
+	if err := gateway.Start(); err != nil {
+		klog.Fatalf("failed to start gateway: %v", err)
+	}
+
+	server := &http.Server{Addr: *bindAddress, Handler: gateway.Handler()}
+	stopCh := genericapiserver.SetupSignalHandler()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(ctx) //nolint
+		gateway.Stop()
+	}()
+
+	klog.Infof("serving embedded proxy on %s", *bindAddress)
+	if err := server.ListenAndServeTLS(*certFile, *keyFile); err != nil && err != http.ErrServerClosed {
+		klog.Fatalf("failed to serve: %v", err)
+	}
+	<-stopped
+}
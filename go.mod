@@ -6,16 +6,19 @@ require (
 	github.com/go-openapi/spec v0.19.3
 	github.com/gobeam/stringy v0.0.5
 	github.com/gogo/protobuf v1.3.2
+	github.com/google/cel-go v0.10.4
 	github.com/kubewharf/apiserver-runtime v0.0.0
 	github.com/libp2p/go-reuseport v0.2.0
 	github.com/pires/go-proxyproto v0.6.2
 	github.com/pkg/errors v0.9.1
-	github.com/prometheus/client_golang v1.0.0
+	github.com/prometheus/client_golang v1.14.0
+	github.com/prometheus/client_model v0.3.0
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/pflag v1.0.5
 	github.com/zoumo/golib v0.0.0-20211216092524-c9bb48ad7bef
 	github.com/zoumo/goset v0.2.0
-	golang.org/x/net v0.0.0-20211101194204-95aca89e93de
+	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
+	golang.org/x/net v0.0.0-20220225172249-27dd8689420f
 	k8s.io/api v0.18.10
 	k8s.io/apiextensions-apiserver v0.18.10
 	k8s.io/apimachinery v0.18.19
@@ -27,13 +30,14 @@ require (
 	k8s.io/kube-openapi v0.0.0-20200410145947-61e04a5be9a6
 	k8s.io/kubernetes v1.18.10
 	sigs.k8s.io/controller-runtime v0.6.0
+	sigs.k8s.io/yaml v1.2.0
 )
 
 replace (
 	github.com/go-logr/logr => github.com/go-logr/logr v0.1.0
 	github.com/go-logr/zapr => github.com/go-logr/zapr v0.1.0
 	github.com/golang/groupcache => github.com/golang/groupcache v0.0.0-20160516000752-02826c3e7903
-	github.com/golang/protobuf => github.com/golang/protobuf v1.3.2
+	github.com/golang/protobuf => github.com/golang/protobuf v1.4.3
 	github.com/google/go-cmp => github.com/google/go-cmp v0.3.0
 	github.com/googleapis/gnostic => github.com/googleapis/gnostic v0.1.0
 	github.com/gorilla/websocket => github.com/gorilla/websocket v1.4.0
@@ -50,6 +54,8 @@ replace (
 	golang.org/x/sys => golang.org/x/sys v0.0.0-20190813064441-fde4db37ae7a
 	golang.org/x/tools => golang.org/x/tools v0.0.0-20190821162956-65e3620a7ae7
 	golang.org/x/xerrors => golang.org/x/xerrors v0.0.0-20190717185122-a985d3407aa7
+	// pin grpc to the version of k8s 1.18 and etcd clientv3, dependencies of cel-go require a newer one
+	google.golang.org/grpc => google.golang.org/grpc v1.26.0
 	gopkg.in/check.v1 => gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127
 	k8s.io/api => k8s.io/api v0.18.10
 	k8s.io/apiextensions-apiserver => k8s.io/apiextensions-apiserver v0.18.10
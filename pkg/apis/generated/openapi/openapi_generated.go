@@ -36,12 +36,14 @@ func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenA
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ClientConfig":                         schema_pkg_apis_proxy_v1alpha1_ClientConfig(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.DispatchPolicy":                       schema_pkg_apis_proxy_v1alpha1_DispatchPolicy(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.DispatchPolicyRule":                   schema_pkg_apis_proxy_v1alpha1_DispatchPolicyRule(ref),
+		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.EndpointReachability":                 schema_pkg_apis_proxy_v1alpha1_EndpointReachability(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ExemptFlowControlSchema":              schema_pkg_apis_proxy_v1alpha1_ExemptFlowControlSchema(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.FlowControl":                          schema_pkg_apis_proxy_v1alpha1_FlowControl(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.FlowControlSchema":                    schema_pkg_apis_proxy_v1alpha1_FlowControlSchema(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.FlowControlSchemaConfiguration":       schema_pkg_apis_proxy_v1alpha1_FlowControlSchemaConfiguration(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.LoggingConfig":                        schema_pkg_apis_proxy_v1alpha1_LoggingConfig(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.MaxRequestsInflightFlowControlSchema": schema_pkg_apis_proxy_v1alpha1_MaxRequestsInflightFlowControlSchema(ref),
+		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ReplicaReachability":                  schema_pkg_apis_proxy_v1alpha1_ReplicaReachability(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.SecretReferecence":                    schema_pkg_apis_proxy_v1alpha1_SecretReferecence(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.SecureServing":                        schema_pkg_apis_proxy_v1alpha1_SecureServing(ref),
 		"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ServiceAccountRef":                    schema_pkg_apis_proxy_v1alpha1_ServiceAccountRef(ref),
@@ -365,6 +367,63 @@ func schema_pkg_apis_proxy_v1alpha1_DispatchPolicyRule(ref common.ReferenceCallb
 	}
 }
 
+func schema_pkg_apis_proxy_v1alpha1_EndpointReachability(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "EndpointReachability is the result of the last blackbox probe from a gateway replica to an endpoint",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"endpoint": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Endpoint is the upstream api server address",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"reachable": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reachable is true if all stages of probe succeeded",
+							Type:        []string{"boolean"},
+							Format:      "",
+						},
+					},
+					"failedStage": {
+						SchemaProps: spec.SchemaProps{
+							Description: "FailedStage is the first failed stage, one of tcp, tls and http",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"message": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Message is the error of failed stage",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"latencyMilliseconds": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LatencyMilliseconds is the total duration of all stages",
+							Type:        []string{"integer"},
+							Format:      "int64",
+						},
+					},
+					"lastProbeTime": {
+						SchemaProps: spec.SchemaProps{
+							Description: "LastProbeTime is the start time of the probe",
+							Ref:         ref("k8s.io/apimachinery/pkg/apis/meta/v1.Time"),
+						},
+					},
+				},
+				Required: []string{"endpoint", "reachable", "latencyMilliseconds", "lastProbeTime"},
+			},
+		},
+		Dependencies: []string{
+			"k8s.io/apimachinery/pkg/apis/meta/v1.Time"},
+	}
+}
+
 func schema_pkg_apis_proxy_v1alpha1_ExemptFlowControlSchema(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -513,6 +572,42 @@ func schema_pkg_apis_proxy_v1alpha1_MaxRequestsInflightFlowControlSchema(ref com
 	}
 }
 
+func schema_pkg_apis_proxy_v1alpha1_ReplicaReachability(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "ReplicaReachability is the reachability of endpoints observed by one gateway replica",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"replica": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Replica is the name of gateway replica, see --proxy-replica-name",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"endpoints": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Endpoints contains the last probe result of each endpoint",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.EndpointReachability"),
+									},
+								},
+							},
+						},
+					},
+				},
+				Required: []string{"replica"},
+			},
+		},
+		Dependencies: []string{
+			"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.EndpointReachability"},
+	}
+}
+
 func schema_pkg_apis_proxy_v1alpha1_SecretReferecence(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -812,8 +907,25 @@ func schema_pkg_apis_proxy_v1alpha1_UpstreamClusterStatus(ref common.ReferenceCa
 			SchemaProps: spec.SchemaProps{
 				Description: "UpstreamClusterStatus defines the observed state of UpstreamCluster",
 				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"reachability": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Reachability contains the endpoint reachability observed by blackbox probes of each gateway replica, so an endpoint partitioned from some replicas can be told from an unhealthy endpoint.",
+							Type:        []string{"array"},
+							Items: &spec.SchemaOrArray{
+								Schema: &spec.Schema{
+									SchemaProps: spec.SchemaProps{
+										Ref: ref("github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ReplicaReachability"),
+									},
+								},
+							},
+						},
+					},
+				},
 			},
 		},
+		Dependencies: []string{
+			"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1.ReplicaReachability"},
 	}
 }
 
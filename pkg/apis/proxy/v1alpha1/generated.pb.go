@@ -126,10 +126,38 @@ func (m *DispatchPolicyRule) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_DispatchPolicyRule proto.InternalMessageInfo
 
+func (m *EndpointReachability) Reset()      { *m = EndpointReachability{} }
+func (*EndpointReachability) ProtoMessage() {}
+func (*EndpointReachability) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d037ab291b4fff89, []int{3}
+}
+func (m *EndpointReachability) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *EndpointReachability) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
+func (m *EndpointReachability) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EndpointReachability.Merge(m, src)
+}
+func (m *EndpointReachability) XXX_Size() int {
+	return m.Size()
+}
+func (m *EndpointReachability) XXX_DiscardUnknown() {
+	xxx_messageInfo_EndpointReachability.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EndpointReachability proto.InternalMessageInfo
+
 func (m *ExemptFlowControlSchema) Reset()      { *m = ExemptFlowControlSchema{} }
 func (*ExemptFlowControlSchema) ProtoMessage() {}
 func (*ExemptFlowControlSchema) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{3}
+	return fileDescriptor_d037ab291b4fff89, []int{4}
 }
 func (m *ExemptFlowControlSchema) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -157,7 +185,7 @@ var xxx_messageInfo_ExemptFlowControlSchema proto.InternalMessageInfo
 func (m *FlowControl) Reset()      { *m = FlowControl{} }
 func (*FlowControl) ProtoMessage() {}
 func (*FlowControl) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{4}
+	return fileDescriptor_d037ab291b4fff89, []int{5}
 }
 func (m *FlowControl) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -185,7 +213,7 @@ var xxx_messageInfo_FlowControl proto.InternalMessageInfo
 func (m *FlowControlSchema) Reset()      { *m = FlowControlSchema{} }
 func (*FlowControlSchema) ProtoMessage() {}
 func (*FlowControlSchema) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{5}
+	return fileDescriptor_d037ab291b4fff89, []int{6}
 }
 func (m *FlowControlSchema) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -213,7 +241,7 @@ var xxx_messageInfo_FlowControlSchema proto.InternalMessageInfo
 func (m *FlowControlSchemaConfiguration) Reset()      { *m = FlowControlSchemaConfiguration{} }
 func (*FlowControlSchemaConfiguration) ProtoMessage() {}
 func (*FlowControlSchemaConfiguration) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{6}
+	return fileDescriptor_d037ab291b4fff89, []int{7}
 }
 func (m *FlowControlSchemaConfiguration) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -241,7 +269,7 @@ var xxx_messageInfo_FlowControlSchemaConfiguration proto.InternalMessageInfo
 func (m *LoggingConfig) Reset()      { *m = LoggingConfig{} }
 func (*LoggingConfig) ProtoMessage() {}
 func (*LoggingConfig) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{7}
+	return fileDescriptor_d037ab291b4fff89, []int{8}
 }
 func (m *LoggingConfig) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -269,7 +297,7 @@ var xxx_messageInfo_LoggingConfig proto.InternalMessageInfo
 func (m *MaxRequestsInflightFlowControlSchema) Reset()      { *m = MaxRequestsInflightFlowControlSchema{} }
 func (*MaxRequestsInflightFlowControlSchema) ProtoMessage() {}
 func (*MaxRequestsInflightFlowControlSchema) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{8}
+	return fileDescriptor_d037ab291b4fff89, []int{9}
 }
 func (m *MaxRequestsInflightFlowControlSchema) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -294,10 +322,38 @@ func (m *MaxRequestsInflightFlowControlSchema) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_MaxRequestsInflightFlowControlSchema proto.InternalMessageInfo
 
+func (m *ReplicaReachability) Reset()      { *m = ReplicaReachability{} }
+func (*ReplicaReachability) ProtoMessage() {}
+func (*ReplicaReachability) Descriptor() ([]byte, []int) {
+	return fileDescriptor_d037ab291b4fff89, []int{10}
+}
+func (m *ReplicaReachability) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *ReplicaReachability) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
+func (m *ReplicaReachability) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReplicaReachability.Merge(m, src)
+}
+func (m *ReplicaReachability) XXX_Size() int {
+	return m.Size()
+}
+func (m *ReplicaReachability) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReplicaReachability.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReplicaReachability proto.InternalMessageInfo
+
 func (m *SecretReferecence) Reset()      { *m = SecretReferecence{} }
 func (*SecretReferecence) ProtoMessage() {}
 func (*SecretReferecence) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{9}
+	return fileDescriptor_d037ab291b4fff89, []int{11}
 }
 func (m *SecretReferecence) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -325,7 +381,7 @@ var xxx_messageInfo_SecretReferecence proto.InternalMessageInfo
 func (m *SecureServing) Reset()      { *m = SecureServing{} }
 func (*SecureServing) ProtoMessage() {}
 func (*SecureServing) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{10}
+	return fileDescriptor_d037ab291b4fff89, []int{12}
 }
 func (m *SecureServing) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -353,7 +409,7 @@ var xxx_messageInfo_SecureServing proto.InternalMessageInfo
 func (m *ServiceAccountRef) Reset()      { *m = ServiceAccountRef{} }
 func (*ServiceAccountRef) ProtoMessage() {}
 func (*ServiceAccountRef) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{11}
+	return fileDescriptor_d037ab291b4fff89, []int{13}
 }
 func (m *ServiceAccountRef) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -381,7 +437,7 @@ var xxx_messageInfo_ServiceAccountRef proto.InternalMessageInfo
 func (m *TokenBucketFlowControlSchema) Reset()      { *m = TokenBucketFlowControlSchema{} }
 func (*TokenBucketFlowControlSchema) ProtoMessage() {}
 func (*TokenBucketFlowControlSchema) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{12}
+	return fileDescriptor_d037ab291b4fff89, []int{14}
 }
 func (m *TokenBucketFlowControlSchema) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -409,7 +465,7 @@ var xxx_messageInfo_TokenBucketFlowControlSchema proto.InternalMessageInfo
 func (m *UpstreamCluster) Reset()      { *m = UpstreamCluster{} }
 func (*UpstreamCluster) ProtoMessage() {}
 func (*UpstreamCluster) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{13}
+	return fileDescriptor_d037ab291b4fff89, []int{15}
 }
 func (m *UpstreamCluster) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -437,7 +493,7 @@ var xxx_messageInfo_UpstreamCluster proto.InternalMessageInfo
 func (m *UpstreamClusterList) Reset()      { *m = UpstreamClusterList{} }
 func (*UpstreamClusterList) ProtoMessage() {}
 func (*UpstreamClusterList) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{14}
+	return fileDescriptor_d037ab291b4fff89, []int{16}
 }
 func (m *UpstreamClusterList) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -465,7 +521,7 @@ var xxx_messageInfo_UpstreamClusterList proto.InternalMessageInfo
 func (m *UpstreamClusterServer) Reset()      { *m = UpstreamClusterServer{} }
 func (*UpstreamClusterServer) ProtoMessage() {}
 func (*UpstreamClusterServer) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{15}
+	return fileDescriptor_d037ab291b4fff89, []int{17}
 }
 func (m *UpstreamClusterServer) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -493,7 +549,7 @@ var xxx_messageInfo_UpstreamClusterServer proto.InternalMessageInfo
 func (m *UpstreamClusterSpec) Reset()      { *m = UpstreamClusterSpec{} }
 func (*UpstreamClusterSpec) ProtoMessage() {}
 func (*UpstreamClusterSpec) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{16}
+	return fileDescriptor_d037ab291b4fff89, []int{18}
 }
 func (m *UpstreamClusterSpec) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -521,7 +577,7 @@ var xxx_messageInfo_UpstreamClusterSpec proto.InternalMessageInfo
 func (m *UpstreamClusterStatus) Reset()      { *m = UpstreamClusterStatus{} }
 func (*UpstreamClusterStatus) ProtoMessage() {}
 func (*UpstreamClusterStatus) Descriptor() ([]byte, []int) {
-	return fileDescriptor_d037ab291b4fff89, []int{17}
+	return fileDescriptor_d037ab291b4fff89, []int{19}
 }
 func (m *UpstreamClusterStatus) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
@@ -550,12 +606,14 @@ func init() {
 	proto.RegisterType((*ClientConfig)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.ClientConfig")
 	proto.RegisterType((*DispatchPolicy)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.DispatchPolicy")
 	proto.RegisterType((*DispatchPolicyRule)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.DispatchPolicyRule")
+	proto.RegisterType((*EndpointReachability)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.EndpointReachability")
 	proto.RegisterType((*ExemptFlowControlSchema)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.ExemptFlowControlSchema")
 	proto.RegisterType((*FlowControl)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.FlowControl")
 	proto.RegisterType((*FlowControlSchema)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.FlowControlSchema")
 	proto.RegisterType((*FlowControlSchemaConfiguration)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.FlowControlSchemaConfiguration")
 	proto.RegisterType((*LoggingConfig)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.LoggingConfig")
 	proto.RegisterType((*MaxRequestsInflightFlowControlSchema)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.MaxRequestsInflightFlowControlSchema")
+	proto.RegisterType((*ReplicaReachability)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.ReplicaReachability")
 	proto.RegisterType((*SecretReferecence)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.SecretReferecence")
 	proto.RegisterType((*SecureServing)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.SecureServing")
 	proto.RegisterType((*ServiceAccountRef)(nil), "github.com.kubewharf.kubegateway.pkg.apis.proxy.v1alpha1.ServiceAccountRef")
@@ -572,102 +630,115 @@ func init() {
 }
 
 var fileDescriptor_d037ab291b4fff89 = []byte{
-	// 1515 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x57, 0x4f, 0x6f, 0xdb, 0xc6,
-	0x12, 0x37, 0x25, 0xcb, 0x96, 0x96, 0xfe, 0xbb, 0x7e, 0x86, 0xf5, 0x8c, 0x44, 0x32, 0xf8, 0x5e,
-	0x0b, 0x03, 0x69, 0xa9, 0x5a, 0x08, 0xda, 0xa0, 0x68, 0x0f, 0xa6, 0xed, 0x24, 0x46, 0xec, 0xc4,
-	0x59, 0xc5, 0x41, 0x51, 0x14, 0x45, 0x29, 0x7a, 0x4d, 0xb3, 0x96, 0x48, 0x7a, 0x77, 0xe9, 0x3f,
-	0x45, 0x0f, 0x39, 0xe4, 0x52, 0xa0, 0x28, 0x7a, 0xea, 0xa9, 0xe8, 0xbd, 0xdf, 0xc4, 0xb7, 0xe6,
-	0x98, 0x43, 0x2b, 0x34, 0xcc, 0xa9, 0x5f, 0x21, 0xa7, 0x62, 0x97, 0x4b, 0x91, 0x14, 0xed, 0xd8,
-	0x70, 0x7c, 0x23, 0x67, 0x7e, 0x3b, 0x33, 0x3b, 0x3b, 0x33, 0xfb, 0x5b, 0x70, 0xdf, 0x76, 0xd8,
-	0x5e, 0xd0, 0xd6, 0x2d, 0xaf, 0xdb, 0xd8, 0x0f, 0xda, 0xf8, 0x68, 0xcf, 0x24, 0xbb, 0xe2, 0xcb,
-	0x36, 0x19, 0x3e, 0x32, 0x4f, 0x1a, 0xfe, 0xbe, 0xdd, 0x30, 0x7d, 0x87, 0x36, 0x7c, 0xe2, 0x1d,
-	0x9f, 0x34, 0x0e, 0x97, 0xcc, 0x8e, 0xbf, 0x67, 0x2e, 0x35, 0x6c, 0xec, 0x62, 0x62, 0x32, 0xbc,
-	0xa3, 0xfb, 0xc4, 0x63, 0x1e, 0xbc, 0x93, 0x58, 0xd2, 0xfb, 0x96, 0xf4, 0x94, 0x25, 0xdd, 0xdf,
-	0xb7, 0x75, 0x6e, 0x49, 0x17, 0x96, 0xf4, 0xd8, 0xd2, 0xfc, 0x87, 0xa9, 0x18, 0x6c, 0xcf, 0xf6,
-	0x1a, 0xc2, 0x60, 0x3b, 0xd8, 0x15, 0x7f, 0xe2, 0x47, 0x7c, 0x45, 0x8e, 0xe6, 0x6f, 0xef, 0xdf,
-	0xa1, 0xba, 0xe3, 0xf1, 0xa0, 0xba, 0xa6, 0xb5, 0xe7, 0xb8, 0x98, 0xa4, 0xa2, 0xec, 0x62, 0x66,
-	0x36, 0x0e, 0x73, 0xe1, 0xcd, 0x37, 0xce, 0x5b, 0x45, 0x02, 0x97, 0x39, 0x5d, 0x9c, 0x5b, 0xf0,
-	0xf1, 0x45, 0x0b, 0xa8, 0xb5, 0x87, 0xbb, 0xe6, 0xe0, 0x3a, 0xed, 0xcf, 0x02, 0x18, 0x5b, 0xe9,
-	0x38, 0xd8, 0x65, 0x2b, 0x9e, 0xbb, 0xeb, 0xd8, 0xf0, 0x03, 0x50, 0x76, 0x5c, 0x8a, 0xad, 0x80,
-	0xe0, 0xaa, 0xb2, 0xa0, 0x2c, 0x96, 0x8d, 0xa9, 0xd3, 0x5e, 0x7d, 0x28, 0xec, 0xd5, 0xcb, 0xeb,
-	0x52, 0x8e, 0xfa, 0x08, 0xb8, 0x04, 0xd4, 0x36, 0x36, 0x09, 0x26, 0x4f, 0xbc, 0x7d, 0xec, 0x56,
-	0x0b, 0x0b, 0xca, 0xe2, 0x98, 0x31, 0x19, 0xf6, 0xea, 0xaa, 0x91, 0x88, 0x51, 0x1a, 0x03, 0xdf,
-	0x03, 0xa3, 0xfb, 0xf8, 0x64, 0xd5, 0x64, 0x66, 0xb5, 0x28, 0xe0, 0x6a, 0xd8, 0xab, 0x8f, 0x3e,
-	0x88, 0x44, 0x28, 0xd6, 0xc1, 0x45, 0x50, 0xb6, 0x30, 0x61, 0x02, 0x37, 0x2c, 0x70, 0x63, 0x3c,
-	0x86, 0x15, 0x29, 0x43, 0x7d, 0x2d, 0xd4, 0xc0, 0x88, 0x65, 0x0a, 0x5c, 0x49, 0xe0, 0x40, 0xd8,
-	0xab, 0x8f, 0xac, 0x2c, 0x0b, 0x94, 0xd4, 0xc0, 0x9b, 0xa0, 0x78, 0xe0, 0xd3, 0xea, 0xc8, 0x82,
-	0xb2, 0x58, 0x32, 0x54, 0xb9, 0xa1, 0xe2, 0xe3, 0xad, 0x16, 0xe2, 0x72, 0xf8, 0x3f, 0x50, 0x6a,
-	0x07, 0x84, 0xb2, 0xea, 0xa8, 0x00, 0x8c, 0x4b, 0x40, 0xc9, 0xe0, 0x42, 0x14, 0xe9, 0x60, 0x13,
-	0x80, 0x03, 0x9f, 0xae, 0x3a, 0x87, 0x0e, 0xf5, 0x48, 0xb5, 0x2c, 0x90, 0x50, 0x22, 0xc1, 0xe3,
-	0xad, 0x96, 0xd4, 0xa0, 0x14, 0x4a, 0x7b, 0x5e, 0x04, 0x13, 0xab, 0x0e, 0xf5, 0x4d, 0x66, 0xed,
-	0x6d, 0x79, 0x1d, 0xc7, 0x3a, 0x81, 0x77, 0x40, 0x99, 0x32, 0x7e, 0x04, 0xf6, 0x89, 0x48, 0x70,
-	0xc5, 0xb8, 0x11, 0x27, 0xb8, 0x25, 0xe5, 0x6f, 0x52, 0xdf, 0xa8, 0x8f, 0x86, 0x9f, 0x82, 0x89,
-	0xc0, 0xa7, 0x8c, 0x60, 0xb3, 0xdb, 0x0a, 0xda, 0x14, 0xb3, 0x6a, 0x61, 0xa1, 0xb8, 0x58, 0x31,
-	0x60, 0xd8, 0xab, 0x4f, 0x6c, 0x67, 0x34, 0x68, 0x00, 0x09, 0x0f, 0x40, 0x89, 0x04, 0x1d, 0x4c,
-	0xab, 0xc5, 0x85, 0xe2, 0xa2, 0xda, 0xdc, 0xd0, 0xaf, 0x5a, 0xff, 0x7a, 0x76, 0x3b, 0x28, 0xe8,
-	0xe0, 0x24, 0x5f, 0xfc, 0x8f, 0xa2, 0xc8, 0x13, 0x6c, 0x81, 0xd9, 0xdd, 0x8e, 0x77, 0xb4, 0xe2,
-	0xb9, 0x8c, 0x78, 0x9d, 0x96, 0xa8, 0xbf, 0x87, 0x66, 0x17, 0x8b, 0xe3, 0xac, 0x18, 0x37, 0xe5,
-	0xa2, 0xd9, 0xbb, 0x67, 0x81, 0xd0, 0xd9, 0x6b, 0xe1, 0x6d, 0x30, 0xda, 0xf1, 0xec, 0x4d, 0x6f,
-	0x07, 0x8b, 0xd3, 0xae, 0x18, 0xf3, 0xd2, 0xcc, 0xe8, 0x46, 0x24, 0x7e, 0x93, 0x7c, 0xa2, 0x18,
-	0xaa, 0xfd, 0x53, 0x04, 0x30, 0x1f, 0x37, 0xac, 0x83, 0xd2, 0x21, 0x26, 0x6d, 0x5a, 0x55, 0x44,
-	0x1e, 0x2b, 0x7c, 0x0b, 0x4f, 0xb9, 0x00, 0x45, 0x72, 0x78, 0x0b, 0x54, 0x4c, 0xdf, 0xb9, 0x47,
-	0xbc, 0xc0, 0xa7, 0x32, 0xd9, 0xe3, 0x61, 0xaf, 0x5e, 0x59, 0xde, 0x5a, 0x8f, 0x84, 0x28, 0xd1,
-	0x73, 0x30, 0xc1, 0xd4, 0x0b, 0x88, 0x25, 0xd3, 0x2c, 0xc1, 0x28, 0x16, 0xa2, 0x44, 0x0f, 0x3f,
-	0x01, 0xe3, 0xf1, 0x0f, 0xdf, 0x17, 0xad, 0x0e, 0x8b, 0x05, 0xd3, 0x61, 0xaf, 0x3e, 0x8e, 0xd2,
-	0x0a, 0x94, 0xc5, 0xf1, 0x98, 0x03, 0x8a, 0x09, 0xad, 0x96, 0x92, 0x98, 0xb7, 0xb9, 0x00, 0x45,
-	0x72, 0xf8, 0x93, 0x02, 0x26, 0x29, 0x26, 0x87, 0x8e, 0x85, 0x97, 0x2d, 0xcb, 0x0b, 0x5c, 0xc6,
-	0xeb, 0x9e, 0x1f, 0xfa, 0x83, 0xab, 0x1f, 0x7a, 0x2b, 0x63, 0x10, 0xe1, 0x5d, 0x63, 0x4e, 0xe6,
-	0x7d, 0x32, 0xab, 0xa2, 0x68, 0xd0, 0x39, 0xd4, 0x01, 0xe0, 0x91, 0xc9, 0x2c, 0x8e, 0x8a, 0xb0,
-	0x27, 0x78, 0xcf, 0x6c, 0xf7, 0xa5, 0x28, 0x85, 0x80, 0x9f, 0x83, 0x49, 0xd7, 0x73, 0xe3, 0x24,
-	0x6c, 0xa3, 0x0d, 0x5a, 0x2d, 0x8b, 0x45, 0x33, 0xdc, 0xdd, 0xc3, 0xac, 0x0a, 0x0d, 0x62, 0xb5,
-	0xff, 0x82, 0xb9, 0xb5, 0x63, 0xdc, 0xf5, 0x59, 0xae, 0xae, 0xb4, 0x5f, 0x15, 0xa0, 0xa6, 0xa4,
-	0xf0, 0x47, 0x05, 0xc0, 0x5c, 0x99, 0x45, 0xd5, 0xf0, 0x4e, 0xd9, 0xca, 0x79, 0x36, 0x26, 0xe3,
-	0x2a, 0x95, 0x3e, 0xd0, 0x19, 0x7e, 0xb5, 0x67, 0x05, 0x30, 0x9d, 0x5b, 0x0a, 0x17, 0xc0, 0xb0,
-	0xcb, 0xbb, 0x26, 0x9a, 0x15, 0x63, 0xd2, 0xd0, 0xb0, 0x68, 0x12, 0xa1, 0x81, 0xa7, 0x0a, 0xa8,
-	0xe5, 0xcc, 0x45, 0xe3, 0x3c, 0x20, 0x26, 0x73, 0xbc, 0x68, 0x30, 0xab, 0xcd, 0x2f, 0xae, 0x71,
-	0x4b, 0x19, 0xfb, 0xc6, 0xfb, 0x32, 0xac, 0xda, 0xdb, 0x71, 0xe8, 0x82, 0x38, 0xb5, 0x3f, 0x8a,
-	0xe0, 0x02, 0x13, 0x30, 0x00, 0x23, 0x58, 0x9c, 0xaf, 0xc8, 0x88, 0xda, 0x7c, 0x7c, 0xf5, 0x4d,
-	0x9d, 0x53, 0x27, 0xd1, 0x0d, 0x12, 0x29, 0x91, 0x74, 0x06, 0x7f, 0x57, 0xc0, 0x4c, 0xd7, 0x3c,
-	0x46, 0xf8, 0x20, 0xc0, 0x94, 0xd1, 0x75, 0x77, 0xb7, 0xe3, 0xd8, 0x7b, 0x4c, 0x66, 0xf6, 0xeb,
-	0xab, 0x07, 0xb1, 0x99, 0x37, 0x9a, 0x8f, 0x68, 0x2e, 0xec, 0xd5, 0x67, 0xce, 0x40, 0xa2, 0xb3,
-	0x62, 0x82, 0x3f, 0x28, 0x40, 0x65, 0xfc, 0xb2, 0x35, 0x02, 0x6b, 0x1f, 0x33, 0x71, 0xcf, 0xaa,
-	0xcd, 0xa7, 0x57, 0x8f, 0xf1, 0x49, 0x62, 0xec, 0x8c, 0xda, 0xe6, 0xd7, 0x7d, 0x0a, 0x81, 0xd2,
-	0xbe, 0xb5, 0xcf, 0xc0, 0xf8, 0x86, 0x67, 0xdb, 0x8e, 0x6b, 0x4b, 0x82, 0x71, 0x0b, 0x0c, 0x77,
-	0xf9, 0xf8, 0x8e, 0xea, 0x39, 0x1e, 0x23, 0xc3, 0x83, 0xb3, 0x5b, 0x80, 0xb4, 0x35, 0xf0, 0xff,
-	0xcb, 0xe4, 0x87, 0xdf, 0xef, 0x5d, 0xf3, 0x58, 0xd8, 0x4c, 0xdd, 0xef, 0x7c, 0x29, 0x97, 0x6b,
-	0xbb, 0x60, 0xba, 0x85, 0x2d, 0x82, 0xf9, 0xe4, 0xc2, 0x04, 0x5b, 0xd8, 0xb5, 0x30, 0x6c, 0x80,
-	0x0a, 0x6f, 0x1f, 0xea, 0x9b, 0x56, 0x1c, 0xcd, 0xb4, 0x5c, 0x59, 0x79, 0x18, 0x2b, 0x50, 0x82,
-	0xe9, 0x77, 0x62, 0xe1, 0xbc, 0x4e, 0xd4, 0x7e, 0x51, 0xc0, 0x78, 0x4b, 0x30, 0x23, 0x31, 0x15,
-	0x5d, 0x3b, 0xcd, 0x76, 0x94, 0x4b, 0xb2, 0x9d, 0xc2, 0x5b, 0xd9, 0xce, 0x6d, 0x30, 0x66, 0x45,
-	0x7c, 0x6d, 0x39, 0xc5, 0xa1, 0xa6, 0xc2, 0x5e, 0x3d, 0xe6, 0x71, 0x11, 0xf3, 0xc9, 0xa0, 0xa2,
-	0x04, 0x0c, 0x8c, 0xf0, 0x4b, 0x4c, 0x96, 0x4c, 0x8a, 0x0a, 0x17, 0xa7, 0x48, 0x6b, 0x83, 0x1b,
-	0x6f, 0xab, 0x95, 0x98, 0x87, 0x29, 0x17, 0xf1, 0xb0, 0xc2, 0xf9, 0x3c, 0x4c, 0xfb, 0xab, 0x00,
-	0x26, 0x63, 0xb6, 0xb3, 0xd2, 0x09, 0x28, 0xc3, 0x04, 0x7e, 0x03, 0xca, 0x9c, 0x4a, 0xef, 0xc4,
-	0x79, 0x56, 0x9b, 0x1f, 0xe9, 0x11, 0x23, 0xd6, 0xd3, 0x8c, 0x38, 0x29, 0x70, 0x8e, 0xd6, 0x0f,
-	0x97, 0xf4, 0x47, 0xed, 0x6f, 0xb1, 0xc5, 0x36, 0x31, 0x33, 0x13, 0x2e, 0x97, 0xc8, 0x50, 0xdf,
-	0x2a, 0xf4, 0xc0, 0x30, 0xf5, 0xb1, 0x25, 0xfb, 0x7d, 0xf3, 0xea, 0xbd, 0x34, 0x10, 0x7a, 0xcb,
-	0xc7, 0x56, 0x92, 0x7b, 0xfe, 0x87, 0x84, 0x23, 0x78, 0x04, 0x46, 0x28, 0x33, 0x59, 0x40, 0x65,
-	0xfb, 0x3e, 0xba, 0x3e, 0x97, 0xc2, 0xac, 0x31, 0x21, 0x9d, 0x8e, 0x44, 0xff, 0x48, 0xba, 0xd3,
-	0x5e, 0x2b, 0x60, 0x66, 0x60, 0xc5, 0x86, 0x43, 0x19, 0xfc, 0x2a, 0x97, 0x63, 0xfd, 0x72, 0x39,
-	0xe6, 0xab, 0x45, 0x86, 0xfb, 0x2f, 0x89, 0x58, 0x92, 0xca, 0xaf, 0x0b, 0x4a, 0x0e, 0xc3, 0xdd,
-	0x88, 0x66, 0xa9, 0xcd, 0xf5, 0x6b, 0xdb, 0x6d, 0x52, 0x45, 0xeb, 0xdc, 0x3e, 0x8a, 0xdc, 0x68,
-	0x1e, 0x98, 0x1d, 0x4c, 0x0b, 0x26, 0x87, 0x98, 0xf0, 0x07, 0x10, 0x76, 0x77, 0x7c, 0xcf, 0x71,
-	0x99, 0xec, 0x8c, 0x7e, 0xd8, 0x6b, 0x52, 0x8e, 0xfa, 0x08, 0xde, 0xb8, 0x3b, 0x0e, 0x35, 0xdb,
-	0x1d, 0xbc, 0x23, 0x4a, 0xa3, 0x1c, 0x35, 0xee, 0xaa, 0x94, 0xa1, 0xbe, 0x56, 0xfb, 0x6d, 0x24,
-	0x97, 0x56, 0x7e, 0xda, 0xf0, 0x3b, 0x30, 0x4a, 0x85, 0xe7, 0x98, 0x78, 0x5c, 0xe3, 0x41, 0x0b,
-	0xbb, 0x29, 0xf2, 0x11, 0xf9, 0x41, 0xb1, 0x43, 0xf8, 0x4c, 0xe9, 0x4f, 0x13, 0x31, 0x9c, 0x65,
-	0x75, 0xdf, 0xbd, 0x7a, 0x04, 0xe9, 0xb7, 0xa4, 0xf1, 0x1f, 0xe9, 0x38, 0xf3, 0xc2, 0x44, 0x19,
-	0x8f, 0xf0, 0xb9, 0x02, 0xc6, 0x69, 0x7a, 0x64, 0xca, 0x72, 0xbf, 0xf7, 0x2e, 0x64, 0x35, 0x65,
-	0xce, 0x98, 0x95, 0x41, 0x64, 0x07, 0x33, 0xca, 0x3a, 0x85, 0xdf, 0x03, 0x35, 0x45, 0x4d, 0xc4,
-	0x13, 0x45, 0x6d, 0xae, 0x5d, 0x0b, 0x5f, 0x32, 0x66, 0x64, 0x04, 0x69, 0xee, 0x89, 0xd2, 0xee,
-	0x38, 0x67, 0x9f, 0xda, 0x49, 0xbf, 0x4f, 0x1c, 0x1c, 0x11, 0x7c, 0xb5, 0x79, 0xff, 0xba, 0x5e,
-	0x6a, 0x46, 0x55, 0x86, 0x31, 0xb5, 0x3a, 0xe0, 0x09, 0xe5, 0x7c, 0x43, 0x22, 0x9e, 0x59, 0xfc,
-	0xd6, 0x16, 0x6f, 0xe6, 0x77, 0x3a, 0x8e, 0xcc, 0xf5, 0x9f, 0x14, 0xa3, 0x14, 0xa3, 0xd8, 0x91,
-	0x36, 0x97, 0xef, 0xc8, 0x68, 0x50, 0xe9, 0xa7, 0xaf, 0x6a, 0x43, 0x2f, 0x5e, 0xd5, 0x86, 0x5e,
-	0xbe, 0xaa, 0x0d, 0x3d, 0x0b, 0x6b, 0xca, 0x69, 0x58, 0x53, 0x5e, 0x84, 0x35, 0xe5, 0x65, 0x58,
-	0x53, 0xfe, 0x0e, 0x6b, 0xca, 0xcf, 0xaf, 0x6b, 0x43, 0x5f, 0x96, 0x63, 0x87, 0xff, 0x06, 0x00,
-	0x00, 0xff, 0xff, 0x18, 0xb6, 0xc3, 0xdc, 0x26, 0x12, 0x00, 0x00,
+	// 1725 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x58, 0x4f, 0x6f, 0xdb, 0xc8,
+	0x15, 0x37, 0x25, 0x5b, 0x7f, 0x1e, 0x2d, 0x3b, 0x19, 0x6f, 0x10, 0x35, 0xdd, 0x95, 0x0c, 0xf6,
+	0x0f, 0xbc, 0xdd, 0x96, 0x6a, 0x84, 0xb4, 0x0d, 0x8a, 0xf6, 0x10, 0xda, 0xc9, 0xae, 0xb1, 0x76,
+	0xd6, 0x19, 0x25, 0x8b, 0xa2, 0x28, 0x8a, 0x8e, 0xa8, 0x11, 0xc5, 0x9a, 0x22, 0x19, 0xce, 0xd0,
+	0x89, 0x8b, 0x02, 0xcd, 0x61, 0x81, 0xa2, 0x40, 0xd1, 0xf6, 0xd4, 0x53, 0x51, 0xa0, 0xc7, 0x7e,
+	0x8c, 0xde, 0x72, 0xeb, 0x1e, 0xf7, 0xd0, 0x0a, 0x8d, 0xf6, 0xd4, 0xaf, 0x90, 0x53, 0x31, 0xc3,
+	0xa1, 0x48, 0x4a, 0x4a, 0xec, 0xda, 0xbe, 0x89, 0xef, 0xfd, 0xde, 0xfb, 0x3d, 0xbe, 0x79, 0xf3,
+	0xde, 0xa3, 0xe0, 0x23, 0xc7, 0xe5, 0xa3, 0xb8, 0x6f, 0xda, 0xc1, 0xb8, 0x73, 0x1c, 0xf7, 0xe9,
+	0xb3, 0x11, 0x89, 0x86, 0xf2, 0x97, 0x43, 0x38, 0x7d, 0x46, 0x4e, 0x3b, 0xe1, 0xb1, 0xd3, 0x21,
+	0xa1, 0xcb, 0x3a, 0x61, 0x14, 0x3c, 0x3f, 0xed, 0x9c, 0xdc, 0x26, 0x5e, 0x38, 0x22, 0xb7, 0x3b,
+	0x0e, 0xf5, 0x69, 0x44, 0x38, 0x1d, 0x98, 0x61, 0x14, 0xf0, 0x00, 0xdd, 0xcd, 0x3c, 0x99, 0x33,
+	0x4f, 0x66, 0xce, 0x93, 0x19, 0x1e, 0x3b, 0xa6, 0xf0, 0x64, 0x4a, 0x4f, 0x66, 0xea, 0xe9, 0xd6,
+	0x77, 0x72, 0x31, 0x38, 0x81, 0x13, 0x74, 0xa4, 0xc3, 0x7e, 0x3c, 0x94, 0x4f, 0xf2, 0x41, 0xfe,
+	0x4a, 0x88, 0x6e, 0xdd, 0x39, 0xbe, 0xcb, 0x4c, 0x37, 0x10, 0x41, 0x8d, 0x89, 0x3d, 0x72, 0x7d,
+	0x1a, 0xe5, 0xa2, 0x1c, 0x53, 0x4e, 0x3a, 0x27, 0x0b, 0xe1, 0xdd, 0xea, 0xbc, 0xc9, 0x2a, 0x8a,
+	0x7d, 0xee, 0x8e, 0xe9, 0x82, 0xc1, 0xf7, 0xcf, 0x32, 0x60, 0xf6, 0x88, 0x8e, 0xc9, 0xbc, 0x9d,
+	0xf1, 0xaf, 0x12, 0xac, 0xef, 0x7a, 0x2e, 0xf5, 0xf9, 0x6e, 0xe0, 0x0f, 0x5d, 0x07, 0x7d, 0x1b,
+	0x6a, 0xae, 0xcf, 0xa8, 0x1d, 0x47, 0xb4, 0xa9, 0x6d, 0x6b, 0x3b, 0x35, 0xeb, 0xda, 0xcb, 0x49,
+	0x7b, 0x65, 0x3a, 0x69, 0xd7, 0xf6, 0x95, 0x1c, 0xcf, 0x10, 0xe8, 0x36, 0xe8, 0x7d, 0x4a, 0x22,
+	0x1a, 0x3d, 0x0e, 0x8e, 0xa9, 0xdf, 0x2c, 0x6d, 0x6b, 0x3b, 0xeb, 0xd6, 0xe6, 0x74, 0xd2, 0xd6,
+	0xad, 0x4c, 0x8c, 0xf3, 0x18, 0xf4, 0x0d, 0xa8, 0x1e, 0xd3, 0xd3, 0x3d, 0xc2, 0x49, 0xb3, 0x2c,
+	0xe1, 0xfa, 0x74, 0xd2, 0xae, 0x7e, 0x9c, 0x88, 0x70, 0xaa, 0x43, 0x3b, 0x50, 0xb3, 0x69, 0xc4,
+	0x25, 0x6e, 0x55, 0xe2, 0xd6, 0x45, 0x0c, 0xbb, 0x4a, 0x86, 0x67, 0x5a, 0x64, 0x40, 0xc5, 0x26,
+	0x12, 0xb7, 0x26, 0x71, 0x30, 0x9d, 0xb4, 0x2b, 0xbb, 0xf7, 0x24, 0x4a, 0x69, 0xd0, 0x7b, 0x50,
+	0x7e, 0x1a, 0xb2, 0x66, 0x65, 0x5b, 0xdb, 0x59, 0xb3, 0x74, 0xf5, 0x42, 0xe5, 0x47, 0x47, 0x3d,
+	0x2c, 0xe4, 0xe8, 0x6b, 0xb0, 0xd6, 0x8f, 0x23, 0xc6, 0x9b, 0x55, 0x09, 0x68, 0x28, 0xc0, 0x9a,
+	0x25, 0x84, 0x38, 0xd1, 0xa1, 0x2e, 0xc0, 0xd3, 0x90, 0xed, 0xb9, 0x27, 0x2e, 0x0b, 0xa2, 0x66,
+	0x4d, 0x22, 0x91, 0x42, 0xc2, 0xa3, 0xa3, 0x9e, 0xd2, 0xe0, 0x1c, 0xca, 0xf8, 0xac, 0x0c, 0x1b,
+	0x7b, 0x2e, 0x0b, 0x09, 0xb7, 0x47, 0x47, 0x81, 0xe7, 0xda, 0xa7, 0xe8, 0x2e, 0xd4, 0x18, 0x17,
+	0x47, 0xe0, 0x9c, 0xca, 0x04, 0xd7, 0xad, 0x77, 0xd3, 0x04, 0xf7, 0x94, 0xfc, 0x75, 0xee, 0x37,
+	0x9e, 0xa1, 0xd1, 0x0f, 0x61, 0x23, 0x0e, 0x19, 0x8f, 0x28, 0x19, 0xf7, 0xe2, 0x3e, 0xa3, 0xbc,
+	0x59, 0xda, 0x2e, 0xef, 0xd4, 0x2d, 0x34, 0x9d, 0xb4, 0x37, 0x9e, 0x14, 0x34, 0x78, 0x0e, 0x89,
+	0x9e, 0xc2, 0x5a, 0x14, 0x7b, 0x94, 0x35, 0xcb, 0xdb, 0xe5, 0x1d, 0xbd, 0x7b, 0x60, 0x5e, 0xb4,
+	0xfe, 0xcd, 0xe2, 0xeb, 0xe0, 0xd8, 0xa3, 0x59, 0xbe, 0xc4, 0x13, 0xc3, 0x09, 0x13, 0xea, 0xc1,
+	0x8d, 0xa1, 0x17, 0x3c, 0xdb, 0x0d, 0x7c, 0x1e, 0x05, 0x5e, 0x4f, 0xd6, 0xdf, 0x43, 0x32, 0xa6,
+	0xf2, 0x38, 0xeb, 0xd6, 0x7b, 0xca, 0xe8, 0xc6, 0x83, 0x65, 0x20, 0xbc, 0xdc, 0x16, 0xdd, 0x81,
+	0xaa, 0x17, 0x38, 0x87, 0xc1, 0x80, 0xca, 0xd3, 0xae, 0x5b, 0xb7, 0x94, 0x9b, 0xea, 0x41, 0x22,
+	0x7e, 0x9d, 0xfd, 0xc4, 0x29, 0xd4, 0xf8, 0x6f, 0x19, 0xd0, 0x62, 0xdc, 0xa8, 0x0d, 0x6b, 0x27,
+	0x34, 0xea, 0xb3, 0xa6, 0x26, 0xf3, 0x58, 0x17, 0xaf, 0xf0, 0xa9, 0x10, 0xe0, 0x44, 0x8e, 0x3e,
+	0x80, 0x3a, 0x09, 0xdd, 0x0f, 0xa3, 0x20, 0x0e, 0x99, 0x4a, 0x76, 0x63, 0x3a, 0x69, 0xd7, 0xef,
+	0x1d, 0xed, 0x27, 0x42, 0x9c, 0xe9, 0x05, 0x38, 0xa2, 0x2c, 0x88, 0x23, 0x5b, 0xa5, 0x59, 0x81,
+	0x71, 0x2a, 0xc4, 0x99, 0x1e, 0xfd, 0x00, 0x1a, 0xe9, 0x83, 0x78, 0x2f, 0xd6, 0x5c, 0x95, 0x06,
+	0xd7, 0xa7, 0x93, 0x76, 0x03, 0xe7, 0x15, 0xb8, 0x88, 0x13, 0x31, 0xc7, 0x8c, 0x46, 0xac, 0xb9,
+	0x96, 0xc5, 0xfc, 0x44, 0x08, 0x70, 0x22, 0x47, 0x7f, 0xd0, 0x60, 0x93, 0xd1, 0xe8, 0xc4, 0xb5,
+	0xe9, 0x3d, 0xdb, 0x0e, 0x62, 0x9f, 0x8b, 0xba, 0x17, 0x87, 0xfe, 0xf1, 0xc5, 0x0f, 0xbd, 0x57,
+	0x70, 0x88, 0xe9, 0xd0, 0xba, 0xa9, 0xf2, 0xbe, 0x59, 0x54, 0x31, 0x3c, 0x4f, 0x8e, 0x4c, 0x00,
+	0x11, 0x99, 0xca, 0x62, 0x55, 0x86, 0xbd, 0x21, 0xee, 0xcc, 0x93, 0x99, 0x14, 0xe7, 0x10, 0xe8,
+	0xc7, 0xb0, 0xe9, 0x07, 0x7e, 0x9a, 0x84, 0x27, 0xf8, 0x80, 0x35, 0x6b, 0xd2, 0x68, 0x4b, 0xd0,
+	0x3d, 0x2c, 0xaa, 0xf0, 0x3c, 0xd6, 0xf8, 0x63, 0x19, 0xde, 0xb9, 0xef, 0x0f, 0xc2, 0xc0, 0x15,
+	0x81, 0x12, 0x7b, 0x44, 0xfa, 0xae, 0xe7, 0xf2, 0x53, 0xd1, 0xd9, 0xa8, 0x92, 0xab, 0x8b, 0x37,
+	0xeb, 0x6c, 0x33, 0xfc, 0x0c, 0x81, 0x3a, 0xe2, 0x34, 0xa5, 0xb5, 0x47, 0x65, 0x5f, 0xab, 0x59,
+	0xd7, 0x15, 0xbc, 0x8e, 0x53, 0x05, 0xce, 0x30, 0xe8, 0x7b, 0xa0, 0x0f, 0x89, 0xeb, 0xd1, 0x41,
+	0x8f, 0x13, 0x87, 0xca, 0xde, 0x56, 0xb7, 0xb6, 0x94, 0x89, 0xfe, 0x20, 0x53, 0xe1, 0x3c, 0x0e,
+	0xbd, 0x0f, 0xd5, 0x31, 0x65, 0x8c, 0x38, 0xe9, 0xbd, 0xd8, 0x4c, 0x0b, 0xfa, 0x30, 0x11, 0xe3,
+	0x54, 0x8f, 0x0e, 0x61, 0xcb, 0x23, 0x9c, 0xfa, 0xf6, 0xe9, 0xa1, 0xeb, 0x79, 0x2e, 0xa3, 0x76,
+	0xe0, 0x0f, 0x98, 0xbc, 0x07, 0x65, 0xeb, 0xab, 0xca, 0x6c, 0xeb, 0x60, 0x11, 0x82, 0x97, 0xd9,
+	0x21, 0x07, 0x1a, 0x1e, 0x61, 0xfc, 0x28, 0x0a, 0xfa, 0xf4, 0xb1, 0x3b, 0xa6, 0xb2, 0x3b, 0xea,
+	0xdd, 0x6f, 0x99, 0xc9, 0x28, 0x31, 0xf3, 0xa3, 0x24, 0x2b, 0x0c, 0x31, 0xb1, 0xcc, 0x93, 0xdb,
+	0xa6, 0xb0, 0xb0, 0x6e, 0x28, 0xd2, 0xc6, 0x41, 0xde, 0x11, 0x2e, 0xfa, 0x35, 0xbe, 0x02, 0x37,
+	0xef, 0x3f, 0xa7, 0xe3, 0x90, 0x2f, 0xdc, 0x74, 0xe3, 0x2f, 0x1a, 0xe8, 0x39, 0x29, 0xfa, 0xbd,
+	0x06, 0x68, 0xe1, 0xe2, 0x27, 0xf7, 0xf3, 0x52, 0xf5, 0xbb, 0xc0, 0x9c, 0xa5, 0x59, 0x71, 0xe0,
+	0x25, 0xbc, 0xc6, 0x8b, 0x12, 0x5c, 0x5f, 0x30, 0x45, 0xdb, 0xb0, 0xea, 0x8b, 0x3e, 0x96, 0x14,
+	0xd1, 0xba, 0x72, 0xb4, 0x2a, 0xdb, 0x96, 0xd4, 0xa0, 0x97, 0x1a, 0xb4, 0x16, 0xdc, 0x25, 0x03,
+	0x36, 0x8e, 0x08, 0x77, 0x83, 0x64, 0x54, 0xea, 0xdd, 0x9f, 0x5c, 0xe1, 0x2b, 0x15, 0xfc, 0x5b,
+	0xdf, 0x54, 0x61, 0xb5, 0xde, 0x8e, 0xc3, 0x67, 0xc4, 0x69, 0xfc, 0xb3, 0x0c, 0x67, 0xb8, 0x40,
+	0x31, 0x54, 0xa8, 0x3c, 0x5f, 0x99, 0x11, 0xbd, 0xfb, 0xe8, 0xe2, 0x2f, 0xf5, 0x86, 0x3a, 0x49,
+	0x66, 0x7a, 0xa2, 0xc4, 0x8a, 0x0c, 0xfd, 0x5d, 0x83, 0xad, 0x31, 0x79, 0x8e, 0xe9, 0xd3, 0x98,
+	0x32, 0xce, 0xf6, 0xfd, 0xa1, 0xe7, 0x3a, 0x23, 0xae, 0x32, 0xfb, 0xf3, 0x8b, 0x07, 0x71, 0xb8,
+	0xe8, 0x74, 0x31, 0xa2, 0x9b, 0xe2, 0xae, 0x2d, 0x41, 0xe2, 0x65, 0x31, 0xa1, 0xdf, 0x69, 0xa0,
+	0x73, 0xb1, 0xfe, 0x58, 0xb1, 0x7d, 0x4c, 0xb9, 0xec, 0x0e, 0x7a, 0xf7, 0xd3, 0x8b, 0xc7, 0xf8,
+	0x38, 0x73, 0xb6, 0xa4, 0xb6, 0x45, 0xc7, 0xc9, 0x21, 0x70, 0x9e, 0xdb, 0xf8, 0x11, 0x34, 0x0e,
+	0x02, 0xc7, 0x71, 0x7d, 0x47, 0xad, 0x7c, 0x1f, 0xc0, 0xea, 0x58, 0x0c, 0xd4, 0xa4, 0x9e, 0xd3,
+	0xc6, 0xbe, 0x3a, 0x3f, 0x4d, 0x25, 0xc8, 0xb8, 0x0f, 0x5f, 0x3f, 0x4f, 0x7e, 0xc4, 0xc6, 0x35,
+	0x26, 0xcf, 0x9b, 0x5a, 0x71, 0xe3, 0x12, 0xa6, 0x42, 0x6e, 0xfc, 0x43, 0x83, 0x2d, 0x4c, 0x43,
+	0xcf, 0xb5, 0x49, 0xa1, 0x49, 0xbf, 0x0f, 0xd5, 0x28, 0x11, 0xab, 0x70, 0x66, 0xf7, 0x34, 0x45,
+	0xa7, 0x7a, 0xf4, 0x1b, 0xa8, 0xa7, 0xdd, 0x3a, 0x19, 0xce, 0x7a, 0xf7, 0xe1, 0x25, 0x2a, 0x6f,
+	0xc9, 0xc8, 0xc8, 0x3a, 0x7e, 0xaa, 0x65, 0x38, 0xe3, 0x34, 0x86, 0x70, 0xbd, 0x47, 0xed, 0x88,
+	0x8a, 0x79, 0x48, 0x23, 0x6a, 0x53, 0xdf, 0xa6, 0x62, 0x6e, 0x88, 0x16, 0xc0, 0x42, 0x62, 0xa7,
+	0x19, 0x9d, 0x79, 0x79, 0x98, 0x2a, 0x70, 0x86, 0x99, 0x75, 0x93, 0xd2, 0x9b, 0xba, 0x89, 0xf1,
+	0x67, 0x0d, 0x1a, 0x3d, 0xb9, 0x6f, 0xcb, 0x59, 0xeb, 0x3b, 0xf9, 0x1d, 0x5a, 0x3b, 0xe7, 0x0e,
+	0x5d, 0x7a, 0xeb, 0x0e, 0x7d, 0x07, 0xd6, 0xed, 0xe4, 0x2b, 0xe0, 0x5e, 0x6e, 0x33, 0xbf, 0x36,
+	0x9d, 0xb4, 0xd7, 0x77, 0x73, 0x72, 0x5c, 0x40, 0x25, 0x09, 0x98, 0x5b, 0x0c, 0xce, 0xd1, 0x1d,
+	0x0b, 0x29, 0x2a, 0x9d, 0x9d, 0x22, 0xa3, 0x0f, 0xef, 0xbe, 0xad, 0xde, 0xd3, 0xed, 0x5e, 0x3b,
+	0x6b, 0xbb, 0x2f, 0xbd, 0x79, 0xbb, 0x37, 0xfe, 0x5d, 0x82, 0xcd, 0x74, 0x87, 0xde, 0xf5, 0x62,
+	0xc6, 0x69, 0x84, 0x7e, 0x01, 0x35, 0x31, 0xee, 0x06, 0x69, 0x9e, 0xf5, 0xee, 0x77, 0xcf, 0x37,
+	0x1c, 0x3f, 0xe9, 0xff, 0x92, 0xda, 0xfc, 0x90, 0x72, 0x92, 0x7d, 0x21, 0x64, 0x32, 0x3c, 0xf3,
+	0x8a, 0x02, 0x58, 0x65, 0x21, 0xb5, 0x55, 0xcf, 0x3a, 0xbc, 0x78, 0xf9, 0xce, 0x85, 0xde, 0x0b,
+	0xa9, 0x9d, 0xe5, 0x5e, 0x3c, 0x61, 0x49, 0x84, 0x9e, 0x41, 0x85, 0x71, 0xc2, 0x63, 0xa6, 0x5a,
+	0xd0, 0x27, 0x57, 0x47, 0x29, 0xdd, 0x5a, 0x1b, 0x8a, 0xb4, 0x92, 0x3c, 0x63, 0x45, 0x67, 0x7c,
+	0xa9, 0xc1, 0xd6, 0x9c, 0xc5, 0x81, 0xcb, 0x38, 0xfa, 0xd9, 0x42, 0x8e, 0xcd, 0xf3, 0xe5, 0x58,
+	0x58, 0xcb, 0x0c, 0xcf, 0xb6, 0xb8, 0x54, 0x92, 0xcb, 0xaf, 0x0f, 0x6b, 0x2e, 0xa7, 0xe3, 0xb4,
+	0x3f, 0xec, 0x5f, 0xd9, 0xdb, 0x66, 0x55, 0xb4, 0x2f, 0xfc, 0xe3, 0x84, 0xc6, 0x08, 0xe0, 0xc6,
+	0x7c, 0x5a, 0x68, 0x74, 0x42, 0xa3, 0xff, 0x73, 0xf9, 0xdc, 0x81, 0xda, 0xc0, 0x65, 0x62, 0xad,
+	0x1c, 0xa8, 0xdd, 0x53, 0x5e, 0xdc, 0x3d, 0x25, 0xc3, 0x33, 0xad, 0xf1, 0xd7, 0xca, 0x42, 0x5a,
+	0xc5, 0x69, 0xa3, 0x5f, 0x41, 0x95, 0x49, 0xe6, 0x74, 0x79, 0xba, 0xc2, 0x83, 0x96, 0x7e, 0x73,
+	0x0b, 0x54, 0xc2, 0x83, 0x53, 0x42, 0xf4, 0x42, 0x9b, 0x75, 0x13, 0x39, 0x60, 0x54, 0x75, 0x3f,
+	0xb8, 0x78, 0x04, 0xf9, 0x7f, 0x28, 0xac, 0x77, 0x14, 0x71, 0xe1, 0x7f, 0x0b, 0x5c, 0x60, 0x44,
+	0x9f, 0x69, 0xd0, 0x60, 0xf9, 0x96, 0xa9, 0xca, 0xfd, 0xc3, 0xcb, 0x7c, 0x02, 0xe5, 0xdc, 0x65,
+	0x9b, 0x6f, 0x41, 0x8c, 0x8b, 0xa4, 0xe8, 0xd7, 0xa0, 0xe7, 0xd6, 0x2b, 0xb9, 0xe0, 0xeb, 0xdd,
+	0xfb, 0x57, 0xb2, 0xf3, 0xe5, 0x3e, 0x2d, 0x32, 0x21, 0xce, 0xd3, 0x89, 0x2f, 0xc1, 0x6b, 0x83,
+	0xfc, 0x57, 0xaf, 0x4b, 0x93, 0xcf, 0x46, 0xbd, 0xfb, 0xd1, 0x55, 0x7d, 0xff, 0x5b, 0x4d, 0x15,
+	0xc6, 0xb5, 0xbd, 0x39, 0x26, 0xbc, 0xc0, 0x8d, 0x22, 0xf9, 0xf1, 0x2e, 0x36, 0x8f, 0x66, 0xe5,
+	0xb2, 0xc7, 0x51, 0x58, 0x61, 0xb2, 0x62, 0x54, 0x62, 0x9c, 0x12, 0x19, 0x7f, 0xd3, 0x16, 0xaf,
+	0xa4, 0xec, 0x48, 0xe8, 0xb7, 0x1a, 0xac, 0x47, 0xb9, 0x69, 0xaf, 0x2e, 0xca, 0x25, 0x9a, 0xf0,
+	0x92, 0x85, 0x26, 0xab, 0xd6, 0xbc, 0x14, 0x17, 0x88, 0x2d, 0xf3, 0xe5, 0xab, 0xd6, 0xca, 0xe7,
+	0xaf, 0x5a, 0x2b, 0x5f, 0xbc, 0x6a, 0xad, 0xbc, 0x98, 0xb6, 0xb4, 0x97, 0xd3, 0x96, 0xf6, 0xf9,
+	0xb4, 0xa5, 0x7d, 0x31, 0x6d, 0x69, 0xff, 0x99, 0xb6, 0xb4, 0x3f, 0x7d, 0xd9, 0x5a, 0xf9, 0x69,
+	0x2d, 0xe5, 0xf9, 0xdf, 0x00, 0x78, 0x27, 0x16, 0x85, 0x07, 0x15, 0x00, 0x00,
 }
 
 func (m *ClientConfig) Marshal() (dAtA []byte, err error) {
@@ -899,6 +970,65 @@ func (m *DispatchPolicyRule) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *EndpointReachability) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *EndpointReachability) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *EndpointReachability) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	{
+		size, err := m.LastProbeTime.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintGenerated(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x32
+	i = encodeVarintGenerated(dAtA, i, uint64(m.LatencyMilliseconds))
+	i--
+	dAtA[i] = 0x28
+	i -= len(m.Message)
+	copy(dAtA[i:], m.Message)
+	i = encodeVarintGenerated(dAtA, i, uint64(len(m.Message)))
+	i--
+	dAtA[i] = 0x22
+	i -= len(m.FailedStage)
+	copy(dAtA[i:], m.FailedStage)
+	i = encodeVarintGenerated(dAtA, i, uint64(len(m.FailedStage)))
+	i--
+	dAtA[i] = 0x1a
+	i--
+	if m.Reachable {
+		dAtA[i] = 1
+	} else {
+		dAtA[i] = 0
+	}
+	i--
+	dAtA[i] = 0x10
+	i -= len(m.Endpoint)
+	copy(dAtA[i:], m.Endpoint)
+	i = encodeVarintGenerated(dAtA, i, uint64(len(m.Endpoint)))
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
 func (m *ExemptFlowControlSchema) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1110,6 +1240,48 @@ func (m *MaxRequestsInflightFlowControlSchema) MarshalToSizedBuffer(dAtA []byte)
 	return len(dAtA) - i, nil
 }
 
+func (m *ReplicaReachability) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ReplicaReachability) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *ReplicaReachability) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.Endpoints) > 0 {
+		for iNdEx := len(m.Endpoints) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Endpoints[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintGenerated(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	i -= len(m.Replica)
+	copy(dAtA[i:], m.Replica)
+	i = encodeVarintGenerated(dAtA, i, uint64(len(m.Replica)))
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
 func (m *SecretReferecence) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -1498,6 +1670,20 @@ func (m *UpstreamClusterStatus) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.Reachability) > 0 {
+		for iNdEx := len(m.Reachability) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Reachability[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintGenerated(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
+		}
+	}
 	return len(dAtA) - i, nil
 }
 
@@ -1625,6 +1811,25 @@ func (m *DispatchPolicyRule) Size() (n int) {
 	return n
 }
 
+func (m *EndpointReachability) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Endpoint)
+	n += 1 + l + sovGenerated(uint64(l))
+	n += 2
+	l = len(m.FailedStage)
+	n += 1 + l + sovGenerated(uint64(l))
+	l = len(m.Message)
+	n += 1 + l + sovGenerated(uint64(l))
+	n += 1 + sovGenerated(uint64(m.LatencyMilliseconds))
+	l = m.LastProbeTime.Size()
+	n += 1 + l + sovGenerated(uint64(l))
+	return n
+}
+
 func (m *ExemptFlowControlSchema) Size() (n int) {
 	if m == nil {
 		return 0
@@ -1704,6 +1909,23 @@ func (m *MaxRequestsInflightFlowControlSchema) Size() (n int) {
 	return n
 }
 
+func (m *ReplicaReachability) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.Replica)
+	n += 1 + l + sovGenerated(uint64(l))
+	if len(m.Endpoints) > 0 {
+		for _, e := range m.Endpoints {
+			l = e.Size()
+			n += 1 + l + sovGenerated(uint64(l))
+		}
+	}
+	return n
+}
+
 func (m *SecretReferecence) Size() (n int) {
 	if m == nil {
 		return 0
@@ -1843,6 +2065,12 @@ func (m *UpstreamClusterStatus) Size() (n int) {
 	}
 	var l int
 	_ = l
+	if len(m.Reachability) > 0 {
+		for _, e := range m.Reachability {
+			l = e.Size()
+			n += 1 + l + sovGenerated(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -1910,6 +2138,21 @@ func (this *DispatchPolicyRule) String() string {
 	}, "")
 	return s
 }
+func (this *EndpointReachability) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&EndpointReachability{`,
+		`Endpoint:` + fmt.Sprintf("%v", this.Endpoint) + `,`,
+		`Reachable:` + fmt.Sprintf("%v", this.Reachable) + `,`,
+		`FailedStage:` + fmt.Sprintf("%v", this.FailedStage) + `,`,
+		`Message:` + fmt.Sprintf("%v", this.Message) + `,`,
+		`LatencyMilliseconds:` + fmt.Sprintf("%v", this.LatencyMilliseconds) + `,`,
+		`LastProbeTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.LastProbeTime), "Time", "v1.Time", 1), `&`, ``, 1) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *ExemptFlowControlSchema) String() string {
 	if this == nil {
 		return "nil"
@@ -1977,6 +2220,22 @@ func (this *MaxRequestsInflightFlowControlSchema) String() string {
 	}, "")
 	return s
 }
+func (this *ReplicaReachability) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForEndpoints := "[]EndpointReachability{"
+	for _, f := range this.Endpoints {
+		repeatedStringForEndpoints += strings.Replace(strings.Replace(f.String(), "EndpointReachability", "EndpointReachability", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForEndpoints += "}"
+	s := strings.Join([]string{`&ReplicaReachability{`,
+		`Replica:` + fmt.Sprintf("%v", this.Replica) + `,`,
+		`Endpoints:` + repeatedStringForEndpoints + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func (this *SecretReferecence) String() string {
 	if this == nil {
 		return "nil"
@@ -2090,7 +2349,13 @@ func (this *UpstreamClusterStatus) String() string {
 	if this == nil {
 		return "nil"
 	}
+	repeatedStringForReachability := "[]ReplicaReachability{"
+	for _, f := range this.Reachability {
+		repeatedStringForReachability += strings.Replace(strings.Replace(f.String(), "ReplicaReachability", "ReplicaReachability", 1), `&`, ``, 1) + ","
+	}
+	repeatedStringForReachability += "}"
 	s := strings.Join([]string{`&UpstreamClusterStatus{`,
+		`Reachability:` + repeatedStringForReachability + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2886,7 +3151,7 @@ func (m *DispatchPolicyRule) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *ExemptFlowControlSchema) Unmarshal(dAtA []byte) error {
+func (m *EndpointReachability) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2909,47 +3174,265 @@ func (m *ExemptFlowControlSchema) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: ExemptFlowControlSchema: wiretype end group for non-group")
+			return fmt.Errorf("proto: EndpointReachability: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: ExemptFlowControlSchema: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: EndpointReachability: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		default:
-			iNdEx = preIndex
-			skippy, err := skipGenerated(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Endpoint", wireType)
 			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthGenerated
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
 			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthGenerated
 			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *FlowControl) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowGenerated
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
 			}
-			if iNdEx >= l {
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			b := dAtA[iNdEx]
+			m.Endpoint = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reachable", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Reachable = bool(v != 0)
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FailedStage", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FailedStage = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Message", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Message = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LatencyMilliseconds", wireType)
+			}
+			m.LatencyMilliseconds = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LatencyMilliseconds |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LastProbeTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.LastProbeTime.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipGenerated(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ExemptFlowControlSchema) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowGenerated
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ExemptFlowControlSchema: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ExemptFlowControlSchema: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		default:
+			iNdEx = preIndex
+			skippy, err := skipGenerated(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *FlowControl) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowGenerated
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
 			iNdEx++
 			wire |= uint64(b&0x7F) << shift
 			if b < 0x80 {
@@ -3444,6 +3927,122 @@ func (m *MaxRequestsInflightFlowControlSchema) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *ReplicaReachability) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowGenerated
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ReplicaReachability: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ReplicaReachability: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Replica", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Replica = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Endpoints", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Endpoints = append(m.Endpoints, EndpointReachability{})
+			if err := m.Endpoints[len(m.Endpoints)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipGenerated(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func (m *SecretReferecence) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
@@ -4560,6 +5159,40 @@ func (m *UpstreamClusterStatus) Unmarshal(dAtA []byte) error {
 			return fmt.Errorf("proto: UpstreamClusterStatus: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reachability", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowGenerated
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthGenerated
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reachability = append(m.Reachability, ReplicaReachability{})
+			if err := m.Reachability[len(m.Reachability)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipGenerated(dAtA[iNdEx:])
@@ -294,6 +294,37 @@ type ServiceAccountRef struct {
 
 // UpstreamClusterStatus defines the observed state of UpstreamCluster
 type UpstreamClusterStatus struct {
+	// Reachability contains the endpoint reachability observed by blackbox probes of each gateway replica,
+	// so an endpoint partitioned from some replicas can be told from an unhealthy endpoint.
+	// +optional
+	Reachability []ReplicaReachability `json:"reachability,omitempty" protobuf:"bytes,1,rep,name=reachability"`
+}
+
+// ReplicaReachability is the reachability of endpoints observed by one gateway replica
+type ReplicaReachability struct {
+	// Replica is the name of gateway replica, see --proxy-replica-name
+	Replica string `json:"replica" protobuf:"bytes,1,opt,name=replica"`
+	// Endpoints contains the last probe result of each endpoint
+	// +optional
+	Endpoints []EndpointReachability `json:"endpoints,omitempty" protobuf:"bytes,2,rep,name=endpoints"`
+}
+
+// EndpointReachability is the result of the last blackbox probe from a gateway replica to an endpoint
+type EndpointReachability struct {
+	// Endpoint is the upstream api server address
+	Endpoint string `json:"endpoint" protobuf:"bytes,1,opt,name=endpoint"`
+	// Reachable is true if all stages of probe succeeded
+	Reachable bool `json:"reachable" protobuf:"varint,2,opt,name=reachable"`
+	// FailedStage is the first failed stage, one of tcp, tls and http
+	// +optional
+	FailedStage string `json:"failedStage,omitempty" protobuf:"bytes,3,opt,name=failedStage"`
+	// Message is the error of failed stage
+	// +optional
+	Message string `json:"message,omitempty" protobuf:"bytes,4,opt,name=message"`
+	// LatencyMilliseconds is the total duration of all stages
+	LatencyMilliseconds int64 `json:"latencyMilliseconds" protobuf:"varint,5,opt,name=latencyMilliseconds"`
+	// LastProbeTime is the start time of the probe
+	LastProbeTime metav1.Time `json:"lastProbeTime" protobuf:"bytes,6,opt,name=lastProbeTime"`
 }
 
 // +genclient
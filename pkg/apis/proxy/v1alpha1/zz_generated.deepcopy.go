@@ -145,6 +145,23 @@ func (in *DispatchPolicyRule) DeepCopy() *DispatchPolicyRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointReachability) DeepCopyInto(out *EndpointReachability) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointReachability.
+func (in *EndpointReachability) DeepCopy() *EndpointReachability {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointReachability)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExemptFlowControlSchema) DeepCopyInto(out *ExemptFlowControlSchema) {
 	*out = *in
@@ -264,6 +281,29 @@ func (in *MaxRequestsInflightFlowControlSchema) DeepCopy() *MaxRequestsInflightF
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaReachability) DeepCopyInto(out *ReplicaReachability) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]EndpointReachability, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaReachability.
+func (in *ReplicaReachability) DeepCopy() *ReplicaReachability {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaReachability)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretReferecence) DeepCopyInto(out *SecretReferecence) {
 	*out = *in
@@ -349,7 +389,7 @@ func (in *UpstreamCluster) DeepCopyInto(out *UpstreamCluster) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -462,6 +502,13 @@ func (in *UpstreamClusterSpec) DeepCopy() *UpstreamClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *UpstreamClusterStatus) DeepCopyInto(out *UpstreamClusterStatus) {
 	*out = *in
+	if in.Reachability != nil {
+		in, out := &in.Reachability, &out.Reachability
+		*out = make([]ReplicaReachability, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
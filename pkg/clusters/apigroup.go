@@ -0,0 +1,154 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+const (
+	// APIGroupEndpointsAnnotationKey routes resource requests of aggregated API groups directly to their
+	// extension servers instead of the core apiservers in spec.servers, the value is a comma separated list
+	// of group=endpoints pairs, endpoints of one group are separated by semicolons, e.g.
+	// metrics.k8s.io=https://10.0.0.5:4443;https://10.0.0.6:4443,custom.metrics.k8s.io=https://10.0.0.7:6443
+	//
+	// The extension servers are dialed with clientConfig of the cluster, so their serving certificates must
+	// be trusted by it. Unlike spec.servers whose certificates are verified against the cluster name, the
+	// certificate of an extension server is verified against the host of its endpoint URL. Requests fall
+	// back to spec.servers if none of the extension servers is ready.
+	APIGroupEndpointsAnnotationKey = "proxy.kubegateway.io/api-group-endpoints"
+)
+
+// apiGroupEndpoints are endpoints of extension servers overriding the core apiservers for API groups
+type apiGroupEndpoints struct {
+	groups map[string][]string
+	// endpoints which are not in spec.servers, they only serve requests of overridden groups
+	extensions sets.String
+}
+
+// ParseAPIGroupEndpoints parses endpoints of API groups from annotation value
+func ParseAPIGroupEndpoints(value string) (map[string][]string, error) {
+	groups := map[string][]string{}
+	for _, s := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing endpoints for api group %q", s)
+		}
+		group := strings.TrimSpace(kv[0])
+		if len(group) == 0 {
+			return nil, fmt.Errorf("empty api group in %q, the core group can not be overridden", s)
+		}
+		if _, ok := groups[group]; ok {
+			return nil, fmt.Errorf("duplicate api group %q", group)
+		}
+		endpoints := []string{}
+		for _, ep := range strings.Split(kv[1], ";") {
+			ep = strings.TrimSpace(ep)
+			if len(ep) == 0 {
+				continue
+			}
+			u, err := url.Parse(ep)
+			if err != nil {
+				return nil, fmt.Errorf("invalid endpoint %q of api group %q, err: %v", ep, group, err)
+			}
+			if u.Scheme != "https" || len(u.Host) == 0 || (len(u.Path) > 0 && u.Path != "/") {
+				return nil, fmt.Errorf("invalid endpoint %q of api group %q, must be in format https://host:port", ep, group)
+			}
+			endpoints = append(endpoints, ep)
+		}
+		if len(endpoints) == 0 {
+			return nil, fmt.Errorf("missing endpoints for api group %q", group)
+		}
+		groups[group] = endpoints
+	}
+	return groups, nil
+}
+
+// endpointServerName returns the server name used to verify the serving certificate of endpoint.
+// Aggregated API servers are not served under the cluster name, so the host of their URL is used.
+func (c *ClusterInfo) endpointServerName(endpoint string) string {
+	if !c.loadAPIGroupEndpoints().extensions.Has(endpoint) {
+		return c.restConfig.TLSClientConfig.ServerName
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return c.restConfig.TLSClientConfig.ServerName
+	}
+	return u.Hostname()
+}
+
+func (c *ClusterInfo) loadAPIGroupEndpoints() apiGroupEndpoints {
+	if e, ok := c.currentAPIGroupEndpoints.Load().(apiGroupEndpoints); ok {
+		return e
+	}
+	return apiGroupEndpoints{}
+}
+
+// APIGroupEndpoints returns the endpoints overriding spec.servers for resource requests of group,
+// it returns false if the group is served by spec.servers
+func (c *ClusterInfo) APIGroupEndpoints(group string) ([]string, bool) {
+	endpoints, ok := c.loadRouting().apiGroups[group]
+	return endpoints, ok && len(endpoints) > 0
+}
+
+// ServerEndpoints returns endpoints of spec.servers, endpoints only serving overridden API groups are excluded
+func (c *ClusterInfo) ServerEndpoints() []string {
+	return append([]string{}, c.loadRouting().servers...)
+}
+
+func (c *ClusterInfo) syncAPIGroupEndpoints(annotations map[string]string, servers []proxyv1alpha1.UpstreamClusterServer) error {
+	groups := map[string][]string{}
+	if value := annotations[APIGroupEndpointsAnnotationKey]; len(value) > 0 {
+		var err error
+		groups, err = ParseAPIGroupEndpoints(value)
+		if err != nil {
+			return err
+		}
+	}
+	serverEndpoints := sets.NewString()
+	for _, s := range servers {
+		serverEndpoints.Insert(s.Endpoint)
+	}
+	extensions := sets.NewString()
+	for _, endpoints := range groups {
+		for _, ep := range endpoints {
+			if !serverEndpoints.Has(ep) {
+				extensions.Insert(ep)
+			}
+		}
+	}
+	if old := c.loadAPIGroupEndpoints(); !reflect.DeepEqual(old.groups, groups) && (len(old.groups) > 0 || len(groups) > 0) {
+		names := make([]string, 0, len(groups))
+		for group := range groups {
+			names = append(names, group)
+		}
+		sort.Strings(names)
+		klog.Infof("[cluster info] cluster=%q update api group endpoints, groups=%v", c.Cluster, names)
+	}
+	c.currentAPIGroupEndpoints.Store(apiGroupEndpoints{groups: groups, extensions: extensions})
+	return nil
+}
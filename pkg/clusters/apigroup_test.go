@@ -0,0 +1,157 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestParseAPIGroupEndpoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			"multiple groups",
+			"metrics.k8s.io=https://10.0.0.5:4443;https://10.0.0.6:4443, custom.metrics.k8s.io=https://10.0.0.7:6443",
+			map[string][]string{
+				"metrics.k8s.io":        {"https://10.0.0.5:4443", "https://10.0.0.6:4443"},
+				"custom.metrics.k8s.io": {"https://10.0.0.7:6443"},
+			},
+			false,
+		},
+		{"core group", "=https://10.0.0.5:4443", nil, true},
+		{"missing endpoints", "metrics.k8s.io=", nil, true},
+		{"plain http", "metrics.k8s.io=http://10.0.0.5:4443", nil, true},
+		{"endpoint with path", "metrics.k8s.io=https://10.0.0.5:4443/apis", nil, true},
+		{"duplicate group", "metrics.k8s.io=https://10.0.0.5:4443,metrics.k8s.io=https://10.0.0.6:4443", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAPIGroupEndpoints(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAPIGroupEndpoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseAPIGroupEndpoints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_MatchAttributes_APIGroupEndpoints(t *testing.T) {
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Annotations = map[string]string{
+		APIGroupEndpointsAnnotationKey: "metrics.k8s.io=https://127.0.0.5:4443",
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	core, _ := info.Endpoints.Load("https://127.0.0.1:443")
+	extension, ok := info.Endpoints.Load("https://127.0.0.5:4443")
+	if !ok {
+		t.Fatalf("extension server endpoint is not created")
+	}
+	core.UpdateStatus(true, "", "")
+	extension.UpdateStatus(true, "", "")
+
+	if got := info.ServerEndpoints(); !reflect.DeepEqual(got, []string{"https://127.0.0.1:443"}) {
+		t.Errorf("ServerEndpoints() = %v, want only spec.servers", got)
+	}
+
+	pick := func(group string) string {
+		picker, err := info.MatchAttributes(authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "test"},
+			Verb:            "list",
+			APIGroup:        group,
+			Resource:        "pods",
+			ResourceRequest: true,
+		})
+		if err != nil {
+			t.Fatalf("MatchAttributes() error = %v", err)
+		}
+		ep, err := picker.Pop()
+		if err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+		return ep.Endpoint
+	}
+
+	if got := pick(""); got != "https://127.0.0.1:443" {
+		t.Errorf("core group is routed to %v", got)
+	}
+	if got := pick("metrics.k8s.io"); got != "https://127.0.0.5:4443" {
+		t.Errorf("metrics.k8s.io is routed to %v, want extension server", got)
+	}
+
+	// core apiservers proxy to the extension server by APIService if it is not ready
+	extension.UpdateStatus(false, "Failure", "connection refused")
+	if got := pick("metrics.k8s.io"); got != "https://127.0.0.1:443" {
+		t.Errorf("metrics.k8s.io is routed to %v, want fallback to core apiserver", got)
+	}
+}
+
+func TestClusterInfo_APIGroupEndpoints_ServerName(t *testing.T) {
+	// httptest serves a certificate for example.com and 127.0.0.1, which never matches the cluster name
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.ClientConfig.Insecure = false
+	cluster.Spec.ClientConfig.CAData = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	cluster.Annotations = map[string]string{
+		APIGroupEndpointsAnnotationKey: "metrics.k8s.io=" + server.URL,
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	if got := info.endpointServerName("https://127.0.0.1:443"); got != cluster.Name {
+		t.Errorf("server name of spec.servers = %q, want cluster name %q", got, cluster.Name)
+	}
+	extension, ok := info.Endpoints.Load(server.URL)
+	if !ok {
+		t.Fatalf("extension server endpoint is not created")
+	}
+	if got := extension.healthCheckConfig.TLSClientConfig.ServerName; got != "127.0.0.1" {
+		t.Errorf("server name of extension server = %q, want endpoint host", got)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/apis/metrics.k8s.io/v1beta1", nil)
+	resp, err := extension.ProxyTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() to extension server error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("RoundTrip() status = %v, want %v", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+)
+
+const (
+	// AuditOnlyRulesAnnotationKey marks rejecting rules of one upstream cluster as audit-only, requests which
+	// would be rejected by them are logged and counted but not rejected, so that new rules can be rolled out safely.
+	// Audit-only flow control schemas are evaluated in shadow, requests are still limited by the schema which
+	// would be selected if they did not exist.
+	// The value is a comma separated list of flow control schema names in spec.flowControl.schemas, and
+	// DenyAllRequests for the feature gate, e.g. new-qps-limit,DenyAllRequests
+	AuditOnlyRulesAnnotationKey = "proxy.kubegateway.io/audit-only-rules"
+
+	// AuditOnlyDenyAllRequests makes feature gate DenyAllRequests audit-only
+	AuditOnlyDenyAllRequests = string(features.DenyAllRequests)
+)
+
+// ParseAuditOnlyRules parses names of audit-only rules, flowControlSchemas are names of all flow control
+// schemas of the cluster.
+func ParseAuditOnlyRules(value string, flowControlSchemas sets.String) (sets.String, error) {
+	rules := sets.NewString()
+	for _, s := range strings.Split(value, ",") {
+		name := strings.TrimSpace(s)
+		if len(name) == 0 {
+			continue
+		}
+		if name != AuditOnlyDenyAllRequests && !flowControlSchemas.Has(name) {
+			return nil, fmt.Errorf("unknown audit-only rule %q, must be %s or a flow control schema name in spec.flowControl.schemas", name, AuditOnlyDenyAllRequests)
+		}
+		rules.Insert(name)
+	}
+	return rules, nil
+}
+
+// IsAuditOnly returns true if requests rejected by rule should only be logged and counted
+func (c *ClusterInfo) IsAuditOnly(rule string) bool {
+	rules, ok := c.currentAuditOnlyRules.Load().(sets.String)
+	return ok && rules.Has(rule)
+}
+
+func (c *ClusterInfo) syncAuditOnlyRules(annotations map[string]string, flowControlSchemas sets.String) error {
+	rules := sets.NewString()
+	if value := annotations[AuditOnlyRulesAnnotationKey]; len(value) > 0 {
+		var err error
+		rules, err = ParseAuditOnlyRules(value, flowControlSchemas)
+		if err != nil {
+			return err
+		}
+	}
+	if old, _ := c.currentAuditOnlyRules.Load().(sets.String); !old.Equal(rules) {
+		klog.Infof("[cluster info] cluster=%q update audit-only rules, rules=%v", c.Cluster, rules.List())
+	}
+	c.currentAuditOnlyRules.Store(rules)
+	return nil
+}
+
+func flowControlSchemaNames(flowControl proxyv1alpha1.FlowControl) sets.String {
+	names := sets.NewString()
+	for _, schema := range flowControl.Schemas {
+		names.Insert(schema.Name)
+	}
+	return names
+}
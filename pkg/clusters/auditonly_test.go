@@ -0,0 +1,128 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+func TestParseAuditOnlyRules(t *testing.T) {
+	schemas := sets.NewString("qps", "new-qps-limit")
+	tests := []struct {
+		name    string
+		value   string
+		want    sets.String
+		wantErr bool
+	}{
+		{"flow control schemas", "new-qps-limit, qps", sets.NewString("qps", "new-qps-limit"), false},
+		{"deny all requests", "DenyAllRequests,new-qps-limit", sets.NewString("DenyAllRequests", "new-qps-limit"), false},
+		{"empty items", ",,", sets.NewString(), false},
+		{"unknown schema", "unknown", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAuditOnlyRules(tt.value, schemas)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAuditOnlyRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !got.Equal(tt.want) {
+				t.Errorf("ParseAuditOnlyRules() = %v, want %v", got.List(), tt.want.List())
+			}
+		})
+	}
+}
+
+func TestClusterInfo_IsAuditOnly(t *testing.T) {
+	c := NewEmptyClusterInfo("test", nil, nil, nil)
+	if c.IsAuditOnly(AuditOnlyDenyAllRequests) {
+		t.Errorf("IsAuditOnly() = true before syncing")
+	}
+	annotations := map[string]string{AuditOnlyRulesAnnotationKey: "DenyAllRequests"}
+	if err := c.syncAuditOnlyRules(annotations, sets.NewString()); err != nil {
+		t.Fatalf("syncAuditOnlyRules() error = %v", err)
+	}
+	if !c.IsAuditOnly(AuditOnlyDenyAllRequests) {
+		t.Errorf("IsAuditOnly() = false after syncing annotation")
+	}
+	if err := c.syncAuditOnlyRules(map[string]string{}, sets.NewString()); err != nil {
+		t.Fatalf("syncAuditOnlyRules() error = %v", err)
+	}
+	if c.IsAuditOnly(AuditOnlyDenyAllRequests) {
+		t.Errorf("IsAuditOnly() = true after removing annotation")
+	}
+}
+
+func TestClusterInfo_MatchRequest_AuditOnlySchema(t *testing.T) {
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.FlowControl = proxyv1alpha1.FlowControl{
+		Schemas: []proxyv1alpha1.FlowControlSchema{
+			{
+				Name: "inflight",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					MaxRequestsInflight: &proxyv1alpha1.MaxRequestsInflightFlowControlSchema{Max: 1},
+				},
+			},
+			{
+				Name: "new-qps-limit",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 1},
+				},
+			},
+		},
+	}
+	cluster.Spec.DispatchPolicies[0].FlowControlSchemaName = "inflight"
+	cluster.Annotations = map[string]string{
+		UserAgentRulesAnnotationKey: `[{"userAgents":["my-operator"],"flowControlSchemaName":"new-qps-limit"}]`,
+		AuditOnlyRulesAnnotationKey: "new-qps-limit",
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	picker, err := info.MatchRequest(authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "test"},
+		Verb:            "list",
+		Resource:        "pods",
+		ResourceRequest: true,
+	}, "my-operator/v0.18.3 (linux/amd64)")
+	if err != nil {
+		t.Fatalf("MatchRequest() error = %v", err)
+	}
+
+	// the audit-only schema is evaluated in shadow, the schema of dispatch policy is still enforced
+	if got, flowSchema := gatewayflowcontrol.NameOf(picker.FlowControl()), picker.FlowSchema(); got != "inflight" || flowSchema != "dispatch-policy-0" {
+		t.Errorf("enforcing flow control = %q by %q, want inflight by dispatch-policy-0", got, flowSchema)
+	}
+	shadows := picker.AuditOnlyFlowControls()
+	if len(shadows) != 1 || gatewayflowcontrol.NameOf(shadows[0]) != "new-qps-limit" {
+		t.Fatalf("AuditOnlyFlowControls() = %v, want new-qps-limit", shadows)
+	}
+	if !picker.FlowControl().TryAcquire() {
+		t.Fatalf("first request is rejected by enforcing flow control")
+	}
+	defer picker.FlowControl().Release()
+	if picker.FlowControl().TryAcquire() {
+		t.Errorf("enforcing limit is not applied while an audit-only schema is selected")
+	}
+}
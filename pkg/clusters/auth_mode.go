@@ -0,0 +1,105 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/kubewharf/kubegateway/pkg/transport"
+)
+
+const (
+	// AuthModeAnnotationKey overrides the default upstream auth mode for one upstream cluster,
+	// the value is one of Impersonate, Passthrough and RequestHeader. The cluster is recreated
+	// when its auth mode changes.
+	AuthModeAnnotationKey = "proxy.kubegateway.io/auth-mode"
+)
+
+// AuthMode describes how gateway authenticates proxied requests to upstream cluster
+type AuthMode string
+
+const (
+	// AuthModeImpersonate authenticates with gateway credential and impersonates the client
+	AuthModeImpersonate AuthMode = "Impersonate"
+	// AuthModePassthrough forwards the client bearer token without gateway credential, so upstream
+	// authenticates and authorizes clients itself. Clients authenticated by certificates are rejected.
+	AuthModePassthrough AuthMode = "Passthrough"
+	// AuthModeRequestHeader authenticates with gateway client certificate and propagates the
+	// client in X-Remote-* headers, upstream must trust gateway as an authenticating front proxy.
+	AuthModeRequestHeader AuthMode = "RequestHeader"
+)
+
+// ParseAuthMode parses auth mode case-insensitively
+func ParseAuthMode(value string) (AuthMode, error) {
+	for _, m := range []AuthMode{AuthModeImpersonate, AuthModePassthrough, AuthModeRequestHeader} {
+		if strings.EqualFold(value, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown auth mode %q, must be one of %s, %s and %s", value, AuthModeImpersonate, AuthModePassthrough, AuthModeRequestHeader)
+}
+
+// AuthModeOf returns the auth mode specified by annotations, missing or invalid value falls back to
+// defaultMode. The mode is resolved when a cluster is created and compared on every sync, a changed
+// mode recreates the cluster.
+func AuthModeOf(annotations map[string]string, defaultMode AuthMode) AuthMode {
+	if value := annotations[AuthModeAnnotationKey]; len(value) > 0 {
+		if mode, err := ParseAuthMode(value); err == nil {
+			return mode
+		}
+	}
+	return defaultMode
+}
+
+// AuthMode returns the auth mode of this cluster
+func (c *ClusterInfo) AuthMode() AuthMode {
+	if len(c.authMode) == 0 {
+		return AuthModeImpersonate
+	}
+	return c.authMode
+}
+
+// proxyConfigForAuthMode returns a copy of config used by proxy transports in mode
+func proxyConfigForAuthMode(config *rest.Config, mode AuthMode) *rest.Config {
+	copied := rest.CopyConfig(config)
+	switch mode {
+	case AuthModePassthrough:
+		// gateway credential must not be sent, otherwise upstream authenticates gateway instead of client
+		copied.BearerToken = ""
+		copied.BearerTokenFile = ""
+		copied.Username = ""
+		copied.Password = ""
+		copied.ExecProvider = nil
+		copied.AuthProvider = nil
+		copied.CertData = nil
+		copied.CertFile = ""
+		copied.KeyData = nil
+		copied.KeyFile = ""
+		copied.WrapTransport = transport.NewPassthroughRoundTripper
+	case AuthModeRequestHeader:
+		// upstream only trusts request headers from a verified client certificate
+		copied.BearerToken = ""
+		copied.BearerTokenFile = ""
+		copied.ExecProvider = nil
+		copied.AuthProvider = nil
+		copied.WrapTransport = transport.NewRequestHeaderRoundTripper
+	default:
+		copied.WrapTransport = transport.NewDynamicImpersonatingRoundTripper
+	}
+	return copied
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func Test_AuthModeOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		defaultMode AuthMode
+		want        AuthMode
+	}{
+		{"default", nil, AuthModeImpersonate, AuthModeImpersonate},
+		{"default of gateway", nil, AuthModePassthrough, AuthModePassthrough},
+		{"passthrough", map[string]string{AuthModeAnnotationKey: "passthrough"}, AuthModeImpersonate, AuthModePassthrough},
+		{"request header", map[string]string{AuthModeAnnotationKey: "RequestHeader"}, AuthModeImpersonate, AuthModeRequestHeader},
+		{"invalid", map[string]string{AuthModeAnnotationKey: "unknown"}, AuthModeImpersonate, AuthModeImpersonate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AuthModeOf(tt.annotations, tt.defaultMode); got != tt.want {
+				t.Errorf("AuthModeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_proxyConfigForAuthMode(t *testing.T) {
+	config := &rest.Config{
+		BearerToken: "gateway-token",
+		TLSClientConfig: rest.TLSClientConfig{
+			CertData: []byte("cert"),
+			KeyData:  []byte("key"),
+			CAData:   []byte("ca"),
+		},
+	}
+	tests := []struct {
+		name      string
+		mode      AuthMode
+		wantToken bool
+		wantCert  bool
+	}{
+		{"impersonate", AuthModeImpersonate, true, true},
+		{"passthrough", AuthModePassthrough, false, false},
+		{"request header", AuthModeRequestHeader, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proxyConfigForAuthMode(config, tt.mode)
+			if (len(got.BearerToken) > 0) != tt.wantToken {
+				t.Errorf("proxyConfigForAuthMode() bearer token = %q, want token %v", got.BearerToken, tt.wantToken)
+			}
+			if (len(got.CertData) > 0) != tt.wantCert {
+				t.Errorf("proxyConfigForAuthMode() cert = %q, want cert %v", got.CertData, tt.wantCert)
+			}
+			if string(got.CAData) != "ca" {
+				t.Errorf("proxyConfigForAuthMode() must keep CA data")
+			}
+			if got.WrapTransport == nil {
+				t.Errorf("proxyConfigForAuthMode() must wrap transport")
+			}
+		})
+	}
+	if len(config.BearerToken) == 0 || len(config.CertData) == 0 {
+		t.Errorf("proxyConfigForAuthMode() modified the original config")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// ResourceBudgetAnnotationKey overrides the default resource budget for one upstream cluster,
+	// the value is a comma separated list of key=value pairs, e.g. maxInflightRequests=400,maxPendingDials=20,maxWatchesPerUser=500
+	ResourceBudgetAnnotationKey = "proxy.kubegateway.io/resource-budget"
+
+	budgetMaxInflightRequests = "maxInflightRequests"
+	budgetMaxPendingDials     = "maxPendingDials"
+	budgetMaxWatchesPerUser   = "maxWatchesPerUser"
+
+	// budget names used in metrics
+	budgetRequests = "requests"
+	budgetDials    = "dials"
+	budgetWatches  = "watches"
+)
+
+var ErrTooManyPendingDials = errors.New("too many pending dials")
+
+// ResourceBudget bounds the resources that requests to one upstream cluster can hold, so that
+// a saturated or extremely slow cluster can not exhaust resources shared by all clusters.
+// Zero value means unlimited.
+type ResourceBudget struct {
+	// MaxInflightRequests is the maximum number of non-long-running requests being proxied to the cluster
+	MaxInflightRequests int32
+	// MaxPendingDials is the maximum number of connections being dialed to the cluster's endpoints
+	MaxPendingDials int32
+	// MaxWatchesPerUser is the maximum number of concurrent watch streams of one user to the cluster
+	MaxWatchesPerUser int32
+}
+
+// ParseResourceBudget parses budget from annotation value, keys not present in value inherit from defaults.
+func ParseResourceBudget(value string, defaults ResourceBudget) (ResourceBudget, error) {
+	budget := defaults
+	for _, s := range strings.Split(value, ",") {
+		if len(s) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return budget, fmt.Errorf("missing value for resource budget %q", s)
+		}
+		k := strings.TrimSpace(kv[0])
+		v, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil {
+			return budget, fmt.Errorf("invalid value of %s=%s, err: %v", k, kv[1], err)
+		}
+		if v < 0 {
+			return budget, fmt.Errorf("invalid value of %s=%s, must not be negative", k, kv[1])
+		}
+		switch k {
+		case budgetMaxInflightRequests:
+			budget.MaxInflightRequests = int32(v)
+		case budgetMaxPendingDials:
+			budget.MaxPendingDials = int32(v)
+		case budgetMaxWatchesPerUser:
+			budget.MaxWatchesPerUser = int32(v)
+		default:
+			return budget, fmt.Errorf("unrecognized resource budget %q", k)
+		}
+	}
+	return budget, nil
+}
+
+// budgetLimiter limits the number of concurrent holders, max <= 0 means unlimited.
+// The max can be changed at any time without affecting current holders.
+type budgetLimiter struct {
+	max     int32
+	current int32
+
+	// waiters of each priority for a free holder, see Acquire
+	mu      sync.Mutex
+	waiting int32
+	waiters [numRequestPriorities][]chan struct{}
+	// queueChanged is called with the new queue length of priority if it is not nil, l.mu is held
+	queueChanged func(priority RequestPriority, length int)
+}
+
+func newBudgetLimiter(max int32) *budgetLimiter {
+	return &budgetLimiter{max: max}
+}
+
+func (l *budgetLimiter) TryAcquire() bool {
+	max := atomic.LoadInt32(&l.max)
+	n := atomic.AddInt32(&l.current, 1)
+	if max > 0 && n > max {
+		atomic.AddInt32(&l.current, -1)
+		return false
+	}
+	return true
+}
+
+func (l *budgetLimiter) Release() {
+	if atomic.LoadInt32(&l.waiting) == 0 {
+		atomic.AddInt32(&l.current, -1)
+		if atomic.LoadInt32(&l.waiting) == 0 {
+			return
+		}
+		// a waiter is queued concurrently, the freed holder may be missed by it
+		l.mu.Lock()
+		l.dispatchLocked()
+		l.mu.Unlock()
+		return
+	}
+	l.mu.Lock()
+	if !l.handOffLocked() {
+		atomic.AddInt32(&l.current, -1)
+	}
+	l.mu.Unlock()
+}
+
+func (l *budgetLimiter) Max() int32 {
+	return atomic.LoadInt32(&l.max)
+}
+
+func (l *budgetLimiter) SetMax(max int32) bool {
+	changed := atomic.SwapInt32(&l.max, max) != max
+	if changed && atomic.LoadInt32(&l.waiting) > 0 {
+		l.mu.Lock()
+		l.dispatchLocked()
+		l.mu.Unlock()
+	}
+	return changed
+}
+
+func (l *budgetLimiter) Current() int32 {
+	return atomic.LoadInt32(&l.current)
+}
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// TryAcquireRequestBudget tries to acquire a slot of cluster inflight requests budget,
+// ReleaseRequestBudget must be called after request finished if it returns true.
+func (c *ClusterInfo) TryAcquireRequestBudget() bool {
+	if !c.requestBudget.TryAcquire() {
+		metrics.RecordClusterBudgetRejected(c.Cluster, budgetRequests)
+		return false
+	}
+	metrics.RecordClusterBudgetAcquired(c.Cluster, budgetRequests)
+	return true
+}
+
+func (c *ClusterInfo) ReleaseRequestBudget() {
+	c.requestBudget.Release()
+	metrics.RecordClusterBudgetReleased(c.Cluster, budgetRequests)
+}
+
+// ResourceBudget returns the current resource budget of this cluster
+func (c *ClusterInfo) ResourceBudget() ResourceBudget {
+	return ResourceBudget{
+		MaxInflightRequests: c.requestBudget.Max(),
+		MaxPendingDials:     c.dialBudget.Max(),
+		MaxWatchesPerUser:   c.watchBudget.Max(),
+	}
+}
+
+// RequestBudgetUtilization returns the ratio of inflight requests to maxInflightRequests budget,
+// 0 means the budget is unlimited.
+func (c *ClusterInfo) RequestBudgetUtilization() float64 {
+	max := c.requestBudget.Max()
+	if max <= 0 {
+		return 0
+	}
+	return float64(c.requestBudget.Current()) / float64(max)
+}
+
+// budgetedDial wraps dial and fails fast if there are too many pending dials to this cluster,
+// so endless pending dials can not pile up goroutines and connections.
+func (c *ClusterInfo) budgetedDial(dial dialFunc) dialFunc {
+	if dial == nil {
+		// the same dialer as newRESTConfig, rest configs of clusters are created by it
+		dial = (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !c.dialBudget.TryAcquire() {
+			metrics.RecordClusterBudgetRejected(c.Cluster, budgetDials)
+			return nil, errors.WithMessagef(ErrTooManyPendingDials, "cluster(%s) reached maxPendingDials=%d when dialing %s", c.Cluster, c.dialBudget.Max(), address)
+		}
+		metrics.RecordClusterBudgetAcquired(c.Cluster, budgetDials)
+		defer func() {
+			c.dialBudget.Release()
+			metrics.RecordClusterBudgetReleased(c.Cluster, budgetDials)
+		}()
+		return dial(ctx, network, address)
+	}
+}
+
+func (c *ClusterInfo) syncResourceBudget(annotations map[string]string) error {
+	budget := c.defaults.ResourceBudget
+	if !gatewayfeatures.Enabled(gatewayfeatures.ClusterResourceBudget) {
+		// unlimited
+		budget = ResourceBudget{}
+	} else if value := annotations[ResourceBudgetAnnotationKey]; len(value) > 0 {
+		var err error
+		budget, err = ParseResourceBudget(value, c.defaults.ResourceBudget)
+		if err != nil {
+			return err
+		}
+	}
+	requestsChanged := c.requestBudget.SetMax(budget.MaxInflightRequests)
+	dialsChanged := c.dialBudget.SetMax(budget.MaxPendingDials)
+	watchesChanged := c.watchBudget.SetMax(budget.MaxWatchesPerUser)
+	if requestsChanged || dialsChanged || watchesChanged {
+		klog.Infof("[cluster info] cluster=%q update resource budget, maxInflightRequests=%d maxPendingDials=%d maxWatchesPerUser=%d", c.Cluster, budget.MaxInflightRequests, budget.MaxPendingDials, budget.MaxWatchesPerUser)
+	}
+	metrics.RecordClusterBudgetLimit(c.Cluster, budgetRequests, budget.MaxInflightRequests)
+	metrics.RecordClusterBudgetLimit(c.Cluster, budgetDials, budget.MaxPendingDials)
+	metrics.RecordClusterBudgetLimit(c.Cluster, budgetWatches, budget.MaxWatchesPerUser)
+	return nil
+}
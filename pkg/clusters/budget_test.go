@@ -0,0 +1,194 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseResourceBudget(t *testing.T) {
+	defaults := ResourceBudget{MaxInflightRequests: 100, MaxPendingDials: 10}
+	tests := []struct {
+		name    string
+		value   string
+		want    ResourceBudget
+		wantErr bool
+	}{
+		{"empty", "", defaults, false},
+		{"override all", "maxInflightRequests=20,maxPendingDials=2", ResourceBudget{MaxInflightRequests: 20, MaxPendingDials: 2}, false},
+		{"override one", "maxPendingDials=0", ResourceBudget{MaxInflightRequests: 100, MaxPendingDials: 0}, false},
+		{"watches per user", "maxWatchesPerUser=50", ResourceBudget{MaxInflightRequests: 100, MaxPendingDials: 10, MaxWatchesPerUser: 50}, false},
+		{"unknown key", "maxConns=1", defaults, true},
+		{"missing value", "maxPendingDials", defaults, true},
+		{"negative", "maxPendingDials=-1", defaults, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResourceBudget(tt.value, defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseResourceBudget() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseResourceBudget() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_RequestBudget(t *testing.T) {
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, nil)
+	if err := info.syncResourceBudget(map[string]string{ResourceBudgetAnnotationKey: "maxInflightRequests=2"}); err != nil {
+		t.Fatalf("syncResourceBudget() error = %v", err)
+	}
+
+	if !info.TryAcquireRequestBudget() || !info.TryAcquireRequestBudget() {
+		t.Fatalf("TryAcquireRequestBudget() failed before budget exhausted")
+	}
+	if info.TryAcquireRequestBudget() {
+		t.Errorf("TryAcquireRequestBudget() succeeded after budget exhausted")
+	}
+	info.ReleaseRequestBudget()
+	if !info.TryAcquireRequestBudget() {
+		t.Errorf("TryAcquireRequestBudget() failed after budget released")
+	}
+
+	// remove annotation, fallback to unlimited default budget
+	if err := info.syncResourceBudget(nil); err != nil {
+		t.Fatalf("syncResourceBudget() error = %v", err)
+	}
+	if !info.TryAcquireRequestBudget() {
+		t.Errorf("TryAcquireRequestBudget() failed with unlimited budget")
+	}
+}
+
+func TestClusterInfo_WatchBudget(t *testing.T) {
+	defaults := NewClusterDefaults()
+	defaults.WatchLimitOverrides = map[string]int32{"controller": 3}
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, defaults)
+	if err := info.syncResourceBudget(map[string]string{ResourceBudgetAnnotationKey: "maxWatchesPerUser=2"}); err != nil {
+		t.Fatalf("syncResourceBudget() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := info.TryAcquireWatchBudget("alice"); !ok {
+			t.Fatalf("TryAcquireWatchBudget() failed before budget exhausted")
+		}
+	}
+	if max, ok := info.TryAcquireWatchBudget("alice"); ok || max != 2 {
+		t.Errorf("TryAcquireWatchBudget() = %v, %v after budget exhausted, want 2, false", max, ok)
+	}
+	// budget is per user
+	if _, ok := info.TryAcquireWatchBudget("bob"); !ok {
+		t.Errorf("TryAcquireWatchBudget() of another user failed")
+	}
+	info.ReleaseWatchBudget("alice")
+	if _, ok := info.TryAcquireWatchBudget("alice"); !ok {
+		t.Errorf("TryAcquireWatchBudget() failed after budget released")
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, ok := info.TryAcquireWatchBudget("controller"); !ok {
+			t.Fatalf("TryAcquireWatchBudget() failed before overridden budget exhausted")
+		}
+	}
+	if max, ok := info.TryAcquireWatchBudget("controller"); ok || max != 3 {
+		t.Errorf("TryAcquireWatchBudget() = %v, %v after overridden budget exhausted, want 3, false", max, ok)
+	}
+}
+
+func TestClusterInfo_budgetedDial(t *testing.T) {
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, nil)
+	if err := info.syncResourceBudget(map[string]string{ResourceBudgetAnnotationKey: "maxPendingDials=1"}); err != nil {
+		t.Fatalf("syncResourceBudget() error = %v", err)
+	}
+
+	pending := make(chan struct{})
+	release := make(chan struct{})
+	dial := info.budgetedDial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		close(pending)
+		<-release
+		return nil, errors.New("dial failed")
+	})
+
+	go dial(context.TODO(), "tcp", "127.0.0.1:443") //nolint
+	<-pending
+
+	if _, err := dial(context.TODO(), "tcp", "127.0.0.1:443"); !errors.Is(err, ErrTooManyPendingDials) {
+		t.Errorf("budgetedDial() error = %v, want %v", err, ErrTooManyPendingDials)
+	}
+	close(release)
+}
+
+func Test_budgetLimiter_Acquire(t *testing.T) {
+	l := newBudgetLimiter(1)
+	// queue lengths of each priority, they are changed with l.mu held
+	var queued [numRequestPriorities][]int
+	l.queueChanged = func(priority RequestPriority, length int) {
+		queued[priority] = append(queued[priority], length)
+	}
+	ctx := context.Background()
+	if !l.Acquire(ctx, RequestPriorityNormal, 0) {
+		t.Fatalf("Acquire() failed before budget exhausted")
+	}
+	if l.Acquire(ctx, RequestPriorityHigh, 0) {
+		t.Fatalf("Acquire() without timeout succeeded after budget exhausted")
+	}
+	if l.Acquire(ctx, RequestPriorityHigh, 10*time.Millisecond) {
+		t.Fatalf("Acquire() succeeded after queue timeout")
+	}
+
+	order := make(chan RequestPriority, 2)
+	for i, p := range []RequestPriority{RequestPriorityLow, RequestPriorityHigh} {
+		go func(p RequestPriority) {
+			if l.Acquire(ctx, p, 5*time.Second) {
+				order <- p
+			}
+		}(p)
+		// make sure the low priority request is queued first
+		for atomic.LoadInt32(&l.waiting) != int32(i+1) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	l.Release()
+	if got := <-order; got != RequestPriorityHigh {
+		t.Errorf("Acquire() admitted %v first, want high", got)
+	}
+	l.Release()
+	if got := <-order; got != RequestPriorityLow {
+		t.Errorf("Acquire() admitted %v second, want low", got)
+	}
+	if current := l.Current(); current != 1 {
+		t.Errorf("Current() = %v, want 1", current)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for p, want := range map[RequestPriority][]int{
+		RequestPriorityLow:    {1, 0},
+		RequestPriorityNormal: nil,
+		RequestPriorityHigh:   {1, 0, 1, 0},
+	} {
+		if got := queued[p]; !reflect.DeepEqual(got, want) {
+			t.Errorf("queue lengths of %v = %v, want %v", p, got, want)
+		}
+	}
+}
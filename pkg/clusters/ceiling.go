@@ -0,0 +1,285 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	gatewaynet "github.com/kubewharf/kubegateway/pkg/gateway/net"
+)
+
+const (
+	// CeilingsAnnotationKey overrides the default ceilings for one upstream cluster, the value is
+	// a comma separated list of key=value pairs, e.g. maxQPS=2000,maxBytesPerSecond=104857600
+	CeilingsAnnotationKey = "proxy.kubegateway.io/ceilings"
+
+	ceilingMaxQPS            = "maxQPS"
+	ceilingMaxBytesPerSecond = "maxBytesPerSecond"
+
+	// ceiling names used in metrics
+	ceilingQPS   = "qps"
+	ceilingBytes = "bytes"
+
+	// EventReasonCeilingSaturated is the reason of events emitted when a ceiling of cluster is saturated
+	EventReasonCeilingSaturated = "CeilingSaturated"
+
+	ceilingEventInterval = time.Minute
+)
+
+// CeilingSharer splits ceilings of a cluster among gateway replicas, Share returns the part of
+// total this replica enforces. It is implemented by coordination.Coordinator.
+type CeilingSharer interface {
+	Share(total int64) int64
+}
+
+// ClusterCeilings are coarse guards of the aggregate traffic gateway sends toward one upstream
+// cluster regardless of per-user budgets and flow controls, they are the ultimate backstop
+// during client storms. Zero value means unlimited.
+type ClusterCeilings struct {
+	// MaxQPS is the maximum number of requests per second proxied to the cluster, requests
+	// exceeding it are rejected
+	MaxQPS int32
+	// MaxBytesPerSecond is the maximum bytes per second of request bodies sent to the cluster,
+	// bodies exceeding it are throttled
+	MaxBytesPerSecond int64
+}
+
+// ParseClusterCeilings parses ceilings from annotation value, keys not present in value inherit from defaults.
+func ParseClusterCeilings(value string, defaults ClusterCeilings) (ClusterCeilings, error) {
+	ceilings := defaults
+	for _, s := range strings.Split(value, ",") {
+		if len(s) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return ceilings, fmt.Errorf("missing value for ceiling %q", s)
+		}
+		k := strings.TrimSpace(kv[0])
+		v, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return ceilings, fmt.Errorf("invalid value of %s=%s, err: %v", k, kv[1], err)
+		}
+		if v < 0 {
+			return ceilings, fmt.Errorf("invalid value of %s=%s, must not be negative", k, kv[1])
+		}
+		switch k {
+		case ceilingMaxQPS:
+			if v > 1<<31-1 {
+				return ceilings, fmt.Errorf("invalid value of %s=%s, too large", k, kv[1])
+			}
+			ceilings.MaxQPS = int32(v)
+		case ceilingMaxBytesPerSecond:
+			ceilings.MaxBytesPerSecond = v
+		default:
+			return ceilings, fmt.Errorf("unrecognized ceiling %q", k)
+		}
+	}
+	return ceilings, nil
+}
+
+// EventReference returns the reference of UpstreamCluster object which events of cluster are emitted on.
+// UpstreamCluster is cluster scoped, but there is no default namespace in control plane to keep events
+// of cluster scoped objects, so they are kept in kube-system.
+func EventReference(cluster string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: proxyv1alpha1.SchemeGroupVersion.String(),
+		Kind:       "UpstreamCluster",
+		Name:       cluster,
+		Namespace:  metav1.NamespaceSystem,
+	}
+}
+
+// ceilingLimiter holds rate limiters of one cluster's ceilings, they are replaced as a whole
+// when ceilings or the share of this replica change.
+type ceilingLimiter struct {
+	// ceilings are the aggregate ceilings of the cluster across all replicas
+	ceilings ClusterCeilings
+	// enforced is the part of ceilings enforced by this replica
+	enforced ClusterCeilings
+	qps      flowcontrol.RateLimiter
+	bytes    *gatewaynet.BandwidthLimiter
+}
+
+func newCeilingLimiter(ceilings ClusterCeilings, sharer CeilingSharer) *ceilingLimiter {
+	enforced := ClusterCeilings{
+		MaxQPS:            int32(shareCeiling(sharer, int64(ceilings.MaxQPS))),
+		MaxBytesPerSecond: shareCeiling(sharer, ceilings.MaxBytesPerSecond),
+	}
+	l := &ceilingLimiter{ceilings: ceilings, enforced: enforced}
+	if enforced.MaxQPS > 0 {
+		l.qps = flowcontrol.NewTokenBucketRateLimiter(float32(enforced.MaxQPS), int(enforced.MaxQPS))
+	}
+	if enforced.MaxBytesPerSecond > 0 {
+		l.bytes = gatewaynet.NewBandwidthLimiter(enforced.MaxBytesPerSecond, 0)
+	}
+	return l
+}
+
+// shareCeiling returns the part of ceiling total enforced by this replica. A limited ceiling
+// never becomes unlimited on any replica, so each share is rounded up to at least 1, the sum of
+// shares may exceed total by less than the number of replicas.
+func shareCeiling(sharer CeilingSharer, total int64) int64 {
+	if sharer == nil || total <= 0 {
+		return total
+	}
+	if share := sharer.Share(total); share > 0 {
+		return share
+	}
+	return 1
+}
+
+// ceilingEvents throttles saturation events of each ceiling to one per ceilingEventInterval
+type ceilingEvents struct {
+	// recorder emits events on UpstreamClusters, nil means no event is emitted
+	recorder record.EventRecorder
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (e *ceilingEvents) emit(cluster, ceiling, message string) {
+	if e.recorder == nil {
+		return
+	}
+	now := time.Now()
+	e.mu.Lock()
+	if e.last == nil {
+		e.last = map[string]time.Time{}
+	}
+	if now.Sub(e.last[ceiling]) < ceilingEventInterval {
+		e.mu.Unlock()
+		return
+	}
+	e.last[ceiling] = now
+	e.mu.Unlock()
+	e.recorder.Event(EventReference(cluster), corev1.EventTypeWarning, EventReasonCeilingSaturated, message)
+}
+
+// ClusterCeilings returns the current aggregate ceilings of this cluster
+func (c *ClusterInfo) ClusterCeilings() ClusterCeilings {
+	return c.loadCeilings().ceilings
+}
+
+// EnforcedCeilings returns the part of ceilings of this cluster enforced by this replica
+func (c *ClusterInfo) EnforcedCeilings() ClusterCeilings {
+	return c.loadCeilings().enforced
+}
+
+// ShareCeilings splits ceilings of this cluster among replicas by sharer from now on, limiters
+// are rebuilt only if the share of this replica changes. It is called again whenever the set of
+// live replicas may have changed, nil sharer enforces the whole ceilings on this replica.
+func (c *ClusterInfo) ShareCeilings(sharer CeilingSharer) {
+	c.ceilingsLock.Lock()
+	defer c.ceilingsLock.Unlock()
+	c.ceilingSharer = sharer
+	c.storeCeilings(c.loadCeilings().ceilings)
+}
+
+func (c *ClusterInfo) loadCeilings() *ceilingLimiter {
+	if l, ok := c.ceilings.Load().(*ceilingLimiter); ok {
+		return l
+	}
+	return &ceilingLimiter{}
+}
+
+// TryAcceptQPSCeiling returns false if the request exceeds the maxQPS ceiling of this cluster
+func (c *ClusterInfo) TryAcceptQPSCeiling() bool {
+	l := c.loadCeilings()
+	if l.qps == nil || l.qps.TryAccept() {
+		return true
+	}
+	metrics.RecordClusterCeilingSaturated(c.Cluster, ceilingQPS)
+	c.ceilingEvents.emit(c.Cluster, ceilingQPS, fmt.Sprintf("requests are rejected by ceiling maxQPS=%d of cluster %s", l.enforced.MaxQPS, c.Cluster))
+	return false
+}
+
+// ThrottleRequestBody returns body throttled by the maxBytesPerSecond ceiling of this cluster
+func (c *ClusterInfo) ThrottleRequestBody(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	l := c.loadCeilings()
+	if l.bytes == nil || body == nil {
+		return body
+	}
+	return &ceilingThrottledBody{ReadCloser: body, ctx: ctx, cluster: c, limiter: l}
+}
+
+type ceilingThrottledBody struct {
+	io.ReadCloser
+	ctx       context.Context
+	cluster   *ClusterInfo
+	limiter   *ceilingLimiter
+	saturated int32
+}
+
+func (b *ceilingThrottledBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n <= 0 {
+		return n, err
+	}
+	wait, werr := b.limiter.bytes.WaitN(b.ctx, n)
+	if werr != nil {
+		return n, werr
+	}
+	if wait > 0 && atomic.CompareAndSwapInt32(&b.saturated, 0, 1) {
+		// count each throttled body once
+		c := b.cluster
+		metrics.RecordClusterCeilingSaturated(c.Cluster, ceilingBytes)
+		c.ceilingEvents.emit(c.Cluster, ceilingBytes, fmt.Sprintf("request bodies are throttled by ceiling maxBytesPerSecond=%d of cluster %s", b.limiter.enforced.MaxBytesPerSecond, c.Cluster))
+	}
+	return n, err
+}
+
+func (c *ClusterInfo) syncClusterCeilings(annotations map[string]string) error {
+	ceilings := c.defaults.Ceilings
+	if value := annotations[CeilingsAnnotationKey]; len(value) > 0 {
+		var err error
+		ceilings, err = ParseClusterCeilings(value, c.defaults.Ceilings)
+		if err != nil {
+			return err
+		}
+	}
+	c.ceilingsLock.Lock()
+	defer c.ceilingsLock.Unlock()
+	c.storeCeilings(ceilings)
+	return nil
+}
+
+// storeCeilings replaces limiters of this cluster if ceilings or the share of this replica
+// change, ceilingsLock must be held.
+func (c *ClusterInfo) storeCeilings(ceilings ClusterCeilings) {
+	l := newCeilingLimiter(ceilings, c.ceilingSharer)
+	if current, ok := c.ceilings.Load().(*ceilingLimiter); !ok || current.ceilings != l.ceilings || current.enforced != l.enforced {
+		c.ceilings.Store(l)
+		klog.Infof("[cluster info] cluster=%q update ceilings, maxQPS=%d maxBytesPerSecond=%d, enforced by this replica maxQPS=%d maxBytesPerSecond=%d",
+			c.Cluster, ceilings.MaxQPS, ceilings.MaxBytesPerSecond, l.enforced.MaxQPS, l.enforced.MaxBytesPerSecond)
+	}
+	metrics.RecordClusterCeilingLimit(c.Cluster, ceilingQPS, int64(l.enforced.MaxQPS))
+	metrics.RecordClusterCeilingLimit(c.Cluster, ceilingBytes, l.enforced.MaxBytesPerSecond)
+}
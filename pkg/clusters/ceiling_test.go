@@ -0,0 +1,165 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+)
+
+func TestParseClusterCeilings(t *testing.T) {
+	defaults := ClusterCeilings{MaxQPS: 1000}
+	tests := []struct {
+		name    string
+		value   string
+		want    ClusterCeilings
+		wantErr bool
+	}{
+		{"empty", "", defaults, false},
+		{"override all", "maxQPS=20,maxBytesPerSecond=1048576", ClusterCeilings{MaxQPS: 20, MaxBytesPerSecond: 1048576}, false},
+		{"override one", "maxBytesPerSecond=100", ClusterCeilings{MaxQPS: 1000, MaxBytesPerSecond: 100}, false},
+		{"unlimited", "maxQPS=0", ClusterCeilings{}, false},
+		{"unknown key", "maxConns=1", defaults, true},
+		{"missing value", "maxQPS", defaults, true},
+		{"negative", "maxQPS=-1", defaults, true},
+		{"qps overflow", "maxQPS=4294967296", defaults, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClusterCeilings(tt.value, defaults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseClusterCeilings() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseClusterCeilings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_QPSCeiling(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	defaults := NewClusterDefaults()
+	defaults.EventRecorder = recorder
+
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, defaults)
+	if !info.TryAcceptQPSCeiling() {
+		t.Fatalf("TryAcceptQPSCeiling() failed without ceiling")
+	}
+	if err := info.syncClusterCeilings(map[string]string{CeilingsAnnotationKey: "maxQPS=2"}); err != nil {
+		t.Fatalf("syncClusterCeilings() error = %v", err)
+	}
+	if !info.TryAcceptQPSCeiling() || !info.TryAcceptQPSCeiling() {
+		t.Fatalf("TryAcceptQPSCeiling() failed before ceiling saturated")
+	}
+	for i := 0; i < 3; i++ {
+		if info.TryAcceptQPSCeiling() {
+			t.Errorf("TryAcceptQPSCeiling() succeeded after ceiling saturated")
+		}
+	}
+	// events are throttled
+	if len(recorder.Events) != 1 {
+		t.Errorf("got %d events, want 1", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.Contains(event, EventReasonCeilingSaturated) {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	// remove annotation, fallback to unlimited default ceilings
+	if err := info.syncClusterCeilings(nil); err != nil {
+		t.Fatalf("syncClusterCeilings() error = %v", err)
+	}
+	if !info.TryAcceptQPSCeiling() {
+		t.Errorf("TryAcceptQPSCeiling() failed with unlimited ceiling")
+	}
+}
+
+func TestClusterInfo_ThrottleRequestBody(t *testing.T) {
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, nil)
+	body := ioutil.NopCloser(strings.NewReader("hello"))
+	if got := info.ThrottleRequestBody(context.Background(), body); got != body {
+		t.Errorf("ThrottleRequestBody() wraps body without ceiling")
+	}
+
+	if err := info.syncClusterCeilings(map[string]string{CeilingsAnnotationKey: "maxBytesPerSecond=1048576"}); err != nil {
+		t.Fatalf("syncClusterCeilings() error = %v", err)
+	}
+	throttled := info.ThrottleRequestBody(context.Background(), body)
+	if throttled == body {
+		t.Fatalf("ThrottleRequestBody() does not wrap body with ceiling")
+	}
+	data, err := ioutil.ReadAll(throttled)
+	if err != nil || string(data) != "hello" {
+		t.Errorf("read throttled body = %q, %v", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	throttled = info.ThrottleRequestBody(ctx, ioutil.NopCloser(strings.NewReader(strings.Repeat("x", 4<<20))))
+	if _, err := ioutil.ReadAll(throttled); err == nil {
+		t.Errorf("read throttled body succeeded after context is canceled")
+	}
+}
+
+type fixedSharer struct {
+	replicas int64
+}
+
+func (s *fixedSharer) Share(total int64) int64 {
+	return total / s.replicas
+}
+
+func TestClusterInfo_ShareCeilings(t *testing.T) {
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, nil)
+	if err := info.syncClusterCeilings(map[string]string{CeilingsAnnotationKey: "maxQPS=4,maxBytesPerSecond=2"}); err != nil {
+		t.Fatalf("syncClusterCeilings() error = %v", err)
+	}
+	sharer := &fixedSharer{replicas: 2}
+	info.ShareCeilings(sharer)
+	if got, want := info.EnforcedCeilings(), (ClusterCeilings{MaxQPS: 2, MaxBytesPerSecond: 1}); got != want {
+		t.Errorf("EnforcedCeilings() = %v, want %v", got, want)
+	}
+	if got, want := info.ClusterCeilings(), (ClusterCeilings{MaxQPS: 4, MaxBytesPerSecond: 2}); got != want {
+		t.Errorf("ClusterCeilings() = %v, want %v", got, want)
+	}
+	if !info.TryAcceptQPSCeiling() || !info.TryAcceptQPSCeiling() || info.TryAcceptQPSCeiling() {
+		t.Errorf("TryAcceptQPSCeiling() does not enforce the share of this replica")
+	}
+
+	// shares are kept when ceilings change and never round down to unlimited
+	sharer.replicas = 8
+	if err := info.syncClusterCeilings(map[string]string{CeilingsAnnotationKey: "maxQPS=8"}); err != nil {
+		t.Fatalf("syncClusterCeilings() error = %v", err)
+	}
+	if got, want := info.EnforcedCeilings(), (ClusterCeilings{MaxQPS: 1}); got != want {
+		t.Errorf("EnforcedCeilings() = %v, want %v", got, want)
+	}
+	sharer.replicas = 16
+	info.ShareCeilings(sharer)
+	if got, want := info.EnforcedCeilings(), (ClusterCeilings{MaxQPS: 1}); got != want {
+		t.Errorf("EnforcedCeilings() = %v, want %v", got, want)
+	}
+
+	info.ShareCeilings(nil)
+	if got, want := info.EnforcedCeilings(), (ClusterCeilings{MaxQPS: 8}); got != want {
+		t.Errorf("EnforcedCeilings() without sharer = %v, want %v", got, want)
+	}
+}
@@ -15,11 +15,16 @@
 package clusters
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
 )
 
+// ErrFleetHostname is returned by the ClientProvider of NewFleetClientProvider for the fleet hostname
+var ErrFleetHostname = errors.New("fleet hostname is not an upstream cluster")
+
 type ClientProvider interface {
 	ClientFor(name string) (*ClusterInfo, kubernetes.Interface, error)
 }
@@ -35,3 +40,29 @@ func (m *manager) ClientFor(name string) (*ClusterInfo, kubernetes.Interface, er
 	}
 	return cluster, endpoint.Clientset(), nil
 }
+
+type fleetClientProvider struct {
+	ClientProvider
+	hostname string
+	members  []string
+}
+
+// NewFleetClientProvider returns a ClientProvider which never resolves the fleet hostname to a member
+// cluster, it returns ErrFleetHostname instead. A token reviewed or a user authorized by one member means
+// nothing to other members, so requests to the fleet hostname are only authenticated by gateway-level
+// authenticators, e.g. x509 and OIDC, and every member authorizes the request fanned out to it as the
+// client itself. Other names are passed to delegate.
+func NewFleetClientProvider(delegate ClientProvider, hostname string, members []string) ClientProvider {
+	return &fleetClientProvider{
+		ClientProvider: delegate,
+		hostname:       strings.ToLower(hostname),
+		members:        members,
+	}
+}
+
+func (p *fleetClientProvider) ClientFor(name string) (*ClusterInfo, kubernetes.Interface, error) {
+	if strings.ToLower(name) != p.hostname {
+		return p.ClientProvider.ClientFor(name)
+	}
+	return nil, nil, fmt.Errorf("fleet %q of clusters %v: %w", name, p.members, ErrFleetHostname)
+}
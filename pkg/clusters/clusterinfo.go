@@ -28,6 +28,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/zoumo/goset"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/apiserver/pkg/authorization/authorizer"
@@ -40,6 +41,8 @@ import (
 	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
 	"github.com/kubewharf/kubegateway/pkg/clusters/features"
 	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
 	"github.com/kubewharf/kubegateway/pkg/transport"
 )
 
@@ -51,27 +54,50 @@ var (
 // EndpointPicker knows
 type EndpointPicker interface {
 	FlowControl() gatewayflowcontrol.FlowControl
+	// FlowSchema names the dispatch policy or rule which selected the flow control, e.g. dispatch-policy-0
+	FlowSchema() string
+	// AuditOnlyFlowControls are evaluated in shadow, requests rejected by them are only logged and counted
+	AuditOnlyFlowControls() []gatewayflowcontrol.FlowControl
 	Pop() (*EndpointInfo, error)
 	EnableLog() bool
 }
 
 // endpointPickStrategy implement EndpointPicker interface
 type endpointPickStrategy struct {
-	cluster     *ClusterInfo
+	// routing snapshot when the request is matched, it is never changed during picking
+	routing     *routingSnapshot
+	counter     *uint64
 	strategy    proxyv1alpha1.Strategy
 	flowControl gatewayflowcontrol.FlowControl
+	flowSchema  string
+	auditOnly   []gatewayflowcontrol.FlowControl
 	upstreams   []string
 	enableLog   bool
+	// write requests are routed to endpoints with healthy etcd if possible
+	write bool
+	// endpoints picked if none of upstreams is ready, e.g. core apiservers of an aggregated API group
+	fallback []string
 }
 
 func (s *endpointPickStrategy) Pop() (*EndpointInfo, error) {
+	ep, err := s.pop()
+	if err != nil && len(s.fallback) > 0 {
+		fallback := *s
+		fallback.upstreams, fallback.fallback = s.fallback, nil
+		fallback.counter = s.routing.counterOf(s.fallback)
+		return fallback.pop()
+	}
+	return ep, err
+}
+
+func (s *endpointPickStrategy) pop() (*EndpointInfo, error) {
 	if len(s.upstreams) == 0 {
 		return nil, ErrNoReadyEndpoints
 	}
 	readyEndpoints := []*EndpointInfo{}
 	unreadyReason := []string{}
 	for _, ep := range s.upstreams {
-		info, ok := s.cluster.Endpoints.Load(ep)
+		info, ok := s.routing.endpoints[ep]
 		if ok {
 			if info.IsReady() {
 				readyEndpoints = append(readyEndpoints, info)
@@ -83,16 +109,20 @@ func (s *endpointPickStrategy) Pop() (*EndpointInfo, error) {
 	if len(readyEndpoints) == 0 {
 		return nil, errors.WithMessage(ErrNoReadyEndpoints, strings.Join(unreadyReason, " "))
 	}
+	if s.write {
+		readyEndpoints = preferEtcdHealthy(readyEndpoints)
+	}
 
 	if len(readyEndpoints) == 1 {
 		return readyEndpoints[0], nil
 	}
 
+	if gatewayfeatures.Enabled(gatewayfeatures.EndpointScoring) {
+		return pickWeighted(readyEndpoints), nil
+	}
+
 	// TODO: apply strategy
-	key := fmt.Sprintf("%v", readyEndpoints)
-	var i uint64
-	lb, _ := s.cluster.loadbalancer.LoadOrStore(key, &i)
-	index := atomic.AddUint64(lb.(*uint64), 1)
+	index := atomic.AddUint64(s.counter, 1)
 	index = index % uint64(len(readyEndpoints))
 	return readyEndpoints[index], nil
 }
@@ -105,6 +135,14 @@ func (s *endpointPickStrategy) FlowControl() gatewayflowcontrol.FlowControl {
 	return s.flowControl
 }
 
+func (s *endpointPickStrategy) FlowSchema() string {
+	return s.flowSchema
+}
+
+func (s *endpointPickStrategy) AuditOnlyFlowControls() []gatewayflowcontrol.FlowControl {
+	return s.auditOnly
+}
+
 // ClusterInfo is a wrapper to a UpstreamCluster with additional information
 type ClusterInfo struct {
 	// server Cluster
@@ -119,10 +157,13 @@ type ClusterInfo struct {
 
 	defaultFlowControl gatewayflowcontrol.FlowControl
 	flowcontrol        *gatewayflowcontrol.FlowControls
-	loadbalancer       sync.Map
+	// current routing snapshot read by the request path, see routingSnapshot
+	currentRouting atomic.Value
 
 	// upstream endpoint client rest config, the host must be replaced when using it
 	restConfig *rest.Config
+	// how proxied requests are authenticated to upstream, it never changes after created
+	authMode AuthMode
 	// current synced flow controler spec
 	currentFlowControlSpec atomic.Value
 	// current synced tls config for secure seving
@@ -132,9 +173,54 @@ type ClusterInfo struct {
 	// current logging config
 	currentLoggingConfig atomic.Value
 	featuregate          featuregate.MutableFeatureGate
+	// current cors policy overridden by annotation
+	currentCORSPolicy atomic.Value
+	// current rules of CORS headers sent from upstream
+	currentUpstreamCORSRules atomic.Value
+	// current redirect policy overridden by annotation
+	currentRedirectPolicy atomic.Value
+	// current fault injection set by admin API
+	currentFaultInjection atomic.Value
+	// current endpoints of aggregated API groups overridden by annotation
+	currentAPIGroupEndpoints atomic.Value
+	// current names of rules which only log and count rejections
+	currentAuditOnlyRules atomic.Value
+	// current expected identities of endpoints verified at TLS handshakes
+	currentEndpointIdentities atomic.Value
+	// current flush intervals of proxied responses
+	currentFlushIntervals atomic.Value
+	// current rules overriding dispatch policies by User-Agent
+	currentUserAgentRules atomic.Value
+	// current rules denying or overriding dispatch policies by expressions
+	currentExpressionRules atomic.Value
+	// current sampling intervals of error logs
+	currentErrorLogSampling atomic.Value
+	// current readers split from writers by annotation
+	currentReadReplicas atomic.Value
+	// users whose last write is within readYourWrites window of read replicas
+	recentWriters *cache.LRUExpireCache
+	// sampling state of error logs, keyed by error class
+	errorLogs sync.Map
+
+	// resource budgets isolate this cluster from others
+	requestBudget *budgetLimiter
+	dialBudget    *budgetLimiter
+	watchBudget   *watchLimiter
+
+	// ceilings guard the aggregate traffic to this cluster, see ceiling.go
+	ceilings      atomic.Value
+	ceilingsLock  sync.Mutex
+	ceilingSharer CeilingSharer
+	ceilingEvents ceilingEvents
+
+	// availability notified to notification sink, see notify.go
+	availability int32
 
 	healthCheckIntervalSeconds time.Duration
 	endpointHeathCheck         EndpointHealthCheck
+
+	// defaults of gateway which created this cluster
+	defaults *ClusterDefaults
 }
 
 type secureServingConfig struct {
@@ -144,9 +230,11 @@ type secureServingConfig struct {
 	verifyOptions *x509.VerifyOptions
 }
 
-// NewEmptyClusterInfo creates a empty ClusterInfo without UpstreamCluster information such as endpoints
-func NewEmptyClusterInfo(clusterName string, config *rest.Config, healthCheck EndpointHealthCheck) *ClusterInfo {
+// NewEmptyClusterInfo creates a empty ClusterInfo without UpstreamCluster information such as endpoints,
+// nil defaults means NewClusterDefaults.
+func NewEmptyClusterInfo(clusterName string, config *rest.Config, healthCheck EndpointHealthCheck, defaults *ClusterDefaults) *ClusterInfo {
 	clusterName = strings.ToLower(clusterName)
+	defaults = orNewClusterDefaults(defaults)
 	ctx, cancel := context.WithCancel(context.Background())
 	info := &ClusterInfo{
 		ctx:                        ctx,
@@ -155,24 +243,36 @@ func NewEmptyClusterInfo(clusterName string, config *rest.Config, healthCheck En
 		restConfig:                 config,
 		Endpoints:                  &EndpointInfoMap{data: sync.Map{}},
 		healthCheckIntervalSeconds: 5 * time.Second,
-		defaultFlowControl:         gatewayflowcontrol.DefaultFlowControl,
+		defaultFlowControl:         gatewayflowcontrol.NewObservedFlowControl(clusterName, gatewayflowcontrol.DefaultFlowControlSchema),
 		flowcontrol:                gatewayflowcontrol.NewFlowControls(),
-		loadbalancer:               sync.Map{},
 		endpointHeathCheck:         healthCheck,
 		featuregate:                features.DefaultMutableFeatureGate.DeepCopy(),
+		requestBudget:              newBudgetLimiter(defaults.ResourceBudget.MaxInflightRequests),
+		dialBudget:                 newBudgetLimiter(defaults.ResourceBudget.MaxPendingDials),
+		watchBudget:                newWatchLimiter(defaults.ResourceBudget.MaxWatchesPerUser, defaults.WatchLimitOverrides),
+		ceilingEvents:              ceilingEvents{recorder: defaults.EventRecorder},
+		recentWriters:              newRecentWriters(),
+		defaults:                   defaults,
+	}
+	info.ceilings.Store(newCeilingLimiter(defaults.Ceilings, nil))
+	info.requestBudget.queueChanged = func(priority RequestPriority, length int) {
+		metrics.RecordClusterBudgetQueueLength(clusterName, priority.String(), length)
 	}
 	return info
 }
 
-// CreateClusterInfo try every endpoint to find a ready endpoint, and then init rest config
-func CreateClusterInfo(cluster *proxyv1alpha1.UpstreamCluster, healthCheck EndpointHealthCheck) (*ClusterInfo, error) {
-	restconfig, err := buildClusterRESTConfig(cluster)
+// CreateClusterInfo try every endpoint to find a ready endpoint, and then init rest config, nil defaults
+// means NewClusterDefaults.
+func CreateClusterInfo(cluster *proxyv1alpha1.UpstreamCluster, healthCheck EndpointHealthCheck, defaults *ClusterDefaults) (*ClusterInfo, error) {
+	defaults = orNewClusterDefaults(defaults)
+	restconfig, err := buildClusterRESTConfig(cluster, defaults.UpstreamCredentials)
 	if err != nil {
 		return nil, err
 	}
 
 	klog.Infof("create valid rest config for cluster: %v", cluster.Name)
-	info := NewEmptyClusterInfo(cluster.Name, restconfig, healthCheck)
+	info := NewEmptyClusterInfo(cluster.Name, restconfig, healthCheck, defaults)
+	info.authMode = AuthModeOf(cluster.Annotations, defaults.AuthMode)
 	err = info.Sync(cluster)
 	if err != nil {
 		return nil, err
@@ -282,6 +382,9 @@ func (c *ClusterInfo) Sync(cluster *proxyv1alpha1.UpstreamCluster) error {
 
 	klog.V(5).Infof("[cluster info] syncing cluster info, name=%q", c.Cluster)
 
+	// requests keep routing with the previous snapshot until all changes are synced
+	defer c.publishRouting()
+
 	// update flow control
 	c.syncFlowControlLocked(cluster.Spec.FlowControl)
 
@@ -290,6 +393,71 @@ func (c *ClusterInfo) Sync(cluster *proxyv1alpha1.UpstreamCluster) error {
 		return err
 	}
 
+	if err := c.syncResourceBudget(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncClusterCeilings(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncCORSPolicy(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncUpstreamCORSRules(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncRedirectPolicy(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncAuditOnlyRules(cluster.Annotations, flowControlSchemaNames(cluster.Spec.FlowControl)); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncEndpointIdentities(cluster.Annotations, cluster.Spec.Servers); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncUserAgentRules(cluster.Annotations, cluster.Spec.Servers, flowControlSchemaNames(cluster.Spec.FlowControl)); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncExpressionRules(cluster.Annotations, cluster.Spec.Servers, flowControlSchemaNames(cluster.Spec.FlowControl)); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncFlushIntervals(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncErrorLogSampling(cluster.Annotations); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncAPIGroupEndpoints(cluster.Annotations, cluster.Spec.Servers); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
+	if err := c.syncReadReplicas(cluster.Annotations, cluster.Spec.Servers); err != nil {
+		// we should never get here because there is validating admission
+		return err
+	}
+
 	// add or update endpoints
 	if err := c.syncEndpoints(cluster.Spec.Servers); err != nil {
 		return err
@@ -315,14 +483,12 @@ func (c *ClusterInfo) syncEndpoints(servers []proxyv1alpha1.UpstreamClusterServe
 	for _, e := range servers {
 		wantedEPs.Add(e.Endpoint) //nolint
 	}
+	// extension servers of aggregated API groups are health checked like servers
+	for _, ep := range c.loadAPIGroupEndpoints().extensions.List() {
+		wantedEPs.Add(ep) //nolint
+	}
 
 	deleted := currentEPs.Diff(wantedEPs)
-	added := wantedEPs.Diff(currentEPs)
-
-	if added.Len() > 0 || deleted.Len() > 0 {
-		// servers changed, reset loadbalancer
-		c.loadbalancer = sync.Map{}
-	}
 
 	deleted.Range(func(index int, elem interface{}) bool {
 		ep := elem.(string)
@@ -331,9 +497,11 @@ func (c *ClusterInfo) syncEndpoints(servers []proxyv1alpha1.UpstreamClusterServe
 			return true
 		}
 		klog.Infof("[cluster info] endpoint=%q is deleted from cluster %q", info.Endpoint, c.Cluster)
-		if info.cancel != nil {
-			info.cancel()
-		}
+		info.stop()
+		releaseStaleEndpoint(c.Cluster, ep, func() bool {
+			_, ok := c.Endpoints.Load(ep)
+			return ok && c.ctx.Err() == nil
+		})
 		return true
 	})
 
@@ -383,7 +551,7 @@ func (c *ClusterInfo) syncFlowControlLocked(newObj proxyv1alpha1.FlowControl) {
 		fc, ok := c.flowcontrol.Load(newSchema.Name)
 		if !ok || oldType != newType {
 			// flow control is not created or type changed
-			newFC := gatewayflowcontrol.NewFlowControl(newSchema)
+			newFC := gatewayflowcontrol.NewObservedFlowControl(c.Cluster, newSchema)
 			c.flowcontrol.Store(newSchema.Name, newFC)
 			klog.Infof("[cluster info] cluster=%q ensure flowcontrol schema %v", c.Cluster, newFC.String())
 			continue
@@ -484,52 +652,105 @@ func (c *ClusterInfo) Stop() {
 	if c.cancel != nil {
 		c.cancel()
 	}
+	// tear down health checks and connection pools of all endpoints
+	c.Endpoints.Range(func(name string, info *EndpointInfo) bool {
+		info.stop()
+		return true
+	})
 }
 
 // MatchAttributes matches a requestAttributes from reqeust and return a flowcontrol and endpointPicker
 func (c *ClusterInfo) MatchAttributes(requestAttributes authorizer.Attributes) (EndpointPicker, error) {
-	policies := c.loadDispatchPolicies()
-	logging := c.loadLoggingConfig()
-	policy := MatchPolicies(requestAttributes, policies)
-	if policy == nil {
+	return c.MatchRequest(requestAttributes, "")
+}
+
+// MatchRequest is like MatchAttributes, but upstream subset and flow control schema of the matched
+// dispatch policy may be overridden by the User-Agent rules of this cluster.
+func (c *ClusterInfo) MatchRequest(requestAttributes authorizer.Attributes, userAgent string) (EndpointPicker, error) {
+	routing := c.loadRouting()
+	index := matchPolicyIndex(requestAttributes, routing.policies)
+	if index < 0 {
 		return nil, ErrNoRouterRuleMatches
 	}
+	policy := &routing.policies[index]
+
+	// audit-only schemas are evaluated in shadow, requests are still limited by the schema which
+	// would be selected if they did not exist
+	var schema string
+	var auditOnly []string
+	flowSchema := fmt.Sprintf("dispatch-policy-%d", index)
+	selectSchema := func(name, rule string) {
+		if c.IsAuditOnly(name) {
+			auditOnly = append(auditOnly, name)
+			return
+		}
+		schema, flowSchema = name, rule
+	}
+	selectSchema(policy.FlowControlSchemaName, flowSchema)
+
+	subset := policy.UpstreamSubset
+	if rule, name := routing.matchUserAgentRule(userAgent); rule != nil {
+		if len(rule.FlowControlSchemaName) > 0 {
+			selectSchema(rule.FlowControlSchemaName, name)
+		}
+		if len(rule.UpstreamSubset) > 0 {
+			subset = rule.UpstreamSubset
+		}
+	}
+	if rule := routing.matchExpressionRule(c.Cluster, requestAttributes, userAgent); rule != nil {
+		if rule.Deny {
+			return nil, &ExpressionDeniedError{Rule: rule.Name, Message: rule.Message}
+		}
+		if len(rule.FlowControlSchemaName) > 0 {
+			selectSchema(rule.FlowControlSchemaName, "expression-rule-"+rule.Name)
+		}
+		if len(rule.UpstreamSubset) > 0 {
+			subset = rule.UpstreamSubset
+		}
+	}
 
 	result := &endpointPickStrategy{
-		cluster:     c,
+		routing:     routing,
 		strategy:    policy.Strategy,
-		flowControl: c.getFlowSchema(policy.FlowControlSchemaName),
-		enableLog:   isLogEnabled(logging.Mode, policy.LogMode),
+		flowControl: routing.flowControl(schema, c.defaultFlowControl),
+		flowSchema:  flowSchema,
+		enableLog:   isLogEnabled(routing.logging.Mode, policy.LogMode),
+		write:       !requestAttributes.IsReadOnly(),
+	}
+	for _, name := range auditOnly {
+		result.auditOnly = append(result.auditOnly, routing.flowControl(name, c.defaultFlowControl))
 	}
 
-	if len(policy.UpstreamSubset) != 0 {
-		result.upstreams = policy.UpstreamSubset
+	if len(subset) != 0 {
+		result.upstreams = subset
 	} else {
-		result.upstreams = c.AllEndpoints()
+		result.upstreams = routing.servers
 	}
+	// reads go to readers and fall back to writers, writes never go to readers
+	result.upstreams, result.fallback = routing.readWriteSplit.route(c, result.upstreams, requestAttributes.IsReadOnly(), requestAttributes.GetUser())
+
+	// resource requests of aggregated API groups go directly to their extension servers
+	if requestAttributes.IsResourceRequest() {
+		if endpoints, ok := routing.apiGroups[requestAttributes.GetAPIGroup()]; ok && len(endpoints) > 0 {
+			result.fallback = result.upstreams
+			result.upstreams = endpoints
+		}
+	}
+	result.counter = routing.counterOf(result.upstreams)
 
 	return result, nil
 }
 
 func (c *ClusterInfo) PickOne() (*EndpointInfo, error) {
+	routing := c.loadRouting()
 	s := &endpointPickStrategy{
-		cluster:   c,
-		upstreams: c.AllEndpoints(),
+		routing:   routing,
+		upstreams: routing.servers,
+		counter:   routing.counterOf(routing.servers),
 	}
 	return s.Pop()
 }
 
-func (c *ClusterInfo) getFlowSchema(name string) gatewayflowcontrol.FlowControl {
-	if len(name) == 0 {
-		return c.defaultFlowControl
-	}
-	load, ok := c.flowcontrol.Load(name)
-	if !ok {
-		return c.defaultFlowControl
-	}
-	return load
-}
-
 func (c *ClusterInfo) addOrUpdateEndpoint(endpoint string, disabled bool) error {
 	info, ok := c.Endpoints.Load(endpoint)
 	if ok {
@@ -538,19 +759,30 @@ func (c *ClusterInfo) addOrUpdateEndpoint(endpoint string, disabled bool) error
 		return nil
 	}
 
-	http2configCopy := *c.restConfig
-	http2configCopy.WrapTransport = transport.NewDynamicImpersonatingRoundTripper
+	// pinned identity is looked up at every handshake, so it can be changed without recreating transports
+	verifyIdentity := func(state tls.ConnectionState) error {
+		return c.verifyEndpointIdentity(endpoint, state)
+	}
+
+	http2configCopy := *proxyConfigForAuthMode(c.restConfig, c.AuthMode())
 	http2configCopy.Host = endpoint
-	ts, err := rest.TransportFor(&http2configCopy)
+	serverName := c.endpointServerName(endpoint)
+	http2configCopy.TLSClientConfig.ServerName = serverName
+	ts, err := newEndpointTransport(c.Cluster, c.defaults, &http2configCopy, c.budgetedDial(http2configCopy.Dial), shortRequestTransportProfile(c.defaults.RequestTimeout), verifyIdentity)
 	if err != nil {
 		klog.Errorf("failed to create http2 transport for <cluster:%s,endpoint:%s>, err: %v", c.Cluster, endpoint, err)
 		return err
 	}
+	longRunningTS, err := newEndpointTransport(c.Cluster, c.defaults, &http2configCopy, c.budgetedDial(http2configCopy.Dial), longRunningTransportProfile, verifyIdentity)
+	if err != nil {
+		klog.Errorf("failed to create long running http2 transport for <cluster:%s,endpoint:%s>, err: %v", c.Cluster, endpoint, err)
+		return err
+	}
 
 	// since http2 doesn't support websocket, we need to disable http2 when using websocket
 	upgradeConfigCopy := http2configCopy
 	upgradeConfigCopy.NextProtos = []string{"http/1.1"}
-	ts2, err := rest.TransportFor(&upgradeConfigCopy)
+	ts2, err := newEndpointTransport(c.Cluster, c.defaults, &upgradeConfigCopy, streamObservedDial(c.budgetedDial(upgradeConfigCopy.Dial)), longRunningTransportProfile, verifyIdentity)
 	if err != nil {
 		klog.Errorf("failed to create http/1.1 transport for <cluster:%s,endpoint:%s>, err: %v", c.Cluster, endpoint, err)
 		return err
@@ -560,7 +792,28 @@ func (c *ClusterInfo) addOrUpdateEndpoint(endpoint string, disabled bool) error
 		klog.Errorf("failed to convert transport to proxy.UpgradeRequestRoundTripper for <cluster:%s,endpoint:%s>", c.Cluster, endpoint)
 	}
 
-	client, err := kubernetes.NewForConfig(&http2configCopy)
+	// health check client is not limited by resource budget and always authenticates with gateway
+	// credential whatever the auth mode is, it should always be able to detect endpoint status even
+	// if the cluster is saturated.
+	healthCheckConfig := *c.restConfig
+	healthCheckConfig.WrapTransport = transport.NewDynamicImpersonatingRoundTripper
+	healthCheckConfig.Host = endpoint
+	healthCheckConfig.TLSClientConfig.ServerName = serverName
+	// the gateway credential must never be sent before identity of endpoint is verified
+	healthCheckTS, err := newEndpointTransport(c.Cluster, c.defaults, &healthCheckConfig, healthCheckConfig.Dial, shortRequestTransportProfile(c.defaults.RequestTimeout), verifyIdentity)
+	if err != nil {
+		klog.Errorf("failed to create health check transport for <cluster:%s,endpoint:%s>, err: %v", c.Cluster, endpoint, err)
+		return err
+	}
+	client, err := kubernetes.NewForConfig(&rest.Config{
+		Host:        endpoint,
+		Transport:   healthCheckTS,
+		QPS:         healthCheckConfig.QPS,
+		Burst:       healthCheckConfig.Burst,
+		RateLimiter: healthCheckConfig.RateLimiter,
+		Timeout:     healthCheckConfig.Timeout,
+		UserAgent:   healthCheckConfig.UserAgent,
+	})
 	if err != nil {
 		klog.Errorf("failed to create clientset for <cluster:%s,endpoint:%s>, err: %v", c.Cluster, endpoint, err)
 		return err
@@ -572,6 +825,11 @@ func (c *ClusterInfo) addOrUpdateEndpoint(endpoint string, disabled bool) error
 		Healthy:  false,
 	}
 
+	// request outcomes of both short and long running requests are collected for endpoint scoring
+	stats := newEndpointStats()
+	ts = &statsRoundTripper{rt: ts, stats: stats}
+	longRunningTS = &statsRoundTripper{rt: longRunningTS, stats: stats, longRunning: true}
+
 	ctx, cancel := context.WithCancel(c.Context())
 	info = &EndpointInfo{
 		ctx:                   ctx,
@@ -581,10 +839,25 @@ func (c *ClusterInfo) addOrUpdateEndpoint(endpoint string, disabled bool) error
 		status:                initStatus,
 		proxyConfig:           &http2configCopy,
 		ProxyTransport:        ts,
+		LongRunningTransport:  longRunningTS,
 		proxyUpgradeConfig:    &upgradeConfigCopy,
 		PorxyUpgradeTransport: urrt,
 		clientset:             client,
+		healthCheckConfig:     &healthCheckConfig,
 		healthCheckFun:        c.endpointHeathCheck,
+		healthCheckScheduler:  c.defaults.HealthCheckScheduler,
+		prewarmConnections:    c.defaults.PrewarmConnections,
+		stats:                 stats,
+		featureEnabled:        c.FeatureEnabled,
+		verifyIdentity:        verifyIdentity,
+		sampleErrorLog:        c.SampleErrorLog,
+		onStatusChange:        c.observeAvailability,
+	}
+
+	if DefaultEndpointStateStore != nil {
+		if state, ok := DefaultEndpointStateStore.Lookup(c.Cluster, endpoint); ok {
+			info.restoreState(state)
+		}
 	}
 
 	klog.Infof("[cluster info] new endpoint added, cluster=%q, endpoint=%q", c.Cluster, info.Endpoint)
@@ -600,6 +873,7 @@ func (c *ClusterInfo) FeatureEnabled(key featuregate.Feature) bool {
 }
 
 func (c *ClusterInfo) syncFeatureGate(annotations map[string]string) error {
+	defer c.notifyCircuitBreaker(c.FeatureEnabled(features.DenyAllRequests))
 	featuregate := annotations[features.FeatureGateAnnotationKey]
 	if len(featuregate) == 0 {
 		if !features.IsDefault(c.featuregate) {
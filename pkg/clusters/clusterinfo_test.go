@@ -88,7 +88,7 @@ func newTestUpstreamClusterConfig() *proxyv1alpha1.UpstreamCluster {
 }
 
 func createTestClusterInfo() *ClusterInfo {
-	ret, _ := CreateClusterInfo(newTestUpstreamClusterConfig(), alwaysReadyHealthCheck)
+	ret, _ := CreateClusterInfo(newTestUpstreamClusterConfig(), alwaysReadyHealthCheck, nil)
 	return ret
 }
 
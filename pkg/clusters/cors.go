@@ -0,0 +1,373 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	// CORSPolicyAnnotationKey overrides the default CORS policy for one upstream cluster,
+	// the value is a json encoded CORSPolicySpec.
+	CORSPolicyAnnotationKey = "proxy.kubegateway.io/cors-policy"
+
+	// UpstreamCORSAnnotationKey controls how CORS headers sent from upstream are handled by path, the value is
+	// a comma separated list of pathPrefix=mode pairs, e.g. *=strip,/apis/metrics.example.io/=preserve.
+	// The longest matched prefix wins and * is for paths without any matched prefix, see UpstreamCORSMode.
+	UpstreamCORSAnnotationKey = "proxy.kubegateway.io/upstream-cors-headers"
+
+	upstreamCORSAnyPath = "*"
+)
+
+// UpstreamCORSMode is how CORS headers sent from upstream are handled
+type UpstreamCORSMode string
+
+const (
+	// UpstreamCORSStrip strips CORS headers sent from upstream, only headers of gateway policy are sent to clients
+	UpstreamCORSStrip UpstreamCORSMode = "strip"
+	// UpstreamCORSPreserve passes CORS headers sent from upstream to clients, gateway policy only fills
+	// in headers upstream does not send
+	UpstreamCORSPreserve UpstreamCORSMode = "preserve"
+	// UpstreamCORSMerge overrides CORS headers sent from upstream by gateway policy, except that list
+	// headers, e.g. Access-Control-Expose-Headers, are the union of both
+	UpstreamCORSMerge UpstreamCORSMode = "merge"
+)
+
+var (
+	// DefaultCORSPolicy is the CORS policy for every upstream cluster without CORSPolicyAnnotationKey
+	// annotation, nil means no gateway level policy. It is read by every cross-origin request without
+	// locking, CORSOptions sets it once before proxy server starts serving.
+	DefaultCORSPolicy *CORSPolicy
+
+	// the same defaults as generic apiserver CORS filter
+	defaultCORSAllowedMethods = []string{"POST", "GET", "OPTIONS", "PUT", "DELETE", "PATCH"}
+	defaultCORSAllowedHeaders = []string{"Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization", "X-Requested-With", "If-Modified-Since"}
+	defaultCORSExposedHeaders = []string{"Date"}
+)
+
+// CORSPolicySpec describes how gateway answers cross-origin requests from browsers, CORS headers
+// sent from upstream are always replaced by the headers generated from this policy.
+type CORSPolicySpec struct {
+	// AllowedOrigins is a list of regular expressions matching the allowed origins, it must not be empty.
+	// An allowed origin can be anchored by ^ and $, e.g. ^https://dashboard\.example\.com$
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// AllowedMethods defaults to POST, GET, OPTIONS, PUT, DELETE, PATCH
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+	// AllowedHeaders defaults to the headers allowed by generic apiserver
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// ExposedHeaders defaults to Date
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+	// MaxAgeSeconds is how long the results of a preflight request can be cached, zero means not set
+	MaxAgeSeconds int32 `json:"maxAgeSeconds,omitempty"`
+	// AllowCredentials indicates whether the response can be shared when request's credentials mode is include
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+}
+
+// CORSPolicy is a validated CORSPolicySpec
+type CORSPolicy struct {
+	spec    CORSPolicySpec
+	origins []*regexp.Regexp
+
+	allowedMethods string
+	allowedHeaders string
+	exposedHeaders string
+}
+
+// NewCORSPolicy validates spec and fills in defaults
+func NewCORSPolicy(spec CORSPolicySpec) (*CORSPolicy, error) {
+	if len(spec.AllowedOrigins) == 0 {
+		return nil, fmt.Errorf("allowedOrigins must not be empty")
+	}
+	if spec.MaxAgeSeconds < 0 {
+		return nil, fmt.Errorf("maxAgeSeconds must not be negative")
+	}
+	p := &CORSPolicy{}
+	for _, origin := range spec.AllowedOrigins {
+		re, err := regexp.Compile(origin)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed origin %q: %v", origin, err)
+		}
+		p.origins = append(p.origins, re)
+	}
+	if len(spec.AllowedMethods) == 0 {
+		spec.AllowedMethods = defaultCORSAllowedMethods
+	}
+	if len(spec.AllowedHeaders) == 0 {
+		spec.AllowedHeaders = defaultCORSAllowedHeaders
+	}
+	if len(spec.ExposedHeaders) == 0 {
+		spec.ExposedHeaders = defaultCORSExposedHeaders
+	}
+	p.spec = spec
+	p.allowedMethods = strings.Join(spec.AllowedMethods, ", ")
+	p.allowedHeaders = strings.Join(spec.AllowedHeaders, ", ")
+	p.exposedHeaders = strings.Join(spec.ExposedHeaders, ", ")
+	return p, nil
+}
+
+// ParseCORSPolicy parses policy from annotation value
+func ParseCORSPolicy(value string) (*CORSPolicy, error) {
+	spec := CORSPolicySpec{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid cors policy: %v", err)
+	}
+	return NewCORSPolicy(spec)
+}
+
+// Spec returns the defaulted spec of this policy
+func (p *CORSPolicy) Spec() CORSPolicySpec {
+	return p.spec
+}
+
+// AllowOrigin returns true if the origin matches one of the allowed origins
+func (p *CORSPolicy) AllowOrigin(origin string) bool {
+	for _, re := range p.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetResponseHeaders replaces CORS headers in header for an allowed origin,
+// preflight indicates whether it is the response of a preflight request.
+func (p *CORSPolicy) SetResponseHeaders(header http.Header, origin string, preflight bool) {
+	RemoveCORSHeaders(header)
+	p.setResponseHeaders(header, origin, preflight)
+}
+
+// ResponseHeaders returns the CORS headers of policy for an allowed origin, they are merged with
+// headers sent from upstream by MergeCORSHeaders.
+func (p *CORSPolicy) ResponseHeaders(origin string, preflight bool) http.Header {
+	header := http.Header{}
+	p.setResponseHeaders(header, origin, preflight)
+	return header
+}
+
+func (p *CORSPolicy) setResponseHeaders(header http.Header, origin string, preflight bool) {
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+	if p.spec.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if preflight {
+		header.Set("Access-Control-Allow-Methods", p.allowedMethods)
+		header.Set("Access-Control-Allow-Headers", p.allowedHeaders)
+		if p.spec.MaxAgeSeconds > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(p.spec.MaxAgeSeconds)))
+		}
+		return
+	}
+	header.Set("Access-Control-Expose-Headers", p.exposedHeaders)
+}
+
+// RemoveCORSHeaders strips all CORS response headers
+func RemoveCORSHeaders(header http.Header) {
+	header.Del("Access-Control-Allow-Credentials")
+	header.Del("Access-Control-Allow-Headers")
+	header.Del("Access-Control-Allow-Methods")
+	header.Del("Access-Control-Allow-Origin")
+	header.Del("Access-Control-Expose-Headers")
+	header.Del("Access-Control-Max-Age")
+}
+
+// corsListHeaders are CORS headers whose values are comma separated lists
+var corsListHeaders = map[string]bool{
+	"Access-Control-Allow-Headers":  true,
+	"Access-Control-Allow-Methods":  true,
+	"Access-Control-Expose-Headers": true,
+}
+
+// MergeCORSHeaders merges CORS headers of gateway policy into header, which contains headers sent from upstream
+func MergeCORSHeaders(header, gateway http.Header, mode UpstreamCORSMode) {
+	for key, values := range gateway {
+		switch {
+		case key == "Vary":
+			if !headerContainsToken(header, key, "Origin") {
+				header.Add(key, "Origin")
+			}
+		case len(header[key]) == 0:
+			header[key] = values
+		case mode == UpstreamCORSPreserve:
+			// upstream wins
+		case corsListHeaders[key]:
+			header.Set(key, unionHeaderTokens(header[key], values))
+		default:
+			header[key] = values
+		}
+	}
+}
+
+func headerContainsToken(header http.Header, key, token string) bool {
+	for _, value := range header[key] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unionHeaderTokens(lists ...[]string) string {
+	seen := map[string]bool{}
+	tokens := []string{}
+	for _, values := range lists {
+		for _, value := range values {
+			for _, t := range strings.Split(value, ",") {
+				t = strings.TrimSpace(t)
+				if len(t) == 0 || seen[strings.ToLower(t)] {
+					continue
+				}
+				seen[strings.ToLower(t)] = true
+				tokens = append(tokens, t)
+			}
+		}
+	}
+	return strings.Join(tokens, ", ")
+}
+
+// UpstreamCORSRules are upstream CORS modes by path prefix
+type UpstreamCORSRules struct {
+	// Default is the mode of paths without any matched prefix
+	Default UpstreamCORSMode
+	// prefixes sorted by length in descending order
+	prefixes []upstreamCORSRule
+}
+
+type upstreamCORSRule struct {
+	prefix string
+	mode   UpstreamCORSMode
+}
+
+// ParseUpstreamCORSRules parses upstream CORS rules from annotation value, paths default to strip
+func ParseUpstreamCORSRules(value string) (*UpstreamCORSRules, error) {
+	rules := &UpstreamCORSRules{Default: UpstreamCORSStrip}
+	seen := map[string]bool{}
+	for _, s := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing mode for upstream cors headers %q", s)
+		}
+		prefix, mode := strings.TrimSpace(kv[0]), UpstreamCORSMode(strings.TrimSpace(kv[1]))
+		switch mode {
+		case UpstreamCORSStrip, UpstreamCORSPreserve, UpstreamCORSMerge:
+		default:
+			return nil, fmt.Errorf("invalid mode of %s=%s, must be one of %s, %s and %s", prefix, mode, UpstreamCORSStrip, UpstreamCORSPreserve, UpstreamCORSMerge)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("duplicate path prefix %q", prefix)
+		}
+		seen[prefix] = true
+		if prefix == upstreamCORSAnyPath {
+			rules.Default = mode
+			continue
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix %q must start with /", prefix)
+		}
+		rules.prefixes = append(rules.prefixes, upstreamCORSRule{prefix: prefix, mode: mode})
+	}
+	sort.SliceStable(rules.prefixes, func(i, j int) bool {
+		return len(rules.prefixes[i].prefix) > len(rules.prefixes[j].prefix)
+	})
+	return rules, nil
+}
+
+func (r *UpstreamCORSRules) String() string {
+	if r == nil {
+		return ""
+	}
+	pairs := []string{fmt.Sprintf("%s=%s", upstreamCORSAnyPath, r.Default)}
+	for _, rule := range r.prefixes {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", rule.prefix, rule.mode))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ModeFor returns the upstream CORS mode of path
+func (r *UpstreamCORSRules) ModeFor(path string) UpstreamCORSMode {
+	if r == nil {
+		return UpstreamCORSStrip
+	}
+	for _, rule := range r.prefixes {
+		if strings.HasPrefix(path, rule.prefix) {
+			return rule.mode
+		}
+	}
+	return r.Default
+}
+
+// UpstreamCORSMode returns how CORS headers sent from upstream are handled for requests to path
+func (c *ClusterInfo) UpstreamCORSMode(path string) UpstreamCORSMode {
+	rules, _ := c.currentUpstreamCORSRules.Load().(*UpstreamCORSRules)
+	return rules.ModeFor(path)
+}
+
+func (c *ClusterInfo) syncUpstreamCORSRules(annotations map[string]string) error {
+	var rules *UpstreamCORSRules
+	if value := annotations[UpstreamCORSAnnotationKey]; len(value) > 0 {
+		var err error
+		rules, err = ParseUpstreamCORSRules(value)
+		if err != nil {
+			return err
+		}
+	}
+	if old, _ := c.currentUpstreamCORSRules.Load().(*UpstreamCORSRules); old.String() != rules.String() {
+		klog.Infof("[cluster info] cluster=%q update upstream cors headers, rules=%q", c.Cluster, rules.String())
+	}
+	c.currentUpstreamCORSRules.Store(rules)
+	return nil
+}
+
+// CORSPolicy returns the CORS policy of this cluster, nil means gateway does not answer
+// cross-origin requests for it.
+func (c *ClusterInfo) CORSPolicy() *CORSPolicy {
+	if p, ok := c.currentCORSPolicy.Load().(*CORSPolicy); ok && p != nil {
+		return p
+	}
+	return DefaultCORSPolicy
+}
+
+func (c *ClusterInfo) syncCORSPolicy(annotations map[string]string) error {
+	var policy *CORSPolicy
+	if value := annotations[CORSPolicyAnnotationKey]; len(value) > 0 {
+		var err error
+		policy, err = ParseCORSPolicy(value)
+		if err != nil {
+			return err
+		}
+	}
+	old, _ := c.currentCORSPolicy.Load().(*CORSPolicy)
+	if (old == nil) != (policy == nil) {
+		klog.Infof("[cluster info] cluster=%q update cors policy, override=%v", c.Cluster, policy != nil)
+	}
+	c.currentCORSPolicy.Store(policy)
+	return nil
+}
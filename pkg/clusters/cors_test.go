@@ -0,0 +1,196 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_ParseCORSPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `{"allowedOrigins":["^https://dashboard\\.example\\.com$"],"maxAgeSeconds":600}`, false},
+		{"empty origins", `{"allowedOrigins":[]}`, true},
+		{"invalid origin", `{"allowedOrigins":["("]}`, true},
+		{"negative max age", `{"allowedOrigins":[".*"],"maxAgeSeconds":-1}`, true},
+		{"unknown field", `{"allowedOrigins":[".*"],"allowOrigins":[".*"]}`, true},
+		{"invalid json", `allowedOrigins`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCORSPolicy(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("ParseCORSPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_CORSPolicy_SetResponseHeaders(t *testing.T) {
+	policy, err := NewCORSPolicy(CORSPolicySpec{
+		AllowedOrigins:   []string{`^https://dashboard\.example\.com$`},
+		AllowedMethods:   []string{"GET"},
+		MaxAgeSeconds:    600,
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatalf("NewCORSPolicy() error = %v", err)
+	}
+	if policy.AllowOrigin("https://evil.example.com") {
+		t.Errorf("AllowOrigin() unexpected allowed origin")
+	}
+	origin := "https://dashboard.example.com"
+	if !policy.AllowOrigin(origin) {
+		t.Fatalf("AllowOrigin() origin %v should be allowed", origin)
+	}
+
+	header := http.Header{}
+	header.Set("Access-Control-Allow-Origin", "*")
+	policy.SetResponseHeaders(header, origin, true)
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      origin,
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range want {
+		if got := header.Get(k); got != v {
+			t.Errorf("SetResponseHeaders() %v = %v, want %v", k, got, v)
+		}
+	}
+	if _, ok := header["Access-Control-Expose-Headers"]; ok {
+		t.Errorf("SetResponseHeaders() preflight response should not expose headers")
+	}
+
+	header = http.Header{}
+	policy.SetResponseHeaders(header, origin, false)
+	if got := header.Get("Access-Control-Expose-Headers"); got != "Date" {
+		t.Errorf("SetResponseHeaders() Access-Control-Expose-Headers = %v, want Date", got)
+	}
+	if _, ok := header["Access-Control-Max-Age"]; ok {
+		t.Errorf("SetResponseHeaders() actual response should not set max age")
+	}
+}
+
+func Test_ParseUpstreamCORSRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]UpstreamCORSMode
+		wantErr bool
+	}{
+		{
+			"default and prefixes",
+			"*=merge,/apis=preserve,/apis/apps=strip",
+			map[string]UpstreamCORSMode{
+				"/api/v1/pods":                     UpstreamCORSMerge,
+				"/apis/batch/v1/jobs":              UpstreamCORSPreserve,
+				"/apis/apps/v1/deployments":        UpstreamCORSStrip,
+				"/apis/apps.example.com/v1/things": UpstreamCORSStrip,
+			},
+			false,
+		},
+		{
+			"default to strip",
+			"/api/v1/namespaces/kube-system/services=preserve",
+			map[string]UpstreamCORSMode{
+				"/api/v1/namespaces/kube-system/services/dashboard/proxy/": UpstreamCORSPreserve,
+				"/api/v1/pods": UpstreamCORSStrip,
+			},
+			false,
+		},
+		{"invalid mode", "*=keep", nil, true},
+		{"missing mode", "/api", nil, true},
+		{"duplicate prefix", "/api=merge,/api=strip", nil, true},
+		{"relative prefix", "api=merge", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := ParseUpstreamCORSRules(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseUpstreamCORSRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for path, want := range tt.want {
+				if got := rules.ModeFor(path); got != want {
+					t.Errorf("ModeFor(%v) = %v, want %v", path, got, want)
+				}
+			}
+		})
+	}
+
+	var rules *UpstreamCORSRules
+	if got := rules.ModeFor("/api"); got != UpstreamCORSStrip {
+		t.Errorf("nil rules ModeFor() = %v, want %v", got, UpstreamCORSStrip)
+	}
+}
+
+func Test_MergeCORSHeaders(t *testing.T) {
+	policy, err := NewCORSPolicy(CORSPolicySpec{
+		AllowedOrigins:   []string{`^https://dashboard\.example\.com$`},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatalf("NewCORSPolicy() error = %v", err)
+	}
+	origin := "https://dashboard.example.com"
+
+	upstream := func() http.Header {
+		header := http.Header{}
+		header.Set("Access-Control-Allow-Origin", "*")
+		header.Set("Access-Control-Expose-Headers", "Warning, X-Request-Id")
+		header.Set("Vary", "Accept-Encoding")
+		return header
+	}
+
+	tests := []struct {
+		mode UpstreamCORSMode
+		want map[string]string
+	}{
+		{
+			UpstreamCORSPreserve,
+			map[string]string{
+				"Access-Control-Allow-Origin":      "*",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Warning, X-Request-Id",
+			},
+		},
+		{
+			UpstreamCORSMerge,
+			map[string]string{
+				"Access-Control-Allow-Origin":      origin,
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "Warning, X-Request-Id",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			header := upstream()
+			MergeCORSHeaders(header, policy.ResponseHeaders(origin, false), tt.mode)
+			for k, v := range tt.want {
+				if got := header.Get(k); got != v {
+					t.Errorf("MergeCORSHeaders() %v = %v, want %v", k, got, v)
+				}
+			}
+			if !headerContainsToken(header, "Vary", "Origin") || !headerContainsToken(header, "Vary", "Accept-Encoding") {
+				t.Errorf("MergeCORSHeaders() Vary = %v, want Origin appended", header["Vary"])
+			}
+		})
+	}
+}
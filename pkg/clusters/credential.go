@@ -0,0 +1,145 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/yaml"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+)
+
+// UpstreamCredentialAnnotationKey names an entry of the gateway-side credential allowlist, see
+// --proxy-upstream-credentials-file, which is used to authenticate to upstream cluster instead of
+// spec.clientConfig.bearerToken.
+const UpstreamCredentialAnnotationKey = "proxy.kubegateway.io/upstream-credential"
+
+var supportedExecCredentialVersions = []string{
+	"client.authentication.k8s.io/v1alpha1",
+	"client.authentication.k8s.io/v1beta1",
+}
+
+// UpstreamCredential is a token file or a client-go exec credential plugin on gateway host.
+type UpstreamCredential struct {
+	// TokenFile is a bearer token file, it is reloaded periodically.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// Exec is a client-go exec credential plugin, e.g.
+	// {"apiVersion":"client.authentication.k8s.io/v1beta1","command":"aws","args":["eks","get-token","--cluster-name","foo"]}
+	// the credential is refreshed automatically after expired or rejected by upstream cluster.
+	Exec *clientcmdapi.ExecConfig `json:"exec,omitempty"`
+}
+
+// UpstreamCredentials maps names which clusters can reference to credentials, it is the allowlist
+// loaded from --proxy-upstream-credentials-file.
+type UpstreamCredentials map[string]*UpstreamCredential
+
+// LoadUpstreamCredentials loads and validates credentials from a yaml or json file, e.g.
+//
+//	eks-foo:
+//	  exec: {"apiVersion":"client.authentication.k8s.io/v1beta1","command":"aws","args":["eks","get-token","--cluster-name","foo"]}
+//	vault:
+//	  tokenFile: /var/run/secrets/vault/token
+func LoadUpstreamCredentials(file string) (UpstreamCredentials, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	credentials := UpstreamCredentials{}
+	if err := yaml.UnmarshalStrict(data, &credentials); err != nil {
+		return nil, fmt.Errorf("invalid upstream credentials file %q, err: %v", file, err)
+	}
+	for name, c := range credentials {
+		if err := c.validate(); err != nil {
+			return nil, fmt.Errorf("invalid upstream credential %q, err: %v", name, err)
+		}
+	}
+	return credentials, nil
+}
+
+// Names returns sorted names of credentials
+func (c UpstreamCredentials) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *UpstreamCredential) validate() error {
+	if c == nil || (len(c.TokenFile) == 0) == (c.Exec == nil) {
+		return fmt.Errorf("exactly one of tokenFile and exec must be specified")
+	}
+	if len(c.TokenFile) > 0 && !filepath.IsAbs(c.TokenFile) {
+		return fmt.Errorf("token file %q must be an absolute path", c.TokenFile)
+	}
+	if c.Exec == nil {
+		return nil
+	}
+	if len(c.Exec.Command) == 0 {
+		return fmt.Errorf("command of exec credential must be specified")
+	}
+	for _, v := range supportedExecCredentialVersions {
+		if c.Exec.APIVersion == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported apiVersion %q of exec credential, must be one of %v", c.Exec.APIVersion, supportedExecCredentialVersions)
+}
+
+// ValidateUpstreamCredential validates that the credential annotation names one of allowed credentials
+func ValidateUpstreamCredential(annotations map[string]string, allowed UpstreamCredentials) error {
+	_, err := upstreamCredentialOf(annotations, allowed)
+	return err
+}
+
+func upstreamCredentialOf(annotations map[string]string, allowed UpstreamCredentials) (*UpstreamCredential, error) {
+	name, ok := annotations[UpstreamCredentialAnnotationKey]
+	if !ok {
+		return nil, nil
+	}
+	// credential plugins read files and run commands on gateway host
+	if !gatewayfeatures.Enabled(gatewayfeatures.UpstreamCredentialPlugins) {
+		return nil, fmt.Errorf("upstream credential annotation requires feature gate %s=true", gatewayfeatures.UpstreamCredentialPlugins)
+	}
+	credential, ok := allowed[name]
+	if !ok {
+		return nil, fmt.Errorf("upstream credential %q is not allowed by gateway, must be one of %v", name, allowed.Names())
+	}
+	return credential, nil
+}
+
+// applyUpstreamCredential replaces the static bearer token in config with the allowed credential
+// named in annotations.
+func applyUpstreamCredential(config *rest.Config, annotations map[string]string, allowed UpstreamCredentials) error {
+	credential, err := upstreamCredentialOf(annotations, allowed)
+	if err != nil || credential == nil {
+		return err
+	}
+	config.BearerToken = ""
+	if len(credential.TokenFile) > 0 {
+		config.BearerTokenFile = credential.TokenFile
+	}
+	if credential.Exec != nil {
+		config.ExecProvider = credential.Exec.DeepCopy()
+	}
+	return nil
+}
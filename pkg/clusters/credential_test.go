@@ -0,0 +1,125 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+)
+
+const testUpstreamCredentials = `
+eks-foo:
+  exec: {"apiVersion":"client.authentication.k8s.io/v1beta1","command":"aws","args":["eks","get-token","--cluster-name","foo"]}
+vault:
+  tokenFile: /var/run/token
+`
+
+func TestLoadUpstreamCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upstream-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"valid", testUpstreamCredentials, false},
+		{"relative token file", "vault:\n  tokenFile: token\n", true},
+		{"exec credential without command", `eks: {"exec": {"apiVersion":"client.authentication.k8s.io/v1beta1"}}`, true},
+		{"unsupported exec credential version", `eks: {"exec": {"apiVersion":"v1","command":"aws"}}`, true},
+		{"mutually exclusive", `eks: {"tokenFile": "/var/run/token", "exec": {"apiVersion":"client.authentication.k8s.io/v1beta1","command":"aws"}}`, true},
+		{"empty", "eks: {}\n", true},
+		{"unknown field", "vault:\n  token: /var/run/token\n", true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name)
+			if err := ioutil.WriteFile(file, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadUpstreamCredentials(file)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadUpstreamCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if i == 0 && err == nil && (len(got) != 2 || got["vault"].TokenFile != "/var/run/token" || got["eks-foo"].Exec.Command != "aws") {
+				t.Errorf("LoadUpstreamCredentials() got unexpected credentials %v", got)
+			}
+		})
+	}
+}
+
+var allowedUpstreamCredentials = UpstreamCredentials{
+	"vault": {TokenFile: "/var/run/token"},
+	"eks-foo": {Exec: &clientcmdapi.ExecConfig{
+		APIVersion: "client.authentication.k8s.io/v1beta1",
+		Command:    "aws",
+		Args:       []string{"eks", "get-token", "--cluster-name", "foo"},
+	}},
+}
+
+func TestValidateUpstreamCredential(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     bool
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{"no credential", false, nil, false},
+		{"feature disabled", false, map[string]string{UpstreamCredentialAnnotationKey: "vault"}, true},
+		{"token file", true, map[string]string{UpstreamCredentialAnnotationKey: "vault"}, false},
+		{"exec credential", true, map[string]string{UpstreamCredentialAnnotationKey: "eks-foo"}, false},
+		{"not allowed", true, map[string]string{UpstreamCredentialAnnotationKey: "/etc/shadow"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, gatewayfeatures.UpstreamCredentialPlugins, tt.enabled)()
+			if err := ValidateUpstreamCredential(tt.annotations, allowedUpstreamCredentials); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpstreamCredential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_applyUpstreamCredential(t *testing.T) {
+	defer featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, gatewayfeatures.UpstreamCredentialPlugins, true)()
+
+	config := &rest.Config{BearerToken: "static"}
+	if err := applyUpstreamCredential(config, map[string]string{UpstreamCredentialAnnotationKey: "eks-foo"}, allowedUpstreamCredentials); err != nil {
+		t.Fatalf("applyUpstreamCredential() unexpected error = %v", err)
+	}
+	if len(config.BearerToken) != 0 || config.ExecProvider == nil || config.ExecProvider.Command != "aws" || len(config.ExecProvider.Args) != 4 {
+		t.Errorf("applyUpstreamCredential() got unexpected config %+v", config)
+	}
+
+	config = &rest.Config{BearerToken: "static"}
+	if err := applyUpstreamCredential(config, map[string]string{UpstreamCredentialAnnotationKey: "vault"}, allowedUpstreamCredentials); err != nil {
+		t.Fatalf("applyUpstreamCredential() unexpected error = %v", err)
+	}
+	if len(config.BearerToken) != 0 || config.BearerTokenFile != "/var/run/token" {
+		t.Errorf("applyUpstreamCredential() got unexpected config %+v", config)
+	}
+}
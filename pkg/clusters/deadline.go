@@ -0,0 +1,65 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"net/http"
+	"time"
+)
+
+// DeadlineHeader carries the time in RFC3339 after which gateway no longer waits for the response,
+// so upstream and other hops in between can stop working on the request.
+const DeadlineHeader = "X-Request-Deadline"
+
+// DeadlinePropagation tells upstream the remaining deadline of non-long-running requests, computed
+// from the response header timeout of their attempt and the deadline of client request if any, so
+// upstream apiservers do not keep working on requests gateway has already given up.
+type DeadlinePropagation struct {
+	Enabled bool
+	// Margin is subtracted from the remaining time, so upstream times out and responds a Status
+	// before gateway cancels the request
+	Margin time.Duration
+}
+
+// apply returns a copy of req with DeadlineHeader and the timeout query parameter, which kube-apiserver
+// honors for non-long-running requests, shortened to the time left before timeout from now.
+// The timeout parameter of client is kept if it is already shorter.
+func (p DeadlinePropagation) apply(req *http.Request, now time.Time, timeout time.Duration) *http.Request {
+	if !p.Enabled {
+		return req
+	}
+	deadline := now.Add(timeout)
+	if d, ok := req.Context().Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if remaining := deadline.Sub(now); remaining > p.Margin {
+		deadline = deadline.Add(-p.Margin)
+	}
+	remaining := deadline.Sub(now).Truncate(time.Millisecond)
+	if remaining <= 0 {
+		return req
+	}
+
+	// the request may be sent again by retries, never modify it in place
+	newReq := req.Clone(req.Context())
+	newReq.Header.Set(DeadlineHeader, deadline.UTC().Format(time.RFC3339Nano))
+	query := newReq.URL.Query()
+	if current, err := time.ParseDuration(query.Get("timeout")); err == nil && current > 0 && current <= remaining {
+		return newReq
+	}
+	query.Set("timeout", remaining.String())
+	newReq.URL.RawQuery = query.Encode()
+	return newReq
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeadlinePropagation_apply(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		p           DeadlinePropagation
+		url         string
+		ctxDeadline time.Duration
+		timeout     time.Duration
+		wantTimeout string
+		wantHeader  string
+	}{
+		{
+			name:    "disabled",
+			p:       DeadlinePropagation{},
+			url:     "https://a:6443/api/v1/pods",
+			timeout: time.Minute,
+		},
+		{
+			name:        "response header timeout",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods",
+			timeout:     time.Minute,
+			wantTimeout: "59s",
+			wantHeader:  "2022-01-01T00:00:59Z",
+		},
+		{
+			name:        "earlier client deadline",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods?limit=500",
+			ctxDeadline: 10 * time.Second,
+			timeout:     time.Minute,
+			wantTimeout: "9s",
+			wantHeader:  "2022-01-01T00:00:09Z",
+		},
+		{
+			name:        "shorter client timeout is kept",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods?timeout=5s",
+			timeout:     time.Minute,
+			wantTimeout: "5s",
+			wantHeader:  "2022-01-01T00:00:59Z",
+		},
+		{
+			name:        "longer client timeout is shortened",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods?timeout=5m",
+			timeout:     time.Minute,
+			wantTimeout: "59s",
+			wantHeader:  "2022-01-01T00:00:59Z",
+		},
+		{
+			name:        "margin larger than remaining",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods",
+			timeout:     500 * time.Millisecond,
+			wantTimeout: "500ms",
+			wantHeader:  "2022-01-01T00:00:00.5Z",
+		},
+		{
+			name:        "client deadline exceeded",
+			p:           DeadlinePropagation{Enabled: true, Margin: time.Second},
+			url:         "https://a:6443/api/v1/pods",
+			ctxDeadline: -time.Second,
+			timeout:     time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.ctxDeadline != 0 {
+				ctx, cancel := context.WithDeadline(req.Context(), now.Add(tt.ctxDeadline))
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+			rawQuery := req.URL.RawQuery
+
+			got := tt.p.apply(req, now, tt.timeout)
+			if gotTimeout := got.URL.Query().Get("timeout"); gotTimeout != tt.wantTimeout {
+				t.Errorf("apply() timeout = %q, want %q", gotTimeout, tt.wantTimeout)
+			}
+			if gotHeader := got.Header.Get(DeadlineHeader); gotHeader != tt.wantHeader {
+				t.Errorf("apply() %s = %q, want %q", DeadlineHeader, gotHeader, tt.wantHeader)
+			}
+			if req.URL.RawQuery != rawQuery || len(req.Header.Get(DeadlineHeader)) > 0 {
+				t.Errorf("apply() modified the original request")
+			}
+		})
+	}
+}
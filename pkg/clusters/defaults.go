@@ -0,0 +1,90 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/expression"
+)
+
+// ClusterDefaults are the settings of all upstream clusters created by one gateway, some of them can be
+// overridden by annotations of each cluster. Every ClusterInfo keeps the defaults it is created with and
+// reads them without locking, so they must be filled before the first cluster is created and never
+// changed afterwards.
+type ClusterDefaults struct {
+	// ResourceBudget is the resource budget of clusters without ResourceBudgetAnnotationKey annotation
+	ResourceBudget ResourceBudget
+	// WatchLimitOverrides overrides maxWatchesPerUser budget of all clusters for specific users, e.g.
+	// controllers which legitimately watch many resources
+	WatchLimitOverrides map[string]int32
+
+	// Ceilings are the ceilings of clusters without CeilingsAnnotationKey annotation
+	Ceilings ClusterCeilings
+	// EventRecorder emits events on UpstreamClusters, nil means no event is emitted
+	EventRecorder record.EventRecorder
+
+	// ExpressionCostLimit is the maximum cost of evaluating an expression rule once
+	ExpressionCostLimit int64
+
+	// HealthCheckScheduler schedules health checks of all endpoints, sharing it bounds the QPS of
+	// health checks of the whole gateway
+	HealthCheckScheduler *HealthCheckScheduler
+	// PrewarmConnections is the number of connections established in advance after an endpoint
+	// becomes healthy, zero means disabled
+	PrewarmConnections int
+
+	// RequestTimeout is the request timeout of upstream apiservers, i.e. --request-timeout, the response
+	// header timeout of non-long-running requests is derived from it
+	RequestTimeout time.Duration
+	// ResponseHeaderTimeout bounds the time to wait for upstream response headers of all requests,
+	// including long-running requests. Zero means only the timeout derived from RequestTimeout is used.
+	ResponseHeaderTimeout time.Duration
+	// DeadlinePropagation tells upstream the deadline of non-long-running requests
+	DeadlinePropagation DeadlinePropagation
+	// ResponseHeaderPolicy limits response headers and trailers of all endpoints
+	ResponseHeaderPolicy ResponseHeaderPolicy
+
+	// RedirectPolicy is the redirect policy of clusters without RedirectPolicyAnnotationKey annotation
+	RedirectPolicy RedirectPolicy
+	// AuthMode is the auth mode of clusters without AuthModeAnnotationKey annotation
+	AuthMode AuthMode
+	// UpstreamCredentials are the credentials clusters can name by UpstreamCredentialAnnotationKey
+	// annotation, so authors of UpstreamCluster never choose which files are read or which commands
+	// are run on gateway hosts
+	UpstreamCredentials UpstreamCredentials
+}
+
+// NewClusterDefaults returns the defaults used if no option of gateway is set
+func NewClusterDefaults() *ClusterDefaults {
+	return &ClusterDefaults{
+		WatchLimitOverrides:  map[string]int32{},
+		ExpressionCostLimit:  expression.DefaultCostLimit,
+		HealthCheckScheduler: NewHealthCheckScheduler(0, 0, 0, 0),
+		RequestTimeout:       60 * time.Second,
+		RedirectPolicy:       RedirectPolicyPassThrough,
+		AuthMode:             AuthModeImpersonate,
+	}
+}
+
+// orNewClusterDefaults returns d, or the defaults used if no option is set if d is nil
+func orNewClusterDefaults(d *ClusterDefaults) *ClusterDefaults {
+	if d == nil {
+		return NewClusterDefaults()
+	}
+	return d
+}
@@ -16,14 +16,17 @@ package clusters
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/component-base/featuregate"
 	"k8s.io/klog"
 
 	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
@@ -34,13 +37,21 @@ type endpointStatus struct {
 	Reason   string
 	Message  string
 	Disabled bool
-	mux      sync.RWMutex
+	// Cordoned is set at runtime when the machine of endpoint is under maintenance, unlike Disabled
+	// it is not overwritten by syncing UpstreamCluster spec
+	Cordoned     bool
+	CordonReason string
+	mux          sync.RWMutex
+
+	// seq is the sequence number of the last transition, see transition.go
+	seq                  uint64
+	lastHealthTransition time.Time
 }
 
 func (s *endpointStatus) IsReady() bool {
 	s.mux.RLock()
 	defer s.mux.RUnlock()
-	return !s.Disabled && s.Healthy
+	return !s.Disabled && !s.Cordoned && s.Healthy
 }
 
 func (s *endpointStatus) SetDisabled(disabled bool) {
@@ -66,18 +77,46 @@ type EndpointInfo struct {
 
 	proxyConfig        *rest.Config
 	proxyUpgradeConfig *rest.Config
-	// http2 proxy round tripper
+	// http2 proxy round tripper for short requests
 	ProxyTransport http.RoundTripper
+	// http2 proxy round tripper for long running requests, e.g. watch
+	LongRunningTransport http.RoundTripper
 	// http1 proxy round tripper for websocket
 	PorxyUpgradeTransport proxy.UpgradeRequestRoundTripper
 
 	clientset kubernetes.Interface
+	// healthCheckConfig authenticates with gateway credential whatever the auth mode is
+	healthCheckConfig *rest.Config
 
 	status endpointStatus
+	// last blackbox probe result
+	probeResult atomic.Value
+	// request outcomes for endpoint scoring
+	stats *endpointStats
+	// remaining successful health checks before an endpoint restored as unhealthy is ready
+	recoveryChecks int32
+	// 1 if etcd checks failed but the endpoint still serves reads, see EtcdAwareReadiness
+	etcdUnhealthy int32
+	// featureEnabled reports feature gates of the cluster
+	featureEnabled func(featuregate.Feature) bool
+	// verifyIdentity verifies pinned identity of the endpoint at TLS handshakes
+	verifyIdentity func(tls.ConnectionState) error
+	// sampleErrorLog samples error logs of the cluster by error class
+	sampleErrorLog func(class string) (bool, int64)
+	// onStatusChange is called after readiness of the endpoint may be changed
+	onStatusChange func()
+	// 1 if the endpoint is notified to be unhealthy and not yet recovered
+	notifiedUnhealthy int32
+	// transitionLock serializes status transitions, so their records are ordered
+	transitionLock sync.Mutex
 
 	healthCheckFun    EndpointHealthCheck
 	healthCheckCh     chan struct{}
 	cancelHealthCheck context.CancelFunc
+	// healthCheckScheduler is shared by all endpoints of the gateway, nil means health checks are not throttled
+	healthCheckScheduler *HealthCheckScheduler
+	// prewarmConnections is the number of connections established after the endpoint becomes healthy
+	prewarmConnections int
 	sync.Mutex
 }
 
@@ -85,14 +124,23 @@ func (e *EndpointInfo) Context() context.Context {
 	return e.ctx
 }
 
+// ProxyTransportFor returns the http2 proxy round tripper for short or long running requests
+func (e *EndpointInfo) ProxyTransportFor(longRunning bool) http.RoundTripper {
+	if longRunning && e.LongRunningTransport != nil {
+		return e.LongRunningTransport
+	}
+	return e.ProxyTransport
+}
+
 func (e *EndpointInfo) Clientset() kubernetes.Interface {
 	return e.clientset
 }
 
 func (e *EndpointInfo) SetDisabled(disabled bool) {
-	if e.status.Disabled != disabled {
-		e.status.Disabled = disabled
-		e.recordStatusChange()
+	e.transitionLock.Lock()
+	defer e.transitionLock.Unlock()
+	if t, changed := e.status.transitDisabled(disabled); changed {
+		e.recordTransition(t)
 	}
 }
 
@@ -100,15 +148,56 @@ func (e *EndpointInfo) IstDisabled() bool {
 	return e.status.Disabled
 }
 
+// SetCordoned takes the endpoint out of routing without touching its health check, e.g. when its
+// machine is under maintenance, reason is shown in unready messages.
+func (e *EndpointInfo) SetCordoned(cordoned bool, reason string) {
+	if !cordoned {
+		reason = ""
+	}
+	e.transitionLock.Lock()
+	defer e.transitionLock.Unlock()
+	if t, changed := e.status.transitCordoned(cordoned, reason); changed {
+		metrics.RecordUpstreamCordoned(e.Cluster, e.Endpoint, cordoned)
+		e.recordTransition(t)
+	}
+}
+
+func (e *EndpointInfo) IsCordoned() bool {
+	e.status.mux.RLock()
+	defer e.status.mux.RUnlock()
+	return e.status.Cordoned
+}
+
 func (e *EndpointInfo) UpdateStatus(healthy bool, reason, message string) {
 	if !healthy {
 		metrics.RecordUnhealthyUpstream(e.Cluster, e.Endpoint, reason)
+		e.resetRecovery()
+	} else {
+		if e.stats != nil {
+			e.stats.markHealthy(time.Now())
+		}
+		if e.recovering() {
+			reason, message = endpointReasonRecovering, "waiting for more successful health checks after restart"
+			healthy = false
+		}
+	}
+	e.transitionLock.Lock()
+	defer e.transitionLock.Unlock()
+	if t, changed := e.status.transitHealth(healthy, reason, message, time.Now()); changed {
+		e.recordTransition(t)
+		if healthy && e.prewarmConnections > 0 && !e.IstDisabled() {
+			go e.prewarm(e.prewarmConnections)
+		}
 	}
-	if e.status.Healthy != healthy {
-		// healthy changed
-		e.status.SetStatus(healthy, reason, message)
-		e.recordStatusChange()
+}
+
+// SampleErrorLog reports whether an error log of class should be written, and how many logs
+// of the class were suppressed since the last written one, see ClusterInfo.SampleErrorLog
+func (e *EndpointInfo) SampleErrorLog(class string) (bool, int64) {
+	if e.sampleErrorLog == nil {
+		return true, 0
 	}
+	return e.sampleErrorLog(class)
 }
 
 func (e *EndpointInfo) TriggerHealthCheck() {
@@ -150,45 +239,18 @@ func startGatewayHealthCheck(e *EndpointInfo, interval time.Duration, ctx contex
 	if e.healthCheckCh == nil {
 		e.healthCheckCh = make(chan struct{}, 1)
 	}
-
-	go func() {
-		tick := time.NewTicker(interval)
-		defer tick.Stop()
-
-		// trigger health check immediately
-		e.healthCheckCh <- struct{}{}
-		for {
-			select {
-			case <-tick.C:
-				e.healthCheckCh <- struct{}{}
-			case <-ctx.Done():
-				return
-			}
-		}
-	}()
+	scheduler := e.healthCheckScheduler
+	if scheduler == nil {
+		scheduler = NewHealthCheckScheduler(0, 0, 0, 0)
+	}
 
 	go func() {
 		klog.V(2).Infof("[endpoint info] start health checking for cluster=%q, endpoint=%q", e.Cluster, e.Endpoint)
 		defer klog.V(2).Infof("[endpoint info] stop health checking for cluster=%q, endpoint=%q", e.Cluster, e.Endpoint)
-
-		for {
-			select {
-			case <-e.healthCheckCh:
-				e.healthCheckFun(e)
-			case <-ctx.Done():
-				return
-			}
-		}
+		runHealthCheckLoop(ctx, e, interval, scheduler)
 	}()
 }
 
-func (e *EndpointInfo) recordStatusChange() {
-	klog.V(1).Infof(
-		"[endpoint info] endpoint status changed, cluster=%q, endpoint=%q, disabled=%v, healthy=%v, reason=%q, message=%q",
-		e.Cluster, e.Endpoint, e.status.Disabled, e.status.Healthy, e.status.Reason, e.status.Message,
-	)
-}
-
 func (e *EndpointInfo) IsReady() bool {
 	return e.status.IsReady()
 }
@@ -197,6 +259,8 @@ func (e *EndpointInfo) UnreadyReason() string {
 	message := ""
 	if e.status.Disabled {
 		message = fmt.Sprintf("endpoint=%q is disabled.", e.Endpoint)
+	} else if e.status.Cordoned {
+		message = fmt.Sprintf("endpoint=%q is cordoned, reason=%q.", e.Endpoint, e.status.CordonReason)
 	} else if !e.status.Healthy {
 		message = fmt.Sprintf("endpoint=%q is unhealthy, reason=%q, message=%q.", e.Endpoint, e.status.Reason, e.status.Message)
 	}
@@ -0,0 +1,221 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+)
+
+// EndpointAction is an administrative action on endpoints of upstream clusters
+type EndpointAction string
+
+const (
+	EndpointActionCordon   EndpointAction = "cordon"
+	EndpointActionUncordon EndpointAction = "uncordon"
+
+	defaultBatchCordonReason = "cordoned by admin API"
+)
+
+var (
+	ErrEndpointPreconditionFailed = errors.New("endpoint operation precondition failed")
+	ErrNoEndpointsSelected        = errors.New("no endpoints selected")
+
+	// endpointBatchLock serializes batch operations, so concurrent batches can not pass their
+	// preconditions separately and cordon all endpoints of a cluster together.
+	endpointBatchLock sync.Mutex
+)
+
+// EndpointBatch applies one action to endpoints of many upstream clusters at once, e.g. cordoning
+// all endpoints on the machines of one rack. It is kept in memory only and is lost when the cluster is
+// recreated or gateway restarts. If maintenance controller is enabled, it owns cordons of all endpoints
+// and reverts batch cordons at its next reconciliation, cordon them by node annotations instead.
+//
+// Endpoints carry no zone or labels, so a zone is selected by listing the hosts of its machines in
+// Endpoints. Only cordon and uncordon are supported, gateway has neither read-only clusters nor
+// endpoint weights to batch, weights are derived from endpoint scores, see score.go.
+type EndpointBatch struct {
+	Action EndpointAction `json:"action"`
+	// Clusters limits the batch to the clusters, empty means all clusters
+	Clusters []string `json:"clusters,omitempty"`
+	// Endpoints selects endpoints by urls or hosts, e.g. https://10.0.0.1:6443 or 10.0.0.1, list all
+	// hosts of a zone to select the zone, empty means all endpoints of the clusters
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Reason is shown in unready messages of cordoned endpoints
+	Reason string `json:"reason,omitempty"`
+}
+
+// EndpointBatchResult lists endpoints selected by the batch and preconditions it violates
+type EndpointBatchResult struct {
+	DryRun bool `json:"dryRun,omitempty"`
+	// Forced is true if the batch is applied in spite of violations
+	Forced     bool                  `json:"forced,omitempty"`
+	Endpoints  []EndpointBatchChange `json:"endpoints"`
+	Violations []string              `json:"violations,omitempty"`
+}
+
+type EndpointBatchChange struct {
+	Cluster  string `json:"cluster"`
+	Endpoint string `json:"endpoint"`
+	// Changed is true if the endpoint is changed by the batch, or would be in dry run
+	Changed bool `json:"changed"`
+}
+
+// Validate returns an error if the action of b is unknown
+func (b *EndpointBatch) Validate() error {
+	switch b.Action {
+	case EndpointActionCordon, EndpointActionUncordon:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q, must be one of %s, %s", b.Action, EndpointActionCordon, EndpointActionUncordon)
+	}
+}
+
+// ApplyEndpointBatch applies b to endpoints of manager. It refuses to cordon the last ready endpoints
+// of a cluster and changes nothing if any precondition is violated, unless force is true. If dryRun is
+// true, nothing is changed and the result shows what would be.
+func ApplyEndpointBatch(manager Manager, b EndpointBatch, force, dryRun bool) (EndpointBatchResult, error) {
+	if err := b.Validate(); err != nil {
+		return EndpointBatchResult{}, err
+	}
+	infos, err := selectBatchClusters(manager, b.Clusters)
+	if err != nil {
+		return EndpointBatchResult{}, err
+	}
+	selector := newEndpointSelector(b.Endpoints)
+	reason := b.Reason
+	if len(reason) == 0 {
+		reason = defaultBatchCordonReason
+	}
+
+	endpointBatchLock.Lock()
+	defer endpointBatchLock.Unlock()
+
+	result := EndpointBatchResult{DryRun: dryRun, Endpoints: []EndpointBatchChange{}}
+	var selected []*EndpointInfo
+	for _, info := range infos {
+		ready, cordoned := 0, 0
+		info.Endpoints.Range(func(name string, e *EndpointInfo) bool {
+			if !selector.matches(name) {
+				if e.IsReady() {
+					ready++
+				}
+				return true
+			}
+			change := EndpointBatchChange{Cluster: info.Cluster, Endpoint: name}
+			switch b.Action {
+			case EndpointActionCordon:
+				change.Changed = !e.IsCordoned()
+				if e.IsReady() {
+					cordoned++
+				}
+			case EndpointActionUncordon:
+				change.Changed = e.IsCordoned()
+				if e.IsReady() {
+					ready++
+				}
+			}
+			result.Endpoints = append(result.Endpoints, change)
+			selected = append(selected, e)
+			return true
+		})
+		if ready == 0 && cordoned > 0 {
+			result.Violations = append(result.Violations, fmt.Sprintf("cluster %q would have no ready endpoints after cordoning its last %d ready endpoints", info.Cluster, cordoned))
+		}
+	}
+	if len(selected) == 0 {
+		return result, errors.WithMessagef(ErrNoEndpointsSelected, "clusters=%v endpoints=%v", b.Clusters, b.Endpoints)
+	}
+	sort.Slice(result.Endpoints, func(i, j int) bool {
+		if result.Endpoints[i].Cluster != result.Endpoints[j].Cluster {
+			return result.Endpoints[i].Cluster < result.Endpoints[j].Cluster
+		}
+		return result.Endpoints[i].Endpoint < result.Endpoints[j].Endpoint
+	})
+	if len(result.Violations) > 0 {
+		if !force {
+			return result, errors.WithMessage(ErrEndpointPreconditionFailed, strings.Join(result.Violations, "; "))
+		}
+		result.Forced = true
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	for _, e := range selected {
+		e.SetCordoned(b.Action == EndpointActionCordon, reason)
+	}
+	klog.Warningf("[cluster info] %s %d endpoints by batch, forced=%v violations=%v", b.Action, len(selected), result.Forced, result.Violations)
+	return result, nil
+}
+
+func selectBatchClusters(manager Manager, names []string) ([]*ClusterInfo, error) {
+	if len(names) == 0 {
+		return manager.List(), nil
+	}
+	infos := make([]*ClusterInfo, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		info, ok := manager.Get(strings.ToLower(name))
+		if !ok {
+			return nil, errors.WithMessagef(ErrClusterNotFound, "cluster=%q", name)
+		}
+		if !seen[info.Cluster] {
+			seen[info.Cluster] = true
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// endpointSelector matches endpoints by urls or hosts, nil matches all endpoints
+type endpointSelector map[string]bool
+
+func newEndpointSelector(endpoints []string) endpointSelector {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	s := endpointSelector{}
+	for _, e := range endpoints {
+		if e = strings.TrimSuffix(strings.TrimSpace(e), "/"); len(e) > 0 {
+			s[e] = true
+		}
+	}
+	return s
+}
+
+func (s endpointSelector) matches(endpoint string) bool {
+	if s == nil {
+		return true
+	}
+	if s[endpoint] {
+		return true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Host) == 0 {
+		return false
+	}
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return s[host]
+	}
+	return s[u.Host]
+}
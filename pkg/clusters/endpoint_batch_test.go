@@ -0,0 +1,113 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"errors"
+	"testing"
+)
+
+func newBatchTestManager(endpoints map[string][]string) Manager {
+	manager := NewManager()
+	for cluster, names := range endpoints {
+		c := NewEmptyClusterInfo(cluster, nil, nil, nil)
+		for _, name := range names {
+			e := &EndpointInfo{Cluster: c.Cluster, Endpoint: name}
+			e.UpdateStatus(true, "", "")
+			c.Endpoints.Store(name, e)
+		}
+		manager.Add(c)
+	}
+	return manager
+}
+
+func cordonedEndpoints(manager Manager) map[string]bool {
+	cordoned := map[string]bool{}
+	for _, c := range manager.List() {
+		c.Endpoints.Range(func(name string, e *EndpointInfo) bool {
+			if e.IsCordoned() {
+				cordoned[c.Cluster+"/"+name] = true
+			}
+			return true
+		})
+	}
+	return cordoned
+}
+
+func TestApplyEndpointBatch(t *testing.T) {
+	manager := newBatchTestManager(map[string][]string{
+		"a": {"https://10.0.0.1:6443", "https://10.0.0.2:6443"},
+		"b": {"https://10.0.0.1:6444"},
+	})
+
+	// cordoning host 10.0.0.1 would leave cluster b without ready endpoints
+	batch := EndpointBatch{Action: EndpointActionCordon, Endpoints: []string{"10.0.0.1"}, Reason: "rack down"}
+	result, err := ApplyEndpointBatch(manager, batch, false, false)
+	if !errors.Is(err, ErrEndpointPreconditionFailed) {
+		t.Fatalf("ApplyEndpointBatch() error = %v, want %v", err, ErrEndpointPreconditionFailed)
+	}
+	if len(result.Endpoints) != 2 || len(result.Violations) != 1 {
+		t.Errorf("ApplyEndpointBatch() result = %+v, want 2 endpoints and 1 violation", result)
+	}
+	if got := cordonedEndpoints(manager); len(got) != 0 {
+		t.Errorf("refused batch cordoned %v", got)
+	}
+
+	// limited to cluster a, one ready endpoint is left
+	batch.Clusters = []string{"A"}
+	if _, err := ApplyEndpointBatch(manager, batch, false, true); err != nil {
+		t.Fatalf("ApplyEndpointBatch() dry run error = %v", err)
+	}
+	if got := cordonedEndpoints(manager); len(got) != 0 {
+		t.Errorf("dry run cordoned %v", got)
+	}
+	if _, err := ApplyEndpointBatch(manager, batch, false, false); err != nil {
+		t.Fatalf("ApplyEndpointBatch() error = %v", err)
+	}
+	if got := cordonedEndpoints(manager); len(got) != 1 || !got["a/https://10.0.0.1:6443"] {
+		t.Errorf("cordoned = %v, want a/https://10.0.0.1:6443", got)
+	}
+
+	// the last ready endpoint is cordoned only if forced
+	batch.Endpoints = []string{"https://10.0.0.2:6443/"}
+	if _, err := ApplyEndpointBatch(manager, batch, false, false); !errors.Is(err, ErrEndpointPreconditionFailed) {
+		t.Fatalf("ApplyEndpointBatch() error = %v, want %v", err, ErrEndpointPreconditionFailed)
+	}
+	result, err = ApplyEndpointBatch(manager, batch, true, false)
+	if err != nil || !result.Forced {
+		t.Fatalf("ApplyEndpointBatch() forced result = %+v, error = %v", result, err)
+	}
+	if got := cordonedEndpoints(manager); len(got) != 2 {
+		t.Errorf("cordoned = %v, want all endpoints of cluster a", got)
+	}
+
+	result, err = ApplyEndpointBatch(manager, EndpointBatch{Action: EndpointActionUncordon}, false, false)
+	if err != nil || len(result.Endpoints) != 3 {
+		t.Fatalf("ApplyEndpointBatch() uncordon result = %+v, error = %v", result, err)
+	}
+	if got := cordonedEndpoints(manager); len(got) != 0 {
+		t.Errorf("cordoned = %v after uncordon, want none", got)
+	}
+
+	if _, err := ApplyEndpointBatch(manager, EndpointBatch{Action: EndpointActionCordon, Clusters: []string{"c"}}, false, false); !errors.Is(err, ErrClusterNotFound) {
+		t.Errorf("ApplyEndpointBatch() error = %v, want %v", err, ErrClusterNotFound)
+	}
+	if _, err := ApplyEndpointBatch(manager, EndpointBatch{Action: EndpointActionCordon, Endpoints: []string{"10.0.0.3"}}, false, false); !errors.Is(err, ErrNoEndpointsSelected) {
+		t.Errorf("ApplyEndpointBatch() error = %v, want %v", err, ErrNoEndpointsSelected)
+	}
+	if _, err := ApplyEndpointBatch(manager, EndpointBatch{Action: "drain"}, false, false); err == nil {
+		t.Errorf("ApplyEndpointBatch() of unknown action succeeded")
+	}
+}
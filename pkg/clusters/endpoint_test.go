@@ -43,6 +43,16 @@ func TestEndpointInfo_ReadyAndReason(t *testing.T) {
 			false,
 			`endpoint="" is disabled.`,
 		},
+		{
+			"cordoned",
+			endpointStatus{
+				Healthy:      true,
+				Cordoned:     true,
+				CordonReason: "node master-1 is unschedulable",
+			},
+			false,
+			`endpoint="" is cordoned, reason="node master-1 is unschedulable".`,
+		},
 		{
 			"unhealthy",
 			endpointStatus{
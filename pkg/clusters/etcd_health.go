@@ -0,0 +1,98 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"sync/atomic"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// endpointReasonEtcdUnhealthy is the unhealthy reason of endpoints which serve reads but not writes
+const endpointReasonEtcdUnhealthy = "EtcdUnhealthy"
+
+// FailedHealthChecks parses names of failed checks from verbose output of /healthz or /readyz, e.g.
+// "[-]etcd failed: reason withheld" means check etcd failed.
+func FailedHealthChecks(body []byte) []string {
+	failed := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "[-]") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "[-]"))
+		if len(fields) > 0 {
+			failed = append(failed, fields[0])
+		}
+	}
+	return failed
+}
+
+// OnlyEtcdFailed returns true if all failed checks are etcd checks, e.g. etcd and etcd-readiness,
+// such endpoints are still able to serve reads from watch cache.
+func OnlyEtcdFailed(failed []string) bool {
+	if len(failed) == 0 {
+		return false
+	}
+	for _, name := range failed {
+		if !strings.HasPrefix(name, "etcd") {
+			return false
+		}
+	}
+	return true
+}
+
+// EtcdAwareReadiness returns true if etcd health is distinguished from other failures for this endpoint
+func (e *EndpointInfo) EtcdAwareReadiness() bool {
+	return e.featureEnabled != nil && e.featureEnabled(features.EtcdAwareReadiness)
+}
+
+// IsEtcdHealthy returns false if etcd checks of the endpoint failed in last health check
+func (e *EndpointInfo) IsEtcdHealthy() bool {
+	return atomic.LoadInt32(&e.etcdUnhealthy) == 0
+}
+
+// SetEtcdHealthy updates etcd health of the endpoint, writes are not routed to endpoints with unhealthy etcd
+func (e *EndpointInfo) SetEtcdHealthy(healthy bool, message string) {
+	var v int32
+	if !healthy {
+		v = 1
+		metrics.RecordUnhealthyUpstream(e.Cluster, e.Endpoint, endpointReasonEtcdUnhealthy)
+	}
+	if atomic.SwapInt32(&e.etcdUnhealthy, v) != v {
+		klog.V(1).Infof("[endpoint info] endpoint etcd health changed, cluster=%q, endpoint=%q, etcdHealthy=%v, message=%q", e.Cluster, e.Endpoint, healthy, message)
+	}
+}
+
+// preferEtcdHealthy filters out endpoints with unhealthy etcd, all endpoints are returned if none is healthy
+func preferEtcdHealthy(endpoints []*EndpointInfo) []*EndpointInfo {
+	healthy := make([]*EndpointInfo, 0, len(endpoints))
+	for _, e := range endpoints {
+		if e.IsEtcdHealthy() {
+			healthy = append(healthy, e)
+		}
+	}
+	if len(healthy) == 0 {
+		return endpoints
+	}
+	return healthy
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFailedHealthChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     []string
+		etcdOnly bool
+	}{
+		{
+			"healthy",
+			"[+]ping ok\n[+]etcd ok\nhealthz check passed\n",
+			[]string{},
+			false,
+		},
+		{
+			"etcd failed",
+			"[+]ping ok\n[-]etcd failed: reason withheld\n[+]poststarthook/start-apiextensions-controllers ok\nhealthz check failed\n",
+			[]string{"etcd"},
+			true,
+		},
+		{
+			"etcd readiness failed",
+			"[+]ping ok\n[-]etcd-readiness failed: reason withheld\nreadyz check failed\n",
+			[]string{"etcd-readiness"},
+			true,
+		},
+		{
+			"others failed",
+			"[-]etcd failed: reason withheld\n[-]poststarthook/rbac/bootstrap-roles failed: not finished\nhealthz check failed\n",
+			[]string{"etcd", "poststarthook/rbac/bootstrap-roles"},
+			false,
+		},
+		{
+			"not verbose",
+			"Internal Server Error",
+			[]string{},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FailedHealthChecks([]byte(tt.body))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FailedHealthChecks() = %v, want %v", got, tt.want)
+			}
+			if etcdOnly := OnlyEtcdFailed(got); etcdOnly != tt.etcdOnly {
+				t.Errorf("OnlyEtcdFailed() = %v, want %v", etcdOnly, tt.etcdOnly)
+			}
+		})
+	}
+}
+
+func Test_preferEtcdHealthy(t *testing.T) {
+	a := &EndpointInfo{Cluster: "test", Endpoint: "a"}
+	b := &EndpointInfo{Cluster: "test", Endpoint: "b"}
+	b.SetEtcdHealthy(false, "etcd failed")
+
+	if got := preferEtcdHealthy([]*EndpointInfo{a, b}); !reflect.DeepEqual(got, []*EndpointInfo{a}) {
+		t.Errorf("preferEtcdHealthy() = %v, want only endpoint with healthy etcd", got)
+	}
+	if got := preferEtcdHealthy([]*EndpointInfo{b}); !reflect.DeepEqual(got, []*EndpointInfo{b}) {
+		t.Errorf("preferEtcdHealthy() = %v, want all endpoints if none has healthy etcd", got)
+	}
+	b.SetEtcdHealthy(true, "")
+	if got := preferEtcdHealthy([]*EndpointInfo{a, b}); len(got) != 2 {
+		t.Errorf("preferEtcdHealthy() = %v, want all endpoints after etcd recovered", got)
+	}
+}
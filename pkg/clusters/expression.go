@@ -0,0 +1,159 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/gateway/expression"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// ExpressionRulesAnnotationKey denies requests, or overrides upstream subset and flow control schema of
+	// dispatch policies for requests of one upstream cluster matching expressions, which are written in
+	// CEL, see pkg/gateway/expression. The value is a json encoded list of ExpressionRule, rules
+	// are matched in order and the first matched one takes effect, it takes precedence over user agent rules.
+	ExpressionRulesAnnotationKey = "proxy.kubegateway.io/expression-rules"
+
+	// results of evaluating expression rules used in metrics
+	expressionMatched   = "matched"
+	expressionUnmatched = "unmatched"
+	expressionError     = "error"
+)
+
+// ExpressionRule denies or overrides the matched dispatch policy for requests matching Expression
+type ExpressionRule struct {
+	// Name identifies the rule in logs, metrics and responses
+	Name string `json:"name"`
+	// Expression is a bool expression of variables request and user, e.g.
+	// request.verb == 'delete' && request.resource == 'nodes' && !('system:masters' in user.groups).
+	// Requests failed to be evaluated, e.g. exceeding the cost limit, do not match the rule.
+	Expression string `json:"expression"`
+	// Deny rejects matched requests with Message, it can not be set with UpstreamSubset or FlowControlSchemaName
+	Deny    bool   `json:"deny,omitempty"`
+	Message string `json:"message,omitempty"`
+	// UpstreamSubset routes matched requests to a subset of upstream endpoints, empty means the subset of dispatch policy
+	UpstreamSubset []string `json:"upstreamSubset,omitempty"`
+	// FlowControlSchemaName limits matched requests by a flow control schema in spec.flowControl.schemas,
+	// empty means the schema of dispatch policy
+	FlowControlSchemaName string `json:"flowControlSchemaName,omitempty"`
+
+	program *expression.Program
+}
+
+// ExpressionDeniedError is returned by MatchRequest if the request is denied by an expression rule
+type ExpressionDeniedError struct {
+	Rule    string
+	Message string
+}
+
+func (e *ExpressionDeniedError) Error() string {
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("denied by expression rule(%s)", e.Rule)
+	}
+	return fmt.Sprintf("denied by expression rule(%s): %s", e.Rule, e.Message)
+}
+
+// ParseExpressionRules parses and compiles rules from annotation value, servers are endpoints in spec.servers
+// and flowControlSchemas are names of all flow control schemas of the cluster. costLimit is the maximum
+// cost of evaluating an expression once.
+func ParseExpressionRules(value string, servers, flowControlSchemas sets.String, costLimit int64) ([]ExpressionRule, error) {
+	rules := []ExpressionRule{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid expression rules: %v", err)
+	}
+	names := sets.NewString()
+	for i := range rules {
+		rule := &rules[i]
+		if errs := validation.IsDNS1123Label(rule.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid name %q of rule[%d]: %v", rule.Name, i, errs)
+		}
+		if names.Has(rule.Name) {
+			return nil, fmt.Errorf("duplicate name %q of rule[%d]", rule.Name, i)
+		}
+		names.Insert(rule.Name)
+		program, err := expression.Compile(rule.Expression, costLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of rule[%d]: %v", i, err)
+		}
+		rule.program = program
+		overrides := len(rule.UpstreamSubset) > 0 || len(rule.FlowControlSchemaName) > 0
+		if rule.Deny == overrides {
+			return nil, fmt.Errorf("rule[%d] must either deny requests or override upstreamSubset or flowControlSchemaName", i)
+		}
+		for _, u := range rule.UpstreamSubset {
+			if !servers.Has(u) {
+				return nil, fmt.Errorf("upstream subset endpoint %q of rule[%d] must be present in servers", u, i)
+			}
+		}
+		if len(rule.FlowControlSchemaName) > 0 && !flowControlSchemas.Has(rule.FlowControlSchemaName) {
+			return nil, fmt.Errorf("flow control schema %q of rule[%d] must be present in spec.flowControl.schemas", rule.FlowControlSchemaName, i)
+		}
+	}
+	return rules, nil
+}
+
+func (c *ClusterInfo) syncExpressionRules(annotations map[string]string, servers []proxyv1alpha1.UpstreamClusterServer, flowControlSchemas sets.String) error {
+	var rules []ExpressionRule
+	if value := annotations[ExpressionRulesAnnotationKey]; len(value) > 0 {
+		var err error
+		rules, err = ParseExpressionRules(value, serverEndpoints(servers), flowControlSchemas, c.defaults.ExpressionCostLimit)
+		if err != nil {
+			return err
+		}
+	}
+	old, _ := c.currentExpressionRules.Load().([]ExpressionRule)
+	if !reflect.DeepEqual(old, rules) {
+		klog.Infof("[cluster info] cluster=%q update expression rules, rules=%d", c.Cluster, len(rules))
+	}
+	c.currentExpressionRules.Store(rules)
+	return nil
+}
+
+// matchExpressionRule returns the first rule matching request, nil if none matches
+func (s *routingSnapshot) matchExpressionRule(cluster string, requestAttributes authorizer.Attributes, userAgent string) *ExpressionRule {
+	if len(s.expressionRules) == 0 {
+		return nil
+	}
+	act := expression.NewActivation(requestAttributes, userAgent)
+	for i := range s.expressionRules {
+		rule := &s.expressionRules[i]
+		matched, err := rule.program.Eval(act)
+		switch {
+		case err != nil:
+			metrics.RecordExpressionRuleEvaluation(cluster, rule.Name, expressionError)
+			klog.V(2).Infof("[expression rule] cluster=%q rule=%q failed to evaluate request of user=%q verb=%q path=%q: %v",
+				cluster, rule.Name, act.User.Name, act.Request.Verb, act.Request.Path, err)
+		case matched:
+			metrics.RecordExpressionRuleEvaluation(cluster, rule.Name, expressionMatched)
+			return rule
+		default:
+			metrics.RecordExpressionRuleEvaluation(cluster, rule.Name, expressionUnmatched)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+	"github.com/kubewharf/kubegateway/pkg/gateway/expression"
+)
+
+func TestParseExpressionRules(t *testing.T) {
+	servers := sets.NewString("https://127.0.0.1:443")
+	schemas := sets.NewString("throttle")
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `[{"name":"deny-nodes","expression":"request.resource == 'nodes'","deny":true},{"name":"throttle","expression":"request.verb == 'list'","flowControlSchemaName":"throttle","upstreamSubset":["https://127.0.0.1:443"]}]`, false},
+		{"invalid name", `[{"name":"Deny Nodes","expression":"true","deny":true}]`, true},
+		{"duplicate name", `[{"name":"a","expression":"true","deny":true},{"name":"a","expression":"false","deny":true}]`, true},
+		{"invalid expression", `[{"name":"a","expression":"request.unknown == 'x'","deny":true}]`, true},
+		{"no action", `[{"name":"a","expression":"true"}]`, true},
+		{"deny and override", `[{"name":"a","expression":"true","deny":true,"flowControlSchemaName":"throttle"}]`, true},
+		{"unknown schema", `[{"name":"a","expression":"true","flowControlSchemaName":"unknown"}]`, true},
+		{"unknown server", `[{"name":"a","expression":"true","upstreamSubset":["https://127.0.0.2:443"]}]`, true},
+		{"unknown field", `[{"name":"a","expression":"true","deny":true,"qps":1}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseExpressionRules(tt.value, servers, schemas, expression.DefaultCostLimit); (err != nil) != tt.wantErr {
+				t.Errorf("ParseExpressionRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_MatchRequest_ExpressionRules(t *testing.T) {
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.FlowControl = proxyv1alpha1.FlowControl{
+		Schemas: []proxyv1alpha1.FlowControlSchema{
+			{
+				Name: "throttle",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 1},
+				},
+			},
+		},
+	}
+	cluster.Annotations = map[string]string{
+		ExpressionRulesAnnotationKey: `[
+			{"name":"protect-nodes","expression":"request.verb == 'delete' && request.resource == 'nodes' && !('system:masters' in user.groups)","deny":true,"message":"only admins can delete nodes"},
+			{"name":"throttle-operator","expression":"request.userAgent.startsWith('my-operator/')","flowControlSchemaName":"throttle"}
+		]`,
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	match := func(verb, resource string, groups []string, userAgent string) (EndpointPicker, error) {
+		return info.MatchRequest(authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "test", Groups: groups},
+			Verb:            verb,
+			Resource:        resource,
+			ResourceRequest: true,
+		}, userAgent)
+	}
+
+	_, err = match("delete", "nodes", []string{"system:authenticated"}, "kubectl/v1.18.19")
+	var denied *ExpressionDeniedError
+	if !errors.As(err, &denied) || denied.Rule != "protect-nodes" {
+		t.Errorf("MatchRequest() of deleting nodes error = %v, want denied by protect-nodes", err)
+	}
+	if _, err := match("delete", "nodes", []string{"system:masters"}, "kubectl/v1.18.19"); err != nil {
+		t.Errorf("MatchRequest() of deleting nodes by admin error = %v", err)
+	}
+
+	picker, err := match("list", "pods", nil, "my-operator/v0.18.3")
+	if err != nil {
+		t.Fatalf("MatchRequest() error = %v", err)
+	}
+	if got := gatewayflowcontrol.NameOf(picker.FlowControl()); got != "throttle" {
+		t.Errorf("flow control of matched request = %q, want throttle", got)
+	}
+}
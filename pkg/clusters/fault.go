@@ -0,0 +1,102 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"k8s.io/klog"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+)
+
+// FaultInjection injects faults into a percentage of requests to one upstream cluster, so that
+// client retry behavior and gateway resilience can be validated in staging. It is set by admin
+// API only and is lost when the cluster is recreated or gateway restarts.
+type FaultInjection struct {
+	// Percentage of requests in (0, 100] to inject faults into
+	Percentage float64 `json:"percentage"`
+	// LatencyMilliseconds delays the faulted requests before they are proxied
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+	// StatusCode responds the faulted requests with an error status instead of proxying them
+	StatusCode int `json:"statusCode,omitempty"`
+	// ResetConnection aborts the faulted requests by resetting client connections or streams
+	ResetConnection bool `json:"resetConnection,omitempty"`
+	// ExpireTime disables the fault injection automatically, zero means never
+	ExpireTime time.Time `json:"expireTime,omitempty"`
+}
+
+// Validate returns an error if f injects nothing or is invalid
+func (f *FaultInjection) Validate() error {
+	if f.Percentage <= 0 || f.Percentage > 100 {
+		return fmt.Errorf("percentage must be in (0, 100]")
+	}
+	if f.LatencyMilliseconds < 0 {
+		return fmt.Errorf("latencyMilliseconds must not be negative")
+	}
+	if f.StatusCode != 0 && (f.StatusCode < 400 || f.StatusCode > 599) {
+		return fmt.Errorf("statusCode must be an error code in [400, 599]")
+	}
+	if f.StatusCode != 0 && f.ResetConnection {
+		return fmt.Errorf("statusCode and resetConnection are mutually exclusive")
+	}
+	if f.LatencyMilliseconds == 0 && f.StatusCode == 0 && !f.ResetConnection {
+		return fmt.Errorf("at least one of latencyMilliseconds, statusCode and resetConnection must be set")
+	}
+	if f.StatusCode != 0 && http.StatusText(f.StatusCode) == "" {
+		return fmt.Errorf("unknown statusCode %d", f.StatusCode)
+	}
+	return nil
+}
+
+// Latency returns the injected latency
+func (f *FaultInjection) Latency() time.Duration {
+	return time.Duration(f.LatencyMilliseconds) * time.Millisecond
+}
+
+// SetFaultInjection sets the fault injection of this cluster, nil disables it
+func (c *ClusterInfo) SetFaultInjection(f *FaultInjection) {
+	if f == nil {
+		klog.Infof("[cluster info] cluster=%q disable fault injection", c.Cluster)
+	} else {
+		klog.Warningf("[cluster info] cluster=%q enable fault injection, %+v", c.Cluster, *f)
+	}
+	c.currentFaultInjection.Store(f)
+}
+
+// FaultInjection returns the current fault injection, nil means it is disabled or expired
+func (c *ClusterInfo) FaultInjection() *FaultInjection {
+	f, _ := c.currentFaultInjection.Load().(*FaultInjection)
+	if f == nil || (!f.ExpireTime.IsZero() && time.Now().After(f.ExpireTime)) {
+		return nil
+	}
+	return f
+}
+
+// PickFault returns the fault to inject into current request, nil means the request should be
+// proxied as usual. It always returns nil if feature gate FaultInjection is disabled.
+func (c *ClusterInfo) PickFault() *FaultInjection {
+	if !gatewayfeatures.Enabled(gatewayfeatures.FaultInjection) {
+		return nil
+	}
+	f := c.FaultInjection()
+	if f == nil || rand.Float64()*100 >= f.Percentage {
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+	"time"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+)
+
+func TestFaultInjection_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   FaultInjection
+		wantErr bool
+	}{
+		{"latency", FaultInjection{Percentage: 10, LatencyMilliseconds: 100}, false},
+		{"error", FaultInjection{Percentage: 100, StatusCode: 503}, false},
+		{"reset", FaultInjection{Percentage: 1, ResetConnection: true}, false},
+		{"zero percentage", FaultInjection{StatusCode: 503}, true},
+		{"percentage too large", FaultInjection{Percentage: 101, StatusCode: 503}, true},
+		{"nothing injected", FaultInjection{Percentage: 10}, true},
+		{"success code", FaultInjection{Percentage: 10, StatusCode: 200}, true},
+		{"error and reset", FaultInjection{Percentage: 10, StatusCode: 503, ResetConnection: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fault.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_PickFault(t *testing.T) {
+	info := NewEmptyClusterInfo("test", newRESTConfig(), nil, nil)
+	info.SetFaultInjection(&FaultInjection{Percentage: 100, StatusCode: 503})
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, gatewayfeatures.FaultInjection, false)()
+	if info.PickFault() != nil {
+		t.Errorf("PickFault() returns fault when feature gate is disabled")
+	}
+
+	defer featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, gatewayfeatures.FaultInjection, true)()
+	if info.PickFault() == nil {
+		t.Errorf("PickFault() returns nil with 100 percentage")
+	}
+
+	info.SetFaultInjection(&FaultInjection{Percentage: 100, StatusCode: 503, ExpireTime: time.Now().Add(-time.Second)})
+	if info.PickFault() != nil {
+		t.Errorf("PickFault() returns expired fault")
+	}
+
+	info.SetFaultInjection(nil)
+	if info.PickFault() != nil {
+		t.Errorf("PickFault() returns fault after disabled")
+	}
+}
@@ -27,6 +27,23 @@ const (
 
 	// Deny all reqeusts and make cluster temporary down
 	DenyAllRequests featuregate.Feature = "DenyAllRequests"
+
+	// Keep routing reads to endpoints whose only failed health checks are etcd checks,
+	// and route writes to endpoints with healthy etcd if there are any.
+	EtcdAwareReadiness featuregate.Feature = "EtcdAwareReadiness"
+
+	// End JSON watches closed by gateway, e.g. when their upstream endpoint is stopped, with a BOOKMARK
+	// event carrying the last known resourceVersion if clients allow bookmarks, so reflectors re-watch from
+	// that version instead of relisting.
+	WatchResumeHint featuregate.Feature = "WatchResumeHint"
+
+	// Verify GET responses by comparing checksums of bytes received from upstream and bytes written
+	// to client, to detect rare data corruption introduced by the proxy copy path.
+	ResponseChecksum featuregate.Feature = "ResponseChecksum"
+
+	// Translate exec and attach sessions of WebSocket v5 channel protocol and port-forward sessions tunneled
+	// over WebSocket from clients to SPDY toward upstream apiservers which do not support them, e.g. older than v1.30.
+	WebSocketStreamTranslation featuregate.Feature = "WebSocketStreamTranslation"
 )
 
 var (
@@ -48,8 +65,12 @@ var (
 	// defaultFeatureGates consists of all known feature keys.
 	// To add a new feature, define a key for it above and add it here.
 	defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
-		CloseConnectionWhenIdle: {Default: false, PreRelease: featuregate.Alpha},
-		DenyAllRequests:         {Default: false, PreRelease: featuregate.Alpha},
+		CloseConnectionWhenIdle:    {Default: false, PreRelease: featuregate.Alpha},
+		DenyAllRequests:            {Default: false, PreRelease: featuregate.Alpha},
+		EtcdAwareReadiness:         {Default: false, PreRelease: featuregate.Alpha},
+		WatchResumeHint:            {Default: false, PreRelease: featuregate.Alpha},
+		ResponseChecksum:           {Default: false, PreRelease: featuregate.Alpha},
+		WebSocketStreamTranslation: {Default: false, PreRelease: featuregate.Alpha},
 	}
 
 	defaultKnownFeatures []string
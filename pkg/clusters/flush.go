@@ -0,0 +1,101 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// FlushIntervalAnnotationKey overrides how often proxied responses of one upstream cluster are flushed to
+	// clients, the value is a comma separated list of key=duration pairs, e.g. standard=100ms,streaming=50ms.
+	// streaming is for watches and followed logs, zero flushes every write immediately. standard is for all the
+	// other responses, zero disables periodic flushing.
+	FlushIntervalAnnotationKey = "proxy.kubegateway.io/flush-interval"
+
+	flushIntervalStandard  = "standard"
+	flushIntervalStreaming = "streaming"
+)
+
+// defaultFlushIntervals are the same as k8s upgrade aware proxy handler
+var defaultFlushIntervals = FlushIntervals{Standard: 200 * time.Millisecond}
+
+// FlushIntervals are flush intervals of proxied responses, flushing less often trades latency of
+// small writes for throughput of large responses.
+type FlushIntervals struct {
+	// Standard is the flush interval of responses other than streams, zero disables periodic flushing
+	Standard time.Duration
+	// Streaming is the flush interval of watches and followed logs, zero means flushing immediately
+	Streaming time.Duration
+}
+
+// ParseFlushIntervals parses flush intervals from annotation value, keys not present in value use defaults.
+func ParseFlushIntervals(value string) (FlushIntervals, error) {
+	intervals := defaultFlushIntervals
+	for _, s := range strings.Split(value, ",") {
+		if len(s) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return intervals, fmt.Errorf("missing value for flush interval %q", s)
+		}
+		k := strings.TrimSpace(kv[0])
+		v, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return intervals, fmt.Errorf("invalid value of %s=%s, err: %v", k, kv[1], err)
+		}
+		if v < 0 {
+			return intervals, fmt.Errorf("invalid value of %s=%s, must not be negative", k, kv[1])
+		}
+		switch k {
+		case flushIntervalStandard:
+			intervals.Standard = v
+		case flushIntervalStreaming:
+			intervals.Streaming = v
+		default:
+			return intervals, fmt.Errorf("unrecognized flush interval %q", k)
+		}
+	}
+	return intervals, nil
+}
+
+// FlushIntervals returns the current flush intervals of this cluster
+func (c *ClusterInfo) FlushIntervals() FlushIntervals {
+	if intervals, ok := c.currentFlushIntervals.Load().(FlushIntervals); ok {
+		return intervals
+	}
+	return defaultFlushIntervals
+}
+
+func (c *ClusterInfo) syncFlushIntervals(annotations map[string]string) error {
+	intervals := defaultFlushIntervals
+	if value := annotations[FlushIntervalAnnotationKey]; len(value) > 0 {
+		var err error
+		intervals, err = ParseFlushIntervals(value)
+		if err != nil {
+			return err
+		}
+	}
+	if old := c.FlushIntervals(); old != intervals {
+		klog.Infof("[cluster info] cluster=%q update flush intervals, standard=%v streaming=%v", c.Cluster, intervals.Standard, intervals.Streaming)
+	}
+	c.currentFlushIntervals.Store(intervals)
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFlushIntervals(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    FlushIntervals
+		wantErr bool
+	}{
+		{"both", "standard=100ms, streaming=50ms", FlushIntervals{Standard: 100 * time.Millisecond, Streaming: 50 * time.Millisecond}, false},
+		{"streaming only", "streaming=1s", FlushIntervals{Standard: 200 * time.Millisecond, Streaming: time.Second}, false},
+		{"disable periodic flushing", "standard=0", FlushIntervals{}, false},
+		{"missing value", "standard", FlushIntervals{}, true},
+		{"negative", "streaming=-1s", FlushIntervals{}, true},
+		{"unknown key", "watch=1s", FlushIntervals{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFlushIntervals(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFlushIntervals() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseFlushIntervals() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_FlushIntervals(t *testing.T) {
+	c := NewEmptyClusterInfo("test", nil, nil, nil)
+	if got := c.FlushIntervals(); got != defaultFlushIntervals {
+		t.Errorf("FlushIntervals() = %+v before syncing, want defaults", got)
+	}
+	annotations := map[string]string{FlushIntervalAnnotationKey: "streaming=100ms"}
+	if err := c.syncFlushIntervals(annotations); err != nil {
+		t.Fatalf("syncFlushIntervals() error = %v", err)
+	}
+	if got := c.FlushIntervals(); got.Streaming != 100*time.Millisecond {
+		t.Errorf("FlushIntervals().Streaming = %v, want 100ms", got.Streaming)
+	}
+}
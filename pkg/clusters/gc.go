@@ -0,0 +1,107 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+var (
+	// staleStateDelay is how long state of deleted clusters and endpoints outlives them before it is
+	// released, so that metrics recorded by requests still being aborted are released as well.
+	staleStateDelay = 30 * time.Second
+
+	cleanupsLock sync.RWMutex
+	cleanups     []func(cluster string)
+)
+
+// OnClusterDeleted registers fn to release state kept outside this package for a deleted cluster,
+// e.g. caches keyed by cluster name. fn is called after staleStateDelay unless a cluster with the
+// same name is added again. It must be called before upstream cluster controller starts.
+func OnClusterDeleted(fn func(cluster string)) {
+	cleanupsLock.Lock()
+	defer cleanupsLock.Unlock()
+	cleanups = append(cleanups, fn)
+}
+
+// releaseStaleCluster releases metric series and registered state of a deleted cluster,
+// exists reports whether the cluster is added again in the meantime.
+func releaseStaleCluster(cluster string, exists func() bool) {
+	time.AfterFunc(staleStateDelay, func() {
+		if exists() {
+			return
+		}
+		cleanupsLock.RLock()
+		fns := cleanups
+		cleanupsLock.RUnlock()
+		for _, fn := range fns {
+			fn(cluster)
+		}
+		deleted := metrics.DeleteClusterSeries(cluster)
+		klog.V(2).Infof("[cluster manager] released stale state of deleted cluster=%q, deleted %d metric series", cluster, deleted)
+	})
+}
+
+// releaseStaleEndpoint releases metric series of an endpoint deleted from cluster,
+// exists reports whether the endpoint is added again in the meantime.
+func releaseStaleEndpoint(cluster, endpoint string, exists func() bool) {
+	time.AfterFunc(staleStateDelay, func() {
+		if exists() {
+			return
+		}
+		deleted := metrics.DeleteEndpointSeries(cluster, endpoint)
+		klog.V(2).Infof("[cluster info] released stale state of deleted endpoint=%q of cluster=%q, deleted %d metric series", endpoint, cluster, deleted)
+	})
+}
+
+// stop cancels all requests and health checks of the endpoint and closes idle connections of its transports,
+// connections serving requests are closed after requests are aborted.
+func (e *EndpointInfo) stop() {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.Lock()
+	cancel := e.cancelHealthCheck
+	e.cancelHealthCheck = nil
+	e.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	closeIdleConnections(e.ProxyTransport)
+	closeIdleConnections(e.LongRunningTransport)
+	closeIdleConnections(e.PorxyUpgradeTransport)
+}
+
+// closeIdleConnections closes idle connections of the innermost transport wrapped by rt
+func closeIdleConnections(rt http.RoundTripper) {
+	for rt != nil {
+		if c, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			c.CloseIdleConnections()
+			return
+		}
+		w, ok := rt.(utilnet.RoundTripperWrapper)
+		if !ok {
+			return
+		}
+		rt = w.WrappedRoundTripper()
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// waitForGoroutines waits until the number of goroutines is not more than n
+func waitForGoroutines(t *testing.T, n int) {
+	var current int
+	for i := 0; i < 100; i++ {
+		if current = runtime.NumGoroutine(); current <= n {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	buf := make([]byte, 1<<20)
+	t.Fatalf("goroutines leaked, want %d, got %d:\n%s", n, current, buf[:runtime.Stack(buf, true)])
+}
+
+func TestManager_Delete_releasesGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	// a slow health check fills the trigger channel, its ticker must not block after cluster is deleted
+	checking := make(chan struct{}, 1)
+	release := make(chan struct{})
+	slowHealthCheck := func(e *EndpointInfo) bool {
+		select {
+		case checking <- struct{}{}:
+		default:
+		}
+		<-release
+		return false
+	}
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.Servers = append(cluster.Spec.Servers, cluster.Spec.Servers[0])
+	cluster.Spec.Servers[1].Endpoint = "https://127.0.0.2:443"
+	info := NewEmptyClusterInfo(cluster.Name, nil, slowHealthCheck, nil)
+	restConfig, err := buildClusterRESTConfig(cluster, nil)
+	if err != nil {
+		t.Fatalf("buildClusterRESTConfig() error = %v", err)
+	}
+	info.restConfig = restConfig
+	info.authMode = AuthModeOf(cluster.Annotations, AuthModeImpersonate)
+	info.healthCheckIntervalSeconds = 10 * time.Millisecond
+	if err := info.Sync(cluster); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	<-checking
+	// let tickers fill trigger channels
+	time.Sleep(50 * time.Millisecond)
+
+	m := NewManager()
+	m.Add(info)
+	m.Delete(info.Cluster)
+	close(release)
+
+	waitForGoroutines(t, before)
+	if info.Context().Err() == nil {
+		t.Errorf("context of deleted cluster is not canceled")
+	}
+	info.Endpoints.Range(func(name string, e *EndpointInfo) bool {
+		if e.Context().Err() == nil {
+			t.Errorf("context of endpoint %v of deleted cluster is not canceled", name)
+		}
+		return true
+	})
+}
+
+func TestManager_Delete_releasesStaleState(t *testing.T) {
+	delay := staleStateDelay
+	staleStateDelay = 10 * time.Millisecond
+	defer func() {
+		staleStateDelay = delay
+	}()
+
+	released := make(chan string, 2)
+	OnClusterDeleted(func(cluster string) {
+		select {
+		case released <- cluster:
+		default:
+		}
+	})
+
+	m := NewManager()
+	m.Add(NewEmptyClusterInfo("deleted", nil, nil, nil))
+	m.Add(NewEmptyClusterInfo("recreated", nil, nil, nil))
+	m.Delete("deleted")
+	m.Delete("recreated")
+	m.Add(NewEmptyClusterInfo("recreated", nil, nil, nil))
+
+	select {
+	case cluster := <-released:
+		if cluster != "deleted" {
+			t.Errorf("released state of cluster %v, want deleted", cluster)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("state of deleted cluster is not released")
+	}
+	select {
+	case cluster := <-released:
+		t.Errorf("released state of cluster %v which is added again", cluster)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	healthCheckTriggerPeriodic = "periodic"
+	healthCheckTriggerOnDemand = "on_demand"
+)
+
+// HealthCheckScheduler executes health checks of thousands of endpoints without synchronized bursts.
+// The interval of each endpoint is jittered, so endpoints added at the same time drift apart, all
+// checks are paced by a global rate and bounded by a global concurrency.
+type HealthCheckScheduler struct {
+	jitter  float64
+	limiter flowcontrol.RateLimiter
+	slots   chan struct{}
+}
+
+// NewHealthCheckScheduler creates a scheduler, the interval of an endpoint is randomized in [interval, interval*(1+jitter)),
+// zero qps means checks are not paced and zero maxConcurrency means checks are not bounded.
+func NewHealthCheckScheduler(jitter float64, qps float32, burst int, maxConcurrency int) *HealthCheckScheduler {
+	s := &HealthCheckScheduler{jitter: jitter}
+	if qps > 0 {
+		if burst <= 0 {
+			burst = 1
+		}
+		s.limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	if maxConcurrency > 0 {
+		s.slots = make(chan struct{}, maxConcurrency)
+	}
+	return s
+}
+
+// next returns the delay before the next periodic check
+func (s *HealthCheckScheduler) next(interval time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return interval
+	}
+	return wait.Jitter(interval, s.jitter)
+}
+
+// run waits for global pacing and a free slot, then executes check. It returns false without executing
+// check if ctx is done while waiting.
+func (s *HealthCheckScheduler) run(ctx context.Context, trigger string, due time.Time, check func()) bool {
+	if s.limiter != nil {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return false
+		}
+	}
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return false
+		}
+	}
+	metrics.RecordHealthCheckStarted(trigger, time.Since(due))
+	defer metrics.RecordHealthCheckFinished()
+	check()
+	return true
+}
+
+// runHealthCheckLoop checks endpoint immediately, then every jittered interval or whenever it is triggered,
+// until ctx is done.
+func runHealthCheckLoop(ctx context.Context, e *EndpointInfo, interval time.Duration, scheduler *HealthCheckScheduler) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	due := time.Now()
+	for {
+		trigger := healthCheckTriggerPeriodic
+		select {
+		case <-timer.C:
+		case <-e.healthCheckCh:
+			trigger, due = healthCheckTriggerOnDemand, time.Now()
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+		if !scheduler.run(ctx, trigger, due, func() { e.healthCheckFun(e) }) {
+			return
+		}
+		delay := scheduler.next(interval)
+		due = time.Now().Add(delay)
+		timer.Reset(delay)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckScheduler_maxConcurrency(t *testing.T) {
+	s := NewHealthCheckScheduler(0, 0, 0, 2)
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(context.Background(), healthCheckTriggerPeriodic, time.Now(), func() {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			})
+		}()
+	}
+	wg.Wait()
+	if maxRunning > 2 {
+		t.Errorf("max running health checks = %v, want <= 2", maxRunning)
+	}
+}
+
+func TestHealthCheckScheduler_canceled(t *testing.T) {
+	s := NewHealthCheckScheduler(0, 0, 0, 1)
+	s.slots <- struct{}{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if s.run(ctx, healthCheckTriggerPeriodic, time.Now(), func() { t.Errorf("check must not run after canceled") }) {
+		t.Errorf("run() = true, want false")
+	}
+}
+
+func TestHealthCheckScheduler_next(t *testing.T) {
+	s := NewHealthCheckScheduler(0.5, 0, 0, 0)
+	for i := 0; i < 100; i++ {
+		if d := s.next(time.Second); d < time.Second || d >= 1500*time.Millisecond {
+			t.Fatalf("next() = %v, want in [1s, 1.5s)", d)
+		}
+	}
+	if d := NewHealthCheckScheduler(0, 0, 0, 0).next(time.Second); d != time.Second {
+		t.Errorf("next() without jitter = %v, want 1s", d)
+	}
+}
+
+func Test_runHealthCheckLoop(t *testing.T) {
+	checked := make(chan struct{}, 10)
+	e := &EndpointInfo{
+		healthCheckCh: make(chan struct{}, 1),
+		healthCheckFun: func(*EndpointInfo) bool {
+			checked <- struct{}{}
+			return false
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runHealthCheckLoop(ctx, e, time.Hour, NewHealthCheckScheduler(0.1, 0, 0, 0))
+
+	wait := func(what string) {
+		select {
+		case <-checked:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s health check is not executed", what)
+		}
+	}
+	wait("initial")
+	e.TriggerHealthCheck()
+	wait("triggered")
+	select {
+	case <-checked:
+		t.Errorf("unexpected health check before interval")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
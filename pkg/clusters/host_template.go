@@ -0,0 +1,138 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultHostTemplates resolve hostnames without an explicit cluster to cluster names, they are
+// tried in order after exact match. Manager.Resolve reads the slice for every request without
+// locking, so it is never changed once proxy server is serving.
+var DefaultHostTemplates []*HostTemplate
+
+var hostTemplateVariable = regexp.MustCompile(`\{([a-z][a-z0-9]*)\}`)
+
+// HostTemplate extracts cluster name from hostname, e.g. host pattern {name}.region-a.gateway.example.com
+// with cluster template {name}-region-a resolves foo.region-a.gateway.example.com to cluster foo-region-a.
+// Each variable matches exactly one DNS label.
+type HostTemplate struct {
+	hostPattern     string
+	clusterTemplate string
+	regexp          *regexp.Regexp
+	variables       []string
+}
+
+// ParseHostTemplate parses template in format hostPattern[=clusterTemplate], cluster template
+// defaults to the first variable of host pattern. A leading * label is the same as {name}.
+func ParseHostTemplate(value string) (*HostTemplate, error) {
+	parts := strings.SplitN(strings.ToLower(strings.TrimSpace(value)), "=", 2)
+	hostPattern := parts[0]
+	if strings.HasPrefix(hostPattern, "*.") {
+		hostPattern = "{name}" + strings.TrimPrefix(hostPattern, "*")
+	}
+	if strings.Contains(hostPattern, "*") {
+		return nil, fmt.Errorf("invalid host pattern %q, only the first label can be *", parts[0])
+	}
+
+	matches := hostTemplateVariable.FindAllStringSubmatchIndex(hostPattern, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("invalid host pattern %q, no variable like {name} found", parts[0])
+	}
+	expr := strings.Builder{}
+	expr.WriteString("^")
+	variables := []string{}
+	last := 0
+	for _, m := range matches {
+		literal := hostPattern[last:m[0]]
+		if strings.ContainsAny(literal, "{}") {
+			return nil, fmt.Errorf("invalid host pattern %q, variable names must be lowercase alphanumeric", parts[0])
+		}
+		expr.WriteString(regexp.QuoteMeta(literal))
+		name := hostPattern[m[2]:m[3]]
+		for _, v := range variables {
+			if v == name {
+				return nil, fmt.Errorf("invalid host pattern %q, variable {%s} is duplicated", parts[0], name)
+			}
+		}
+		variables = append(variables, name)
+		expr.WriteString(`([a-z0-9]([a-z0-9-]*[a-z0-9])?)`)
+		last = m[1]
+	}
+	literal := hostPattern[last:]
+	if strings.ContainsAny(literal, "{}") {
+		return nil, fmt.Errorf("invalid host pattern %q, variable names must be lowercase alphanumeric", parts[0])
+	}
+	expr.WriteString(regexp.QuoteMeta(literal))
+	expr.WriteString("$")
+
+	clusterTemplate := "{" + variables[0] + "}"
+	if len(parts) == 2 {
+		clusterTemplate = parts[1]
+	}
+	for _, m := range hostTemplateVariable.FindAllStringSubmatch(clusterTemplate, -1) {
+		found := false
+		for _, v := range variables {
+			found = found || v == m[1]
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid cluster template %q, variable {%s} is not in host pattern", clusterTemplate, m[1])
+		}
+	}
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	return &HostTemplate{
+		hostPattern:     hostPattern,
+		clusterTemplate: clusterTemplate,
+		regexp:          re,
+		variables:       variables,
+	}, nil
+}
+
+// Resolve returns the cluster name of host if host matches the pattern
+func (t *HostTemplate) Resolve(host string) (string, bool) {
+	m := t.regexp.FindStringSubmatch(strings.ToLower(host))
+	if m == nil {
+		return "", false
+	}
+	values := map[string]string{}
+	// every variable has two groups, the label and its optional tail
+	for i, v := range t.variables {
+		values[v] = m[1+2*i]
+	}
+	cluster := hostTemplateVariable.ReplaceAllStringFunc(t.clusterTemplate, func(s string) string {
+		return values[s[1:len(s)-1]]
+	})
+	return cluster, true
+}
+
+func (t *HostTemplate) String() string {
+	return t.hostPattern + "=" + t.clusterTemplate
+}
+
+// resolveHostTemplates returns the cluster name resolved by the first matching template
+func resolveHostTemplates(templates []*HostTemplate, host string) (string, bool) {
+	for _, t := range templates {
+		if cluster, ok := t.Resolve(host); ok {
+			return cluster, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+)
+
+func Test_HostTemplate_Resolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		template    string
+		host        string
+		wantCluster string
+		wantMatched bool
+	}{
+		{"wildcard", "*.gateway.example.com", "foo.gateway.example.com", "foo", true},
+		{"wildcard nested label", "*.gateway.example.com", "a.foo.gateway.example.com", "", false},
+		{"wildcard suffix only", "*.gateway.example.com", "gateway.example.com", "", false},
+		{"cluster template", "{name}.region-a.gateway.example.com={name}-region-a", "Foo.region-a.gateway.example.com", "foo-region-a", true},
+		{"multiple variables", "{name}.{region}.gateway.example.com={region}-{name}", "foo.b.gateway.example.com", "b-foo", true},
+		{"dashed label", "*.gateway.example.com", "foo-bar.gateway.example.com", "foo-bar", true},
+		{"not matched", "*.gateway.example.com", "foo.example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template, err := ParseHostTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("ParseHostTemplate() error = %v", err)
+			}
+			cluster, matched := template.Resolve(tt.host)
+			if cluster != tt.wantCluster || matched != tt.wantMatched {
+				t.Errorf("Resolve() = %v, %v, want %v, %v", cluster, matched, tt.wantCluster, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func Test_ParseHostTemplate_Invalid(t *testing.T) {
+	tests := []string{
+		"gateway.example.com",
+		"foo.*.example.com",
+		"{name}.{name}.example.com",
+		"{name}.example.com={other}",
+	}
+	for _, value := range tests {
+		if _, err := ParseHostTemplate(value); err == nil {
+			t.Errorf("ParseHostTemplate(%q) expected error", value)
+		}
+	}
+}
+
+func Test_manager_Resolve_HostTemplates(t *testing.T) {
+	template, err := ParseHostTemplate("{name}.region-a.gateway.example.com={name}-region-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old []*HostTemplate) { DefaultHostTemplates = old }(DefaultHostTemplates)
+	DefaultHostTemplates = []*HostTemplate{template}
+
+	m := NewManager().(*manager)
+	cluster := &ClusterInfo{Cluster: "foo-region-a"}
+	m.clusters.Store("foo-region-a", cluster)
+
+	if got, ok := m.Resolve("foo.region-a.gateway.example.com"); !ok || got != cluster {
+		t.Errorf("Resolve() = %v, %v, want cluster foo-region-a", got, ok)
+	}
+	if got, ok := m.Resolve("foo-region-a"); !ok || got != cluster {
+		t.Errorf("Resolve() = %v, %v, want exact cluster foo-region-a", got, ok)
+	}
+	if _, ok := m.Resolve("bar.region-a.gateway.example.com"); ok {
+		t.Errorf("Resolve() expected no cluster for bar")
+	}
+	if _, ok := m.Get("foo.region-a.gateway.example.com"); ok {
+		t.Errorf("Get() expected exact lookup without host templates")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// EndpointIdentitiesAnnotationKey pins the expected identity of endpoints of one upstream cluster, the
+	// value is a json encoded map from endpoint to EndpointIdentity, e.g.
+	// {"https://apiserver-1.example.com:6443":{"spkiSHA256":["<base64>"],"sans":["apiserver-1.example.com"]}}
+	//
+	// The identity is verified at every TLS handshake to the endpoint, including health checks, before
+	// any credential is sent, so a hijacked endpoint name can not receive requests of the cluster.
+	EndpointIdentitiesAnnotationKey = "proxy.kubegateway.io/endpoint-identities"
+)
+
+// EndpointIdentity is the expected identity of an upstream endpoint, all configured items must be satisfied
+type EndpointIdentity struct {
+	// SPKISHA256 are base64 encoded SHA-256 digests of subject public key info, one of them must match
+	// the leaf certificate or a certificate of its verified chains, e.g. the cluster CA
+	SPKISHA256 []string `json:"spkiSHA256,omitempty"`
+	// SANs are DNS names or IP addresses which must all be valid for the leaf certificate
+	SANs []string `json:"sans,omitempty"`
+}
+
+// SPKISHA256Of returns the base64 encoded SHA-256 digest of subject public key info of cert
+func SPKISHA256Of(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Verify verifies certificates presented by endpoint in TLS handshake
+func (id EndpointIdentity) Verify(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("endpoint presents no certificate")
+	}
+	leaf := state.PeerCertificates[0]
+	if len(id.SPKISHA256) > 0 {
+		// certificates other than the leaf are trusted only if they are verified as its issuers
+		candidates := []*x509.Certificate{leaf}
+		for _, chain := range state.VerifiedChains {
+			candidates = append(candidates, chain...)
+		}
+		if !spkiPinned(id.SPKISHA256, candidates) {
+			return fmt.Errorf("certificate of endpoint does not match any pinned public key, leaf spkiSHA256=%s", SPKISHA256Of(leaf))
+		}
+	}
+	for _, san := range id.SANs {
+		if err := leaf.VerifyHostname(san); err != nil {
+			return fmt.Errorf("certificate of endpoint is missing required SAN %q: %v", san, err)
+		}
+	}
+	return nil
+}
+
+func spkiPinned(pins []string, certs []*x509.Certificate) bool {
+	for _, cert := range certs {
+		digest := SPKISHA256Of(cert)
+		for _, pin := range pins {
+			if pin == digest {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseEndpointIdentities parses expected identities of endpoints from annotation value, endpoints must be
+// in servers. Identities of other endpoints would never be verified, so pinning would be silently turned
+// off by a typo or a renamed server.
+func ParseEndpointIdentities(value string, servers sets.String) (map[string]EndpointIdentity, error) {
+	identities := map[string]EndpointIdentity{}
+	if err := json.Unmarshal([]byte(value), &identities); err != nil {
+		return nil, fmt.Errorf("invalid endpoint identities: %v", err)
+	}
+	for endpoint, id := range identities {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Scheme != "https" || len(u.Host) == 0 {
+			return nil, fmt.Errorf("invalid endpoint %q, identity can only be verified for https endpoints", endpoint)
+		}
+		if !servers.Has(endpoint) {
+			return nil, fmt.Errorf("endpoint %q is not in spec.servers", endpoint)
+		}
+		if len(id.SPKISHA256) == 0 && len(id.SANs) == 0 {
+			return nil, fmt.Errorf("empty identity of endpoint %q, spkiSHA256 or sans must be set", endpoint)
+		}
+		for _, pin := range id.SPKISHA256 {
+			if digest, err := base64.StdEncoding.DecodeString(pin); err != nil || len(digest) != sha256.Size {
+				return nil, fmt.Errorf("invalid spkiSHA256 %q of endpoint %q, must be a base64 encoded SHA-256 digest", pin, endpoint)
+			}
+		}
+		for _, san := range id.SANs {
+			if len(san) == 0 {
+				return nil, fmt.Errorf("empty san of endpoint %q", endpoint)
+			}
+		}
+	}
+	return identities, nil
+}
+
+func (c *ClusterInfo) loadEndpointIdentities() map[string]EndpointIdentity {
+	identities, _ := c.currentEndpointIdentities.Load().(map[string]EndpointIdentity)
+	return identities
+}
+
+// verifyEndpointIdentity verifies TLS handshake to endpoint if its identity is pinned
+func (c *ClusterInfo) verifyEndpointIdentity(endpoint string, state tls.ConnectionState) error {
+	id, ok := c.loadEndpointIdentities()[endpoint]
+	if !ok {
+		return nil
+	}
+	if err := id.Verify(state); err != nil {
+		metrics.RecordUpstreamIdentityMismatch(c.Cluster, endpoint)
+		klog.Errorf("[cluster info] refuse to connect to cluster=%q endpoint=%q, identity verification failed: %v", c.Cluster, endpoint, err)
+		return err
+	}
+	return nil
+}
+
+func (c *ClusterInfo) syncEndpointIdentities(annotations map[string]string, servers []proxyv1alpha1.UpstreamClusterServer) error {
+	identities := map[string]EndpointIdentity{}
+	if value := annotations[EndpointIdentitiesAnnotationKey]; len(value) > 0 {
+		endpoints := sets.NewString()
+		for _, s := range servers {
+			endpoints.Insert(s.Endpoint)
+		}
+		var err error
+		identities, err = ParseEndpointIdentities(value, endpoints)
+		if err != nil {
+			return err
+		}
+	}
+	if old := c.loadEndpointIdentities(); !reflect.DeepEqual(old, identities) && (len(old) > 0 || len(identities) > 0) {
+		klog.Infof("[cluster info] cluster=%q update endpoint identities, pinned endpoints=%d", c.Cluster, len(identities))
+	}
+	c.currentEndpointIdentities.Store(identities)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+func TestEndpointIdentity_Verify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{server.Certificate()}}
+	pin := SPKISHA256Of(server.Certificate())
+
+	tests := []struct {
+		name    string
+		id      EndpointIdentity
+		wantErr bool
+	}{
+		{"pinned public key", EndpointIdentity{SPKISHA256: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=", pin}}, false},
+		{"mismatched public key", EndpointIdentity{SPKISHA256: []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}}, true},
+		{"required sans", EndpointIdentity{SANs: []string{"example.com", "127.0.0.1"}}, false},
+		{"missing san", EndpointIdentity{SANs: []string{"example.com", "apiserver.attacker.com"}}, true},
+		{"pin and san", EndpointIdentity{SPKISHA256: []string{pin}, SANs: []string{"example.com"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.id.Verify(state); (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := (EndpointIdentity{SANs: []string{"example.com"}}).Verify(tls.ConnectionState{}); err == nil {
+		t.Errorf("Verify() without peer certificates should fail")
+	}
+}
+
+func TestParseEndpointIdentities(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `{"https://10.0.0.1:6443":{"spkiSHA256":["AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="],"sans":["apiserver.example.com"]}}`, false},
+		{"invalid json", `{"https://10.0.0.1:6443":`, true},
+		{"plain http endpoint", `{"http://10.0.0.1:8080":{"sans":["apiserver.kubegateway.io"]}}`, true},
+		{"empty identity", `{"https://10.0.0.1:6443":{}}`, true},
+		{"invalid pin", `{"https://10.0.0.1:6443":{"spkiSHA256":["not-a-digest"]}}`, true},
+		{"endpoint not in servers", `{"https://10.0.0.2:6443":{"sans":["apiserver.example.com"]}}`, true},
+		{"endpoint with trailing slash", `{"https://10.0.0.1:6443/":{"sans":["apiserver.example.com"]}}`, true},
+	}
+	servers := sets.NewString("https://10.0.0.1:6443")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseEndpointIdentities(tt.value, servers); (err != nil) != tt.wantErr {
+				t.Errorf("ParseEndpointIdentities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_verifyEndpointIdentity(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	c := NewEmptyClusterInfo("test", nil, nil, nil)
+	verify := func(state tls.ConnectionState) error {
+		return c.verifyEndpointIdentity(server.URL, state)
+	}
+	newClient := func() *http.Client {
+		config := &rest.Config{Host: server.URL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}}
+		rt, err := newEndpointTransport(c.Cluster, c.defaults, config, nil, shortRequestTransportProfile(c.defaults.RequestTimeout), verify)
+		if err != nil {
+			t.Fatalf("newEndpointTransport() error = %v", err)
+		}
+		return &http.Client{Transport: rt}
+	}
+
+	// endpoints without pinned identity are not verified
+	resp, err := newClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("request to endpoint without identity failed: %v", err)
+	}
+	resp.Body.Close()
+
+	annotations := map[string]string{
+		EndpointIdentitiesAnnotationKey: `{"` + server.URL + `":{"sans":["apiserver.kubegateway.io"]}}`,
+	}
+	servers := []proxyv1alpha1.UpstreamClusterServer{{Endpoint: server.URL}}
+	if err := c.syncEndpointIdentities(map[string]string{
+		EndpointIdentitiesAnnotationKey: `{"` + server.URL + `/":{"sans":["apiserver.kubegateway.io"]}}`,
+	}, servers); err == nil {
+		t.Fatalf("syncEndpointIdentities() of endpoint not in servers should fail")
+	}
+	if err := c.syncEndpointIdentities(annotations, servers); err != nil {
+		t.Fatalf("syncEndpointIdentities() error = %v", err)
+	}
+	// a new transport makes a new handshake
+	if resp, err := newClient().Get(server.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("request to endpoint with mismatched identity should fail")
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// ErrorLogSamplingAnnotationKey overrides how often error logs of one upstream cluster are written for
+	// each error class, the value is a comma separated list of class=duration pairs, e.g. *=1s,connection_refused=10s.
+	// At most one log of a class is written per duration and others are only counted, * is for classes not
+	// present in value, zero writes every log.
+	ErrorLogSamplingAnnotationKey = "proxy.kubegateway.io/error-log-sampling"
+
+	errorLogSamplingAnyClass = "*"
+)
+
+// defaultErrorLogInterval bounds a flapping endpoint to one log per second of each error class
+const defaultErrorLogInterval = time.Second
+
+// ErrorLogSampling is the minimum interval between two error logs of the same error class
+type ErrorLogSampling struct {
+	// Default is the interval of error classes not present in Classes
+	Default time.Duration
+	// Classes are the intervals of specific error classes
+	Classes map[string]time.Duration
+}
+
+var defaultErrorLogSampling = ErrorLogSampling{Default: defaultErrorLogInterval}
+
+// Interval returns the sampling interval of error class
+func (s ErrorLogSampling) Interval(class string) time.Duration {
+	if interval, ok := s.Classes[class]; ok {
+		return interval
+	}
+	return s.Default
+}
+
+func (s ErrorLogSampling) String() string {
+	pairs := []string{fmt.Sprintf("%s=%v", errorLogSamplingAnyClass, s.Default)}
+	classes := make([]string, 0, len(s.Classes))
+	for class := range s.Classes {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", class, s.Classes[class]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseErrorLogSampling parses error log sampling from annotation value, * not present in value uses default
+func ParseErrorLogSampling(value string) (ErrorLogSampling, error) {
+	sampling := ErrorLogSampling{Default: defaultErrorLogInterval}
+	for _, s := range strings.Split(value, ",") {
+		if len(s) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return sampling, fmt.Errorf("missing value for error log sampling %q", s)
+		}
+		k := strings.TrimSpace(kv[0])
+		if len(k) == 0 {
+			return sampling, fmt.Errorf("missing error class for error log sampling %q", s)
+		}
+		v, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return sampling, fmt.Errorf("invalid value of %s=%s, err: %v", k, kv[1], err)
+		}
+		if v < 0 {
+			return sampling, fmt.Errorf("invalid value of %s=%s, must not be negative", k, kv[1])
+		}
+		if k == errorLogSamplingAnyClass {
+			sampling.Default = v
+			continue
+		}
+		if sampling.Classes == nil {
+			sampling.Classes = map[string]time.Duration{}
+		}
+		sampling.Classes[k] = v
+	}
+	return sampling, nil
+}
+
+// errorLogState is the sampling state of one error class
+type errorLogState struct {
+	mu         sync.Mutex
+	last       time.Time
+	suppressed int64
+}
+
+// ErrorLogSampling returns the current error log sampling of this cluster
+func (c *ClusterInfo) ErrorLogSampling() ErrorLogSampling {
+	if sampling, ok := c.currentErrorLogSampling.Load().(ErrorLogSampling); ok {
+		return sampling
+	}
+	return defaultErrorLogSampling
+}
+
+// SampleErrorLog reports whether an error log of class should be written now, and how many
+// logs of the class were suppressed since the last written one. Suppressed logs are counted
+// by metrics, so a flapping endpoint produces bounded log volume without hiding the error rate.
+func (c *ClusterInfo) SampleErrorLog(class string) (bool, int64) {
+	interval := c.ErrorLogSampling().Interval(class)
+	if interval <= 0 {
+		return true, 0
+	}
+	v, _ := c.errorLogs.LoadOrStore(class, &errorLogState{})
+	state := v.(*errorLogState)
+
+	now := time.Now()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.last.IsZero() && now.Sub(state.last) < interval {
+		state.suppressed++
+		metrics.RecordSuppressedErrorLog(c.Cluster, class)
+		return false, 0
+	}
+	suppressed := state.suppressed
+	state.last = now
+	state.suppressed = 0
+	return true, suppressed
+}
+
+func (c *ClusterInfo) syncErrorLogSampling(annotations map[string]string) error {
+	sampling := defaultErrorLogSampling
+	if value := annotations[ErrorLogSamplingAnnotationKey]; len(value) > 0 {
+		var err error
+		sampling, err = ParseErrorLogSampling(value)
+		if err != nil {
+			return err
+		}
+	}
+	if old := c.ErrorLogSampling(); old.String() != sampling.String() {
+		klog.Infof("[cluster info] cluster=%q update error log sampling, %v", c.Cluster, sampling)
+	}
+	c.currentErrorLogSampling.Store(sampling)
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrorLogSampling(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"classes only", "connection_refused=10s", "*=1s,connection_refused=10s", false},
+		{"override default", "*=5s, timeout=0", "*=5s,timeout=0s", false},
+		{"missing value", "eof", "", true},
+		{"missing class", "=1s", "", true},
+		{"negative", "eof=-1s", "", true},
+		{"invalid duration", "eof=1", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseErrorLogSampling(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseErrorLogSampling() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("ParseErrorLogSampling() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_SampleErrorLog(t *testing.T) {
+	c := NewEmptyClusterInfo("test", nil, nil, nil)
+	annotations := map[string]string{ErrorLogSamplingAnnotationKey: "*=1h,eof=50ms,timeout=0"}
+	if err := c.syncErrorLogSampling(annotations); err != nil {
+		t.Fatalf("syncErrorLogSampling() error = %v", err)
+	}
+
+	if logged, _ := c.SampleErrorLog("connection_refused"); !logged {
+		t.Errorf("SampleErrorLog() first log of class is not written")
+	}
+	for i := 0; i < 3; i++ {
+		if logged, _ := c.SampleErrorLog("connection_refused"); logged {
+			t.Errorf("SampleErrorLog() log is written within interval")
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if logged, suppressed := c.SampleErrorLog("timeout"); !logged || suppressed != 0 {
+			t.Errorf("SampleErrorLog() = %v, %v, want every log written for zero interval", logged, suppressed)
+		}
+	}
+
+	c.SampleErrorLog("eof")
+	c.SampleErrorLog("eof")
+	c.SampleErrorLog("eof")
+	time.Sleep(60 * time.Millisecond)
+	if logged, suppressed := c.SampleErrorLog("eof"); !logged || suppressed != 2 {
+		t.Errorf("SampleErrorLog() = %v, %v after interval, want true, 2", logged, suppressed)
+	}
+}
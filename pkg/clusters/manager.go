@@ -31,6 +31,10 @@ type EndpointHealthCheck func(*EndpointInfo) (done bool)
 type Manager interface {
 	Add(*ClusterInfo)
 	Get(name string) (*ClusterInfo, bool)
+	// Resolve returns the cluster serving requests to hostname, which is the cluster name, the cluster
+	// of a virtual cluster hostname or the cluster name resolved by host templates
+	Resolve(hostname string) (*ClusterInfo, bool)
+	List() []*ClusterInfo
 	Delete(name string)
 	DeleteAll()
 
@@ -58,6 +62,35 @@ func (m *manager) Get(name string) (*ClusterInfo, bool) {
 	return v.(*ClusterInfo), true
 }
 
+func (m *manager) Resolve(hostname string) (*ClusterInfo, bool) {
+	hostname = strings.ToLower(hostname)
+	if cluster, ok := m.Get(hostname); ok {
+		return cluster, true
+	}
+	// hostnames of virtual clusters are resolved to shared upstream clusters, and hostnames
+	// of wildcard certificates are resolved to cluster names by templates
+	var resolved string
+	var matched bool
+	if vc, isVirtual := LookupVirtualCluster(hostname); isVirtual {
+		resolved, matched = vc.Cluster, true
+	} else {
+		resolved, matched = resolveHostTemplates(DefaultHostTemplates, hostname)
+	}
+	if !matched {
+		return nil, false
+	}
+	return m.Get(resolved)
+}
+
+func (m *manager) List() []*ClusterInfo {
+	result := []*ClusterInfo{}
+	m.clusters.Range(func(key, value interface{}) bool {
+		result = append(result, value.(*ClusterInfo))
+		return true
+	})
+	return result
+}
+
 func (m *manager) Add(cluster *ClusterInfo) {
 	if cluster == nil {
 		return
@@ -76,14 +109,24 @@ func (m *manager) Delete(name string) {
 	// close all requests to this cluster
 	cluster := v.(*ClusterInfo)
 	cluster.Stop()
+	m.releaseStaleCluster(name)
 	klog.V(1).Infof("[cluster manager] cluster info is deleted, cluster=%q", cluster.Cluster)
 }
 
+// releaseStaleCluster releases state of deleted cluster unless it is added again
+func (m *manager) releaseStaleCluster(name string) {
+	releaseStaleCluster(name, func() bool {
+		_, ok := m.clusters.Load(name)
+		return ok
+	})
+}
+
 func (m *manager) DeleteAll() {
 	klog.V(1).Infof("[cluster manager] delete all cluster info")
 	m.clusters.Range(func(key, value interface{}) bool {
 		cluster := value.(*ClusterInfo)
 		cluster.Stop()
+		m.releaseStaleCluster(cluster.Cluster)
 		return true
 	})
 	m.clusters = sync.Map{}
@@ -21,12 +21,20 @@ import (
 )
 
 func MatchPolicies(requestAttributes authorizer.Attributes, policies []proxyv1alpha1.DispatchPolicy) *proxyv1alpha1.DispatchPolicy {
+	if i := matchPolicyIndex(requestAttributes, policies); i >= 0 {
+		return &policies[i]
+	}
+	return nil
+}
+
+// matchPolicyIndex returns the index of the first policy matching requestAttributes, -1 if none matches
+func matchPolicyIndex(requestAttributes authorizer.Attributes, policies []proxyv1alpha1.DispatchPolicy) int {
 	for i := range policies {
 		if PolicyMatches(requestAttributes, &policies[i]) {
-			return &policies[i]
+			return i
 		}
 	}
-	return nil
+	return -1
 }
 
 func PolicyMatches(requestAttributes authorizer.Attributes, policy *proxyv1alpha1.DispatchPolicy) bool {
@@ -0,0 +1,96 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"sync/atomic"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/notification"
+)
+
+// availability of a cluster tracked for notifications
+const (
+	// the cluster never had ready endpoints, e.g. it is just created
+	availabilityUnknown int32 = iota
+	availabilityAvailable
+	availabilityUnavailable
+)
+
+// observeAvailability notifies when the cluster loses its last ready endpoint or gets one back,
+// a cluster which never had ready endpoints is not reported, so startup is not noisy.
+func (c *ClusterInfo) observeAvailability() {
+	ready := false
+	c.Endpoints.Range(func(name string, info *EndpointInfo) bool {
+		ready = info.IsReady()
+		return !ready
+	})
+	if ready {
+		if atomic.SwapInt32(&c.availability, availabilityAvailable) == availabilityUnavailable {
+			notification.Notify(notification.Event{Type: notification.EventClusterRecovered, Cluster: c.Cluster})
+		}
+		return
+	}
+	if atomic.CompareAndSwapInt32(&c.availability, availabilityAvailable, availabilityUnavailable) {
+		notification.Notify(notification.Event{
+			Type:    notification.EventClusterUnavailable,
+			Cluster: c.Cluster,
+			Message: "no ready endpoints",
+		})
+	}
+}
+
+// notifyCircuitBreaker notifies when feature gate DenyAllRequests of the cluster is changed
+func (c *ClusterInfo) notifyCircuitBreaker(wasOpen bool) {
+	open := c.FeatureEnabled(features.DenyAllRequests)
+	if open == wasOpen {
+		return
+	}
+	event := notification.Event{
+		Type:    notification.EventCircuitBreakerClosed,
+		Cluster: c.Cluster,
+		Reason:  string(features.DenyAllRequests),
+	}
+	if open {
+		event.Type = notification.EventCircuitBreakerOpened
+	}
+	notification.Notify(event)
+}
+
+// notifyHealthChange notifies when a healthy endpoint becomes unhealthy, and when it is healthy
+// again, endpoints becoming healthy for the first time are not reported.
+func (e *EndpointInfo) notifyHealthChange(t endpointTransition) {
+	if !t.Healthy {
+		if atomic.CompareAndSwapInt32(&e.notifiedUnhealthy, 0, 1) {
+			notification.Notify(notification.Event{
+				Type:     notification.EventEndpointUnhealthy,
+				Cluster:  e.Cluster,
+				Endpoint: e.Endpoint,
+				Sequence: t.Seq,
+				Reason:   t.Reason,
+				Message:  t.Message,
+			})
+		}
+		return
+	}
+	if atomic.CompareAndSwapInt32(&e.notifiedUnhealthy, 1, 0) {
+		notification.Notify(notification.Event{
+			Type:     notification.EventEndpointRecovered,
+			Cluster:  e.Cluster,
+			Endpoint: e.Endpoint,
+			Sequence: t.Seq,
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/notification"
+)
+
+type recordingSink struct {
+	events []notification.Event
+}
+
+func (s *recordingSink) Notify(event notification.Event) {
+	s.events = append(s.events, event)
+}
+
+func (s *recordingSink) types() []string {
+	types := []string{}
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	s.events = nil
+	return types
+}
+
+func TestNotifications(t *testing.T) {
+	sink := &recordingSink{}
+	notification.Default = sink
+	defer func() { notification.Default = nil }()
+
+	c := NewEmptyClusterInfo("test", nil, nil, nil)
+	ep1 := &EndpointInfo{Cluster: c.Cluster, Endpoint: "https://1.1.1.1", onStatusChange: c.observeAvailability}
+	ep2 := &EndpointInfo{Cluster: c.Cluster, Endpoint: "https://2.2.2.2", onStatusChange: c.observeAvailability}
+	c.Endpoints.Store(ep1.Endpoint, ep1)
+	c.Endpoints.Store(ep2.Endpoint, ep2)
+
+	// endpoints failing their first health checks are not reported
+	ep1.UpdateStatus(false, "Timeout", "")
+	if got := sink.types(); len(got) != 0 {
+		t.Errorf("initial unhealthy notified %v, want none", got)
+	}
+
+	ep1.UpdateStatus(true, "", "")
+	ep2.UpdateStatus(true, "", "")
+	if got := sink.types(); len(got) != 0 {
+		t.Errorf("initial healthy notified %v, want none", got)
+	}
+
+	ep1.UpdateStatus(false, "Timeout", "request timeout")
+	if got, want := sink.types(), []string{notification.EventEndpointUnhealthy}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+	ep2.UpdateStatus(false, "Timeout", "request timeout")
+	if got, want := sink.types(), []string{notification.EventEndpointUnhealthy, notification.EventClusterUnavailable}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+	ep1.UpdateStatus(false, "Timeout", "request timeout")
+	if got := sink.types(); len(got) != 0 {
+		t.Errorf("unchanged status notified %v, want none", got)
+	}
+	ep1.UpdateStatus(true, "", "")
+	if got, want := sink.types(), []string{notification.EventEndpointRecovered, notification.EventClusterRecovered}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+
+	if err := c.syncFeatureGate(map[string]string{features.FeatureGateAnnotationKey: "DenyAllRequests=true"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.types(), []string{notification.EventCircuitBreakerOpened}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+	if err := c.syncFeatureGate(map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.types(), []string{notification.EventCircuitBreakerClosed}; !reflect.DeepEqual(got, want) {
+		t.Errorf("notified %v, want %v", got, want)
+	}
+}
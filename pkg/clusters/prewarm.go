@@ -0,0 +1,105 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// MaxPrewarmConnections is the maximum connections pre-warmed for one endpoint, more connections
+	// are closed by the idle connection pool of upgrade transport immediately.
+	MaxPrewarmConnections = 25
+
+	prewarmTimeout = 10 * time.Second
+	prewarmPath    = "/healthz"
+
+	// results of pre-warming in metrics
+	prewarmResultSuccess = "success"
+	prewarmResultFailure = "failure"
+)
+
+// prewarm dials and TLS handshakes connections to the endpoint in advance, so the first client
+// requests do not pay cold-start latency. HTTP/2 transports of short and long-running requests
+// multiplex all requests on one connection, so each of them is warmed with one connection and n
+// connections are kept idle in the HTTP/1.1 pool of upgrade requests, e.g. exec and port-forward.
+func (e *EndpointInfo) prewarm(n int) {
+	if n <= 0 {
+		return
+	}
+	if n > MaxPrewarmConnections {
+		n = MaxPrewarmConnections
+	}
+	ctx, cancel := context.WithTimeout(e.ctx, prewarmTimeout)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	warmed := 0
+	warm := func(rt http.RoundTripper) {
+		defer wg.Done()
+		if err := prewarmConnection(ctx, rt, e.Endpoint); err != nil {
+			klog.V(2).Infof("[endpoint info] failed to pre-warm connection, cluster=%q, endpoint=%q, err: %v", e.Cluster, e.Endpoint, err)
+			metrics.RecordUpstreamPrewarm(e.Cluster, prewarmResultFailure)
+			return
+		}
+		metrics.RecordUpstreamPrewarm(e.Cluster, prewarmResultSuccess)
+		mu.Lock()
+		warmed++
+		mu.Unlock()
+	}
+	for _, rt := range []http.RoundTripper{e.ProxyTransport, e.LongRunningTransport} {
+		if rt != nil {
+			wg.Add(1)
+			go warm(rt)
+		}
+	}
+	if e.PorxyUpgradeTransport != nil {
+		// concurrent requests force the pool to open one connection for each of them
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go warm(e.PorxyUpgradeTransport)
+		}
+	}
+	wg.Wait()
+	klog.V(2).Infof("[endpoint info] pre-warmed %d connections, cluster=%q, endpoint=%q, duration=%v", warmed, e.Cluster, e.Endpoint, time.Since(start))
+}
+
+// prewarmConnection sends a cheap request through rt, the connection is returned to the idle pool
+// of rt after the response body is drained. The response status does not matter, e.g. 401 of a
+// passthrough transport still proves the connection is established.
+func prewarmConnection(ctx context.Context, rt http.RoundTripper, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+prewarmPath, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testUpgradeRoundTripper struct {
+	http.RoundTripper
+}
+
+func (rt *testUpgradeRoundTripper) WrapRequest(req *http.Request) (*http.Request, error) {
+	return req, nil
+}
+
+func TestEndpointInfo_prewarm(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// hold requests for a while, so concurrent requests can not share connections
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	upgrade := &http.Transport{MaxIdleConnsPerHost: MaxPrewarmConnections}
+	defer upgrade.CloseIdleConnections()
+	e := &EndpointInfo{
+		ctx:                   context.Background(),
+		Cluster:               "test",
+		Endpoint:              server.URL,
+		PorxyUpgradeTransport: &testUpgradeRoundTripper{RoundTripper: upgrade},
+	}
+	e.prewarm(3)
+	if got := atomic.LoadInt32(&conns); got != 3 {
+		t.Errorf("prewarm() established %v connections, want 3", got)
+	}
+
+	// warmed connections are reused by later requests
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := e.PorxyUpgradeTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	resp.Body.Close()
+	if got := atomic.LoadInt32(&conns); got != 3 {
+		t.Errorf("request after prewarm() established new connection, connections = %v", got)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// RequestPriority orders requests waiting for cluster resource budget, requests of higher priority
+// are always admitted before requests of lower priority.
+type RequestPriority int
+
+const (
+	RequestPriorityLow RequestPriority = iota
+	RequestPriorityNormal
+	RequestPriorityHigh
+
+	numRequestPriorities = 3
+)
+
+func (p RequestPriority) String() string {
+	switch p {
+	case RequestPriorityLow:
+		return "low"
+	case RequestPriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParseRequestPriority parses priority from high, normal or low
+func ParseRequestPriority(value string) (RequestPriority, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "low":
+		return RequestPriorityLow, nil
+	case "normal":
+		return RequestPriorityNormal, nil
+	case "high":
+		return RequestPriorityHigh, nil
+	default:
+		return RequestPriorityNormal, fmt.Errorf("invalid request priority %q, must be one of high, normal, low", value)
+	}
+}
+
+// Acquire acquires a holder of l, it waits in the queue of priority for at most timeout if l is full.
+// A released holder is handed off to the first waiter of the highest priority.
+func (l *budgetLimiter) Acquire(ctx context.Context, priority RequestPriority, timeout time.Duration) bool {
+	if atomic.LoadInt32(&l.waiting) == 0 && l.TryAcquire() {
+		return true
+	}
+	if timeout <= 0 {
+		return false
+	}
+	if priority < RequestPriorityLow || priority > RequestPriorityHigh {
+		priority = RequestPriorityNormal
+	}
+
+	l.mu.Lock()
+	atomic.AddInt32(&l.waiting, 1)
+	// retry with lock held, so that a concurrent Release either sees this waiter or frees a holder for it
+	if l.TryAcquire() {
+		atomic.AddInt32(&l.waiting, -1)
+		l.mu.Unlock()
+		return true
+	}
+	ready := make(chan struct{})
+	l.waiters[priority] = append(l.waiters[priority], ready)
+	l.observeQueueLocked(priority)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	queue := l.waiters[priority]
+	for i := range queue {
+		if queue[i] == ready {
+			l.waiters[priority] = append(queue[:i], queue[i+1:]...)
+			atomic.AddInt32(&l.waiting, -1)
+			l.observeQueueLocked(priority)
+			return false
+		}
+	}
+	// handed off concurrently
+	return true
+}
+
+// dispatchLocked hands off free holders to waiters, l.mu must be held
+func (l *budgetLimiter) dispatchLocked() {
+	for atomic.LoadInt32(&l.waiting) > 0 && l.TryAcquire() {
+		if !l.handOffLocked() {
+			atomic.AddInt32(&l.current, -1)
+			return
+		}
+	}
+}
+
+// handOffLocked transfers one holder to the first waiter of the highest priority, l.mu must be held
+func (l *budgetLimiter) handOffLocked() bool {
+	for p := numRequestPriorities - 1; p >= 0; p-- {
+		if len(l.waiters[p]) == 0 {
+			continue
+		}
+		ready := l.waiters[p][0]
+		l.waiters[p] = l.waiters[p][1:]
+		atomic.AddInt32(&l.waiting, -1)
+		l.observeQueueLocked(RequestPriority(p))
+		close(ready)
+		return true
+	}
+	return false
+}
+
+func (l *budgetLimiter) observeQueueLocked(priority RequestPriority) {
+	if l.queueChanged != nil {
+		l.queueChanged(priority, len(l.waiters[priority]))
+	}
+}
+
+// AcquireRequestBudget acquires a slot of cluster inflight requests budget, it waits in the queue of priority
+// for at most timeout if the budget is exhausted. ReleaseRequestBudget must be called if it returns true.
+func (c *ClusterInfo) AcquireRequestBudget(ctx context.Context, priority RequestPriority, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return c.TryAcquireRequestBudget()
+	}
+	start := time.Now()
+	admitted := c.requestBudget.Acquire(ctx, priority, timeout)
+	if wait := time.Since(start); wait > time.Millisecond || !admitted {
+		metrics.RecordClusterBudgetQueued(c.Cluster, priority.String(), admitted, wait)
+	}
+	if !admitted {
+		metrics.RecordClusterBudgetRejected(c.Cluster, budgetRequests)
+		return false
+	}
+	metrics.RecordClusterBudgetAcquired(c.Cluster, budgetRequests)
+	return true
+}
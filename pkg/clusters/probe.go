@@ -0,0 +1,154 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// probe stages
+	ProbeStageTCP  = "tcp"
+	ProbeStageTLS  = "tls"
+	ProbeStageHTTP = "http"
+)
+
+// ProbeResult is the result of a blackbox probe from this gateway replica to an endpoint
+type ProbeResult struct {
+	Reachable bool
+	// FailedStage is the first failed stage, one of tcp, tls and http
+	FailedStage string
+	Message     string
+	// LatencyMilliseconds is the total duration of all stages
+	LatencyMilliseconds int64
+	LastProbeTime       metav1.Time
+}
+
+// Probe verifies TCP, TLS and HTTP reachability to the endpoint step by step over one fresh connection,
+// so the failed stage tells whether the endpoint is partitioned from this replica or just unhealthy.
+// Result is saved and can be got from LastProbeResult.
+func (e *EndpointInfo) Probe(ctx context.Context, timeout time.Duration) ProbeResult {
+	start := time.Now()
+	result := ProbeResult{LastProbeTime: metav1.NewTime(start)}
+	stage, err := e.probe(ctx, timeout)
+	result.LatencyMilliseconds = time.Since(start).Milliseconds()
+	if err != nil {
+		result.FailedStage = stage
+		result.Message = err.Error()
+	} else {
+		result.Reachable = true
+	}
+	e.probeResult.Store(result)
+	return result
+}
+
+// LastProbeResult returns the result of last blackbox probe
+func (e *EndpointInfo) LastProbeResult() (ProbeResult, bool) {
+	result, ok := e.probeResult.Load().(ProbeResult)
+	return result, ok
+}
+
+func (e *EndpointInfo) probe(ctx context.Context, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := url.Parse(e.Endpoint)
+	if err != nil {
+		return ProbeStageTCP, err
+	}
+	host := u.Host
+	if len(u.Port()) == 0 {
+		if u.Scheme == "http" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return ProbeStageTCP, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) //nolint
+	}
+
+	if u.Scheme != "http" {
+		tlsConfig, err := rest.TLSConfigFor(e.healthCheckConfig)
+		if err != nil {
+			return ProbeStageTLS, err
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		if len(tlsConfig.ServerName) == 0 {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		tlsConfig.VerifyConnection = e.verifyIdentity
+		// the http stage writes HTTP/1.1 over this connection
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return ProbeStageTLS, err
+		}
+		conn = tlsConn
+	}
+
+	// http stage is sent over the probed connection instead of the pooled transport of endpoint, which
+	// may reuse a connection established before the partition. It is authenticated with gateway
+	// credential like health checks because anonymous requests to /healthz may be forbidden.
+	rt, err := rest.HTTPWrappersForConfig(e.healthCheckConfig, &probeConnRoundTripper{conn: conn})
+	if err != nil {
+		return ProbeStageHTTP, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(e.Endpoint, "/")+"/healthz", nil)
+	if err != nil {
+		return ProbeStageHTTP, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return ProbeStageHTTP, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ProbeStageHTTP, fmt.Errorf("request %s/healthz, got response code %v", e.Endpoint, resp.StatusCode)
+	}
+	return "", nil
+}
+
+// probeConnRoundTripper sends one request over conn and never reuses or dials connections
+type probeConnRoundTripper struct {
+	conn net.Conn
+}
+
+func (rt *probeConnRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Close = true
+	if err := req.Write(rt.conn); err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(bufio.NewReader(rt.conn), req)
+}
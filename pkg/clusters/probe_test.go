@@ -0,0 +1,71 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestEndpointInfo_Probe(t *testing.T) {
+	var conns, healthy int32 = 0, 1
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || r.Header.Get("Authorization") != "Bearer gateway" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok")) //nolint
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	e := &EndpointInfo{
+		Endpoint:          server.URL,
+		healthCheckConfig: &rest.Config{Host: server.URL, BearerToken: "gateway"},
+	}
+	for i := 0; i < 2; i++ {
+		if result := e.Probe(context.TODO(), time.Second); !result.Reachable {
+			t.Fatalf("Probe() got unreachable result %+v", result)
+		}
+	}
+	// every probe verifies reachability with a fresh connection
+	if got := atomic.LoadInt32(&conns); got != 2 {
+		t.Errorf("Probe() used %v connections, want 2", got)
+	}
+
+	atomic.StoreInt32(&healthy, 0)
+	if result := e.Probe(context.TODO(), time.Second); result.Reachable || result.FailedStage != ProbeStageHTTP {
+		t.Errorf("Probe() got result %+v, want failed stage %v", result, ProbeStageHTTP)
+	}
+	if result, ok := e.LastProbeResult(); !ok || result.FailedStage != ProbeStageHTTP {
+		t.Errorf("LastProbeResult() got %+v, want failed stage %v", result, ProbeStageHTTP)
+	}
+}
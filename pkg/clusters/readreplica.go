@@ -0,0 +1,212 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// ReadReplicasAnnotationKey splits read and write traffic of one upstream cluster, the value is a comma
+	// separated list of key=value pairs, e.g. readers=https://10.0.0.4:6443;https://10.0.0.5:6443,readYourWrites=5s
+	//
+	// readers are endpoints of spec.servers which only serve reads, e.g. apiservers backed by etcd learners or
+	// a read cache. get, list and watch requests are routed to readers and fall back to writers if none of the
+	// readers is ready, other requests are never routed to readers. readYourWrites pins reads of a user to
+	// writers for the duration after the user's last write, so the user is not confused by stale readers.
+	ReadReplicasAnnotationKey = "proxy.kubegateway.io/read-replicas"
+
+	readReplicasReaders        = "readers"
+	readReplicasReadYourWrites = "readYourWrites"
+
+	// MaxReadYourWritesWindow is the maximum readYourWrites window
+	MaxReadYourWritesWindow = time.Hour
+
+	// recentWritersSize bounds the number of users whose last write is remembered per cluster
+	recentWritersSize = 10000
+
+	// route targets used in metrics
+	readReplicaTargetReader = "reader"
+	readReplicaTargetWriter = "writer"
+	readReplicaTargetPinned = "pinned_writer"
+)
+
+// ReadReplicas are readers split from writers of a cluster
+type ReadReplicas struct {
+	Readers []string
+	// ReadYourWritesWindow is the duration reads of a user are pinned to writers after the user's last write
+	ReadYourWritesWindow time.Duration
+}
+
+// ParseReadReplicas parses read replicas from annotation value, readers must be in servers and
+// at least one of servers must be left as writer.
+func ParseReadReplicas(value string, servers sets.String) (ReadReplicas, error) {
+	replicas := ReadReplicas{}
+	for _, s := range strings.Split(value, ",") {
+		if len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return replicas, fmt.Errorf("missing value for read replicas %q", s)
+		}
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch k {
+		case readReplicasReaders:
+			for _, ep := range strings.Split(v, ";") {
+				ep = strings.TrimSpace(ep)
+				if len(ep) == 0 {
+					continue
+				}
+				if !servers.Has(ep) {
+					return replicas, fmt.Errorf("reader %q is not in spec.servers", ep)
+				}
+				replicas.Readers = append(replicas.Readers, ep)
+			}
+		case readReplicasReadYourWrites:
+			window, err := time.ParseDuration(v)
+			if err != nil {
+				return replicas, fmt.Errorf("invalid value of %s=%s, err: %v", k, v, err)
+			}
+			if window < 0 || window > MaxReadYourWritesWindow {
+				return replicas, fmt.Errorf("invalid value of %s=%s, must be between 0 and %v", k, v, MaxReadYourWritesWindow)
+			}
+			replicas.ReadYourWritesWindow = window
+		default:
+			return replicas, fmt.Errorf("unrecognized read replicas key %q", k)
+		}
+	}
+	if len(replicas.Readers) == 0 {
+		return replicas, fmt.Errorf("missing %s of read replicas", readReplicasReaders)
+	}
+	if sets.NewString(replicas.Readers...).IsSuperset(servers) {
+		return replicas, fmt.Errorf("all servers are readers, at least one server must be left as writer")
+	}
+	return replicas, nil
+}
+
+// readWriteSplit is the read replicas view of a routing snapshot
+type readWriteSplit struct {
+	readers        sets.String
+	readYourWrites time.Duration
+}
+
+// route returns the upstreams a request is routed to and the fallback if none of them is ready,
+// upstreams without readers are returned as is.
+func (s readWriteSplit) route(c *ClusterInfo, upstreams []string, readOnly bool, u user.Info) ([]string, []string) {
+	if len(s.readers) == 0 {
+		return upstreams, nil
+	}
+	readers, writers := s.split(upstreams)
+	if len(readers) == 0 || len(writers) == 0 {
+		// subsets of only readers or only writers are routed as configured
+		return upstreams, nil
+	}
+
+	name := ""
+	if u != nil {
+		name = u.GetName()
+	}
+	if !readOnly {
+		if s.readYourWrites > 0 && len(name) > 0 {
+			c.recentWriters.Add(name, struct{}{}, s.readYourWrites)
+		}
+		metrics.RecordReadReplicaRoute(c.Cluster, readReplicaTargetWriter)
+		return writers, nil
+	}
+	if s.readYourWrites > 0 && len(name) > 0 {
+		if _, ok := c.recentWriters.Get(name); ok {
+			metrics.RecordReadReplicaRoute(c.Cluster, readReplicaTargetPinned)
+			return writers, nil
+		}
+	}
+	metrics.RecordReadReplicaRoute(c.Cluster, readReplicaTargetReader)
+	return readers, writers
+}
+
+// split returns readers and writers of upstreams
+func (s readWriteSplit) split(upstreams []string) ([]string, []string) {
+	readers, writers := []string{}, []string{}
+	for _, ep := range upstreams {
+		if s.readers.Has(ep) {
+			readers = append(readers, ep)
+		} else {
+			writers = append(writers, ep)
+		}
+	}
+	return readers, writers
+}
+
+// addReadWriteSplitCounters preallocates round robin counters of readers and writers of all known upstreams
+func (s *routingSnapshot) addReadWriteSplitCounters() {
+	if len(s.readWriteSplit.readers) == 0 {
+		return
+	}
+	upstreams := [][]string{s.servers}
+	for i := range s.policies {
+		upstreams = append(upstreams, s.policies[i].UpstreamSubset)
+	}
+	for i := range s.userAgentRules {
+		upstreams = append(upstreams, s.userAgentRules[i].UpstreamSubset)
+	}
+	for i := range s.expressionRules {
+		upstreams = append(upstreams, s.expressionRules[i].UpstreamSubset)
+	}
+	for _, u := range upstreams {
+		readers, writers := s.readWriteSplit.split(u)
+		s.addCounter(readers)
+		s.addCounter(writers)
+	}
+}
+
+func (c *ClusterInfo) loadReadReplicas() ReadReplicas {
+	replicas, _ := c.currentReadReplicas.Load().(ReadReplicas)
+	return replicas
+}
+
+func (c *ClusterInfo) syncReadReplicas(annotations map[string]string, servers []proxyv1alpha1.UpstreamClusterServer) error {
+	replicas := ReadReplicas{}
+	if value := annotations[ReadReplicasAnnotationKey]; len(value) > 0 {
+		endpoints := sets.NewString()
+		for _, s := range servers {
+			endpoints.Insert(s.Endpoint)
+		}
+		var err error
+		replicas, err = ParseReadReplicas(value, endpoints)
+		if err != nil {
+			return err
+		}
+	}
+	if old := c.loadReadReplicas(); !reflect.DeepEqual(old, replicas) {
+		klog.Infof("[cluster info] cluster=%q update read replicas, readers=%v readYourWrites=%v", c.Cluster, replicas.Readers, replicas.ReadYourWritesWindow)
+	}
+	c.currentReadReplicas.Store(replicas)
+	return nil
+}
+
+func newRecentWriters() *cache.LRUExpireCache {
+	return cache.NewLRUExpireCache(recentWritersSize)
+}
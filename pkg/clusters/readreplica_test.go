@@ -0,0 +1,100 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestParseReadReplicas(t *testing.T) {
+	servers := sets.NewString("https://10.0.0.1:6443", "https://10.0.0.4:6443", "https://10.0.0.5:6443")
+	tests := []struct {
+		name    string
+		value   string
+		want    ReadReplicas
+		wantErr bool
+	}{
+		{"readers", "readers=https://10.0.0.4:6443;https://10.0.0.5:6443", ReadReplicas{Readers: []string{"https://10.0.0.4:6443", "https://10.0.0.5:6443"}}, false},
+		{"read your writes", "readers=https://10.0.0.4:6443,readYourWrites=5s", ReadReplicas{Readers: []string{"https://10.0.0.4:6443"}, ReadYourWritesWindow: 5 * time.Second}, false},
+		{"unknown reader", "readers=https://10.0.0.9:6443", ReadReplicas{}, true},
+		{"no writer", "readers=https://10.0.0.1:6443;https://10.0.0.4:6443;https://10.0.0.5:6443", ReadReplicas{}, true},
+		{"missing readers", "readYourWrites=5s", ReadReplicas{}, true},
+		{"window too long", "readers=https://10.0.0.4:6443,readYourWrites=2h", ReadReplicas{}, true},
+		{"unknown key", "readers=https://10.0.0.4:6443,lag=1s", ReadReplicas{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReadReplicas(tt.value, servers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReadReplicas() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (!sets.NewString(got.Readers...).Equal(sets.NewString(tt.want.Readers...)) || got.ReadYourWritesWindow != tt.want.ReadYourWritesWindow) {
+				t.Errorf("ParseReadReplicas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_MatchRequest_ReadReplicas(t *testing.T) {
+	writer, reader := "https://127.0.0.1:443", "https://127.0.0.2:443"
+	cluster, info := newTestRoutingCluster(t, writer, reader)
+	defer info.Stop()
+	cluster.Annotations = map[string]string{ReadReplicasAnnotationKey: "readers=" + reader + ",readYourWrites=1m"}
+	if err := info.Sync(cluster); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	pick := func(verb, name string) string {
+		picker, err := info.MatchRequest(authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: name},
+			Verb:            verb,
+			Resource:        "pods",
+			ResourceRequest: true,
+		}, "")
+		if err != nil {
+			t.Fatalf("MatchRequest() error = %v", err)
+		}
+		ep, err := picker.Pop()
+		if err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+		return ep.Endpoint
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := pick("list", "alice"); got != reader {
+			t.Errorf("list routed to %v, want reader %v", got, reader)
+		}
+		if got := pick("create", "bob"); got != writer {
+			t.Errorf("create routed to %v, want writer %v", got, writer)
+		}
+	}
+	// reads of bob are pinned to writer after his write
+	if got := pick("watch", "bob"); got != writer {
+		t.Errorf("watch after write routed to %v, want writer %v", got, writer)
+	}
+
+	// reads fall back to writers if no reader is ready
+	e, _ := info.Endpoints.Load(reader)
+	e.UpdateStatus(false, "Timeout", "")
+	if got := pick("get", "alice"); got != writer {
+		t.Errorf("get routed to %v when reader is unready, want writer %v", got, writer)
+	}
+}
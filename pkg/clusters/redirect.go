@@ -0,0 +1,98 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	// RedirectPolicyAnnotationKey overrides the default redirect policy for one upstream cluster,
+	// the value is one of PassThrough, Rewrite and Follow.
+	RedirectPolicyAnnotationKey = "proxy.kubegateway.io/redirect-policy"
+)
+
+// RedirectPolicy describes how gateway handles 3xx responses from upstream endpoints
+type RedirectPolicy string
+
+const (
+	// RedirectPolicyPassThrough returns redirects to clients untouched
+	RedirectPolicyPassThrough RedirectPolicy = "PassThrough"
+	// RedirectPolicyRewrite rewrites Location headers pointing to an endpoint of the cluster
+	// back to the gateway host requested by client, so internal addresses never leak to clients
+	RedirectPolicyRewrite RedirectPolicy = "Rewrite"
+	// RedirectPolicyFollow follows redirects within the same endpoint for replayable requests,
+	// responses which can not be followed are rewritten as RedirectPolicyRewrite does
+	RedirectPolicyFollow RedirectPolicy = "Follow"
+)
+
+// ParseRedirectPolicy parses policy case-insensitively
+func ParseRedirectPolicy(value string) (RedirectPolicy, error) {
+	for _, p := range []RedirectPolicy{RedirectPolicyPassThrough, RedirectPolicyRewrite, RedirectPolicyFollow} {
+		if strings.EqualFold(value, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown redirect policy %q, must be one of %s, %s and %s", value, RedirectPolicyPassThrough, RedirectPolicyRewrite, RedirectPolicyFollow)
+}
+
+// RedirectPolicy returns the current redirect policy of this cluster, the annotation overrides the
+// default of gateway. Redirects are passed through unless Rewrite or Follow is opted in.
+func (c *ClusterInfo) RedirectPolicy() RedirectPolicy {
+	if p, ok := c.currentRedirectPolicy.Load().(RedirectPolicy); ok && len(p) > 0 {
+		return p
+	}
+	if c.defaults == nil {
+		return RedirectPolicyPassThrough
+	}
+	return c.defaults.RedirectPolicy
+}
+
+func (c *ClusterInfo) syncRedirectPolicy(annotations map[string]string) error {
+	var policy RedirectPolicy
+	if value := annotations[RedirectPolicyAnnotationKey]; len(value) > 0 {
+		var err error
+		policy, err = ParseRedirectPolicy(value)
+		if err != nil {
+			return err
+		}
+	}
+	if old, _ := c.currentRedirectPolicy.Load().(RedirectPolicy); old != policy {
+		klog.Infof("[cluster info] cluster=%q update redirect policy, override=%q", c.Cluster, policy)
+	}
+	c.currentRedirectPolicy.Store(policy)
+	return nil
+}
+
+// IsEndpointURL returns true if u is an absolute url pointing to one of the endpoints of this cluster
+func (c *ClusterInfo) IsEndpointURL(u *url.URL) bool {
+	if len(u.Host) == 0 {
+		return false
+	}
+	for _, name := range c.AllEndpoints() {
+		ep, err := url.Parse(name)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(ep.Host, u.Host) {
+			return true
+		}
+	}
+	return false
+}
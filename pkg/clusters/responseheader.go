@@ -0,0 +1,190 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// reasons of invalid upstream response headers used in metrics
+	invalidResponseHeaderTooLarge  = "too_large"
+	invalidResponseHeaderMalformed = "malformed"
+
+	// headerFieldOverhead is the per field overhead of header list size defined by RFC 7540 section 6.5.2
+	headerFieldOverhead = 32
+)
+
+var (
+	ErrResponseHeaderTooLarge  = errors.New("upstream response headers too large")
+	ErrMalformedResponseHeader = errors.New("malformed upstream response headers")
+
+	// messages of errors returned by net/http and http2 transports, they are not exported as typed errors
+	responseHeaderTooLargeMessages = []string{
+		"server response headers exceeded",
+		"response header list larger than advertised limit",
+	}
+	malformedResponseHeaderMessages = []string{
+		"malformed HTTP response",
+		"malformed HTTP status code",
+		"malformed MIME header",
+		"invalid header field",
+		"invalid pseudo-header",
+		"missing status pseudo header",
+		"malformed response from server",
+	}
+)
+
+// ResponseHeaderPolicy limits the response headers and trailers received from upstream endpoints
+type ResponseHeaderPolicy struct {
+	// MaxHeaderBytes is the maximum size of response headers, zero means the default limit of
+	// transports (10MB). For HTTP/2 responses, the size is computed as header list size of RFC 7540.
+	MaxHeaderBytes int64
+	// Trailers are canonical names of response trailers propagated to clients,
+	// nil means all trailers are propagated and empty means all trailers are dropped.
+	Trailers sets.String
+}
+
+// propagateAllTrailers returns true if upstream trailers are passed through without filtering
+func (p ResponseHeaderPolicy) propagateAllTrailers() bool {
+	return p.Trailers == nil
+}
+
+// responseHeaderPolicyRoundTripper enforces ResponseHeaderPolicy on responses of base round tripper,
+// and attributes errors caused by invalid response headers to the cluster and endpoint.
+type responseHeaderPolicyRoundTripper struct {
+	rt      http.RoundTripper
+	cluster string
+	policy  ResponseHeaderPolicy
+}
+
+var _ utilnet.RoundTripperWrapper = &responseHeaderPolicyRoundTripper{}
+
+func (rt *responseHeaderPolicyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		return nil, rt.attributeError(req, err)
+	}
+	// http.Transport enforces MaxResponseHeaderBytes on HTTP/1 responses only
+	if rt.policy.MaxHeaderBytes > 0 && resp.ProtoMajor == 2 {
+		if size := headerListSize(resp.Header); size > rt.policy.MaxHeaderBytes {
+			resp.Body.Close()
+			metrics.RecordInvalidUpstreamResponseHeader(rt.cluster, invalidResponseHeaderTooLarge)
+			return nil, fmt.Errorf("%w from %s: header list size %d exceeds limit %d", ErrResponseHeaderTooLarge, req.URL.Host, size, rt.policy.MaxHeaderBytes)
+		}
+	}
+	if rt.policy.propagateAllTrailers() || resp.StatusCode == http.StatusSwitchingProtocols {
+		return resp, nil
+	}
+	return filterTrailers(resp, rt.policy.Trailers), nil
+}
+
+func (rt *responseHeaderPolicyRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+func (rt *responseHeaderPolicyRoundTripper) attributeError(req *http.Request, err error) error {
+	msg := err.Error()
+	for _, m := range responseHeaderTooLargeMessages {
+		if strings.Contains(msg, m) {
+			metrics.RecordInvalidUpstreamResponseHeader(rt.cluster, invalidResponseHeaderTooLarge)
+			return fmt.Errorf("%w from %s: %v", ErrResponseHeaderTooLarge, req.URL.Host, err)
+		}
+	}
+	for _, m := range malformedResponseHeaderMessages {
+		if strings.Contains(msg, m) {
+			metrics.RecordInvalidUpstreamResponseHeader(rt.cluster, invalidResponseHeaderMalformed)
+			return fmt.Errorf("%w from %s: %v", ErrMalformedResponseHeader, req.URL.Host, err)
+		}
+	}
+	return err
+}
+
+// headerListSize returns the size of header as defined by HTTP/2 SETTINGS_MAX_HEADER_LIST_SIZE
+func headerListSize(header http.Header) int64 {
+	var size int64
+	for k, values := range header {
+		for _, v := range values {
+			size += int64(len(k) + len(v) + headerFieldOverhead)
+		}
+	}
+	return size
+}
+
+// filterTrailers returns a shallow copy of resp which only carries allowed trailers. Transports fill
+// trailers into the original response after body is read to EOF, so they are copied by the body.
+func filterTrailers(resp *http.Response, allowed sets.String) *http.Response {
+	filtered := *resp
+	filtered.Trailer = nil
+	for k := range resp.Trailer {
+		if allowed.Has(k) {
+			if filtered.Trailer == nil {
+				filtered.Trailer = http.Header{}
+			}
+			// announced trailers, values are filled after body is read
+			filtered.Trailer[k] = nil
+		}
+	}
+	if len(resp.Trailer) > 0 || resp.ProtoMajor == 1 {
+		// HTTP/1 chunked responses may send trailers without announcing them
+		filtered.Body = &trailerFilterBody{ReadCloser: resp.Body, from: resp, to: &filtered, allowed: allowed}
+	}
+	return &filtered
+}
+
+// trailerFilterBody copies allowed trailers from the original response after body is read to EOF or closed
+type trailerFilterBody struct {
+	io.ReadCloser
+	once    sync.Once
+	from    *http.Response
+	to      *http.Response
+	allowed sets.String
+}
+
+func (b *trailerFilterBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.copyTrailers)
+	}
+	return n, err
+}
+
+func (b *trailerFilterBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.copyTrailers)
+	return err
+}
+
+func (b *trailerFilterBody) copyTrailers() {
+	for k, values := range b.from.Trailer {
+		if !b.allowed.Has(k) || len(values) == 0 {
+			continue
+		}
+		if b.to.Trailer == nil {
+			b.to.Trailer = http.Header{}
+		}
+		b.to.Trailer[k] = values
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type fakeResponseRoundTripper struct {
+	resp *http.Response
+	err  error
+}
+
+func (rt *fakeResponseRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.resp, rt.err
+}
+
+// trailerBody fills trailers of resp when it is read to EOF, like transports do
+type trailerBody struct {
+	io.Reader
+	resp     *http.Response
+	trailers http.Header
+}
+
+func (b *trailerBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		for k, v := range b.trailers {
+			b.resp.Trailer[k] = v
+		}
+	}
+	return n, err
+}
+
+func (b *trailerBody) Close() error {
+	return nil
+}
+
+func Test_responseHeaderPolicyRoundTripper(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://10.0.0.1:6443/api", nil)
+
+	tests := []struct {
+		name    string
+		policy  ResponseHeaderPolicy
+		header  http.Header
+		err     error
+		wantErr error
+	}{
+		{
+			"http2 headers too large",
+			ResponseHeaderPolicy{MaxHeaderBytes: 64},
+			http.Header{"X-Large": []string{strings.Repeat("a", 64)}},
+			nil,
+			ErrResponseHeaderTooLarge,
+		},
+		{
+			"http2 headers in limit",
+			ResponseHeaderPolicy{MaxHeaderBytes: 64},
+			http.Header{"X-Small": []string{"a"}},
+			nil,
+			nil,
+		},
+		{
+			"http1 headers too large",
+			ResponseHeaderPolicy{MaxHeaderBytes: 64},
+			nil,
+			errors.New("net/http: server response headers exceeded 64 bytes; aborted"),
+			ErrResponseHeaderTooLarge,
+		},
+		{
+			"malformed headers",
+			ResponseHeaderPolicy{},
+			nil,
+			errors.New(`net/http: HTTP/1.x transport connection broken: malformed MIME header line: bad`),
+			ErrMalformedResponseHeader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: http.StatusOK, ProtoMajor: 2, Header: tt.header, Body: http.NoBody}
+			}
+			rt := &responseHeaderPolicyRoundTripper{rt: &fakeResponseRoundTripper{resp: resp, err: tt.err}, cluster: "test", policy: tt.policy}
+			_, err := rt.RoundTrip(req)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("RoundTrip() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), req.URL.Host) {
+				t.Errorf("RoundTrip() error = %v, want endpoint in message", err)
+			}
+		})
+	}
+}
+
+func Test_filterTrailers(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		ProtoMajor: 2,
+		Trailer:    http.Header{"Grpc-Status": nil, "X-Checksum": nil},
+	}
+	resp.Body = &trailerBody{
+		Reader:   strings.NewReader("body"),
+		resp:     resp,
+		trailers: http.Header{"Grpc-Status": []string{"0"}, "X-Checksum": []string{"abc"}},
+	}
+	rt := &responseHeaderPolicyRoundTripper{
+		rt:     &fakeResponseRoundTripper{resp: resp},
+		policy: ResponseHeaderPolicy{Trailers: sets.NewString("X-Checksum")},
+	}
+	got, err := rt.RoundTrip(&http.Request{})
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	if len(got.Trailer) != 1 {
+		t.Errorf("RoundTrip() announced trailers = %v, want only X-Checksum", got.Trailer)
+	}
+	if _, err := ioutil.ReadAll(got.Body); err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	got.Body.Close()
+	if got.Trailer.Get("X-Checksum") != "abc" {
+		t.Errorf("RoundTrip() trailer X-Checksum = %v, want abc", got.Trailer.Get("X-Checksum"))
+	}
+	if _, ok := got.Trailer["Grpc-Status"]; ok {
+		t.Errorf("RoundTrip() trailer Grpc-Status should be dropped")
+	}
+}
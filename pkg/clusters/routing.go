@@ -0,0 +1,172 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+// routingSnapshot is an immutable view of everything the request path needs to route a request
+// to an endpoint. Sync builds a new snapshot from the synced state and swaps it atomically, so
+// requests never contend with config updates on shared maps, and a request always routes with
+// one consistent version of policies, flow controls and endpoints.
+//
+// Only the maps and slices are immutable, EndpointInfo and FlowControl are shared with the
+// writer and their own state (e.g. readiness, tokens) is still updated in place.
+type routingSnapshot struct {
+	policies       []proxyv1alpha1.DispatchPolicy
+	logging        proxyv1alpha1.LoggingConfig
+	userAgentRules []UserAgentRule
+	// expressionRules take precedence over userAgentRules
+	expressionRules []ExpressionRule
+	apiGroups       map[string][]string
+	readWriteSplit  readWriteSplit
+	flowControls    map[string]gatewayflowcontrol.FlowControl
+	endpoints       map[string]*EndpointInfo
+	// endpoints of spec.servers, extension servers only serving overridden API groups are excluded
+	servers []string
+	// round robin counters keyed by upstreams, see counterOf
+	counters map[string]*uint64
+	// counter of upstreams which are unknown when the snapshot is built
+	defaultCounter *uint64
+}
+
+var emptyRoutingSnapshot = &routingSnapshot{defaultCounter: new(uint64)}
+
+// publishRouting builds a routing snapshot from current synced state and swaps it in.
+// It must only be called by the single writer, i.e. Sync.
+func (c *ClusterInfo) publishRouting() {
+	groups := c.loadAPIGroupEndpoints()
+	s := &routingSnapshot{
+		policies:       c.loadDispatchPolicies(),
+		logging:        c.loadLoggingConfig(),
+		apiGroups:      groups.groups,
+		flowControls:   map[string]gatewayflowcontrol.FlowControl{},
+		endpoints:      map[string]*EndpointInfo{},
+		servers:        []string{},
+		counters:       map[string]*uint64{},
+		defaultCounter: new(uint64),
+	}
+	s.userAgentRules, _ = c.currentUserAgentRules.Load().([]UserAgentRule)
+	s.expressionRules, _ = c.currentExpressionRules.Load().([]ExpressionRule)
+	replicas := c.loadReadReplicas()
+	s.readWriteSplit = readWriteSplit{readers: sets.NewString(replicas.Readers...), readYourWrites: replicas.ReadYourWritesWindow}
+
+	if spec, ok := c.loadFlowControlSpec(); ok {
+		for _, schema := range spec.Schemas {
+			if fc, ok := c.flowcontrol.Load(schema.Name); ok {
+				s.flowControls[schema.Name] = fc
+			}
+		}
+	}
+
+	c.Endpoints.Range(func(name string, info *EndpointInfo) bool {
+		s.endpoints[name] = info
+		if !groups.extensions.Has(name) {
+			s.servers = append(s.servers, name)
+		}
+		return true
+	})
+	sort.Strings(s.servers)
+
+	// counters are preallocated for all known upstreams, picking endpoints never writes the snapshot
+	s.addCounter(s.servers)
+	for i := range s.policies {
+		s.addCounter(s.policies[i].UpstreamSubset)
+	}
+	for i := range s.userAgentRules {
+		s.addCounter(s.userAgentRules[i].UpstreamSubset)
+	}
+	for i := range s.expressionRules {
+		s.addCounter(s.expressionRules[i].UpstreamSubset)
+	}
+	for _, endpoints := range s.apiGroups {
+		s.addCounter(endpoints)
+	}
+	s.addReadWriteSplitCounters()
+
+	// keep round robin positions of unchanged upstreams, so a config update does not
+	// send the next requests of all of them to their first endpoint
+	if old := c.loadRouting(); old != emptyRoutingSnapshot {
+		for key := range s.counters {
+			if oldCounter, ok := old.counters[key]; ok {
+				s.counters[key] = oldCounter
+			}
+		}
+	}
+
+	c.currentRouting.Store(s)
+}
+
+// loadRouting returns the current routing snapshot, it is never nil
+func (c *ClusterInfo) loadRouting() *routingSnapshot {
+	if s, ok := c.currentRouting.Load().(*routingSnapshot); ok {
+		return s
+	}
+	return emptyRoutingSnapshot
+}
+
+func (s *routingSnapshot) addCounter(upstreams []string) {
+	if len(upstreams) == 0 {
+		return
+	}
+	key := strings.Join(upstreams, ",")
+	if _, ok := s.counters[key]; !ok {
+		s.counters[key] = new(uint64)
+	}
+}
+
+// counterOf returns the round robin counter of upstreams
+func (s *routingSnapshot) counterOf(upstreams []string) *uint64 {
+	if counter, ok := s.counters[strings.Join(upstreams, ",")]; ok {
+		return counter
+	}
+	return s.defaultCounter
+}
+
+func (s *routingSnapshot) flowControl(name string, defaultFlowControl gatewayflowcontrol.FlowControl) gatewayflowcontrol.FlowControl {
+	if fc, ok := s.flowControls[name]; ok {
+		return fc
+	}
+	return defaultFlowControl
+}
+
+// matchUserAgentRule returns the first rule matching userAgent and the flow schema naming it, rules of the cluster
+// are matched before DefaultUserAgentRules, nil if none matches
+func (s *routingSnapshot) matchUserAgentRule(userAgent string) (*UserAgentRule, string) {
+	if len(s.userAgentRules) == 0 && len(DefaultUserAgentRules) == 0 {
+		return nil, ""
+	}
+	normalized := NormalizeUserAgent(userAgent)
+	for i := range s.userAgentRules {
+		if s.userAgentRules[i].Matches(normalized) {
+			return &s.userAgentRules[i], fmt.Sprintf("user-agent-rule-%d", i)
+		}
+	}
+	for i := range DefaultUserAgentRules {
+		rule := &DefaultUserAgentRules[i]
+		if _, ok := s.flowControls[rule.FlowControlSchemaName]; ok && rule.Matches(normalized) {
+			return rule, fmt.Sprintf("default-user-agent-rule-%d", i)
+		}
+	}
+	return nil, ""
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"sync"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+var testListPods = authorizer.AttributesRecord{
+	User:            &user.DefaultInfo{Name: "test"},
+	Verb:            "list",
+	Resource:        "pods",
+	ResourceRequest: true,
+}
+
+func newTestRoutingCluster(t *testing.T, endpoints ...string) (*proxyv1alpha1.UpstreamCluster, *ClusterInfo) {
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.Servers = nil
+	for _, ep := range endpoints {
+		cluster.Spec.Servers = append(cluster.Spec.Servers, proxyv1alpha1.UpstreamClusterServer{Endpoint: ep})
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	for _, ep := range endpoints {
+		e, _ := info.Endpoints.Load(ep)
+		e.UpdateStatus(true, "", "")
+	}
+	return cluster, info
+}
+
+func TestClusterInfo_MatchRequest_RoundRobin(t *testing.T) {
+	cluster, info := newTestRoutingCluster(t, "https://127.0.0.1:443", "https://127.0.0.2:443")
+	defer info.Stop()
+
+	pick := func() string {
+		picker, err := info.MatchRequest(testListPods, "")
+		if err != nil {
+			t.Fatalf("MatchRequest() error = %v", err)
+		}
+		ep, err := picker.Pop()
+		if err != nil {
+			t.Fatalf("Pop() error = %v", err)
+		}
+		return ep.Endpoint
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 10; i++ {
+		counts[pick()]++
+	}
+	if counts["https://127.0.0.1:443"] != 5 || counts["https://127.0.0.2:443"] != 5 {
+		t.Errorf("requests are not evenly distributed, got %v", counts)
+	}
+
+	// picker holds the snapshot when it is matched
+	picker, _ := info.MatchRequest(testListPods, "")
+	last := pick()
+	if err := info.Sync(cluster); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := pick(); got == last {
+		t.Errorf("round robin position is reset by sync, got %v twice", got)
+	}
+	cluster.Spec.Servers = cluster.Spec.Servers[:1]
+	if err := info.Sync(cluster); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if got := info.ServerEndpoints(); len(got) != 1 {
+		t.Errorf("ServerEndpoints() = %v, want the synced server", got)
+	}
+	if _, err := picker.Pop(); err != nil {
+		t.Errorf("Pop() of matched picker error = %v", err)
+	}
+}
+
+func TestClusterInfo_MatchRequest_ConcurrentSync(t *testing.T) {
+	cluster, info := newTestRoutingCluster(t, "https://127.0.0.1:443", "https://127.0.0.2:443")
+	defer info.Stop()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				picker, err := info.MatchRequest(testListPods, "")
+				if err != nil {
+					t.Errorf("MatchRequest() error = %v", err)
+					return
+				}
+				picker.Pop() //nolint
+			}
+		}()
+	}
+
+	servers := cluster.Spec.Servers
+	for i := 0; i < 20; i++ {
+		cluster.Spec.Servers = servers[:1+i%2]
+		if err := info.Sync(cluster); err != nil {
+			t.Errorf("Sync() error = %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
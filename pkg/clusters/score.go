@@ -0,0 +1,239 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+const (
+	// scoreEWMAWeight is the weight of a new sample in moving averages
+	scoreEWMAWeight = 0.1
+	// scoreReferenceLatency is the latency which halves the latency factor
+	scoreReferenceLatency = 100 * time.Millisecond
+	// scoreReferenceInflight is the number of inflight requests which halves the headroom factor
+	scoreReferenceInflight = 64
+	// scoreHealthCheckFreshness is the age of last successful health check before score decays
+	scoreHealthCheckFreshness = 15 * time.Second
+	// scoreMinFactor keeps every ready endpoint a small chance to be picked, so it can recover
+	scoreMinFactor = 0.01
+)
+
+// EndpointScore is the composite score of an endpoint and the inputs of scoring function,
+// endpoints with higher score get more requests. Unready endpoints always score 0.
+type EndpointScore struct {
+	Endpoint string  `json:"endpoint"`
+	Ready    bool    `json:"ready"`
+	Score    float64 `json:"score"`
+	// LatencyMilliseconds is moving average of time to response headers of non-long-running requests
+	LatencyMilliseconds float64 `json:"latencyMs"`
+	// ErrorRate is moving average of failed requests ratio, a request fails if it gets
+	// an error, 429 or 5xx response from upstream
+	ErrorRate float64 `json:"errorRate"`
+	// HealthCheckAgeSeconds is the time since last successful health check, -1 means never succeeded
+	HealthCheckAgeSeconds float64 `json:"healthCheckAgeSeconds"`
+	// Inflight is the number of non-long-running requests being proxied to the endpoint
+	Inflight int32 `json:"inflight"`
+}
+
+// endpointStats collects request outcomes of an endpoint for scoring
+type endpointStats struct {
+	mux       sync.Mutex
+	latency   float64 // seconds
+	errorRate float64
+	sampled   bool
+
+	inflight int32
+	// unix nano of last successful health check
+	lastHealthy int64
+}
+
+func newEndpointStats() *endpointStats {
+	return &endpointStats{}
+}
+
+func (s *endpointStats) observe(latency time.Duration, failed bool, recordLatency bool) {
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.sampled {
+		s.sampled = true
+		s.errorRate = sample
+		if recordLatency {
+			s.latency = latency.Seconds()
+		}
+		return
+	}
+	s.errorRate += scoreEWMAWeight * (sample - s.errorRate)
+	if recordLatency {
+		s.latency += scoreEWMAWeight * (latency.Seconds() - s.latency)
+	}
+}
+
+// restore seeds moving averages from persisted state
+func (s *endpointStats) restore(latency time.Duration, errorRate float64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.sampled = true
+	s.latency = latency.Seconds()
+	s.errorRate = errorRate
+}
+
+func (s *endpointStats) markHealthy(now time.Time) {
+	atomic.StoreInt64(&s.lastHealthy, now.UnixNano())
+}
+
+// score computes endpoint score from current stats
+func (s *endpointStats) score(endpoint string, ready bool, now time.Time) EndpointScore {
+	s.mux.Lock()
+	latency, errorRate := s.latency, s.errorRate
+	s.mux.Unlock()
+
+	result := EndpointScore{
+		Endpoint:              endpoint,
+		Ready:                 ready,
+		LatencyMilliseconds:   latency * 1000,
+		ErrorRate:             errorRate,
+		HealthCheckAgeSeconds: -1,
+		Inflight:              atomic.LoadInt32(&s.inflight),
+	}
+	var age time.Duration
+	if lastHealthy := atomic.LoadInt64(&s.lastHealthy); lastHealthy > 0 {
+		age = now.Sub(time.Unix(0, lastHealthy))
+		result.HealthCheckAgeSeconds = age.Seconds()
+	}
+	if !ready {
+		return result
+	}
+
+	latencyFactor := scoreReferenceLatency.Seconds() / (scoreReferenceLatency.Seconds() + latency)
+	errorFactor := math.Max(1-errorRate, scoreMinFactor)
+	freshnessFactor := 1.0
+	if result.HealthCheckAgeSeconds < 0 {
+		freshnessFactor = scoreMinFactor
+	} else if age > scoreHealthCheckFreshness {
+		freshnessFactor = math.Max(scoreHealthCheckFreshness.Seconds()/age.Seconds(), scoreMinFactor)
+	}
+	headroomFactor := float64(scoreReferenceInflight) / float64(scoreReferenceInflight+result.Inflight)
+	result.Score = 100 * latencyFactor * errorFactor * freshnessFactor * headroomFactor
+	return result
+}
+
+// Score returns the composite score of this endpoint
+func (e *EndpointInfo) Score() EndpointScore {
+	if e.stats == nil {
+		return EndpointScore{Endpoint: e.Endpoint, Ready: e.IsReady(), HealthCheckAgeSeconds: -1}
+	}
+	return e.stats.score(e.Endpoint, e.IsReady(), time.Now())
+}
+
+// EndpointScores returns scores of all endpoints of this cluster
+func (c *ClusterInfo) EndpointScores() []EndpointScore {
+	scores := []EndpointScore{}
+	for _, name := range c.AllEndpoints() {
+		if e, ok := c.Endpoints.Load(name); ok {
+			scores = append(scores, e.Score())
+		}
+	}
+	return scores
+}
+
+// pickWeighted picks one endpoint randomly weighted by score, the first one is returned
+// if all of them score 0.
+func pickWeighted(endpoints []*EndpointInfo) *EndpointInfo {
+	weights := make([]float64, len(endpoints))
+	total := 0.0
+	for i, e := range endpoints {
+		weights[i] = e.Score().Score
+		total += weights[i]
+	}
+	if total <= 0 {
+		return endpoints[rand.Intn(len(endpoints))]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return endpoints[i]
+		}
+	}
+	return endpoints[len(endpoints)-1]
+}
+
+// statsRoundTripper records request outcomes to endpoint stats
+type statsRoundTripper struct {
+	rt    http.RoundTripper
+	stats *endpointStats
+	// latency and inflight of long-running requests are meaningless for scoring
+	longRunning bool
+}
+
+var _ utilnet.RoundTripperWrapper = &statsRoundTripper{}
+
+func (rt *statsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !rt.longRunning {
+		atomic.AddInt32(&rt.stats.inflight, 1)
+	}
+	start := time.Now()
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		if !rt.longRunning {
+			atomic.AddInt32(&rt.stats.inflight, -1)
+		}
+		// requests canceled by clients say nothing about the endpoint
+		if !errors.Is(err, context.Canceled) {
+			rt.stats.observe(time.Since(start), true, false)
+		}
+		return nil, err
+	}
+	failed := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+	rt.stats.observe(time.Since(start), failed, !rt.longRunning)
+	if !rt.longRunning {
+		resp.Body = &inflightBody{ReadCloser: resp.Body, inflight: &rt.stats.inflight}
+	}
+	return resp, nil
+}
+
+func (rt *statsRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+// inflightBody decreases inflight counter once the body is closed
+type inflightBody struct {
+	io.ReadCloser
+	inflight *int32
+	once     sync.Once
+}
+
+func (b *inflightBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		atomic.AddInt32(b.inflight, -1)
+	})
+	return err
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_endpointStats_score(t *testing.T) {
+	now := time.Now()
+	healthy := func(latency time.Duration, errorRate float64, inflight int32, healthCheckAge time.Duration) *endpointStats {
+		return &endpointStats{
+			latency:     latency.Seconds(),
+			errorRate:   errorRate,
+			sampled:     true,
+			inflight:    inflight,
+			lastHealthy: now.Add(-healthCheckAge).UnixNano(),
+		}
+	}
+	base := healthy(10*time.Millisecond, 0, 0, time.Second).score("base", true, now).Score
+
+	tests := []struct {
+		name      string
+		stats     *endpointStats
+		ready     bool
+		wantZero  bool
+		wantLower bool
+	}{
+		{"unready", healthy(10*time.Millisecond, 0, 0, time.Second), false, true, true},
+		{"same", healthy(10*time.Millisecond, 0, 0, time.Second), true, false, false},
+		{"slow", healthy(time.Second, 0, 0, time.Second), true, false, true},
+		{"errors", healthy(10*time.Millisecond, 0.5, 0, time.Second), true, false, true},
+		{"stale health check", healthy(10*time.Millisecond, 0, 0, time.Minute), true, false, true},
+		{"never health checked", &endpointStats{}, true, false, true},
+		{"busy", healthy(10*time.Millisecond, 0, 64, time.Second), true, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.stats.score(tt.name, tt.ready, now)
+			if tt.wantZero != (got.Score == 0) {
+				t.Errorf("score() = %v, want zero %v", got.Score, tt.wantZero)
+			}
+			if tt.wantLower != (got.Score < base) {
+				t.Errorf("score() = %v, base %v, want lower %v", got.Score, base, tt.wantLower)
+			}
+		})
+	}
+}
+
+type fakeRoundTripper struct {
+	code int
+	err  error
+}
+
+func (f *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &http.Response{StatusCode: f.code, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+}
+
+func Test_statsRoundTripper(t *testing.T) {
+	tests := []struct {
+		name          string
+		rt            *fakeRoundTripper
+		wantSampled   bool
+		wantErrorRate float64
+	}{
+		{"ok", &fakeRoundTripper{code: http.StatusOK}, true, 0},
+		{"not found", &fakeRoundTripper{code: http.StatusNotFound}, true, 0},
+		{"too many requests", &fakeRoundTripper{code: http.StatusTooManyRequests}, true, 1},
+		{"server error", &fakeRoundTripper{code: http.StatusServiceUnavailable}, true, 1},
+		{"transport error", &fakeRoundTripper{err: errors.New("connection refused")}, true, 1},
+		{"canceled by client", &fakeRoundTripper{err: context.Canceled}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := newEndpointStats()
+			rt := &statsRoundTripper{rt: tt.rt, stats: stats}
+			req, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:6443/api", nil)
+			resp, err := rt.RoundTrip(req)
+			if err == nil {
+				if stats.inflight != 1 {
+					t.Errorf("inflight = %v before body closed, want 1", stats.inflight)
+				}
+				resp.Body.Close()
+				resp.Body.Close()
+			}
+			if stats.inflight != 0 {
+				t.Errorf("inflight = %v after request finished, want 0", stats.inflight)
+			}
+			if stats.sampled != tt.wantSampled {
+				t.Errorf("sampled = %v, want %v", stats.sampled, tt.wantSampled)
+			}
+			if stats.errorRate != tt.wantErrorRate {
+				t.Errorf("errorRate = %v, want %v", stats.errorRate, tt.wantErrorRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+const softStateVersion = "v1"
+
+// SoftState is the live soft state of a gateway replica, it is learned from traffic and lost on restart.
+// A blue/green replacement set of replicas imports the state exported by the retiring set, so it starts
+// with warm endpoint scores and rate limiter balances instead of a thundering herd of mis-throttled traffic.
+type SoftState struct {
+	Version      string             `json:"version"`
+	ExportTime   time.Time          `json:"exportTime"`
+	Endpoints    []EndpointState    `json:"endpoints"`
+	FlowControls []FlowControlState `json:"flowControls"`
+}
+
+// FlowControlState is the token balance of a token bucket flow control schema of one cluster
+type FlowControlState struct {
+	Cluster string                     `json:"cluster"`
+	Schema  string                     `json:"schema"`
+	Balance gatewayflowcontrol.Balance `json:"balance"`
+}
+
+// SoftStateImportResult counts states applied by ImportSoftState, states of clusters, endpoints
+// or flow control schemas unknown to this replica and states older than maxAge are skipped.
+type SoftStateImportResult struct {
+	Endpoints    int `json:"endpoints"`
+	FlowControls int `json:"flowControls"`
+	Skipped      int `json:"skipped"`
+}
+
+// ExportSoftState returns soft state of all clusters in manager
+func ExportSoftState(manager Manager) SoftState {
+	now := time.Now()
+	state := SoftState{
+		Version:      softStateVersion,
+		ExportTime:   now,
+		Endpoints:    []EndpointState{},
+		FlowControls: []FlowControlState{},
+	}
+	for _, cluster := range manager.List() {
+		cluster.Endpoints.Range(func(_ string, e *EndpointInfo) bool {
+			state.Endpoints = append(state.Endpoints, e.State(now))
+			return true
+		})
+		cluster.flowcontrol.Range(func(name string, fc gatewayflowcontrol.FlowControl) bool {
+			if balance, ok := gatewayflowcontrol.BalanceOf(fc); ok {
+				state.FlowControls = append(state.FlowControls, FlowControlState{Cluster: cluster.Cluster, Schema: name, Balance: balance})
+			}
+			return true
+		})
+	}
+	sort.Slice(state.Endpoints, func(i, j int) bool {
+		return endpointStateKey(state.Endpoints[i].Cluster, state.Endpoints[i].Endpoint) < endpointStateKey(state.Endpoints[j].Cluster, state.Endpoints[j].Endpoint)
+	})
+	sort.Slice(state.FlowControls, func(i, j int) bool {
+		if state.FlowControls[i].Cluster != state.FlowControls[j].Cluster {
+			return state.FlowControls[i].Cluster < state.FlowControls[j].Cluster
+		}
+		return state.FlowControls[i].Schema < state.FlowControls[j].Schema
+	})
+	return state
+}
+
+// ImportSoftState applies state exported by another replica to clusters in manager. It should be
+// called after upstream clusters are synced, states of clusters not known yet are skipped.
+func ImportSoftState(manager Manager, state SoftState, maxAge time.Duration) (SoftStateImportResult, error) {
+	result := SoftStateImportResult{}
+	if state.Version != softStateVersion {
+		return result, fmt.Errorf("unknown soft state version %q, want %q", state.Version, softStateVersion)
+	}
+	if maxAge > 0 && time.Since(state.ExportTime) > maxAge {
+		return result, fmt.Errorf("soft state exported at %s is older than %v", state.ExportTime.Format(time.RFC3339), maxAge)
+	}
+	for _, s := range state.Endpoints {
+		cluster, ok := manager.Get(s.Cluster)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		e, ok := cluster.Endpoints.Load(s.Endpoint)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		e.restoreState(s)
+		result.Endpoints++
+	}
+	for _, s := range state.FlowControls {
+		cluster, ok := manager.Get(s.Cluster)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		fc, ok := cluster.flowcontrol.Load(s.Schema)
+		if !ok || !gatewayflowcontrol.RestoreBalance(fc, s.Balance) {
+			result.Skipped++
+			continue
+		}
+		result.FlowControls++
+	}
+	klog.Infof("[soft state] imported state exported at %s, endpoints=%d flowControls=%d skipped=%d",
+		state.ExportTime.Format(time.RFC3339), result.Endpoints, result.FlowControls, result.Skipped)
+	return result, nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+	"time"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+func newSoftStateCluster(name string) *ClusterInfo {
+	c := NewEmptyClusterInfo(name, nil, nil, nil)
+	c.flowcontrol.Store("bucket", gatewayflowcontrol.NewFlowControl(proxyv1alpha1.FlowControlSchema{
+		Name: "bucket",
+		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+			TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 100},
+		},
+	}))
+	c.flowcontrol.Store("inflight", gatewayflowcontrol.NewFlowControl(proxyv1alpha1.FlowControlSchema{
+		Name: "inflight",
+		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+			MaxRequestsInflight: &proxyv1alpha1.MaxRequestsInflightFlowControlSchema{Max: 10},
+		},
+	}))
+	e := &EndpointInfo{Cluster: name, Endpoint: "https://a", stats: newEndpointStats()}
+	e.status.SetStatus(true, "", "")
+	c.Endpoints.Store(e.Endpoint, e)
+	return c
+}
+
+func TestSoftState(t *testing.T) {
+	old := NewManager()
+	oldCluster := newSoftStateCluster("test")
+	old.Add(oldCluster)
+	old.Add(newSoftStateCluster("retired"))
+	bucket, _ := oldCluster.flowcontrol.Load("bucket")
+	for i := 0; i < 90; i++ {
+		bucket.TryAcquire()
+	}
+	e, _ := oldCluster.Endpoints.Load("https://a")
+	e.stats.restore(50*time.Millisecond, 0.2)
+
+	state := ExportSoftState(old)
+	if len(state.Endpoints) != 2 || len(state.FlowControls) != 2 {
+		t.Fatalf("ExportSoftState() = %d endpoints and %d flow controls, want 2 and 2 token buckets", len(state.Endpoints), len(state.FlowControls))
+	}
+
+	replacement := NewManager()
+	newCluster := newSoftStateCluster("test")
+	replacement.Add(newCluster)
+	result, err := ImportSoftState(replacement, state, time.Minute)
+	if err != nil {
+		t.Fatalf("ImportSoftState() error = %v", err)
+	}
+	if want := (SoftStateImportResult{Endpoints: 1, FlowControls: 1, Skipped: 2}); result != want {
+		t.Errorf("ImportSoftState() = %+v, want %+v", result, want)
+	}
+	fc, _ := newCluster.flowcontrol.Load("bucket")
+	if status, _ := gatewayflowcontrol.StatusOf(fc); status.Remaining > 15 {
+		t.Errorf("Remaining = %d after import, want the spent balance of the old replica", status.Remaining)
+	}
+	imported, _ := newCluster.Endpoints.Load("https://a")
+	if got := imported.State(time.Now()).ErrorRate; got != 0.2 {
+		t.Errorf("imported endpoint error rate = %v, want 0.2", got)
+	}
+
+	state.ExportTime = time.Now().Add(-time.Hour)
+	if _, err := ImportSoftState(replacement, state, time.Minute); err == nil {
+		t.Errorf("ImportSoftState() of stale state succeeded, want error")
+	}
+	state.Version = "v0"
+	if _, err := ImportSoftState(replacement, state, 0); err == nil {
+		t.Errorf("ImportSoftState() of unknown version succeeded, want error")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	endpointStateFileVersion = "v1"
+
+	// endpointRecoveryChecks is the number of consecutive successful health checks required
+	// before an endpoint restored as unhealthy receives traffic again
+	endpointRecoveryChecks = 3
+
+	endpointReasonRecovering = "Recovering"
+)
+
+// DefaultEndpointStateStore restores learned endpoint state when endpoints are created,
+// nil means disabled. It must be set before upstream cluster controller creates the first
+// endpoint, otherwise state of that endpoint is never restored.
+var DefaultEndpointStateStore *EndpointStateStore
+
+// EndpointState is the learned state of an endpoint persisted across gateway restarts
+type EndpointState struct {
+	Cluster             string    `json:"cluster"`
+	Endpoint            string    `json:"endpoint"`
+	Healthy             bool      `json:"healthy"`
+	Reason              string    `json:"reason,omitempty"`
+	Message             string    `json:"message,omitempty"`
+	LatencyMilliseconds float64   `json:"latencyMs"`
+	ErrorRate           float64   `json:"errorRate"`
+	UpdateTime          time.Time `json:"updateTime"`
+}
+
+type endpointStateFile struct {
+	Version   string          `json:"version"`
+	Endpoints []EndpointState `json:"endpoints"`
+}
+
+// EndpointStateStore saves state of all endpoints to a local file periodically and restores
+// it after restart, so a restarted gateway does not send a burst of traffic to a known-bad
+// endpoint while health checks warm up. States older than maxAge are ignored.
+type EndpointStateStore struct {
+	path   string
+	maxAge time.Duration
+
+	mux      sync.RWMutex
+	restored map[string]EndpointState
+}
+
+func NewEndpointStateStore(path string, maxAge time.Duration) *EndpointStateStore {
+	return &EndpointStateStore{
+		path:     path,
+		maxAge:   maxAge,
+		restored: map[string]EndpointState{},
+	}
+}
+
+func endpointStateKey(cluster, endpoint string) string {
+	return cluster + "/" + endpoint
+}
+
+// Load reads states from the state file, a missing file is not an error
+func (s *EndpointStateStore) Load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	file := endpointStateFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to decode endpoint state file %s: %v", s.path, err)
+	}
+	if file.Version != endpointStateFileVersion {
+		klog.Warningf("[endpoint state] ignore state file %s with unknown version %q", s.path, file.Version)
+		return nil
+	}
+	restored := map[string]EndpointState{}
+	for _, state := range file.Endpoints {
+		restored[endpointStateKey(state.Cluster, state.Endpoint)] = state
+	}
+	s.mux.Lock()
+	s.restored = restored
+	s.mux.Unlock()
+	klog.Infof("[endpoint state] loaded %d endpoint states from %s", len(restored), s.path)
+	return nil
+}
+
+// Lookup returns the restored state of endpoint if it is not older than maxAge
+func (s *EndpointStateStore) Lookup(cluster, endpoint string) (EndpointState, bool) {
+	s.mux.RLock()
+	state, ok := s.restored[endpointStateKey(cluster, endpoint)]
+	s.mux.RUnlock()
+	if !ok || time.Since(state.UpdateTime) > s.maxAge {
+		return EndpointState{}, false
+	}
+	return state, true
+}
+
+// Save writes states of all endpoints of clusters into the state file atomically
+func (s *EndpointStateStore) Save(clusters []*ClusterInfo) error {
+	file := endpointStateFile{
+		Version:   endpointStateFileVersion,
+		Endpoints: []EndpointState{},
+	}
+	now := time.Now()
+	for _, cluster := range clusters {
+		cluster.Endpoints.Range(func(name string, e *EndpointInfo) bool {
+			file.Endpoints = append(file.Endpoints, e.State(now))
+			return true
+		})
+	}
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Run saves states of clusters in manager every interval until stopCh is closed,
+// states are saved once more before it returns.
+func (s *EndpointStateStore) Run(manager Manager, interval time.Duration, stopCh <-chan struct{}) {
+	klog.Infof("[endpoint state] start saving endpoint states to %s every %v", s.path, interval)
+	save := func() {
+		if err := s.Save(manager.List()); err != nil {
+			klog.Errorf("[endpoint state] failed to save endpoint states to %s: %v", s.path, err)
+		}
+	}
+	wait.Until(save, interval, stopCh)
+	save()
+}
+
+// State returns the current learned state of this endpoint
+func (e *EndpointInfo) State(now time.Time) EndpointState {
+	e.status.mux.RLock()
+	state := EndpointState{
+		Cluster:    e.Cluster,
+		Endpoint:   e.Endpoint,
+		Healthy:    e.status.Healthy,
+		Reason:     e.status.Reason,
+		Message:    e.status.Message,
+		UpdateTime: now,
+	}
+	e.status.mux.RUnlock()
+	if e.stats != nil {
+		score := e.stats.score(e.Endpoint, false, now)
+		state.LatencyMilliseconds = score.LatencyMilliseconds
+		state.ErrorRate = score.ErrorRate
+	}
+	return state
+}
+
+// restoreState seeds status and stats of a new endpoint from its restored state,
+// an endpoint restored as unhealthy must pass several health checks before it is ready.
+func (e *EndpointInfo) restoreState(state EndpointState) {
+	if e.stats != nil {
+		e.stats.restore(time.Duration(state.LatencyMilliseconds*float64(time.Millisecond)), state.ErrorRate)
+	}
+	if state.Healthy {
+		return
+	}
+	e.status.SetStatus(false, state.Reason, fmt.Sprintf("restored from state at %s: %s", state.UpdateTime.Format(time.RFC3339), state.Message))
+	atomic.StoreInt32(&e.recoveryChecks, endpointRecoveryChecks)
+	klog.Infof("[endpoint state] cluster=%q endpoint=%q restored as unhealthy, reason=%q", e.Cluster, e.Endpoint, state.Reason)
+}
+
+// recovering returns true if the endpoint is still recovering from restored unhealthy state,
+// each call consumes one successful health check.
+func (e *EndpointInfo) recovering() bool {
+	for {
+		n := atomic.LoadInt32(&e.recoveryChecks)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&e.recoveryChecks, n, n-1) {
+			return n > 1
+		}
+	}
+}
+
+// resetRecovery requires consecutive successful health checks again if the endpoint is recovering
+func (e *EndpointInfo) resetRecovery() {
+	for {
+		n := atomic.LoadInt32(&e.recoveryChecks)
+		if n <= 0 || atomic.CompareAndSwapInt32(&e.recoveryChecks, n, endpointRecoveryChecks) {
+			return
+		}
+	}
+}
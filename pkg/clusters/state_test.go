@@ -0,0 +1,81 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_EndpointStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "endpoint-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	cluster := &ClusterInfo{Cluster: "test", Endpoints: &EndpointInfoMap{}}
+	healthy := &EndpointInfo{Cluster: "test", Endpoint: "https://a", stats: newEndpointStats()}
+	healthy.status.SetStatus(true, "", "")
+	healthy.stats.restore(20*time.Millisecond, 0.1)
+	unhealthy := &EndpointInfo{Cluster: "test", Endpoint: "https://b", stats: newEndpointStats()}
+	unhealthy.status.SetStatus(false, "Timeout", "healthz timeout")
+	cluster.Endpoints.Store(healthy.Endpoint, healthy)
+	cluster.Endpoints.Store(unhealthy.Endpoint, unhealthy)
+
+	if err := NewEndpointStateStore(path, time.Minute).Save([]*ClusterInfo{cluster}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	store := NewEndpointStateStore(path, time.Minute)
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	state, ok := store.Lookup("test", "https://a")
+	if !ok || !state.Healthy || state.ErrorRate != 0.1 {
+		t.Errorf("Lookup() = %+v, %v, want healthy state with errorRate 0.1", state, ok)
+	}
+	if _, ok := store.Lookup("test", "https://c"); ok {
+		t.Errorf("Lookup() found state of unknown endpoint")
+	}
+	if _, ok := NewEndpointStateStore(filepath.Join(dir, "missing.json"), time.Minute).Lookup("test", "https://a"); ok {
+		t.Errorf("Lookup() found state without loading")
+	}
+
+	state, ok = store.Lookup("test", "https://b")
+	if !ok || state.Healthy {
+		t.Fatalf("Lookup() = %+v, %v, want unhealthy state", state, ok)
+	}
+	restored := &EndpointInfo{Cluster: "test", Endpoint: "https://b", stats: newEndpointStats()}
+	restored.restoreState(state)
+	for i := 0; i < endpointRecoveryChecks; i++ {
+		if restored.IsReady() {
+			t.Fatalf("endpoint is ready after %d successful health checks, want %d", i, endpointRecoveryChecks)
+		}
+		restored.UpdateStatus(true, "", "")
+		if i == 0 {
+			// a failure requires consecutive successes again
+			restored.UpdateStatus(false, "Timeout", "")
+			restored.UpdateStatus(true, "", "")
+		}
+	}
+	if !restored.IsReady() {
+		t.Errorf("endpoint is not ready after %d successful health checks", endpointRecoveryChecks)
+	}
+}
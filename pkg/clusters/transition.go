@@ -0,0 +1,113 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// healthFlapWindow is the window in which a health transition reversing the previous one is a flap
+const healthFlapWindow = 5 * time.Minute
+
+// endpointTransition is a change of endpoint status. Seq increases monotonically for each
+// transition of the endpoint, so records of concurrent checkers can be told apart and ordered.
+type endpointTransition struct {
+	Seq      uint64
+	Disabled bool
+	Cordoned bool
+	Healthy  bool
+	Reason   string
+	Message  string
+	// HealthChanged is true if Healthy is changed by the transition
+	HealthChanged bool
+	// Flap is true if the health transition reverses the previous one within healthFlapWindow
+	Flap bool
+}
+
+// transitionLocked returns the transition to current status, mux must be held
+func (s *endpointStatus) transitionLocked() endpointTransition {
+	s.seq++
+	return endpointTransition{
+		Seq:      s.seq,
+		Disabled: s.Disabled,
+		Cordoned: s.Cordoned,
+		Healthy:  s.Healthy,
+		Reason:   s.Reason,
+		Message:  s.Message,
+	}
+}
+
+// transitDisabled sets Disabled, it returns false if status is not changed
+func (s *endpointStatus) transitDisabled(disabled bool) (endpointTransition, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.Disabled == disabled {
+		return endpointTransition{}, false
+	}
+	s.Disabled = disabled
+	return s.transitionLocked(), true
+}
+
+// transitCordoned sets Cordoned with reason, it returns false if status is not changed
+func (s *endpointStatus) transitCordoned(cordoned bool, reason string) (endpointTransition, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.Cordoned == cordoned && s.CordonReason == reason {
+		return endpointTransition{}, false
+	}
+	s.Cordoned = cordoned
+	s.CordonReason = reason
+	return s.transitionLocked(), true
+}
+
+// transitHealth sets Healthy with reason and message at now, it returns false if Healthy is not
+// changed, so only the first of concurrent checkers observing the same change records it.
+func (s *endpointStatus) transitHealth(healthy bool, reason, message string, now time.Time) (endpointTransition, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.Healthy == healthy {
+		return endpointTransition{}, false
+	}
+	s.Healthy = healthy
+	s.Reason = reason
+	s.Message = message
+	t := s.transitionLocked()
+	t.HealthChanged = true
+	t.Flap = !s.lastHealthTransition.IsZero() && now.Sub(s.lastHealthTransition) < healthFlapWindow
+	s.lastHealthTransition = now
+	return t, true
+}
+
+// recordTransition logs transition and reacts to it, transitionLock must be held so records
+// are written in order of Seq.
+func (e *EndpointInfo) recordTransition(t endpointTransition) {
+	klog.V(1).Infof(
+		"[endpoint info] endpoint status changed, cluster=%q, endpoint=%q, seq=%d, disabled=%v, cordoned=%v, healthy=%v, reason=%q, message=%q",
+		e.Cluster, e.Endpoint, t.Seq, t.Disabled, t.Cordoned, t.Healthy, t.Reason, t.Message,
+	)
+	if t.Flap {
+		metrics.RecordUpstreamHealthFlap(e.Cluster, e.Endpoint)
+	}
+	if t.HealthChanged {
+		e.notifyHealthChange(t)
+	}
+	if e.onStatusChange != nil {
+		e.onStatusChange()
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEndpointStatus_transitHealth(t *testing.T) {
+	s := &endpointStatus{}
+	now := time.Now()
+
+	if _, changed := s.transitHealth(false, "Timeout", "", now); changed {
+		t.Errorf("transitHealth() to unchanged health should not be a transition")
+	}
+	first, changed := s.transitHealth(true, "", "", now)
+	if !changed || first.Seq != 1 || first.Flap || !first.HealthChanged {
+		t.Errorf("first transition = %+v, %v, want seq 1 without flap", first, changed)
+	}
+	second, _ := s.transitHealth(false, "Timeout", "request timeout", now.Add(time.Minute))
+	if second.Seq != 2 || !second.Flap || second.Reason != "Timeout" {
+		t.Errorf("second transition = %+v, want seq 2 with flap", second)
+	}
+	third, _ := s.transitHealth(true, "", "", now.Add(time.Minute+healthFlapWindow))
+	if third.Seq != 3 || third.Flap {
+		t.Errorf("third transition = %+v, want seq 3 without flap", third)
+	}
+	cordon, _ := s.transitCordoned(true, "maintenance")
+	if cordon.Seq != 4 || cordon.HealthChanged || !cordon.Cordoned {
+		t.Errorf("cordon transition = %+v, want seq 4 without health change", cordon)
+	}
+}
+
+func TestEndpointInfo_concurrentUpdateStatus(t *testing.T) {
+	e := &EndpointInfo{Cluster: "test", Endpoint: "https://1.1.1.1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.UpdateStatus(true, "", "")
+		}()
+	}
+	wg.Wait()
+	if e.status.seq != 1 {
+		t.Errorf("concurrent checkers observing the same change recorded %d transitions, want 1", e.status.seq)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	gatewaynet "github.com/kubewharf/kubegateway/pkg/gateway/net"
+)
+
+// transportProfile tunes connection pool of an endpoint transport for one kind of requests
+type transportProfile struct {
+	// ResponseHeaderTimeout is the time to wait for upstream response headers, zero means no timeout
+	ResponseHeaderTimeout time.Duration
+	// IdleConnTimeout is the maximum time an idle connection remains in pool
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost is the maximum idle connections kept in pool
+	MaxIdleConnsPerHost int
+}
+
+// requestTimeoutHeadroom is added to the request timeout, so upstream times out and responds a Status
+// before gateway gives up waiting for headers.
+const requestTimeoutHeadroom = 10 * time.Second
+
+// shortRequestTransportProfile is used by non-long-running requests, they are expected to be
+// responded in requestTimeout, and connections are reused frequently.
+func shortRequestTransportProfile(requestTimeout time.Duration) transportProfile {
+	return transportProfile{
+		ResponseHeaderTimeout: requestTimeout + requestTimeoutHeadroom,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   100,
+	}
+}
+
+var (
+	// longRunningTransportProfile is used by watches and other long-running requests, upstream may
+	// respond headers after a long time, e.g. a watch without any event.
+	longRunningTransportProfile = transportProfile{
+		ResponseHeaderTimeout: 0,
+		IdleConnTimeout:       5 * time.Minute,
+		// same as client-go
+		MaxIdleConnsPerHost: 25,
+	}
+)
+
+var (
+	ErrResponseHeaderTimeout = errors.New("timeout awaiting upstream response headers")
+
+	// DefaultUpstreamTLSPolicy restricts TLS parameters of connections to all upstream endpoints, nil means
+	// parameters of cluster client config are used. It is applied to the TLS config of each endpoint
+	// transport when it is created, TLSPolicyOptions sets it before any endpoint exists.
+	DefaultUpstreamTLSPolicy *gatewaynet.TLSPolicy
+)
+
+type responseHeaderTimeoutKey struct{}
+
+// WithResponseHeaderTimeout returns a context which shortens the response header timeout of requests
+// sent with it, e.g. an adaptive timeout derived from recent latencies. It never extends the timeout of
+// transport profile, and requests without profile timeout, e.g. watches, are not affected.
+func WithResponseHeaderTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, responseHeaderTimeoutKey{}, timeout)
+}
+
+// responseHeaderTimeoutFrom returns the response header timeout set by WithResponseHeaderTimeout
+func responseHeaderTimeoutFrom(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(responseHeaderTimeoutKey{}).(time.Duration)
+	return timeout, ok && timeout > 0
+}
+
+// responseHeaderTimeout returns the smaller one of profile timeout and max, zero max means no limit.
+// max bounds long-running requests too, which are not bounded by their profile.
+func (p transportProfile) responseHeaderTimeout(max time.Duration) time.Duration {
+	timeout := p.ResponseHeaderTimeout
+	if max > 0 && (timeout == 0 || max < timeout) {
+		timeout = max
+	}
+	return timeout
+}
+
+// newEndpointTransport creates a dedicated round tripper for an upstream endpoint.
+//
+// rest.TransportFor caches transports by tls config, so endpoints of different clusters may
+// share one connection pool and dialer. Build http.Transport by ourselves to make sure
+// connections and dials to one cluster never affect others.
+//
+// Each profile has its own connection pool, so that long-running streams never share HTTP/2
+// connections with short requests and starve them behind connection level flow control.
+//
+// Response header timeout, deadline propagation and response header policy are built from defaults.
+//
+// verify is called at every TLS handshake after certificates are verified by tls config, a non-nil
+// error aborts the handshake. It may be nil.
+func newEndpointTransport(cluster string, defaults *ClusterDefaults, config *rest.Config, dial dialFunc, profile transportProfile, verify func(tls.ConnectionState) error) (http.RoundTripper, error) {
+	configCopy := *config
+	configCopy.Dial = dial
+	// TransportConfig resolves exec credential plugin, the plugin may set a client certificate
+	// callback and wrap Dial to close connections after the certificate is rotated.
+	transportConfig, err := configCopy.TransportConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := transport.TLSConfigFor(transportConfig)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		DefaultUpstreamTLSPolicy.ApplyTo(tlsConfig)
+		if len(config.NextProtos) > 0 {
+			// protocols required by the transport, e.g. http/1.1 for upgrade requests
+			tlsConfig.NextProtos = config.NextProtos
+		}
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			metrics.RecordTLSHandshake("upstream", cluster, state)
+			if verify != nil {
+				return verify(state)
+			}
+			return nil
+		}
+	}
+	headerPolicy := defaults.ResponseHeaderPolicy
+	base := utilnet.SetTransportDefaults(&http.Transport{
+		Proxy:                  http.ProxyFromEnvironment,
+		TLSHandshakeTimeout:    10 * time.Second,
+		TLSClientConfig:        tlsConfig,
+		MaxIdleConnsPerHost:    profile.MaxIdleConnsPerHost,
+		IdleConnTimeout:        profile.IdleConnTimeout,
+		DialContext:            transportConfig.Dial,
+		MaxResponseHeaderBytes: headerPolicy.MaxHeaderBytes,
+	})
+	var rt http.RoundTripper = &responseHeaderPolicyRoundTripper{rt: base, cluster: cluster, policy: headerPolicy}
+	if timeout := profile.responseHeaderTimeout(defaults.ResponseHeaderTimeout); timeout > 0 {
+		// http2 transport ignores http.Transport.ResponseHeaderTimeout, so bound it by ourselves
+		// the deadline of non-long-running requests is propagated, response bodies of long-running
+		// requests are not bounded by the timeout
+		timeoutRT := &responseHeaderTimeoutRoundTripper{rt: rt, timeout: timeout}
+		if profile.ResponseHeaderTimeout > 0 {
+			timeoutRT.deadline = defaults.DeadlinePropagation
+		}
+		rt = timeoutRT
+	}
+	// wrap base with auth (bearer token, token file and exec credential), impersonation and user agent
+	return transport.HTTPWrappersForConfig(transportConfig, rt)
+}
+
+// streamObservedDial wraps connections dialed for upgrade requests by the stream observer of request,
+// so that upstream resets of upgraded streams are told apart from client disconnects. UpgradeAwareHandler
+// dials and handshakes the backend connection itself, so the raw connection is the only one reachable,
+// the observer ignores it until the 101 response is written to client.
+func streamObservedDial(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if observer, ok := gatewaynet.StreamObserverFrom(ctx); ok {
+			return observer.WrapUpstream(conn), nil
+		}
+		return conn, nil
+	}
+}
+
+// responseHeaderTimeoutRoundTripper cancels the request if upstream does not respond headers in timeout,
+// it detects endpoints which accept connections but never answer. The response body can be streamed
+// without any limit after headers arrive.
+type responseHeaderTimeoutRoundTripper struct {
+	rt      http.RoundTripper
+	timeout time.Duration
+	// deadline tells upstream the timeout, it is disabled for long-running requests
+	deadline DeadlinePropagation
+}
+
+var _ utilnet.RoundTripperWrapper = &responseHeaderTimeoutRoundTripper{}
+
+func (rt *responseHeaderTimeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := rt.timeout
+	if t, ok := responseHeaderTimeoutFrom(req.Context()); ok && t < timeout {
+		timeout = t
+	}
+	req = rt.deadline.apply(req, time.Now(), timeout)
+	ctx, cancel := context.WithCancel(req.Context())
+	timer := time.AfterFunc(timeout, cancel)
+	resp, err := rt.rt.RoundTrip(req.WithContext(ctx))
+	if !timer.Stop() {
+		// timer fired before headers arrived
+		if resp != nil {
+			resp.Body.Close()
+		}
+		cancel()
+		return nil, fmt.Errorf("%w after %v from %s", ErrResponseHeaderTimeout, timeout, req.URL.Host)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// keep the upgraded body writable, ctx is released with the parent context
+		return resp, nil
+	}
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (rt *responseHeaderTimeoutRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+// cancelOnCloseBody releases the request context after response body is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
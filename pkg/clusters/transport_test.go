@@ -0,0 +1,105 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type blockingRoundTripper struct {
+	delay time.Duration
+}
+
+func (rt *blockingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-time.After(rt.delay):
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("ok"))}, nil
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+}
+
+func Test_responseHeaderTimeoutRoundTripper(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		shorten time.Duration
+		wantErr bool
+	}{
+		{"headers in time", 0, 0, false},
+		{"hung upstream", time.Second, 0, true},
+		{"shortened by context", 40 * time.Millisecond, time.Millisecond, true},
+		{"never extended by context", time.Second, time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &responseHeaderTimeoutRoundTripper{rt: &blockingRoundTripper{delay: tt.delay}, timeout: 50 * time.Millisecond}
+			req := httptest.NewRequest(http.MethodGet, "https://a:6443/api/v1/pods?watch=true", nil)
+			if tt.shorten > 0 {
+				req = req.WithContext(WithResponseHeaderTimeout(req.Context(), tt.shorten))
+			}
+			resp, err := rt.RoundTrip(req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrResponseHeaderTimeout) {
+					t.Fatalf("RoundTrip() error = %v, want %v", err, ErrResponseHeaderTimeout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RoundTrip() unexpected error = %v", err)
+			}
+			// body can be read after the timeout
+			time.Sleep(100 * time.Millisecond)
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil || string(data) != "ok" {
+				t.Errorf("ReadAll() = %q, %v", data, err)
+			}
+			resp.Body.Close()
+		})
+	}
+}
+
+func Test_transportProfile_responseHeaderTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		longRunning    bool
+		requestTimeout time.Duration
+		timeout        time.Duration
+		want           time.Duration
+	}{
+		{"default disabled", false, 60 * time.Second, 0, 70 * time.Second},
+		{"long running without timeout", true, 60 * time.Second, 0, 0},
+		{"long running bounded", true, 60 * time.Second, 10 * time.Second, 10 * time.Second},
+		{"short request keeps smaller profile timeout", false, 60 * time.Second, 90 * time.Second, 70 * time.Second},
+		{"short request follows request timeout", false, 5 * time.Minute, 0, 5*time.Minute + 10*time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := shortRequestTransportProfile(tt.requestTimeout)
+			if tt.longRunning {
+				profile = longRunningTransportProfile
+			}
+			if got := profile.responseHeaderTimeout(tt.timeout); got != tt.want {
+				t.Errorf("responseHeaderTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+const (
+	// UserAgentRulesAnnotationKey overrides upstream subset and flow control schema of dispatch policies for
+	// requests from some clients of one upstream cluster, e.g. throttling a client-go version with a known
+	// hot-loop bug without touching user identities. The value is a json encoded list of UserAgentRule,
+	// rules are matched in order and the first matched one takes effect.
+	UserAgentRulesAnnotationKey = "proxy.kubegateway.io/user-agent-rules"
+)
+
+// DefaultUserAgentRules are fleet-wide rules matched after rules of UserAgentRulesAnnotationKey of each upstream
+// cluster, e.g. throttling a buggy client version everywhere by a flow control schema name every cluster defines.
+// Endpoints differ by cluster, so they never set upstream subsets, and a rule is skipped for clusters without its
+// flow control schema. It is parsed from --proxy-user-agent-rules before upstream cluster controller starts.
+var DefaultUserAgentRules []UserAgentRule
+
+// UserAgentRule overrides the matched dispatch policy for requests whose normalized User-Agent matches one of UserAgents
+type UserAgentRule struct {
+	// UserAgents is a list of case-insensitive patterns of normalized User-Agent, see NormalizeUserAgent.
+	// "*" matches all, component name without version (e.g. kubectl) matches all versions of it,
+	// trailing "*" matches by prefix (e.g. my-operator/v0.18.*), others must match exactly.
+	UserAgents []string `json:"userAgents"`
+	// UpstreamSubset routes matched requests to a subset of upstream endpoints, empty means the subset of dispatch policy
+	UpstreamSubset []string `json:"upstreamSubset,omitempty"`
+	// FlowControlSchemaName limits matched requests by a flow control schema in spec.flowControl.schemas,
+	// empty means the schema of dispatch policy
+	FlowControlSchemaName string `json:"flowControlSchemaName,omitempty"`
+}
+
+// NormalizeUserAgent returns the lowercase component/version of User-Agent, e.g.
+// "kubectl/v1.18.19 (linux/amd64) kubernetes/f0d9e4c" is normalized to "kubectl/v1.18.19".
+func NormalizeUserAgent(userAgent string) string {
+	fields := strings.Fields(userAgent)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// Matches returns true if normalized User-Agent matches one of patterns of the rule
+func (r *UserAgentRule) Matches(normalized string) bool {
+	return MatchUserAgent(r.UserAgents, normalized)
+}
+
+// MatchUserAgent returns true if normalized User-Agent matches one of case-insensitive patterns, "*" matches all,
+// component name without version (e.g. kubectl) matches all versions of it, trailing "*" matches by prefix
+// (e.g. my-operator/v0.18.*), others must match exactly.
+func MatchUserAgent(patterns []string, normalized string) bool {
+	if len(normalized) == 0 {
+		return false
+	}
+	component := strings.SplitN(normalized, "/", 2)[0]
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == proxyv1alpha1.MatchAll:
+			return true
+		case strings.HasSuffix(pattern, "*"):
+			if strings.HasPrefix(normalized, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case !strings.Contains(pattern, "/"):
+			if pattern == component {
+				return true
+			}
+		case pattern == normalized:
+			return true
+		}
+	}
+	return false
+}
+
+// ParseUserAgentRules parses rules from annotation value, servers are endpoints in spec.servers and
+// flowControlSchemas are names of all flow control schemas of the cluster.
+func ParseUserAgentRules(value string, servers, flowControlSchemas sets.String) ([]UserAgentRule, error) {
+	rules, err := decodeUserAgentRules(value)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		for _, u := range rule.UpstreamSubset {
+			if !servers.Has(u) {
+				return nil, fmt.Errorf("upstream subset endpoint %q of rule[%d] must be present in servers", u, i)
+			}
+		}
+		if len(rule.FlowControlSchemaName) > 0 && !flowControlSchemas.Has(rule.FlowControlSchemaName) {
+			return nil, fmt.Errorf("flow control schema %q of rule[%d] must be present in spec.flowControl.schemas", rule.FlowControlSchemaName, i)
+		}
+	}
+	return rules, nil
+}
+
+// ParseDefaultUserAgentRules parses fleet-wide rules, see DefaultUserAgentRules. Every rule must name a flow
+// control schema and must not set an upstream subset.
+func ParseDefaultUserAgentRules(value string) ([]UserAgentRule, error) {
+	rules, err := decodeUserAgentRules(value)
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if len(rule.UpstreamSubset) > 0 {
+			return nil, fmt.Errorf("upstreamSubset of rule[%d] is not supported, endpoints differ by cluster", i)
+		}
+		if len(rule.FlowControlSchemaName) == 0 {
+			return nil, fmt.Errorf("flowControlSchemaName of rule[%d] must not be empty", i)
+		}
+	}
+	return rules, nil
+}
+
+func decodeUserAgentRules(value string) ([]UserAgentRule, error) {
+	rules := []UserAgentRule{}
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid user agent rules: %v", err)
+	}
+	for i, rule := range rules {
+		if len(rule.UserAgents) == 0 {
+			return nil, fmt.Errorf("userAgents of rule[%d] must not be empty", i)
+		}
+		for _, ua := range rule.UserAgents {
+			if len(strings.TrimSpace(ua)) == 0 || strings.ContainsAny(ua, " \t") {
+				return nil, fmt.Errorf("invalid user agent pattern %q of rule[%d], it must be a normalized component/version", ua, i)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func (c *ClusterInfo) syncUserAgentRules(annotations map[string]string, servers []proxyv1alpha1.UpstreamClusterServer, flowControlSchemas sets.String) error {
+	var rules []UserAgentRule
+	if value := annotations[UserAgentRulesAnnotationKey]; len(value) > 0 {
+		var err error
+		rules, err = ParseUserAgentRules(value, serverEndpoints(servers), flowControlSchemas)
+		if err != nil {
+			return err
+		}
+	}
+	if old, _ := c.currentUserAgentRules.Load().([]UserAgentRule); !reflect.DeepEqual(old, rules) {
+		klog.Infof("[cluster info] cluster=%q update user agent rules, rules=%d", c.Cluster, len(rules))
+	}
+	c.currentUserAgentRules.Store(rules)
+	return nil
+}
+
+func serverEndpoints(servers []proxyv1alpha1.UpstreamClusterServer) sets.String {
+	endpoints := sets.NewString()
+	for _, server := range servers {
+		endpoints.Insert(server.Endpoint)
+	}
+	return endpoints
+}
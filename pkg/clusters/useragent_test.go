@@ -0,0 +1,212 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+func TestNormalizeUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"kubectl/v1.18.19 (linux/amd64) kubernetes/f0d9e4c", "kubectl/v1.18.19"},
+		{"My-Operator/v0.18.3", "my-operator/v0.18.3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeUserAgent(tt.userAgent); got != tt.want {
+			t.Errorf("NormalizeUserAgent(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestUserAgentRule_Matches(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		normalized string
+		want       bool
+	}{
+		{"match all", []string{"*"}, "kubectl/v1.18.19", true},
+		{"component", []string{"kubectl"}, "kubectl/v1.18.19", true},
+		{"exact", []string{"kubectl/v1.18.19"}, "kubectl/v1.18.19", true},
+		{"prefix", []string{"My-Operator/v0.18.*"}, "my-operator/v0.18.3", true},
+		{"other version", []string{"my-operator/v0.18.*"}, "my-operator/v0.19.0", false},
+		{"other component", []string{"kubectl"}, "kubelet/v1.18.19", false},
+		{"empty user agent", []string{"*"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &UserAgentRule{UserAgents: tt.patterns}
+			if got := rule.Matches(tt.normalized); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUserAgentRules(t *testing.T) {
+	servers := sets.NewString("https://127.0.0.1:443")
+	schemas := sets.NewString("throttle")
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `[{"userAgents":["my-operator/v0.18.*"],"flowControlSchemaName":"throttle","upstreamSubset":["https://127.0.0.1:443"]}]`, false},
+		{"empty user agents", `[{"flowControlSchemaName":"throttle"}]`, true},
+		{"raw user agent", `[{"userAgents":["kubectl/v1.18.19 (linux/amd64)"]}]`, true},
+		{"unknown schema", `[{"userAgents":["kubectl"],"flowControlSchemaName":"unknown"}]`, true},
+		{"unknown server", `[{"userAgents":["kubectl"],"upstreamSubset":["https://127.0.0.2:443"]}]`, true},
+		{"unknown field", `[{"userAgents":["kubectl"],"qps":1}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseUserAgentRules(tt.value, servers, schemas); (err != nil) != tt.wantErr {
+				t.Errorf("ParseUserAgentRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDefaultUserAgentRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{"valid", `[{"userAgents":["my-operator/v0.18.*"],"flowControlSchemaName":"throttle"}]`, false},
+		{"missing schema", `[{"userAgents":["kubectl"]}]`, true},
+		{"upstream subset", `[{"userAgents":["kubectl"],"flowControlSchemaName":"throttle","upstreamSubset":["https://127.0.0.1:443"]}]`, true},
+		{"raw user agent", `[{"userAgents":["kubectl/v1.18.19 (linux/amd64)"],"flowControlSchemaName":"throttle"}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDefaultUserAgentRules(tt.value); (err != nil) != tt.wantErr {
+				t.Errorf("ParseDefaultUserAgentRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterInfo_MatchRequest_UserAgentRules(t *testing.T) {
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.FlowControl = proxyv1alpha1.FlowControl{
+		Schemas: []proxyv1alpha1.FlowControlSchema{
+			{
+				Name: "throttle",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 1},
+				},
+			},
+		},
+	}
+	cluster.Annotations = map[string]string{
+		UserAgentRulesAnnotationKey: `[{"userAgents":["my-operator/v0.18.*"],"flowControlSchemaName":"throttle"}]`,
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	schemaOf := func(userAgent string) (string, string) {
+		picker, err := info.MatchRequest(authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "test"},
+			Verb:            "list",
+			Resource:        "pods",
+			ResourceRequest: true,
+		}, userAgent)
+		if err != nil {
+			t.Fatalf("MatchRequest() error = %v", err)
+		}
+		return gatewayflowcontrol.NameOf(picker.FlowControl()), picker.FlowSchema()
+	}
+
+	if got, flowSchema := schemaOf("my-operator/v0.18.3 (linux/amd64)"); got != "throttle" || flowSchema != "user-agent-rule-0" {
+		t.Errorf("flow control of matched user agent = %q by %q, want throttle by user-agent-rule-0", got, flowSchema)
+	}
+	if got, flowSchema := schemaOf("my-operator/v0.19.0 (linux/amd64)"); got == "throttle" || flowSchema != "dispatch-policy-0" {
+		t.Errorf("flow control of unmatched user agent = %q by %q, want the one of dispatch-policy-0", got, flowSchema)
+	}
+}
+
+func TestClusterInfo_MatchRequest_DefaultUserAgentRules(t *testing.T) {
+	defer func(rules []UserAgentRule) { DefaultUserAgentRules = rules }(DefaultUserAgentRules)
+	DefaultUserAgentRules = []UserAgentRule{
+		{UserAgents: []string{"my-operator"}, FlowControlSchemaName: "throttle"},
+		{UserAgents: []string{"kubectl"}, FlowControlSchemaName: "undefined"},
+	}
+
+	cluster := newTestUpstreamClusterConfig()
+	cluster.Spec.FlowControl = proxyv1alpha1.FlowControl{
+		Schemas: []proxyv1alpha1.FlowControlSchema{
+			{
+				Name: "throttle",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 1},
+				},
+			},
+			{
+				Name: "strict",
+				FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+					TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 1},
+				},
+			},
+		},
+	}
+	cluster.Annotations = map[string]string{
+		UserAgentRulesAnnotationKey: `[{"userAgents":["my-operator/v0.18.*"],"flowControlSchemaName":"strict"}]`,
+	}
+	info, err := CreateClusterInfo(cluster, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	defer info.Stop()
+
+	schemaOf := func(userAgent string) (string, string) {
+		picker, err := info.MatchRequest(authorizer.AttributesRecord{
+			User:            &user.DefaultInfo{Name: "test"},
+			Verb:            "list",
+			Resource:        "pods",
+			ResourceRequest: true,
+		}, userAgent)
+		if err != nil {
+			t.Fatalf("MatchRequest() error = %v", err)
+		}
+		return gatewayflowcontrol.NameOf(picker.FlowControl()), picker.FlowSchema()
+	}
+
+	// rules of the cluster take precedence over fleet-wide rules
+	if got, flowSchema := schemaOf("my-operator/v0.18.3"); got != "strict" || flowSchema != "user-agent-rule-0" {
+		t.Errorf("flow control = %q by %q, want strict by user-agent-rule-0", got, flowSchema)
+	}
+	if got, flowSchema := schemaOf("my-operator/v0.19.0"); got != "throttle" || flowSchema != "default-user-agent-rule-0" {
+		t.Errorf("flow control = %q by %q, want throttle by default-user-agent-rule-0", got, flowSchema)
+	}
+	// the cluster does not define the schema of the fleet-wide rule
+	if _, flowSchema := schemaOf("kubectl/v1.18.19"); flowSchema != "dispatch-policy-0" {
+		t.Errorf("flow schema = %q, want dispatch-policy-0", flowSchema)
+	}
+}
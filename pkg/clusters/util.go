@@ -26,7 +26,7 @@ import (
 	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
 )
 
-func buildClusterRESTConfig(cluster *proxyv1alpha1.UpstreamCluster) (*rest.Config, error) {
+func buildClusterRESTConfig(cluster *proxyv1alpha1.UpstreamCluster, credentials UpstreamCredentials) (*rest.Config, error) {
 	httpScheme := "https"
 	if len(cluster.Spec.Servers) > 0 {
 		server := cluster.Spec.Servers[0]
@@ -40,6 +40,9 @@ func buildClusterRESTConfig(cluster *proxyv1alpha1.UpstreamCluster) (*rest.Confi
 
 	cfg := newRESTConfig()
 	cfg.BearerToken = string(cluster.Spec.ClientConfig.BearerToken)
+	if err := applyUpstreamCredential(cfg, cluster.Annotations, credentials); err != nil {
+		return nil, fmt.Errorf("failed to apply upstream credential of cluster %q, err: %v", cluster.Name, err)
+	}
 
 	if cluster.Spec.ClientConfig.QPS > 0 {
 		qps := calQPS(cluster.Spec.ClientConfig.QPS, cluster.Spec.ClientConfig.QPSDivisor)
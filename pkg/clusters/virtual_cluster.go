@@ -0,0 +1,213 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+const (
+	// kinds of selectors injected into requests of virtual clusters
+	SelectorKindLabel = "label"
+	SelectorKindField = "field"
+)
+
+// DefaultVirtualClusters map tenant-facing hostnames onto shared upstream clusters, they are
+// tried after exact match and before host templates. LookupVirtualCluster reads the map for every
+// request without locking, VirtualClusterOptions replaces it once and nothing mutates it afterwards.
+var DefaultVirtualClusters = map[string]*VirtualCluster{}
+
+// VirtualCluster exposes a subset of namespaces of a shared upstream cluster to one tenant by a dedicated
+// hostname, e.g. tenant-a.gateway.example.com=shared-1:tenant-a-*|tenant-a only allows requests to
+// namespace tenant-a and namespaces prefixed with tenant-a- of cluster shared-1.
+//
+// Requests escaping the namespaces, e.g. cluster scoped resources and lists across all namespaces, are
+// rejected by gateway. It is soft multi-tenancy, upstream authorization still applies to every request.
+type VirtualCluster struct {
+	// Hostname is the tenant-facing hostname
+	Hostname string
+	// Cluster is the name of shared upstream cluster
+	Cluster string
+
+	namespaces []string
+	prefixes   []string
+
+	// selectors intersected with selectors of LIST, WATCH and DELETECOLLECTION requests, nil means no constraint
+	labelSelector labels.Selector
+	fieldSelector fields.Selector
+}
+
+// ParseVirtualCluster parses virtual cluster in format hostname=cluster:namespace|prefix-*|...
+func ParseVirtualCluster(value string) (*VirtualCluster, error) {
+	kv := strings.SplitN(strings.ToLower(strings.TrimSpace(value)), "=", 2)
+	if len(kv) != 2 || len(kv[0]) == 0 {
+		return nil, fmt.Errorf("invalid virtual cluster %q, must be in format hostname=cluster:namespaces", value)
+	}
+	parts := strings.SplitN(kv[1], ":", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return nil, fmt.Errorf("invalid virtual cluster %q, must be in format hostname=cluster:namespaces", value)
+	}
+	if kv[0] == parts[0] {
+		return nil, fmt.Errorf("invalid virtual cluster %q, hostname must not be the same as cluster name", value)
+	}
+	vc := &VirtualCluster{Hostname: kv[0], Cluster: parts[0]}
+	for _, ns := range strings.Split(parts[1], "|") {
+		ns = strings.TrimSpace(ns)
+		if prefix := strings.TrimSuffix(ns, "*"); prefix != ns {
+			if len(prefix) == 0 || strings.Contains(prefix, "*") {
+				return nil, fmt.Errorf("invalid namespace prefix %q of virtual cluster %s", ns, vc.Hostname)
+			}
+			vc.prefixes = append(vc.prefixes, prefix)
+			continue
+		}
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q of virtual cluster %s: %s", ns, vc.Hostname, strings.Join(errs, ", "))
+		}
+		vc.namespaces = append(vc.namespaces, ns)
+	}
+	return vc, nil
+}
+
+// AllowNamespace returns true if namespace is visible to tenant of the virtual cluster
+func (v *VirtualCluster) AllowNamespace(namespace string) bool {
+	for _, ns := range v.namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	for _, prefix := range v.prefixes {
+		if strings.HasPrefix(namespace, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize returns an error if request attributes escape the namespaces of the virtual cluster.
+// Non-resource requests, e.g. discovery and version, are always allowed.
+func (v *VirtualCluster) Authorize(attrs authorizer.Attributes) error {
+	if !attrs.IsResourceRequest() {
+		return nil
+	}
+	if attrs.GetAPIGroup() == "" && attrs.GetResource() == "namespaces" {
+		if len(attrs.GetName()) > 0 && v.AllowNamespace(attrs.GetName()) {
+			return nil
+		}
+		return fmt.Errorf("virtual cluster %s only allows %s of namespaces %s", v.Hostname, attrs.GetVerb(), v.namespacesString())
+	}
+	if len(attrs.GetNamespace()) == 0 {
+		return fmt.Errorf("virtual cluster %s does not allow cluster scoped or all namespaces access, only namespaces %s are allowed", v.Hostname, v.namespacesString())
+	}
+	if !v.AllowNamespace(attrs.GetNamespace()) {
+		return fmt.Errorf("virtual cluster %s does not allow access to namespace %s, only namespaces %s are allowed", v.Hostname, attrs.GetNamespace(), v.namespacesString())
+	}
+	return nil
+}
+
+// AddSelector constrains collection requests of the virtual cluster by a label or field selector,
+// e.g. label selector tenant=foo only returns objects labeled with tenant=foo. Selectors added
+// more than once are intersected.
+func (v *VirtualCluster) AddSelector(kind, selector string) error {
+	switch kind {
+	case SelectorKindLabel:
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return fmt.Errorf("invalid label selector %q of virtual cluster %s: %v", selector, v.Hostname, err)
+		}
+		v.labelSelector = intersectLabelSelectors(v.labelSelector, s)
+	case SelectorKindField:
+		s, err := fields.ParseSelector(selector)
+		if err != nil {
+			return fmt.Errorf("invalid field selector %q of virtual cluster %s: %v", selector, v.Hostname, err)
+		}
+		v.fieldSelector = intersectFieldSelectors(v.fieldSelector, s)
+	default:
+		return fmt.Errorf("invalid selector kind %q of virtual cluster %s, must be %s or %s", kind, v.Hostname, SelectorKindLabel, SelectorKindField)
+	}
+	return nil
+}
+
+// InjectSelectors intersects selectors of the virtual cluster with selectors in query of collection requests,
+// so tenants only see objects matched by both. An unparsable client selector is rejected instead of
+// passed through, otherwise upstream may interpret it differently and escape the constraint.
+func (v *VirtualCluster) InjectSelectors(verb string, query url.Values) error {
+	switch verb {
+	case "list", "watch", "deletecollection":
+	default:
+		return nil
+	}
+	if v.labelSelector != nil {
+		client, err := labels.Parse(query.Get("labelSelector"))
+		if err != nil {
+			return fmt.Errorf("invalid labelSelector: %v", err)
+		}
+		query.Set("labelSelector", intersectLabelSelectors(client, v.labelSelector).String())
+	}
+	if v.fieldSelector != nil {
+		client, err := fields.ParseSelector(query.Get("fieldSelector"))
+		if err != nil {
+			return fmt.Errorf("invalid fieldSelector: %v", err)
+		}
+		query.Set("fieldSelector", intersectFieldSelectors(client, v.fieldSelector).String())
+	}
+	return nil
+}
+
+func intersectLabelSelectors(a, b labels.Selector) labels.Selector {
+	if a == nil || a.Empty() {
+		return b
+	}
+	requirements, _ := b.Requirements()
+	return a.Add(requirements...)
+}
+
+func intersectFieldSelectors(a, b fields.Selector) fields.Selector {
+	if a == nil || a.Empty() {
+		return b
+	}
+	return fields.AndSelectors(a, b)
+}
+
+func (v *VirtualCluster) namespacesString() string {
+	patterns := append([]string{}, v.namespaces...)
+	for _, prefix := range v.prefixes {
+		patterns = append(patterns, prefix+"*")
+	}
+	return strings.Join(patterns, "|")
+}
+
+func (v *VirtualCluster) String() string {
+	s := v.Hostname + "=" + v.Cluster + ":" + v.namespacesString()
+	if v.labelSelector != nil {
+		s += " labelSelector=" + v.labelSelector.String()
+	}
+	if v.fieldSelector != nil {
+		s += " fieldSelector=" + v.fieldSelector.String()
+	}
+	return s
+}
+
+// LookupVirtualCluster returns the virtual cluster of hostname in DefaultVirtualClusters
+func LookupVirtualCluster(hostname string) (*VirtualCluster, bool) {
+	vc, ok := DefaultVirtualClusters[strings.ToLower(hostname)]
+	return vc, ok
+}
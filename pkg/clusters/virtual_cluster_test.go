@@ -0,0 +1,119 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"net/url"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func Test_ParseVirtualCluster_Invalid(t *testing.T) {
+	tests := []string{
+		"tenant-a.gateway.example.com",
+		"tenant-a.gateway.example.com=shared-1",
+		"tenant-a.gateway.example.com=shared-1:",
+		"shared-1=shared-1:tenant-a",
+		"tenant-a.gateway.example.com=shared-1:*",
+		"tenant-a.gateway.example.com=shared-1:tenant_a",
+	}
+	for _, value := range tests {
+		if _, err := ParseVirtualCluster(value); err == nil {
+			t.Errorf("ParseVirtualCluster(%q) expected error", value)
+		}
+	}
+}
+
+func Test_VirtualCluster_Authorize(t *testing.T) {
+	vc, err := ParseVirtualCluster("Tenant-A.gateway.example.com=shared-1:tenant-a|tenant-a-*")
+	if err != nil {
+		t.Fatalf("ParseVirtualCluster() error = %v", err)
+	}
+	if vc.Hostname != "tenant-a.gateway.example.com" || vc.Cluster != "shared-1" {
+		t.Fatalf("ParseVirtualCluster() = %v", vc)
+	}
+
+	tests := []struct {
+		name    string
+		attrs   authorizer.AttributesRecord
+		wantErr bool
+	}{
+		{"discovery", authorizer.AttributesRecord{Verb: "get", Path: "/apis"}, false},
+		{"namespaced resource", authorizer.AttributesRecord{Verb: "list", Namespace: "tenant-a", Resource: "pods", ResourceRequest: true}, false},
+		{"prefixed namespace", authorizer.AttributesRecord{Verb: "get", Namespace: "tenant-a-dev", Resource: "pods", Name: "foo", ResourceRequest: true}, false},
+		{"other namespace", authorizer.AttributesRecord{Verb: "get", Namespace: "tenant-b", Resource: "pods", Name: "foo", ResourceRequest: true}, true},
+		{"all namespaces", authorizer.AttributesRecord{Verb: "list", Resource: "pods", ResourceRequest: true}, true},
+		{"cluster scoped", authorizer.AttributesRecord{Verb: "get", Resource: "nodes", Name: "node-1", ResourceRequest: true}, true},
+		{"own namespace", authorizer.AttributesRecord{Verb: "get", Resource: "namespaces", Name: "tenant-a", ResourceRequest: true}, false},
+		{"list namespaces", authorizer.AttributesRecord{Verb: "list", Resource: "namespaces", ResourceRequest: true}, true},
+		{"other namespace object", authorizer.AttributesRecord{Verb: "delete", Resource: "namespaces", Name: "kube-system", ResourceRequest: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := vc.Authorize(tt.attrs); (err != nil) != tt.wantErr {
+				t.Errorf("Authorize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_VirtualCluster_InjectSelectors(t *testing.T) {
+	vc, err := ParseVirtualCluster("tenant-a.gateway.example.com=shared-1:tenant-a")
+	if err != nil {
+		t.Fatalf("ParseVirtualCluster() error = %v", err)
+	}
+	if err := vc.AddSelector(SelectorKindLabel, "tenant=foo"); err != nil {
+		t.Fatalf("AddSelector() error = %v", err)
+	}
+	if err := vc.AddSelector(SelectorKindField, "spec.schedulerName=tenant-a"); err != nil {
+		t.Fatalf("AddSelector() error = %v", err)
+	}
+	if err := vc.AddSelector("annotation", "a=b"); err == nil {
+		t.Errorf("AddSelector() expected error of unknown kind")
+	}
+
+	tests := []struct {
+		name      string
+		verb      string
+		query     string
+		wantLabel string
+		wantField string
+		wantErr   bool
+	}{
+		{"list without selectors", "list", "", "tenant=foo", "spec.schedulerName=tenant-a", false},
+		{"watch with selectors", "watch", "labelSelector=app%3Dweb&fieldSelector=status.phase%3DRunning", "app=web,tenant=foo", "status.phase=Running,spec.schedulerName=tenant-a", false},
+		{"get is not constrained", "get", "labelSelector=app%3Dweb", "app=web", "", false},
+		{"invalid client selector", "list", "labelSelector=app%3D%3D%3Dweb", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			err := vc.InjectSelectors(tt.verb, query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InjectSelectors() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := query.Get("labelSelector"); got != tt.wantLabel {
+				t.Errorf("InjectSelectors() labelSelector = %v, want %v", got, tt.wantLabel)
+			}
+			if got := query.Get("fieldSelector"); got != tt.wantField {
+				t.Errorf("InjectSelectors() fieldSelector = %v, want %v", got, tt.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusters
+
+import (
+	"sync"
+	"sync/atomic"
+
+	gatewayfeatures "github.com/kubewharf/kubegateway/pkg/gateway/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// watchLimiter limits concurrent watch streams of each user, max <= 0 means unlimited.
+type watchLimiter struct {
+	max int32
+	// overrides of max for specific users, it is read by every watch without locking and never mutated
+	overrides map[string]int32
+
+	mu     sync.Mutex
+	counts map[string]int32
+}
+
+func newWatchLimiter(max int32, overrides map[string]int32) *watchLimiter {
+	return &watchLimiter{
+		max:       max,
+		overrides: overrides,
+		counts:    map[string]int32{},
+	}
+}
+
+func (l *watchLimiter) Max() int32 {
+	return atomic.LoadInt32(&l.max)
+}
+
+func (l *watchLimiter) SetMax(max int32) bool {
+	return atomic.SwapInt32(&l.max, max) != max
+}
+
+// limitOf returns the watch limit of user, overrides take precedence over the cluster budget
+func (l *watchLimiter) limitOf(user string) int32 {
+	if max, ok := l.overrides[user]; ok {
+		return max
+	}
+	return l.Max()
+}
+
+// TryAcquire returns false and the limit if user has reached its limit
+func (l *watchLimiter) TryAcquire(user string) (int32, bool) {
+	max := l.limitOf(user)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.counts[user]
+	if max > 0 && n >= max {
+		return max, false
+	}
+	l.counts[user] = n + 1
+	return max, true
+}
+
+func (l *watchLimiter) Release(user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := l.counts[user] - 1
+	if n <= 0 {
+		delete(l.counts, user)
+		return
+	}
+	l.counts[user] = n
+}
+
+// Current returns the number of concurrent watches of user
+func (l *watchLimiter) Current(user string) int32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[user]
+}
+
+// TryAcquireWatchBudget tries to acquire a slot of the user's concurrent watches budget of this cluster,
+// it returns the limit of user and ReleaseWatchBudget must be called after the watch finished if it returns true.
+func (c *ClusterInfo) TryAcquireWatchBudget(user string) (int32, bool) {
+	if !gatewayfeatures.Enabled(gatewayfeatures.ClusterResourceBudget) {
+		return 0, true
+	}
+	max, ok := c.watchBudget.TryAcquire(user)
+	if !ok {
+		metrics.RecordClusterBudgetRejected(c.Cluster, budgetWatches)
+		return max, false
+	}
+	metrics.RecordClusterBudgetAcquired(c.Cluster, budgetWatches)
+	return max, true
+}
+
+func (c *ClusterInfo) ReleaseWatchBudget(user string) {
+	if !gatewayfeatures.Enabled(gatewayfeatures.ClusterResourceBudget) {
+		return
+	}
+	c.watchBudget.Release(user)
+	metrics.RecordClusterBudgetReleased(c.Cluster, budgetWatches)
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcontrol
+
+import (
+	"math"
+	"time"
+)
+
+// Balance is the number of tokens left in a token bucket flow control at Time. It is exported by
+// retiring gateway replicas and restored by their replacements, so the replacements do not start
+// with full buckets and admit a burst that the old replicas have already spent.
+type Balance struct {
+	Tokens float64   `json:"tokens"`
+	Time   time.Time `json:"time"`
+}
+
+type balancer interface {
+	Balance() (Balance, bool)
+	RestoreBalance(Balance) bool
+}
+
+// BalanceOf returns the token balance of flow control, false means it is not a token bucket
+func BalanceOf(f FlowControl) (Balance, bool) {
+	b, ok := f.(balancer)
+	if !ok {
+		return Balance{}, false
+	}
+	return b.Balance()
+}
+
+// RestoreBalance sets the token balance of flow control, tokens refilled since the balance was
+// taken are added. It returns false if the flow control is not a token bucket.
+func RestoreBalance(f FlowControl, balance Balance) bool {
+	b, ok := f.(balancer)
+	if !ok {
+		return false
+	}
+	return b.RestoreBalance(balance)
+}
+
+func (b *tokenBucket) balance() Balance {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+	return Balance{Tokens: b.tokens, Time: b.last}
+}
+
+func (b *tokenBucket) restore(balance Balance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	tokens := balance.Tokens
+	if elapsed := now.Sub(balance.Time); elapsed > 0 {
+		tokens += elapsed.Seconds() * b.qps
+	}
+	b.tokens = math.Max(0, math.Min(b.burst, tokens))
+	b.last = now
+}
+
+func (f *resizeableTokenBucket) Balance() (Balance, bool) {
+	return f.rateLimiter.balance(), true
+}
+
+func (f *resizeableTokenBucket) RestoreBalance(balance Balance) bool {
+	f.rateLimiter.restore(balance)
+	return true
+}
+
+func (f *observedFlowControl) Balance() (Balance, bool) {
+	return BalanceOf(f.FlowControl)
+}
+
+func (f *observedFlowControl) RestoreBalance(balance Balance) bool {
+	return RestoreBalance(f.FlowControl, balance)
+}
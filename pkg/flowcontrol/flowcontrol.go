@@ -17,9 +17,9 @@ package flowcontrol
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zoumo/golib/lock/maxinflight"
-	"k8s.io/client-go/util/flowcontrol"
 
 	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
 )
@@ -50,6 +50,13 @@ func (f *FlowControls) Delete(name string) {
 	f.data.Delete(name)
 }
 
+// Range calls fn for each flow control sequentially until fn returns false
+func (f *FlowControls) Range(fn func(name string, fl FlowControl) bool) {
+	f.data.Range(func(key, value interface{}) bool {
+		return fn(key.(string), value.(FlowControl))
+	})
+}
+
 func (f *FlowControls) Len() int {
 	length := 0
 	f.data.Range(func(key, value interface{}) bool {
@@ -72,12 +79,15 @@ type FlowControl interface {
 }
 
 var (
-	DefaultFlowControl = NewFlowControl(proxyv1alpha1.FlowControlSchema{
+	// DefaultFlowControlSchema is used by dispatch policies without flow control schema
+	DefaultFlowControlSchema = proxyv1alpha1.FlowControlSchema{
 		Name: "system-default",
 		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
 			Exempt: &proxyv1alpha1.ExemptFlowControlSchema{},
 		},
-	})
+	}
+
+	DefaultFlowControl = NewFlowControl(DefaultFlowControlSchema)
 )
 
 func GuessFlowControlSchemaType(config proxyv1alpha1.FlowControlSchema) proxyv1alpha1.FlowControlSchemaType {
@@ -105,7 +115,7 @@ func NewFlowControl(schema proxyv1alpha1.FlowControlSchema) FlowControl {
 		}
 	case proxyv1alpha1.TokenBucket:
 		return &resizeableTokenBucket{
-			rateLimiter: flowcontrol.NewTokenBucketRateLimiter(float32(schema.TokenBucket.QPS), int(schema.TokenBucket.Burst)),
+			rateLimiter: newTokenBucket(float64(schema.TokenBucket.QPS), int(schema.TokenBucket.Burst)),
 			name:        name,
 			typ:         typ,
 			qps:         uint32(schema.TokenBucket.QPS),
@@ -124,6 +134,42 @@ type flowControl struct {
 	name string
 	typ  proxyv1alpha1.FlowControlSchemaType
 	max  uint32
+	// inflight is the number of acquired tokens, it is only used by Status
+	inflight int32
+}
+
+func (f *flowControl) TryAcquire() bool {
+	if !f.TokenBucket.TryAcquire() {
+		return false
+	}
+	atomic.AddInt32(&f.inflight, 1)
+	return true
+}
+
+func (f *flowControl) Release() {
+	atomic.AddInt32(&f.inflight, -1)
+	f.TokenBucket.Release()
+}
+
+func (f *flowControl) Status() (Status, bool) {
+	if f.typ != proxyv1alpha1.MaxRequestsInflight {
+		return Status{}, false
+	}
+	limit := int(f.max)
+	remaining := limit - int(atomic.LoadInt32(&f.inflight))
+	if remaining < 0 {
+		remaining = 0
+	}
+	status := Status{Limit: limit, Remaining: remaining}
+	if remaining == 0 {
+		status.Reset = inflightResetHint
+	}
+	return status, true
+}
+
+// Name returns the name of flow control schema
+func (f *flowControl) Name() string {
+	return f.name
 }
 
 func (f *flowControl) String() string {
@@ -141,7 +187,7 @@ func (f *flowControl) Resize(n uint32, burst uint32) bool {
 }
 
 type resizeableTokenBucket struct {
-	rateLimiter flowcontrol.RateLimiter
+	rateLimiter *tokenBucket
 	name        string
 	typ         proxyv1alpha1.FlowControlSchemaType
 	qps         uint32
@@ -159,7 +205,7 @@ func (f *resizeableTokenBucket) String() string {
 func (f *resizeableTokenBucket) Resize(n uint32, burst uint32) bool {
 	resized := false
 	if f.qps != n || f.burst != burst {
-		f.rateLimiter = flowcontrol.NewTokenBucketRateLimiter(float32(n), int(burst))
+		f.rateLimiter = newTokenBucket(float64(n), int(burst))
 		f.qps = n
 		f.burst = burst
 		resized = true
@@ -169,3 +215,7 @@ func (f *resizeableTokenBucket) Resize(n uint32, burst uint32) bool {
 
 func (f *resizeableTokenBucket) Release() {
 }
+
+func (f *resizeableTokenBucket) Status() (Status, bool) {
+	return f.rateLimiter.Status(), true
+}
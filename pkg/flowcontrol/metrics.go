@@ -0,0 +1,129 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcontrol
+
+import (
+	"time"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// reasons of rejected requests, same as apiserver priority and fairness
+	rejectReasonConcurrencyLimit = "concurrency-limit"
+	rejectReasonRateLimit        = "rate-limit"
+)
+
+// Flow is a group of requests sharing a flow control schema, like flows of apiserver priority and fairness.
+// Schema names the rule which selected the flow control schema, e.g. a dispatch policy, and Distinguisher
+// is the user of requests.
+type Flow struct {
+	Schema        string
+	Distinguisher string
+}
+
+// flowAcquirer is implemented by flow controls recording metrics of each flow
+type flowAcquirer interface {
+	TryAcquireFlow(flow Flow, seats int) (int, bool)
+	ReleaseFlow(flow Flow, seats int)
+}
+
+// TryAcquireFlow is TryAcquireSeats for a request of flow, so metrics of the flow control are labeled by
+// the rule selecting it and rejections are counted per user. ReleaseFlow must be called with the charged
+// seats after the request finished if it returns true.
+func TryAcquireFlow(f FlowControl, flow Flow, seats int) (int, bool) {
+	if o, ok := f.(flowAcquirer); ok {
+		return o.TryAcquireFlow(flow, seats)
+	}
+	return TryAcquireSeats(f, seats)
+}
+
+// ReleaseFlow gives back seats charged by TryAcquireFlow
+func ReleaseFlow(f FlowControl, flow Flow, seats int) {
+	if o, ok := f.(flowAcquirer); ok {
+		o.ReleaseFlow(flow, seats)
+		return
+	}
+	ReleaseSeats(f, seats)
+}
+
+// observedFlowControl records metrics of a flow control schema of one upstream cluster
+type observedFlowControl struct {
+	FlowControl
+	serverName string
+	name       string
+	typ        proxyv1alpha1.FlowControlSchemaType
+}
+
+// NewObservedFlowControl creates a flow control from schema which records metrics labeled by serverName
+func NewObservedFlowControl(serverName string, schema proxyv1alpha1.FlowControlSchema) FlowControl {
+	f := &observedFlowControl{
+		FlowControl: NewFlowControl(schema),
+		serverName:  serverName,
+		name:        schema.Name,
+		typ:         GuessFlowControlSchemaType(schema),
+	}
+	switch f.typ {
+	case proxyv1alpha1.MaxRequestsInflight:
+		metrics.RecordFlowControlLimit(serverName, f.name, uint32(schema.MaxRequestsInflight.Max))
+	case proxyv1alpha1.TokenBucket:
+		metrics.RecordFlowControlLimit(serverName, f.name, uint32(schema.TokenBucket.QPS))
+	default:
+		metrics.RecordFlowControlLimit(serverName, f.name, 0)
+	}
+	return f
+}
+
+func (f *observedFlowControl) TryAcquire() bool {
+	_, ok := f.TryAcquireFlow(Flow{}, 1)
+	return ok
+}
+
+func (f *observedFlowControl) Release() {
+	f.ReleaseFlow(Flow{}, 1)
+}
+
+func (f *observedFlowControl) TryAcquireFlow(flow Flow, seats int) (int, bool) {
+	start := time.Now()
+	seats, ok := TryAcquireSeats(f.FlowControl, seats)
+	reason := rejectReasonConcurrencyLimit
+	if f.typ == proxyv1alpha1.TokenBucket {
+		reason = rejectReasonRateLimit
+	}
+	metrics.RecordFlowControlAdmission(f.serverName, f.name, flow.Schema, flow.Distinguisher, seats, ok, reason, time.Since(start))
+	return seats, ok
+}
+
+func (f *observedFlowControl) ReleaseFlow(flow Flow, seats int) {
+	ReleaseSeats(f.FlowControl, seats)
+	metrics.RecordFlowControlRelease(f.serverName, f.name, flow.Schema, seats)
+}
+
+func (f *observedFlowControl) Resize(n uint32, burst uint32) bool {
+	resized := f.FlowControl.Resize(n, burst)
+	if resized {
+		metrics.RecordFlowControlLimit(f.serverName, f.name, n)
+	}
+	return resized
+}
+
+func (f *observedFlowControl) Status() (Status, bool) {
+	return StatusOf(f.FlowControl)
+}
+
+func (f *observedFlowControl) Name() string {
+	return f.name
+}
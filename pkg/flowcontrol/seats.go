@@ -0,0 +1,106 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcontrol
+
+// seatsAcquirer is implemented by flow controls charging one request more than one token
+type seatsAcquirer interface {
+	TryAcquireSeats(seats int) (int, bool)
+	ReleaseSeats(seats int)
+}
+
+// TryAcquireSeats takes seats tokens of flow control at once, so an expensive request is charged
+// proportionally to its estimated cost. Seats are clamped to the capacity of the flow control, so an
+// expensive request is never rejected forever, the charged seats are returned. ReleaseSeats must be
+// called with the charged seats after the request finished if it returns true.
+func TryAcquireSeats(f FlowControl, seats int) (int, bool) {
+	if seats <= 1 {
+		return 1, f.TryAcquire()
+	}
+	if s, ok := f.(seatsAcquirer); ok {
+		return s.TryAcquireSeats(seats)
+	}
+	return seats, acquireEach(f, seats)
+}
+
+// ReleaseSeats gives back seats charged by TryAcquireSeats
+func ReleaseSeats(f FlowControl, seats int) {
+	if seats <= 1 {
+		f.Release()
+		return
+	}
+	if s, ok := f.(seatsAcquirer); ok {
+		s.ReleaseSeats(seats)
+		return
+	}
+	for i := 0; i < seats; i++ {
+		f.Release()
+	}
+}
+
+// acquireEach acquires seats tokens one by one, acquired ones are released if any fails
+func acquireEach(f FlowControl, seats int) bool {
+	for i := 0; i < seats; i++ {
+		if !f.TryAcquire() {
+			for ; i > 0; i-- {
+				f.Release()
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (f *flowControl) TryAcquireSeats(seats int) (int, bool) {
+	if max := int(f.max); max > 0 && seats > max {
+		seats = max
+	}
+	return seats, acquireEach(f, seats)
+}
+
+func (f *flowControl) ReleaseSeats(seats int) {
+	for i := 0; i < seats; i++ {
+		f.Release()
+	}
+}
+
+func (f *resizeableTokenBucket) TryAcquireSeats(seats int) (int, bool) {
+	return f.rateLimiter.TryAcceptN(seats)
+}
+
+func (f *resizeableTokenBucket) ReleaseSeats(int) {
+}
+
+func (f *observedFlowControl) TryAcquireSeats(seats int) (int, bool) {
+	return f.TryAcquireFlow(Flow{}, seats)
+}
+
+func (f *observedFlowControl) ReleaseSeats(seats int) {
+	f.ReleaseFlow(Flow{}, seats)
+}
+
+// TryAcceptN takes n tokens at once, n is clamped to burst and the taken tokens are returned
+func (b *tokenBucket) TryAcceptN(n int) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+	if burst := int(b.burst); n > burst && burst > 0 {
+		n = burst
+	}
+	if b.tokens < float64(n) {
+		return n, false
+	}
+	b.tokens -= float64(n)
+	return n, true
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcontrol
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// inflightResetHint is the reset duration of an exhausted max requests inflight flow control,
+// there is no way to know when an inflight request finishes.
+const inflightResetHint = time.Second
+
+// Status is the quota state of a flow control, it is exposed to clients by RateLimit-* response headers
+type Status struct {
+	// Limit is the burst of token bucket or the max of inflight requests
+	Limit int
+	// Remaining is the number of requests which can be admitted immediately
+	Remaining int
+	// Reset is the duration until the quota is fully restored
+	Reset time.Duration
+}
+
+type statusReporter interface {
+	Status() (Status, bool)
+}
+
+// StatusOf returns the quota state of flow control, false means the flow control
+// does not limit requests or can not report its state, e.g. exempt flow control.
+func StatusOf(f FlowControl) (Status, bool) {
+	s, ok := f.(statusReporter)
+	if !ok {
+		return Status{}, false
+	}
+	return s.Status()
+}
+
+type namer interface {
+	Name() string
+}
+
+// NameOf returns the name of flow control schema, it is empty if flow control is not
+// created from a schema.
+func NameOf(f FlowControl) string {
+	n, ok := f.(namer)
+	if !ok {
+		return ""
+	}
+	return n.Name()
+}
+
+// tokenBucket is a token bucket rate limiter which is able to report remaining tokens,
+// it behaves the same as client-go token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	qps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		qps:    qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// advanceLocked refills tokens elapsed since last call
+func (b *tokenBucket) advanceLocked() {
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed.Seconds()*b.qps)
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) TryAccept() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *tokenBucket) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advanceLocked()
+	status := Status{
+		Limit:     int(b.burst),
+		Remaining: int(b.tokens),
+	}
+	if missing := b.burst - b.tokens; missing > 0 && b.qps > 0 {
+		status.Reset = time.Duration(missing / b.qps * float64(time.Second))
+	}
+	return status
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowcontrol
+
+import (
+	"testing"
+	"time"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+func Test_tokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, 4)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	for i := 0; i < 4; i++ {
+		if !b.TryAccept() {
+			t.Fatalf("TryAccept() %d = false, want true", i)
+		}
+	}
+	if b.TryAccept() {
+		t.Fatalf("TryAccept() = true after burst is exhausted, want false")
+	}
+	if got, want := b.Status(), (Status{Limit: 4, Remaining: 0, Reset: 2 * time.Second}); got != want {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+
+	now = now.Add(time.Second)
+	if got, want := b.Status(), (Status{Limit: 4, Remaining: 2, Reset: time.Second}); got != want {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+
+	now = now.Add(time.Hour)
+	if got, want := b.Status(), (Status{Limit: 4, Remaining: 4}); got != want {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatusOf(t *testing.T) {
+	exempt := NewObservedFlowControl("test", DefaultFlowControlSchema)
+	if _, ok := StatusOf(exempt); ok {
+		t.Errorf("StatusOf() exempt flow control ok = true, want false")
+	}
+
+	inflight := NewObservedFlowControl("test", proxyv1alpha1.FlowControlSchema{
+		Name: "inflight",
+		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+			MaxRequestsInflight: &proxyv1alpha1.MaxRequestsInflightFlowControlSchema{Max: 2},
+		},
+	})
+	inflight.TryAcquire()
+	if got, ok := StatusOf(inflight); !ok || got != (Status{Limit: 2, Remaining: 1}) {
+		t.Errorf("StatusOf() = %+v, %v, want 1 remaining", got, ok)
+	}
+	inflight.TryAcquire()
+	if got, ok := StatusOf(inflight); !ok || got != (Status{Limit: 2, Remaining: 0, Reset: inflightResetHint}) {
+		t.Errorf("StatusOf() = %+v, %v, want 0 remaining", got, ok)
+	}
+	inflight.Release()
+	if got, _ := StatusOf(inflight); got.Remaining != 1 {
+		t.Errorf("StatusOf() remaining = %v after release, want 1", got.Remaining)
+	}
+}
+
+func Test_tokenBucketBalance(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(2, 10)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	for i := 0; i < 6; i++ {
+		b.TryAccept()
+	}
+	exported := b.balance()
+	if exported.Tokens != 4 {
+		t.Fatalf("balance() tokens = %v, want 4", exported.Tokens)
+	}
+
+	restored := newTokenBucket(2, 10)
+	restored.now = func() time.Time { return now.Add(time.Second) }
+	restored.restore(exported)
+	if got := restored.Status().Remaining; got != 6 {
+		t.Errorf("Remaining = %v after restoring 4 tokens taken 1s ago at 2 qps, want 6", got)
+	}
+
+	restored.restore(Balance{Tokens: -3, Time: now.Add(time.Second)})
+	if got := restored.Status().Remaining; got != 0 {
+		t.Errorf("Remaining = %v after restoring negative tokens, want 0", got)
+	}
+	restored.restore(Balance{Tokens: 4, Time: now.Add(-time.Hour)})
+	if got := restored.Status().Remaining; got != 10 {
+		t.Errorf("Remaining = %v after restoring an old balance, want burst 10", got)
+	}
+}
+
+func TestTryAcquireSeats(t *testing.T) {
+	bucket := NewFlowControl(proxyv1alpha1.FlowControlSchema{
+		Name: "bucket",
+		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+			TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: 1, Burst: 10},
+		},
+	})
+	if seats, ok := TryAcquireSeats(bucket, 4); !ok || seats != 4 {
+		t.Fatalf("TryAcquireSeats(4) = %v, %v, want 4, true", seats, ok)
+	}
+	if seats, ok := TryAcquireSeats(bucket, 20); ok || seats != 10 {
+		t.Errorf("TryAcquireSeats(20) = %v, %v, want seats clamped to burst and rejected", seats, ok)
+	}
+	if status, _ := StatusOf(bucket); status.Remaining != 6 {
+		t.Errorf("Remaining = %v, want 6", status.Remaining)
+	}
+
+	inflight := NewFlowControl(proxyv1alpha1.FlowControlSchema{
+		Name: "inflight",
+		FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+			MaxRequestsInflight: &proxyv1alpha1.MaxRequestsInflightFlowControlSchema{Max: 5},
+		},
+	})
+	seats, ok := TryAcquireSeats(inflight, 3)
+	if !ok || seats != 3 {
+		t.Fatalf("TryAcquireSeats(3) = %v, %v, want 3, true", seats, ok)
+	}
+	if _, ok := TryAcquireSeats(inflight, 3); ok {
+		t.Errorf("TryAcquireSeats(3) = true with 2 free slots, want false")
+	}
+	if status, _ := StatusOf(inflight); status.Remaining != 2 {
+		t.Errorf("Remaining = %v after a rejected acquire, want 2", status.Remaining)
+	}
+	ReleaseSeats(inflight, seats)
+	if seats, ok := TryAcquireSeats(inflight, 8); !ok || seats != 5 {
+		t.Errorf("TryAcquireSeats(8) = %v, %v, want seats clamped to max and admitted", seats, ok)
+	}
+}
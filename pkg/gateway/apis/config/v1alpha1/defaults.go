@@ -0,0 +1,92 @@
+/*
+Copyright 2022 ByteDance and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controlplaneoptions "github.com/kubewharf/kubegateway/pkg/gateway/controlplane/options"
+	proxyoptions "github.com/kubewharf/kubegateway/pkg/gateway/proxy/options"
+)
+
+// SetDefaults_GatewayConfiguration sets defaults for fields not set in configuration file,
+// defaults are the same as the counterpart flags.
+// nolint
+func SetDefaults_GatewayConfiguration(obj *GatewayConfiguration) {
+	if len(obj.APIVersion) == 0 {
+		obj.APIVersion = SchemeGroupVersion.String()
+	}
+	if len(obj.Kind) == 0 {
+		obj.Kind = Kind
+	}
+
+	secureServing := controlplaneoptions.NewSecureServingOptions()
+	listener := &obj.Listeners.ControlPlane
+	if listener.BindAddress == nil {
+		address := secureServing.BindAddress.String()
+		listener.BindAddress = &address
+	}
+	if listener.Port == nil {
+		listener.Port = &secureServing.BindPort
+	}
+	if listener.ReusePort == nil {
+		listener.ReusePort = &secureServing.ReusePort
+	}
+
+	authn := proxyoptions.NewAuthenticationOptions()
+	if obj.Authentication.TokenSuccessCacheTTL == nil {
+		obj.Authentication.TokenSuccessCacheTTL = &metav1.Duration{Duration: authn.TokenSuccessCacheTTL}
+	}
+	if obj.Authentication.TokenFailureCacheTTL == nil {
+		obj.Authentication.TokenFailureCacheTTL = &metav1.Duration{Duration: authn.TokenFailureCacheTTL}
+	}
+
+	oidc := proxyoptions.NewOIDCAuthenticationOptions()
+	for i := range obj.Authentication.OIDCIssuers {
+		issuer := &obj.Authentication.OIDCIssuers[i]
+		if len(issuer.UsernameClaim) == 0 {
+			issuer.UsernameClaim = oidc.UsernameClaim
+		}
+		if len(issuer.SigningAlgs) == 0 {
+			issuer.SigningAlgs = oidc.SigningAlgs
+		}
+	}
+
+	authz := proxyoptions.NewAuthorizationOptions()
+	if obj.Authorization.CacheAuthorizedTTL == nil {
+		obj.Authorization.CacheAuthorizedTTL = &metav1.Duration{Duration: authz.CacheAuthorizedTTL}
+	}
+	if obj.Authorization.CacheUnauthorizedTTL == nil {
+		obj.Authorization.CacheUnauthorizedTTL = &metav1.Duration{Duration: authz.CacheUnauthorizedTTL}
+	}
+
+	budget := proxyoptions.NewResourceBudgetOptions()
+	if obj.FlowControl.MaxInflightRequestsPerCluster == nil {
+		obj.FlowControl.MaxInflightRequestsPerCluster = &budget.MaxInflightRequestsPerCluster
+	}
+	if obj.FlowControl.MaxPendingDialsPerCluster == nil {
+		obj.FlowControl.MaxPendingDialsPerCluster = &budget.MaxPendingDialsPerCluster
+	}
+
+	if obj.Logging.EnableProxyAccessLog == nil {
+		obj.Logging.EnableProxyAccessLog = &proxyoptions.NewLoggingOptions().EnableProxyAccessLog
+	}
+
+	if obj.Fleet.Timeout == nil {
+		obj.Fleet.Timeout = &metav1.Duration{Duration: proxyoptions.NewFleetOptions().Timeout}
+	}
+}
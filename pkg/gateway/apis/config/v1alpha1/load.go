@@ -0,0 +1,56 @@
+/*
+Copyright 2022 ByteDance and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadConfigFile reads, strictly decodes, defaults and validates the configuration file.
+// Unknown or duplicate fields are treated as errors.
+func LoadConfigFile(path string) (*GatewayConfiguration, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gateway configuration file %q: %v", path, err)
+	}
+	cfg, err := DecodeGatewayConfiguration(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load gateway configuration file %q: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// DecodeGatewayConfiguration strictly decodes data in yaml or json format into a defaulted
+// and validated GatewayConfiguration
+func DecodeGatewayConfiguration(data []byte) (*GatewayConfiguration, error) {
+	cfg := &GatewayConfiguration{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, err
+	}
+	// apiVersion and kind are required to make sure we are decoding the right version
+	if len(cfg.APIVersion) == 0 || len(cfg.Kind) == 0 {
+		return nil, fmt.Errorf("apiVersion and kind must be set, expected apiVersion=%s kind=%s", SchemeGroupVersion.String(), Kind)
+	}
+	SetDefaults_GatewayConfiguration(cfg)
+	if errs := ValidateGatewayConfiguration(cfg); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	return cfg, nil
+}
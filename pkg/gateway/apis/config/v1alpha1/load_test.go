@@ -0,0 +1,166 @@
+/*
+Copyright 2022 ByteDance and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecodeGatewayConfiguration(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		check   func(t *testing.T, cfg *GatewayConfiguration)
+	}{
+		{
+			name: "defaulting",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+listeners:
+  proxy:
+    ports: [6443]
+flowControl:
+  maxInflightRequestsPerCluster: 100
+`,
+			check: func(t *testing.T, cfg *GatewayConfiguration) {
+				if *cfg.FlowControl.MaxInflightRequestsPerCluster != 100 {
+					t.Errorf("maxInflightRequestsPerCluster = %v, want 100", *cfg.FlowControl.MaxInflightRequestsPerCluster)
+				}
+				if *cfg.FlowControl.MaxPendingDialsPerCluster != 0 {
+					t.Errorf("maxPendingDialsPerCluster = %v, want 0", *cfg.FlowControl.MaxPendingDialsPerCluster)
+				}
+				if cfg.Authentication.TokenSuccessCacheTTL.Duration != 10*time.Minute {
+					t.Errorf("tokenSuccessCacheTTL = %v, want 10m", cfg.Authentication.TokenSuccessCacheTTL.Duration)
+				}
+				if cfg.Listeners.ControlPlane.Port == nil {
+					t.Errorf("control plane port is not defaulted")
+				}
+			},
+		},
+		{
+			name: "runtime tunables",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+runtime:
+  gcPercent: 50
+  memoryLimit: 4Gi
+`,
+			check: func(t *testing.T, cfg *GatewayConfiguration) {
+				if *cfg.Runtime.GCPercent != 50 {
+					t.Errorf("gcPercent = %v, want 50", *cfg.Runtime.GCPercent)
+				}
+				if cfg.Runtime.MemoryLimit.Value() != 4<<30 {
+					t.Errorf("memoryLimit = %v, want 4Gi", cfg.Runtime.MemoryLimit)
+				}
+			},
+		},
+		{
+			name: "negative memory limit",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+runtime:
+  memoryLimit: -1Gi
+`,
+			wantErr: true,
+		},
+		{
+			name: "unknown field",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+logging:
+  enableAccessLog: true
+`,
+			wantErr: true,
+		},
+		{
+			name: "missing kind",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+`,
+			wantErr: true,
+		},
+		{
+			name: "unsupported version",
+			data: `
+apiVersion: config.kubegateway.io/v1beta1
+kind: GatewayConfiguration
+`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate ports",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+listeners:
+  controlPlane:
+    port: 9443
+  proxy:
+    ports: [9443]
+`,
+			wantErr: true,
+		},
+		{
+			name: "oidc issuers",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+authentication:
+  oidcIssuers:
+  - issuerURL: https://issuer.example.com
+    clientID: kubernetes
+`,
+			check: func(t *testing.T, cfg *GatewayConfiguration) {
+				issuer := cfg.Authentication.OIDCIssuers[0]
+				if issuer.UsernameClaim != "sub" {
+					t.Errorf("usernameClaim = %v, want sub", issuer.UsernameClaim)
+				}
+				if len(issuer.SigningAlgs) != 1 || issuer.SigningAlgs[0] != "RS256" {
+					t.Errorf("signingAlgs = %v, want [RS256]", issuer.SigningAlgs)
+				}
+			},
+		},
+		{
+			name: "oidc issuer without client id",
+			data: `
+apiVersion: config.kubegateway.io/v1alpha1
+kind: GatewayConfiguration
+authentication:
+  oidcIssuers:
+  - issuerURL: https://issuer.example.com
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeGatewayConfiguration([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeGatewayConfiguration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2022 ByteDance and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	GroupName = "config.kubegateway.io"
+	Version   = "v1alpha1"
+	Kind      = "GatewayConfiguration"
+)
+
+// SchemeGroupVersion is group version used in configuration file
+var SchemeGroupVersion = metav1.GroupVersion{Group: GroupName, Version: Version}
+
+// GatewayConfiguration configures a kube-gateway server. Every field has a counterpart
+// command line flag, and flags explicitly set on command line override the file.
+type GatewayConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// FeatureGates is a map of feature names to bools that enable or disable
+	// gateway and kubernetes features, aka --feature-gates
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// Listeners configures the serving ports of control plane and proxy
+	Listeners ListenersConfiguration `json:"listeners"`
+	// Authentication configures how requests are authenticated
+	Authentication AuthenticationConfiguration `json:"authentication"`
+	// Authorization configures how requests are authorized
+	Authorization AuthorizationConfiguration `json:"authorization"`
+	// FlowControl configures the default flow control of upstream clusters
+	FlowControl FlowControlConfiguration `json:"flowControl"`
+	// Logging configures proxy logging
+	Logging LoggingConfiguration `json:"logging"`
+	// Metrics configures metrics exposing
+	Metrics MetricsConfiguration `json:"metrics"`
+	// Fleet configures the fleet-wide fan-out read route
+	Fleet FleetConfiguration `json:"fleet"`
+	// Runtime configures runtime tunables, they can also be changed live by admin API
+	Runtime RuntimeConfiguration `json:"runtime"`
+}
+
+type ListenersConfiguration struct {
+	// ControlPlane is the secure listener for control plane api, aka --bind-address and --secure-port
+	ControlPlane ControlPlaneListener `json:"controlPlane"`
+	// Proxy is the secure listeners for proxy, aka --proxy-secure-ports
+	Proxy ProxyListener `json:"proxy"`
+}
+
+type ControlPlaneListener struct {
+	BindAddress *string `json:"bindAddress,omitempty"`
+	Port        *int    `json:"port,omitempty"`
+	OtherPorts  []int   `json:"otherPorts,omitempty"`
+	ReusePort   *bool   `json:"reusePort,omitempty"`
+	// CertFile and KeyFile are the serving x509 certificate and key files
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+type ProxyListener struct {
+	Ports []int `json:"ports,omitempty"`
+	// InternalH2CBindAddress is the plaintext address serving proxy by h2c only, aka --proxy-internal-h2c-bind-address
+	InternalH2CBindAddress string `json:"internalH2CBindAddress,omitempty"`
+}
+
+type AuthenticationConfiguration struct {
+	// ClientCAFile is used to verify client certificates, aka --client-ca-file
+	ClientCAFile string `json:"clientCAFile,omitempty"`
+	// TokenSuccessCacheTTL is the duration to cache success responses from upstream token authenticator
+	TokenSuccessCacheTTL *metav1.Duration `json:"tokenSuccessCacheTTL,omitempty"`
+	// TokenFailureCacheTTL is the duration to cache failure responses from upstream token authenticator
+	TokenFailureCacheTTL *metav1.Duration `json:"tokenFailureCacheTTL,omitempty"`
+	// OIDCIssuers are OpenID Connect issuers whose ID tokens are validated by gateway locally,
+	// in addition to the issuer of --proxy-oidc-issuer-url
+	OIDCIssuers []OIDCIssuerConfiguration `json:"oidcIssuers,omitempty"`
+}
+
+type OIDCIssuerConfiguration struct {
+	IssuerURL      string            `json:"issuerURL"`
+	ClientID       string            `json:"clientID"`
+	CAFile         string            `json:"caFile,omitempty"`
+	UsernameClaim  string            `json:"usernameClaim,omitempty"`
+	UsernamePrefix string            `json:"usernamePrefix,omitempty"`
+	GroupsClaim    string            `json:"groupsClaim,omitempty"`
+	GroupsPrefix   string            `json:"groupsPrefix,omitempty"`
+	SigningAlgs    []string          `json:"signingAlgs,omitempty"`
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+}
+
+type AuthorizationConfiguration struct {
+	// Modes is the ordered list of control plane authorization plugins, aka --authorization-mode
+	Modes []string `json:"modes,omitempty"`
+	// CacheAuthorizedTTL is the duration to cache authorized responses from upstream authorizer
+	CacheAuthorizedTTL *metav1.Duration `json:"cacheAuthorizedTTL,omitempty"`
+	// CacheUnauthorizedTTL is the duration to cache unauthorized responses from upstream authorizer
+	CacheUnauthorizedTTL *metav1.Duration `json:"cacheUnauthorizedTTL,omitempty"`
+}
+
+type FlowControlConfiguration struct {
+	// MaxInflightRequestsPerCluster is the default maximum number of non-long-running requests
+	// proxied to one upstream cluster, 0 means unlimited
+	MaxInflightRequestsPerCluster *int32 `json:"maxInflightRequestsPerCluster,omitempty"`
+	// MaxPendingDialsPerCluster is the default maximum number of pending dials to one upstream cluster,
+	// 0 means unlimited
+	MaxPendingDialsPerCluster *int32 `json:"maxPendingDialsPerCluster,omitempty"`
+}
+
+type LoggingConfiguration struct {
+	EnableProxyAccessLog *bool `json:"enableProxyAccessLog,omitempty"`
+}
+
+type MetricsConfiguration struct {
+	// ShowHiddenMetricsForVersion is the previous version for which you want to show hidden metrics
+	ShowHiddenMetricsForVersion string `json:"showHiddenMetricsForVersion,omitempty"`
+}
+
+type FleetConfiguration struct {
+	Hostname string           `json:"hostname,omitempty"`
+	Clusters []string         `json:"clusters,omitempty"`
+	Timeout  *metav1.Duration `json:"timeout,omitempty"`
+}
+
+type RuntimeConfiguration struct {
+	GOMAXPROCS *int `json:"gomaxprocs,omitempty"`
+	// GCPercent -1 disables GC until memory limit is reached
+	GCPercent *int `json:"gcPercent,omitempty"`
+	// MemoryLimit is the soft memory limit, e.g. 4Gi, 0 means unlimited
+	MemoryLimit *resource.Quantity `json:"memoryLimit,omitempty"`
+	// CopyBufferSize is the size in bytes of buffers copying proxied bodies
+	CopyBufferSize *int `json:"copyBufferSize,omitempty"`
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2022 ByteDance and its affiliates.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateGatewayConfiguration validates a defaulted GatewayConfiguration
+func ValidateGatewayConfiguration(obj *GatewayConfiguration) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if obj.APIVersion != SchemeGroupVersion.String() {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("apiVersion"), obj.APIVersion, []string{SchemeGroupVersion.String()}))
+	}
+	if obj.Kind != Kind {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("kind"), obj.Kind, []string{Kind}))
+	}
+	allErrs = append(allErrs, validateListeners(&obj.Listeners, field.NewPath("listeners"))...)
+
+	if d := obj.Authentication.TokenSuccessCacheTTL; d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("authentication", "tokenSuccessCacheTTL"), d.Duration.String(), "must not be negative"))
+	}
+	if d := obj.Authentication.TokenFailureCacheTTL; d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("authentication", "tokenFailureCacheTTL"), d.Duration.String(), "must not be negative"))
+	}
+	for i, issuer := range obj.Authentication.OIDCIssuers {
+		issuerPath := field.NewPath("authentication", "oidcIssuers").Index(i)
+		if len(issuer.IssuerURL) == 0 {
+			allErrs = append(allErrs, field.Required(issuerPath.Child("issuerURL"), ""))
+		}
+		if len(issuer.ClientID) == 0 {
+			allErrs = append(allErrs, field.Required(issuerPath.Child("clientID"), ""))
+		}
+	}
+	if d := obj.Authorization.CacheAuthorizedTTL; d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("authorization", "cacheAuthorizedTTL"), d.Duration.String(), "must not be negative"))
+	}
+	if d := obj.Authorization.CacheUnauthorizedTTL; d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("authorization", "cacheUnauthorizedTTL"), d.Duration.String(), "must not be negative"))
+	}
+
+	if v := obj.FlowControl.MaxInflightRequestsPerCluster; v != nil && *v < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("flowControl", "maxInflightRequestsPerCluster"), *v, "must not be negative"))
+	}
+	if v := obj.FlowControl.MaxPendingDialsPerCluster; v != nil && *v < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("flowControl", "maxPendingDialsPerCluster"), *v, "must not be negative"))
+	}
+
+	if len(obj.Fleet.Hostname) > 0 && len(obj.Fleet.Clusters) == 0 {
+		allErrs = append(allErrs, field.Required(field.NewPath("fleet", "clusters"), "must be set when fleet hostname is set"))
+	}
+	if d := obj.Fleet.Timeout; d != nil && d.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("fleet", "timeout"), d.Duration.String(), "must be greater than 0"))
+	}
+
+	if q := obj.Runtime.MemoryLimit; q != nil && q.Sign() < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("runtime", "memoryLimit"), q.String(), "must not be negative"))
+	}
+	return allErrs
+}
+
+func validateListeners(listeners *ListenersConfiguration, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	controlPlanePath := fldPath.Child("controlPlane")
+	if address := listeners.ControlPlane.BindAddress; address != nil && net.ParseIP(*address) == nil {
+		allErrs = append(allErrs, field.Invalid(controlPlanePath.Child("bindAddress"), *address, "must be a valid IP address"))
+	}
+
+	usedPorts := sets.NewInt()
+	checkPort := func(port int, path *field.Path) {
+		if port < 1 || port > 65535 {
+			allErrs = append(allErrs, field.Invalid(path, port, "must be between 1 and 65535, inclusive"))
+			return
+		}
+		if usedPorts.Has(port) {
+			allErrs = append(allErrs, field.Duplicate(path, port))
+			return
+		}
+		usedPorts.Insert(port)
+	}
+	if listeners.ControlPlane.Port != nil {
+		checkPort(*listeners.ControlPlane.Port, controlPlanePath.Child("port"))
+	}
+	for i, port := range listeners.ControlPlane.OtherPorts {
+		checkPort(port, controlPlanePath.Child("otherPorts").Index(i))
+	}
+	for i, port := range listeners.Proxy.Ports {
+		checkPort(port, fldPath.Child("proxy", "ports").Index(i))
+	}
+
+	if (len(listeners.ControlPlane.CertFile) == 0) != (len(listeners.ControlPlane.KeyFile) == 0) {
+		allErrs = append(allErrs, field.Required(controlPlanePath.Child("certFile"), "certFile and keyFile must be set together"))
+	}
+	return allErrs
+}
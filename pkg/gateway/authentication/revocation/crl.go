@@ -0,0 +1,147 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+// crlSet is revoked serial numbers grouped by issuer
+type crlSet map[string]map[string]struct{}
+
+// CRLChecker checks certificates against CRL files, files are reloaded when they are modified.
+// CRL files are trusted local configuration, their signatures are not verified.
+type CRLChecker struct {
+	files   []string
+	revoked atomic.Value
+	// modification time of files when they are loaded last time
+	modTimes []time.Time
+}
+
+// NewCRLChecker loads CRL files in PEM or DER format, Run must be called to reload them
+func NewCRLChecker(files []string) (*CRLChecker, error) {
+	c := &CRLChecker{
+		files:    files,
+		modTimes: make([]time.Time, len(files)),
+	}
+	if _, err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run reloads modified CRL files every interval until stopCh is closed
+func (c *CRLChecker) Run(interval time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		reloaded, err := c.reload()
+		if err != nil {
+			klog.Errorf("[crl] failed to reload crl files, keep using the last loaded ones: %v", err)
+			return
+		}
+		if reloaded {
+			klog.Infof("[crl] reloaded crl files %v", c.files)
+		}
+	}, interval, stopCh)
+}
+
+func (c *CRLChecker) reload() (bool, error) {
+	modTimes := make([]time.Time, len(c.files))
+	changed := c.revoked.Load() == nil
+	for i, file := range c.files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false, err
+		}
+		modTimes[i] = info.ModTime()
+		changed = changed || !modTimes[i].Equal(c.modTimes[i])
+	}
+	if !changed {
+		return false, nil
+	}
+
+	set := crlSet{}
+	now := time.Now()
+	for _, file := range c.files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		if err := set.add(data, now); err != nil {
+			return false, fmt.Errorf("invalid crl file %s: %v", file, err)
+		}
+	}
+	c.revoked.Store(set)
+	c.modTimes = modTimes
+	return true, nil
+}
+
+func (s crlSet) add(data []byte, now time.Time) error {
+	der := [][]byte{}
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "X509 CRL" {
+			der = append(der, block.Bytes)
+		}
+	}
+	if len(der) == 0 {
+		// not PEM encoded
+		der = append(der, data)
+	}
+	for _, d := range der {
+		crl, err := x509.ParseDERCRL(d)
+		if err != nil {
+			return err
+		}
+		if crl.HasExpired(now) {
+			klog.Warningf("[crl] crl of issuer %q expired at %v, it is still used until replaced", crl.TBSCertList.Issuer.String(), crl.TBSCertList.NextUpdate)
+		}
+		issuer := crl.TBSCertList.Issuer.String()
+		serials := s[issuer]
+		if serials == nil {
+			serials = map[string]struct{}{}
+			s[issuer] = serials
+		}
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			serials[revoked.SerialNumber.String()] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (c *CRLChecker) Check(chain []*x509.Certificate) error {
+	set, _ := c.revoked.Load().(crlSet)
+	cert := chain[0]
+	issuer, err := issuerKey(cert.RawIssuer)
+	if err != nil {
+		return fmt.Errorf("invalid issuer of client certificate: %v", err)
+	}
+	if _, ok := set[issuer][cert.SerialNumber.String()]; ok {
+		return fmt.Errorf("%w by crl, serial=%s issuer=%q", ErrRevoked, cert.SerialNumber.String(), issuer)
+	}
+	return nil
+}
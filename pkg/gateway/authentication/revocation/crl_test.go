@@ -0,0 +1,148 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"test"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+func newTestClientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func writeTestCRL(t *testing.T, file string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serials ...int64) {
+	revoked := []pkix.RevokedCertificate{}
+	for _, s := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(s), RevocationTime: time.Now()})
+	}
+	der, err := ca.CreateCRL(rand.Reader, caKey, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCRLChecker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "ca.crl")
+
+	ca, caKey := newTestCA(t, "test-ca")
+	otherCA, otherKey := newTestCA(t, "other-ca")
+	writeTestCRL(t, file, ca, caKey, 2)
+
+	checker, err := NewCRLChecker([]string{file})
+	if err != nil {
+		t.Fatalf("NewCRLChecker() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		revoked bool
+	}{
+		{"revoked", newTestClientCert(t, ca, caKey, 2), true},
+		{"not revoked", newTestClientCert(t, ca, caKey, 3), false},
+		{"same serial of other issuer", newTestClientCert(t, otherCA, otherKey, 2), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checker.Check([]*x509.Certificate{tt.cert, ca})
+			if got := errors.Is(err, ErrRevoked); got != tt.revoked {
+				t.Errorf("Check() error = %v, want revoked %v", err, tt.revoked)
+			}
+		})
+	}
+
+	// reload modified file
+	writeTestCRL(t, file, ca, caKey, 3)
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := checker.reload(); err != nil || !reloaded {
+		t.Fatalf("reload() = %v, %v, want reloaded", reloaded, err)
+	}
+	if err := checker.Check([]*x509.Certificate{newTestClientCert(t, ca, caKey, 2), ca}); err != nil {
+		t.Errorf("Check() error = %v after unrevoked", err)
+	}
+	if err := checker.Check([]*x509.Certificate{newTestClientCert(t, ca, caKey, 3), ca}); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Check() error = %v, want revoked after reloaded", err)
+	}
+	if reloaded, err := checker.reload(); err != nil || reloaded {
+		t.Errorf("reload() = %v, %v, want not reloaded without modification", reloaded, err)
+	}
+}
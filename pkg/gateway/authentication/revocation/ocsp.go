@@ -0,0 +1,139 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+	"k8s.io/klog"
+)
+
+const (
+	// maxOCSPResponseBytes bounds the response body read from OCSP responders
+	maxOCSPResponseBytes = 1 << 20
+	// ocspErrorCacheTTL avoids hammering unavailable responders
+	ocspErrorCacheTTL = 30 * time.Second
+)
+
+// OCSPChecker checks certificates by OCSP responders in their AuthorityInfoAccess extension.
+// Responses are cached until their NextUpdate or cacheTTL, whichever is earlier.
+type OCSPChecker struct {
+	client     *http.Client
+	cacheTTL   time.Duration
+	failClosed bool
+
+	lock  sync.Mutex
+	cache map[string]ocspResult
+}
+
+type ocspResult struct {
+	err    error
+	expire time.Time
+}
+
+// NewOCSPChecker creates an OCSP checker, certificates are rejected if their status can not be
+// fetched in timeout and failClosed is true, otherwise they are accepted.
+func NewOCSPChecker(timeout, cacheTTL time.Duration, failClosed bool) *OCSPChecker {
+	return &OCSPChecker{
+		client:     &http.Client{Timeout: timeout},
+		cacheTTL:   cacheTTL,
+		failClosed: failClosed,
+		cache:      map[string]ocspResult{},
+	}
+}
+
+func (c *OCSPChecker) Check(chain []*x509.Certificate) error {
+	cert := chain[0]
+	if len(cert.OCSPServer) == 0 || len(chain) < 2 {
+		// no responder or self-signed certificate
+		return nil
+	}
+	issuer := chain[1]
+	key := string(issuer.RawSubjectPublicKeyInfo) + "/" + cert.SerialNumber.String()
+
+	now := time.Now()
+	c.lock.Lock()
+	result, ok := c.cache[key]
+	c.lock.Unlock()
+	if !ok || now.After(result.expire) {
+		result = c.query(cert, issuer, now)
+		c.lock.Lock()
+		c.cache[key] = result
+		// drop expired results lazily
+		for k, r := range c.cache {
+			if now.After(r.expire) {
+				delete(c.cache, k)
+			}
+		}
+		c.lock.Unlock()
+	}
+	return result.err
+}
+
+func (c *OCSPChecker) query(cert, issuer *x509.Certificate, now time.Time) ocspResult {
+	resp, err := c.fetch(cert, issuer)
+	if err != nil {
+		klog.Warningf("[ocsp] failed to check client certificate, serial=%s responder=%s: %v", cert.SerialNumber.String(), cert.OCSPServer[0], err)
+		if c.failClosed {
+			return ocspResult{err: fmt.Errorf("failed to check revocation of client certificate: %v", err), expire: now.Add(ocspErrorCacheTTL)}
+		}
+		return ocspResult{expire: now.Add(ocspErrorCacheTTL)}
+	}
+
+	expire := now.Add(c.cacheTTL)
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(expire) {
+		expire = resp.NextUpdate
+	}
+	switch resp.Status {
+	case ocsp.Good:
+		return ocspResult{expire: expire}
+	case ocsp.Revoked:
+		return ocspResult{err: fmt.Errorf("%w by ocsp, serial=%s revokedAt=%v", ErrRevoked, cert.SerialNumber.String(), resp.RevokedAt), expire: expire}
+	default:
+		if c.failClosed {
+			return ocspResult{err: fmt.Errorf("unknown revocation status of client certificate serial=%s", cert.SerialNumber.String()), expire: expire}
+		}
+		return ocspResult{expire: expire}
+	}
+}
+
+func (c *OCSPChecker) fetch(cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpResp, err := c.client.Post(cert.OCSPServer[0], "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", httpResp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	// the response signature is verified against issuer
+	return ocsp.ParseResponseForCert(body, cert, issuer)
+}
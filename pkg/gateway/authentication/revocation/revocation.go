@@ -0,0 +1,78 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation checks whether verified client certificates are revoked by CRL or
+// OCSP, so compromised client certificates can be blocked without rotating the CA.
+package revocation
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	x509request "k8s.io/apiserver/pkg/authentication/request/x509"
+)
+
+var ErrRevoked = errors.New("client certificate is revoked")
+
+// Checker checks the leaf certificate of a verified chain, the chain is ordered from leaf
+// to root as returned by x509.Certificate.Verify.
+type Checker interface {
+	// Check returns an error if the leaf certificate is revoked or can not be checked
+	Check(chain []*x509.Certificate) error
+}
+
+type union []Checker
+
+// NewUnion returns a checker which fails if any of checkers fails
+func NewUnion(checkers ...Checker) Checker {
+	return union(checkers)
+}
+
+func (u union) Check(chain []*x509.Certificate) error {
+	for _, c := range u {
+		if err := c.Check(chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewUserConversion wraps conversion, so that users are converted only from unrevoked certificates
+func NewUserConversion(conversion x509request.UserConversion, checker Checker) x509request.UserConversion {
+	return x509request.UserConversionFunc(func(chain []*x509.Certificate) (*authenticator.Response, bool, error) {
+		if len(chain) > 0 {
+			if err := checker.Check(chain); err != nil {
+				return nil, false, err
+			}
+		}
+		return conversion.User(chain)
+	})
+}
+
+// issuerKey returns a comparable key of raw issuer or subject name
+func issuerKey(raw []byte) (string, error) {
+	var rdn pkix.RDNSequence
+	rest, err := asn1.Unmarshal(raw, &rdn)
+	if err != nil {
+		return "", err
+	}
+	if len(rest) > 0 {
+		return "", fmt.Errorf("trailing data after name")
+	}
+	return rdn.String(), nil
+}
@@ -0,0 +1,265 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tokenfile provides bearer token authenticators backed by local files for the
+// control plane (admin and debug) server. Files are checked periodically and reloaded when
+// their content changes, so tokens can be rotated without restarting gateway.
+package tokenfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/klog"
+)
+
+const (
+	reloadInterval = 30 * time.Second
+
+	bootstrapUserPrefix   = "system:bootstrap:"
+	bootstrapDefaultGroup = "system:bootstrappers"
+)
+
+var (
+	bootstrapTokenRegexp      = regexp.MustCompile(`^([a-z0-9]{6})\.([a-z0-9]{16})$`)
+	bootstrapExtraGroupRegexp = regexp.MustCompile(`^system:bootstrappers:[a-z0-9:-]{0,255}[a-z0-9]$`)
+)
+
+type tokenEntry struct {
+	user *user.DefaultInfo
+	// secret and expiration are only used by bootstrap tokens
+	secret     string
+	expiration time.Time
+}
+
+// tokenParser parses file content into an index of tokens
+type tokenParser func(data []byte) (map[string]*tokenEntry, error)
+
+// tokenLookup finds the entry matching the bearer token in the index
+type tokenLookup func(tokens map[string]*tokenEntry, token string) (*tokenEntry, bool)
+
+// Authenticator authenticates bearer tokens listed in a file
+type Authenticator struct {
+	kind   string
+	path   string
+	parse  tokenParser
+	lookup tokenLookup
+
+	content []byte
+	// tokens is map[string]*tokenEntry, it is replaced as a whole on reload
+	tokens atomic.Value
+}
+
+var _ authenticator.Token = &Authenticator{}
+
+// NewStaticTokenAuthenticator creates an authenticator from a csv file with the same format as
+// kube-apiserver --token-auth-file: token,user,uid,"group1,group2,group3"
+func NewStaticTokenAuthenticator(path string) (*Authenticator, error) {
+	return newAuthenticator("static token", path, parseStaticTokens, lookupStaticToken)
+}
+
+// NewBootstrapTokenAuthenticator creates an authenticator from a csv file of bootstrap tokens:
+// token,expiration,"extra-group1,extra-group2". The token must be in form of [a-z0-9]{6}.[a-z0-9]{16},
+// expiration is in RFC3339 and empty means never expires. The token authenticates as user
+// system:bootstrap:<token-id> in group system:bootstrappers and the extra groups.
+func NewBootstrapTokenAuthenticator(path string) (*Authenticator, error) {
+	return newAuthenticator("bootstrap token", path, parseBootstrapTokens, lookupBootstrapToken)
+}
+
+func newAuthenticator(kind, path string, parse tokenParser, lookup tokenLookup) (*Authenticator, error) {
+	a := &Authenticator{
+		kind:   kind,
+		path:   path,
+		parse:  parse,
+		lookup: lookup,
+	}
+	if _, err := a.reload(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Run checks the file periodically until stopCh is closed, and reloads tokens if the file is changed.
+// Tokens are kept unchanged if the new content is invalid.
+func (a *Authenticator) Run(stopCh <-chan struct{}) {
+	klog.Infof("[token file] start watching %s file %s", a.kind, a.path)
+	wait.Until(func() {
+		changed, err := a.reload()
+		if err != nil {
+			klog.Errorf("[token file] failed to reload %s file %s, keep using the previous tokens: %v", a.kind, a.path, err)
+			return
+		}
+		if changed {
+			klog.Infof("[token file] %s file %s reloaded", a.kind, a.path)
+		}
+	}, reloadInterval, stopCh)
+}
+
+func (a *Authenticator) reload() (bool, error) {
+	data, err := ioutil.ReadFile(a.path)
+	if err != nil {
+		return false, err
+	}
+	if a.content != nil && bytes.Equal(data, a.content) {
+		return false, nil
+	}
+	tokens, err := a.parse(data)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s file %s: %v", a.kind, a.path, err)
+	}
+	a.content = data
+	a.tokens.Store(tokens)
+	return true, nil
+}
+
+func (a *Authenticator) AuthenticateToken(ctx context.Context, token string) (*authenticator.Response, bool, error) {
+	tokens, _ := a.tokens.Load().(map[string]*tokenEntry)
+	entry, ok := a.lookup(tokens, token)
+	if !ok {
+		return nil, false, nil
+	}
+	return &authenticator.Response{User: entry.user}, true, nil
+}
+
+func parseStaticTokens(data []byte) (map[string]*tokenEntry, error) {
+	tokens := map[string]*tokenEntry{}
+	err := readRecords(data, func(n int, record []string) error {
+		if len(record) < 3 {
+			return fmt.Errorf("record %d: token file must have at least 3 columns (token, user name, user uid), found %d", n, len(record))
+		}
+		token := strings.TrimSpace(record[0])
+		if len(token) == 0 {
+			return fmt.Errorf("record %d: empty token", n)
+		}
+		if _, ok := tokens[token]; ok {
+			return fmt.Errorf("record %d: duplicate token", n)
+		}
+		info := &user.DefaultInfo{
+			Name: strings.TrimSpace(record[1]),
+			UID:  strings.TrimSpace(record[2]),
+		}
+		if len(record) >= 4 {
+			info.Groups = splitGroups(record[3])
+		}
+		tokens[token] = &tokenEntry{user: info}
+		return nil
+	})
+	return tokens, err
+}
+
+func lookupStaticToken(tokens map[string]*tokenEntry, token string) (*tokenEntry, bool) {
+	entry, ok := tokens[token]
+	return entry, ok
+}
+
+func parseBootstrapTokens(data []byte) (map[string]*tokenEntry, error) {
+	tokens := map[string]*tokenEntry{}
+	err := readRecords(data, func(n int, record []string) error {
+		match := bootstrapTokenRegexp.FindStringSubmatch(strings.TrimSpace(record[0]))
+		if len(match) != 3 {
+			return fmt.Errorf("record %d: bootstrap token must be in form of %q", n, bootstrapTokenRegexp.String())
+		}
+		id, secret := match[1], match[2]
+		if _, ok := tokens[id]; ok {
+			return fmt.Errorf("record %d: duplicate bootstrap token id %q", n, id)
+		}
+		entry := &tokenEntry{
+			user: &user.DefaultInfo{
+				Name:   bootstrapUserPrefix + id,
+				Groups: []string{bootstrapDefaultGroup},
+			},
+			secret: secret,
+		}
+		if len(record) >= 2 && len(strings.TrimSpace(record[1])) > 0 {
+			expiration, err := time.Parse(time.RFC3339, strings.TrimSpace(record[1]))
+			if err != nil {
+				return fmt.Errorf("record %d: invalid expiration of bootstrap token %q: %v", n, id, err)
+			}
+			entry.expiration = expiration
+		}
+		if len(record) >= 3 {
+			for _, group := range splitGroups(record[2]) {
+				if !bootstrapExtraGroupRegexp.MatchString(group) {
+					return fmt.Errorf("record %d: extra group %q of bootstrap token %q must match %q", n, group, id, bootstrapExtraGroupRegexp.String())
+				}
+				if group != bootstrapDefaultGroup {
+					entry.user.Groups = append(entry.user.Groups, group)
+				}
+			}
+		}
+		tokens[id] = entry
+		return nil
+	})
+	return tokens, err
+}
+
+func lookupBootstrapToken(tokens map[string]*tokenEntry, token string) (*tokenEntry, bool) {
+	match := bootstrapTokenRegexp.FindStringSubmatch(token)
+	if len(match) != 3 {
+		return nil, false
+	}
+	entry, ok := tokens[match[1]]
+	if !ok {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(entry.secret), []byte(match[2])) != 1 {
+		klog.V(3).Infof("[token file] bootstrap token %q secret mismatch", match[1])
+		return nil, false
+	}
+	if !entry.expiration.IsZero() && time.Now().After(entry.expiration) {
+		klog.V(3).Infof("[token file] bootstrap token %q expired at %v", match[1], entry.expiration)
+		return nil, false
+	}
+	return entry, true
+}
+
+// readRecords reads csv records from data, empty lines are skipped
+func readRecords(data []byte, fn func(n int, record []string) error) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(n, record); err != nil {
+			return err
+		}
+	}
+}
+
+func splitGroups(s string) []string {
+	groups := []string{}
+	for _, group := range strings.Split(s, ",") {
+		if group = strings.TrimSpace(group); len(group) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenfile
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTokenFile(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "tokens.csv")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	return path
+}
+
+func Test_StaticTokenAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeTokenFile(t, dir, "token1,admin,uid1,\"system:masters,ops\"\ntoken2,viewer,uid2\n")
+	a, err := NewStaticTokenAuthenticator(path)
+	if err != nil {
+		t.Fatalf("NewStaticTokenAuthenticator() error = %v", err)
+	}
+
+	tests := []struct {
+		token      string
+		wantOK     bool
+		wantName   string
+		wantGroups []string
+	}{
+		{"token1", true, "admin", []string{"system:masters", "ops"}},
+		{"token2", true, "viewer", nil},
+		{"token3", false, "", nil},
+	}
+	for _, tt := range tests {
+		resp, ok, err := a.AuthenticateToken(context.TODO(), tt.token)
+		if err != nil || ok != tt.wantOK {
+			t.Errorf("AuthenticateToken(%q) = %v, %v, want ok %v", tt.token, ok, err, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if resp.User.GetName() != tt.wantName {
+			t.Errorf("AuthenticateToken(%q) user = %v, want %v", tt.token, resp.User.GetName(), tt.wantName)
+		}
+		if len(tt.wantGroups) > 0 && !reflect.DeepEqual(resp.User.GetGroups(), tt.wantGroups) {
+			t.Errorf("AuthenticateToken(%q) groups = %v, want %v", tt.token, resp.User.GetGroups(), tt.wantGroups)
+		}
+	}
+
+	// invalid content keeps previous tokens
+	writeTokenFile(t, dir, "token4\n")
+	if _, err := a.reload(); err == nil {
+		t.Errorf("reload() expected error for invalid file")
+	}
+	if _, ok, _ := a.AuthenticateToken(context.TODO(), "token1"); !ok {
+		t.Errorf("AuthenticateToken() previous token should still be valid after failed reload")
+	}
+
+	writeTokenFile(t, dir, "token4,admin,uid1\n")
+	if changed, err := a.reload(); err != nil || !changed {
+		t.Errorf("reload() = %v, %v, want changed", changed, err)
+	}
+	if _, ok, _ := a.AuthenticateToken(context.TODO(), "token1"); ok {
+		t.Errorf("AuthenticateToken() removed token should be rejected")
+	}
+	if _, ok, _ := a.AuthenticateToken(context.TODO(), "token4"); !ok {
+		t.Errorf("AuthenticateToken() new token should be accepted")
+	}
+	if changed, err := a.reload(); err != nil || changed {
+		t.Errorf("reload() = %v, %v, want unchanged", changed, err)
+	}
+}
+
+func Test_BootstrapTokenAuthenticator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokenfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := writeTokenFile(t, dir, ""+
+		"abcdef.0123456789abcdef,,system:bootstrappers:gateway\n"+
+		"expire.0123456789abcdef,2000-01-01T00:00:00Z\n")
+	a, err := NewBootstrapTokenAuthenticator(path)
+	if err != nil {
+		t.Fatalf("NewBootstrapTokenAuthenticator() error = %v", err)
+	}
+
+	tests := []struct {
+		token  string
+		wantOK bool
+	}{
+		{"abcdef.0123456789abcdef", true},
+		{"abcdef.0123456789abcdee", false},
+		{"expire.0123456789abcdef", false},
+		{"unknow.0123456789abcdef", false},
+		{"invalid", false},
+	}
+	for _, tt := range tests {
+		resp, ok, err := a.AuthenticateToken(context.TODO(), tt.token)
+		if err != nil || ok != tt.wantOK {
+			t.Errorf("AuthenticateToken(%q) = %v, %v, want ok %v", tt.token, ok, err, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if resp.User.GetName() != "system:bootstrap:abcdef" {
+			t.Errorf("AuthenticateToken(%q) user = %v", tt.token, resp.User.GetName())
+		}
+		wantGroups := []string{"system:bootstrappers", "system:bootstrappers:gateway"}
+		if !reflect.DeepEqual(resp.User.GetGroups(), wantGroups) {
+			t.Errorf("AuthenticateToken(%q) groups = %v, want %v", tt.token, resp.User.GetGroups(), wantGroups)
+		}
+	}
+}
+
+func Test_parseBootstrapTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", "abcdef.0123456789abcdef\n", false},
+		{"invalid token", "abcdef.short\n", true},
+		{"invalid expiration", "abcdef.0123456789abcdef,tomorrow\n", true},
+		{"invalid extra group", "abcdef.0123456789abcdef,,system:masters\n", true},
+		{"duplicate id", "abcdef.0123456789abcdef\nabcdef.fedcba9876543210\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseBootstrapTokens([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("parseBootstrapTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package webhook
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -55,6 +56,10 @@ func (a *multiClusterTokenReviewAuthenticator) AuthenticateToken(ctx context.Con
 	host := info.Hostname
 
 	cluster, _, err := a.clientProvider.ClientFor(host)
+	if errors.Is(err, clusters.ErrFleetHostname) {
+		// a token valid on one member must not act as the same-named user on other members
+		return nil, false, nil
+	}
 	if err != nil {
 		return nil, false, err
 	}
@@ -0,0 +1,92 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+// fakeClientProvider provides clients of clusters by name
+type fakeClientProvider map[string]kubernetes.Interface
+
+func (p fakeClientProvider) ClientFor(name string) (*clusters.ClusterInfo, kubernetes.Interface, error) {
+	client, ok := p[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("cluster %q: %w", name, clusters.ErrClusterNotFound)
+	}
+	return clusters.NewEmptyClusterInfo(name, nil, nil, nil), client, nil
+}
+
+// newTokenReviewClient returns a client whose token reviews only authenticate token as user
+func newTokenReviewClient(token, user string) kubernetes.Interface {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == token {
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: authenticationv1.UserInfo{Username: user}}
+		}
+		return true, review, nil
+	})
+	return client
+}
+
+func TestMultiClusterTokenReviewAuthenticator_fleet(t *testing.T) {
+	members := fakeClientProvider{
+		"a.example.com": newTokenReviewClient("token-of-a", "system:serviceaccount:default:deployer"),
+		"b.example.com": newTokenReviewClient("token-of-b", "system:serviceaccount:default:deployer"),
+	}
+	provider := clusters.NewFleetClientProvider(members, "fleet.example.com", []string{"a.example.com", "b.example.com"})
+	auth := NewMultiClusterTokenReviewAuthenticator(provider, 0, 0, nil)
+
+	tests := []struct {
+		host     string
+		wantUser string
+	}{
+		{"a.example.com", "system:serviceaccount:default:deployer"},
+		// the same-named service account of member b is a different identity
+		{"b.example.com", ""},
+		// the token must not be impersonated into other members through the fleet hostname
+		{"fleet.example.com", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			ctx := request.WithExtraReqeustInfo(context.Background(), &request.ExtraRequestInfo{Hostname: tt.host})
+			resp, ok, err := auth.AuthenticateToken(ctx, "token-of-a")
+			if err != nil {
+				t.Fatalf("AuthenticateToken() error = %v", err)
+			}
+			if !ok {
+				if len(tt.wantUser) > 0 {
+					t.Errorf("AuthenticateToken() is not authenticated, want user %v", tt.wantUser)
+				}
+				return
+			}
+			if resp.User.GetName() != tt.wantUser {
+				t.Errorf("AuthenticateToken() user = %v, want %v", resp.User.GetName(), tt.wantUser)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package subjectaccessreview
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -72,6 +73,9 @@ func (a *MultiClusterSubjectAccessReviewAuthorizer) Authorize(ctx context.Contex
 	host := info.Hostname
 
 	cluster, client, err := a.clientProvider.ClientFor(host)
+	if errors.Is(err, clusters.ErrFleetHostname) {
+		return authorizeFleet(attr)
+	}
 	if err != nil {
 		return a.decisionOnError, "", err
 	}
@@ -130,6 +134,17 @@ func (a *MultiClusterSubjectAccessReviewAuthorizer) Authorize(ctx context.Contex
 	}
 }
 
+// authorizeFleet allows reads of resources on the fleet hostname, which are fanned out to member clusters.
+// Gateway authenticates to every member as the client, by impersonation, passthrough token or request
+// headers, so each member authorizes the request itself. Anything else, especially impersonation, is
+// denied because no member reviews it.
+func authorizeFleet(attr authorizer.Attributes) (authorizer.Decision, string, error) {
+	if attr.IsResourceRequest() && (attr.GetVerb() == "get" || attr.GetVerb() == "list") {
+		return authorizer.DecisionAllow, "authorized by each member cluster of fleet", nil
+	}
+	return authorizer.DecisionDeny, "fleet hostname only serves get and list of resources", nil
+}
+
 func (a *MultiClusterSubjectAccessReviewAuthorizer) subjectAccessReviewFromAttributes(attr authorizer.Attributes) *authorizationv1.SubjectAccessReview {
 	r := &authorizationv1.SubjectAccessReview{}
 	if user := attr.GetUser(); user != nil {
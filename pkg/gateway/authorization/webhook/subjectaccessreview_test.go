@@ -0,0 +1,60 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subjectaccessreview
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+type noClusterProvider struct{}
+
+func (noClusterProvider) ClientFor(name string) (*clusters.ClusterInfo, kubernetes.Interface, error) {
+	return nil, nil, clusters.ErrClusterNotFound
+}
+
+func TestMultiClusterSubjectAccessReviewAuthorizer_fleet(t *testing.T) {
+	provider := clusters.NewFleetClientProvider(noClusterProvider{}, "fleet.example.com", []string{"a.example.com"})
+	a := NewMultiClusterSubjectAccessReviewAuthorizer(provider, time.Minute, time.Minute)
+	ctx := request.WithExtraReqeustInfo(context.Background(), &request.ExtraRequestInfo{Hostname: "fleet.example.com"})
+	alice := &user.DefaultInfo{Name: "alice"}
+
+	tests := []struct {
+		name string
+		attr authorizer.AttributesRecord
+		want authorizer.Decision
+	}{
+		{"list", authorizer.AttributesRecord{User: alice, Verb: "list", Resource: "pods", ResourceRequest: true}, authorizer.DecisionAllow},
+		{"get", authorizer.AttributesRecord{User: alice, Verb: "get", Resource: "pods", Name: "a", ResourceRequest: true}, authorizer.DecisionAllow},
+		{"delete", authorizer.AttributesRecord{User: alice, Verb: "delete", Resource: "pods", Name: "a", ResourceRequest: true}, authorizer.DecisionDeny},
+		{"impersonate", authorizer.AttributesRecord{User: alice, Verb: "impersonate", Resource: "users", Name: "admin", ResourceRequest: true}, authorizer.DecisionDeny},
+		{"non-resource", authorizer.AttributesRecord{User: alice, Verb: "get", Path: "/api"}, authorizer.DecisionDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, err := a.Authorize(ctx, tt.attr); err != nil || got != tt.want {
+				t.Errorf("Authorize() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
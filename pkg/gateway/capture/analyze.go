@@ -0,0 +1,277 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// watches closed before shortWatchDuration are counted as churn
+	shortWatchDuration = time.Minute
+)
+
+// Count is the number of requests grouped by key
+type Count struct {
+	Key      string
+	Requests int64
+}
+
+// WatchChurn summarizes watch requests of one user
+type WatchChurn struct {
+	User    string
+	Watches int64
+	// ShortWatches is the number of watches closed within one minute
+	ShortWatches int64
+	// WatchesPerMinute is the average rate of new watches during the capture
+	WatchesPerMinute float64
+}
+
+// ClusterErrors summarizes retryable errors of one cluster
+type ClusterErrors struct {
+	Cluster  string
+	Requests int64
+	// Retryable is the number of requests finished with 429, 5xx or retried on other endpoints
+	Retryable int64
+	Retries   int64
+}
+
+// Rate returns the ratio of retryable requests
+func (c ClusterErrors) Rate() float64 {
+	if c.Requests == 0 {
+		return 0
+	}
+	return float64(c.Retryable) / float64(c.Requests)
+}
+
+// Report is the result of analyzing captures
+type Report struct {
+	Records   int64
+	Malformed int64
+	Start     time.Time
+	End       time.Time
+
+	TopUsers      []Count
+	TopResources  []Count
+	WatchChurn    []WatchChurn
+	ClusterErrors []ClusterErrors
+}
+
+// Analyze reads NDJSON records from captures, gzip compressed captures are detected automatically,
+// only the top n entries are kept in each ranking.
+func Analyze(captures []io.Reader, top int) (*Report, error) {
+	a := newAnalyzer()
+	for _, c := range captures {
+		if err := a.read(c); err != nil {
+			return nil, err
+		}
+	}
+	return a.report(top), nil
+}
+
+type analyzer struct {
+	records   int64
+	malformed int64
+	start     time.Time
+	end       time.Time
+
+	users     map[string]int64
+	resources map[string]int64
+	watches   map[string]*WatchChurn
+	clusters  map[string]*ClusterErrors
+}
+
+func newAnalyzer() *analyzer {
+	return &analyzer{
+		users:     map[string]int64{},
+		resources: map[string]int64{},
+		watches:   map[string]*WatchChurn{},
+		clusters:  map[string]*ClusterErrors{},
+	}
+}
+
+func (a *analyzer) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		record := Record{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			a.malformed++
+			continue
+		}
+		a.add(&record)
+	}
+	return scanner.Err()
+}
+
+func (a *analyzer) add(r *Record) {
+	a.records++
+	if a.start.IsZero() || r.Time.Before(a.start) {
+		a.start = r.Time
+	}
+	if r.Time.After(a.end) {
+		a.end = r.Time
+	}
+
+	a.users[r.User]++
+	a.resources[resourceKey(r)]++
+
+	if r.Verb == "watch" {
+		w, ok := a.watches[r.User]
+		if !ok {
+			w = &WatchChurn{User: r.User}
+			a.watches[r.User] = w
+		}
+		w.Watches++
+		if time.Duration(r.DurationMs*float64(time.Millisecond)) < shortWatchDuration {
+			w.ShortWatches++
+		}
+	}
+
+	c, ok := a.clusters[r.Cluster]
+	if !ok {
+		c = &ClusterErrors{Cluster: r.Cluster}
+		a.clusters[r.Cluster] = c
+	}
+	c.Requests++
+	c.Retries += int64(r.Retries)
+	if r.Code == http.StatusTooManyRequests || r.Code >= http.StatusInternalServerError || r.Retries > 0 {
+		c.Retryable++
+	}
+}
+
+func resourceKey(r *Record) string {
+	resource := r.Path
+	if len(r.Resource) > 0 {
+		resource = r.Resource
+		if len(r.APIGroup) > 0 {
+			resource = r.Resource + "." + r.APIGroup
+		}
+		if len(r.Subresource) > 0 {
+			resource += "/" + r.Subresource
+		}
+	}
+	return fmt.Sprintf("%s %s %s", r.Cluster, r.Verb, resource)
+}
+
+func (a *analyzer) report(top int) *Report {
+	report := &Report{
+		Records:      a.records,
+		Malformed:    a.malformed,
+		Start:        a.start,
+		End:          a.end,
+		TopUsers:     topCounts(a.users, top),
+		TopResources: topCounts(a.resources, top),
+	}
+
+	minutes := a.end.Sub(a.start).Minutes()
+	for _, w := range a.watches {
+		if minutes > 0 {
+			w.WatchesPerMinute = float64(w.Watches) / minutes
+		}
+		report.WatchChurn = append(report.WatchChurn, *w)
+	}
+	sort.Slice(report.WatchChurn, func(i, j int) bool {
+		if report.WatchChurn[i].ShortWatches != report.WatchChurn[j].ShortWatches {
+			return report.WatchChurn[i].ShortWatches > report.WatchChurn[j].ShortWatches
+		}
+		return report.WatchChurn[i].User < report.WatchChurn[j].User
+	})
+	if top > 0 && len(report.WatchChurn) > top {
+		report.WatchChurn = report.WatchChurn[:top]
+	}
+
+	for _, c := range a.clusters {
+		report.ClusterErrors = append(report.ClusterErrors, *c)
+	}
+	sort.Slice(report.ClusterErrors, func(i, j int) bool {
+		return report.ClusterErrors[i].Cluster < report.ClusterErrors[j].Cluster
+	})
+	return report
+}
+
+func topCounts(counts map[string]int64, top int) []Count {
+	result := make([]Count, 0, len(counts))
+	for k, v := range counts {
+		result = append(result, Count{Key: k, Requests: v})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Requests != result[j].Requests {
+			return result[i].Requests > result[j].Requests
+		}
+		return result[i].Key < result[j].Key
+	})
+	if top > 0 && len(result) > top {
+		result = result[:top]
+	}
+	return result
+}
+
+// Print writes report in human readable tables
+func (r *Report) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "records: %d, malformed: %d, from %s to %s\n", r.Records, r.Malformed, r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339))
+
+	fmt.Fprintf(w, "\nTOP USERS\tREQUESTS\n")
+	for _, c := range r.TopUsers {
+		fmt.Fprintf(w, "%s\t%d\n", displayUser(c.Key), c.Requests)
+	}
+
+	fmt.Fprintf(w, "\nTOP RESOURCES\tREQUESTS\n")
+	for _, c := range r.TopResources {
+		fmt.Fprintf(w, "%s\t%d\n", c.Key, c.Requests)
+	}
+
+	fmt.Fprintf(w, "\nWATCH CHURN\tWATCHES\tSHORT(<%v)\tPER MINUTE\n", shortWatchDuration)
+	for _, c := range r.WatchChurn {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f\n", displayUser(c.User), c.Watches, c.ShortWatches, c.WatchesPerMinute)
+	}
+
+	fmt.Fprintf(w, "\nCLUSTER\tREQUESTS\tRETRYABLE\tRATE\tRETRIES\n")
+	for _, c := range r.ClusterErrors {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%d\n", c.Cluster, c.Requests, c.Retryable, c.Rate()*100, c.Retries)
+	}
+	return w.Flush()
+}
+
+func displayUser(user string) string {
+	if len(strings.TrimSpace(user)) == 0 {
+		return "<anonymous>"
+	}
+	return user
+}
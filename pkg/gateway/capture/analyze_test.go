@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capture
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func encodeRecords(t *testing.T, compress bool, records ...Record) io.Reader {
+	buf := &bytes.Buffer{}
+	var w io.Writer = buf
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(buf)
+		w = gz
+	}
+	encoder := json.NewEncoder(w)
+	for i := range records {
+		if err := encoder.Encode(&records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	return buf
+}
+
+func Test_Analyze(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	plain := encodeRecords(t, false,
+		Record{Time: start, Cluster: "a", User: "alice", Verb: "list", Resource: "pods", Code: 200},
+		Record{Time: start.Add(time.Minute), Cluster: "a", User: "alice", Verb: "watch", Resource: "pods", Code: 200, DurationMs: 1000},
+		Record{Time: start.Add(2 * time.Minute), Cluster: "a", User: "bob", Verb: "get", Resource: "deployments", APIGroup: "apps", Code: 503},
+	)
+	compressed := encodeRecords(t, true,
+		Record{Time: start.Add(3 * time.Minute), Cluster: "b", User: "alice", Verb: "watch", Resource: "pods", Code: 200, DurationMs: 300000},
+		Record{Time: start.Add(4 * time.Minute), Cluster: "b", User: "bob", Verb: "list", Resource: "pods", Code: 200, Retries: 1},
+	)
+	malformed := strings.NewReader("not json\n\n")
+
+	report, err := Analyze([]io.Reader{plain, compressed, malformed}, 10)
+	if err != nil {
+		t.Fatalf("Analyze() error = %v", err)
+	}
+	if report.Records != 5 || report.Malformed != 1 {
+		t.Errorf("Analyze() records = %v, malformed = %v, want 5 and 1", report.Records, report.Malformed)
+	}
+	if len(report.TopUsers) != 2 || report.TopUsers[0] != (Count{Key: "alice", Requests: 3}) {
+		t.Errorf("Analyze() top users = %v", report.TopUsers)
+	}
+	if report.TopResources[0] != (Count{Key: "a get deployments.apps", Requests: 1}) {
+		t.Errorf("Analyze() top resources = %v", report.TopResources)
+	}
+	if len(report.WatchChurn) != 1 || report.WatchChurn[0].Watches != 2 || report.WatchChurn[0].ShortWatches != 1 {
+		t.Errorf("Analyze() watch churn = %+v", report.WatchChurn)
+	}
+	want := []ClusterErrors{
+		{Cluster: "a", Requests: 3, Retryable: 1},
+		{Cluster: "b", Requests: 2, Retryable: 1, Retries: 1},
+	}
+	for i := range want {
+		if report.ClusterErrors[i] != want[i] {
+			t.Errorf("Analyze() cluster errors = %+v, want %+v", report.ClusterErrors[i], want[i])
+		}
+	}
+	if err := report.Print(&bytes.Buffer{}); err != nil {
+		t.Errorf("Print() error = %v", err)
+	}
+}
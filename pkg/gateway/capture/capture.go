@@ -0,0 +1,177 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capture records sanitized metadata of proxied requests into NDJSON capture files
+// for offline traffic analysis. Request and response bodies, headers, credentials and query
+// parameters are never captured.
+package capture
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// recordBufferSize is the number of records waiting to be written, records are dropped
+	// instead of blocking requests if the buffer is full
+	recordBufferSize = 4096
+
+	flushInterval = time.Second
+)
+
+// Record is the sanitized metadata of one request
+type Record struct {
+	Time        time.Time `json:"ts"`
+	Cluster     string    `json:"cluster"`
+	User        string    `json:"user,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	Verb        string    `json:"verb"`
+	APIGroup    string    `json:"group,omitempty"`
+	APIVersion  string    `json:"version,omitempty"`
+	Resource    string    `json:"resource,omitempty"`
+	Subresource string    `json:"subresource,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	// Path is only set for non-resource requests
+	Path string `json:"path,omitempty"`
+	// Code is the status code written to client
+	Code       int     `json:"code"`
+	DurationMs float64 `json:"durationMs"`
+	Forwarded  bool    `json:"forwarded"`
+	Endpoint   string  `json:"endpoint,omitempty"`
+	// Reason is the termination reason of requests not forwarded to upstream
+	Reason string `json:"reason,omitempty"`
+	// Rejection is the rejection code of Reason, see package rejection
+	Rejection string `json:"rejection,omitempty"`
+	// Retries is the number of failed tries to upstream endpoints before the final one
+	Retries    int    `json:"retries,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+	// FlowControl is the name of flow control schema which the request is accounted to
+	FlowControl string `json:"flowControl,omitempty"`
+	RequestID   string `json:"requestID,omitempty"`
+}
+
+// Recorder writes sampled records into a capture file asynchronously, the file is
+// gzip compressed if its name ends with .gz
+type Recorder struct {
+	path       string
+	sampleRate float64
+	maxBytes   int64
+
+	records chan *Record
+	dropped int64
+	written int64
+}
+
+// NewRecorder creates a recorder, maxBytes <= 0 means unlimited, capturing stops once the
+// uncompressed records exceed it.
+func NewRecorder(path string, sampleRate float64, maxBytes int64) *Recorder {
+	return &Recorder{
+		path:       path,
+		sampleRate: sampleRate,
+		maxBytes:   maxBytes,
+		records:    make(chan *Record, recordBufferSize),
+	}
+}
+
+// Sampled returns true if the current request should be captured
+func (r *Recorder) Sampled() bool {
+	return r.sampleRate >= 1 || rand.Float64() < r.sampleRate
+}
+
+// Record queues a record to be written, it never blocks
+func (r *Recorder) Record(record *Record) {
+	select {
+	case r.records <- record:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// Run writes queued records into capture file until stopCh is closed
+func (r *Recorder) Run(stopCh <-chan struct{}) {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		klog.Errorf("[traffic capture] failed to open capture file %s: %v", r.path, err)
+		return
+	}
+	defer file.Close()
+	klog.Infof("[traffic capture] start capturing requests into %s, sampleRate=%v", r.path, r.sampleRate)
+
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if strings.HasSuffix(r.path, ".gz") {
+		// every run appends a new gzip member, which is readable as one stream
+		gz = gzip.NewWriter(file)
+		defer gz.Close()
+		w = gz
+	}
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(&countingWriter{w: buf, n: &r.written})
+	flush := func() {
+		if err := buf.Flush(); err != nil {
+			klog.Errorf("[traffic capture] failed to write capture file %s: %v", r.path, err)
+		}
+		if gz != nil {
+			gz.Flush()
+		}
+		if dropped := atomic.SwapInt64(&r.dropped, 0); dropped > 0 {
+			klog.Warningf("[traffic capture] dropped %d records because the buffer is full", dropped)
+		}
+	}
+	defer flush()
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	full := false
+	for {
+		select {
+		case record := <-r.records:
+			if full {
+				continue
+			}
+			if r.maxBytes > 0 && atomic.LoadInt64(&r.written) >= r.maxBytes {
+				klog.Warningf("[traffic capture] capture file %s reached max size %d bytes, stop capturing", r.path, r.maxBytes)
+				full = true
+				continue
+			}
+			if err := encoder.Encode(record); err != nil {
+				klog.Errorf("[traffic capture] failed to encode record: %v", err)
+			}
+		case <-ticker.C:
+			flush()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
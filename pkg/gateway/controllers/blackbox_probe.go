@@ -0,0 +1,223 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	proxylisters "github.com/kubewharf/kubegateway/pkg/client/listers/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+var probeFailureReasons = map[string]string{
+	clusters.ProbeStageTCP:  "TCPUnreachable",
+	clusters.ProbeStageTLS:  "TLSHandshakeFailed",
+	clusters.ProbeStageHTTP: "HTTPUnhealthy",
+}
+
+// BlackboxProbeConfig enables blackbox probing mode, in which each gateway replica verifies TCP, TLS
+// and HTTP reachability to every endpoint by itself. Unreachable endpoints are not routed by this
+// replica only, so asymmetric network partitions are factored into local routing.
+type BlackboxProbeConfig struct {
+	// Timeout bounds all stages of one probe
+	Timeout time.Duration
+	// Replica is the name of this gateway replica in reachability status
+	Replica string
+	// ReportInterval is the interval to report reachability to UpstreamCluster status, zero means not reporting
+	ReportInterval time.Duration
+	// Client is the gateway control plane client used to patch UpstreamCluster
+	Client gatewayclientset.Interface
+}
+
+// NewBlackboxHealthCheck returns an endpoint health check which probes endpoints stage by stage
+func NewBlackboxHealthCheck(timeout time.Duration) clusters.EndpointHealthCheck {
+	return func(e *clusters.EndpointInfo) (done bool) {
+		result := e.Probe(e.Context(), timeout)
+		metrics.RecordUpstreamProbe(e.Cluster, e.Endpoint, result.Reachable, result.FailedStage, time.Duration(result.LatencyMilliseconds)*time.Millisecond)
+		if result.Reachable {
+			e.UpdateStatus(true, "", "")
+			return false
+		}
+		reason := probeFailureReasons[result.FailedStage]
+		klog.Errorf("upstream blackbox probe failed, cluster=%q endpoint=%q stage=%q message=%q", e.Cluster, e.Endpoint, result.FailedStage, result.Message)
+		e.UpdateStatus(false, reason, result.Message)
+		return false
+	}
+}
+
+const (
+	// reachabilityRefreshIntervals is the number of report intervals after which unchanged reachability
+	// is reported again, so results of live replicas are never mistaken for those of stopped ones
+	reachabilityRefreshIntervals = 3
+	// reachabilityExpireIntervals is the number of report intervals after which reachability of a replica
+	// is removed from status, e.g. a replica replaced by a rollout
+	reachabilityExpireIntervals = 2 * reachabilityRefreshIntervals
+	// maxReplicaReachability bounds reachability entries in one status, the oldest ones are removed first
+	maxReplicaReachability = 64
+)
+
+// reachabilityReporter reports probe results of this replica to status of each UpstreamCluster,
+// so reachability observed by different replicas can be compared.
+type reachabilityReporter struct {
+	replica  string
+	client   gatewayclientset.Interface
+	lister   proxylisters.UpstreamClusterLister
+	manager  clusters.Manager
+	interval time.Duration
+}
+
+func newReachabilityReporter(cfg BlackboxProbeConfig, lister proxylisters.UpstreamClusterLister, manager clusters.Manager) *reachabilityReporter {
+	return &reachabilityReporter{
+		replica:  cfg.Replica,
+		client:   cfg.Client,
+		lister:   lister,
+		manager:  manager,
+		interval: cfg.ReportInterval,
+	}
+}
+
+func (r *reachabilityReporter) Run(stopCh <-chan struct{}) {
+	klog.Infof("[reachability reporter] start reporting endpoint reachability of replica %s to status", r.replica)
+	wait.Until(r.report, r.interval, stopCh)
+}
+
+func (r *reachabilityReporter) report() {
+	list, err := r.lister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[reachability reporter] failed to list upstream clusters: %v", err)
+		return
+	}
+	for _, cluster := range list {
+		info, ok := r.manager.Get(cluster.Name)
+		if !ok {
+			continue
+		}
+		endpoints := []proxyv1alpha1.EndpointReachability{}
+		for _, endpoint := range info.AllEndpoints() {
+			e, ok := info.Endpoints.Load(endpoint)
+			if !ok {
+				continue
+			}
+			if result, ok := e.LastProbeResult(); ok {
+				endpoints = append(endpoints, proxyv1alpha1.EndpointReachability{
+					Endpoint:            endpoint,
+					Reachable:           result.Reachable,
+					FailedStage:         result.FailedStage,
+					Message:             result.Message,
+					LatencyMilliseconds: result.LatencyMilliseconds,
+					LastProbeTime:       result.LastProbeTime,
+				})
+			}
+		}
+		if len(endpoints) == 0 || !r.needReport(replicaReachabilityOf(cluster.Status, r.replica), endpoints) {
+			continue
+		}
+		sort.Slice(endpoints, func(i, j int) bool { return endpoints[i].Endpoint < endpoints[j].Endpoint })
+
+		// replicas update status of the same cluster concurrently, retry with the latest one on conflicts
+		current := cluster.DeepCopy()
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			setReplicaReachability(&current.Status, proxyv1alpha1.ReplicaReachability{Replica: r.replica, Endpoints: endpoints}, time.Now(), reachabilityExpireIntervals*r.interval)
+			_, err := r.client.ProxyV1alpha1().UpstreamClusters().UpdateStatus(context.TODO(), current, metav1.UpdateOptions{})
+			if errors.IsConflict(err) {
+				latest, getErr := r.client.ProxyV1alpha1().UpstreamClusters().Get(context.TODO(), cluster.Name, metav1.GetOptions{})
+				if getErr != nil {
+					return getErr
+				}
+				current = latest
+			}
+			return err
+		})
+		if err != nil {
+			klog.Errorf("[reachability reporter] failed to report reachability of cluster %q: %v", cluster.Name, err)
+		}
+	}
+}
+
+// needReport returns true if reachability changed, or the reported results are too old and
+// may be mistaken for results from a stopped replica.
+func (r *reachabilityReporter) needReport(reported *proxyv1alpha1.ReplicaReachability, endpoints []proxyv1alpha1.EndpointReachability) bool {
+	if reported == nil || len(reported.Endpoints) != len(endpoints) {
+		return true
+	}
+	old := map[string]proxyv1alpha1.EndpointReachability{}
+	for _, e := range reported.Endpoints {
+		old[e.Endpoint] = e
+	}
+	staleAfter := reachabilityRefreshIntervals * r.interval
+	for _, e := range endpoints {
+		o, ok := old[e.Endpoint]
+		if !ok || o.Reachable != e.Reachable || o.FailedStage != e.FailedStage || o.Message != e.Message {
+			return true
+		}
+		if e.LastProbeTime.Sub(o.LastProbeTime.Time) > staleAfter {
+			return true
+		}
+	}
+	return false
+}
+
+func replicaReachabilityOf(status proxyv1alpha1.UpstreamClusterStatus, replica string) *proxyv1alpha1.ReplicaReachability {
+	for i := range status.Reachability {
+		if status.Reachability[i].Replica == replica {
+			return &status.Reachability[i]
+		}
+	}
+	return nil
+}
+
+// lastReportTime returns the latest probe time in reachability, replicas report all their results at once
+func lastReportTime(reachability proxyv1alpha1.ReplicaReachability) time.Time {
+	var last time.Time
+	for _, e := range reachability.Endpoints {
+		if e.LastProbeTime.After(last) {
+			last = e.LastProbeTime.Time
+		}
+	}
+	return last
+}
+
+// setReplicaReachability sets reachability of one replica in status. Entries of other replicas which are
+// not reported in expireAfter are removed, and at most maxReplicaReachability latest entries are kept, so
+// replicas replaced by rollouts do not accumulate in status.
+func setReplicaReachability(status *proxyv1alpha1.UpstreamClusterStatus, reachability proxyv1alpha1.ReplicaReachability, now time.Time, expireAfter time.Duration) {
+	merged := []proxyv1alpha1.ReplicaReachability{reachability}
+	for _, reported := range status.Reachability {
+		if reported.Replica == reachability.Replica || now.Sub(lastReportTime(reported)) > expireAfter {
+			continue
+		}
+		merged = append(merged, reported)
+	}
+	if len(merged) > maxReplicaReachability {
+		// the first one is reported now and is always kept
+		others := merged[1:]
+		sort.SliceStable(others, func(i, j int) bool { return lastReportTime(others[i]).After(lastReportTime(others[j])) })
+		merged = merged[:maxReplicaReachability]
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Replica < merged[j].Replica })
+	status.Reachability = merged
+}
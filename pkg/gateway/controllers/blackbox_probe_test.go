@@ -0,0 +1,126 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+func Test_reachabilityReporter_needReport(t *testing.T) {
+	now := time.Now()
+	reported := &proxyv1alpha1.ReplicaReachability{
+		Replica: "gateway-0",
+		Endpoints: []proxyv1alpha1.EndpointReachability{
+			{Endpoint: "https://a:6443", Reachable: true, LastProbeTime: metav1.NewTime(now)},
+		},
+	}
+	r := &reachabilityReporter{replica: "gateway-0", interval: 30 * time.Second}
+
+	tests := []struct {
+		name      string
+		reported  *proxyv1alpha1.ReplicaReachability
+		endpoints []proxyv1alpha1.EndpointReachability
+		want      bool
+	}{
+		{"not reported", nil, reported.Endpoints, true},
+		{"unchanged", reported, []proxyv1alpha1.EndpointReachability{
+			{Endpoint: "https://a:6443", Reachable: true, LatencyMilliseconds: 10, LastProbeTime: metav1.NewTime(now.Add(time.Minute))},
+		}, false},
+		{"reachability changed", reported, []proxyv1alpha1.EndpointReachability{
+			{Endpoint: "https://a:6443", Reachable: false, FailedStage: clusters.ProbeStageTLS, LastProbeTime: metav1.NewTime(now)},
+		}, true},
+		{"endpoint added", reported, []proxyv1alpha1.EndpointReachability{
+			{Endpoint: "https://a:6443", Reachable: true, LastProbeTime: metav1.NewTime(now)},
+			{Endpoint: "https://b:6443", Reachable: true, LastProbeTime: metav1.NewTime(now)},
+		}, true},
+		{"stale report", reported, []proxyv1alpha1.EndpointReachability{
+			{Endpoint: "https://a:6443", Reachable: true, LastProbeTime: metav1.NewTime(now.Add(time.Hour))},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.needReport(tt.reported, tt.endpoints); got != tt.want {
+				t.Errorf("needReport() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_setReplicaReachability(t *testing.T) {
+	now := time.Now()
+	expireAfter := time.Minute
+	reachabilityAt := func(replica string, probeTime time.Time, reachable bool) proxyv1alpha1.ReplicaReachability {
+		return proxyv1alpha1.ReplicaReachability{
+			Replica:   replica,
+			Endpoints: []proxyv1alpha1.EndpointReachability{{Endpoint: "https://a:6443", Reachable: reachable, LastProbeTime: metav1.NewTime(probeTime)}},
+		}
+	}
+
+	status := proxyv1alpha1.UpstreamClusterStatus{}
+	setReplicaReachability(&status, reachabilityAt("gateway-1", now, false), now, expireAfter)
+	setReplicaReachability(&status, reachabilityAt("gateway-0", now, true), now, expireAfter)
+	updated := reachabilityAt("gateway-1", now, true)
+	setReplicaReachability(&status, updated, now, expireAfter)
+
+	want := []proxyv1alpha1.ReplicaReachability{reachabilityAt("gateway-0", now, true), updated}
+	if !reflect.DeepEqual(status.Reachability, want) {
+		t.Errorf("setReplicaReachability() got %v, want %v", status.Reachability, want)
+	}
+
+	// gateway-0 is not reported after a rollout and expires, the replacement replica is added
+	later := now.Add(2 * expireAfter)
+	setReplicaReachability(&status, reachabilityAt("gateway-1", later, true), later, expireAfter)
+	setReplicaReachability(&status, reachabilityAt("gateway-2", later, true), later, expireAfter)
+	want = []proxyv1alpha1.ReplicaReachability{reachabilityAt("gateway-1", later, true), reachabilityAt("gateway-2", later, true)}
+	if !reflect.DeepEqual(status.Reachability, want) {
+		t.Errorf("setReplicaReachability() after expiration got %v, want %v", status.Reachability, want)
+	}
+}
+
+func Test_setReplicaReachability_bounded(t *testing.T) {
+	now := time.Now()
+	status := proxyv1alpha1.UpstreamClusterStatus{}
+	for i := 0; i < maxReplicaReachability+10; i++ {
+		// later replicas reported more recently
+		probeTime := now.Add(time.Duration(i) * time.Millisecond)
+		setReplicaReachability(&status, proxyv1alpha1.ReplicaReachability{
+			Replica:   fmt.Sprintf("gateway-%03d", i),
+			Endpoints: []proxyv1alpha1.EndpointReachability{{Endpoint: "https://a:6443", Reachable: true, LastProbeTime: metav1.NewTime(probeTime)}},
+		}, probeTime, time.Hour)
+	}
+	// the oldest replica reports again and is kept
+	setReplicaReachability(&status, proxyv1alpha1.ReplicaReachability{
+		Replica:   "gateway-000",
+		Endpoints: []proxyv1alpha1.EndpointReachability{{Endpoint: "https://a:6443", Reachable: true, LastProbeTime: metav1.NewTime(now.Add(time.Second))}},
+	}, now.Add(time.Second), time.Hour)
+
+	if len(status.Reachability) != maxReplicaReachability {
+		t.Fatalf("setReplicaReachability() kept %d entries, want %d", len(status.Reachability), maxReplicaReachability)
+	}
+	if got := status.Reachability[0].Replica; got != "gateway-000" {
+		t.Errorf("first entry = %q, want the replica reported last", got)
+	}
+	if got := status.Reachability[1].Replica; got != "gateway-011" {
+		t.Errorf("second entry = %q, want the oldest entries removed", got)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/cert"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const canaryUserAgent = "kube-gateway-canary"
+
+// CanaryConfig enables synthetic canary requests, which are sent to the proxy listener of this replica
+// for each upstream cluster and go through the full proxy path, including TLS, authentication,
+// authorization, flow control and dispatching. Their success and latency are the end-to-end availability
+// of a cluster, unlike endpoint health checks which bypass the proxy path.
+type CanaryConfig struct {
+	// Address is the proxy listener which canary requests are sent to, e.g. 127.0.0.1:9443
+	Address string
+	// Paths are cheap read requests sent to each cluster every round, e.g. /version
+	Paths []string
+	// Interval is the interval between two rounds
+	Interval time.Duration
+	// Timeout bounds each canary request
+	Timeout time.Duration
+	// TokenFile contains the bearer token authenticating canary requests, it is read every round so
+	// rotated tokens are picked up. Empty means canary requests are anonymous.
+	TokenFile string
+	// CAFile verifies the default serving certificate of gateway for clusters without their own serving
+	// certificates, clusters with serving certificates are pinned to them. Canary requests to clusters
+	// whose serving certificate can not be verified fail before the token is sent.
+	CAFile string
+}
+
+// canaryProber sends canary requests to all clusters of manager every interval
+type canaryProber struct {
+	cfg     CanaryConfig
+	manager clusters.Manager
+	// roots verify the default serving certificate of gateway, nil means it can not be verified
+	roots  *x509.CertPool
+	client *http.Client
+}
+
+func newCanaryProber(cfg CanaryConfig, manager clusters.Manager) *canaryProber {
+	p := &canaryProber{
+		cfg:     cfg,
+		manager: manager,
+	}
+	if len(cfg.CAFile) > 0 {
+		roots, err := cert.NewPool(cfg.CAFile)
+		if err != nil {
+			klog.Errorf("[canary] failed to load CA file %q, canary requests to clusters using the default serving certificate will fail: %v", cfg.CAFile, err)
+		}
+		p.roots = roots
+	}
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	transport := &http.Transport{
+		// requests are addressed to cluster hostnames for SNI and Host based routing,
+		// but always dialed to the proxy listener of this replica
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, cfg.Address)
+		},
+		TLSClientConfig: &tls.Config{
+			// the default verification checks the dialed address, serving certificates are verified
+			// against the requested cluster by verifyServingCertificate instead
+			InsecureSkipVerify: true, //nolint:gosec
+			VerifyConnection:   p.verifyServingCertificate,
+		},
+		TLSHandshakeTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost: 1,
+		IdleConnTimeout:     2 * cfg.Interval,
+	}
+	p.client = &http.Client{Transport: transport, Timeout: cfg.Timeout}
+	return p
+}
+
+// verifyServingCertificate verifies the certificate served for the requested cluster, the handshake fails
+// and the token is never sent if it is not the serving certificate of the cluster or it is not signed by
+// roots for the cluster hostname.
+func (p *canaryProber) verifyServingCertificate(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return fmt.Errorf("no serving certificate for %q", state.ServerName)
+	}
+	leaf := state.PeerCertificates[0]
+	if p.manager != nil {
+		if cluster, ok := p.manager.Resolve(state.ServerName); ok {
+			if tlsConfig, ok := cluster.LoadTLSConfig(); ok && len(tlsConfig.Certificates) > 0 {
+				for _, c := range tlsConfig.Certificates {
+					if len(c.Certificate) > 0 && bytes.Equal(c.Certificate[0], leaf.Raw) {
+						return nil
+					}
+				}
+				return fmt.Errorf("certificate served for %q is not the serving certificate of cluster %q", state.ServerName, cluster.Cluster)
+			}
+		}
+	}
+	if p.roots == nil {
+		return fmt.Errorf("can not verify the default serving certificate for %q without --proxy-canary-ca-file", state.ServerName)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{DNSName: state.ServerName, Roots: p.roots, Intermediates: intermediates})
+	return err
+}
+
+func (p *canaryProber) Run(stopCh <-chan struct{}) {
+	klog.Infof("[canary] start sending canary requests %v to %s every %v", p.cfg.Paths, p.cfg.Address, p.cfg.Interval)
+	// the proxy listener may not be serving yet when controller starts
+	select {
+	case <-stopCh:
+		return
+	case <-time.After(p.cfg.Interval):
+	}
+	wait.Until(p.probeAll, p.cfg.Interval, stopCh)
+}
+
+func (p *canaryProber) probeAll() {
+	token, err := p.token()
+	if err != nil {
+		klog.Errorf("[canary] failed to read token file %q: %v", p.cfg.TokenFile, err)
+		return
+	}
+	var wg sync.WaitGroup
+	for _, cluster := range p.manager.List() {
+		wg.Add(1)
+		go func(cluster string) {
+			defer wg.Done()
+			p.probe(cluster, token)
+		}(cluster.Cluster)
+	}
+	wg.Wait()
+}
+
+func (p *canaryProber) token() (string, error) {
+	if len(p.cfg.TokenFile) == 0 {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(p.cfg.TokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// probe sends all canary requests to cluster, the cluster is available if all of them succeed
+func (p *canaryProber) probe(cluster, token string) {
+	available := true
+	for _, path := range p.cfg.Paths {
+		start := time.Now()
+		err := p.do(cluster, path, token)
+		metrics.RecordCanaryRequest(cluster, path, err == nil, time.Since(start))
+		if err != nil {
+			available = false
+			klog.Errorf("[canary] canary request failed, cluster=%q path=%q err: %v", cluster, path, err)
+		}
+	}
+	metrics.RecordCanaryAvailable(cluster, available)
+}
+
+func (p *canaryProber) do(cluster, path, token string) error {
+	_, port, _ := net.SplitHostPort(p.cfg.Address)
+	req, err := http.NewRequest(http.MethodGet, "https://"+net.JoinHostPort(cluster, port)+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", canaryUserAgent)
+	req.Header.Set("Accept", "application/json")
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// read the whole body so the connection is reused and the request is complete end to end
+	if _, err := io.Copy(ioutil.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
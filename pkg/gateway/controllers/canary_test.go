@@ -0,0 +1,75 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_canaryProber_do(t *testing.T) {
+	var gotHost, gotServerName, gotAuthorization string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotHost, gotServerName, gotAuthorization = req.Host, req.TLS.ServerName, req.Header.Get("Authorization")
+		if req.URL.Path != "/version" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"major":"1"}`)) //nolint
+	}))
+	server.TLS = &tls.Config{}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("failed to write CA file: %v", err)
+	}
+
+	// the serving certificate can not be verified without CA file, the token must not be sent
+	insecure := newCanaryProber(CanaryConfig{Address: server.Listener.Addr().String(), Interval: time.Minute, Timeout: 5 * time.Second}, nil)
+	if err := insecure.do("example.com", "/version", "token"); err == nil {
+		t.Errorf("do() error = nil for unverified serving certificate")
+	}
+	if len(gotAuthorization) > 0 {
+		t.Errorf("do() sent token %q over unverified connection", gotAuthorization)
+	}
+
+	// serving certificate of httptest is signed for example.com
+	p := newCanaryProber(CanaryConfig{Address: server.Listener.Addr().String(), Interval: time.Minute, Timeout: 5 * time.Second, CAFile: caFile}, nil)
+	if err := p.do("example.com", "/version", "token"); err != nil {
+		t.Fatalf("do() unexpected error = %v", err)
+	}
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	if gotHost != "example.com:"+port || gotServerName != "example.com" {
+		t.Errorf("do() host = %q, server name = %q, want routed to example.com", gotHost, gotServerName)
+	}
+	if gotAuthorization != "Bearer token" {
+		t.Errorf("do() authorization = %q, want bearer token", gotAuthorization)
+	}
+	if err := p.do("example.com", "/api/v1/namespaces/kube-system", ""); err == nil {
+		t.Errorf("do() error = nil for forbidden canary request")
+	}
+	if err := p.do("cluster-a", "/version", "token"); err == nil {
+		t.Errorf("do() error = nil for serving certificate not signed for cluster-a")
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+const (
+	// MaintenanceAnnotationKey marks a node of the management cluster under maintenance when it is "true",
+	// endpoints served by the node are cordoned until the annotation is removed.
+	MaintenanceAnnotationKey = "proxy.kubegateway.io/maintenance"
+
+	// MaintenanceEndpointsAnnotationKey lists upstream endpoints served by a node as a comma separated list of
+	// urls or hosts, e.g. https://10.0.0.1:6443. Without it, endpoints are matched by addresses of the node.
+	MaintenanceEndpointsAnnotationKey = "proxy.kubegateway.io/upstream-endpoints"
+)
+
+// MaintenanceConfig enables cordoning endpoints whose upstream control plane machines are marked for maintenance
+// by node objects of a management cluster, so they are taken out of routing before the machine goes down.
+type MaintenanceConfig struct {
+	// Client is the client of management cluster where nodes represent upstream control plane machines
+	Client kubernetes.Interface
+	// NodeSelector selects nodes representing upstream control plane machines, empty means all nodes
+	NodeSelector string
+	// HonorUnschedulable also treats unschedulable nodes, e.g. cordoned by kubectl drain, as under maintenance
+	HonorUnschedulable bool
+	// ResyncInterval is the interval to reconcile all endpoints, so new endpoints are cordoned as well
+	ResyncInterval time.Duration
+}
+
+// maintenanceCordoner cordons endpoints of all clusters of manager by nodes under maintenance
+type maintenanceCordoner struct {
+	cfg      MaintenanceConfig
+	manager  clusters.Manager
+	informer cache.SharedIndexInformer
+	lister   corelisters.NodeLister
+	queue    chan struct{}
+}
+
+func newMaintenanceCordoner(cfg MaintenanceConfig, manager clusters.Manager) *maintenanceCordoner {
+	factory := informers.NewSharedInformerFactoryWithOptions(cfg.Client, 0, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.LabelSelector = cfg.NodeSelector
+	}))
+	nodes := factory.Core().V1().Nodes()
+	c := &maintenanceCordoner{
+		cfg:      cfg,
+		manager:  manager,
+		informer: nodes.Informer(),
+		lister:   nodes.Lister(),
+		queue:    make(chan struct{}, 1),
+	}
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { c.enqueue() },
+		UpdateFunc: func(interface{}, interface{}) { c.enqueue() },
+		DeleteFunc: func(interface{}) { c.enqueue() },
+	})
+	return c
+}
+
+func (c *maintenanceCordoner) enqueue() {
+	select {
+	case c.queue <- struct{}{}:
+	default:
+	}
+}
+
+func (c *maintenanceCordoner) Run(stopCh <-chan struct{}) {
+	klog.Infof("[maintenance] start cordoning endpoints of nodes under maintenance, selector=%q honorUnschedulable=%v", c.cfg.NodeSelector, c.cfg.HonorUnschedulable)
+	go c.informer.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		klog.Errorf("[maintenance] failed to wait for nodes synced")
+		return
+	}
+	go wait.Until(c.enqueue, c.cfg.ResyncInterval, stopCh)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.queue:
+			c.reconcile()
+		}
+	}
+}
+
+func (c *maintenanceCordoner) reconcile() {
+	nodes, err := c.lister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[maintenance] failed to list nodes: %v", err)
+		return
+	}
+	reasons := maintenanceReasons(nodes, c.cfg.HonorUnschedulable)
+	for _, cluster := range c.manager.List() {
+		cluster.Endpoints.Range(func(name string, info *clusters.EndpointInfo) bool {
+			reason, ok := reasons[name]
+			if !ok {
+				reason, ok = reasons[endpointHost(name)]
+			}
+			if ok != info.IsCordoned() {
+				klog.Infof("[maintenance] cluster=%q endpoint=%q cordoned=%v %s", cluster.Cluster, name, ok, reason)
+			}
+			info.SetCordoned(ok, reason)
+			return true
+		})
+	}
+}
+
+// maintenanceReasons returns endpoint urls and hosts of nodes under maintenance with the reason
+func maintenanceReasons(nodes []*corev1.Node, honorUnschedulable bool) map[string]string {
+	reasons := map[string]string{}
+	// sorted, so the reason of an endpoint shared by nodes is stable
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, node := range nodes {
+		var reason string
+		switch {
+		case node.Annotations[MaintenanceAnnotationKey] == "true":
+			reason = fmt.Sprintf("node %s is annotated with %s", node.Name, MaintenanceAnnotationKey)
+		case honorUnschedulable && node.Spec.Unschedulable:
+			reason = fmt.Sprintf("node %s is unschedulable", node.Name)
+		default:
+			continue
+		}
+		for _, key := range nodeEndpoints(node) {
+			if _, ok := reasons[key]; !ok {
+				reasons[key] = reason
+			}
+		}
+	}
+	return reasons
+}
+
+// nodeEndpoints returns endpoint urls or hosts served by node
+func nodeEndpoints(node *corev1.Node) []string {
+	if value := node.Annotations[MaintenanceEndpointsAnnotationKey]; len(value) > 0 {
+		var endpoints []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				endpoints = append(endpoints, strings.TrimSuffix(s, "/"))
+			}
+		}
+		return endpoints
+	}
+	var hosts []string
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case corev1.NodeInternalIP, corev1.NodeExternalIP, corev1.NodeHostName, corev1.NodeInternalDNS, corev1.NodeExternalDNS:
+			hosts = append(hosts, address.Address)
+		}
+	}
+	return hosts
+}
+
+// endpointHost returns host of endpoint url without port
+func endpointHost(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Host) == 0 {
+		return endpoint
+	}
+	if host, _, err := net.SplitHostPort(u.Host); err == nil {
+		return host
+	}
+	return u.Host
+}
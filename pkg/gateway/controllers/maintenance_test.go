@@ -0,0 +1,68 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_maintenanceReasons(t *testing.T) {
+	node := func(name string, annotations map[string]string, unschedulable bool, address string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+			Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+			Status:     corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: address}}},
+		}
+	}
+	nodes := []*corev1.Node{
+		node("master-3", nil, false, "10.0.0.3"),
+		node("master-2", nil, true, "10.0.0.2"),
+		node("master-1", map[string]string{
+			MaintenanceAnnotationKey:          "true",
+			MaintenanceEndpointsAnnotationKey: "https://10.0.0.1:6443/, https://apiserver-1.example.com:6443",
+		}, false, "10.0.0.1"),
+	}
+
+	got := maintenanceReasons(nodes, false)
+	want := map[string]string{
+		"https://10.0.0.1:6443":                "node master-1 is annotated with " + MaintenanceAnnotationKey,
+		"https://apiserver-1.example.com:6443": "node master-1 is annotated with " + MaintenanceAnnotationKey,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maintenanceReasons() = %v, want %v", got, want)
+	}
+
+	got = maintenanceReasons(nodes, true)
+	if got["10.0.0.2"] != "node master-2 is unschedulable" || len(got) != 3 {
+		t.Errorf("maintenanceReasons() with unschedulable = %v", got)
+	}
+}
+
+func Test_endpointHost(t *testing.T) {
+	tests := map[string]string{
+		"https://10.0.0.1:6443":         "10.0.0.1",
+		"https://[fd00::1]:6443":        "fd00::1",
+		"https://apiserver.example.com": "apiserver.example.com",
+	}
+	for endpoint, want := range tests {
+		if got := endpointHost(endpoint); got != want {
+			t.Errorf("endpointHost(%q) = %v, want %v", endpoint, got, want)
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -35,6 +36,7 @@ import (
 	scheme "github.com/kubewharf/kubegateway/pkg/client/kubernetes/scheme"
 	proxylisters "github.com/kubewharf/kubegateway/pkg/client/listers/proxy/v1alpha1"
 	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
 	gatewaynet "github.com/kubewharf/kubegateway/pkg/gateway/net"
 	"github.com/kubewharf/kubegateway/pkg/syncqueue"
 )
@@ -47,14 +49,34 @@ type UpstreamClusterController struct {
 	lister proxylisters.UpstreamClusterLister
 	synced cache.InformerSynced
 
+	// defaults are the settings of all clusters created by the controller
+	defaults    *clusters.ClusterDefaults
+	healthCheck clusters.EndpointHealthCheck
+	reporter    *reachabilityReporter
+	canary      *canaryProber
+	maintenance *maintenanceCordoner
+
+	stateStore        *clusters.EndpointStateStore
+	stateSaveInterval time.Duration
+
+	// listener port -> tls policy
+	listenerTLSPolicies map[int]*gatewaynet.TLSPolicy
+
 	clusters.Manager
 }
 
-func NewUpstreamClusterController(upstreamclusterinformer proxyinformers.UpstreamClusterInformer) *UpstreamClusterController {
+// NewUpstreamClusterController creates a controller whose clusters are created with defaults, nil means
+// clusters.NewClusterDefaults(). defaults must not be changed after the controller is created.
+func NewUpstreamClusterController(upstreamclusterinformer proxyinformers.UpstreamClusterInformer, defaults *clusters.ClusterDefaults) *UpstreamClusterController {
+	if defaults == nil {
+		defaults = clusters.NewClusterDefaults()
+	}
 	m := &UpstreamClusterController{
-		lister:  upstreamclusterinformer.Lister(),
-		synced:  upstreamclusterinformer.Informer().HasSynced,
-		Manager: clusters.NewManager(),
+		lister:      upstreamclusterinformer.Lister(),
+		synced:      upstreamclusterinformer.Informer().HasSynced,
+		defaults:    defaults,
+		healthCheck: GatewayHealthCheck,
+		Manager:     clusters.NewManager(),
 	}
 	m.queue = syncqueue.NewPassthroughSyncQueue(proxyv1alpha1.SchemeGroupVersion.WithKind("UpstreamCluster"), m.syncUpstreamCluster)
 
@@ -62,11 +84,53 @@ func NewUpstreamClusterController(upstreamclusterinformer proxyinformers.Upstrea
 	return m
 }
 
+// EnableBlackboxProbing replaces the default healthz check with blackbox probing, it must be called before Run
+func (m *UpstreamClusterController) EnableBlackboxProbing(cfg BlackboxProbeConfig) {
+	m.healthCheck = NewBlackboxHealthCheck(cfg.Timeout)
+	if cfg.ReportInterval > 0 && cfg.Client != nil {
+		m.reporter = newReachabilityReporter(cfg, m.lister, m.Manager)
+	}
+}
+
+// EnableCanaryProbing sends canary requests through the proxy path to every cluster, it must be called before Run
+func (m *UpstreamClusterController) EnableCanaryProbing(cfg CanaryConfig) {
+	m.canary = newCanaryProber(cfg, m.Manager)
+}
+
+// EnableMaintenanceCordon cordons endpoints of machines under maintenance, it must be called before Run
+func (m *UpstreamClusterController) EnableMaintenanceCordon(cfg MaintenanceConfig) {
+	m.maintenance = newMaintenanceCordoner(cfg, m.Manager)
+}
+
+// EnableEndpointStatePersistence saves endpoint state to store every interval, it must be called before Run
+func (m *UpstreamClusterController) EnableEndpointStatePersistence(store *clusters.EndpointStateStore, interval time.Duration) {
+	m.stateStore = store
+	m.stateSaveInterval = interval
+}
+
+// SetListenerTLSPolicies restricts TLS parameters of proxy listeners by their ports, it must be called before
+// proxy server starts serving
+func (m *UpstreamClusterController) SetListenerTLSPolicies(policies map[int]*gatewaynet.TLSPolicy) {
+	m.listenerTLSPolicies = policies
+}
+
 func (m *UpstreamClusterController) Run(stopCh <-chan struct{}) {
 	klog.Info("starting upstream cluster controller")
 	if !cache.WaitForCacheSync(stopCh, m.synced) {
 		panic("failed to wait for upstream cluster synced")
 	}
+	if m.reporter != nil {
+		go m.reporter.Run(stopCh)
+	}
+	if m.canary != nil {
+		go m.canary.Run(stopCh)
+	}
+	if m.maintenance != nil {
+		go m.maintenance.Run(stopCh)
+	}
+	if m.stateStore != nil {
+		go m.stateStore.Run(m.Manager, m.stateSaveInterval, stopCh)
+	}
 
 	m.queue.Run(1)
 	defer func() {
@@ -93,10 +157,16 @@ func (m *UpstreamClusterController) syncUpstreamCluster(obj interface{}) (syncqu
 	}
 
 	info, ok := m.Get(clusterName)
+	if authMode := clusters.AuthModeOf(cluster.Annotations, m.defaults.AuthMode); ok && info.AuthMode() != authMode {
+		// transports of all endpoints depend on auth mode, rebuild the cluster
+		klog.Infof("auth mode of cluster %v changed from %v to %v, recreate it", cluster.Name, info.AuthMode(), authMode)
+		m.Delete(clusterName)
+		ok = false
+	}
 
 	if !ok {
 		// bootstrap
-		clusterInfo, err := clusters.CreateClusterInfo(cluster, GatewayHealthCheck)
+		clusterInfo, err := clusters.CreateClusterInfo(cluster, m.healthCheck, m.defaults)
 		if err != nil {
 			klog.Errorf("failed to create cluster: %v, err: %v", cluster.Name, err)
 			return syncqueue.Result{RequeueAfter: 5 * time.Second, MaxRequeueTimes: 3}, nil
@@ -123,34 +193,34 @@ func (m *UpstreamClusterController) WrapGetConfigForClient(getConfigFunc dynamic
 			return baseTLSConfig, err
 		}
 
+		localHost, localPort, err := net.SplitHostPort(clientHello.Conn.LocalAddr().String())
+		if err != nil {
+			klog.Errorf("faild to get hostname from clientHello's conn: %v", err)
+			return baseTLSConfig, nil
+		}
+		tlsConfigCopy := m.withListenerTLSPolicy(baseTLSConfig, localPort)
+
 		// if the client set SNI information, just use our "normal" SNI flow
 		// Get request host name from SNI information or inspect the requested IP
 		hostname := clientHello.ServerName
 		if len(hostname) == 0 {
 			// if the client didn't set SNI, then we need to inspect the requested IP so that we can choose
 			// a certificate from our list if we specifically handle that IP.  This can happen when an IP is specifically mapped by name.
-			var err error
-			hostname, _, err = net.SplitHostPort(clientHello.Conn.LocalAddr().String())
-			if err != nil {
-				klog.Errorf("faild to get hostname from clientHello's conn: %v", err)
-				return baseTLSConfig, nil
-			}
+			hostname = localHost
 		}
 
 		klog.V(5).Infof("get tls config for %q", hostname)
 
-		cluster, ok := m.Get(hostname)
+		cluster, ok := m.Resolve(hostname)
 		if !ok {
-			return baseTLSConfig, nil
+			return tlsConfigCopy, nil
 		}
 
 		tlsConfig, ok := cluster.LoadTLSConfig()
 		if !ok {
-			return baseTLSConfig, nil
+			return tlsConfigCopy, nil
 		}
 
-		tlsConfigCopy := baseTLSConfig.Clone()
-
 		if tlsConfig.ClientCAs != nil {
 			// Populate PeerCertificates in requests, but don't reject connections without certificates
 			// This allows certificates to be validated by authenticators, while still allowing other auth types
@@ -168,10 +238,34 @@ func (m *UpstreamClusterController) WrapGetConfigForClient(getConfigFunc dynamic
 	}
 }
 
+// withListenerTLSPolicy returns a copy of config restricted by tls policy of the listener, negotiated
+// parameters and protocols of handshakes using the copy are recorded in metrics.
+func (m *UpstreamClusterController) withListenerTLSPolicy(config *tls.Config, port string) *tls.Config {
+	if config == nil {
+		return nil
+	}
+	p, _ := strconv.Atoi(port)
+	policy := m.listenerTLSPolicies[p]
+	configCopy := config.Clone()
+	policy.ApplyTo(configCopy)
+	verify := configCopy.VerifyConnection
+	configCopy.VerifyConnection = func(state tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(state); err != nil {
+				return err
+			}
+		}
+		metrics.RecordTLSHandshake("listener", port, state)
+		metrics.RecordListenerConnection(port, state.NegotiatedProtocol)
+		return nil
+	}
+	return configCopy
+}
+
 func (m *UpstreamClusterController) SNIVerifyOptions(host string) (x509.VerifyOptions, bool) {
 	hostname := gatewaynet.HostWithoutPort(host)
 	empty := x509.VerifyOptions{}
-	cluster, ok := m.Get(hostname)
+	cluster, ok := m.Resolve(hostname)
 	if !ok {
 		return empty, false
 	}
@@ -183,13 +277,31 @@ func GatewayHealthCheck(e *clusters.EndpointInfo) (done bool) {
 	done = false
 
 	// TODO: use readyz if all kubernetes master version is greater than v1.16
-	result := e.Clientset().CoreV1().RESTClient().
-		Get().AbsPath("/healthz").Timeout(5 * time.Second).Do(context.TODO())
+	request := e.Clientset().CoreV1().RESTClient().
+		Get().AbsPath("/healthz").Timeout(5 * time.Second)
+	etcdAware := e.EtcdAwareReadiness()
+	if etcdAware {
+		// verbose output lists failed checks
+		request = request.Param("verbose", "true")
+	}
+	result := request.Do(context.TODO())
 	err := result.Error()
 
 	var reason, message string
 	statusCode := 0
 
+	if etcdAware && err != nil {
+		result.StatusCode(&statusCode)
+		body, _ := result.Raw()
+		if failed := clusters.FailedHealthChecks(body); statusCode == http.StatusInternalServerError && clusters.OnlyEtcdFailed(failed) {
+			// the endpoint still serves reads from watch cache, only writes are routed elsewhere
+			e.UpdateStatus(true, "", "")
+			e.SetEtcdHealthy(false, fmt.Sprintf("request %s/healthz, failed checks: %v", e.Endpoint, failed))
+			return done
+		}
+	}
+	e.SetEtcdHealthy(true, "")
+
 	if err != nil {
 		if os.IsTimeout(err) {
 			reason = "Timeout"
@@ -19,6 +19,7 @@ import (
 
 	gatewayinformers "github.com/kubewharf/kubegateway/pkg/client/informers"
 	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
 )
 
 // WantsGatewayResourceClientSet defines a function which sets external ClientSet for admission plugins that need it
@@ -33,20 +34,29 @@ type WantsGatewayResourceInformerFactory interface {
 	admission.InitializationValidator
 }
 
+// WantsClusterDefaults defines a function which sets the defaults of upstream clusters for admission plugins that need it
+type WantsClusterDefaults interface {
+	SetClusterDefaults(*clusters.ClusterDefaults)
+	admission.InitializationValidator
+}
+
 // New creates an instance of admission plugins initializer.
 func New(
 	gatewayclientset gatewayclientset.Interface,
 	gatewayinformers gatewayinformers.SharedInformerFactory,
+	clusterDefaults *clusters.ClusterDefaults,
 ) admission.PluginInitializer {
 	return pluginInitializer{
 		gatewayclientset: gatewayclientset,
 		gatewayinformers: gatewayinformers,
+		clusterDefaults:  clusterDefaults,
 	}
 }
 
 type pluginInitializer struct {
 	gatewayclientset gatewayclientset.Interface
 	gatewayinformers gatewayinformers.SharedInformerFactory
+	clusterDefaults  *clusters.ClusterDefaults
 }
 
 func (i pluginInitializer) Initialize(plugin admission.Interface) {
@@ -56,4 +66,7 @@ func (i pluginInitializer) Initialize(plugin admission.Interface) {
 	if wants, ok := plugin.(WantsGatewayResourceInformerFactory); ok {
 		wants.SetGatewayResourceInformerFactory(i.gatewayinformers)
 	}
+	if wants, ok := plugin.(WantsClusterDefaults); ok {
+		wants.SetClusterDefaults(i.clusterDefaults)
+	}
 }
@@ -0,0 +1,197 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/authenticatorfactory"
+	requestunion "k8s.io/apiserver/pkg/authentication/request/union"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+	authorizerunion "k8s.io/apiserver/pkg/authorization/union"
+	"k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// AdminDelegationOptions delegates authentication and authorization of admin requests of control plane
+// server, e.g. /debug/gateway/faults and /debug/gateway/state, to a Kubernetes cluster by TokenReview and
+// SubjectAccessReview, so admin operations are governed by RBAC of that cluster instead of static tokens.
+type AdminDelegationOptions struct {
+	AuthenticationKubeconfig string
+	AuthorizationKubeconfig  string
+	// Paths are prefixes of admin request paths which are delegated
+	Paths                      []string
+	AuthenticationCacheTTL     time.Duration
+	AuthorizationAllowCacheTTL time.Duration
+	AuthorizationDenyCacheTTL  time.Duration
+}
+
+func NewAdminDelegationOptions() *AdminDelegationOptions {
+	return &AdminDelegationOptions{
+		Paths:                      []string{"/debug/"},
+		AuthenticationCacheTTL:     10 * time.Second,
+		AuthorizationAllowCacheTTL: 10 * time.Second,
+		AuthorizationDenyCacheTTL:  10 * time.Second,
+	}
+}
+
+func (o *AdminDelegationOptions) Validate() []error {
+	if o == nil || (len(o.AuthenticationKubeconfig) == 0 && len(o.AuthorizationKubeconfig) == 0) {
+		return nil
+	}
+	errors := []error{}
+	if len(o.Paths) == 0 {
+		errors = append(errors, fmt.Errorf("--admin-delegation-paths must not be empty when admin requests are delegated"))
+	}
+	for _, p := range o.Paths {
+		if !strings.HasPrefix(p, "/") {
+			errors = append(errors, fmt.Errorf("--admin-delegation-paths %q must start with /", p))
+		}
+	}
+	if o.AuthenticationCacheTTL < 0 || o.AuthorizationAllowCacheTTL < 0 || o.AuthorizationDenyCacheTTL < 0 {
+		errors = append(errors, fmt.Errorf("cache ttl of admin delegation must not be negative"))
+	}
+	return errors
+}
+
+func (o *AdminDelegationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.AuthenticationKubeconfig, "admin-authentication-kubeconfig", o.AuthenticationKubeconfig, ""+
+		"The kubeconfig of a Kubernetes cluster which authenticates bearer tokens of admin requests by TokenReview. "+
+		"Empty means admin requests are authenticated like other requests of control plane.")
+	fs.StringVar(&o.AuthorizationKubeconfig, "admin-authorization-kubeconfig", o.AuthorizationKubeconfig, ""+
+		"The kubeconfig of a Kubernetes cluster which authorizes admin requests by SubjectAccessReview of "+
+		"non-resource URLs, e.g. verb=put path=/debug/gateway/faults. Users not allowed by the cluster are denied. "+
+		"Empty means admin requests are authorized like other requests of control plane.")
+	fs.StringSliceVar(&o.Paths, "admin-delegation-paths", o.Paths,
+		"The path prefixes of admin requests delegated to --admin-authentication-kubeconfig and --admin-authorization-kubeconfig.")
+	fs.DurationVar(&o.AuthenticationCacheTTL, "admin-authentication-cache-ttl", o.AuthenticationCacheTTL,
+		"The duration to cache responses from the delegated TokenReview.")
+	fs.DurationVar(&o.AuthorizationAllowCacheTTL, "admin-authorization-allow-cache-ttl", o.AuthorizationAllowCacheTTL,
+		"The duration to cache 'authorized' responses from the delegated SubjectAccessReview.")
+	fs.DurationVar(&o.AuthorizationDenyCacheTTL, "admin-authorization-deny-cache-ttl", o.AuthorizationDenyCacheTTL,
+		"The duration to cache 'unauthorized' responses from the delegated SubjectAccessReview.")
+}
+
+// ApplyTo puts the delegated authenticator and authorizer of admin requests in front of the existing
+// ones of server config, requests to other paths are not affected.
+func (o *AdminDelegationOptions) ApplyTo(c *server.Config) error {
+	if o == nil {
+		return nil
+	}
+	if len(o.AuthenticationKubeconfig) > 0 {
+		client, err := newDelegationClient(o.AuthenticationKubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to create admin authentication client: %v", err)
+		}
+		delegated, _, err := authenticatorfactory.DelegatingAuthenticatorConfig{
+			TokenAccessReviewClient: client.AuthenticationV1().TokenReviews(),
+			CacheTTL:                o.AuthenticationCacheTTL,
+			APIAudiences:            c.Authentication.APIAudiences,
+		}.New()
+		if err != nil {
+			return fmt.Errorf("failed to create admin authenticator: %v", err)
+		}
+		admin := &adminAuthenticator{paths: o.Paths, delegate: delegated}
+		if c.Authentication.Authenticator == nil {
+			c.Authentication.Authenticator = admin
+		} else {
+			c.Authentication.Authenticator = requestunion.New(admin, c.Authentication.Authenticator)
+		}
+	}
+	if len(o.AuthorizationKubeconfig) > 0 {
+		client, err := newDelegationClient(o.AuthorizationKubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to create admin authorization client: %v", err)
+		}
+		delegated, err := authorizerfactory.DelegatingAuthorizerConfig{
+			SubjectAccessReviewClient: client.AuthorizationV1().SubjectAccessReviews(),
+			AllowCacheTTL:             o.AuthorizationAllowCacheTTL,
+			DenyCacheTTL:              o.AuthorizationDenyCacheTTL,
+		}.New()
+		if err != nil {
+			return fmt.Errorf("failed to create admin authorizer: %v", err)
+		}
+		admin := &adminAuthorizer{paths: o.Paths, delegate: delegated}
+		if c.Authorization.Authorizer == nil {
+			c.Authorization.Authorizer = admin
+		} else {
+			c.Authorization.Authorizer = authorizerunion.New(admin, c.Authorization.Authorizer)
+		}
+	}
+	return nil
+}
+
+func newDelegationClient(kubeconfig string) (kubernetes.Interface, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+func isAdminPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminAuthenticator authenticates requests to admin paths by delegate, so tokens of the delegated
+// cluster are never accepted by other APIs of control plane.
+type adminAuthenticator struct {
+	paths    []string
+	delegate authenticator.Request
+}
+
+func (a *adminAuthenticator) AuthenticateRequest(req *http.Request) (*authenticator.Response, bool, error) {
+	if !isAdminPath(a.paths, req.URL.Path) {
+		return nil, false, nil
+	}
+	return a.delegate.AuthenticateRequest(req)
+}
+
+// adminAuthorizer authorizes requests to admin paths by delegate, requests not allowed by it are denied,
+// so they never fall through to other authorizers of control plane.
+type adminAuthorizer struct {
+	paths    []string
+	delegate authorizer.Authorizer
+}
+
+func (a *adminAuthorizer) Authorize(ctx context.Context, attr authorizer.Attributes) (authorizer.Decision, string, error) {
+	if attr.IsResourceRequest() || !isAdminPath(a.paths, attr.GetPath()) {
+		return authorizer.DecisionNoOpinion, "", nil
+	}
+	decision, reason, err := a.delegate.Authorize(ctx, attr)
+	if decision == authorizer.DecisionAllow {
+		return decision, reason, err
+	}
+	if len(reason) == 0 {
+		reason = "admin request is not allowed by the delegated authorization"
+	}
+	return authorizer.DecisionDeny, reason, err
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestAdminAuthenticator(t *testing.T) {
+	called := false
+	a := &adminAuthenticator{
+		paths: []string{"/debug/"},
+		delegate: authenticator.RequestFunc(func(*http.Request) (*authenticator.Response, bool, error) {
+			called = true
+			return &authenticator.Response{User: &user.DefaultInfo{Name: "admin"}}, true, nil
+		}),
+	}
+	if _, ok, _ := a.AuthenticateRequest(httptest.NewRequest("GET", "/apis/proxy.kubegateway.io/v1alpha1/upstreamclusters", nil)); ok || called {
+		t.Errorf("request to non-admin path is authenticated by delegate")
+	}
+	resp, ok, _ := a.AuthenticateRequest(httptest.NewRequest("PUT", "/debug/gateway/faults", nil))
+	if !ok || !called || resp.User.GetName() != "admin" {
+		t.Errorf("request to admin path is not authenticated by delegate")
+	}
+}
+
+func TestAdminAuthorizer(t *testing.T) {
+	delegate := authorizer.AuthorizerFunc(func(attr authorizer.Attributes) (authorizer.Decision, string, error) {
+		if attr.GetUser().GetName() == "admin" {
+			return authorizer.DecisionAllow, "", nil
+		}
+		return authorizer.DecisionNoOpinion, "", nil
+	})
+	a := &adminAuthorizer{paths: []string{"/debug/"}, delegate: delegate}
+
+	tests := []struct {
+		name string
+		attr authorizer.AttributesRecord
+		want authorizer.Decision
+	}{
+		{
+			name: "allowed admin",
+			attr: authorizer.AttributesRecord{User: &user.DefaultInfo{Name: "admin"}, Verb: "put", Path: "/debug/gateway/faults"},
+			want: authorizer.DecisionAllow,
+		},
+		{
+			name: "no opinion of delegate is denied",
+			attr: authorizer.AttributesRecord{User: &user.DefaultInfo{Name: "alice"}, Verb: "put", Path: "/debug/gateway/faults"},
+			want: authorizer.DecisionDeny,
+		},
+		{
+			name: "non-admin path",
+			attr: authorizer.AttributesRecord{User: &user.DefaultInfo{Name: "alice"}, Verb: "get", Path: "/healthz"},
+			want: authorizer.DecisionNoOpinion,
+		},
+		{
+			name: "resource request",
+			attr: authorizer.AttributesRecord{User: &user.DefaultInfo{Name: "alice"}, Verb: "get", Resource: "upstreamclusters", ResourceRequest: true, Path: "/debug/apis"},
+			want: authorizer.DecisionNoOpinion,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _, _ := a.Authorize(context.Background(), tt.attr); got != tt.want {
+				t.Errorf("Authorize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -35,6 +35,8 @@ type ControlPlaneOptions struct {
 	*apiserveroptions.RecommendedOptions
 
 	SecureServing *SecureServingOptions
+	TokenFile     *TokenFileOptions
+	Admin         *AdminDelegationOptions
 }
 
 // NewControlPlaneOptions return a new controle plane options
@@ -48,6 +50,8 @@ func NewControlPlaneOptions() *ControlPlaneOptions {
 	return &ControlPlaneOptions{
 		RecommendedOptions: recommended,
 		SecureServing:      NewSecureServingOptions(),
+		TokenFile:          NewTokenFileOptions(),
+		Admin:              NewAdminDelegationOptions(),
 	}
 }
 
@@ -56,6 +60,12 @@ func (o *ControlPlaneOptions) Flags() (fss cliflag.NamedFlagSets) {
 	if o.SecureServing != nil {
 		o.SecureServing.AddFlags(fss.FlagSet("secure serving"))
 	}
+	if o.TokenFile != nil {
+		o.TokenFile.AddFlags(fss.FlagSet("authentication"))
+	}
+	if o.Admin != nil {
+		o.Admin.AddFlags(fss.FlagSet("admin delegation"))
+	}
 	return fss
 }
 
@@ -122,7 +132,13 @@ func (o *ControlPlaneOptions) ApplyTo(
 			return err
 		}
 	}
-	return o.RecommendedOptions.ApplyTo(recommended, tweakLoopbackConfig, defaultResourceConfig, pluginInitializers...)
+	if err := o.RecommendedOptions.ApplyTo(recommended, tweakLoopbackConfig, defaultResourceConfig, pluginInitializers...); err != nil {
+		return err
+	}
+	if err := o.TokenFile.ApplyTo(&recommended.Config); err != nil {
+		return err
+	}
+	return o.Admin.ApplyTo(&recommended.Config)
 }
 
 func (o *ControlPlaneOptions) Validate() []error {
@@ -131,5 +147,7 @@ func (o *ControlPlaneOptions) Validate() []error {
 	if o.SecureServing != nil {
 		errors = append(errors, o.SecureServing.Validate()...)
 	}
+	errors = append(errors, o.TokenFile.Validate()...)
+	errors = append(errors, o.Admin.Validate()...)
 	return errors
 }
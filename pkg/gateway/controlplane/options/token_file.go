@@ -0,0 +1,113 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/request/bearertoken"
+	requestunion "k8s.io/apiserver/pkg/authentication/request/union"
+	tokenunion "k8s.io/apiserver/pkg/authentication/token/union"
+	"k8s.io/apiserver/pkg/server"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/authentication/token/tokenfile"
+)
+
+// TokenFileOptions contains file based token authentication for control plane server,
+// e.g. the admin and debug handlers, for environments where client certs are impractical.
+type TokenFileOptions struct {
+	StaticTokenFile    string
+	BootstrapTokenFile string
+}
+
+func NewTokenFileOptions() *TokenFileOptions {
+	return &TokenFileOptions{}
+}
+
+func (o *TokenFileOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errors := []error{}
+	if len(o.StaticTokenFile) > 0 && !filepath.IsAbs(o.StaticTokenFile) {
+		errors = append(errors, fmt.Errorf("--token-auth-file must be an absolute path"))
+	}
+	if len(o.BootstrapTokenFile) > 0 && !filepath.IsAbs(o.BootstrapTokenFile) {
+		errors = append(errors, fmt.Errorf("--bootstrap-token-auth-file must be an absolute path"))
+	}
+	return errors
+}
+
+func (o *TokenFileOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.StaticTokenFile, "token-auth-file", o.StaticTokenFile, ""+
+		"If set, the file that will be used to secure the control plane port via token authentication, "+
+		"in the same csv format as kube-apiserver: token,user,uid,\"group1,group2\". "+
+		"The file is reloaded when its content changes.")
+	fs.StringVar(&o.BootstrapTokenFile, "bootstrap-token-auth-file", o.BootstrapTokenFile, ""+
+		"If set, the csv file of bootstrap tokens that will be used to secure the control plane port: "+
+		"token,expiration,\"extra-group1,extra-group2\". The token authenticates as system:bootstrap:<token-id> "+
+		"in group system:bootstrappers, expiration is in RFC3339 and empty means never expires. "+
+		"The file is reloaded when its content changes.")
+}
+
+// ApplyTo creates the token file authenticators and puts them in front of the existing
+// authenticator of server config, files are reloaded after server started.
+func (o *TokenFileOptions) ApplyTo(c *server.Config) error {
+	if o == nil {
+		return nil
+	}
+	authenticators := []*tokenfile.Authenticator{}
+	if len(o.StaticTokenFile) > 0 {
+		a, err := tokenfile.NewStaticTokenAuthenticator(o.StaticTokenFile)
+		if err != nil {
+			return err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if len(o.BootstrapTokenFile) > 0 {
+		a, err := tokenfile.NewBootstrapTokenAuthenticator(o.BootstrapTokenFile)
+		if err != nil {
+			return err
+		}
+		authenticators = append(authenticators, a)
+	}
+	if len(authenticators) == 0 {
+		return nil
+	}
+
+	tokenAuthenticators := []authenticator.Token{}
+	for _, a := range authenticators {
+		tokenAuthenticators = append(tokenAuthenticators, a)
+	}
+	tokenAuth := bearertoken.New(authenticator.WrapAudienceAgnosticToken(c.Authentication.APIAudiences, tokenunion.New(tokenAuthenticators...)))
+	if c.Authentication.Authenticator == nil {
+		c.Authentication.Authenticator = tokenAuth
+	} else {
+		c.Authentication.Authenticator = requestunion.New(tokenAuth, c.Authentication.Authenticator)
+	}
+
+	return c.AddPostStartHook("kube-gateway-start-token-file-reloaders", func(context server.PostStartHookContext) error {
+		for _, a := range authenticators {
+			go a.Run(context.StopCh)
+		}
+		return nil
+	})
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/kubewharf/apiserver-runtime/pkg/server"
 	apiserver "github.com/kubewharf/apiserver-runtime/pkg/server"
 	corerestplugin "github.com/kubewharf/apiserver-runtime/plugin/registry/core/rest"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	genericapiserver "k8s.io/apiserver/pkg/server"
@@ -29,6 +30,7 @@ import (
 	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
 
 	// RESTStorage installers
+	coordinationrest "k8s.io/kubernetes/pkg/registry/coordination/rest"
 	rbacrest "k8s.io/kubernetes/pkg/registry/rbac/rest"
 
 	proxyrest "github.com/kubewharf/kubegateway/pkg/gateway/controlplane/registry/proxy/rest"
@@ -82,11 +84,13 @@ func (c *CompletedConfig) New(delegationTarget genericapiserver.DelegationTarget
 	}
 
 	// Install Legacy APIs
-	// we only need namespace, secret, serviceaccount and rbac in control plane registry
+	// we only need namespace, secret, serviceaccount, event and rbac in control plane registry
 	legacyRESTStorageProviders := []server.LegecyRESTStorageProvider{
 		corerestplugin.NamepsaceLegacyRESTStorageProvider{},
 		corerestplugin.SecretLegacyRESTStorageProvider{},
 		corerestplugin.ServiceAccountLegacyRESTStorageProvider{},
+		// events reporting abusive clients of proxy
+		corerestplugin.EventLegacyRESTStorageProvider{},
 	}
 	if err := apiserver.InstallLegacyAPI(s, c.GenericConfig.MergedResourceConfig, c.GenericConfig.RESTOptionsGetter, legacyRESTStorageProviders...); err != nil {
 		return nil, err
@@ -95,6 +99,8 @@ func (c *CompletedConfig) New(delegationTarget genericapiserver.DelegationTarget
 	restStorageProviders := []master.RESTStorageProvider{
 		// Install Other group APIs
 		rbacrest.RESTStorageProvider{Authorizer: c.GenericConfig.Authorization.Authorizer},
+		// leases shared by proxy replicas for coordination
+		coordinationrest.RESTStorageProvider{},
 		// Install Gateway APIs
 		proxyrest.NewRESTStorageProviderOrDie(c.GenericConfig.Scheme, c.GenericConfig.RESTStorageOptionsFactory),
 	}
@@ -114,6 +120,7 @@ func DefaultAPIResourceConfigSource() *serverstorage.ResourceConfig {
 		// enable some native apis
 		corev1.SchemeGroupVersion,
 		rbacv1.SchemeGroupVersion,
+		coordinationv1.SchemeGroupVersion,
 		// add gateway apis here
 		proxyv1alpha1.SchemeGroupVersion,
 	)
@@ -0,0 +1,271 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package coordination shares lightweight state between gateway replicas through one Lease object
+// in the gateway control plane, so replicas can split quota or agree on canary steps without an
+// external store. Each replica only writes its own annotation of the Lease, concurrent writes from
+// different replicas never overwrite each other's state and are merged by retrying on conflicts.
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// ReplicaAnnotationPrefix prefixes annotations of the shared Lease, each live replica owns
+// the annotation ReplicaAnnotationPrefix+<replica> whose value is its ReplicaState in json.
+const ReplicaAnnotationPrefix = "coordination.kubegateway.io/"
+
+// ReplicaState is the state one replica publishes to other replicas
+type ReplicaState struct {
+	// RenewTime is the last time the replica published its state, replicas not renewing
+	// their state within TTL are considered gone
+	RenewTime metav1.Time `json:"renewTime"`
+	// Values are published by components of the replica, keyed by component, e.g. canary
+	Values map[string]string `json:"values,omitempty"`
+}
+
+type Config struct {
+	// Client is the client of gateway control plane where the Lease is stored
+	Client kubernetes.Interface
+	// Namespace and Name identify the shared Lease
+	Namespace string
+	Name      string
+	// Replica is the name of this replica, it must be unique among replicas sharing the Lease
+	Replica string
+	// Interval is the interval to publish the state of this replica and observe others
+	Interval time.Duration
+	// TTL is the duration after which a replica not renewing its state is considered gone
+	TTL time.Duration
+}
+
+// Coordinator publishes the state of this replica and observes states of other replicas
+type Coordinator struct {
+	cfg   Config
+	clock clock.Clock
+
+	mu     sync.RWMutex
+	values map[string]string
+	// handlers are called after each successful sync
+	handlers []func()
+	// replicas observed by the last successful sync, excluding this replica
+	replicas map[string]ReplicaState
+}
+
+func NewCoordinator(cfg Config) *Coordinator {
+	return &Coordinator{
+		cfg:      cfg,
+		clock:    clock.RealClock{},
+		values:   map[string]string{},
+		replicas: map[string]ReplicaState{},
+	}
+}
+
+// Replica returns the name of this replica
+func (c *Coordinator) Replica() string {
+	return c.cfg.Replica
+}
+
+// Set sets the value of key published by this replica from the next sync, empty value removes the key
+func (c *Coordinator) Set(key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(value) == 0 {
+		delete(c.values, key)
+		return
+	}
+	c.values[key] = value
+}
+
+// Replicas returns names of all live replicas sorted by name, including this replica
+func (c *Coordinator) Replicas() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	names := []string{c.cfg.Replica}
+	for name, state := range c.replicas {
+		if c.alive(state, now) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Values returns values of key published by all live replicas, keyed by replica
+func (c *Coordinator) Values(key string) map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock.Now()
+	values := map[string]string{}
+	for name, state := range c.replicas {
+		if v, ok := state.Values[key]; ok && c.alive(state, now) {
+			values[name] = v
+		}
+	}
+	if v, ok := c.values[key]; ok {
+		values[c.cfg.Replica] = v
+	}
+	return values
+}
+
+// Share returns the part of total this replica is responsible for, total is split evenly among
+// live replicas and the remainder goes to replicas sorted first, so shares of all replicas sum to total.
+func (c *Coordinator) Share(total int64) int64 {
+	replicas := c.Replicas()
+	n := int64(len(replicas))
+	share := total / n
+	index := int64(sort.SearchStrings(replicas, c.cfg.Replica))
+	if index < total%n {
+		share++
+	}
+	return share
+}
+
+// AddSyncHandler adds handler called after each successful sync, e.g. to recompute shares
+// once replicas join or leave. It must be called before Run.
+func (c *Coordinator) AddSyncHandler(handler func()) {
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *Coordinator) Run(stopCh <-chan struct{}) {
+	klog.Infof("[coordination] start publishing state of replica %q to lease %s/%s every %v", c.cfg.Replica, c.cfg.Namespace, c.cfg.Name, c.cfg.Interval)
+	wait.Until(func() {
+		if err := c.sync(); err != nil {
+			metrics.RecordCoordinationSync(false, len(c.Replicas()))
+			klog.Errorf("[coordination] failed to sync lease %s/%s: %v", c.cfg.Namespace, c.cfg.Name, err)
+			return
+		}
+		metrics.RecordCoordinationSync(true, len(c.Replicas()))
+		for _, handler := range c.handlers {
+			handler()
+		}
+	}, c.cfg.Interval, stopCh)
+}
+
+// sync publishes the state of this replica to the shared Lease and observes states of other replicas.
+// The Lease is reread and merged again on conflicts, only the annotation of this replica and those
+// of expired replicas are changed, so concurrent syncs of other replicas are never lost.
+func (c *Coordinator) sync() error {
+	leases := c.cfg.Client.CoordinationV1().Leases(c.cfg.Namespace)
+	retriable := func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}
+	return retry.OnError(retry.DefaultBackoff, retriable, func() error {
+		lease, err := leases.Get(context.TODO(), c.cfg.Name, metav1.GetOptions{})
+		notFound := apierrors.IsNotFound(err)
+		if notFound {
+			lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: c.cfg.Namespace, Name: c.cfg.Name}}
+		} else if err != nil {
+			return err
+		}
+		merged, observed, err := c.merge(lease)
+		if err != nil {
+			return err
+		}
+		if notFound {
+			_, err = leases.Create(context.TODO(), merged, metav1.CreateOptions{})
+			if apierrors.IsNotFound(err) {
+				// namespace does not exist in control plane, retry after it is created
+				err = c.ensureNamespace()
+			}
+		} else {
+			_, err = leases.Update(context.TODO(), merged, metav1.UpdateOptions{})
+		}
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.replicas = observed
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+// merge returns a copy of lease with renewed state of this replica and without expired replicas,
+// and states of other live replicas in lease.
+func (c *Coordinator) merge(lease *coordinationv1.Lease) (*coordinationv1.Lease, map[string]ReplicaState, error) {
+	now := c.clock.Now()
+	merged := lease.DeepCopy()
+	if merged.Annotations == nil {
+		merged.Annotations = map[string]string{}
+	}
+	observed := map[string]ReplicaState{}
+	for key, value := range merged.Annotations {
+		if !strings.HasPrefix(key, ReplicaAnnotationPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, ReplicaAnnotationPrefix)
+		if name == c.cfg.Replica {
+			continue
+		}
+		state := ReplicaState{}
+		if err := json.Unmarshal([]byte(value), &state); err != nil || !c.alive(state, now) {
+			delete(merged.Annotations, key)
+			continue
+		}
+		observed[name] = state
+	}
+
+	c.mu.RLock()
+	state := ReplicaState{RenewTime: metav1.NewTime(now), Values: make(map[string]string, len(c.values))}
+	for k, v := range c.values {
+		state.Values[k] = v
+	}
+	c.mu.RUnlock()
+	value, err := json.Marshal(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged.Annotations[ReplicaAnnotationPrefix+c.cfg.Replica] = string(value)
+
+	// HolderIdentity is left unset, the Lease is shared by all replicas and is never held by
+	// one of them, tools treating it as a leader election lock must not see a leader
+	renewTime := metav1.NewMicroTime(now)
+	ttl := int32(c.cfg.TTL / time.Second)
+	merged.Spec.LeaseDurationSeconds = &ttl
+	merged.Spec.RenewTime = &renewTime
+	return merged, observed, nil
+}
+
+func (c *Coordinator) alive(state ReplicaState, now time.Time) bool {
+	return now.Sub(state.RenewTime.Time) <= c.cfg.TTL
+}
+
+func (c *Coordinator) ensureNamespace() error {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: c.cfg.Namespace}}
+	_, err := c.cfg.Client.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %q of lease: %v", c.cfg.Namespace, err)
+	}
+	// retry creating the lease
+	return apierrors.NewConflict(coordinationv1.Resource("leases"), c.cfg.Name, fmt.Errorf("namespace %q is just created", c.cfg.Namespace))
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package coordination
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTestCoordinator(client *fake.Clientset, clock clock.Clock, replica string) *Coordinator {
+	c := NewCoordinator(Config{
+		Client:    client,
+		Namespace: "kube-system",
+		Name:      "kube-gateway",
+		Replica:   replica,
+		Interval:  10 * time.Second,
+		TTL:       30 * time.Second,
+	})
+	c.clock = clock
+	return c
+}
+
+func TestCoordinator_Sync(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeClock := clock.NewFakeClock(time.Now())
+	a := newTestCoordinator(client, fakeClock, "a")
+	b := newTestCoordinator(client, fakeClock, "b")
+	c := newTestCoordinator(client, fakeClock, "c")
+
+	a.Set("canary", "step-1")
+	b.Set("canary", "step-2")
+	for _, coordinator := range []*Coordinator{a, b, c, a} {
+		if err := coordinator.sync(); err != nil {
+			t.Fatalf("sync() error = %v", err)
+		}
+	}
+
+	if got, want := a.Replicas(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replicas() = %v, want %v", got, want)
+	}
+	if got, want := a.Values("canary"), map[string]string{"a": "step-1", "b": "step-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+	total := int64(0)
+	for _, coordinator := range []*Coordinator{a, b, c} {
+		// b and c only observed replicas synced before them
+		if err := coordinator.sync(); err != nil {
+			t.Fatalf("sync() error = %v", err)
+		}
+	}
+	for _, coordinator := range []*Coordinator{a, b, c} {
+		total += coordinator.Share(100)
+	}
+	if total != 100 {
+		t.Errorf("sum of Share(100) = %v, want 100", total)
+	}
+	if got := a.Share(100); got != 34 {
+		t.Errorf("Share(100) of a = %v, want 34", got)
+	}
+
+	// b and c stop renewing
+	fakeClock.Step(31 * time.Second)
+	if got, want := a.Replicas(), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replicas() after ttl = %v, want %v", got, want)
+	}
+	if err := a.sync(); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	lease, err := client.CoordinationV1().Leases("kube-system").Get(context.TODO(), "kube-gateway", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get lease: %v", err)
+	}
+	if lease.Spec.HolderIdentity != nil {
+		t.Errorf("shared lease is held by %q", *lease.Spec.HolderIdentity)
+	}
+	if _, ok := lease.Annotations[ReplicaAnnotationPrefix+"b"]; ok {
+		t.Errorf("expired replica b is not pruned from lease annotations %v", lease.Annotations)
+	}
+	if got := a.Share(100); got != 100 {
+		t.Errorf("Share(100) of the only replica = %v, want 100", got)
+	}
+}
+
+func TestCoordinator_SyncConflict(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	fakeClock := clock.NewFakeClock(time.Now())
+	a := newTestCoordinator(client, fakeClock, "a")
+	b := newTestCoordinator(client, fakeClock, "b")
+	if err := a.sync(); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	// a concurrent sync of replica c lands between get and update of b
+	conflicted := false
+	client.PrependReactor("update", "leases", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		obj, err := client.Tracker().Get(coordinationv1.SchemeGroupVersion.WithResource("leases"), "kube-system", "kube-gateway")
+		if err != nil {
+			t.Fatalf("failed to get lease: %v", err)
+		}
+		lease := obj.(*coordinationv1.Lease)
+		state, _ := json.Marshal(ReplicaState{RenewTime: metav1.NewTime(fakeClock.Now())})
+		lease.Annotations[ReplicaAnnotationPrefix+"c"] = string(state)
+		if err := client.Tracker().Update(coordinationv1.SchemeGroupVersion.WithResource("leases"), lease, "kube-system"); err != nil {
+			t.Fatalf("failed to update lease: %v", err)
+		}
+		return true, nil, apierrors.NewConflict(coordinationv1.Resource("leases"), "kube-gateway", nil)
+	})
+	if err := b.sync(); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if got, want := b.Replicas(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Replicas() after conflict = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/mux"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	AutoProfilesPath = "/debug/gateway/autoprofiles"
+
+	// DefaultAutoProfileDuration is the default duration of each triggered CPU profile
+	DefaultAutoProfileDuration = 5 * time.Second
+	// DefaultAutoProfileCooldown is the default minimum interval between two triggered CPU profiles
+	DefaultAutoProfileCooldown = 5 * time.Minute
+	// DefaultMaxAutoProfiles is the default number of recent triggered CPU profiles kept in memory
+	DefaultMaxAutoProfiles = 10
+)
+
+// DefaultAutoProfiler observes self latency of all proxied requests, it is disabled by default.
+// The admin API serves profiles of the instance it is installed with, so AutoProfileOptions
+// replaces it before InstallAutoProfiles.
+var DefaultAutoProfiler = NewAutoProfiler(0, DefaultAutoProfileDuration, DefaultAutoProfileCooldown, DefaultMaxAutoProfiles)
+
+// AutoProfiler captures a short CPU profile when the self latency of a proxied request, i.e. the
+// time spent by gateway before trying the upstream endpoint, exceeds Threshold. At most one profile
+// is captured at a time, and the most recent of them are kept in a bounded ring buffer which
+// can be retrieved by admin API AutoProfilesPath. It catches transient scheduler or GC issues
+// which have gone when someone starts profiling by hand.
+type AutoProfiler struct {
+	// Threshold is the self latency triggering a profile, zero means disabled
+	Threshold time.Duration
+	// Duration is how long each profile lasts
+	Duration time.Duration
+	// Cooldown is the minimum interval between the starts of two profiles
+	Cooldown time.Duration
+
+	running   int32
+	lastStart int64
+
+	mu       sync.Mutex
+	profiles []*AutoProfile
+	next     int
+	full     bool
+	nextID   int
+}
+
+// AutoProfile is a triggered CPU profile
+type AutoProfile struct {
+	ID       int           `json:"id"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	// Latency is the self latency of the request which triggers the profile
+	Latency time.Duration `json:"latency"`
+	Method  string        `json:"method"`
+	Host    string        `json:"host"`
+	URI     string        `json:"uri"`
+	Size    int           `json:"size"`
+
+	data []byte
+}
+
+// NewAutoProfiler creates a profiler keeping at most maxProfiles recent profiles, zero threshold
+// or maxProfiles means disabled.
+func NewAutoProfiler(threshold, duration, cooldown time.Duration, maxProfiles int) *AutoProfiler {
+	if maxProfiles < 0 {
+		maxProfiles = 0
+	}
+	return &AutoProfiler{
+		Threshold: threshold,
+		Duration:  duration,
+		Cooldown:  cooldown,
+		profiles:  make([]*AutoProfile, maxProfiles),
+	}
+}
+
+// Enabled returns true if profiles may be triggered
+func (p *AutoProfiler) Enabled() bool {
+	return p != nil && p.Threshold > 0 && p.Duration > 0 && len(p.profiles) > 0
+}
+
+// Observe checks the self latency of req and starts a CPU profile in background if it exceeds
+// the threshold, it is cheap enough to be called for every proxied request.
+func (p *AutoProfiler) Observe(req *http.Request, latency time.Duration) {
+	if !p.Enabled() || latency < p.Threshold {
+		return
+	}
+	now := time.Now()
+	if last := atomic.LoadInt64(&p.lastStart); last > 0 && now.Sub(time.Unix(0, last)) < p.Cooldown {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		return
+	}
+	atomic.StoreInt64(&p.lastStart, now.UnixNano())
+	profile := &AutoProfile{
+		Time:     now,
+		Duration: p.Duration,
+		Latency:  latency,
+		Method:   req.Method,
+		Host:     req.Host,
+		URI:      redact.URI(req.RequestURI),
+	}
+	go p.capture(profile)
+}
+
+func (p *AutoProfiler) capture(profile *AutoProfile) {
+	defer atomic.StoreInt32(&p.running, 0)
+
+	buf := &bytes.Buffer{}
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		// someone is profiling by hand, e.g. /debug/pprof/profile
+		klog.Warningf("[debug] failed to start cpu profile triggered by self latency %v of %s %s: %v", profile.Latency, profile.Method, profile.URI, err)
+		metrics.RecordAutoProfile(false)
+		return
+	}
+	time.Sleep(profile.Duration)
+	pprof.StopCPUProfile()
+
+	profile.data = buf.Bytes()
+	profile.Size = len(profile.data)
+	metrics.RecordAutoProfile(true)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.nextID++
+	profile.ID = p.nextID
+	p.profiles[p.next] = profile
+	p.next = (p.next + 1) % len(p.profiles)
+	if p.next == 0 {
+		p.full = true
+	}
+	klog.Infof("[debug] captured cpu profile %d triggered by self latency %v of %s %s", profile.ID, profile.Latency, profile.Method, profile.URI)
+}
+
+// Recent returns kept profiles from the newest to the oldest
+func (p *AutoProfiler) Recent() []*AutoProfile {
+	result := []*AutoProfile{}
+	if p == nil || len(p.profiles) == 0 {
+		return result
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.next
+	if p.full {
+		n = len(p.profiles)
+	}
+	for i := 1; i <= n; i++ {
+		result = append(result, p.profiles[(p.next-i+len(p.profiles))%len(p.profiles)])
+	}
+	return result
+}
+
+// Get returns the kept profile by id
+func (p *AutoProfiler) Get(id int) (*AutoProfile, bool) {
+	for _, profile := range p.Recent() {
+		if profile.ID == id {
+			return profile, true
+		}
+	}
+	return nil, false
+}
+
+// InstallAutoProfiles adds the handler which lists recent profiles triggered by self latency,
+// GET AutoProfilesPath?id=N downloads the profile which can be analyzed by go tool pprof.
+func InstallAutoProfiles(c *mux.PathRecorderMux, profiler *AutoProfiler) {
+	c.Handle(AutoProfilesPath, &autoProfiles{profiler: profiler})
+}
+
+type autoProfiles struct {
+	profiler *AutoProfiler
+}
+
+func (p *autoProfiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	id := r.URL.Query().Get("id")
+	if len(id) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p.profiler.Recent()); err != nil {
+			klog.Errorf("[debug] failed to write recent auto profiles: %v", err)
+		}
+		return
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid id %q: %v", id, err), http.StatusBadRequest)
+		return
+	}
+	profile, ok := p.profiler.Get(n)
+	if !ok {
+		http.Error(w, fmt.Sprintf("profile %d not found", n), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="cpu-%d.pprof"`, profile.ID))
+	w.Write(profile.data)
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitAutoProfileDone(t *testing.T, p *AutoProfiler) {
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&p.running) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("auto profile is not done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAutoProfiler_Observe(t *testing.T) {
+	p := NewAutoProfiler(100*time.Millisecond, 50*time.Millisecond, time.Hour, 2)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+
+	p.Observe(req, 10*time.Millisecond)
+	if atomic.LoadInt32(&p.running) != 0 {
+		t.Fatalf("profile is triggered by latency below threshold")
+	}
+
+	p.Observe(req, 200*time.Millisecond)
+	waitAutoProfileDone(t, p)
+	got := p.Recent()
+	if len(got) != 1 || got[0].ID != 1 || got[0].Latency != 200*time.Millisecond || got[0].Size == 0 {
+		t.Fatalf("Recent() = %+v, want one captured profile", got)
+	}
+
+	// in cooldown
+	p.Observe(req, time.Second)
+	waitAutoProfileDone(t, p)
+	if got := p.Recent(); len(got) != 1 {
+		t.Fatalf("Recent() = %+v, want no profile triggered in cooldown", got)
+	}
+
+	w := httptest.NewRecorder()
+	(&autoProfiles{profiler: p}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, AutoProfilesPath+"?id=1", nil))
+	if w.Code != http.StatusOK || w.Body.Len() != got[0].Size {
+		t.Errorf("download profile got status %d and %d bytes, want %d bytes", w.Code, w.Body.Len(), got[0].Size)
+	}
+	w = httptest.NewRecorder()
+	(&autoProfiles{profiler: p}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, AutoProfilesPath+"?id=2", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("download unknown profile got status %d, want 404", w.Code)
+	}
+}
+
+func TestAutoProfiler_Disabled(t *testing.T) {
+	p := NewAutoProfiler(0, 50*time.Millisecond, 0, 2)
+	p.Observe(httptest.NewRequest(http.MethodGet, "/api", nil), time.Hour)
+	if atomic.LoadInt32(&p.running) != 0 {
+		t.Fatalf("profile is triggered by disabled profiler")
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug provides on-demand debugging handlers for the admin port, the handlers are
+// installed on control plane server, so requests are authenticated and authorized by it.
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/mux"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
+	"github.com/kubewharf/kubegateway/pkg/gateway/tunables"
+)
+
+const (
+	GoroutinesPath = "/debug/gateway/goroutines"
+	TracePath      = "/debug/gateway/trace"
+	EndpointsPath  = "/debug/gateway/endpoints"
+	FaultsPath     = "/debug/gateway/faults"
+	TunablesPath   = "/debug/gateway/tunables"
+	RejectionsPath = "/debug/gateway/rejections"
+	StatePath      = "/debug/gateway/state"
+	BatchPath      = "/debug/gateway/endpoints/batch"
+
+	// defaultSoftStateMaxAge bounds the age of imported soft state, stale balances and scores
+	// would mislead the replica more than starting cold.
+	defaultSoftStateMaxAge = 10 * time.Minute
+	maxSoftStateBytes      = 32 << 20
+
+	defaultTraceDuration = 5 * time.Second
+	maxTraceDuration     = 60 * time.Second
+)
+
+// Install adds the debugging handlers to the mux, pprof handlers are installed by
+// generic apiserver if profiling is enabled.
+func Install(c *mux.PathRecorderMux) {
+	c.HandleFunc(GoroutinesPath, Goroutines)
+	c.Handle(TracePath, &flightRecorder{})
+}
+
+// InstallEndpointScores adds the handler which shows endpoint scores of all upstream clusters,
+// they are not profiling data, so it is installed whether profiling is enabled or not.
+func InstallEndpointScores(c *mux.PathRecorderMux, manager clusters.Manager) {
+	c.Handle(EndpointsPath, &endpointScores{manager: manager})
+}
+
+// InstallFaultInjection adds the handler which manages fault injection of upstream clusters,
+// it should be installed only if feature gate FaultInjection is enabled.
+func InstallFaultInjection(c *mux.PathRecorderMux, manager clusters.Manager) {
+	c.Handle(FaultsPath, &faultInjection{manager: manager})
+}
+
+// InstallSoftState adds the handler which exports and imports live soft state of upstream clusters,
+// e.g. endpoint scores and rate limiter balances, for blue/green cutover of gateway replicas.
+func InstallSoftState(c *mux.PathRecorderMux, manager clusters.Manager) {
+	c.Handle(StatePath, &softState{manager: manager})
+}
+
+// InstallEndpointBatch adds the handler which cordons or uncordons endpoints of upstream clusters in batch,
+// e.g. all endpoints on machines of one zone under maintenance, see clusters.EndpointBatch.
+func InstallEndpointBatch(c *mux.PathRecorderMux, manager clusters.Manager) {
+	c.Handle(BatchPath, &endpointBatch{manager: manager})
+}
+
+// InstallTunables adds the handler which shows and changes runtime tunables live
+func InstallTunables(c *mux.PathRecorderMux) {
+	c.Handle(TunablesPath, &runtimeTunables{})
+}
+
+// InstallRejections adds the handler which lists codes of all gateway rejections
+func InstallRejections(c *mux.PathRecorderMux) {
+	c.HandleFunc(RejectionsPath, Rejections)
+}
+
+// Goroutines writes the stack traces of all current goroutines in text format
+func Goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := pprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		klog.Errorf("[debug] failed to dump goroutines: %v", err)
+	}
+}
+
+// Rejections writes all registered rejection codes in JSON
+func Rejections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := json.NewEncoder(w).Encode(rejection.Known()); err != nil {
+		klog.Errorf("[debug] failed to write rejections: %v", err)
+	}
+}
+
+// flightRecorder captures runtime execution trace for a while and streams it back,
+// only one trace can be captured at the same time.
+type flightRecorder struct {
+	running int32
+}
+
+func (f *flightRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	duration, err := parseTraceDuration(r.URL.Query().Get("seconds"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&f.running, 0, 1) {
+		http.Error(w, "another trace is being captured, try again later", http.StatusConflict)
+		return
+	}
+	defer atomic.StoreInt32(&f.running, 0)
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+	if err := trace.Start(w); err != nil {
+		// tracing may be enabled by pprof handler
+		w.Header().Del("Content-Disposition")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.Error(w, fmt.Sprintf("could not enable tracing: %v", err), http.StatusConflict)
+		return
+	}
+	klog.Infof("[debug] start capturing execution trace for %v, remote=%v", duration, r.RemoteAddr)
+
+	select {
+	case <-time.After(duration):
+	case <-r.Context().Done():
+	}
+	trace.Stop()
+	klog.Infof("[debug] stop capturing execution trace, remote=%v", r.RemoteAddr)
+}
+
+func parseTraceDuration(seconds string) (time.Duration, error) {
+	if len(seconds) == 0 {
+		return defaultTraceDuration, nil
+	}
+	s, err := strconv.ParseFloat(seconds, 64)
+	if err != nil || s <= 0 {
+		return 0, fmt.Errorf("invalid seconds %q, must be a positive number", seconds)
+	}
+	d := time.Duration(s * float64(time.Second))
+	if d > maxTraceDuration {
+		return 0, fmt.Errorf("seconds %q exceeds the maximum %v", seconds, maxTraceDuration)
+	}
+	return d, nil
+}
+
+// endpointScores writes scores and scoring inputs of endpoints in JSON, query parameter
+// cluster limits the output to one cluster.
+type endpointScores struct {
+	manager clusters.Manager
+}
+
+type clusterEndpointScores struct {
+	Cluster   string                   `json:"cluster"`
+	Endpoints []clusters.EndpointScore `json:"endpoints"`
+}
+
+func (e *endpointScores) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var infos []*clusters.ClusterInfo
+	if name := r.URL.Query().Get("cluster"); len(name) > 0 {
+		info, ok := e.manager.Get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("cluster %q not found", name), http.StatusNotFound)
+			return
+		}
+		infos = append(infos, info)
+	} else {
+		infos = e.manager.List()
+	}
+
+	result := make([]clusterEndpointScores, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, clusterEndpointScores{Cluster: info.Cluster, Endpoints: info.EndpointScores()})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Cluster < result[j].Cluster
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("[debug] failed to write endpoint scores: %v", err)
+	}
+}
+
+// faultInjection manages fault injection of upstream clusters. GET lists current fault injections,
+// query parameter cluster limits the output to one cluster. PUT sets fault injection of cluster from
+// a JSON body, query parameter ttl (e.g. 10m) expires it. DELETE disables fault injection of cluster.
+type faultInjection struct {
+	manager clusters.Manager
+}
+
+type clusterFaultInjection struct {
+	Cluster string                   `json:"cluster"`
+	Fault   *clusters.FaultInjection `json:"fault"`
+}
+
+func (f *faultInjection) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("cluster")
+	var info *clusters.ClusterInfo
+	if len(name) > 0 {
+		var ok bool
+		info, ok = f.manager.Get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("cluster %q not found", name), http.StatusNotFound)
+			return
+		}
+	} else if r.Method != http.MethodGet {
+		http.Error(w, "query parameter cluster is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		infos := []*clusters.ClusterInfo{info}
+		if info == nil {
+			infos = f.manager.List()
+		}
+		result := []clusterFaultInjection{}
+		for _, info := range infos {
+			if fault := info.FaultInjection(); fault != nil {
+				result = append(result, clusterFaultInjection{Cluster: info.Cluster, Fault: fault})
+			}
+		}
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].Cluster < result[j].Cluster
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			klog.Errorf("[debug] failed to write fault injections: %v", err)
+		}
+	case http.MethodPut, http.MethodPost:
+		fault := &clusters.FaultInjection{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(fault); err != nil {
+			http.Error(w, fmt.Sprintf("invalid fault injection: %v", err), http.StatusBadRequest)
+			return
+		}
+		if ttl := r.URL.Query().Get("ttl"); len(ttl) > 0 {
+			d, err := time.ParseDuration(ttl)
+			if err != nil || d <= 0 {
+				http.Error(w, fmt.Sprintf("invalid ttl %q, must be a positive duration", ttl), http.StatusBadRequest)
+				return
+			}
+			fault.ExpireTime = time.Now().Add(d)
+		}
+		if err := fault.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("invalid fault injection: %v", err), http.StatusBadRequest)
+			return
+		}
+		klog.Warningf("[debug] set fault injection of cluster %q, remote=%v", info.Cluster, r.RemoteAddr)
+		info.SetFaultInjection(fault)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		klog.Infof("[debug] delete fault injection of cluster %q, remote=%v", info.Cluster, r.RemoteAddr)
+		info.SetFaultInjection(nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// softState exports and imports soft state. GET exports the state of this replica, PUT imports
+// the state exported by another replica, query parameter maxAge rejects state exported too long ago.
+type softState struct {
+	manager clusters.Manager
+}
+
+func (s *softState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := json.NewEncoder(w).Encode(clusters.ExportSoftState(s.manager)); err != nil {
+			klog.Errorf("[debug] failed to write soft state: %v", err)
+		}
+	case http.MethodPut, http.MethodPost:
+		maxAge := defaultSoftStateMaxAge
+		if value := r.URL.Query().Get("maxAge"); len(value) > 0 {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				http.Error(w, fmt.Sprintf("invalid maxAge %q, must be a positive duration", value), http.StatusBadRequest)
+				return
+			}
+			maxAge = d
+		}
+		state := clusters.SoftState{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxSoftStateBytes)).Decode(&state); err != nil {
+			http.Error(w, fmt.Sprintf("invalid soft state: %v", err), http.StatusBadRequest)
+			return
+		}
+		klog.Warningf("[debug] import soft state exported at %s, remote=%v", state.ExportTime.Format(time.RFC3339), r.RemoteAddr)
+		result, err := clusters.ImportSoftState(s.manager, state, maxAge)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid soft state: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			klog.Errorf("[debug] failed to write soft state import result: %v", err)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// endpointBatch applies batch operations to endpoints. PUT applies the operation from a JSON body and
+// responds the selected endpoints. It is refused with 409 if preconditions are violated, e.g. the last
+// ready endpoints of a cluster would be cordoned, query parameter force=true applies it anyway.
+// Query parameter dryRun=true shows the result without changing anything.
+type endpointBatch struct {
+	manager clusters.Manager
+}
+
+func (b *endpointBatch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	force, dryRun := false, false
+	for name, value := range map[string]*bool{"force": &force, "dryRun": &dryRun} {
+		if s := r.URL.Query().Get(name); len(s) > 0 {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid %s %q, must be a boolean", name, s), http.StatusBadRequest)
+				return
+			}
+			*value = v
+		}
+	}
+	batch := clusters.EndpointBatch{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&batch); err != nil {
+		http.Error(w, fmt.Sprintf("invalid endpoint batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := batch.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid endpoint batch: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	klog.Warningf("[debug] apply endpoint batch %+v, force=%v dryRun=%v remote=%v", batch, force, dryRun, r.RemoteAddr)
+	result, err := clusters.ApplyEndpointBatch(b.manager, batch, force, dryRun)
+	code := http.StatusOK
+	switch {
+	case errors.Is(err, clusters.ErrClusterNotFound), errors.Is(err, clusters.ErrNoEndpointsSelected):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, clusters.ErrEndpointPreconditionFailed):
+		code = http.StatusConflict
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		klog.Errorf("[debug] failed to write endpoint batch result: %v", err)
+	}
+}
+
+// runtimeTunables manages runtime tunables. GET shows current tunables, PUT applies tunables
+// from a JSON body, fields absent from the body keep their current values.
+type runtimeTunables struct{}
+
+func (t *runtimeTunables) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if err := json.NewEncoder(w).Encode(tunables.Current()); err != nil {
+			klog.Errorf("[debug] failed to write runtime tunables: %v", err)
+		}
+	case http.MethodPut, http.MethodPost:
+		value := tunables.Current()
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid runtime tunables: %v", err), http.StatusBadRequest)
+			return
+		}
+		klog.Warningf("[debug] set runtime tunables %+v, remote=%v", value, r.RemoteAddr)
+		if err := tunables.Apply(value); err != nil {
+			http.Error(w, fmt.Sprintf("invalid runtime tunables: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
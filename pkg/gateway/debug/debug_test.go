@@ -0,0 +1,169 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
+)
+
+func Test_parseTraceDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"default", "", defaultTraceDuration, false},
+		{"fraction", "0.5", 500 * time.Millisecond, false},
+		{"zero", "0", 0, true},
+		{"invalid", "abc", 0, true},
+		{"too long", "61", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTraceDuration(tt.seconds)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseTraceDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseTraceDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_flightRecorder(t *testing.T) {
+	f := &flightRecorder{}
+
+	// a trace is running
+	f.running = 1
+	w := httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, TracePath+"?seconds=0.1", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("flightRecorder code = %v, want %v", w.Code, http.StatusConflict)
+	}
+
+	f.running = 0
+	w = httptest.NewRecorder()
+	f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, TracePath+"?seconds=0.1", nil))
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("flightRecorder code = %v, body length = %v", w.Code, w.Body.Len())
+	}
+}
+
+func TestGoroutines(t *testing.T) {
+	w := httptest.NewRecorder()
+	Goroutines(w, httptest.NewRequest(http.MethodGet, GoroutinesPath, nil))
+	if !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("Goroutines() body does not contain goroutine stacks")
+	}
+}
+
+func TestRejections(t *testing.T) {
+	rejection.Register("debug", "debug_rejected", "DebugRejected", "rejected by test")
+	w := httptest.NewRecorder()
+	Rejections(w, httptest.NewRequest(http.MethodGet, RejectionsPath, nil))
+	var got []rejection.Info
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Rejections() body is not valid json: %v", err)
+	}
+	found := false
+	for _, info := range got {
+		found = found || (info.Reason == "debug_rejected" && info.Code == "DebugRejected")
+	}
+	if !found {
+		t.Errorf("Rejections() = %v, missing registered rejection", got)
+	}
+
+	w = httptest.NewRecorder()
+	Rejections(w, httptest.NewRequest(http.MethodPost, RejectionsPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Rejections() POST code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestSoftState(t *testing.T) {
+	manager := clusters.NewManager()
+	manager.Add(clusters.NewEmptyClusterInfo("test", nil, nil, nil))
+	h := &softState{manager: manager}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, StatePath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET code = %d, want %d", w.Code, http.StatusOK)
+	}
+	exported := w.Body.String()
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, StatePath, strings.NewReader(exported)))
+	if w.Code != http.StatusOK {
+		t.Errorf("PUT code = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, StatePath+"?maxAge=-1s", strings.NewReader(exported)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT with invalid maxAge code = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, StatePath, strings.NewReader(`{"version":"v0"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT of unknown version code = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestEndpointBatch(t *testing.T) {
+	manager := clusters.NewManager()
+	manager.Add(clusters.NewEmptyClusterInfo("test", nil, nil, nil))
+	h := &endpointBatch{manager: manager}
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		code  int
+	}{
+		{"invalid body", "", `{`, http.StatusBadRequest},
+		{"unknown action", "", `{"action":"drain"}`, http.StatusBadRequest},
+		{"invalid force", "?force=yes", `{"action":"cordon"}`, http.StatusBadRequest},
+		{"unknown cluster", "", `{"action":"cordon","clusters":["unknown"]}`, http.StatusNotFound},
+		{"no endpoints", "?dryRun=true", `{"action":"cordon","clusters":["test"]}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, BatchPath+tt.query, strings.NewReader(tt.body)))
+			if w.Code != tt.code {
+				t.Errorf("PUT code = %d, want %d, body: %s", w.Code, tt.code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, BatchPath, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/mux"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	PanicsPath = "/debug/gateway/panics"
+
+	// sites recovering panics
+	PanicSiteReverseProxy = "reverse_proxy"
+	PanicSiteHandlerChain = "handler_chain"
+
+	// classes of panic values
+	PanicClassAbortHandler = "abort_handler"
+	PanicClassRuntimeError = "runtime_error"
+	PanicClassError        = "error"
+	PanicClassOther        = "other"
+
+	// PanicResponseCloseConnection sets Connection: close on the response, so the connection
+	// is torn down (or GOAWAY is sent for http2) after the aborted response.
+	PanicResponseCloseConnection = "close-connection"
+	// PanicResponseStatus returns 500 Status if the response header is not written yet,
+	// otherwise it falls back to close connection.
+	PanicResponseStatus = "status"
+
+	// DefaultMaxPanicRecords is the default number of recent panics kept in memory
+	DefaultMaxPanicRecords = 32
+
+	maxPanicStackBytes = 16 * 1024
+)
+
+// PanicResponses are all known responses of recovered panics
+var PanicResponses = []string{PanicResponseCloseConnection, PanicResponseStatus}
+
+// DefaultPanicPolicy is the policy of all recovered panics. The admin API keeps the instance it is
+// installed with, so PanicOptions replaces it before InstallPanics, otherwise recorded panics are not listed.
+var DefaultPanicPolicy = NewPanicPolicy(PanicResponseCloseConnection, DefaultMaxPanicRecords, false)
+
+// PanicPolicy decides how recovered panics of proxied requests are responded, and keeps the
+// most recent of them in a bounded ring buffer which can be retrieved by admin API PanicsPath.
+type PanicPolicy struct {
+	// Response is one of PanicResponses
+	Response string
+	// CaptureStacks captures stack of the panicking goroutine into records
+	CaptureStacks bool
+
+	mu      sync.Mutex
+	records []PanicRecord
+	next    int
+	full    bool
+}
+
+// PanicRecord is a recovered panic
+type PanicRecord struct {
+	Time   time.Time `json:"time"`
+	Site   string    `json:"site"`
+	Class  string    `json:"class"`
+	Method string    `json:"method"`
+	Host   string    `json:"host"`
+	URI    string    `json:"uri"`
+	Value  string    `json:"value"`
+	Stack  string    `json:"stack,omitempty"`
+}
+
+// NewPanicPolicy creates a policy keeping at most maxRecords recent panics, zero means no panic is kept
+func NewPanicPolicy(response string, maxRecords int, captureStacks bool) *PanicPolicy {
+	if maxRecords < 0 {
+		maxRecords = 0
+	}
+	return &PanicPolicy{
+		Response:      response,
+		CaptureStacks: captureStacks,
+		records:       make([]PanicRecord, maxRecords),
+	}
+}
+
+// ClassifyPanic returns class of panic value
+func ClassifyPanic(value interface{}) string {
+	switch v := value.(type) {
+	case runtime.Error:
+		return PanicClassRuntimeError
+	case error:
+		if v == http.ErrAbortHandler {
+			return PanicClassAbortHandler
+		}
+		return PanicClassError
+	default:
+		return PanicClassOther
+	}
+}
+
+// Record counts the panic recovered at site and keeps it in ring buffer, it must be called
+// in the deferred function recovering the panic so that the stack of panicking goroutine is
+// still there. It returns class of the panic.
+func (p *PanicPolicy) Record(site string, req *http.Request, value interface{}) string {
+	class := ClassifyPanic(value)
+	metrics.RecordPanic(site, class)
+	if p == nil || len(p.records) == 0 {
+		return class
+	}
+	record := PanicRecord{
+		Time:   time.Now(),
+		Site:   site,
+		Class:  class,
+		Method: req.Method,
+		Host:   req.Host,
+		URI:    redact.URI(req.RequestURI),
+		Value:  redact.Text(fmt.Sprint(value)),
+	}
+	if p.CaptureStacks && class != PanicClassAbortHandler {
+		// aborting handler is expected, e.g. copying response to a gone client
+		stack := make([]byte, maxPanicStackBytes)
+		record.Stack = string(stack[:runtime.Stack(stack, false)])
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records[p.next] = record
+	p.next = (p.next + 1) % len(p.records)
+	if p.next == 0 {
+		p.full = true
+	}
+	return class
+}
+
+// Recent returns kept panics from the newest to the oldest
+func (p *PanicPolicy) Recent() []PanicRecord {
+	result := []PanicRecord{}
+	if p == nil || len(p.records) == 0 {
+		return result
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := p.next
+	if p.full {
+		n = len(p.records)
+	}
+	for i := 1; i <= n; i++ {
+		result = append(result, p.records[(p.next-i+len(p.records))%len(p.records)])
+	}
+	return result
+}
+
+// RespondStatus returns true if a 500 Status should be written for a panic of class, the
+// response header must not be written yet.
+func (p *PanicPolicy) RespondStatus(class string) bool {
+	// aborting handler means the response must be aborted instead of being completed
+	return p != nil && p.Response == PanicResponseStatus && class != PanicClassAbortHandler
+}
+
+// InstallPanics adds the handler which shows recent panics recovered by proxy,
+// they are not profiling data, so it is installed whether profiling is enabled or not.
+func InstallPanics(c *mux.PathRecorderMux, policy *PanicPolicy) {
+	c.Handle(PanicsPath, &recentPanics{policy: policy})
+}
+
+type recentPanics struct {
+	policy *PanicPolicy
+}
+
+func (p *recentPanics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := json.NewEncoder(w).Encode(p.policy.Recent()); err != nil {
+		klog.Errorf("[debug] failed to write recent panics: %v", err)
+	}
+}
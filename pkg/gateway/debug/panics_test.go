@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClassifyPanic(t *testing.T) {
+	var nilMap map[string]int
+	runtimeError := func() (r interface{}) {
+		defer func() { r = recover() }()
+		nilMap["a"] = 1
+		return nil
+	}()
+
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{http.ErrAbortHandler, PanicClassAbortHandler},
+		{runtimeError, PanicClassRuntimeError},
+		{errors.New("boom"), PanicClassError},
+		{"boom", PanicClassOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyPanic(tt.value); got != tt.want {
+			t.Errorf("ClassifyPanic(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPanicPolicy_Record(t *testing.T) {
+	p := NewPanicPolicy(PanicResponseStatus, 2, true)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods?token=secret", nil)
+	if got := p.Recent(); len(got) != 0 {
+		t.Fatalf("Recent() = %v, want empty", got)
+	}
+
+	p.Record(PanicSiteReverseProxy, req, "first")
+	p.Record(PanicSiteReverseProxy, req, http.ErrAbortHandler)
+	p.Record(PanicSiteHandlerChain, req, errors.New("third"))
+
+	got := p.Recent()
+	if len(got) != 2 || got[0].Value != "third" || got[1].Class != PanicClassAbortHandler {
+		t.Fatalf("Recent() = %+v, want the two newest panics from the newest", got)
+	}
+	if len(got[0].Stack) == 0 {
+		t.Errorf("stack of panic is not captured")
+	}
+	if len(got[1].Stack) != 0 {
+		t.Errorf("stack of aborted handler is captured")
+	}
+
+	if !p.RespondStatus(PanicClassRuntimeError) || p.RespondStatus(PanicClassAbortHandler) {
+		t.Errorf("RespondStatus() must be true for all panics except aborted handler")
+	}
+	if NewPanicPolicy(PanicResponseCloseConnection, 0, false).RespondStatus(PanicClassRuntimeError) {
+		t.Errorf("RespondStatus() must be false if response is close connection")
+	}
+
+	w := httptest.NewRecorder()
+	(&recentPanics{policy: p}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, PanicsPath, nil))
+	records := []PanicRecord{}
+	if err := json.NewDecoder(w.Body).Decode(&records); err != nil || len(records) != 2 {
+		t.Errorf("recentPanics returns %v, err: %v", records, err)
+	}
+	if strings.Contains(records[0].URI, "secret") {
+		t.Errorf("recentPanics returns unredacted uri %v", records[0].URI)
+	}
+}
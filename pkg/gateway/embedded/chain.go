@@ -0,0 +1,78 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedded
+
+import (
+	"net/http"
+
+	genericapifilters "k8s.io/apiserver/pkg/endpoints/filters"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	genericfilters "k8s.io/apiserver/pkg/server/filters"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/capture"
+	gatewayfilters "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/filters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	proxydispatcher "github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+// DispatchConfig contains policies of dispatcher and of the filters before it, nil policies are
+// disabled, see proxydispatcher.Config.
+type DispatchConfig struct {
+	proxydispatcher.Config
+	// Recorder captures sampled requests, nil means capture is disabled
+	Recorder *capture.Recorder
+	// Validation rejects malformed requests before proxying, nil means strict validation is disabled
+	Validation *gatewayfilters.RequestValidation
+}
+
+// NewHandlerChainFunc returns the handler chain of kube-gateway proxy, requests to hostnames of upstream
+// clusters are routed to dispatcher and requests to IP addresses are served by apiHandler.
+func NewHandlerChainFunc(clusterManager clusters.Manager, dispatch DispatchConfig) func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
+	return func(apiHandler http.Handler, c *genericapiserver.Config) http.Handler {
+		auditBackend := redact.NewAuditBackend(c.AuditBackend)
+		// new gateway handler chain
+		handler := gatewayfilters.WithDispatcher(apiHandler, proxydispatcher.NewDispatcher(clusterManager, dispatch.Config))
+		// without impersonation log
+		handler = gatewayfilters.WithNoLoggingImpersonation(handler, c.Authorization.Authorizer, c.Serializer)
+		// new gateway handler chain, add impersonator userInfo
+		handler = gatewayfilters.WithImpersonator(handler)
+		handler = gatewayfilters.WithTrafficCapture(handler, dispatch.Recorder)
+		handler = genericapifilters.WithAudit(handler, auditBackend, c.AuditPolicyChecker, c.LongRunningFunc)
+		failedHandler := genericapifilters.Unauthorized(c.Serializer, c.Authentication.SupportsBasicAuth)
+		failedHandler = genericapifilters.WithFailedAuthenticationAudit(failedHandler, auditBackend, c.AuditPolicyChecker)
+		handler = genericapifilters.WithAuthentication(handler, c.Authentication.Authenticator, failedHandler, c.Authentication.APIAudiences)
+		// save client credential before authentication removes it
+		handler = gatewayfilters.WithRawCredential(handler)
+		handler = gatewayfilters.WithCORSPolicy(handler, clusterManager, c.CorsAllowedOriginList)
+		// disabel timeout, let upstream cluster handle it
+		// handler = gatewayfilters.WithTimeoutForNonLongRunningRequests(handler, c.LongRunningFunc, c.RequestTimeout)
+		handler = genericfilters.WithWaitGroup(handler, c.LongRunningFunc, c.HandlerChainWaitGroup)
+		// new gateway handler chain
+		handler = gatewayfilters.WithPreProcessingMetrics(handler)
+		handler = gatewayfilters.WithExtraRequestInfo(handler, &request.ExtraRequestInfoFactory{})
+		handler = gatewayfilters.WithTerminationMetrics(handler)
+		handler = genericapifilters.WithRequestInfo(handler, c.RequestInfoResolver)
+		if c.SecureServing != nil && !c.SecureServing.DisableHTTP2 && c.GoawayChance > 0 {
+			handler = genericfilters.WithProbabilisticGoaway(handler, c.GoawayChance)
+		}
+		handler = genericapifilters.WithCacheControl(handler)
+		handler = gatewayfilters.WithRequestValidation(handler, dispatch.Validation, c.Serializer)
+		handler = gatewayfilters.WithRequestID(handler)
+		handler = gatewayfilters.WithNoLoggingPanicRecovery(handler)
+		return handler
+	}
+}
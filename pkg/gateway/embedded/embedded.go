@@ -0,0 +1,194 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package embedded assembles the routing, dispatching and flow control stack of kube-gateway
+// proxy as a library, so other control plane components can embed it into their own binaries.
+//
+// A minimal embedding creates a Gateway with an authenticator and an authorizer, serves
+// Gateway.Handler by its own http server, calls Start before serving and Stop on shutdown.
+// See examples/embedded-proxy for a runnable example.
+//
+// Settings of upstream clusters and dispatcher are per Gateway, see WithClusterDefaults and
+// WithDispatchConfig. The rest are process-wide, e.g. feature gates, metrics, the notification sink and
+// the CORS policy, host templates, virtual clusters and user agent rules of package clusters, so only
+// one Gateway per process is supported.
+package embedded
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubewharf/apiserver-runtime/pkg/scheme"
+	utilwaitgroup "k8s.io/apimachinery/pkg/util/waitgroup"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	gatewayinformers "github.com/kubewharf/kubegateway/pkg/client/informers"
+	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+	proxydispatcher "github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+// Option configures a Gateway
+type Option func(*config)
+
+type config struct {
+	authenticator authenticator.Request
+	authorizer    authorizer.Authorizer
+	apiHandler    http.Handler
+	dispatch      DispatchConfig
+	defaults      *clusters.ClusterDefaults
+	resync        time.Duration
+}
+
+// WithAuthenticator sets the authenticator of client requests, it is required
+func WithAuthenticator(authenticator authenticator.Request) Option {
+	return func(c *config) {
+		c.authenticator = authenticator
+	}
+}
+
+// WithAuthorizer sets the authorizer of impersonation requests, it is required
+func WithAuthorizer(authorizer authorizer.Authorizer) Option {
+	return func(c *config) {
+		c.authorizer = authorizer
+	}
+}
+
+// WithAPIHandler sets the handler of requests to IP addresses instead of cluster hostnames,
+// they are rejected with 404 by default.
+func WithAPIHandler(handler http.Handler) Option {
+	return func(c *config) {
+		c.apiHandler = handler
+	}
+}
+
+// WithDispatchConfig sets policies of dispatcher, all policies are disabled by default
+func WithDispatchConfig(dispatch DispatchConfig) Option {
+	return func(c *config) {
+		c.dispatch = dispatch
+	}
+}
+
+// WithClusterDefaults sets the defaults of upstream clusters, e.g. resource budget, ceilings, timeouts and
+// auth mode, clusters.NewClusterDefaults() is used by default. defaults must not be changed after New.
+func WithClusterDefaults(defaults *clusters.ClusterDefaults) Option {
+	return func(c *config) {
+		c.defaults = defaults
+	}
+}
+
+// WithResyncPeriod sets the resync period of UpstreamCluster informer, zero means never resync
+func WithResyncPeriod(resync time.Duration) Option {
+	return func(c *config) {
+		c.resync = resync
+	}
+}
+
+// Gateway is an embeddable kube-gateway proxy which routes requests to upstream clusters
+// defined by UpstreamCluster objects.
+type Gateway struct {
+	informers  gatewayinformers.SharedInformerFactory
+	controller *controllers.UpstreamClusterController
+	handler    http.Handler
+	drain      *proxydispatcher.DrainPolicy
+	// waitGroup tracks inflight non-long-running requests, Stop waits for them
+	waitGroup *utilwaitgroup.SafeWaitGroup
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+}
+
+// New creates a Gateway which watches UpstreamCluster objects by client. Only one Gateway per process
+// is supported, see the package documentation.
+func New(client gatewayclientset.Interface, opts ...Option) (*Gateway, error) {
+	c := &config{
+		apiHandler: http.NotFoundHandler(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("gateway client must not be nil")
+	}
+	if c.authenticator == nil {
+		return nil, fmt.Errorf("authenticator is required, see WithAuthenticator")
+	}
+	if c.authorizer == nil {
+		return nil, fmt.Errorf("authorizer is required, see WithAuthorizer")
+	}
+
+	informers := gatewayinformers.NewSharedInformerFactory(client, c.resync)
+	controller := controllers.NewUpstreamClusterController(informers.Proxy().V1alpha1().UpstreamClusters(), c.defaults)
+
+	genericConfig := genericapiserver.NewConfig(scheme.Codecs)
+	genericConfig.Authentication.Authenticator = c.authenticator
+	genericConfig.Authorization.Authorizer = c.authorizer
+	genericConfig.RequestInfoResolver = genericapiserver.NewRequestInfoResolver(genericConfig)
+
+	return &Gateway{
+		informers:  informers,
+		controller: controller,
+		handler:    NewHandlerChainFunc(controller, c.dispatch)(c.apiHandler, genericConfig),
+		drain:      c.dispatch.Drain,
+		waitGroup:  genericConfig.HandlerChainWaitGroup,
+		stopCh:     make(chan struct{}),
+	}, nil
+}
+
+// Handler returns the http handler which proxies requests to upstream clusters by hostname
+func (g *Gateway) Handler() http.Handler {
+	return g.handler
+}
+
+// Clusters returns the manager of upstream clusters, it is empty before Start
+func (g *Gateway) Clusters() clusters.Manager {
+	return g.controller
+}
+
+// Start starts watching upstream clusters and waits until they are synced,
+// requests can be served after it returns. It is a no-op after the first call.
+func (g *Gateway) Start() error {
+	var err error
+	g.startOnce.Do(func() {
+		informer := g.informers.Proxy().V1alpha1().UpstreamClusters().Informer()
+		g.informers.Start(g.stopCh)
+		// controller panics if it is stopped before synced
+		if !cache.WaitForCacheSync(g.stopCh, informer.HasSynced) {
+			err = fmt.Errorf("gateway is stopped before upstream clusters are synced")
+			return
+		}
+		go g.controller.Run(g.stopCh)
+		klog.Info("embedded gateway started")
+	})
+	return err
+}
+
+// Stop drains sessions if DispatchConfig.Drain is set, stops watching upstream clusters and waits for
+// inflight non-long-running requests, new requests are rejected after it is called.
+func (g *Gateway) Stop() {
+	g.stopOnce.Do(func() {
+		g.drain.Drain()
+		close(g.stopCh)
+		g.waitGroup.Wait()
+		klog.Info("embedded gateway stopped")
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package embedded
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apiserver/pkg/authentication/authenticator"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizerfactory"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/client/kubernetes/fake"
+	"github.com/kubewharf/kubegateway/pkg/gateway/testing/fakeupstream"
+)
+
+const pods = "/api/v1/namespaces/default/pods"
+
+func TestGateway_StartStop(t *testing.T) {
+	upstream := fakeupstream.NewServer()
+	defer upstream.Close()
+	upstream.AddObject(pods, fakeupstream.Object{
+		"kind":       "Pod",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{"name": "a", "namespace": "default"},
+	})
+
+	config := upstream.RESTConfig()
+	client := fake.NewSimpleClientset(&proxyv1alpha1.UpstreamCluster{
+		// upstream clusters are verified by their names, which must match the serving certificate of httptest
+		ObjectMeta: metav1.ObjectMeta{Name: "example.com"},
+		Spec: proxyv1alpha1.UpstreamClusterSpec{
+			Servers: []proxyv1alpha1.UpstreamClusterServer{{Endpoint: config.Host}},
+			ClientConfig: proxyv1alpha1.ClientConfig{
+				CAData:      config.CAData,
+				BearerToken: []byte("token"),
+			},
+			DispatchPolicies: []proxyv1alpha1.DispatchPolicy{{
+				Rules: []proxyv1alpha1.DispatchPolicyRule{{
+					Verbs:           []string{"*"},
+					APIGroups:       []string{"*"},
+					Resources:       []string{"*"},
+					NonResourceURLs: []string{"*"},
+				}},
+			}},
+		},
+	})
+	alice := authenticator.RequestFunc(func(req *http.Request) (*authenticator.Response, bool, error) {
+		return &authenticator.Response{User: &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}}, true, nil
+	})
+	gateway, err := New(client, WithAuthenticator(alice), WithAuthorizer(authorizerfactory.NewAlwaysAllowAuthorizer()))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := gateway.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// the endpoint is ready after the first health check
+	err = wait.PollImmediate(50*time.Millisecond, 10*time.Second, func() (bool, error) {
+		cluster, ok := gateway.Clusters().Get("example.com")
+		if !ok {
+			return false, nil
+		}
+		endpoint, ok := cluster.Endpoints.Load(config.Host)
+		return ok && endpoint.IsReady(), nil
+	})
+	if err != nil {
+		t.Fatalf("upstream cluster is not ready: %v", err)
+	}
+
+	list := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "https://example.com"+pods, nil)
+		recorder := httptest.NewRecorder()
+		gateway.Handler().ServeHTTP(recorder, req)
+		return recorder
+	}
+	recorder := list()
+	obj := fakeupstream.Object{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &obj); err != nil {
+		t.Fatalf("failed to decode response %q: %v", recorder.Body.String(), err)
+	}
+	if recorder.Code != http.StatusOK || obj["kind"] != "PodList" {
+		t.Errorf("response = %d %v, want 200 PodList", recorder.Code, obj["kind"])
+	}
+
+	gateway.Stop()
+	if recorder := list(); recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("response after Stop() = %d, want 503", recorder.Code)
+	}
+	// Stop is idempotent
+	gateway.Stop()
+}
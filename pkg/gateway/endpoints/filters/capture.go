@@ -0,0 +1,126 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"net/http"
+	"time"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/apiserver/pkg/endpoints/responsewriter"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/capture"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
+)
+
+// WithTrafficCapture records sanitized metadata of sampled requests into recorder,
+// it must be installed after authentication to know the requesting user.
+func WithTrafficCapture(handler http.Handler, recorder *capture.Recorder) http.Handler {
+	if recorder == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !recorder.Sampled() {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		start := time.Now()
+		cw := &captureWriter{w: w}
+		defer func() {
+			recorder.Record(newCaptureRecord(req, start, cw.status))
+		}()
+		handler.ServeHTTP(responsewriter.WrapForHTTP1Or2(cw), req)
+	})
+}
+
+func newCaptureRecord(req *http.Request, start time.Time, status int) *capture.Record {
+	ctx := req.Context()
+	record := &capture.Record{
+		Time:       start,
+		UserAgent:  req.UserAgent(),
+		Verb:       req.Method,
+		Code:       status,
+		DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if extraInfo, ok := request.ExtraReqeustInfoFrom(ctx); ok {
+		record.Cluster = extraInfo.Hostname
+	}
+	if u, ok := genericapirequest.UserFrom(ctx); ok {
+		record.User = u.GetName()
+	}
+	if info, ok := genericapirequest.RequestInfoFrom(ctx); ok {
+		record.Verb = info.Verb
+		if info.IsResourceRequest {
+			record.APIGroup = info.APIGroup
+			record.APIVersion = info.APIVersion
+			record.Resource = info.Resource
+			record.Subresource = info.Subresource
+			record.Namespace = info.Namespace
+		} else {
+			record.Path = info.Path
+		}
+	}
+	if proxyInfo, ok := request.ExtraProxyInfoFrom(ctx); ok {
+		record.Forwarded = proxyInfo.Forwarded
+		record.Reason = proxyInfo.Reason
+		if len(record.Reason) > 0 {
+			record.Rejection = string(rejection.CodeOf(record.Reason))
+		}
+	}
+	if dispatch, ok := request.DispatchInfoFrom(ctx); ok {
+		record.Endpoint = dispatch.Endpoint
+		record.FlowControl = dispatch.FlowControl
+	}
+	if id, ok := request.RequestIDFrom(ctx); ok {
+		record.RequestID = id
+	}
+	// failed attempts include the first try and the final one which is not retried
+	record.Retries = request.ProxyRetriesFrom(ctx)
+	if attempts := request.ProxyAttemptsFrom(ctx); len(attempts) > 0 {
+		record.ErrorClass = attempts[len(attempts)-1].ErrorClass
+	}
+	return record
+}
+
+type captureWriter struct {
+	status int
+	w      http.ResponseWriter
+}
+
+func (rw *captureWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+// Header implements http.ResponseWriter.
+func (rw *captureWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (rw *captureWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.w.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (rw *captureWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	return rw.w.Write(b)
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"net/http"
+
+	"k8s.io/apiserver/pkg/endpoints/responsewriter"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+// WithCORSPolicy answers cross-origin requests according to the CORS policy of the requested
+// upstream cluster, allowedOrigins (--cors-allowed-origins) is used for clusters without policy.
+// It must be installed out of authentication because preflight requests carry no credentials.
+func WithCORSPolicy(handler http.Handler, clusterManager clusters.Manager, allowedOrigins []string) http.Handler {
+	var fallback *clusters.CORSPolicy
+	if len(allowedOrigins) > 0 {
+		var err error
+		fallback, err = clusters.NewCORSPolicy(clusters.CORSPolicySpec{
+			AllowedOrigins:   allowedOrigins,
+			AllowCredentials: true,
+		})
+		if err != nil {
+			klog.Fatalf("invalid CORS allowed origins %v: %v", allowedOrigins, err)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if len(origin) == 0 {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		policy := fallback
+		mode := clusters.UpstreamCORSStrip
+		if info, ok := request.ExtraReqeustInfoFrom(req.Context()); ok {
+			if cluster, ok := clusterManager.Resolve(info.Hostname); ok {
+				if p := cluster.CORSPolicy(); p != nil {
+					policy = p
+				}
+				mode = cluster.UpstreamCORSMode(req.URL.Path)
+			}
+		}
+		if policy == nil || !policy.AllowOrigin(origin) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		preflight := req.Method == http.MethodOptions && len(req.Header.Get("Access-Control-Request-Method")) > 0
+		if !preflight && mode != clusters.UpstreamCORSStrip {
+			// upstream CORS headers are not stripped, merge them with headers of policy before they are sent
+			cw := &corsMergingWriter{w: w, gateway: policy.ResponseHeaders(origin, false), mode: mode}
+			handler.ServeHTTP(responsewriter.WrapForHTTP1Or2(cw), req)
+			cw.merge()
+			return
+		}
+		policy.SetResponseHeaders(w.Header(), origin, preflight)
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// corsMergingWriter merges CORS headers of gateway policy into response headers before they are written
+type corsMergingWriter struct {
+	w       http.ResponseWriter
+	gateway http.Header
+	mode    clusters.UpstreamCORSMode
+	merged  bool
+}
+
+func (rw *corsMergingWriter) Unwrap() http.ResponseWriter {
+	return rw.w
+}
+
+func (rw *corsMergingWriter) merge() {
+	if rw.merged {
+		return
+	}
+	rw.merged = true
+	clusters.MergeCORSHeaders(rw.w.Header(), rw.gateway, rw.mode)
+}
+
+// Header implements http.ResponseWriter.
+func (rw *corsMergingWriter) Header() http.Header {
+	return rw.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (rw *corsMergingWriter) WriteHeader(status int) {
+	rw.merge()
+	rw.w.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (rw *corsMergingWriter) Write(b []byte) (int, error) {
+	rw.merge()
+	return rw.w.Write(b)
+}
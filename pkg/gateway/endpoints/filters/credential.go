@@ -0,0 +1,32 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"net/http"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+// WithRawCredential saves client Authorization header into request context before authentication
+// removes it, so clusters in passthrough auth mode can forward it to upstream.
+func WithRawCredential(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if authorization := req.Header.Get("Authorization"); len(authorization) > 0 {
+			req = req.WithContext(request.WithRawAuthorization(req.Context(), authorization))
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
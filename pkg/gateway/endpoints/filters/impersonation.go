@@ -32,6 +32,10 @@ import (
 	"k8s.io/apiserver/pkg/authorization/authorizer"
 	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
 	"k8s.io/apiserver/pkg/endpoints/request"
+
+	gatewayrequest "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
 )
 
 const (
@@ -42,6 +46,8 @@ const (
 	resourceServiceAccounts = "serviceaccounts"
 )
 
+var reasonImpersonationDenied = rejection.Register("impersonation", "impersonation_denied", "ImpersonationDenied", "the user is not allowed to impersonate the requested user, group or extra")
+
 // the fllowing code is copied from k8s.io/apiserver/pkg/endpoint/filters/impersonation.go and delete httplog for proxy
 //
 // WithNoLoggingImpersonation is a filter that will inspect and check requests that attempt to change the user.Info for their requests
@@ -112,13 +118,15 @@ func WithNoLoggingImpersonation(handler http.Handler, a authorizer.Authorizer, s
 
 			default:
 				klog.V(4).Infof("unknown impersonation request type: %v", impersonationRequest)
+				gatewayrequest.SetProxyTerminated(ctx, reasonImpersonationDenied) //nolint
 				responsewriters.Forbidden(ctx, actingAsAttributes, w, req, fmt.Sprintf("unknown impersonation request type: %v", impersonationRequest), s)
 				return
 			}
 
 			decision, reason, err := a.Authorize(ctx, actingAsAttributes)
 			if err != nil || decision != authorizer.DecisionAllow {
-				klog.V(4).Infof("Forbidden: %#v, Reason: %s, Error: %v", req.RequestURI, reason, err)
+				klog.V(4).Infof("Forbidden: %#v, Reason: %s, Error: %v", redact.URI(req.RequestURI), reason, err)
+				gatewayrequest.SetProxyTerminated(ctx, reasonImpersonationDenied) //nolint
 				responsewriters.Forbidden(ctx, actingAsAttributes, w, req, reason, s)
 				return
 			}
@@ -166,6 +174,8 @@ func WithNoLoggingImpersonation(handler http.Handler, a authorizer.Authorizer, s
 
 		ae := request.AuditEventFrom(ctx)
 		audit.LogImpersonatedUser(ae, newUser)
+		// compliance reviews look for the whole chain of gateway-mediated access in one place
+		audit.LogAnnotation(ae, gatewayrequest.ImpersonationChainAuditAnnotationKey, gatewayrequest.FormatImpersonationChain([]user.Info{requestor, newUser}))
 
 		// clear all the impersonation headers from the request
 		req.Header.Del(authenticationv1.ImpersonateUserHeader)
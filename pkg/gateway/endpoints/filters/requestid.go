@@ -0,0 +1,92 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+const (
+	// RequestIDHeader carries the request ID in both request and response
+	RequestIDHeader = "X-Request-Id"
+
+	// maxRequestIDLength bounds request IDs provided by clients, longer ones are replaced
+	maxRequestIDLength = 128
+
+	// TraceParentHeader carries the W3C trace context of client, see https://www.w3.org/TR/trace-context/
+	TraceParentHeader = "traceparent"
+)
+
+// WithRequestID saves the request ID into request context and echoes it in response, so the
+// request can be correlated across filters, logs and clients. The ID provided by client is
+// reused if it is valid, otherwise a new one is generated. The trace ID of W3C trace context is
+// saved too, so slow or failed requests in logs can be looked up in the tracing backend.
+func WithRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = string(uuid.NewUUID())
+			req.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := request.WithRequestID(req.Context(), id)
+		if traceID, ok := parseTraceParent(req.Header.Get(TraceParentHeader)); ok {
+			ctx = request.WithTraceID(ctx, traceID)
+		}
+		req = req.WithContext(ctx)
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func isValidRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		// printable ascii without spaces, so it can not break log lines
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTraceParent returns the trace ID of traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceParent(value string) (string, bool) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+	traceID := parts[1]
+	if !isLowerHex(parts[0]) || !isLowerHex(traceID) || !isLowerHex(parts[2]) || traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	return traceID, true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
+)
+
+var (
+	// reasons of rejected requests used in metrics and logs, see package rejection for their codes
+	requestRejectedAmbiguousLength = rejection.Register("validation", "ambiguous_length", "AmbiguousLength", "the request has ambiguous message length")
+	requestRejectedIllegalHeader   = rejection.Register("validation", "illegal_header", "IllegalHeader", "the request has illegal characters in headers")
+	requestRejectedURITooLong      = rejection.Register("validation", "uri_too_long", "URITooLong", "the request uri exceeds --proxy-max-request-uri-bytes")
+	requestRejectedHeaderTooLarge  = rejection.Register("validation", "header_too_large", "HeaderTooLarge", "the request headers exceed --proxy-max-request-header-bytes")
+)
+
+const (
+	// maxLoggedPathLength bounds the path of rejected requests in logs
+	maxLoggedPathLength = 256
+)
+
+// RequestValidation rejects malformed requests before they are proxied to upstream, zero limits mean unlimited.
+type RequestValidation struct {
+	// MaxURIBytes is the maximum length of request URI
+	MaxURIBytes int
+	// MaxHeaderBytes is the maximum total size of request headers, counted as they are sent in HTTP/1.1
+	MaxHeaderBytes int
+}
+
+// WithRequestValidation rejects requests with ambiguous message length, illegal header characters
+// or too long URIs and headers, so that they never reach upstream servers which may frame or parse
+// them differently. It should be installed before any other filter except request ID and panic recovery.
+func WithRequestValidation(handler http.Handler, validation *RequestValidation, s runtime.NegotiatedSerializer) http.Handler {
+	if validation == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		code, reason, message := validation.validate(req)
+		if code == 0 {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		metrics.RecordRejectedInvalidRequest(reason)
+		requestID, _ := request.RequestIDFrom(req.Context())
+		path := req.URL.Path
+		if len(path) > maxLoggedPathLength {
+			path = path[:maxLoggedPathLength] + "..."
+		}
+		klog.Infof("[request validation] reject request: method=%q host=%q path=%q proto=%v remoteAddr=%v userAgent=%q requestID=%q reason=%v code=%v message=%q",
+			req.Method, req.Host, redact.Text(path), req.Proto, req.RemoteAddr, req.UserAgent(), requestID, reason, rejection.CodeOf(reason), message)
+		// the connection may be out of sync with client after an ambiguous request
+		w.Header().Set("Connection", "close")
+		status := &apierrors.StatusError{ErrStatus: metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    int32(code),
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: message,
+		}}
+		responsewriters.ErrorNegotiated(rejection.WithStatusDetails(status, reason), s, schema.GroupVersion{Version: "v1"}, w, req)
+	})
+}
+
+// validate returns a non-zero status code if req should be rejected
+func (v *RequestValidation) validate(req *http.Request) (int, string, string) {
+	if v.MaxURIBytes > 0 && len(req.RequestURI) > v.MaxURIBytes {
+		return http.StatusRequestURITooLong, requestRejectedURITooLong, fmt.Sprintf("request uri exceeds %d bytes", v.MaxURIBytes)
+	}
+
+	// net/http drops Content-Length of chunked requests and rejects different Content-Length values,
+	// reject what is left of ambiguous framing instead of normalizing it.
+	contentLength := req.Header["Content-Length"]
+	if len(contentLength) > 1 {
+		return http.StatusBadRequest, requestRejectedAmbiguousLength, "multiple Content-Length headers"
+	}
+	if _, ok := req.Header["Transfer-Encoding"]; ok || len(req.TransferEncoding) > 0 {
+		if len(contentLength) > 0 {
+			return http.StatusBadRequest, requestRejectedAmbiguousLength, "both Content-Length and Transfer-Encoding are set"
+		}
+		if len(req.TransferEncoding) != 1 || req.TransferEncoding[0] != "chunked" {
+			return http.StatusBadRequest, requestRejectedAmbiguousLength, "unsupported Transfer-Encoding"
+		}
+	}
+
+	size := len("Host: \r\n") + len(req.Host)
+	if !isValidHeaderValue(req.Host) {
+		return http.StatusBadRequest, requestRejectedIllegalHeader, "illegal characters in Host"
+	}
+	for name, values := range req.Header {
+		if !isValidHeaderName(name) {
+			return http.StatusBadRequest, requestRejectedIllegalHeader, fmt.Sprintf("illegal header name %q", name)
+		}
+		for _, value := range values {
+			if !isValidHeaderValue(value) {
+				return http.StatusBadRequest, requestRejectedIllegalHeader, fmt.Sprintf("illegal characters in value of header %s", name)
+			}
+			size += len(name) + len(value) + len(": \r\n")
+		}
+	}
+	if v.MaxHeaderBytes > 0 && size > v.MaxHeaderBytes {
+		return http.StatusRequestHeaderFieldsTooLarge, requestRejectedHeaderTooLarge, fmt.Sprintf("request headers exceed %d bytes", v.MaxHeaderBytes)
+	}
+	return 0, "", ""
+}
+
+// isValidHeaderName returns true if name is a token of RFC 7230
+func isValidHeaderName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isValidHeaderValue returns true if value contains no control characters other than horizontal tab
+func isValidHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestValidation_validate(t *testing.T) {
+	v := &RequestValidation{MaxURIBytes: 64, MaxHeaderBytes: 256}
+	tests := []struct {
+		name     string
+		modify   func(req *http.Request)
+		wantCode int
+	}{
+		{"valid", func(req *http.Request) {}, 0},
+		{"chunked", func(req *http.Request) { req.TransferEncoding = []string{"chunked"} }, 0},
+		{"uri too long", func(req *http.Request) { req.RequestURI = "/api/v1/pods?labelSelector=" + strings.Repeat("a", 64) }, http.StatusRequestURITooLong},
+		{"multiple content length", func(req *http.Request) { req.Header["Content-Length"] = []string{"1", "1"} }, http.StatusBadRequest},
+		{"content length with transfer encoding", func(req *http.Request) {
+			req.Header.Set("Content-Length", "10")
+			req.TransferEncoding = []string{"chunked"}
+		}, http.StatusBadRequest},
+		{"raw transfer encoding header", func(req *http.Request) { req.Header.Set("Transfer-Encoding", "chunked") }, http.StatusBadRequest},
+		{"unsupported transfer encoding", func(req *http.Request) { req.TransferEncoding = []string{"gzip", "chunked"} }, http.StatusBadRequest},
+		{"illegal header name", func(req *http.Request) { req.Header["Bad Header"] = []string{"a"} }, http.StatusBadRequest},
+		{"illegal header value", func(req *http.Request) { req.Header["X-Test"] = []string{"a\r\nInjected: b"} }, http.StatusBadRequest},
+		{"header too large", func(req *http.Request) { req.Header.Set("X-Large", strings.Repeat("a", 256)) }, http.StatusRequestHeaderFieldsTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+			req.Header.Set("User-Agent", "kubectl/v1.18.19")
+			tt.modify(req)
+			if code, _, _ := v.validate(req); code != tt.wantCode {
+				t.Errorf("validate() = %v, want %v", code, tt.wantCode)
+			}
+		})
+	}
+}
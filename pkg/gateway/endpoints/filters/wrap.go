@@ -19,11 +19,15 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/klog"
+
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
 )
 
 // WithNoLoggingPanicRecovery wraps an http Handler to recover and log panics (except in the special case of http.ErrAbortHandler panics, which suppress logging).
 func WithNoLoggingPanicRecovery(handler http.Handler) http.Handler {
 	return withNoLoggingPanicRecovery(handler, func(w http.ResponseWriter, req *http.Request, err interface{}) {
+		gatewaydebug.DefaultPanicPolicy.Record(gatewaydebug.PanicSiteHandlerChain, req, err)
 		if err == http.ErrAbortHandler {
 			// honor the http.ErrAbortHandler sentinel panic value:
 			//   ErrAbortHandler is a sentinel panic value to abort a handler.
@@ -32,7 +36,7 @@ func WithNoLoggingPanicRecovery(handler http.Handler) http.Handler {
 			return
 		}
 		http.Error(w, "This request caused apiserver to panic. Look in the logs for details.", http.StatusInternalServerError)
-		klog.Errorf("apiserver panic'd on %v %v", req.Method, req.RequestURI)
+		klog.Errorf("apiserver panic'd on %v %v", req.Method, redact.URI(req.RequestURI))
 	})
 }
 
@@ -0,0 +1,31 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"context"
+)
+
+// WithRawAuthorization returns a copy of parent in which the client Authorization header is set,
+// authentication filter removes the header from request after client is authenticated.
+func WithRawAuthorization(parent context.Context, authorization string) context.Context {
+	return context.WithValue(parent, rawAuthorizationKey, authorization)
+}
+
+// RawAuthorizationFrom returns the client Authorization header saved before authentication
+func RawAuthorizationFrom(ctx context.Context) (string, bool) {
+	authorization, ok := ctx.Value(rawAuthorizationKey).(string)
+	return authorization, ok && len(authorization) > 0
+}
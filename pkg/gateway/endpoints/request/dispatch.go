@@ -0,0 +1,108 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"context"
+	"fmt"
+)
+
+// DispatchInfo is the decision made by dispatcher for a request. It is filled by dispatcher and
+// can be read by all filters wrapping dispatcher and the error handler, so extensions do not
+// need to resolve the cluster or endpoint again.
+type DispatchInfo struct {
+	// Cluster is the upstream cluster which the request is routed to
+	Cluster string
+	// Endpoint is the upstream endpoint chosen for the latest try
+	Endpoint string
+	// FlowControl is the name of flow control schema which the request is accounted to
+	FlowControl string
+}
+
+// DispatchInfoFrom returns a snapshot of the dispatch decision of request
+func DispatchInfoFrom(ctx context.Context) (DispatchInfo, bool) {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return DispatchInfo{}, false
+	}
+	info.dispatchLock.RLock()
+	defer info.dispatchLock.RUnlock()
+	return info.dispatch, true
+}
+
+// UpstreamClusterFrom returns the upstream cluster which the request is routed to
+func UpstreamClusterFrom(ctx context.Context) (string, bool) {
+	info, ok := DispatchInfoFrom(ctx)
+	return info.Cluster, ok && len(info.Cluster) > 0
+}
+
+// UpstreamEndpointFrom returns the upstream endpoint chosen for the latest try of request
+func UpstreamEndpointFrom(ctx context.Context) (string, bool) {
+	info, ok := DispatchInfoFrom(ctx)
+	return info.Endpoint, ok && len(info.Endpoint) > 0
+}
+
+// FlowControlFrom returns the name of flow control schema which the request is accounted to
+func FlowControlFrom(ctx context.Context) (string, bool) {
+	info, ok := DispatchInfoFrom(ctx)
+	return info.FlowControl, ok && len(info.FlowControl) > 0
+}
+
+// SetUpstreamCluster records the upstream cluster which the request is routed to
+func SetUpstreamCluster(ctx context.Context, cluster string) error {
+	return updateDispatchInfo(ctx, func(dispatch *DispatchInfo) {
+		dispatch.Cluster = cluster
+	})
+}
+
+// SetFlowControl records the name of flow control schema which the request is accounted to
+func SetFlowControl(ctx context.Context, flowControl string) error {
+	return updateDispatchInfo(ctx, func(dispatch *DispatchInfo) {
+		dispatch.FlowControl = flowControl
+	})
+}
+
+func updateDispatchInfo(ctx context.Context, update func(dispatch *DispatchInfo)) error {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return fmt.Errorf("no proxy info found in context")
+	}
+	info.dispatchLock.Lock()
+	defer info.dispatchLock.Unlock()
+	update(&info.dispatch)
+	return nil
+}
+
+// WithRequestID returns a copy of parent in which the request ID value is set
+func WithRequestID(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, requestIDKey, id)
+}
+
+// RequestIDFrom returns the request ID which identifies the request in logs of gateway and upstream
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok && len(id) > 0
+}
+
+// WithTraceID returns a copy of parent in which the trace ID value is set
+func WithTraceID(parent context.Context, id string) context.Context {
+	return context.WithValue(parent, traceIDKey, id)
+}
+
+// TraceIDFrom returns the W3C trace ID propagated by client, it links logs and metrics of the request to its trace
+func TraceIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(traceIDKey).(string)
+	return id, ok && len(id) > 0
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDispatchInfo(t *testing.T) {
+	if err := SetUpstreamCluster(context.Background(), "a"); err == nil {
+		t.Errorf("SetUpstreamCluster() without proxy info should fail")
+	}
+	if _, ok := DispatchInfoFrom(context.Background()); ok {
+		t.Errorf("DispatchInfoFrom() without proxy info should return false")
+	}
+
+	ctx := WithProxyInfo(context.Background(), NewProxyInfo())
+	if _, ok := UpstreamEndpointFrom(ctx); ok {
+		t.Errorf("UpstreamEndpointFrom() before dispatching should return false")
+	}
+	if err := SetUpstreamCluster(ctx, "a"); err != nil {
+		t.Fatalf("SetUpstreamCluster() error = %v", err)
+	}
+	if err := SetFlowControl(ctx, "system-default"); err != nil {
+		t.Fatalf("SetFlowControl() error = %v", err)
+	}
+	if err := SetProxyForwarded(ctx, "https://10.0.0.1:6443"); err != nil {
+		t.Fatalf("SetProxyForwarded() error = %v", err)
+	}
+	want := DispatchInfo{Cluster: "a", Endpoint: "https://10.0.0.1:6443", FlowControl: "system-default"}
+	if got, _ := DispatchInfoFrom(ctx); got != want {
+		t.Errorf("DispatchInfoFrom() = %+v, want %+v", got, want)
+	}
+	if got, _ := UpstreamClusterFrom(ctx); got != want.Cluster {
+		t.Errorf("UpstreamClusterFrom() = %v, want %v", got, want.Cluster)
+	}
+	if got, _ := FlowControlFrom(ctx); got != want.FlowControl {
+		t.Errorf("FlowControlFrom() = %v, want %v", got, want.FlowControl)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if _, ok := RequestIDFrom(context.Background()); ok {
+		t.Errorf("RequestIDFrom() without request ID should return false")
+	}
+	if got, _ := RequestIDFrom(WithRequestID(context.Background(), "abc")); got != "abc" {
+		t.Errorf("RequestIDFrom() = %v, want abc", got)
+	}
+}
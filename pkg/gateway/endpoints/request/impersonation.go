@@ -0,0 +1,37 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// ImpersonationChainAuditAnnotationKey is the audit annotation recording the impersonation chain of a request
+const ImpersonationChainAuditAnnotationKey = "proxy.kubegateway.io/impersonation-chain"
+
+// FormatImpersonationChain formats identities of chain, e.g. alice[system:authenticated] -> bob[dev,system:authenticated]
+func FormatImpersonationChain(chain []user.Info) string {
+	identities := make([]string, 0, len(chain))
+	for _, u := range chain {
+		if u == nil {
+			continue
+		}
+		identities = append(identities, fmt.Sprintf("%s[%s]", u.GetName(), strings.Join(u.GetGroups(), ",")))
+	}
+	return strings.Join(identities, " -> ")
+}
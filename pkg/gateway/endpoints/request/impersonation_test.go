@@ -0,0 +1,43 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestFormatImpersonationChain(t *testing.T) {
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"system:authenticated"}}
+	bob := &user.DefaultInfo{Name: "bob", Groups: []string{"dev", "system:authenticated"}}
+
+	tests := []struct {
+		name  string
+		chain []user.Info
+		want  string
+	}{
+		{"impersonated", []user.Info{alice, bob}, "alice[system:authenticated] -> bob[dev,system:authenticated]"},
+		{"impersonator not recorded", []user.Info{nil, bob}, "bob[dev,system:authenticated]"},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatImpersonationChain(tt.chain); got != tt.want {
+				t.Errorf("FormatImpersonationChain() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -17,18 +17,76 @@ package request
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 )
 
 // ProxyInfo contains information that indicates if the request is proxied
 type ProxyInfo struct {
 	Forwarded bool
-	Endpoint  string
 	Reason    string
+
+	// received is when the request is received by gateway, see ProxySelfLatencyFrom
+	received time.Time
+
+	// dispatch is the decision made by dispatcher, see DispatchInfoFrom
+	dispatch     DispatchInfo
+	dispatchLock sync.RWMutex
+
+	// attempts records all failed tries to upstream endpoints
+	attempts          []UpstreamAttempt
+	attemptStart      time.Time
+	firstAttemptStart time.Time
+	// startedAttempts is the number of tries to upstream endpoints, including the first one
+	startedAttempts int
+	attemptsLock    sync.Mutex
+
+	// phases records where the time of the request is spent, see MarkProxyPhase
+	phases     []ProxyPhaseDuration
+	phaseMark  time.Time
+	phasesLock sync.Mutex
+}
+
+// ProxyPhase is a phase of proxying request, all phases but ProxyPhaseUpstream are gateway overhead
+type ProxyPhase string
+
+const (
+	// ProxyPhaseAuthentication is the handler chain before dispatcher, dominated by authentication,
+	// impersonation and audit
+	ProxyPhaseAuthentication ProxyPhase = "authentication"
+	// ProxyPhaseRouting is looking up upstream cluster and checking cluster policies
+	ProxyPhaseRouting ProxyPhase = "routing"
+	// ProxyPhaseQueueing is waiting for ceilings, resource budgets and flow controls
+	ProxyPhaseQueueing ProxyPhase = "queueing"
+	// ProxyPhaseRequestProcessing is picking endpoint and building request and headers for upstream
+	ProxyPhaseRequestProcessing ProxyPhase = "request_processing"
+	// ProxyPhaseUpstream is from the first try to upstream until the response header is written,
+	// including all retries
+	ProxyPhaseUpstream ProxyPhase = "upstream"
+)
+
+// ProxyPhaseDuration is the time spent in a phase of proxying request
+type ProxyPhaseDuration struct {
+	Phase    ProxyPhase
+	Duration time.Duration
+}
+
+// UpstreamAttempt is a summary of one failed try of proxying request to an upstream endpoint
+type UpstreamAttempt struct {
+	Endpoint   string
+	Duration   time.Duration
+	ErrorClass string
+	Message    string
+}
+
+func (a UpstreamAttempt) String() string {
+	return fmt.Sprintf("endpoint=%s duration=%v class=%s message=%q", a.Endpoint, a.Duration.Round(time.Millisecond), a.ErrorClass, a.Message)
 }
 
 func NewProxyInfo() *ProxyInfo {
 	return &ProxyInfo{
 		Forwarded: false,
+		received:  time.Now(),
 	}
 }
 
@@ -49,7 +107,9 @@ func SetProxyForwarded(ctx context.Context, endpoint string) error {
 		return fmt.Errorf("no proxy info found in context")
 	}
 	info.Forwarded = true
-	info.Endpoint = endpoint
+	info.dispatchLock.Lock()
+	info.dispatch.Endpoint = endpoint
+	info.dispatchLock.Unlock()
 	return nil
 }
 
@@ -70,3 +130,127 @@ func IsProxyForwarded(ctx context.Context) bool {
 	}
 	return proxyInfo.Forwarded
 }
+
+// StartProxyAttempt marks the beginning of a try to upstream endpoint
+func StartProxyAttempt(ctx context.Context) error {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return fmt.Errorf("no proxy info found in context")
+	}
+	info.attemptsLock.Lock()
+	defer info.attemptsLock.Unlock()
+	info.attemptStart = time.Now()
+	if info.firstAttemptStart.IsZero() {
+		info.firstAttemptStart = info.attemptStart
+	}
+	info.startedAttempts++
+	return nil
+}
+
+// ProxyRetriesFrom returns the number of tries to upstream endpoints after the first one
+func ProxyRetriesFrom(ctx context.Context) int {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return 0
+	}
+	info.attemptsLock.Lock()
+	defer info.attemptsLock.Unlock()
+	if info.startedAttempts <= 1 {
+		return 0
+	}
+	return info.startedAttempts - 1
+}
+
+// ProxySelfLatencyFrom returns the time spent by gateway itself before the first try to
+// upstream endpoint started, it returns false if the request is not tried yet.
+func ProxySelfLatencyFrom(ctx context.Context) (time.Duration, bool) {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return 0, false
+	}
+	info.attemptsLock.Lock()
+	defer info.attemptsLock.Unlock()
+	if info.received.IsZero() || info.firstAttemptStart.IsZero() {
+		return 0, false
+	}
+	return info.firstAttemptStart.Sub(info.received), true
+}
+
+// MarkProxyPhase marks the end of phase, the time since the end of last phase (or receiving
+// the request) is spent in phase. Phases are marked in the order they happen.
+func MarkProxyPhase(ctx context.Context, phase ProxyPhase) error {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return fmt.Errorf("no proxy info found in context")
+	}
+	now := time.Now()
+	info.phasesLock.Lock()
+	defer info.phasesLock.Unlock()
+	last := info.phaseMark
+	if last.IsZero() {
+		last = info.received
+	}
+	info.phaseMark = now
+	for i := range info.phases {
+		if info.phases[i].Phase == phase {
+			info.phases[i].Duration += now.Sub(last)
+			return nil
+		}
+	}
+	info.phases = append(info.phases, ProxyPhaseDuration{Phase: phase, Duration: now.Sub(last)})
+	return nil
+}
+
+// ProxyPhasesFrom returns the time spent in each marked phase of the request in order
+func ProxyPhasesFrom(ctx context.Context) []ProxyPhaseDuration {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return nil
+	}
+	info.phasesLock.Lock()
+	defer info.phasesLock.Unlock()
+	if len(info.phases) == 0 {
+		return nil
+	}
+	phases := make([]ProxyPhaseDuration, len(info.phases))
+	copy(phases, info.phases)
+	return phases
+}
+
+// FailProxyAttempt records the current try to endpoint failed with errorClass
+func FailProxyAttempt(ctx context.Context, endpoint, errorClass, message string) error {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return fmt.Errorf("no proxy info found in context")
+	}
+	info.attemptsLock.Lock()
+	defer info.attemptsLock.Unlock()
+	var duration time.Duration
+	if !info.attemptStart.IsZero() {
+		duration = time.Since(info.attemptStart)
+	}
+	info.attempts = append(info.attempts, UpstreamAttempt{
+		Endpoint:   endpoint,
+		Duration:   duration,
+		ErrorClass: errorClass,
+		Message:    message,
+	})
+	info.attemptStart = time.Time{}
+	return nil
+}
+
+// ProxyAttemptsFrom returns all failed tries of the request
+func ProxyAttemptsFrom(ctx context.Context) []UpstreamAttempt {
+	info, ok := ExtraProxyInfoFrom(ctx)
+	if !ok {
+		return nil
+	}
+	info.attemptsLock.Lock()
+	defer info.attemptsLock.Unlock()
+	if len(info.attempts) == 0 {
+		return nil
+	}
+	attempts := make([]UpstreamAttempt, len(info.attempts))
+	copy(attempts, info.attempts)
+	return attempts
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package request
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProxyPhases(t *testing.T) {
+	if err := MarkProxyPhase(context.Background(), ProxyPhaseRouting); err == nil {
+		t.Errorf("MarkProxyPhase() without proxy info should fail")
+	}
+
+	ctx := WithProxyInfo(context.Background(), NewProxyInfo())
+	if phases := ProxyPhasesFrom(ctx); phases != nil {
+		t.Errorf("ProxyPhasesFrom() before marking = %v, want nil", phases)
+	}
+	time.Sleep(time.Millisecond)
+	for _, phase := range []ProxyPhase{ProxyPhaseAuthentication, ProxyPhaseRouting, ProxyPhaseUpstream, ProxyPhaseUpstream} {
+		if err := MarkProxyPhase(ctx, phase); err != nil {
+			t.Fatalf("MarkProxyPhase() error = %v", err)
+		}
+	}
+
+	phases := ProxyPhasesFrom(ctx)
+	want := []ProxyPhase{ProxyPhaseAuthentication, ProxyPhaseRouting, ProxyPhaseUpstream}
+	if len(phases) != len(want) {
+		t.Fatalf("ProxyPhasesFrom() = %v, want phases %v", phases, want)
+	}
+	for i, phase := range phases {
+		if phase.Phase != want[i] {
+			t.Errorf("phase %d = %v, want %v", i, phase.Phase, want[i])
+		}
+	}
+	if phases[0].Duration < time.Millisecond {
+		t.Errorf("duration of first phase = %v, want time since request received", phases[0].Duration)
+	}
+}
+
+func TestProxyRetriesFrom(t *testing.T) {
+	ctx := WithProxyInfo(context.Background(), NewProxyInfo())
+	if retries := ProxyRetriesFrom(ctx); retries != 0 {
+		t.Errorf("ProxyRetriesFrom() before any attempt = %v, want 0", retries)
+	}
+	// the first attempt fails and is not retried
+	StartProxyAttempt(ctx)                                           //nolint
+	FailProxyAttempt(ctx, "https://a:6443", "ConnectionRefused", "") //nolint
+	if retries, attempts := ProxyRetriesFrom(ctx), len(ProxyAttemptsFrom(ctx)); retries != 0 || attempts != 1 {
+		t.Errorf("ProxyRetriesFrom() = %v with %v failed attempts, want 0 retries and 1 failed attempt", retries, attempts)
+	}
+	// retried on another endpoint
+	StartProxyAttempt(ctx) //nolint
+	if retries := ProxyRetriesFrom(ctx); retries != 1 {
+		t.Errorf("ProxyRetriesFrom() after retry = %v, want 1", retries)
+	}
+}
@@ -32,6 +32,15 @@ const (
 
 	// proxyInfoKey is the context key for the proxy info.
 	proxyInfoKey key = iota
+
+	// rawAuthorizationKey is the context key for the client Authorization header.
+	rawAuthorizationKey key = iota
+
+	// requestIDKey is the context key for the request ID.
+	requestIDKey key = iota
+
+	// traceIDKey is the context key for the trace ID propagated by client.
+	traceIDKey key = iota
 )
 
 type ExtraRequestInfoResolver interface {
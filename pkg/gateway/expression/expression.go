@@ -0,0 +1,265 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expression compiles policies of requests written in the Common Expression Language (CEL)
+// by cel-go, e.g. request.verb == 'delete' && request.resource == 'nodes' && !('system:masters' in user.groups).
+//
+// Variables are the fields of request and user, see Request and User. Besides standard CEL functions,
+// string extensions of cel-go such as lowerAscii are supported. Expressions are type checked when
+// compiled, compiled programs are cached by source, and each evaluation is bounded by CostLimit
+// in cost units of CEL.
+package expression
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/interpreter"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+const (
+	// DefaultCostLimit is the default maximum cost of evaluating an expression once, most operations
+	// cost 1, functions scanning strings or lists cost more by their sizes
+	DefaultCostLimit int64 = 1000
+
+	// MaxLength is the maximum length of source of an expression
+	MaxLength = 4096
+
+	// number of compiled programs cached and how long they are cached
+	cacheSize = 1024
+	cacheTTL  = time.Hour
+)
+
+var (
+	ErrCostLimitExceeded = errors.New("cost limit exceeded")
+
+	programs = cache.NewLRUExpireCache(cacheSize)
+
+	env = newEnv()
+)
+
+// newEnv returns the CEL environment of expressions. Fields of request and user are declared as
+// qualified names, so the checker rejects unknown fields without protobuf types of variables.
+// List literals must be homogeneous, the same as the type system of Request and User.
+func newEnv() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.HomogeneousAggregateLiterals(),
+		ext.Strings(),
+		cel.Declarations(
+			decls.NewVar("request.verb", decls.String),
+			decls.NewVar("request.apiGroup", decls.String),
+			decls.NewVar("request.apiVersion", decls.String),
+			decls.NewVar("request.resource", decls.String),
+			decls.NewVar("request.subresource", decls.String),
+			decls.NewVar("request.namespace", decls.String),
+			decls.NewVar("request.name", decls.String),
+			decls.NewVar("request.path", decls.String),
+			decls.NewVar("request.userAgent", decls.String),
+			decls.NewVar("request.resourceRequest", decls.Bool),
+			decls.NewVar("request.readOnly", decls.Bool),
+			decls.NewVar("user.name", decls.String),
+			decls.NewVar("user.uid", decls.String),
+			decls.NewVar("user.groups", decls.NewListType(decls.String)),
+		),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create cel environment: %v", err))
+	}
+	return env
+}
+
+// Program is a compiled expression, it is safe for concurrent use
+type Program struct {
+	source  string
+	limit   int64
+	program cel.Program
+}
+
+// programKey identifies a cached program, the cost limit is built into programs
+type programKey struct {
+	source string
+	limit  int64
+}
+
+// Compile parses and type checks expr, which must evaluate to a bool. limit is the maximum cost of
+// evaluating it once, expressions whose estimated minimum cost exceeds limit never succeed and are
+// rejected. Compiled programs are cached, so the same expression is compiled only once for a limit.
+func Compile(expr string, limit int64) (*Program, error) {
+	key := programKey{source: expr, limit: limit}
+	if cached, ok := programs.Get(key); ok {
+		return cached.(*Program), nil
+	}
+	if len(expr) == 0 {
+		return nil, fmt.Errorf("expression must not be empty")
+	}
+	if len(expr) > MaxLength {
+		return nil, fmt.Errorf("expression is longer than %d bytes", MaxLength)
+	}
+	ast, issues := env.Compile(expr)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.ResultType().GetPrimitive() != decls.Bool.GetPrimitive() {
+		return nil, fmt.Errorf("expression must evaluate to bool, got %v", checker.FormatCheckedType(ast.ResultType()))
+	}
+	estimate, err := env.EstimateCost(ast, costEstimator{})
+	if err != nil {
+		return nil, err
+	}
+	if estimate.Min > uint64(limit) {
+		return nil, fmt.Errorf("expression costs at least %d, it exceeds the limit %d", estimate.Min, limit)
+	}
+	// regular expressions of literals are compiled and validated here by OptOptimize
+	program, err := env.Program(ast, cel.EvalOptions(cel.OptOptimize, cel.OptTrackCost), cel.CostLimit(uint64(limit)))
+	if err != nil {
+		return nil, err
+	}
+	p := &Program{source: expr, limit: limit, program: program}
+	programs.Add(key, p, cacheTTL)
+	return p, nil
+}
+
+// String returns the source of program
+func (p *Program) String() string {
+	return p.source
+}
+
+// Eval evaluates program against act, it returns ErrCostLimitExceeded if evaluation costs more than the cost limit it is compiled with
+func (p *Program) Eval(act *Activation) (bool, error) {
+	out, details, err := p.program.Eval(activation{act})
+	if err != nil {
+		if details != nil && details.ActualCost() != nil && *details.ActualCost() > uint64(p.limit) {
+			return false, ErrCostLimitExceeded
+		}
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression evaluates to %v, not bool", out)
+	}
+	return matched, nil
+}
+
+// costEstimator has no more knowledge than the checker, sizes of request fields are unbounded,
+// so only the estimated minimum cost is meaningful.
+type costEstimator struct{}
+
+func (costEstimator) EstimateSize(element checker.AstNode) *checker.SizeEstimate {
+	return nil
+}
+
+func (costEstimator) EstimateCallCost(function, overloadID string, target *checker.AstNode, args []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+// Request is the request variable of expressions
+type Request struct {
+	Verb            string
+	APIGroup        string
+	APIVersion      string
+	Resource        string
+	Subresource     string
+	Namespace       string
+	Name            string
+	Path            string
+	UserAgent       string
+	ResourceRequest bool
+	ReadOnly        bool
+}
+
+// User is the user variable of expressions
+type User struct {
+	Name   string
+	UID    string
+	Groups []string
+}
+
+// Activation binds variables of expressions for one request
+type Activation struct {
+	Request Request
+	User    User
+}
+
+// NewActivation returns the activation of request with attributes and User-Agent
+func NewActivation(attributes authorizer.Attributes, userAgent string) *Activation {
+	act := &Activation{
+		Request: Request{
+			Verb:            attributes.GetVerb(),
+			APIGroup:        attributes.GetAPIGroup(),
+			APIVersion:      attributes.GetAPIVersion(),
+			Resource:        attributes.GetResource(),
+			Subresource:     attributes.GetSubresource(),
+			Namespace:       attributes.GetNamespace(),
+			Name:            attributes.GetName(),
+			Path:            attributes.GetPath(),
+			UserAgent:       userAgent,
+			ResourceRequest: attributes.IsResourceRequest(),
+			ReadOnly:        attributes.IsReadOnly(),
+		},
+	}
+	if user := attributes.GetUser(); user != nil {
+		act.User = User{Name: user.GetName(), UID: user.GetUID(), Groups: user.GetGroups()}
+	}
+	return act
+}
+
+// activation resolves variables declared by newEnv from Activation, without building a map for each evaluation
+type activation struct {
+	*Activation
+}
+
+func (a activation) ResolveName(name string) (interface{}, bool) {
+	switch name {
+	case "request.verb":
+		return a.Request.Verb, true
+	case "request.apiGroup":
+		return a.Request.APIGroup, true
+	case "request.apiVersion":
+		return a.Request.APIVersion, true
+	case "request.resource":
+		return a.Request.Resource, true
+	case "request.subresource":
+		return a.Request.Subresource, true
+	case "request.namespace":
+		return a.Request.Namespace, true
+	case "request.name":
+		return a.Request.Name, true
+	case "request.path":
+		return a.Request.Path, true
+	case "request.userAgent":
+		return a.Request.UserAgent, true
+	case "request.resourceRequest":
+		return a.Request.ResourceRequest, true
+	case "request.readOnly":
+		return a.Request.ReadOnly, true
+	case "user.name":
+		return a.User.Name, true
+	case "user.uid":
+		return a.User.UID, true
+	case "user.groups":
+		return a.User.Groups, true
+	}
+	return nil, false
+}
+
+func (a activation) Parent() interpreter.Activation {
+	return nil
+}
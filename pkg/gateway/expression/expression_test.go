@@ -0,0 +1,135 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{`request.verb == 'delete' && request.resource == 'nodes' && !('system:masters' in user.groups)`, false},
+		{`request.path.startsWith("/apis/") || size(user.groups) > 2 ? true : false`, false},
+		{`request.userAgent.matches('^kubectl/v1\\.1[0-8]\\.')`, false},
+		{`request.namespace in ['kube-system', 'kube-public'] && user.groups[0] != ''`, false},
+		{``, true},
+		{`request.verb`, true},
+		{`request.unknown == 'x'`, true},
+		{`unknown == 'x'`, true},
+		{`request.verb == 1`, true},
+		{`request.verb == 'a' == true`, false},
+		{`request.userAgent.matches(request.verb)`, false},
+		{`request.verb.lowerAscii() == 'get' && user.uid.size() > 0`, false},
+		{`request.userAgent.matches('(')`, true},
+		{`['a', 1] == []`, true},
+		{`undefined(request.verb)`, true},
+		{`request.verb == 'unterminated`, true},
+		{`request.verb == 'a' &&`, true},
+		{`request.verb == 'a' )`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if _, err := Compile(tt.expr, DefaultCostLimit); (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProgram_Eval(t *testing.T) {
+	act := NewActivation(authorizer.AttributesRecord{
+		User:            &user.DefaultInfo{Name: "alice", Groups: []string{"dev", "system:authenticated"}},
+		Verb:            "delete",
+		Namespace:       "default",
+		Resource:        "nodes",
+		Name:            "node-1",
+		ResourceRequest: true,
+		Path:            "/api/v1/nodes/node-1",
+	}, "kubectl/v1.18.19 (linux/amd64)")
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`request.verb == 'delete' && request.resource == 'nodes' && !('system:masters' in user.groups)`, true},
+		{`'dev' in user.groups && user.name != "bob"`, true},
+		{`request.readOnly || !request.resourceRequest`, false},
+		{`request.userAgent.matches('^kubectl/v1\\.1[0-8]\\.')`, true},
+		{`request.path.endsWith('node-1') && request.name.contains('-')`, true},
+		{`size(user.groups) == 2 && user.groups.size() - 1 == 1 && user.groups[1] == 'system:authenticated'`, true},
+		{`request.namespace in ['kube-system', 'kube-public']`, false},
+		{`(request.name + '-x').lowerAscii() == 'node-1-x'`, true},
+		{`request.verb > 'create' && -1 < 0`, true},
+		{`request.verb == 'get' ? request.name == 'x' : request.name == 'node-1'`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			p, err := Compile(tt.expr, DefaultCostLimit)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			got, err := p.Eval(act)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// index out of range is a runtime error
+	p, _ := Compile(`user.groups[5] == 'x'`, DefaultCostLimit)
+	if _, err := p.Eval(act); err == nil {
+		t.Errorf("Eval() of index out of range should fail")
+	}
+}
+
+func TestCostLimit(t *testing.T) {
+	// too many nodes to ever fit the limit
+	if _, err := Compile(strings.Repeat("request.verb + ", 50)+"request.verb == ''", 50); err == nil {
+		t.Errorf("Compile() of expression exceeding cost limit should fail")
+	}
+
+	p, err := Compile(`request.path.matches('^/api/v1/namespaces/')`, 50)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	short := &Activation{Request: Request{Path: "/api/v1/namespaces/"}}
+	if matched, err := p.Eval(short); err != nil || !matched {
+		t.Errorf("Eval() of short path = %v, %v, want true", matched, err)
+	}
+	long := &Activation{Request: Request{Path: "/api/v1/namespaces/" + strings.Repeat("x", 1000)}}
+	if _, err := p.Eval(long); err != ErrCostLimitExceeded {
+		t.Errorf("Eval() of long path error = %v, want %v", err, ErrCostLimitExceeded)
+	}
+}
+
+func TestCompileCache(t *testing.T) {
+	a, err := Compile(`request.verb == 'watch'`, DefaultCostLimit)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	b, _ := Compile(`request.verb == 'watch'`, DefaultCostLimit)
+	if a != b {
+		t.Errorf("Compile() of the same expression should return the cached program")
+	}
+}
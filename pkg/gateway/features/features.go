@@ -0,0 +1,100 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package features defines gateway-wide feature gates for experimental capabilities.
+// They are registered into the global k8s feature gate, so they can be set by
+// --feature-gates=FleetFanout=true,... like other kubernetes features.
+//
+// Features of each upstream cluster are defined in pkg/clusters/features.
+package features
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/runtime"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/component-base/featuregate"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// Fan out read requests of fleet hostname to member clusters, see --proxy-fleet-hostname
+	FleetFanout featuregate.Feature = "FleetFanout"
+
+	// Enforce per cluster resource budget, see --proxy-max-inflight-requests-per-cluster
+	ClusterResourceBudget featuregate.Feature = "ClusterResourceBudget"
+
+	// Authenticate to upstream clusters with token files or exec credential plugins allowed
+	// by --proxy-upstream-credentials-file, see pkg/clusters/credential.go
+	UpstreamCredentialPlugins featuregate.Feature = "UpstreamCredentialPlugins"
+
+	// Pick endpoints randomly weighted by score of latency, error rate, health check
+	// freshness and concurrency headroom instead of round robin, see pkg/clusters/score.go
+	EndpointScoring featuregate.Feature = "EndpointScoring"
+
+	// Inject latency, errors or connection resets into requests to upstream clusters by
+	// admin API /debug/gateway/faults, see pkg/clusters/fault.go. Never enable it in production.
+	FaultInjection featuregate.Feature = "FaultInjection"
+)
+
+var (
+	// defaultGatewayFeatureGates consists of all known gateway feature keys.
+	// To add a new feature, define a key for it above and add it here.
+	defaultGatewayFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+		FleetFanout:               {Default: false, PreRelease: featuregate.Alpha},
+		ClusterResourceBudget:     {Default: true, PreRelease: featuregate.Beta},
+		UpstreamCredentialPlugins: {Default: false, PreRelease: featuregate.Alpha},
+		EndpointScoring:           {Default: false, PreRelease: featuregate.Alpha},
+		FaultInjection:            {Default: false, PreRelease: featuregate.Alpha},
+	}
+)
+
+func init() {
+	runtime.Must(utilfeature.DefaultMutableFeatureGate.Add(defaultGatewayFeatureGates))
+}
+
+// Enabled returns true if the gateway feature is enabled
+func Enabled(key featuregate.Feature) bool {
+	return utilfeature.DefaultFeatureGate.Enabled(key)
+}
+
+// KnownFeatures returns all gateway features sorted by name
+func KnownFeatures() []featuregate.Feature {
+	keys := make([]featuregate.Feature, 0, len(defaultGatewayFeatureGates))
+	for k := range defaultGatewayFeatureGates {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// Stage returns the pre-release stage of gateway feature, e.g. ALPHA, BETA, GA
+func Stage(key featuregate.Feature) string {
+	stage := string(defaultGatewayFeatureGates[key].PreRelease)
+	if len(stage) == 0 {
+		return "GA"
+	}
+	return stage
+}
+
+// RecordMetrics exposes enabled state of all gateway features, it should be
+// called after feature gates are set from command line or configuration file.
+func RecordMetrics() {
+	for _, key := range KnownFeatures() {
+		metrics.RecordFeatureGate(string(key), Stage(key), Enabled(key))
+	}
+}
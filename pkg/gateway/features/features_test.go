@@ -0,0 +1,43 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package features
+
+import (
+	"testing"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+)
+
+func TestKnownFeatures(t *testing.T) {
+	known := utilfeature.DefaultFeatureGate.KnownFeatures()
+	for _, key := range KnownFeatures() {
+		found := false
+		for _, desc := range known {
+			if len(desc) > len(key) && desc[:len(key)+1] == string(key)+"=" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("feature %v is not registered into default feature gate", key)
+		}
+		if stage := Stage(key); len(stage) == 0 {
+			t.Errorf("feature %v has empty stage", key)
+		}
+		if Enabled(key) != defaultGatewayFeatureGates[key].Default {
+			t.Errorf("feature %v enabled = %v, want default %v", key, Enabled(key), defaultGatewayFeatureGates[key].Default)
+		}
+	}
+}
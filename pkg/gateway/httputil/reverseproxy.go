@@ -65,6 +65,14 @@ type ReverseProxy struct {
 	// are flushed to the client immediately.
 	FlushInterval time.Duration
 
+	// Streaming marks the response as a stream, e.g. a watch or
+	// followed logs, whose writes are flushed to the client
+	// immediately if FlushInterval is zero. Streams are told by the
+	// caller from the request rather than by an unknown ContentLength,
+	// so chunked responses of other requests are flushed periodically.
+	// Server-Sent Events are always flushed immediately.
+	Streaming bool
+
 	// ErrorLog specifies an optional logger for errors
 	// that occur when attempting to proxy the request.
 	// If nil, logging is done via the log package's standard logger.
@@ -417,8 +425,7 @@ func (p *ReverseProxy) flushInterval(res *http.Response) time.Duration {
 		return -1 // negative means immediately
 	}
 
-	// We might have the case of streaming for which Content-Length might be unset.
-	if res.ContentLength == -1 {
+	if p.Streaming && p.FlushInterval == 0 {
 		return -1
 	}
 
@@ -0,0 +1,163 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	utilsets "k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	// otherLabelValue aggregates label values beyond cardinality limits
+	otherLabelValue = "other"
+	// aggregatedEndpoint replaces endpoint label values if endpoint labels are disabled
+	aggregatedEndpoint = "all"
+)
+
+// CardinalityLimits bounds the number of series of high cardinality labels, so that labeling by
+// user across thousands of service accounts or by endpoint across thousands of clusters can not
+// explode Prometheus.
+type CardinalityLimits struct {
+	// MaxUsersPerCluster is the number of users with the most requests to each cluster in the last
+	// UserWindow which have their own user label values, other users are aggregated as "other".
+	// Zero means all users are aggregated.
+	MaxUsersPerCluster int
+	// MaxClientsPerCluster is the number of User-Agent components, e.g. kubectl, with the most requests to
+	// each cluster in the last UserWindow which have their own client label values, other clients are
+	// aggregated as "other". Zero means all clients are aggregated.
+	MaxClientsPerCluster int
+	// UserWindow is how often top users and clients are recomputed, series of users and clients leaving
+	// them are deleted
+	UserWindow time.Duration
+	// DisableEndpointLabels aggregates request metrics of all endpoints of a cluster as endpoint "all",
+	// health and probe metrics of endpoints are not affected.
+	DisableEndpointLabels bool
+}
+
+// DefaultCardinalityLimits are the cardinality limits of all metrics. Every recorded request reads them
+// without locking, and series already created under other limits are not relabeled, so they are set
+// before the first request is proxied.
+var DefaultCardinalityLimits = CardinalityLimits{
+	MaxUsersPerCluster:   20,
+	MaxClientsPerCluster: 20,
+	UserWindow:           10 * time.Minute,
+}
+
+// endpointLabel returns the endpoint label value of request metrics
+func endpointLabel(endpoint string) string {
+	if DefaultCardinalityLimits.DisableEndpointLabels {
+		return aggregatedEndpoint
+	}
+	return endpoint
+}
+
+var (
+	// topUsers of each cluster, keyed by server name
+	clusterTopUsers sync.Map
+	// top clients of each cluster, keyed by server name. Clients come from User-Agent which is chosen
+	// by callers, so they are bounded the same way as users.
+	clusterTopClients sync.Map
+)
+
+// userLabel returns the user label value of user for metrics of cluster, evict is called with
+// users leaving top users so their series can be deleted.
+func userLabel(serverName, user string, evict func(user string)) string {
+	limits := DefaultCardinalityLimits
+	return topLabel(&clusterTopUsers, serverName, user, limits.MaxUsersPerCluster, limits.UserWindow, evict)
+}
+
+// clientLabel returns the client label value of client for metrics of cluster, evict is called with
+// clients leaving top clients so their series can be deleted.
+func clientLabel(serverName, client string, evict func(client string)) string {
+	limits := DefaultCardinalityLimits
+	return topLabel(&clusterTopClients, serverName, client, limits.MaxClientsPerCluster, limits.UserWindow, evict)
+}
+
+func topLabel(tops *sync.Map, serverName, value string, k int, window time.Duration, evict func(value string)) string {
+	if k <= 0 {
+		return otherLabelValue
+	}
+	v, _ := tops.LoadOrStore(serverName, newTopUsers())
+	label, evicted := v.(*topUsers).label(value, k, window, time.Now())
+	for _, e := range evicted {
+		evict(e)
+	}
+	return label
+}
+
+// topUsers tracks the users with the most requests in the current window, users are admitted to
+// free slots at once and the top users are recomputed at the end of each window.
+type topUsers struct {
+	mu      sync.Mutex
+	counts  map[string]int64
+	top     utilsets.String
+	resetAt time.Time
+}
+
+func newTopUsers() *topUsers {
+	return &topUsers{
+		counts: map[string]int64{},
+		top:    utilsets.NewString(),
+	}
+}
+
+func (t *topUsers) label(user string, k int, window time.Duration, now time.Time) (string, []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var evicted []string
+	if t.resetAt.IsZero() {
+		t.resetAt = now.Add(window)
+	} else if !now.Before(t.resetAt) {
+		evicted = t.recomputeLocked(k)
+		t.resetAt = now.Add(window)
+	}
+
+	t.counts[user]++
+	if t.top.Has(user) {
+		return user, evicted
+	}
+	if t.top.Len() < k {
+		t.top.Insert(user)
+		return user, evicted
+	}
+	return otherLabelValue, evicted
+}
+
+// recomputeLocked keeps the k users with the most requests in the last window and starts a new window,
+// it returns the users removed from top users.
+func (t *topUsers) recomputeLocked(k int) []string {
+	users := make([]string, 0, len(t.counts))
+	for u := range t.counts {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if t.counts[users[i]] != t.counts[users[j]] {
+			return t.counts[users[i]] > t.counts[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	if len(users) > k {
+		users = users[:k]
+	}
+	top := utilsets.NewString(users...)
+	evicted := t.top.Difference(top).List()
+	t.top = top
+	t.counts = map[string]int64{}
+	return evicted
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_topUsers_label(t *testing.T) {
+	window := time.Minute
+	now := time.Now()
+	top := newTopUsers()
+
+	for _, u := range []string{"a", "b"} {
+		if got, _ := top.label(u, 2, window, now); got != u {
+			t.Errorf("label(%q) = %q, want own label in free slots", u, got)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if got, _ := top.label("c", 2, window, now); got != otherLabelValue {
+			t.Errorf("label(c) = %q, want %q beyond limit", got, otherLabelValue)
+		}
+	}
+	top.label("a", 2, window, now)
+
+	// c has more requests than b in the last window
+	got, evicted := top.label("c", 2, window, now.Add(window))
+	if got != "c" {
+		t.Errorf("label(c) = %q after recompute, want c", got)
+	}
+	if !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Errorf("label() evicted = %v, want [b]", evicted)
+	}
+	if got, _ := top.label("b", 2, window, now.Add(window)); got != otherLabelValue {
+		t.Errorf("label(b) = %q after evicted, want %q", got, otherLabelValue)
+	}
+}
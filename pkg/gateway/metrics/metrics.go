@@ -15,6 +15,7 @@
 package metrics
 
 import (
+	"crypto/tls"
 	"net/http"
 	"os"
 	"strconv"
@@ -31,8 +32,10 @@ import (
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	compbasemetrics "k8s.io/component-base/metrics"
 
+	gatewayrequest "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
 	metricsregistry "github.com/kubewharf/kubegateway/pkg/gateway/metrics/registry"
 	"github.com/kubewharf/kubegateway/pkg/gateway/net"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
 )
 
 const (
@@ -40,6 +43,9 @@ const (
 
 	namespace = "kubegateway"
 	subsystem = "proxy"
+
+	flowControlNamespace = "apiserver"
+	flowControlSubsystem = "flowcontrol"
 )
 
 var (
@@ -83,8 +89,11 @@ var (
 		},
 		[]string{"pid", "serverName", "endpoint", "verb", "resource", "code"},
 	)
-	proxyRequestLatencies = compbasemetrics.NewHistogramVec(
-		&compbasemetrics.HistogramOpts{
+	// proxyRequestLatencies is also exposed as a native histogram to scrapers negotiating protobuf, and its
+	// observations carry trace IDs as exemplars. Metric stability framework of component-base supports
+	// neither, so it is a raw prometheus collector.
+	proxyRequestLatencies = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
 			Namespace: namespace,
 			Subsystem: subsystem,
 			Name:      "apiserver_request_duration_seconds",
@@ -94,7 +103,11 @@ var (
 			// Thus we customize buckets significantly, to empower both usecases.
 			Buckets: []float64{0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.35, 0.4, 0.45, 0.5, 0.6, 0.7, 0.8, 0.9, 1.0,
 				1.25, 1.5, 1.75, 2.0, 2.5, 3.0, 3.5, 4.0, 4.5, 5, 6, 7, 8, 9, 10, 15, 20, 25, 30, 40, 50, 60, 120, 180, 240, 300},
-			StabilityLevel: compbasemetrics.ALPHA,
+			// buckets of native histogram grow by at most 10%, the resolution is reduced if there are more
+			// buckets than the max, and the histogram is reset at most once an hour to restore it
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  160,
+			NativeHistogramMinResetDuration: time.Hour,
 		},
 		[]string{"pid", "serverName", "endpoint", "verb", "resource"},
 	)
@@ -120,6 +133,16 @@ var (
 		},
 		[]string{"pid", "serverName", "endpoint", "reason"},
 	)
+	proxyUpstreamHealthFlaps = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_health_flaps_total",
+			Help:           "Number of upstream endpoint health transitions reversing the previous one within a short window",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint"},
+	)
 	proxyRequestTerminationsTotal = compbasemetrics.NewCounterVec(
 		&compbasemetrics.CounterOpts{
 			Namespace:      namespace,
@@ -128,7 +151,7 @@ var (
 			Help:           "Number of requests which proxy terminated in self-defense.",
 			StabilityLevel: compbasemetrics.ALPHA,
 		},
-		[]string{"pid", "serverName", "verb", "path", "code", "reason", "resource"},
+		[]string{"pid", "serverName", "verb", "path", "code", "reason", "rejection", "resource"},
 	)
 	// proxyRegisteredWatchers is a number of currently registered watchers splitted by resource.
 	proxyRegisteredWatchers = compbasemetrics.NewGaugeVec(
@@ -141,15 +164,712 @@ var (
 		},
 		[]string{"pid", "serverName", "endpoint", "resource"},
 	)
+	proxyClusterBudgetInflight = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_budget_inflight",
+			Help:           "Number of currently held resources of each upstream cluster's resource budget",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "budget"},
+	)
+	proxyClusterBudgetLimit = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_budget_limit",
+			Help:           "Limit of each upstream cluster's resource budget, 0 means unlimited",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "budget"},
+	)
+	proxyClusterBudgetRejected = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_budget_rejected_total",
+			Help:           "Number of requests, dials or watches rejected because upstream cluster's resource budget is exhausted",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "budget"},
+	)
+	proxyClusterBudgetQueueWait = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_budget_queue_wait_duration_seconds",
+			Help:           "Duration requests waited in priority queues for upstream cluster's resource budget",
+			Buckets:        []float64{0.001, 0.005, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priority", "admitted"},
+	)
+	proxyUpstreamRetries = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_retries_total",
+			Help:           "Number of requests retried by gateway, partitioned by retriable upstream response code or connection setup error",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "reason"},
+	)
+	proxyUpstreamAborted = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_aborted_total",
+			Help:           "Number of upstream requests aborted before completion, partitioned by whether downstream client or upstream caused it",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "reason"},
+	)
+	proxyExemptedRequests = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "rate_limit_exempted_requests_total",
+			Help:           "Number of requests from exempted clients which bypass flow control and resource budget",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName"},
+	)
+	proxyTLSHandshakes = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "tls_handshakes_total",
+			Help:           "Number of completed TLS handshakes by negotiated version and cipher suite, side is listener or upstream",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "side", "name", "version", "cipher"},
+	)
+	proxyListenerConnections = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "listener_connections_total",
+			Help:           "Number of accepted client connections by listener and negotiated protocol, e.g. h2, http/1.1 and h2c",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "listener", "protocol"},
+	)
+	proxyUpstreamPrewarms = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_prewarmed_connections_total",
+			Help:           "Number of upstream connections established in advance after endpoints became healthy",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "result"},
+	)
+	proxyDrainedSessions = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "drain_closed_sessions_total",
+			Help:           "Number of sessions closed by gateway when draining them in priority order during termination",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "class"},
+	)
+	proxyWatchResumeHints = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "watch_resume_hints_total",
+			Help:           "Number of watches closed by gateway with a BOOKMARK event carrying the last known resourceVersion",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName"},
+	)
+	proxyAuditOnlyRejections = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "audit_only_rejections_total",
+			Help:           "Number of requests which would be rejected by audit-only rules of each upstream cluster but are still proxied",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "rule"},
+	)
+	proxyUpstreamIdentityMismatches = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_identity_mismatches_total",
+			Help:           "Number of TLS handshakes to upstream endpoints aborted because the certificate does not match the pinned identity",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint"},
+	)
+	proxyUpgradeHandshakeFailures = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upgrade_handshake_failures_total",
+			Help:           "Number of upgrade requests, e.g. exec, attach and port-forward, failed before the connection is upgraded",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "protocol", "reason"},
+	)
+	proxyUpgradeStreamTerminations = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upgrade_stream_terminations_total",
+			Help:           "Number of upgraded streams terminated, reason tells which side ended the stream and whether it is reset",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "protocol", "reason"},
+	)
+	proxyRejectedInvalidRequests = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "rejected_invalid_requests_total",
+			Help:           "Number of requests rejected by strict request validation before proxying",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "reason", "rejection"},
+	)
+	proxyExpiredResourceVersions = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "expired_resource_version_responses_total",
+			Help:           "Number of lists rejected by upstream with 410 Gone because of expired resourceVersion or continue token, client is the component of User-Agent",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "resource", "client"},
+	)
+	proxyRewrittenResourceVersions = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "rewritten_resource_version_lists_total",
+			Help:           "Number of lists with expired resourceVersion retried by gateway without resourceVersion",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "resource", "client"},
+	)
+	proxySuppressedErrorLogs = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "suppressed_error_logs_total",
+			Help:           "Number of error logs not written because of per cluster error log sampling, class is the error class",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "class"},
+	)
+	proxyAdaptiveTimeouts = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "adaptive_timeout_seconds",
+			Help:           "Current response header timeout of non-long-running requests derived from recent latencies",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "verb", "resource"},
+	)
+	proxyRequestSeats = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "request_seats",
+			Help:           "Histogram of flow control seats charged to requests by estimated cost",
+			Buckets:        []float64{1, 2, 3, 5, 8, 10, 20, 50, 100},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "verb", "resource"},
+	)
+	proxyNotifications = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "notifications_total",
+			Help:           "Number of gateway decision notifications by event type and result of delivery to the webhook",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "type", "result"},
+	)
+	proxyUserRequests = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "user_requests_total",
+			Help:           "Number of proxied requests of top users of each cluster, other users are aggregated as user other",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "user"},
+	)
+	proxyStreamResets = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "http2_stream_resets_total",
+			Help:           "Number of proxied requests aborted by http2 RST_STREAM or GOAWAY frames, partitioned by side of connection, frame and error code",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "side", "serverName", "frame", "code"},
+	)
+	proxyInvalidUpstreamResponseHeaders = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "invalid_upstream_response_headers_total",
+			Help:           "Number of upstream responses rejected because of oversized or malformed headers",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "reason"},
+	)
+	proxyPanics = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "panics_total",
+			Help:           "Number of panics recovered by proxy, partitioned by the site recovering it and class of panic value",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "site", "class"},
+	)
+	proxyCoordinationSyncs = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "coordination_syncs_total",
+			Help:           "Number of syncs of replica state to the shared coordination lease, partitioned by whether it succeeded",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "success"},
+	)
+	proxyCoordinationReplicas = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "coordination_replicas",
+			Help:           "Number of live gateway replicas observed in the shared coordination lease, including this replica",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid"},
+	)
+	proxyAutoProfiles = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "auto_profiles_total",
+			Help:           "Number of CPU profiles triggered by gateway self latency regressions, partitioned by whether the profile is captured",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "captured"},
+	)
+	proxyAbuseReports = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "abuse_reports_total",
+			Help:           "Number of users reported because their requests to the upstream cluster were repeatedly rejected",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName"},
+	)
+	proxyResponseTransformErrors = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "response_transform_errors_total",
+			Help:           "Number of proxied responses aborted because a response transformer failed",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "transformer"},
+	)
+	proxyAbortedResponses = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "aborted_responses_total",
+			Help:           "Number of partially written responses aborted because proxying failed mid-stream, partitioned by error class",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "class"},
+	)
+	proxyClusterCeilingLimit = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_ceiling_limit",
+			Help:           "Limit of each upstream cluster's qps and bytes per second ceilings enforced by this replica, 0 means unlimited",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "ceiling"},
+	)
+	proxyClusterCeilingSaturated = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_ceiling_saturated_total",
+			Help:           "Number of requests rejected or request bodies throttled because upstream cluster's ceiling is saturated",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "ceiling"},
+	)
+	proxyOverheadLatencies = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "overhead_duration_seconds",
+			Help:           "Latency added by gateway itself to forwarded requests in seconds by phase, phase total is the sum of all phases",
+			Buckets:        []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "phase"},
+	)
+	proxyUpstreamHeaderLatencies = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_response_header_duration_seconds",
+			Help:           "Latency from the first try to upstream until the response header is written in seconds, including retries",
+			Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "verb"},
+	)
+	proxyResponseChecksums = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "response_checksum_verifications_total",
+			Help:           "Number of responses whose checksums of bytes received from upstream and written to client are compared, by result",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "result"},
+	)
+	proxyExpressionRuleEvaluations = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "expression_rule_evaluations_total",
+			Help:           "Number of requests evaluated by expression rules of upstream clusters, by rule and result",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "rule", "result"},
+	)
+	proxyStreamTranslations = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "stream_translations_total",
+			Help:           "Number of websocket sessions of exec, attach and port-forward translated to SPDY toward upstream, by websocket subprotocol",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "protocol"},
+	)
+	proxyReadReplicaRoutes = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "read_replica_routes_total",
+			Help:           "Number of requests routed by read and write split of clusters with read replicas, by target of reader, writer and pinned_writer",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "target"},
+	)
+	proxyDiscoveryCacheRequests = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "discovery_cache_requests_total",
+			Help:           "Number of discovery and openapi requests served by discovery cache, by result of hit, miss, stale, stale_if_error, revalidated and revalidate_failed",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "result"},
+	)
+	proxyInjectedFaults = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "injected_faults_total",
+			Help:           "Number of requests faulted by fault injection of each upstream cluster",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "fault"},
+	)
+	proxyThrottledStreamingBytes = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "streaming_throttled_bytes_total",
+			Help:           "Number of bytes of streaming sessions delayed by bandwidth limits, scope is session or user",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "scope"},
+	)
+	proxyThrottledStreamingSeconds = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "streaming_throttled_seconds_total",
+			Help:           "Total duration streaming sessions waited for bandwidth limits, scope is session or user",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "scope"},
+	)
+	// flow control metrics have the same names and labels as apiserver priority and fairness metrics, so
+	// dashboards of apiservers work for gateway as well. The flow control schema of upstream cluster acts
+	// as priority level, and the dispatch policy or rule selecting the schema acts as flow schema.
+	proxyFlowControlDispatched = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "dispatched_requests_total",
+			Help:           "Number of requests admitted by flow control",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel", "flowSchema"},
+	)
+	proxyFlowControlRejected = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "rejected_requests_total",
+			Help:           "Number of requests rejected by flow control",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel", "flowSchema", "reason"},
+	)
+	proxyFlowControlExecuting = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "current_executing_requests",
+			Help:           "Number of requests admitted by flow control and not finished yet",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel", "flowSchema"},
+	)
+	proxyFlowControlExecutingSeats = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "current_executing_seats",
+			Help:           "Number of seats charged by requests admitted by flow control and not finished yet",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel", "flowSchema"},
+	)
+	proxyFlowControlWaitDuration = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "request_wait_duration_seconds",
+			Help:           "Duration requests waited for flow control admission",
+			Buckets:        []float64{0.0001, 0.001, 0.005, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel", "flowSchema", "execute"},
+	)
+	proxyFlowControlLimit = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      flowControlNamespace,
+			Subsystem:      flowControlSubsystem,
+			Name:           "request_concurrency_limit",
+			Help:           "Concurrency limit (or qps for token bucket) of flow control, 0 means unlimited",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priorityLevel"},
+	)
+	proxyFlowControlFlowRejected = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "flowcontrol_flow_rejected_requests_total",
+			Help:           "Number of requests of each flow, i.e. user, rejected by flow control, users beyond cardinality limits are aggregated as other",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "flow"},
+	)
+	proxyClusterBudgetQueueLength = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "cluster_budget_queue_length",
+			Help:           "Number of requests waiting in priority queues for upstream cluster's resource budget",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "priority"},
+	)
+	proxyUpstreamReachable = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_reachable",
+			Help:           "Whether the upstream endpoint is reachable from this gateway replica by blackbox probing, 1 means reachable",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint"},
+	)
+	proxyUpstreamCordoned = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_cordoned",
+			Help:           "Whether the upstream endpoint is cordoned because its machine is under maintenance, 1 means cordoned",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint"},
+	)
+	proxyUpstreamProbeFailures = compbasemetrics.NewCounterVec(
+		&compbasemetrics.CounterOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_probe_failures_total",
+			Help:           "Number of failed blackbox probes to upstream endpoints, by the first failed stage (tcp, tls or http)",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint", "stage"},
+	)
+	proxyUpstreamProbeDuration = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "upstream_probe_duration_seconds",
+			Help:           "Duration of blackbox probes from this gateway replica to upstream endpoints",
+			Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "endpoint", "reachable"},
+	)
+	proxyHealthCheckSchedulerLag = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "health_check_scheduler_lag_seconds",
+			Help:           "Delay between when an endpoint health check is due and when it starts, caused by global pacing and bounded concurrency, by trigger of periodic or on_demand",
+			Buckets:        []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "trigger"},
+	)
+	proxyHealthChecksRunning = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "health_checks_running",
+			Help:           "Number of endpoint health checks being executed",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid"},
+	)
+	proxyCanaryDuration = compbasemetrics.NewHistogramVec(
+		&compbasemetrics.HistogramOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "canary_request_duration_seconds",
+			Help:           "End-to-end duration of canary requests sent through the proxy path of this gateway replica",
+			Buckets:        []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName", "path", "success"},
+	)
+	proxyCanaryAvailable = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "canary_available",
+			Help:           "Whether all canary requests of the last round to the upstream cluster succeeded through the proxy path",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "serverName"},
+	)
+	proxyFeatureGateEnabled = compbasemetrics.NewGaugeVec(
+		&compbasemetrics.GaugeOpts{
+			Namespace:      namespace,
+			Subsystem:      subsystem,
+			Name:           "feature_enabled",
+			Help:           "Whether the gateway feature gate is enabled, 1 means enabled and 0 means disabled",
+			StabilityLevel: compbasemetrics.ALPHA,
+		},
+		[]string{"pid", "name", "stage"},
+	)
 
 	localMetrics = []compbasemetrics.Registerable{
 		proxyReceiveRequestCounter,
 		proxyRequestCounter,
-		proxyRequestLatencies,
 		proxyResponseSizes,
 		proxyUpstreamUnhealthy,
+		proxyUpstreamHealthFlaps,
 		proxyRequestTerminationsTotal,
 		proxyRegisteredWatchers,
+		proxyClusterBudgetInflight,
+		proxyClusterBudgetLimit,
+		proxyClusterBudgetRejected,
+		proxyClusterBudgetQueueWait,
+		proxyUpstreamRetries,
+		proxyUpstreamAborted,
+		proxyExemptedRequests,
+		proxyInjectedFaults,
+		proxyTLSHandshakes,
+		proxyListenerConnections,
+		proxyUpstreamPrewarms,
+		proxyDrainedSessions,
+		proxyWatchResumeHints,
+		proxyAuditOnlyRejections,
+		proxyUpstreamIdentityMismatches,
+		proxyUpgradeHandshakeFailures,
+		proxyUpgradeStreamTerminations,
+		proxyRejectedInvalidRequests,
+		proxyExpiredResourceVersions,
+		proxyRewrittenResourceVersions,
+		proxySuppressedErrorLogs,
+		proxyAdaptiveTimeouts,
+		proxyRequestSeats,
+		proxyNotifications,
+		proxyUserRequests,
+		proxyStreamResets,
+		proxyInvalidUpstreamResponseHeaders,
+		proxyPanics,
+		proxyCoordinationSyncs,
+		proxyCoordinationReplicas,
+		proxyAutoProfiles,
+		proxyAbuseReports,
+		proxyAbortedResponses,
+		proxyResponseTransformErrors,
+		proxyThrottledStreamingBytes,
+		proxyClusterCeilingLimit,
+		proxyClusterCeilingSaturated,
+		proxyOverheadLatencies,
+		proxyUpstreamHeaderLatencies,
+		proxyResponseChecksums,
+		proxyExpressionRuleEvaluations,
+		proxyStreamTranslations,
+		proxyDiscoveryCacheRequests,
+		proxyReadReplicaRoutes,
+		proxyThrottledStreamingSeconds,
+		proxyFlowControlDispatched,
+		proxyFlowControlRejected,
+		proxyFlowControlExecuting,
+		proxyFlowControlWaitDuration,
+		proxyFlowControlLimit,
+		proxyFlowControlExecutingSeats,
+		proxyFlowControlFlowRejected,
+		proxyClusterBudgetQueueLength,
+		proxyUpstreamReachable,
+		proxyUpstreamCordoned,
+		proxyUpstreamProbeFailures,
+		proxyUpstreamProbeDuration,
+		proxyHealthCheckSchedulerLag,
+		proxyHealthChecksRunning,
+		proxyCanaryDuration,
+		proxyCanaryAvailable,
+		proxyFeatureGateEnabled,
+	}
+	// rawMetrics bypass metric stability framework
+	rawMetrics = []*prometheus.HistogramVec{
+		proxyRequestLatencies,
 	}
 )
 
@@ -165,14 +885,42 @@ func Register() {
 		for _, metric := range localMetrics {
 			metricsregistry.MustRegister(metric)
 		}
+		for _, metric := range rawMetrics {
+			metricsregistry.RawMustRegister(metric)
+		}
 	})
 }
 
+// RecordUpstreamCordoned records whether the upstream endpoint is cordoned for maintenance
+func RecordUpstreamCordoned(serverName, endpoint string, cordoned bool) {
+	value := 0.0
+	if cordoned {
+		value = 1
+	}
+	proxyUpstreamCordoned.WithLabelValues(proxyPid, serverName, endpoint).Set(value)
+}
+
+// RecordHealthCheckStarted records the scheduler lag of a health check when it starts running
+func RecordHealthCheckStarted(trigger string, lag time.Duration) {
+	proxyHealthCheckSchedulerLag.WithLabelValues(proxyPid, trigger).Observe(lag.Seconds())
+	proxyHealthChecksRunning.WithLabelValues(proxyPid).Inc()
+}
+
+// RecordHealthCheckFinished records that a health check finished running
+func RecordHealthCheckFinished() {
+	proxyHealthChecksRunning.WithLabelValues(proxyPid).Dec()
+}
+
 // RecordUnhealthyUpstream records that the upstream endpoint is unhealthy.
 func RecordUnhealthyUpstream(serverName string, endpoint string, reason string) {
 	proxyUpstreamUnhealthy.WithLabelValues(proxyPid, serverName, endpoint, reason).Inc()
 }
 
+// RecordUpstreamHealthFlap records that health of endpoint flapped
+func RecordUpstreamHealthFlap(serverName, endpoint string) {
+	proxyUpstreamHealthFlaps.WithLabelValues(proxyPid, serverName, endpoint).Inc()
+}
+
 func RecordProxyRequestReceived(req *http.Request, serverName string, requestInfo *request.RequestInfo) {
 	if requestInfo == nil {
 		requestInfo = &request.RequestInfo{Verb: req.Method, Path: req.URL.Path}
@@ -206,8 +954,15 @@ func MonitorProxyRequest(req *http.Request, serverName, endpoint string, request
 			resource += "/" + requestInfo.Subresource
 		}
 	}
+	endpoint = endpointLabel(endpoint)
 	proxyRequestCounter.WithLabelValues(proxyPid, serverName, endpoint, verb, resource, codeToString(httpCode)).Inc()
-	proxyRequestLatencies.WithLabelValues(proxyPid, serverName, endpoint, verb, resource).Observe(elapsedSeconds)
+	latencies := proxyRequestLatencies.WithLabelValues(proxyPid, serverName, endpoint, verb, resource)
+	if traceID, ok := gatewayrequest.TraceIDFrom(req.Context()); ok {
+		// the trace ID links slow buckets on dashboards to traces of the requests
+		latencies.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsedSeconds, prometheus.Labels{"trace_id": traceID})
+	} else {
+		latencies.Observe(elapsedSeconds)
+	}
 	// We are only interested in response sizes of read requests.
 	// nolint:goconst
 	if requestInfo.IsResourceRequest && (verb == "GET" || verb == "LIST") {
@@ -239,15 +994,327 @@ func RecordProxyRequestTermination(req *http.Request, code int, reason string) {
 
 	resource := cleanResource(requestInfo)
 
-	proxyRequestTerminationsTotal.WithLabelValues(proxyPid, serverName, cleanVerb(verb, req), requestInfo.Path, codeToString(code), reason, resource).Inc()
+	proxyRequestTerminationsTotal.WithLabelValues(proxyPid, serverName, cleanVerb(verb, req), requestInfo.Path, codeToString(code), reason, string(rejection.CodeOf(reason)), resource).Inc()
 }
 
 func RecordWatcherRegistered(serverName, endpoint, resource string) {
-	proxyRegisteredWatchers.WithLabelValues(proxyPid, serverName, endpoint, resource).Inc()
+	proxyRegisteredWatchers.WithLabelValues(proxyPid, serverName, endpointLabel(endpoint), resource).Inc()
 }
 
 func RecordWatcherUnregistered(serverName, endpoint, resource string) {
-	proxyRegisteredWatchers.WithLabelValues(proxyPid, serverName, endpoint, resource).Dec()
+	proxyRegisteredWatchers.WithLabelValues(proxyPid, serverName, endpointLabel(endpoint), resource).Dec()
+}
+
+// RecordClusterBudgetAcquired records that a resource of the upstream cluster's budget is held.
+func RecordClusterBudgetAcquired(serverName, budget string) {
+	proxyClusterBudgetInflight.WithLabelValues(proxyPid, serverName, budget).Inc()
+}
+
+// RecordClusterBudgetReleased records that a resource of the upstream cluster's budget is released.
+func RecordClusterBudgetReleased(serverName, budget string) {
+	proxyClusterBudgetInflight.WithLabelValues(proxyPid, serverName, budget).Dec()
+}
+
+// RecordClusterBudgetRejected records that a request or dial is rejected by upstream cluster's budget.
+func RecordClusterBudgetRejected(serverName, budget string) {
+	proxyClusterBudgetRejected.WithLabelValues(proxyPid, serverName, budget).Inc()
+}
+
+// RecordClusterBudgetQueueLength records the number of requests waiting in the queue of priority for upstream cluster's budget
+func RecordClusterBudgetQueueLength(serverName, priority string, length int) {
+	proxyClusterBudgetQueueLength.WithLabelValues(proxyPid, serverName, priority).Set(float64(length))
+}
+
+// RecordClusterBudgetQueued records the duration a request of priority waited for upstream cluster's budget
+func RecordClusterBudgetQueued(serverName, priority string, admitted bool, wait time.Duration) {
+	proxyClusterBudgetQueueWait.WithLabelValues(proxyPid, serverName, priority, strconv.FormatBool(admitted)).Observe(wait.Seconds())
+}
+
+func RecordClusterBudgetLimit(serverName, budget string, limit int32) {
+	proxyClusterBudgetLimit.WithLabelValues(proxyPid, serverName, budget).Set(float64(limit))
+}
+
+// RecordProxyRetry records that a request is retried for reason
+func RecordProxyRetry(serverName, reason string) {
+	proxyUpstreamRetries.WithLabelValues(proxyPid, serverName, reason).Inc()
+}
+
+// RecordUpstreamAborted records that an upstream request is aborted for reason
+func RecordUpstreamAborted(serverName, reason string) {
+	proxyUpstreamAborted.WithLabelValues(proxyPid, serverName, reason).Inc()
+}
+
+// RecordExemptedRequest records that a request bypasses rate limiting
+func RecordExemptedRequest(serverName string) {
+	proxyExemptedRequests.WithLabelValues(proxyPid, serverName).Inc()
+}
+
+// RecordTLSHandshake records the negotiated version and cipher suite of a TLS handshake, name is the
+// listener port for listener side and cluster name for upstream side.
+func RecordTLSHandshake(side, name string, state tls.ConnectionState) {
+	proxyTLSHandshakes.WithLabelValues(proxyPid, side, name, net.TLSVersionName(state.Version), tls.CipherSuiteName(state.CipherSuite)).Inc()
+}
+
+// RecordListenerConnection records a client connection accepted by listener, protocol is the
+// negotiated application protocol, empty means HTTP/1.1 without ALPN.
+func RecordListenerConnection(listener, protocol string) {
+	if len(protocol) == 0 {
+		protocol = "http/1.1"
+	}
+	proxyListenerConnections.WithLabelValues(proxyPid, listener, protocol).Inc()
+}
+
+// RecordUpstreamPrewarm records the result of pre-warming an upstream connection
+func RecordUpstreamPrewarm(serverName, result string) {
+	proxyUpstreamPrewarms.WithLabelValues(proxyPid, serverName, result).Inc()
+}
+
+// RecordDrainedSessions records sessions of class closed by draining
+func RecordDrainedSessions(class string, closed int) {
+	proxyDrainedSessions.WithLabelValues(proxyPid, class).Add(float64(closed))
+}
+
+// RecordWatchResumeHint records that a watch closed by gateway is ended with a resume hint
+func RecordWatchResumeHint(serverName string) {
+	proxyWatchResumeHints.WithLabelValues(proxyPid, serverName).Inc()
+}
+
+// RecordAuditOnlyRejection records a request which would be rejected by an audit-only rule
+func RecordAuditOnlyRejection(serverName, rule string) {
+	proxyAuditOnlyRejections.WithLabelValues(proxyPid, serverName, rule).Inc()
+}
+
+// RecordUpstreamIdentityMismatch records a TLS handshake to endpoint aborted by identity verification
+func RecordUpstreamIdentityMismatch(serverName, endpoint string) {
+	proxyUpstreamIdentityMismatches.WithLabelValues(proxyPid, serverName, endpoint).Inc()
+}
+
+// RecordUpgradeHandshakeFailure records an upgrade request failed before switching protocols
+func RecordUpgradeHandshakeFailure(serverName, protocol, reason string) {
+	proxyUpgradeHandshakeFailures.WithLabelValues(proxyPid, serverName, protocol, reason).Inc()
+}
+
+// RecordUpgradeStreamTermination records an upgraded stream terminated for reason
+func RecordUpgradeStreamTermination(serverName, protocol, reason string) {
+	proxyUpgradeStreamTerminations.WithLabelValues(proxyPid, serverName, protocol, reason).Inc()
+}
+
+// RecordRejectedInvalidRequest records a request rejected by request validation for reason
+func RecordRejectedInvalidRequest(reason string) {
+	proxyRejectedInvalidRequests.WithLabelValues(proxyPid, reason, string(rejection.CodeOf(reason))).Inc()
+}
+
+// RecordExpiredResourceVersion records a list of client rejected by upstream with 410 Gone, clients beyond
+// DefaultCardinalityLimits are aggregated
+func RecordExpiredResourceVersion(serverName, resource, client string) {
+	proxyExpiredResourceVersions.WithLabelValues(proxyPid, serverName, resource, clientLabel(serverName, client, evictClientSeries(serverName))).Inc()
+}
+
+// RecordRewrittenResourceVersion records a list with expired resourceVersion retried without it, clients
+// beyond DefaultCardinalityLimits are aggregated
+func RecordRewrittenResourceVersion(serverName, resource, client string) {
+	proxyRewrittenResourceVersions.WithLabelValues(proxyPid, serverName, resource, clientLabel(serverName, client, evictClientSeries(serverName))).Inc()
+}
+
+// evictClientSeries returns the function deleting series of clients leaving top clients of cluster, series
+// of all resources of the client are deleted.
+func evictClientSeries(serverName string) func(client string) {
+	return func(client string) {
+		deleteSeries(map[string]string{"pid": proxyPid, "serverName": serverName, "client": client})
+	}
+}
+
+// RecordSuppressedErrorLog records an error log of class suppressed by error log sampling
+func RecordSuppressedErrorLog(serverName, class string) {
+	proxySuppressedErrorLogs.WithLabelValues(proxyPid, serverName, class).Inc()
+}
+
+// RecordAdaptiveTimeout records the current adaptive timeout of requests to resource of cluster
+func RecordAdaptiveTimeout(serverName, verb, resource string, timeout time.Duration) {
+	proxyAdaptiveTimeouts.WithLabelValues(proxyPid, serverName, verb, resource).Set(timeout.Seconds())
+}
+
+// RecordRequestSeats records the flow control seats charged to a request by its estimated cost
+func RecordRequestSeats(serverName, verb, resource string, seats int) {
+	proxyRequestSeats.WithLabelValues(proxyPid, serverName, verb, resource).Observe(float64(seats))
+}
+
+// RecordNotification records that a notification of event type is delivered, dropped or failed
+func RecordNotification(eventType, result string) {
+	proxyNotifications.WithLabelValues(proxyPid, eventType, result).Inc()
+}
+
+// RecordUserRequest records a proxied request of user, users beyond DefaultCardinalityLimits are aggregated
+func RecordUserRequest(serverName, user string) {
+	proxyUserRequests.WithLabelValues(proxyPid, serverName, userLabel(serverName, user, evictUserSeries(serverName))).Inc()
+}
+
+// evictUserSeries returns the function deleting series of users leaving top users of cluster
+func evictUserSeries(serverName string) func(user string) {
+	return func(user string) {
+		proxyUserRequests.Delete(map[string]string{"pid": proxyPid, "serverName": serverName, "user": user})
+		proxyFlowControlFlowRejected.Delete(map[string]string{"pid": proxyPid, "serverName": serverName, "flow": user})
+	}
+}
+
+// RecordStreamReset records that a proxied request is aborted by a http2 stream reset, side is
+// inbound for resets on downstream client connections and upstream for upstream connections.
+func RecordStreamReset(side, serverName, frame, code string) {
+	proxyStreamResets.WithLabelValues(proxyPid, side, serverName, frame, code).Inc()
+}
+
+// RecordInvalidUpstreamResponseHeader records that an upstream response is rejected because of its headers
+func RecordInvalidUpstreamResponseHeader(serverName, reason string) {
+	proxyInvalidUpstreamResponseHeaders.WithLabelValues(proxyPid, serverName, reason).Inc()
+}
+
+// RecordPanic records that a panic is recovered at site
+func RecordPanic(site, class string) {
+	proxyPanics.WithLabelValues(proxyPid, site, class).Inc()
+}
+
+// RecordCoordinationSync records the result of a coordination lease sync and live replicas observed
+func RecordCoordinationSync(success bool, replicas int) {
+	proxyCoordinationSyncs.WithLabelValues(proxyPid, strconv.FormatBool(success)).Inc()
+	proxyCoordinationReplicas.WithLabelValues(proxyPid).Set(float64(replicas))
+}
+
+// RecordAutoProfile records a CPU profile triggered by self latency regression and whether it is captured
+func RecordAutoProfile(captured bool) {
+	proxyAutoProfiles.WithLabelValues(proxyPid, strconv.FormatBool(captured)).Inc()
+}
+
+// RecordAbuseReport records that a user is reported for repeated rejections of requests to cluster
+func RecordAbuseReport(cluster string) {
+	proxyAbuseReports.WithLabelValues(proxyPid, cluster).Inc()
+}
+
+// RecordResponseTransformError records that a response to cluster is aborted by failed transformer
+func RecordResponseTransformError(cluster, transformer string) {
+	proxyResponseTransformErrors.WithLabelValues(proxyPid, cluster, transformer).Inc()
+}
+
+// RecordAbortedResponse records that a partially written response to cluster is aborted by error of class
+func RecordAbortedResponse(cluster, class string) {
+	proxyAbortedResponses.WithLabelValues(proxyPid, cluster, class).Inc()
+}
+
+// RecordClusterCeilingLimit records the limit of upstream cluster's ceiling enforced by this replica
+func RecordClusterCeilingLimit(serverName, ceiling string, limit int64) {
+	proxyClusterCeilingLimit.WithLabelValues(proxyPid, serverName, ceiling).Set(float64(limit))
+}
+
+// RecordClusterCeilingSaturated records that a request is rejected or a request body is throttled by upstream cluster's ceiling
+func RecordClusterCeilingSaturated(serverName, ceiling string) {
+	proxyClusterCeilingSaturated.WithLabelValues(proxyPid, serverName, ceiling).Inc()
+}
+
+// RecordOverheadLatency records the latency added by gateway in phase of a forwarded request
+func RecordOverheadLatency(serverName, phase string, elapsed time.Duration) {
+	proxyOverheadLatencies.WithLabelValues(proxyPid, serverName, phase).Observe(elapsed.Seconds())
+}
+
+// RecordUpstreamHeaderLatency records the latency of upstream until the response header of a forwarded request is written
+func RecordUpstreamHeaderLatency(serverName, verb string, elapsed time.Duration) {
+	proxyUpstreamHeaderLatencies.WithLabelValues(proxyPid, serverName, verb).Observe(elapsed.Seconds())
+}
+
+// RecordResponseChecksum records the result of comparing checksums of a proxied response
+func RecordResponseChecksum(serverName, result string) {
+	proxyResponseChecksums.WithLabelValues(proxyPid, serverName, result).Inc()
+}
+
+// RecordExpressionRuleEvaluation records the result of evaluating a request by an expression rule
+func RecordExpressionRuleEvaluation(serverName, rule, result string) {
+	proxyExpressionRuleEvaluations.WithLabelValues(proxyPid, serverName, rule, result).Inc()
+}
+
+// RecordStreamTranslation records that a websocket session is translated to SPDY toward upstream
+func RecordStreamTranslation(serverName, protocol string) {
+	proxyStreamTranslations.WithLabelValues(proxyPid, serverName, protocol).Inc()
+}
+
+// RecordReadReplicaRoute records that a request is routed to readers or writers of a cluster with read replicas
+func RecordReadReplicaRoute(serverName, target string) {
+	proxyReadReplicaRoutes.WithLabelValues(proxyPid, serverName, target).Inc()
+}
+
+// RecordDiscoveryCache records how a discovery or openapi request is served by discovery cache
+func RecordDiscoveryCache(serverName, result string) {
+	proxyDiscoveryCacheRequests.WithLabelValues(proxyPid, serverName, result).Inc()
+}
+
+// RecordInjectedFault records that a fault is injected into a request
+func RecordInjectedFault(serverName, fault string) {
+	proxyInjectedFaults.WithLabelValues(proxyPid, serverName, fault).Inc()
+}
+
+// RecordStreamingThrottled records bytes of a streaming session delayed by bandwidth limit of scope
+func RecordStreamingThrottled(serverName, scope string, bytes int, wait time.Duration) {
+	proxyThrottledStreamingBytes.WithLabelValues(proxyPid, serverName, scope).Add(float64(bytes))
+	proxyThrottledStreamingSeconds.WithLabelValues(proxyPid, serverName, scope).Add(wait.Seconds())
+}
+
+// RecordFlowControlAdmission records the result of flow control admission of a request of user, flowSchema
+// is the rule selecting the flow control and seats are the seats charged by the request.
+func RecordFlowControlAdmission(serverName, flowControl, flowSchema, user string, seats int, admitted bool, reason string, wait time.Duration) {
+	proxyFlowControlWaitDuration.WithLabelValues(proxyPid, serverName, flowControl, flowSchema, strconv.FormatBool(admitted)).Observe(wait.Seconds())
+	if !admitted {
+		proxyFlowControlRejected.WithLabelValues(proxyPid, serverName, flowControl, flowSchema, reason).Inc()
+		if len(user) > 0 {
+			proxyFlowControlFlowRejected.WithLabelValues(proxyPid, serverName, userLabel(serverName, user, evictUserSeries(serverName))).Inc()
+		}
+		return
+	}
+	proxyFlowControlDispatched.WithLabelValues(proxyPid, serverName, flowControl, flowSchema).Inc()
+	proxyFlowControlExecuting.WithLabelValues(proxyPid, serverName, flowControl, flowSchema).Inc()
+	proxyFlowControlExecutingSeats.WithLabelValues(proxyPid, serverName, flowControl, flowSchema).Add(float64(seats))
+}
+
+// RecordFlowControlRelease records that an admitted request is finished
+func RecordFlowControlRelease(serverName, flowControl, flowSchema string, seats int) {
+	proxyFlowControlExecuting.WithLabelValues(proxyPid, serverName, flowControl, flowSchema).Dec()
+	proxyFlowControlExecutingSeats.WithLabelValues(proxyPid, serverName, flowControl, flowSchema).Add(-float64(seats))
+}
+
+func RecordFlowControlLimit(serverName, flowControl string, limit uint32) {
+	proxyFlowControlLimit.WithLabelValues(proxyPid, serverName, flowControl).Set(float64(limit))
+}
+
+// RecordUpstreamProbe records the result of a blackbox probe to upstream endpoint,
+// failedStage is empty if endpoint is reachable.
+func RecordUpstreamProbe(serverName, endpoint string, reachable bool, failedStage string, duration time.Duration) {
+	value := 0.0
+	if reachable {
+		value = 1
+	} else {
+		proxyUpstreamProbeFailures.WithLabelValues(proxyPid, serverName, endpoint, failedStage).Inc()
+	}
+	proxyUpstreamReachable.WithLabelValues(proxyPid, serverName, endpoint).Set(value)
+	proxyUpstreamProbeDuration.WithLabelValues(proxyPid, serverName, endpoint, strconv.FormatBool(reachable)).Observe(duration.Seconds())
+}
+
+// RecordCanaryRequest records the result and end-to-end duration of a canary request to path of cluster
+func RecordCanaryRequest(serverName, path string, success bool, duration time.Duration) {
+	proxyCanaryDuration.WithLabelValues(proxyPid, serverName, path, strconv.FormatBool(success)).Observe(duration.Seconds())
+}
+
+// RecordCanaryAvailable records whether all canary requests of the last round to cluster succeeded
+func RecordCanaryAvailable(serverName string, available bool) {
+	value := 0.0
+	if available {
+		value = 1
+	}
+	proxyCanaryAvailable.WithLabelValues(proxyPid, serverName).Set(value)
+}
+
+// RecordFeatureGate records whether the gateway feature gate is enabled.
+func RecordFeatureGate(name, stage string, enabled bool) {
+	value := 0.0
+	if enabled {
+		value = 1.0
+	}
+	proxyFeatureGateEnabled.WithLabelValues(proxyPid, name, stage).Set(value)
 }
 
 // CleanScope returns the scope of the request.
@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apiserver/pkg/endpoints/request"
+
+	gatewayrequest "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	metricsregistry "github.com/kubewharf/kubegateway/pkg/gateway/metrics/registry"
+)
+
+func gatherHistogram(t *testing.T, name, serverName string) *dto.Histogram {
+	families, err := metricsregistry.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if pair.GetName() == "serverName" && pair.GetValue() == serverName {
+					return m.GetHistogram()
+				}
+			}
+		}
+	}
+	t.Fatalf("histogram %v of cluster %v is not gathered", name, serverName)
+	return nil
+}
+
+func TestMonitorProxyRequest_latencies(t *testing.T) {
+	defer DeleteClusterSeries("latency-traced")
+	defer DeleteClusterSeries("latency-untraced")
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	requestInfo := &request.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"}
+	req := httptest.NewRequest("GET", "/api/v1/pods", nil)
+	MonitorProxyRequest(req, "latency-untraced", "https://10.0.0.1:6443", requestInfo, "", 200, 0, 30*time.Millisecond)
+	req = req.WithContext(gatewayrequest.WithTraceID(req.Context(), traceID))
+	MonitorProxyRequest(req, "latency-traced", "https://10.0.0.1:6443", requestInfo, "", 200, 0, 30*time.Millisecond)
+
+	traced := gatherHistogram(t, "kubegateway_proxy_apiserver_request_duration_seconds", "latency-traced")
+	if traced.GetSampleCount() != 1 {
+		t.Errorf("sample count of traced request = %v, want 1", traced.GetSampleCount())
+	}
+	// native buckets are only exposed if the histogram is a native histogram, schema 0 is valid too
+	if (len(traced.GetPositiveSpan()) == 0) || (traced.ZeroCount == nil) {
+		t.Errorf("histogram of traced request = %v, want native buckets", traced)
+	}
+	var exemplars []*dto.Exemplar
+	for _, bucket := range traced.GetBucket() {
+		if bucket.GetExemplar() != nil {
+			exemplars = append(exemplars, bucket.GetExemplar())
+		}
+	}
+	if len(exemplars) != 1 {
+		t.Fatalf("exemplars of traced request = %v, want 1", exemplars)
+	}
+	if labels := exemplars[0].GetLabel(); (len(labels) != 1) || (labels[0].GetName() != "trace_id") || (labels[0].GetValue() != traceID) {
+		t.Errorf("labels of exemplar = %v, want trace_id=%v", labels, traceID)
+	}
+
+	untraced := gatherHistogram(t, "kubegateway_proxy_apiserver_request_duration_seconds", "latency-untraced")
+	for _, bucket := range untraced.GetBucket() {
+		if bucket.GetExemplar() != nil {
+			t.Errorf("request without trace ID got exemplar %v", bucket.GetExemplar())
+		}
+	}
+}
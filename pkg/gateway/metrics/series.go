@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"k8s.io/klog"
+
+	metricsregistry "github.com/kubewharf/kubegateway/pkg/gateway/metrics/registry"
+)
+
+// seriesDeleter is implemented by all metric vectors
+type seriesDeleter interface {
+	Delete(labels map[string]string) bool
+}
+
+// DeleteClusterSeries deletes all series labeled by serverName and forgets the top users and clients of it,
+// it should be called after an upstream cluster is deleted. It returns the number of deleted series.
+func DeleteClusterSeries(serverName string) int {
+	clusterTopUsers.Delete(serverName)
+	clusterTopClients.Delete(serverName)
+	return deleteSeries(map[string]string{"serverName": serverName})
+}
+
+// DeleteEndpointSeries deletes all series labeled by serverName and endpoint, it should be called after
+// an endpoint is deleted from an upstream cluster. It returns the number of deleted series.
+func DeleteEndpointSeries(serverName, endpoint string) int {
+	return deleteSeries(map[string]string{"serverName": serverName, "endpoint": endpoint})
+}
+
+// deleteSeries deletes series of local metrics whose labels contain all of match.
+//
+// Metric vectors can only delete series by full label sets, so label sets are collected from gathered
+// metrics and deleted from every vector, vectors with different label names never match them.
+func deleteSeries(match map[string]string) int {
+	families, err := metricsregistry.DefaultGatherer.Gather()
+	if err != nil {
+		klog.Errorf("failed to gather metrics to delete series of %v: %v", match, err)
+		return 0
+	}
+	labelSets := []map[string]string{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			matched := true
+			for k, v := range match {
+				matched = matched && labels[k] == v
+			}
+			if matched {
+				labelSets = append(labelSets, labels)
+			}
+		}
+	}
+	deleted := 0
+	for _, metric := range localMetrics {
+		vec, ok := metric.(seriesDeleter)
+		if !ok {
+			continue
+		}
+		for _, labels := range labelSets {
+			if vec.Delete(labels) {
+				deleted++
+			}
+		}
+	}
+	for _, vec := range rawMetrics {
+		for _, labels := range labelSets {
+			if vec.Delete(labels) {
+				deleted++
+			}
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+
+	metricsregistry "github.com/kubewharf/kubegateway/pkg/gateway/metrics/registry"
+)
+
+func countSeries(t *testing.T, match map[string]string) int {
+	families, err := metricsregistry.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	n := 0
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			matched := true
+			for k, v := range match {
+				matched = matched && labels[k] == v
+			}
+			if matched {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestDeleteClusterSeries(t *testing.T) {
+	RecordUnhealthyUpstream("gc-deleted", "https://10.0.0.1:6443", "timeout")
+	RecordUnhealthyUpstream("gc-deleted", "https://10.0.0.2:6443", "timeout")
+	RecordClusterBudgetLimit("gc-deleted", "requests", 100)
+	RecordClusterBudgetLimit("gc-alive", "requests", 100)
+
+	if deleted := DeleteEndpointSeries("gc-deleted", "https://10.0.0.1:6443"); deleted != 1 {
+		t.Errorf("DeleteEndpointSeries() = %v, want 1", deleted)
+	}
+	if n := countSeries(t, map[string]string{"serverName": "gc-deleted"}); n != 2 {
+		t.Errorf("series of cluster after endpoint deleted = %v, want 2", n)
+	}
+	if deleted := DeleteClusterSeries("gc-deleted"); deleted != 2 {
+		t.Errorf("DeleteClusterSeries() = %v, want 2", deleted)
+	}
+	if n := countSeries(t, map[string]string{"serverName": "gc-deleted"}); n != 0 {
+		t.Errorf("series of deleted cluster = %v, want 0", n)
+	}
+	if n := countSeries(t, map[string]string{"serverName": "gc-alive"}); n != 1 {
+		t.Errorf("series of other cluster = %v, want 1", n)
+	}
+}
+
+func TestRecordExpiredResourceVersion_boundedClients(t *testing.T) {
+	defer func(limits CardinalityLimits) { DefaultCardinalityLimits = limits }(DefaultCardinalityLimits)
+	DefaultCardinalityLimits.MaxClientsPerCluster = 2
+	defer DeleteClusterSeries("rv-bounded")
+
+	for _, client := range []string{"kubectl", "helm", "random-1", "random-2"} {
+		RecordExpiredResourceVersion("rv-bounded", "pods", client)
+	}
+	if n := countSeries(t, map[string]string{"serverName": "rv-bounded"}); n != 3 {
+		t.Errorf("series of cluster = %v, want 3", n)
+	}
+	if n := countSeries(t, map[string]string{"serverName": "rv-bounded", "client": otherLabelValue}); n != 1 {
+		t.Errorf("series of client %q = %v, want 1", otherLabelValue, n)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter limits bytes per second with a token bucket, bytes exceeding the bucket are
+// borrowed from the future and callers wait until the debt is paid off.
+type BandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewBandwidthLimiter creates a limiter of bytesPerSecond, burst is the bytes can be sent at once
+// after the limiter has been idle.
+func NewBandwidthLimiter(bytesPerSecond, burst int64) *BandwidthLimiter {
+	if burst < bytesPerSecond {
+		burst = bytesPerSecond
+	}
+	return &BandwidthLimiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// reserve takes n bytes from the bucket and returns the duration to wait before sending them
+func (l *BandwidthLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// WaitN blocks until n bytes can be sent and returns the duration waited, it returns early
+// with error if ctx is done.
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) (time.Duration, error) {
+	if l == nil || n <= 0 {
+		return 0, nil
+	}
+	wait := l.reserve(n)
+	if wait <= 0 {
+		return 0, nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return wait, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
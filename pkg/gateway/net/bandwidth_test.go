@@ -0,0 +1,60 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiter_reserve(t *testing.T) {
+	now := time.Now()
+	l := NewBandwidthLimiter(100, 0)
+	l.last = now
+	l.now = func() time.Time { return now }
+
+	if wait := l.reserve(100); wait != 0 {
+		t.Errorf("reserve() within burst waits %v, want 0", wait)
+	}
+	if wait := l.reserve(50); wait != 500*time.Millisecond {
+		t.Errorf("reserve() exceeding burst waits %v, want 500ms", wait)
+	}
+	// the debt of 50 bytes is paid off after 500ms
+	now = now.Add(time.Second)
+	if wait := l.reserve(50); wait != 0 {
+		t.Errorf("reserve() after debt paid off waits %v, want 0", wait)
+	}
+	// tokens never exceed burst after being idle
+	now = now.Add(time.Hour)
+	if wait := l.reserve(200); wait != time.Second {
+		t.Errorf("reserve() after idle waits %v, want 1s", wait)
+	}
+}
+
+func TestBandwidthLimiter_WaitN(t *testing.T) {
+	var l *BandwidthLimiter
+	if wait, err := l.WaitN(context.Background(), 1<<20); wait != 0 || err != nil {
+		t.Errorf("nil limiter WaitN() = %v, %v, want no waiting", wait, err)
+	}
+
+	l = NewBandwidthLimiter(1, 0)
+	l.reserve(3600)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := l.WaitN(ctx, 1); err != context.Canceled {
+		t.Errorf("WaitN() with canceled context error = %v, want %v", err, context.Canceled)
+	}
+}
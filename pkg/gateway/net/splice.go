@@ -0,0 +1,77 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+)
+
+// tcpConnOf returns the plaintext tcp connection beneath r, it is false if r is not a tcp connection
+// or is wrapped by a layer which must see the bytes in user space, e.g. TLS or bandwidth limiting.
+func tcpConnOf(r io.Reader) (*net.TCPConn, bool) {
+	switch c := r.(type) {
+	case *net.TCPConn:
+		return c, true
+	case *observedStreamConn:
+		return tcpConnOf(c.Conn)
+	}
+	return nil, false
+}
+
+// writerOnly hides ReadFrom of the connection, so io.Copy falls back to copying in user space
+type writerOnly struct {
+	io.Writer
+}
+
+// ReadFrom copies the stream from r, UpgradeAwareHandler reaches it by io.Copy between the hijacked
+// client connection and the upstream connection. If both of them are plaintext tcp connections, the
+// stream is copied by (*net.TCPConn).ReadFrom, which splices it in kernel on linux without copying
+// it to user space. Otherwise, or if splicing is disabled by StreamObserver.DisableSplice, it is copied
+// by Read and Write.
+func (c *observedStreamConn) ReadFrom(r io.Reader) (int64, error) {
+	dst, dstOK := tcpConnOf(c.Conn)
+	src, srcOK := tcpConnOf(r)
+	// the upgrade response written to client must be observed before the stream is spliced
+	if !dstOK || !srcOK || atomic.LoadInt32(&c.observer.noSplice) == 1 || (!c.upstream && c.observer.Status() == 0) {
+		return io.Copy(writerOnly{c}, r)
+	}
+	n, err := dst.ReadFrom(src)
+	c.observeSplice(r, err)
+	return n, err
+}
+
+// observeSplice observes how a spliced copy from r to c ended. The copy returns one error for both
+// connections, it is attributed to c if writing to c failed by EPIPE or c is closed by proxy, and to
+// r otherwise.
+func (c *observedStreamConn) observeSplice(r io.Reader, err error) {
+	source, _ := r.(*observedStreamConn)
+	if err == nil {
+		// the whole stream is copied until EOF of source
+		if source != nil {
+			source.observer.observeError(source, io.EOF)
+		}
+		return
+	}
+	closedByProxy := errors.Is(err, net.ErrClosed) && atomic.LoadInt32(&c.closed) == 1
+	if source == nil || errors.Is(err, syscall.EPIPE) || closedByProxy {
+		c.observer.observeError(c, err)
+		return
+	}
+	source.observer.observeError(source, err)
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+// readerOnly hides the tcp connection beneath, so copies from it are never spliced
+type readerOnly struct {
+	io.Reader
+}
+
+func Test_tcpConnOf(t *testing.T) {
+	conn, _ := newTCPConnPair(t)
+	o := NewStreamObserver()
+	if _, ok := tcpConnOf(o.WrapUpstream(o.WrapClient(conn))); !ok {
+		t.Errorf("tcpConnOf() of observed tcp connection = false, want true")
+	}
+	if _, ok := tcpConnOf(readerOnly{conn}); ok {
+		t.Errorf("tcpConnOf() of wrapped tcp connection = true, want false")
+	}
+}
+
+func TestObservedStreamConn_ReadFrom(t *testing.T) {
+	data := bytes.Repeat([]byte("stream"), 64*1024)
+	tests := []struct {
+		name          string
+		wrapped       bool
+		disableSplice bool
+	}{
+		{name: "spliced"},
+		{name: "user space", wrapped: true},
+		{name: "splice disabled", disableSplice: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, clientPeer := newTCPConnPair(t)
+			upstream, upstreamPeer := newTCPConnPair(t)
+			o := NewStreamObserver()
+			if tt.disableSplice {
+				o.DisableSplice()
+			}
+			observedClient := o.WrapClient(client)
+			var observedUpstream io.Reader = o.WrapUpstream(upstream)
+			if tt.wrapped {
+				observedUpstream = readerOnly{observedUpstream}
+			}
+			if _, err := observedClient.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n")); err != nil {
+				t.Fatalf("failed to write upgrade response: %v", err)
+			}
+
+			go func() {
+				upstreamPeer.Write(data) //nolint
+				upstreamPeer.Close()
+			}()
+			copied := make(chan error, 1)
+			go func() {
+				_, err := io.Copy(observedClient, observedUpstream)
+				client.CloseWrite() //nolint
+				copied <- err
+			}()
+
+			received, err := ioutil.ReadAll(clientPeer)
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			if err := <-copied; err != nil {
+				t.Fatalf("io.Copy() error = %v", err)
+			}
+			want := append([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n"), data...)
+			if !bytes.Equal(received, want) {
+				t.Errorf("received %d bytes, want %d", len(received), len(want))
+			}
+			if end, err := o.End(); end != StreamEndCompleted {
+				t.Errorf("End() = %v, %v, want %v", end, err, StreamEndCompleted)
+			}
+		})
+	}
+}
+
+// BenchmarkObservedStreamConn_ReadFrom compares streams between plaintext tcp connections spliced in
+// kernel with those copied in user space.
+func BenchmarkObservedStreamConn_ReadFrom(b *testing.B) {
+	chunk := bytes.Repeat([]byte{'x'}, 1024*1024)
+	for name, spliced := range map[string]bool{"spliced": true, "user space": false} {
+		b.Run(name, func(b *testing.B) {
+			client, clientPeer := newTCPConnPair(b)
+			upstream, upstreamPeer := newTCPConnPair(b)
+			o := NewStreamObserver()
+			observedClient := o.WrapClient(client)
+			var observedUpstream io.Reader = o.WrapUpstream(upstream)
+			if !spliced {
+				observedUpstream = readerOnly{observedUpstream}
+			}
+			observedClient.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n\r\n")) //nolint
+
+			go io.Copy(observedClient, observedUpstream) //nolint
+			go io.Copy(ioutil.Discard, clientPeer)       //nolint
+			b.SetBytes(int64(len(chunk)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := upstreamPeer.Write(chunk); err != nil {
+					b.Fatalf("failed to write stream: %v", err)
+				}
+			}
+		})
+	}
+}
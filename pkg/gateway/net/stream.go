@@ -0,0 +1,200 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons of upgraded stream termination
+const (
+	// StreamEndCompleted means upstream closed the stream normally
+	StreamEndCompleted = "completed"
+	// StreamEndUpstreamReset means reading from or writing to upstream failed, e.g. connection reset by peer
+	StreamEndUpstreamReset = "upstream_reset"
+	// StreamEndClientClosed means client closed the stream normally
+	StreamEndClientClosed = "client_closed"
+	// StreamEndClientDisconnect means reading from or writing to client failed
+	StreamEndClientDisconnect = "client_disconnect"
+	// StreamEndGatewayClosed means the client connection is closed by gateway itself, e.g. draining
+	StreamEndGatewayClosed = "gateway_closed"
+)
+
+type streamObserverKey struct{}
+
+// WithStreamObserver returns a copy of parent in which the stream observer value is set
+func WithStreamObserver(parent context.Context, o *StreamObserver) context.Context {
+	return context.WithValue(parent, streamObserverKey{}, o)
+}
+
+// StreamObserverFrom returns the stream observer of an upgrade request
+func StreamObserverFrom(ctx context.Context) (*StreamObserver, bool) {
+	o, ok := ctx.Value(streamObserverKey{}).(*StreamObserver)
+	return o, ok && o != nil
+}
+
+// StreamObserver observes both connections of an upgraded stream, e.g. exec, attach and port-forward,
+// to tell which side ended the stream first and how. Errors of one side after the proxy closed it
+// because the other side ended are ignored.
+type StreamObserver struct {
+	// noSplice is 1 if streams must be copied in user space even between plaintext tcp connections
+	noSplice int32
+
+	mu sync.Mutex
+	// status code of the upgrade response written to client, 0 if nothing is written
+	status int
+	end    string
+	err    error
+}
+
+// NewStreamObserver creates an observer for one upgrade request
+func NewStreamObserver() *StreamObserver {
+	return &StreamObserver{}
+}
+
+// WrapUpstream wraps the connection dialed to upstream endpoint. Read and write errors are only observed
+// after the upgrade response is written to client, failures of dialing, TLS handshake and reading the
+// upgrade response belong to the handshake and are reported to the error responder of proxy instead.
+func (o *StreamObserver) WrapUpstream(conn net.Conn) net.Conn {
+	return &observedStreamConn{Conn: conn, observer: o, upstream: true}
+}
+
+// WrapClient wraps the hijacked client connection. Streams between wrapped connections which are both
+// plaintext tcp connections are spliced in kernel, see observedStreamConn.ReadFrom.
+func (o *StreamObserver) WrapClient(conn net.Conn) net.Conn {
+	return &observedStreamConn{Conn: conn, observer: o}
+}
+
+// DisableSplice makes the observed stream copied in user space even if both connections are plaintext
+// tcp connections, it must be called before the client connection is hijacked.
+func (o *StreamObserver) DisableSplice() {
+	atomic.StoreInt32(&o.noSplice, 1)
+}
+
+// Status returns the status code of the upgrade response written to client, 0 if nothing is written,
+// http.StatusSwitchingProtocols means the connection is upgraded.
+func (o *StreamObserver) Status() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.status
+}
+
+// End returns how the stream ended and the error if any. Upstream closing the stream by TLS
+// close_notify is not observable on the raw connection, so nothing observed means completed.
+func (o *StreamObserver) End() (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.end) == 0 {
+		return StreamEndCompleted, nil
+	}
+	return o.end, o.err
+}
+
+func (o *StreamObserver) observeResponse(b []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.status != 0 {
+		return
+	}
+	o.status = -1
+	// status line of response, e.g. HTTP/1.1 101 Switching Protocols
+	fields := bytes.Fields(bytes.SplitN(b, []byte("\n"), 2)[0])
+	if len(fields) < 2 || !bytes.HasPrefix(fields[0], []byte("HTTP/")) {
+		return
+	}
+	if code, err := strconv.Atoi(string(fields[1])); err == nil {
+		o.status = code
+	}
+}
+
+// upgraded returns true if the upgrade response has been written to client
+func (o *StreamObserver) upgraded() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.status == http.StatusSwitchingProtocols
+}
+
+func (o *StreamObserver) observeError(c *observedStreamConn, err error) {
+	if c.upstream && !o.upgraded() {
+		// the stream does not exist before switching protocols
+		return
+	}
+	var end string
+	switch {
+	case errors.Is(err, net.ErrClosed):
+		if atomic.LoadInt32(&c.closed) == 1 {
+			// closed by proxy after the other side ended
+			return
+		}
+		if c.upstream {
+			end = StreamEndUpstreamReset
+		} else {
+			end = StreamEndGatewayClosed
+		}
+	case c.upstream && err == io.EOF:
+		end = StreamEndCompleted
+	case c.upstream:
+		end = StreamEndUpstreamReset
+	case err == io.EOF:
+		end = StreamEndClientClosed
+	default:
+		end = StreamEndClientDisconnect
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.end) == 0 {
+		o.end, o.err = end, err
+	}
+}
+
+type observedStreamConn struct {
+	net.Conn
+	observer *StreamObserver
+	upstream bool
+	closed   int32
+}
+
+func (c *observedStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil {
+		c.observer.observeError(c, err)
+	}
+	return n, err
+}
+
+func (c *observedStreamConn) Write(b []byte) (int, error) {
+	if !c.upstream && len(b) > 0 {
+		c.observer.observeResponse(b)
+	}
+	n, err := c.Conn.Write(b)
+	if err != nil {
+		c.observer.observeError(c, err)
+	}
+	return n, err
+}
+
+func (c *observedStreamConn) Close() error {
+	atomic.StoreInt32(&c.closed, 1)
+	return c.Conn.Close()
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+// newTCPConnPair returns both ends of a loopback tcp connection
+func newTCPConnPair(t testing.TB) (*net.TCPConn, *net.TCPConn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+	local, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	remote := <-accepted
+	if remote == nil {
+		t.Fatalf("failed to accept")
+	}
+	t.Cleanup(func() {
+		local.Close()
+		remote.Close()
+	})
+	return local.(*net.TCPConn), remote.(*net.TCPConn)
+}
+
+func TestStreamObserver(t *testing.T) {
+	tests := []struct {
+		name string
+		// end the stream, client and upstream are connections of gateway
+		end  func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver)
+		want string
+	}{
+		{
+			"upstream closed",
+			func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver) {
+				upstreamPeer.Close()
+			},
+			StreamEndCompleted,
+		},
+		{
+			"upstream reset",
+			func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver) {
+				upstreamPeer.SetLinger(0) //nolint
+				upstreamPeer.Close()
+			},
+			StreamEndUpstreamReset,
+		},
+		{
+			"client closed",
+			func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver) {
+				clientPeer.Close()
+			},
+			StreamEndClientClosed,
+		},
+		{
+			"client reset",
+			func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver) {
+				clientPeer.SetLinger(0) //nolint
+				clientPeer.Close()
+			},
+			StreamEndClientDisconnect,
+		},
+		{
+			"closed by gateway",
+			func(client, clientPeer, upstream, upstreamPeer *net.TCPConn, o *StreamObserver) {
+				client.Close()
+			},
+			StreamEndGatewayClosed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, clientPeer := newTCPConnPair(t)
+			upstream, upstreamPeer := newTCPConnPair(t)
+			o := NewStreamObserver()
+			observedClient := o.WrapClient(client)
+			observedUpstream := o.WrapUpstream(upstream)
+
+			if _, err := observedClient.Write([]byte("HTTP/1.1 101 Switching Protocols\r\nUpgrade: SPDY/3.1\r\n\r\n")); err != nil {
+				t.Fatalf("failed to write upgrade response: %v", err)
+			}
+			if got := o.Status(); got != http.StatusSwitchingProtocols {
+				t.Errorf("Status() = %v, want %v", got, http.StatusSwitchingProtocols)
+			}
+			// unread data makes close of client send a reset
+			if _, err := clientPeer.Read(make([]byte, 1024)); err != nil {
+				t.Fatalf("failed to read upgrade response: %v", err)
+			}
+
+			clientDone := make(chan struct{})
+			upstreamDone := make(chan struct{})
+			go func() {
+				buf := make([]byte, 1024)
+				for {
+					if _, err := observedClient.Read(buf); err != nil {
+						break
+					}
+				}
+				close(clientDone)
+			}()
+			go func() {
+				buf := make([]byte, 1024)
+				for {
+					if _, err := observedUpstream.Read(buf); err != nil {
+						break
+					}
+				}
+				close(upstreamDone)
+			}()
+
+			tt.end(client, clientPeer, upstream, upstreamPeer, o)
+			// proxy closes both connections after one side ended
+			select {
+			case <-clientDone:
+			case <-upstreamDone:
+			}
+			observedClient.Close()
+			observedUpstream.Close()
+			<-clientDone
+			<-upstreamDone
+
+			if got, _ := o.End(); got != tt.want {
+				t.Errorf("End() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamObserver_rejected(t *testing.T) {
+	client, _ := newTCPConnPair(t)
+	o := NewStreamObserver()
+	observedClient := o.WrapClient(client)
+	if _, err := observedClient.Write([]byte("HTTP/1.1 403 Forbidden\r\n")); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if _, err := observedClient.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n")); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got := o.Status(); got != http.StatusForbidden {
+		t.Errorf("Status() = %v, want %v", got, http.StatusForbidden)
+	}
+}
+
+func TestStreamObserver_beforeUpgrade(t *testing.T) {
+	upstream, upstreamPeer := newTCPConnPair(t)
+	o := NewStreamObserver()
+	observedUpstream := o.WrapUpstream(upstream)
+
+	// e.g. upstream resets the connection during TLS handshake
+	upstreamPeer.SetLinger(0) //nolint
+	upstreamPeer.Close()
+	if _, err := observedUpstream.Read(make([]byte, 1024)); err == nil {
+		t.Fatalf("Read() of reset connection succeeded")
+	}
+	if len(o.end) != 0 {
+		t.Errorf("upstream error before switching protocols is observed as stream end %q", o.end)
+	}
+}
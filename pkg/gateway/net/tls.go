@@ -0,0 +1,178 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cliflag "k8s.io/component-base/cli/flag"
+)
+
+// TLSPolicy restricts TLS parameters negotiated by a listener or an upstream transport,
+// zero values inherit from the base tls config.
+type TLSPolicy struct {
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	NextProtos       []string
+	// DisableHTTP2 removes h2 from negotiated protocols, for legacy clients which misbehave
+	// with HTTP/2 GOAWAY semantics
+	DisableHTTP2 bool
+}
+
+var curveIDs = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+// NewTLSPolicy parses policy from names, versions are like VersionTLS12, cipher suites are like
+// TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 and curves are P256, P384, P521 or X25519.
+func NewTLSPolicy(minVersion, maxVersion string, cipherSuites, curves, nextProtos []string) (*TLSPolicy, error) {
+	p := &TLSPolicy{NextProtos: nextProtos}
+	var err error
+	if len(minVersion) > 0 {
+		if p.MinVersion, err = cliflag.TLSVersion(minVersion); err != nil {
+			return nil, err
+		}
+	}
+	if len(maxVersion) > 0 {
+		if p.MaxVersion, err = cliflag.TLSVersion(maxVersion); err != nil {
+			return nil, err
+		}
+	}
+	if p.MinVersion != 0 && p.MaxVersion != 0 && p.MinVersion > p.MaxVersion {
+		return nil, fmt.Errorf("min version %s is greater than max version %s", minVersion, maxVersion)
+	}
+	if len(cipherSuites) > 0 {
+		if p.CipherSuites, err = cliflag.TLSCipherSuites(cipherSuites); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range curves {
+		id, ok := curveIDs[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown curve %q, must be one of P256, P384, P521 and X25519", name)
+		}
+		p.CurvePreferences = append(p.CurvePreferences, id)
+	}
+	return p, nil
+}
+
+// ParseTLSPolicy parses policy from a comma separated list of key=value, keys are minVersion,
+// maxVersion, cipherSuites, curves, alpn and http2, lists are separated by |, e.g.
+// minVersion=VersionTLS12,cipherSuites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256|TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256
+func ParseTLSPolicy(value string) (*TLSPolicy, error) {
+	var minVersion, maxVersion string
+	var cipherSuites, curves, nextProtos []string
+	disableHTTP2 := false
+	for _, s := range strings.Split(value, ",") {
+		if len(s) == 0 {
+			continue
+		}
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing value for tls policy %q", s)
+		}
+		v := strings.TrimSpace(kv[1])
+		switch strings.TrimSpace(kv[0]) {
+		case "minVersion":
+			minVersion = v
+		case "maxVersion":
+			maxVersion = v
+		case "cipherSuites":
+			cipherSuites = strings.Split(v, "|")
+		case "curves":
+			curves = strings.Split(v, "|")
+		case "alpn":
+			nextProtos = strings.Split(v, "|")
+		case "http2":
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of http2=%s, must be true or false", v)
+			}
+			disableHTTP2 = !enabled
+		default:
+			return nil, fmt.Errorf("unrecognized tls policy %q", kv[0])
+		}
+	}
+	p, err := NewTLSPolicy(minVersion, maxVersion, cipherSuites, curves, nextProtos)
+	if err != nil {
+		return nil, err
+	}
+	p.DisableHTTP2 = disableHTTP2
+	return p, nil
+}
+
+// Empty returns true if p changes nothing
+func (p *TLSPolicy) Empty() bool {
+	return p == nil || (p.MinVersion == 0 && p.MaxVersion == 0 && len(p.CipherSuites) == 0 &&
+		len(p.CurvePreferences) == 0 && len(p.NextProtos) == 0 && !p.DisableHTTP2)
+}
+
+// ApplyTo overrides parameters of config by p, config should be a clone if it is shared.
+// Cipher suites only take effect for TLS 1.2 and earlier, TLS 1.3 suites are not configurable.
+func (p *TLSPolicy) ApplyTo(config *tls.Config) {
+	if p == nil {
+		return
+	}
+	if p.MinVersion != 0 {
+		config.MinVersion = p.MinVersion
+	}
+	if p.MaxVersion != 0 {
+		config.MaxVersion = p.MaxVersion
+	}
+	if len(p.CipherSuites) > 0 {
+		config.CipherSuites = p.CipherSuites
+	}
+	if len(p.CurvePreferences) > 0 {
+		config.CurvePreferences = p.CurvePreferences
+	}
+	if len(p.NextProtos) > 0 {
+		config.NextProtos = p.NextProtos
+	}
+	if p.DisableHTTP2 {
+		protos := []string{}
+		for _, proto := range config.NextProtos {
+			if proto != "h2" {
+				protos = append(protos, proto)
+			}
+		}
+		if len(protos) == 0 {
+			protos = append(protos, "http/1.1")
+		}
+		config.NextProtos = protos
+	}
+}
+
+// TLSVersionName returns the name of TLS version, e.g. TLS1.2
+func TLSVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS1.0"
+	case tls.VersionTLS11:
+		return "TLS1.1"
+	case tls.VersionTLS12:
+		return "TLS1.2"
+	case tls.VersionTLS13:
+		return "TLS1.3"
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package net
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+)
+
+func TestParseTLSPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    *TLSPolicy
+		wantErr bool
+	}{
+		{"empty", "", &TLSPolicy{}, false},
+		{
+			"all keys",
+			"minVersion=VersionTLS12,maxVersion=VersionTLS13,cipherSuites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256|TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,curves=X25519|p256,alpn=http/1.1",
+			&TLSPolicy{
+				MinVersion:       tls.VersionTLS12,
+				MaxVersion:       tls.VersionTLS13,
+				CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+				CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+				NextProtos:       []string{"http/1.1"},
+			},
+			false,
+		},
+		{"disable http2", "http2=false", &TLSPolicy{DisableHTTP2: true}, false},
+		{"invalid http2", "http2=no", nil, true},
+		{"min greater than max", "minVersion=VersionTLS13,maxVersion=VersionTLS12", nil, true},
+		{"unknown version", "minVersion=SSLv3", nil, true},
+		{"unknown cipher", "cipherSuites=TLS_FOO", nil, true},
+		{"unknown curve", "curves=P224", nil, true},
+		{"unknown key", "sessionTickets=false", nil, true},
+		{"missing value", "minVersion", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTLSPolicy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTLSPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTLSPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSPolicy_ApplyTo(t *testing.T) {
+	config := &tls.Config{MinVersion: tls.VersionTLS10, NextProtos: []string{"h2", "http/1.1"}}
+	policy := &TLSPolicy{MinVersion: tls.VersionTLS12}
+	policy.ApplyTo(config)
+	if config.MinVersion != tls.VersionTLS12 {
+		t.Errorf("ApplyTo() MinVersion = %x, want %x", config.MinVersion, tls.VersionTLS12)
+	}
+	if !reflect.DeepEqual(config.NextProtos, []string{"h2", "http/1.1"}) {
+		t.Errorf("ApplyTo() changed unset NextProtos to %v", config.NextProtos)
+	}
+}
+
+func TestTLSPolicy_ApplyTo_DisableHTTP2(t *testing.T) {
+	tests := []struct {
+		name       string
+		nextProtos []string
+		policy     *TLSPolicy
+		want       []string
+	}{
+		{"remove h2", []string{"h2", "http/1.1"}, &TLSPolicy{DisableHTTP2: true}, []string{"http/1.1"}},
+		{"only h2", []string{"h2"}, &TLSPolicy{DisableHTTP2: true}, []string{"http/1.1"}},
+		{"with alpn", []string{"h2", "http/1.1"}, &TLSPolicy{NextProtos: []string{"h2", "http/1.1"}, DisableHTTP2: true}, []string{"http/1.1"}},
+		{"alpn preference", []string{"h2", "http/1.1"}, &TLSPolicy{NextProtos: []string{"http/1.1", "h2"}}, []string{"http/1.1", "h2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &tls.Config{NextProtos: tt.nextProtos}
+			tt.policy.ApplyTo(config)
+			if !reflect.DeepEqual(config.NextProtos, tt.want) {
+				t.Errorf("ApplyTo() NextProtos = %v, want %v", config.NextProtos, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package notification delivers significant decisions of gateway, e.g. a circuit breaker is opened
+// or an upstream cluster has no ready endpoints, to an external sink, so incident automation can
+// react to them without scraping logs or metrics.
+package notification
+
+import (
+	"time"
+)
+
+// Types of events
+const (
+	// EventCircuitBreakerOpened means all requests to the cluster are denied by feature gate DenyAllRequests
+	EventCircuitBreakerOpened = "CircuitBreakerOpened"
+	// EventCircuitBreakerClosed means requests to the cluster are no longer denied by DenyAllRequests
+	EventCircuitBreakerClosed = "CircuitBreakerClosed"
+	// EventClusterUnavailable means the cluster had ready endpoints before but has none now
+	EventClusterUnavailable = "ClusterUnavailable"
+	// EventClusterRecovered means an unavailable cluster has ready endpoints again
+	EventClusterRecovered = "ClusterRecovered"
+	// EventEndpointUnhealthy means a healthy endpoint failed its health check
+	EventEndpointUnhealthy = "EndpointUnhealthy"
+	// EventEndpointRecovered means an endpoint reported by EventEndpointUnhealthy is healthy again
+	EventEndpointRecovered = "EndpointRecovered"
+)
+
+// Event is a significant decision made by gateway
+type Event struct {
+	Type     string `json:"type"`
+	Cluster  string `json:"cluster"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Sequence orders events of the same endpoint, it increases monotonically for each status
+	// transition of the endpoint
+	Sequence uint64    `json:"sequence,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Sink receives events, Notify must not block the caller
+type Sink interface {
+	Notify(event Event)
+}
+
+// Default is the sink of all events emitted by Notify, nil means events are dropped.
+// Notify reads it without locking from any goroutine, NotificationOptions sets it before
+// the controllers emitting events start.
+var Default Sink
+
+// Notify sends event to Default sink if there is one, Time is set to now if it is zero
+func Notify(event Event) {
+	sink := Default
+	if sink == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	sink.Notify(event)
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// SignatureHeader carries "sha256=" followed by hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+	// keyed by the shared secret, receivers should verify it and reject stale timestamps.
+	SignatureHeader = "X-Kubegateway-Signature"
+	// TimestampHeader carries the unix seconds when the batch is signed
+	TimestampHeader = "X-Kubegateway-Timestamp"
+
+	webhookTimeout = 5 * time.Second
+	// responses of webhook are drained up to it so connections can be reused
+	maxDrainBytes = 4 << 10
+
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultDropped   = "dropped"
+)
+
+// WebhookConfig configures a WebhookSink
+type WebhookConfig struct {
+	// URL is where batches are posted in JSON
+	URL string
+	// Secret signs batches if it is not empty, see SignatureHeader
+	Secret []byte
+	// BatchSize is the maximum number of events in one batch
+	BatchSize int
+	// FlushInterval is the maximum duration an event waits for its batch to be full
+	FlushInterval time.Duration
+	// MaxRetries is the number of retries of a batch after connection errors, 429 or 5xx responses
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, it doubles for each following retry
+	RetryBackoff time.Duration
+	// QueueSize is the maximum number of events waiting for delivery, events beyond it are dropped
+	QueueSize int
+}
+
+// Batch is the JSON body posted to the webhook
+type Batch struct {
+	// Source is the hostname of gateway sending the batch
+	Source string  `json:"source"`
+	Events []Event `json:"events"`
+}
+
+// WebhookSink posts events to a webhook in batches. Events are queued without blocking the
+// caller, and delivered by Run in the background, a batch is retried with exponential backoff
+// and given up after MaxRetries, so a slow or broken webhook never slows down gateway.
+type WebhookSink struct {
+	config WebhookConfig
+	source string
+	client *http.Client
+	queue  chan Event
+}
+
+// NewWebhookSink creates a sink posting events to config.URL, Run must be called to deliver them
+func NewWebhookSink(config WebhookConfig) *WebhookSink {
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1
+	}
+	if config.QueueSize < config.BatchSize {
+		config.QueueSize = config.BatchSize
+	}
+	hostname, _ := os.Hostname()
+	return &WebhookSink{
+		config: config,
+		source: hostname,
+		client: &http.Client{Timeout: webhookTimeout},
+		queue:  make(chan Event, config.QueueSize),
+	}
+}
+
+// Notify queues event for delivery, it is dropped if the queue is full
+func (s *WebhookSink) Notify(event Event) {
+	select {
+	case s.queue <- event:
+	default:
+		metrics.RecordNotification(event.Type, resultDropped)
+		klog.Warningf("[notification] queue is full, drop event type=%q cluster=%q endpoint=%q", event.Type, event.Cluster, event.Endpoint)
+	}
+}
+
+// Run delivers queued events until stopCh is closed, events queued when it is closed are
+// delivered once more without retries.
+func (s *WebhookSink) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, s.config.BatchSize)
+	for {
+		select {
+		case event := <-s.queue:
+			batch = append(batch, event)
+			if len(batch) < s.config.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-stopCh:
+			batch = append(batch, s.drain()...)
+			if len(batch) > 0 {
+				s.deliver(batch, nil)
+			}
+			return
+		}
+		s.deliver(batch, stopCh)
+		batch = make([]Event, 0, s.config.BatchSize)
+	}
+}
+
+// drain returns all events in the queue without waiting
+func (s *WebhookSink) drain() []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-s.queue:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// deliver posts batch to the webhook with retries, retries are canceled once stopCh is closed
+func (s *WebhookSink) deliver(events []Event, stopCh <-chan struct{}) {
+	body, err := json.Marshal(Batch{Source: s.source, Events: events})
+	if err != nil {
+		klog.Errorf("[notification] failed to marshal %d events: %v", len(events), err)
+		recordNotifications(events, resultFailed)
+		return
+	}
+
+	backoff := s.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retriable, err := s.post(body)
+		if err == nil {
+			recordNotifications(events, resultDelivered)
+			return
+		}
+		if !retriable || attempt >= s.config.MaxRetries || stopCh == nil {
+			klog.Errorf("[notification] failed to post %d events to webhook after %d attempts: %v", len(events), attempt+1, err)
+			recordNotifications(events, resultFailed)
+			return
+		}
+		klog.V(2).Infof("[notification] failed to post %d events to webhook, retry after %v: %v", len(events), backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-stopCh:
+			stopCh = nil
+		}
+		backoff *= 2
+	}
+}
+
+// post posts body once and returns whether a failure is worth retrying
+func (s *WebhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.config.Secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign(s.config.Secret, timestamp, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainBytes)) //nolint
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("webhook responded %s", resp.Status)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the value of SignatureHeader of body signed at timestamp by secret
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func recordNotifications(events []Event, result string) {
+	for _, e := range events {
+		metrics.RecordNotification(e.Type, result)
+	}
+}
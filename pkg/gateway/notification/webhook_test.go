@@ -0,0 +1,106 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notification
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestWebhookSink(t *testing.T) {
+	secret := []byte("secret")
+
+	var lock sync.Mutex
+	attempts := 0
+	batches := []Batch{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		defer lock.Unlock()
+		attempts++
+		if attempts == 1 {
+			// the first batch is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if got, want := r.Header.Get(SignatureHeader), Sign(secret, r.Header.Get(TimestampHeader), body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		var batch Batch
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(WebhookConfig{
+		URL:           server.URL,
+		Secret:        secret,
+		BatchSize:     2,
+		FlushInterval: 50 * time.Millisecond,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+		QueueSize:     10,
+	})
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		sink.Run(stopCh)
+		close(done)
+	}()
+
+	sink.Notify(Event{Type: EventEndpointUnhealthy, Cluster: "a", Endpoint: "https://1.1.1.1"})
+	sink.Notify(Event{Type: EventClusterUnavailable, Cluster: "a"})
+	sink.Notify(Event{Type: EventCircuitBreakerOpened, Cluster: "b"})
+
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(batches) == 2, nil
+	}); err != nil {
+		t.Fatalf("batches are not delivered")
+	}
+	close(stopCh)
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	if len(batches[0].Events) != 2 || batches[0].Events[1].Type != EventClusterUnavailable {
+		t.Errorf("first batch = %+v, want the first 2 events", batches[0])
+	}
+	if len(batches[1].Events) != 1 || batches[1].Events[0].Cluster != "b" {
+		t.Errorf("second batch = %+v, want the last event flushed by interval", batches[1])
+	}
+}
+
+func TestWebhookSink_dropWhenFull(t *testing.T) {
+	sink := NewWebhookSink(WebhookConfig{URL: "http://127.0.0.1:0", BatchSize: 1, QueueSize: 1, FlushInterval: time.Second})
+	sink.Notify(Event{Type: EventClusterUnavailable})
+	sink.Notify(Event{Type: EventClusterRecovered})
+	if events := sink.drain(); len(events) != 1 || events[0].Type != EventClusterUnavailable {
+		t.Errorf("queued events = %+v, want only the first one", events)
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package onboarding generates UpstreamCluster manifests from kubeconfigs of new clusters, with
+// flow controls recommended by a quick probe of cluster size, to streamline onboarding clusters.
+package onboarding
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1/validation"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+const (
+	// names of generated flow control schemas
+	FlowControlDefault  = "default"
+	FlowControlMutating = "mutating"
+)
+
+var mutatingVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// ClusterSize is the scale of a cluster found by probing, used to recommend flow controls
+type ClusterSize struct {
+	Nodes int64
+	Pods  int64
+}
+
+// Recommendation is the recommended flow controls of a cluster
+type Recommendation struct {
+	// MaxRequestsInflight bounds concurrent requests of all clients
+	MaxRequestsInflight int32
+	// MutatingQPS and MutatingBurst bound writes of all clients
+	MutatingQPS   int32
+	MutatingBurst int32
+	// MaxQPS is the aggregate ceiling of the cluster, see clusters.CeilingsAnnotationKey
+	MaxQPS int32
+}
+
+// tiers of recommendations by cluster size, similar to max-requests-inflight used by
+// kube-apiserver at those scales
+var tiers = []struct {
+	nodes          int64
+	recommendation Recommendation
+}{
+	{100, Recommendation{MaxRequestsInflight: 400, MutatingQPS: 100, MutatingBurst: 200, MaxQPS: 1000}},
+	{500, Recommendation{MaxRequestsInflight: 800, MutatingQPS: 200, MutatingBurst: 400, MaxQPS: 2000}},
+	{2000, Recommendation{MaxRequestsInflight: 1600, MutatingQPS: 400, MutatingBurst: 800, MaxQPS: 4000}},
+	{-1, Recommendation{MaxRequestsInflight: 3000, MutatingQPS: 800, MutatingBurst: 1600, MaxQPS: 8000}},
+}
+
+// podsPerNode converts pods to equivalent nodes, so clusters with dense nodes get larger limits
+const podsPerNode = 30
+
+// Recommend returns the recommended flow controls of cluster of size
+func Recommend(size ClusterSize) Recommendation {
+	scale := size.Nodes
+	if pods := size.Pods / podsPerNode; pods > scale {
+		scale = pods
+	}
+	for _, tier := range tiers {
+		if tier.nodes < 0 || scale <= tier.nodes {
+			return tier.recommendation
+		}
+	}
+	return tiers[len(tiers)-1].recommendation
+}
+
+// ProbeClusterSize counts nodes and pods of cluster with lists of one item, so probing a large
+// cluster is as cheap as probing a small one.
+func ProbeClusterSize(ctx context.Context, client kubernetes.Interface) (ClusterSize, error) {
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return ClusterSize{}, fmt.Errorf("failed to list nodes: %v", err)
+	}
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{Limit: 1})
+	if err != nil {
+		return ClusterSize{}, fmt.Errorf("failed to list pods: %v", err)
+	}
+	return ClusterSize{
+		Nodes: countOf(len(nodes.Items), nodes.ListMeta),
+		Pods:  countOf(len(pods.Items), pods.ListMeta),
+	}, nil
+}
+
+func countOf(items int, meta metav1.ListMeta) int64 {
+	count := int64(items)
+	if meta.RemainingItemCount != nil {
+		count += *meta.RemainingItemCount
+	}
+	return count
+}
+
+// DiscoverEndpoints returns endpoints of all apiservers of cluster from the kubernetes service,
+// the scheme is the same as server.
+func DiscoverEndpoints(ctx context.Context, client kubernetes.Interface, server string) ([]string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server %q: %v", server, err)
+	}
+	endpoints, err := client.CoreV1().Endpoints(metav1.NamespaceDefault).Get(ctx, "kubernetes", metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints of kubernetes service: %v", err)
+	}
+	result := []string{}
+	for _, subset := range endpoints.Subsets {
+		port := httpsPortOf(subset.Ports)
+		if port == 0 {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			result = append(result, u.Scheme+"://"+net.JoinHostPort(address.IP, strconv.Itoa(int(port))))
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no ready endpoint found in kubernetes service")
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func httpsPortOf(ports []corev1.EndpointPort) int32 {
+	for _, p := range ports {
+		if p.Name == "https" {
+			return p.Port
+		}
+	}
+	if len(ports) == 1 {
+		return ports[0].Port
+	}
+	return 0
+}
+
+// NewUpstreamCluster returns a validated UpstreamCluster named name, which proxies to endpoints
+// with credentials of config and flow controls of recommendation. UpstreamCluster has no secret
+// reference, so the client key or bearer token of config has to be embedded into it, which is
+// refused unless inlineCredentials is true. Exec credential plugins and auth providers are not supported.
+func NewUpstreamCluster(name string, config *rest.Config, endpoints []string, recommendation Recommendation, inlineCredentials bool) (*proxyv1alpha1.UpstreamCluster, error) {
+	clientConfig, err := clientConfigOf(config, inlineCredentials)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		endpoints = []string{config.Host}
+	}
+	servers := make([]proxyv1alpha1.UpstreamClusterServer, 0, len(endpoints))
+	for _, e := range endpoints {
+		servers = append(servers, proxyv1alpha1.UpstreamClusterServer{Endpoint: e})
+	}
+
+	allResources := proxyv1alpha1.DispatchPolicyRule{
+		Verbs:     []string{proxyv1alpha1.MatchAll},
+		APIGroups: []string{proxyv1alpha1.MatchAll},
+		Resources: []string{proxyv1alpha1.MatchAll},
+	}
+	mutating := allResources
+	mutating.Verbs = mutatingVerbs
+
+	cluster := &proxyv1alpha1.UpstreamCluster{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: proxyv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "UpstreamCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				clusters.CeilingsAnnotationKey: fmt.Sprintf("maxQPS=%d", recommendation.MaxQPS),
+			},
+		},
+		Spec: proxyv1alpha1.UpstreamClusterSpec{
+			Servers:      servers,
+			ClientConfig: *clientConfig,
+			FlowControl: proxyv1alpha1.FlowControl{
+				Schemas: []proxyv1alpha1.FlowControlSchema{
+					{
+						Name: FlowControlMutating,
+						FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+							TokenBucket: &proxyv1alpha1.TokenBucketFlowControlSchema{QPS: recommendation.MutatingQPS, Burst: recommendation.MutatingBurst},
+						},
+					},
+					{
+						Name: FlowControlDefault,
+						FlowControlSchemaConfiguration: proxyv1alpha1.FlowControlSchemaConfiguration{
+							MaxRequestsInflight: &proxyv1alpha1.MaxRequestsInflightFlowControlSchema{Max: recommendation.MaxRequestsInflight},
+						},
+					},
+				},
+			},
+			DispatchPolicies: []proxyv1alpha1.DispatchPolicy{
+				{
+					Strategy:              proxyv1alpha1.RoundRobin,
+					Rules:                 []proxyv1alpha1.DispatchPolicyRule{mutating},
+					FlowControlSchemaName: FlowControlMutating,
+				},
+				{
+					Strategy:              proxyv1alpha1.RoundRobin,
+					Rules:                 []proxyv1alpha1.DispatchPolicyRule{allResources},
+					FlowControlSchemaName: FlowControlDefault,
+				},
+				{
+					Strategy: proxyv1alpha1.RoundRobin,
+					Rules: []proxyv1alpha1.DispatchPolicyRule{{
+						Verbs:           []string{proxyv1alpha1.MatchAll},
+						NonResourceURLs: []string{proxyv1alpha1.MatchAll},
+					}},
+				},
+			},
+		},
+	}
+
+	if errs := validation.ValidateUpstreamCluster(cluster); len(errs) > 0 {
+		return nil, fmt.Errorf("generated UpstreamCluster is invalid: %v", errs.ToAggregate())
+	}
+	return cluster, nil
+}
+
+// clientConfigOf returns the client config of upstream cluster with static credentials of config,
+// it fails if inlineCredentials is false since the credentials are copied into the result.
+func clientConfigOf(config *rest.Config, inlineCredentials bool) (*proxyv1alpha1.ClientConfig, error) {
+	if config.ExecProvider != nil || config.AuthProvider != nil {
+		return nil, fmt.Errorf("exec credential plugins and auth providers of kubeconfig are not supported, use a kubeconfig with a client certificate or bearer token")
+	}
+	// load files referenced by kubeconfig into data
+	config = rest.CopyConfig(config)
+	if err := rest.LoadTLSFiles(config); err != nil {
+		return nil, fmt.Errorf("failed to load tls files: %v", err)
+	}
+	token := config.BearerToken
+	if len(config.BearerTokenFile) > 0 {
+		data, err := ioutil.ReadFile(config.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read bearer token file: %v", err)
+		}
+		token = string(data)
+	}
+	if len(token) == 0 && (len(config.CertData) == 0 || len(config.KeyData) == 0) {
+		return nil, fmt.Errorf("kubeconfig must have a client certificate or bearer token")
+	}
+	if !inlineCredentials {
+		return nil, fmt.Errorf("refusing to embed the client key or bearer token of kubeconfig into UpstreamCluster without inline credentials")
+	}
+	clientConfig := &proxyv1alpha1.ClientConfig{
+		Insecure: config.Insecure,
+		CAData:   config.CAData,
+	}
+	if len(config.CertData) > 0 && len(config.KeyData) > 0 {
+		clientConfig.CertData = config.CertData
+		clientConfig.KeyData = config.KeyData
+	} else {
+		clientConfig.BearerToken = []byte(token)
+	}
+	return clientConfig, nil
+}
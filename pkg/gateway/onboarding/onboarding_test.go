@@ -0,0 +1,124 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package onboarding
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	certutil "k8s.io/client-go/util/cert"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+func TestRecommend(t *testing.T) {
+	tests := []struct {
+		name string
+		size ClusterSize
+		want int32
+	}{
+		{"empty", ClusterSize{}, 400},
+		{"small", ClusterSize{Nodes: 100, Pods: 3000}, 400},
+		{"dense pods", ClusterSize{Nodes: 10, Pods: 30000}, 1600},
+		{"medium", ClusterSize{Nodes: 300}, 800},
+		{"huge", ClusterSize{Nodes: 5000}, 3000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Recommend(tt.size).MaxRequestsInflight; got != tt.want {
+				t.Errorf("Recommend() MaxRequestsInflight = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeClusterSizeAndDiscoverEndpoints(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n1"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n2"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "p1"}},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "kubernetes"},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "10.0.0.1"}},
+				Ports:     []corev1.EndpointPort{{Name: "https", Port: 6443}},
+			}},
+		},
+	)
+	size, err := ProbeClusterSize(context.TODO(), client)
+	if err != nil {
+		t.Fatalf("ProbeClusterSize() error = %v", err)
+	}
+	// fake clientset ignores limit
+	if size.Nodes != 2 || size.Pods != 1 {
+		t.Errorf("ProbeClusterSize() = %+v, want nodes=2 pods=1", size)
+	}
+
+	endpoints, err := DiscoverEndpoints(context.TODO(), client, "https://apiserver.example.com")
+	if err != nil {
+		t.Fatalf("DiscoverEndpoints() error = %v", err)
+	}
+	want := []string{"https://10.0.0.1:6443", "https://10.0.0.2:6443"}
+	if len(endpoints) != len(want) || endpoints[0] != want[0] || endpoints[1] != want[1] {
+		t.Errorf("DiscoverEndpoints() = %v, want %v", endpoints, want)
+	}
+}
+
+func TestNewUpstreamCluster(t *testing.T) {
+	ca, _, err := certutil.GenerateSelfSignedCertKey("apiserver.example.com", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &rest.Config{
+		Host:        "https://apiserver.example.com",
+		BearerToken: "token",
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: ca,
+		},
+	}
+	cluster, err := NewUpstreamCluster("example", config, nil, Recommend(ClusterSize{Nodes: 300}), true)
+	if err != nil {
+		t.Fatalf("NewUpstreamCluster() error = %v", err)
+	}
+	if len(cluster.Spec.Servers) != 1 || cluster.Spec.Servers[0].Endpoint != config.Host {
+		t.Errorf("NewUpstreamCluster() servers = %v", cluster.Spec.Servers)
+	}
+	if string(cluster.Spec.ClientConfig.BearerToken) != "token" || string(cluster.Spec.ClientConfig.CAData) != string(ca) {
+		t.Errorf("NewUpstreamCluster() client config = %+v", cluster.Spec.ClientConfig)
+	}
+	if got := cluster.Annotations[clusters.CeilingsAnnotationKey]; got != "maxQPS=2000" {
+		t.Errorf("NewUpstreamCluster() ceilings = %v", got)
+	}
+
+	// credentials are not allowed to be inlined
+	if _, err := NewUpstreamCluster("example", config, nil, Recommendation{}, false); err == nil {
+		t.Errorf("NewUpstreamCluster() without inline credentials should fail")
+	}
+	// no credentials
+	if _, err := NewUpstreamCluster("example", &rest.Config{Host: config.Host, TLSClientConfig: config.TLSClientConfig}, nil, Recommendation{}, true); err == nil {
+		t.Errorf("NewUpstreamCluster() without credentials should fail")
+	}
+	// exec credential plugin
+	exec := rest.CopyConfig(config)
+	exec.ExecProvider = &clientcmdapi.ExecConfig{Command: "get-token"}
+	if _, err := NewUpstreamCluster("example", exec, nil, Recommendation{}, true); err == nil {
+		t.Errorf("NewUpstreamCluster() with exec credential plugin should fail")
+	}
+}
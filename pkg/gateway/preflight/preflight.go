@@ -0,0 +1,281 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preflight validates gateway configuration before serving, including TLS material
+// and reachability of upstream clusters, so that broken config changes can be caught in CI.
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+// names of checks in report
+const (
+	CheckServingCert      = "serving-cert"
+	CheckCABundle         = "ca-bundle"
+	CheckUpstreamTLS      = "upstream-tls"
+	CheckEndpointResolve  = "endpoint-resolve"
+	CheckClusterReachable = "cluster-reachable"
+)
+
+// CertKeyPair is a pair of PEM-encoded certificate file and key file
+type CertKeyPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// Config is what preflight checks verify
+type Config struct {
+	// ServingCerts are certificates which the gateway serves with
+	ServingCerts []CertKeyPair
+	// CAFiles are CA bundles which the gateway verifies peers with
+	CAFiles []string
+	// Clusters are upstream clusters to check
+	Clusters []*proxyv1alpha1.UpstreamCluster
+	// RequiredClusters must have at least one reachable endpoint, others are checked but never fail the report
+	RequiredClusters []string
+	// Timeout bounds resolving and dialing of each endpoint
+	Timeout time.Duration
+
+	// resolver and dialer are replaced in tests
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	dial       func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Result is the result of one check against one target
+type Result struct {
+	Check   string `json:"check"`
+	Target  string `json:"target"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the machine-readable report of all preflight checks
+type Report struct {
+	Passed  bool     `json:"passed"`
+	Results []Result `json:"results"`
+}
+
+func (r *Report) add(check, target string, err error) {
+	result := Result{Check: check, Target: target, Passed: err == nil}
+	if err != nil {
+		result.Message = err.Error()
+		r.Passed = false
+	}
+	r.Results = append(r.Results, result)
+}
+
+// Failures returns all failed results
+func (r *Report) Failures() []Result {
+	failed := []Result{}
+	for _, result := range r.Results {
+		if !result.Passed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Run runs all preflight checks, it never stops at the first failure so that all problems are reported at once
+func Run(ctx context.Context, cfg Config) *Report {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.lookupHost == nil {
+		cfg.lookupHost = net.DefaultResolver.LookupHost
+	}
+	if cfg.dial == nil {
+		cfg.dial = (&net.Dialer{}).DialContext
+	}
+
+	report := &Report{Passed: true, Results: []Result{}}
+	for _, pair := range cfg.ServingCerts {
+		_, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		report.add(CheckServingCert, pair.CertFile, err)
+	}
+	for _, file := range cfg.CAFiles {
+		report.add(CheckCABundle, file, checkCAFile(file))
+	}
+
+	required := map[string]bool{}
+	for _, name := range cfg.RequiredClusters {
+		required[name] = true
+	}
+	for _, cluster := range cfg.Clusters {
+		report.add(CheckUpstreamTLS, cluster.Name, checkUpstreamTLS(cluster))
+		reachable := checkEndpoints(ctx, cfg, cluster, report)
+		var err error
+		if reachable == 0 {
+			err = fmt.Errorf("none of %d endpoints is reachable", len(cluster.Spec.Servers))
+		}
+		if err != nil && !required[cluster.Name] {
+			// recorded but not failing the report
+			report.Results = append(report.Results, Result{Check: CheckClusterReachable, Target: cluster.Name, Passed: true, Message: err.Error() + ", ignored because the cluster is not required"})
+		} else {
+			report.add(CheckClusterReachable, cluster.Name, err)
+		}
+		delete(required, cluster.Name)
+	}
+	for name := range required {
+		report.add(CheckClusterReachable, name, fmt.Errorf("required cluster is not configured"))
+	}
+	return report
+}
+
+func checkCAFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return checkCAData(data)
+}
+
+func checkCAData(data []byte) error {
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return fmt.Errorf("no valid PEM-encoded certificate found")
+	}
+	return nil
+}
+
+func checkUpstreamTLS(cluster *proxyv1alpha1.UpstreamCluster) error {
+	client := cluster.Spec.ClientConfig
+	if len(client.CAData) > 0 {
+		if err := checkCAData(client.CAData); err != nil {
+			return fmt.Errorf("invalid clientConfig.caData: %v", err)
+		}
+	}
+	if len(client.CertData) > 0 || len(client.KeyData) > 0 {
+		if _, err := tls.X509KeyPair(client.CertData, client.KeyData); err != nil {
+			return fmt.Errorf("invalid clientConfig.certData and keyData: %v", err)
+		}
+	}
+	serving := cluster.Spec.SecureServing
+	if len(serving.CertData) > 0 || len(serving.KeyData) > 0 {
+		if _, err := tls.X509KeyPair(serving.CertData, serving.KeyData); err != nil {
+			return fmt.Errorf("invalid secureServing.certData and keyData: %v", err)
+		}
+	}
+	if len(serving.ClientCAData) > 0 {
+		if err := checkCAData(serving.ClientCAData); err != nil {
+			return fmt.Errorf("invalid secureServing.clientCAData: %v", err)
+		}
+	}
+	return nil
+}
+
+// checkEndpoints resolves and dials all endpoints of cluster concurrently, it returns the number of reachable endpoints
+func checkEndpoints(ctx context.Context, cfg Config, cluster *proxyv1alpha1.UpstreamCluster, report *Report) int {
+	resolveErrs := make([]error, len(cluster.Spec.Servers))
+	dialErrs := make([]error, len(cluster.Spec.Servers))
+	var wg sync.WaitGroup
+	for i, server := range cluster.Spec.Servers {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			resolveErrs[i], dialErrs[i] = checkEndpoint(ctx, cfg, endpoint)
+		}(i, server.Endpoint)
+	}
+	wg.Wait()
+
+	n := 0
+	for i, server := range cluster.Spec.Servers {
+		target := cluster.Name + "/" + server.Endpoint
+		if resolveErrs[i] != nil {
+			report.add(CheckEndpointResolve, target, resolveErrs[i])
+			continue
+		}
+		result := Result{Check: CheckEndpointResolve, Target: target, Passed: true}
+		if dialErrs[i] != nil {
+			// unreachable endpoints fail cluster-reachable check only if all endpoints are unreachable
+			result.Message = fmt.Sprintf("unreachable: %v", dialErrs[i])
+		} else {
+			n++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return n
+}
+
+// checkEndpoint returns error if endpoint is invalid or can not be resolved, and error if it can not be dialed
+func checkEndpoint(ctx context.Context, cfg Config, endpoint string) (resolveErr error, dialErr error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err, nil
+	}
+	host, port := u.Hostname(), u.Port()
+	if len(host) == 0 {
+		return fmt.Errorf("endpoint has no host"), nil
+	}
+	if len(port) == 0 {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	if net.ParseIP(host) == nil {
+		if _, err := cfg.lookupHost(ctx, host); err != nil {
+			return err, nil
+		}
+	}
+	conn, err := cfg.dial(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return nil, nil
+}
+
+// LoadUpstreamClusters reads UpstreamCluster manifests in yaml or json format, a file may contain multiple documents
+func LoadUpstreamClusters(files []string) ([]*proxyv1alpha1.UpstreamCluster, error) {
+	result := []*proxyv1alpha1.UpstreamCluster{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			cluster := &proxyv1alpha1.UpstreamCluster{}
+			if err := decoder.Decode(cluster); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("failed to decode upstream clusters in %q: %v", file, err)
+			}
+			if len(cluster.Name) == 0 {
+				// empty document
+				continue
+			}
+			result = append(result, cluster)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+)
+
+func newTestCluster(name string, endpoints ...string) *proxyv1alpha1.UpstreamCluster {
+	cluster := &proxyv1alpha1.UpstreamCluster{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	for _, e := range endpoints {
+		cluster.Spec.Servers = append(cluster.Spec.Servers, proxyv1alpha1.UpstreamClusterServer{Endpoint: e})
+	}
+	return cluster
+}
+
+func TestRun(t *testing.T) {
+	reachable := map[string]bool{"10.0.0.1:6443": true, "a.example.com:443": true}
+	cfg := Config{
+		lookupHost: func(ctx context.Context, host string) ([]string, error) {
+			if host == "unknown.example.com" {
+				return nil, fmt.Errorf("no such host")
+			}
+			return []string{"10.0.0.2"}, nil
+		},
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if !reachable[address] {
+				return nil, fmt.Errorf("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		clusters []*proxyv1alpha1.UpstreamCluster
+		required []string
+		want     bool
+	}{
+		{"all reachable", []*proxyv1alpha1.UpstreamCluster{newTestCluster("a", "https://10.0.0.1:6443", "https://a.example.com")}, []string{"a"}, true},
+		{"one endpoint reachable", []*proxyv1alpha1.UpstreamCluster{newTestCluster("a", "https://10.0.0.1:6443", "https://10.0.0.3:6443")}, []string{"a"}, true},
+		{"unresolvable endpoint", []*proxyv1alpha1.UpstreamCluster{newTestCluster("a", "https://10.0.0.1:6443", "https://unknown.example.com")}, nil, false},
+		{"required cluster unreachable", []*proxyv1alpha1.UpstreamCluster{newTestCluster("b", "https://10.0.0.3:6443")}, []string{"b"}, false},
+		{"optional cluster unreachable", []*proxyv1alpha1.UpstreamCluster{newTestCluster("b", "https://10.0.0.3:6443")}, nil, true},
+		{"required cluster not configured", nil, []string{"c"}, false},
+		{"invalid ca data", []*proxyv1alpha1.UpstreamCluster{func() *proxyv1alpha1.UpstreamCluster {
+			c := newTestCluster("a", "https://10.0.0.1:6443")
+			c.Spec.ClientConfig.CAData = []byte("invalid")
+			return c
+		}()}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cfg
+			cfg.Clusters = tt.clusters
+			cfg.RequiredClusters = tt.required
+			report := Run(context.TODO(), cfg)
+			if report.Passed != tt.want {
+				t.Errorf("Run() passed = %v, want %v, results: %+v", report.Passed, tt.want, report.Results)
+			}
+		})
+	}
+}
+
+func TestRun_tlsMaterial(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.pem")
+	if err := ioutil.WriteFile(invalid, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	report := Run(context.TODO(), Config{
+		ServingCerts: []CertKeyPair{{CertFile: invalid, KeyFile: invalid}},
+		CAFiles:      []string{invalid, filepath.Join(dir, "missing.pem")},
+	})
+	if report.Passed {
+		t.Fatalf("Run() passed with invalid TLS material")
+	}
+	if got := len(report.Failures()); got != 3 {
+		t.Errorf("Run() failures = %v, want 3", got)
+	}
+}
+
+func TestLoadUpstreamClusters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "clusters.yaml")
+	data := `apiVersion: proxy.kubegateway.io/v1alpha1
+kind: UpstreamCluster
+metadata:
+  name: a
+spec:
+  servers:
+  - endpoint: https://10.0.0.1:6443
+---
+---
+apiVersion: proxy.kubegateway.io/v1alpha1
+kind: UpstreamCluster
+metadata:
+  name: b
+`
+	if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clusters, err := LoadUpstreamClusters([]string{file})
+	if err != nil {
+		t.Fatalf("LoadUpstreamClusters() error = %v", err)
+	}
+	if len(clusters) != 2 || clusters[0].Name != "a" || len(clusters[0].Spec.Servers) != 1 || clusters[1].Name != "b" {
+		t.Errorf("LoadUpstreamClusters() = %+v", clusters)
+	}
+}
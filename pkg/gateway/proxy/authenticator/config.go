@@ -16,6 +16,7 @@ package authenticator
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/go-openapi/spec"
@@ -28,8 +29,11 @@ import (
 	unionauth "k8s.io/apiserver/pkg/authentication/request/union"
 	"k8s.io/apiserver/pkg/authentication/request/websocket"
 	"k8s.io/apiserver/pkg/authentication/request/x509"
+	tokenunion "k8s.io/apiserver/pkg/authentication/token/union"
+	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
 
 	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/authentication/revocation"
 	"github.com/kubewharf/kubegateway/pkg/gateway/authentication/token/webhook"
 )
 
@@ -40,18 +44,24 @@ type ClientCertAuthenticationConfig struct {
 	CAContentProvider authenticatorfactory.CAContentProvider
 	// SNIVerifyOptionsPorvider provides dynamic verifyOptions for each sni hostname
 	SNIVerifyOptionsPorvider x509.SNIVerifyOptionsProvider
+	// RevocationChecker rejects revoked client certificates, nil means revocation is not checked
+	RevocationChecker revocation.Checker
 }
 
 func (c *ClientCertAuthenticationConfig) New() authenticator.Request {
 	if c == nil {
 		return nil
 	}
+	var conversion x509.UserConversion = x509.CommonNameUserConversion
+	if c.RevocationChecker != nil {
+		conversion = revocation.NewUserConversion(conversion, c.RevocationChecker)
+	}
 	if c.CAContentProvider != nil && c.SNIVerifyOptionsPorvider != nil {
-		return x509.NewSNIDynamic(c.SNIVerifyOptionsPorvider.SNIVerifyOptions, c.CAContentProvider.VerifyOptions, x509.CommonNameUserConversion)
+		return x509.NewSNIDynamic(c.SNIVerifyOptionsPorvider.SNIVerifyOptions, c.CAContentProvider.VerifyOptions, conversion)
 	} else if c.CAContentProvider != nil && c.SNIVerifyOptionsPorvider == nil {
-		return x509.NewDynamic(c.CAContentProvider.VerifyOptions, x509.CommonNameUserConversion)
+		return x509.NewDynamic(c.CAContentProvider.VerifyOptions, conversion)
 	} else if c.CAContentProvider == nil && c.SNIVerifyOptionsPorvider != nil {
-		return x509.NewSNIDynamic(c.SNIVerifyOptionsPorvider.SNIVerifyOptions, nil, x509.CommonNameUserConversion)
+		return x509.NewSNIDynamic(c.SNIVerifyOptionsPorvider.SNIVerifyOptions, nil, conversion)
 	}
 	return nil
 }
@@ -76,6 +86,9 @@ type AuthenricatorConfig struct {
 
 	TokenRequest *TokenAuthenticationConfig
 
+	// OIDC validates tokens issued by OpenID Connect issuers locally, they take precedence over TokenRequest
+	OIDC []oidc.Options
+
 	Anonymous bool
 }
 
@@ -102,21 +115,31 @@ func (c AuthenricatorConfig) New() (authenticator.Request, *spec.SecurityDefinit
 		authenticators = append(authenticators, a)
 	}
 
-	if c.TokenRequest != nil {
-		var tokenAuth authenticator.Token
-		if c.TokenRequest.ClusterClientProvider != nil {
-			tokenAuth = webhook.NewMultiClusterTokenReviewAuthenticator(c.TokenRequest.ClusterClientProvider, c.TokenSuccessCacheTTL, c.TokenFailureCacheTTL, c.APIAudiences)
+	tokenAuthenticators := []authenticator.Token{}
+	for _, opts := range c.OIDC {
+		oidcAuth, err := oidc.New(opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create oidc authenticator for issuer %q: %v", opts.IssuerURL, err)
 		}
-		if tokenAuth != nil {
-			authenticators = append(authenticators, bearertoken.New(tokenAuth), websocket.NewProtocolAuthenticator(tokenAuth))
-			securityDefinitions["BearerToken"] = &spec.SecurityScheme{
-				SecuritySchemeProps: spec.SecuritySchemeProps{
-					Type:        "apiKey",
-					Name:        "authorization",
-					In:          "header",
-					Description: "Bearer Token authentication",
-				},
-			}
+		// oidc authenticator is audience agnostic, it only accepts tokens of its client id
+		tokenAuthenticators = append(tokenAuthenticators, authenticator.WrapAudienceAgnosticToken(c.APIAudiences, oidcAuth))
+	}
+
+	if c.TokenRequest != nil && c.TokenRequest.ClusterClientProvider != nil {
+		tokenAuthenticators = append(tokenAuthenticators, webhook.NewMultiClusterTokenReviewAuthenticator(c.TokenRequest.ClusterClientProvider, c.TokenSuccessCacheTTL, c.TokenFailureCacheTTL, c.APIAudiences))
+	}
+
+	if len(tokenAuthenticators) > 0 {
+		// tokens of other issuers are ignored by oidc authenticator and fall through to TokenRequest
+		tokenAuth := tokenunion.New(tokenAuthenticators...)
+		authenticators = append(authenticators, bearertoken.New(tokenAuth), websocket.NewProtocolAuthenticator(tokenAuth))
+		securityDefinitions["BearerToken"] = &spec.SecurityScheme{
+			SecuritySchemeProps: spec.SecuritySchemeProps{
+				Type:        "apiKey",
+				Name:        "authorization",
+				In:          "header",
+				Description: "Bearer Token authentication",
+			},
 		}
 	}
 
@@ -0,0 +1,207 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// EventReasonClientAbuse is the reason of events emitted for abusive clients
+	EventReasonClientAbuse = "ClientAbuse"
+
+	abuseWebhookTimeout = 5 * time.Second
+)
+
+// abuseReasons are rejections charged to the client instead of the upstream cluster
+var abuseReasons = map[string]bool{
+	statusReasonRateLimited:        true,
+	statusReasonWatchLimitExceeded: true,
+	statusReasonLoadShed:           true,
+}
+
+// AbuseReporter notifies platform teams when requests of one user to one cluster are repeatedly
+// rejected by rate limiting, load shedding or watch limits. Once the rejections of a user reach
+// Threshold within Window, a Warning Event is emitted on the UpstreamCluster with aggregated
+// details, and the same details are posted to the optional webhook. A user is reported at most
+// once per window, so a misbehaving client can not flood events.
+type AbuseReporter struct {
+	Threshold int
+	Window    time.Duration
+
+	recorder   record.EventRecorder
+	webhookURL string
+	client     *http.Client
+	clock      clock.Clock
+
+	lock      sync.Mutex
+	offenders map[abuseKey]*AbuseReport
+	lastSweep time.Time
+}
+
+type abuseKey struct {
+	cluster string
+	user    string
+}
+
+// AbuseReport is the aggregated rejections of one user to one cluster in a window,
+// it is the JSON body posted to the webhook.
+type AbuseReport struct {
+	Cluster    string         `json:"cluster"`
+	User       string         `json:"user"`
+	UserAgent  string         `json:"userAgent,omitempty"`
+	Rejections int            `json:"rejections"`
+	Reasons    map[string]int `json:"reasons"`
+	FirstTime  time.Time      `json:"firstTime"`
+	LastTime   time.Time      `json:"lastTime"`
+
+	reported bool
+}
+
+// NewAbuseReporter creates a reporter emitting events by recorder, webhookURL can be empty if
+// no webhook is called.
+func NewAbuseReporter(threshold int, window time.Duration, recorder record.EventRecorder, webhookURL string) *AbuseReporter {
+	return &AbuseReporter{
+		Threshold:  threshold,
+		Window:     window,
+		recorder:   recorder,
+		webhookURL: webhookURL,
+		client:     &http.Client{Timeout: abuseWebhookTimeout},
+		clock:      clock.RealClock{},
+		offenders:  map[abuseKey]*AbuseReport{},
+	}
+}
+
+// ObserveRejection counts the rejection of req if the reason is charged to the client
+func (a *AbuseReporter) ObserveRejection(req *http.Request, reason string) {
+	if a == nil || !abuseReasons[reason] {
+		return
+	}
+	user, ok := genericapirequest.UserFrom(req.Context())
+	if !ok {
+		return
+	}
+	extraInfo, ok := request.ExtraReqeustInfoFrom(req.Context())
+	if !ok {
+		return
+	}
+	if report := a.observe(extraInfo.Hostname, user.GetName(), req.UserAgent(), reason); report != nil {
+		a.report(report)
+	}
+}
+
+// observe returns a copy of the report if the user should be reported now
+func (a *AbuseReporter) observe(cluster, user, userAgent, reason string) *AbuseReport {
+	now := a.clock.Now()
+	key := abuseKey{cluster: cluster, user: user}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.sweepLocked(now)
+	report, ok := a.offenders[key]
+	if !ok || now.Sub(report.FirstTime) > a.Window {
+		report = &AbuseReport{Cluster: cluster, User: user, Reasons: map[string]int{}, FirstTime: now}
+		a.offenders[key] = report
+	}
+	report.Rejections++
+	report.Reasons[reason]++
+	report.LastTime = now
+	report.UserAgent = userAgent
+	if report.reported || report.Rejections < a.Threshold {
+		return nil
+	}
+	report.reported = true
+	result := *report
+	result.Reasons = make(map[string]int, len(report.Reasons))
+	for k, v := range report.Reasons {
+		result.Reasons[k] = v
+	}
+	return &result
+}
+
+// sweepLocked forgets users whose window is over, so memory is bounded by users rejected recently
+func (a *AbuseReporter) sweepLocked(now time.Time) {
+	if now.Sub(a.lastSweep) < a.Window {
+		return
+	}
+	a.lastSweep = now
+	for key, report := range a.offenders {
+		if now.Sub(report.FirstTime) > a.Window {
+			delete(a.offenders, key)
+		}
+	}
+}
+
+func (a *AbuseReporter) report(report *AbuseReport) {
+	metrics.RecordAbuseReport(report.Cluster)
+	message := fmt.Sprintf("requests of user %q (user agent %q) were rejected %d times in %v: %s",
+		report.User, report.UserAgent, report.Rejections, report.LastTime.Sub(report.FirstTime).Round(time.Second), formatAbuseReasons(report.Reasons))
+	klog.Warningf("[abuse] cluster=%q %s", report.Cluster, message)
+	if a.recorder != nil {
+		// UpstreamCluster is cluster scoped, but there is no default namespace in control plane
+		// to keep events of cluster scoped objects, so they are kept in kube-system
+		a.recorder.Event(clusters.EventReference(report.Cluster), corev1.EventTypeWarning, EventReasonClientAbuse, message)
+	}
+	if len(a.webhookURL) > 0 {
+		go a.callWebhook(report)
+	}
+}
+
+func (a *AbuseReporter) callWebhook(report *AbuseReport) {
+	body, err := json.Marshal(report)
+	if err != nil {
+		klog.Errorf("[abuse] failed to encode report of user %q: %v", report.User, err)
+		return
+	}
+	resp, err := a.client.Post(a.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		klog.Errorf("[abuse] failed to call webhook for user %q: %v", report.User, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		klog.Errorf("[abuse] webhook responded %d for user %q", resp.StatusCode, report.User)
+	}
+}
+
+func formatAbuseReasons(reasons map[string]int) string {
+	keys := make([]string, 0, len(reasons))
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%d", k, reasons[k]))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+func TestAbuseReporter(t *testing.T) {
+	webhook := make(chan AbuseReport, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report AbuseReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("failed to decode webhook body: %v", err)
+		}
+		webhook <- report
+	}))
+	defer server.Close()
+
+	recorder := record.NewFakeRecorder(10)
+	reporter := NewAbuseReporter(3, time.Minute, recorder, server.URL)
+	fakeClock := clock.NewFakeClock(time.Now())
+	reporter.clock = fakeClock
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	ctx := genericapirequest.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"})
+	ctx = request.WithExtraReqeustInfo(ctx, &request.ExtraRequestInfo{Hostname: "cluster-a"})
+	req = req.WithContext(ctx)
+
+	reporter.ObserveRejection(req, statusReasonClusterBudgetExhausted)
+	reporter.ObserveRejection(req, statusReasonRateLimited)
+	reporter.ObserveRejection(req, statusReasonLoadShed)
+	if len(recorder.Events) != 0 {
+		t.Fatalf("user is reported before rejections reach threshold")
+	}
+	reporter.ObserveRejection(req, statusReasonRateLimited)
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, EventReasonClientAbuse) || !strings.Contains(event, "load_shed=1,rate_limited=2") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Fatalf("no event is emitted after rejections reach threshold")
+	}
+	select {
+	case report := <-webhook:
+		if report.Cluster != "cluster-a" || report.User != "alice" || report.Rejections != 3 {
+			t.Errorf("unexpected webhook report %+v", report)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("webhook is not called")
+	}
+
+	// reported at most once per window
+	reporter.ObserveRejection(req, statusReasonRateLimited)
+	if len(recorder.Events) != 0 {
+		t.Errorf("user is reported twice in one window")
+	}
+
+	fakeClock.Step(2 * time.Minute)
+	for i := 0; i < 3; i++ {
+		reporter.ObserveRejection(req, statusReasonWatchLimitExceeded)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("user is not reported again in a new window")
+	}
+	<-webhook
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+)
+
+const (
+	// adaptiveTimeoutWindow is the number of recent latencies kept for each (cluster, verb, resource)
+	adaptiveTimeoutWindow = 256
+	// adaptiveTimeoutMinSamples is the number of latencies required before the timeout adapts,
+	// Ceiling is used before that
+	adaptiveTimeoutMinSamples = 32
+	// adaptiveTimeoutRecompute is the number of new latencies observed before the percentile is recomputed
+	adaptiveTimeoutRecompute = 16
+)
+
+// AdaptiveTimeoutPolicy derives the response header timeout of non-long-running requests from rolling
+// latency percentiles of each (cluster, verb, resource) instead of one static number, so that fast
+// requests to a hung endpoint are canceled and retried early, while slow lists of large resources are
+// not canceled prematurely.
+type AdaptiveTimeoutPolicy struct {
+	// Percentile of recent latencies the timeout is derived from, e.g. 0.99
+	Percentile float64
+	// Multiplier is applied to the percentile to leave headroom for jitter
+	Multiplier float64
+	// Floor is the minimum timeout
+	Floor time.Duration
+	// Ceiling is the maximum timeout, it is also used before enough latencies are observed
+	Ceiling time.Duration
+
+	mu      sync.Mutex
+	windows map[adaptiveTimeoutKey]*latencyWindow
+}
+
+type adaptiveTimeoutKey struct {
+	cluster  string
+	verb     string
+	resource string
+}
+
+// NewAdaptiveTimeoutPolicy creates an adaptive timeout policy
+func NewAdaptiveTimeoutPolicy(percentile, multiplier float64, floor, ceiling time.Duration) *AdaptiveTimeoutPolicy {
+	return &AdaptiveTimeoutPolicy{
+		Percentile: percentile,
+		Multiplier: multiplier,
+		Floor:      floor,
+		Ceiling:    ceiling,
+		windows:    map[adaptiveTimeoutKey]*latencyWindow{},
+	}
+}
+
+func (p *AdaptiveTimeoutPolicy) window(cluster, verb, resource string) *latencyWindow {
+	key := adaptiveTimeoutKey{cluster: cluster, verb: verb, resource: resource}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, ok := p.windows[key]
+	if !ok {
+		w = &latencyWindow{}
+		p.windows[key] = w
+	}
+	return w
+}
+
+// Timeout returns the current response header timeout of requests to resource of cluster
+func (p *AdaptiveTimeoutPolicy) Timeout(cluster, verb, resource string) time.Duration {
+	percentile, ok := p.window(cluster, verb, resource).Percentile()
+	if !ok {
+		return p.Ceiling
+	}
+	return p.clamp(time.Duration(float64(percentile) * p.Multiplier))
+}
+
+// Observe records the latency of response headers of a request to resource of cluster
+func (p *AdaptiveTimeoutPolicy) Observe(cluster, verb, resource string, latency time.Duration) {
+	w := p.window(cluster, verb, resource)
+	if percentile, changed := w.Observe(latency, p.Percentile); changed {
+		metrics.RecordAdaptiveTimeout(cluster, verb, resource, p.clamp(time.Duration(float64(percentile)*p.Multiplier)))
+	}
+}
+
+// Forget releases latency windows of cluster, it is called after the cluster is deleted
+func (p *AdaptiveTimeoutPolicy) Forget(cluster string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.windows {
+		if key.cluster == cluster {
+			delete(p.windows, key)
+		}
+	}
+}
+
+func (p *AdaptiveTimeoutPolicy) clamp(timeout time.Duration) time.Duration {
+	if timeout < p.Floor {
+		return p.Floor
+	}
+	if p.Ceiling > 0 && timeout > p.Ceiling {
+		return p.Ceiling
+	}
+	return timeout
+}
+
+// latencyWindow keeps recent latencies in a ring buffer, the percentile is cached and
+// recomputed every adaptiveTimeoutRecompute observations.
+type latencyWindow struct {
+	mu         sync.Mutex
+	samples    [adaptiveTimeoutWindow]time.Duration
+	next       int
+	count      int
+	pending    int
+	percentile time.Duration
+}
+
+// Observe adds latency to window, it returns the new percentile if it is recomputed
+func (w *latencyWindow) Observe(latency time.Duration, percentile float64) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % adaptiveTimeoutWindow
+	if w.count < adaptiveTimeoutWindow {
+		w.count++
+	}
+	w.pending++
+	if w.count < adaptiveTimeoutMinSamples || (w.percentile > 0 && w.pending < adaptiveTimeoutRecompute) {
+		return 0, false
+	}
+	w.pending = 0
+
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(float64(w.count-1) * percentile)
+	w.percentile = sorted[index]
+	return w.percentile, true
+}
+
+// Percentile returns the cached percentile, false if there are not enough samples
+func (w *latencyWindow) Percentile() (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.percentile, w.percentile > 0
+}
+
+// adaptiveTimeoutRoundTripper bounds the response header timeout of every upstream attempt of a
+// request by the adaptive timeout, and observes the latency of response headers.
+type adaptiveTimeoutRoundTripper struct {
+	rt       http.RoundTripper
+	policy   *AdaptiveTimeoutPolicy
+	cluster  string
+	verb     string
+	resource string
+}
+
+var _ utilnet.RoundTripperWrapper = &adaptiveTimeoutRoundTripper{}
+
+func newAdaptiveTimeoutRoundTripper(rt http.RoundTripper, policy *AdaptiveTimeoutPolicy, cluster, verb, resource string) http.RoundTripper {
+	return &adaptiveTimeoutRoundTripper{
+		rt:       rt,
+		policy:   policy,
+		cluster:  cluster,
+		verb:     verb,
+		resource: resource,
+	}
+}
+
+func (rt *adaptiveTimeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	timeout := rt.policy.Timeout(rt.cluster, rt.verb, rt.resource)
+	start := time.Now()
+	resp, err := rt.rt.RoundTrip(req.WithContext(clusters.WithResponseHeaderTimeout(req.Context(), timeout)))
+	switch {
+	case err == nil:
+		rt.policy.Observe(rt.cluster, rt.verb, rt.resource, time.Since(start))
+	case errclass.ClassifyProxyError(err) == errclass.ResponseHeaderTimeout:
+		// the real latency is unknown but at least timeout, observing it lets the timeout
+		// grow back if the upstream becomes slower for every request
+		rt.policy.Observe(rt.cluster, rt.verb, rt.resource, timeout)
+	}
+	return resp, err
+}
+
+func (rt *adaptiveTimeoutRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
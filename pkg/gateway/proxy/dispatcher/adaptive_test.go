@@ -0,0 +1,65 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeoutPolicy_Timeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		latency time.Duration
+		samples int
+		want    time.Duration
+	}{
+		{"not enough samples", 100 * time.Millisecond, adaptiveTimeoutMinSamples - 1, 10 * time.Second},
+		{"derived from percentile", 100 * time.Millisecond, adaptiveTimeoutMinSamples, 300 * time.Millisecond},
+		{"bounded by floor", time.Millisecond, adaptiveTimeoutWindow, 50 * time.Millisecond},
+		{"bounded by ceiling", 5 * time.Second, adaptiveTimeoutWindow, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewAdaptiveTimeoutPolicy(0.99, 3, 50*time.Millisecond, 10*time.Second)
+			for i := 0; i < tt.samples; i++ {
+				p.Observe("a", "list", "pods", tt.latency)
+			}
+			if got := p.Timeout("a", "list", "pods"); got != tt.want {
+				t.Errorf("Timeout() = %v, want %v", got, tt.want)
+			}
+			if got := p.Timeout("a", "get", "pods"); got != 10*time.Second {
+				t.Errorf("Timeout() of another verb = %v, want ceiling", got)
+			}
+		})
+	}
+}
+
+func TestAdaptiveTimeoutPolicy_Recompute(t *testing.T) {
+	p := NewAdaptiveTimeoutPolicy(0.5, 1, time.Millisecond, time.Minute)
+	for i := 0; i < adaptiveTimeoutWindow; i++ {
+		p.Observe("a", "get", "pods", 10*time.Millisecond)
+	}
+	if got := p.Timeout("a", "get", "pods"); got != 10*time.Millisecond {
+		t.Fatalf("Timeout() = %v, want 10ms", got)
+	}
+	// old latencies are evicted from the window when upstream becomes slower
+	for i := 0; i < adaptiveTimeoutWindow; i++ {
+		p.Observe("a", "get", "pods", time.Second)
+	}
+	if got := p.Timeout("a", "get", "pods"); got != time.Second {
+		t.Errorf("Timeout() = %v after upstream becomes slower, want 1s", got)
+	}
+}
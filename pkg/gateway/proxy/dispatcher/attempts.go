@@ -0,0 +1,69 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// UpstreamAttemptCauseType is the type of status cause which describes a failed upstream attempt,
+	// the field of cause is the endpoint and the message is a summary of the attempt.
+	UpstreamAttemptCauseType metav1.CauseType = "UpstreamAttempt"
+)
+
+// recordFailedAttempt records the current upstream attempt of request failed
+func recordFailedAttempt(req *http.Request, err error) {
+	endpoint, ok := request.UpstreamEndpointFrom(req.Context())
+	if !ok {
+		return
+	}
+	request.FailProxyAttempt(req.Context(), endpoint, string(errclass.ClassifyProxyError(err)), redact.Error(err)) //nolint
+}
+
+// withAttemptCauses appends summary of all failed upstream attempts to status details,
+// so clients can tell whether the failure is caused by one bad endpoint or the whole cluster.
+func withAttemptCauses(err *errors.StatusError, attempts []request.UpstreamAttempt) {
+	if len(attempts) == 0 {
+		return
+	}
+	if err.ErrStatus.Details == nil {
+		err.ErrStatus.Details = &metav1.StatusDetails{}
+	}
+	for i, a := range attempts {
+		err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{
+			Type:    UpstreamAttemptCauseType,
+			Field:   a.Endpoint,
+			Message: fmt.Sprintf("attempt=%d class=%s durationMs=%d: %s", i+1, a.ErrorClass, a.Duration.Milliseconds(), a.Message),
+		})
+	}
+}
+
+func attemptsToString(attempts []request.UpstreamAttempt) string {
+	s := make([]string, 0, len(attempts))
+	for _, a := range attempts {
+		s = append(s, "{"+a.String()+"}")
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,53 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+)
+
+func Test_withAttemptCauses(t *testing.T) {
+	attempts := []request.UpstreamAttempt{
+		{Endpoint: "https://a:6443", Duration: time.Second, ErrorClass: string(errclass.Timeout), Message: "i/o timeout"},
+		{Endpoint: "https://b:6443", Duration: time.Millisecond, ErrorClass: string(errclass.ConnectionRefused), Message: "connection refused"},
+	}
+	err := apierrors.NewTooManyRequests("too many requests", 1)
+	withAttemptCauses(err, attempts)
+
+	if err.ErrStatus.Details.RetryAfterSeconds != 1 {
+		t.Errorf("withAttemptCauses() changed retryAfterSeconds")
+	}
+	causes := err.ErrStatus.Details.Causes
+	if len(causes) != len(attempts) {
+		t.Fatalf("withAttemptCauses() causes = %v, want %v", len(causes), len(attempts))
+	}
+	for i := range causes {
+		if causes[i].Type != UpstreamAttemptCauseType || causes[i].Field != attempts[i].Endpoint {
+			t.Errorf("withAttemptCauses() cause[%d] = %v", i, causes[i])
+		}
+	}
+
+	empty := apierrors.NewBadRequest("bad")
+	withAttemptCauses(empty, nil)
+	if empty.ErrStatus.Details != nil {
+		t.Errorf("withAttemptCauses() should not set details without attempts")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/net"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+// recordAuditOnlyRejection logs and counts a request which would be rejected by an audit-only rule,
+// the request is still proxied as if the rule does not exist.
+func recordAuditOnlyRejection(req *http.Request, serverName, rule, message string) {
+	metrics.RecordAuditOnlyRejection(serverName, rule)
+	var username string
+	if user, ok := genericapirequest.UserFrom(req.Context()); ok {
+		username = user.GetName()
+	}
+	klog.V(1).Infof("[audit-only] request would be rejected by rule=%q: method=%q host=%q uri=%q user=%q message=[%v]", rule, req.Method, net.HostWithoutPort(req.Host), redact.URI(req.RequestURI), username, message)
+}
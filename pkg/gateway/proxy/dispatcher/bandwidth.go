@@ -0,0 +1,231 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/sets"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	gatewaynet "github.com/kubewharf/kubegateway/pkg/gateway/net"
+)
+
+const (
+	// scopes of bandwidth limits in metrics
+	bandwidthScopeSession = "session"
+	bandwidthScopeUser    = "user"
+)
+
+// streamingSubresources stream data as long as the session lasts, watches are not included
+// because they are small and delaying them makes controllers fall behind.
+var streamingSubresources = sets.NewString("log", "exec", "attach", "portforward", "proxy")
+
+// isStreamingSession returns true if request is an upgrade stream or streams data continuously, e.g. logs -f
+func isStreamingSession(req *http.Request, requestInfo *genericapirequest.RequestInfo) bool {
+	if httpstream.IsUpgradeRequest(req) {
+		return true
+	}
+	return requestInfo.IsResourceRequest && streamingSubresources.Has(requestInfo.Subresource)
+}
+
+// isStreamingResponse returns true if the response of request is a stream of events or log lines, e.g. watches
+// and logs -f, whose writes should reach the client promptly. Large lists may be chunked as well, so whether a
+// response is a stream is never guessed from its unknown content length.
+func isStreamingResponse(req *http.Request, requestInfo *genericapirequest.RequestInfo) bool {
+	if !requestInfo.IsResourceRequest {
+		return false
+	}
+	if requestInfo.Verb == "watch" {
+		return true
+	}
+	return requestInfo.Subresource == "log" && req.URL.Query().Get("follow") == "true"
+}
+
+// BandwidthPolicy caps bandwidth of streaming sessions, e.g. logs -f, port-forward and exec,
+// so that a single user can not saturate the network of gateway.
+type BandwidthPolicy struct {
+	// SessionBytesPerSecond caps each streaming session, zero means unlimited
+	SessionBytesPerSecond int64
+	// UserBytesPerSecond caps all streaming sessions of one user together, zero means unlimited
+	UserBytesPerSecond int64
+
+	mu sync.Mutex
+	// limiters shared by streaming sessions of each user
+	users map[string]*userBandwidth
+}
+
+type userBandwidth struct {
+	limiter  *gatewaynet.BandwidthLimiter
+	sessions int
+}
+
+// NewBandwidthPolicy creates a bandwidth policy, zero means unlimited
+func NewBandwidthPolicy(sessionBytesPerSecond, userBytesPerSecond int64) *BandwidthPolicy {
+	return &BandwidthPolicy{
+		SessionBytesPerSecond: sessionBytesPerSecond,
+		UserBytesPerSecond:    userBytesPerSecond,
+		users:                 map[string]*userBandwidth{},
+	}
+}
+
+func (p *BandwidthPolicy) acquireUser(user string) *gatewaynet.BandwidthLimiter {
+	if p.UserBytesPerSecond <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u, ok := p.users[user]
+	if !ok {
+		u = &userBandwidth{limiter: gatewaynet.NewBandwidthLimiter(p.UserBytesPerSecond, 0)}
+		p.users[user] = u
+	}
+	u.sessions++
+	return u.limiter
+}
+
+func (p *BandwidthPolicy) releaseUser(user string) {
+	if p.UserBytesPerSecond <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u, ok := p.users[user]
+	if !ok {
+		return
+	}
+	u.sessions--
+	if u.sessions <= 0 {
+		delete(p.users, user)
+	}
+}
+
+// Limit returns a response writer whose writes and hijacked connection are throttled by
+// bandwidth limits, release must be called after the session finished.
+func (p *BandwidthPolicy) Limit(w http.ResponseWriter, ctx context.Context, cluster, user string) (http.ResponseWriter, func()) {
+	if p == nil || (p.SessionBytesPerSecond <= 0 && p.UserBytesPerSecond <= 0) {
+		return w, func() {}
+	}
+	limiter := &sessionBandwidth{
+		ctx:     ctx,
+		cluster: cluster,
+		user:    p.acquireUser(user),
+	}
+	if p.SessionBytesPerSecond > 0 {
+		limiter.session = gatewaynet.NewBandwidthLimiter(p.SessionBytesPerSecond, 0)
+	}
+	return &bandwidthLimitedWriter{ResponseWriter: w, limiter: limiter}, func() { p.releaseUser(user) }
+}
+
+// sessionBandwidth throttles one streaming session by both session and user limits
+type sessionBandwidth struct {
+	ctx     context.Context
+	cluster string
+	session *gatewaynet.BandwidthLimiter
+	user    *gatewaynet.BandwidthLimiter
+}
+
+func (s *sessionBandwidth) wait(n int) error {
+	wait, err := s.session.WaitN(s.ctx, n)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		metrics.RecordStreamingThrottled(s.cluster, bandwidthScopeSession, n, wait)
+	}
+	wait, err = s.user.WaitN(s.ctx, n)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		metrics.RecordStreamingThrottled(s.cluster, bandwidthScopeUser, n, wait)
+	}
+	return nil
+}
+
+// bandwidthLimitedWriter throttles response bodies of streaming requests and both directions of
+// upgraded connections. It always implements http.Flusher, http.CloseNotifier and http.Hijacker,
+// so responsewriter.WrapForHTTP1Or2 hijacks connections through it.
+type bandwidthLimitedWriter struct {
+	http.ResponseWriter
+	limiter *sessionBandwidth
+}
+
+func (w *bandwidthLimitedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *bandwidthLimitedWriter) Write(b []byte) (int, error) {
+	if err := w.limiter.wait(len(b)); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bandwidthLimitedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *bandwidthLimitedWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *bandwidthLimitedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return &bandwidthLimitedConn{Conn: conn, limiter: w.limiter}, brw, nil
+}
+
+// bandwidthLimitedConn throttles both reads and writes of a hijacked connection
+type bandwidthLimitedConn struct {
+	net.Conn
+	limiter *sessionBandwidth
+}
+
+func (c *bandwidthLimitedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		if werr := c.limiter.wait(n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+func (c *bandwidthLimitedConn) Write(b []byte) (int, error) {
+	if err := c.limiter.wait(len(b)); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(b)
+}
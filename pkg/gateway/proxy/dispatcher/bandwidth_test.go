@@ -0,0 +1,106 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func Test_isStreamingSession(t *testing.T) {
+	upgrade := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/pods/a/exec", nil)
+	upgrade.Header.Set("Connection", "Upgrade")
+	upgrade.Header.Set("Upgrade", "SPDY/3.1")
+	plain := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/a/log", nil)
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		requestInfo *genericapirequest.RequestInfo
+		want        bool
+	}{
+		{"upgrade", upgrade, &genericapirequest.RequestInfo{}, true},
+		{"logs", plain, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Subresource: "log"}, true},
+		{"get", plain, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods"}, false},
+		{"watch", plain, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Verb: "watch"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStreamingSession(tt.req, tt.requestInfo); got != tt.want {
+				t.Errorf("isStreamingSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isStreamingResponse(t *testing.T) {
+	logs := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/a/log", nil)
+	followedLogs := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/a/log?follow=true", nil)
+	pods := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		requestInfo *genericapirequest.RequestInfo
+		want        bool
+	}{
+		{"watch", pods, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Verb: "watch"}, true},
+		{"followed logs", followedLogs, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Subresource: "log", Verb: "get"}, true},
+		{"logs", logs, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Subresource: "log", Verb: "get"}, false},
+		// chunked lists have no content length either
+		{"list", pods, &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Verb: "list"}, false},
+		{"non-resource", pods, &genericapirequest.RequestInfo{Verb: "get"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isStreamingResponse(tt.req, tt.requestInfo); got != tt.want {
+				t.Errorf("isStreamingResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBandwidthPolicy_Limit(t *testing.T) {
+	var disabled *BandwidthPolicy
+	w := httptest.NewRecorder()
+	if got, _ := disabled.Limit(w, context.Background(), "a", "alice"); got != w {
+		t.Errorf("Limit() of nil policy wraps response writer")
+	}
+
+	p := NewBandwidthPolicy(0, 1024)
+	_, release1 := p.Limit(w, context.Background(), "a", "alice")
+	got, release2 := p.Limit(w, context.Background(), "b", "alice")
+	if _, ok := got.(*bandwidthLimitedWriter); !ok {
+		t.Fatalf("Limit() returns %T, want *bandwidthLimitedWriter", got)
+	}
+	if n := len(p.users); n != 1 {
+		t.Errorf("users = %v, want sessions of one user share a limiter", n)
+	}
+	if _, err := got.Write([]byte("hello")); err != nil {
+		t.Errorf("Write() error = %v", err)
+	}
+	release1()
+	if n := len(p.users); n != 1 {
+		t.Errorf("users = %v after one session released, want 1", n)
+	}
+	release2()
+	if n := len(p.users); n != 0 {
+		t.Errorf("users = %v after all sessions released, want 0", n)
+	}
+}
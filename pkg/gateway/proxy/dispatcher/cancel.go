@@ -0,0 +1,80 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+)
+
+const (
+	// reasons of aborted upstream requests
+	abortReasonClientCanceled  = "client_canceled"
+	abortReasonClientBodyError = "client_body_error"
+	abortReasonEndpointStopped = "endpoint_stopped"
+	abortReasonUpstreamError   = "upstream_error"
+)
+
+// clientBody wraps the request body read from downstream client, it cancels the upstream
+// request as soon as reading body fails, e.g. client disconnects in the middle of uploading.
+// Otherwise the upstream request may keep waiting for the rest of body until timeout.
+type clientBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+	failed int32
+}
+
+func withClientBody(req *http.Request, cancel context.CancelFunc) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &clientBody{ReadCloser: req.Body, cancel: cancel}
+}
+
+func (b *clientBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		atomic.StoreInt32(&b.failed, 1)
+		b.cancel()
+	}
+	return n, err
+}
+
+func (b *clientBody) Failed() bool {
+	return atomic.LoadInt32(&b.failed) == 1
+}
+
+// abortReason tells whether the upstream request is aborted by downstream client or upstream,
+// it must be called with the request and error passed to ErrorHandler.
+func abortReason(req *http.Request, err error, endpoint *clusters.EndpointInfo) string {
+	if b, ok := req.Body.(*clientBody); ok && b.Failed() {
+		return abortReasonClientBodyError
+	}
+	if endpoint != nil && endpoint.Context() != nil && endpoint.Context().Err() != nil {
+		return abortReasonEndpointStopped
+	}
+	if errclass.ClassifyProxyError(err).ClientGone() {
+		return abortReasonClientCanceled
+	}
+	if req.Context().Err() == context.Canceled {
+		return abortReasonClientCanceled
+	}
+	return abortReasonUpstreamError
+}
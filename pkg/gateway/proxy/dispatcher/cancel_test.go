@@ -0,0 +1,59 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func Test_clientBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/default/configmaps", iotest.TimeoutReader(strings.NewReader("{}")))
+	ctx, cancel := context.WithCancel(req.Context())
+	req = req.WithContext(ctx)
+	withClientBody(req, cancel)
+
+	if _, err := ioutil.ReadAll(req.Body); err == nil {
+		t.Fatalf("ReadAll() expected error")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Errorf("request context should be canceled after body read error")
+	}
+	if got := abortReason(req, fmt.Errorf("read body failed"), nil); got != abortReasonClientBodyError {
+		t.Errorf("abortReason() = %v, want %v", got, abortReasonClientBodyError)
+	}
+}
+
+func Test_abortReason(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	if got := abortReason(req, fmt.Errorf("dial tcp: connection refused"), nil); got != abortReasonUpstreamError {
+		t.Errorf("abortReason() = %v, want %v", got, abortReasonUpstreamError)
+	}
+	if got := abortReason(req, context.Canceled, nil); got != abortReasonClientCanceled {
+		t.Errorf("abortReason() = %v, want %v", got, abortReasonClientCanceled)
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	if got := abortReason(req.WithContext(ctx), fmt.Errorf("unexpected EOF"), nil); got != abortReasonClientCanceled {
+		t.Errorf("abortReason() = %v, want %v", got, abortReasonClientCanceled)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net"
+	"net/http"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// results of response checksum verification used in metrics
+	checksumMatch    = "match"
+	checksumMismatch = "mismatch"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// responseChecksum verifies that bytes written to client are exactly the bytes received from upstream,
+// to detect rare data corruption introduced by the proxy copy path. Response body received from upstream
+// is hashed by RoundTripper and body written to client is hashed by Wrap, they are compared by Verify
+// after the response is proxied.
+type responseChecksum struct {
+	cluster  string
+	endpoint string
+
+	upstream      hash.Hash32
+	upstreamBytes int64
+	// upstream body is verified only if it is read to EOF
+	upstreamEOF bool
+
+	client      hash.Hash32
+	clientBytes int64
+}
+
+func newResponseChecksum(cluster, endpoint string) *responseChecksum {
+	return &responseChecksum{
+		cluster:  cluster,
+		endpoint: endpoint,
+		upstream: crc32.New(castagnoli),
+		client:   crc32.New(castagnoli),
+	}
+}
+
+// RoundTripper returns rt hashing the response body from upstream, it should be the outermost
+// round tripper so bodies modified by other round trippers are hashed as they are sent to client.
+func (c *responseChecksum) RoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &checksumRoundTripper{rt: rt, checksum: c}
+}
+
+// Wrap returns w hashing bytes written to client, it should wrap the writer closest to client
+func (c *responseChecksum) Wrap(w http.ResponseWriter) http.ResponseWriter {
+	return &checksumWriter{ResponseWriter: w, checksum: c}
+}
+
+// Verify compares checksums of the proxied response, responses not completely received from
+// upstream, e.g. failed or canceled ones, are not verified.
+func (c *responseChecksum) Verify(req *http.Request) {
+	if !c.upstreamEOF {
+		return
+	}
+	upstream, client := c.upstream.Sum32(), c.client.Sum32()
+	if upstream == client && c.upstreamBytes == c.clientBytes {
+		metrics.RecordResponseChecksum(c.cluster, checksumMatch)
+		return
+	}
+	metrics.RecordResponseChecksum(c.cluster, checksumMismatch)
+	requestID, _ := request.RequestIDFrom(req.Context())
+	klog.Errorf("[response checksum] mismatch: host=%q uri=%q endpoint=%v requestID=%q upstream=%08x/%d bytes client=%08x/%d bytes",
+		c.cluster, redact.URI(req.RequestURI), c.endpoint, requestID, upstream, c.upstreamBytes, client, c.clientBytes)
+}
+
+type checksumRoundTripper struct {
+	rt       http.RoundTripper
+	checksum *responseChecksum
+}
+
+var _ utilnet.RoundTripperWrapper = &checksumRoundTripper{}
+
+func (rt *checksumRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	resp.Body = &checksumBody{ReadCloser: resp.Body, checksum: rt.checksum}
+	return resp, nil
+}
+
+func (rt *checksumRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+type checksumBody struct {
+	io.ReadCloser
+	checksum *responseChecksum
+}
+
+func (b *checksumBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.checksum.upstream.Write(p[:n]) //nolint
+		b.checksum.upstreamBytes += int64(n)
+	}
+	if err == io.EOF {
+		b.checksum.upstreamEOF = true
+	}
+	return n, err
+}
+
+type checksumWriter struct {
+	http.ResponseWriter
+	checksum *responseChecksum
+}
+
+func (w *checksumWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if n > 0 {
+		w.checksum.client.Write(p[:n]) //nolint
+		w.checksum.clientBytes += int64(n)
+	}
+	return n, err
+}
+
+func (w *checksumWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *checksumWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *checksumWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// corruptingWriter flips the first byte written to it
+type corruptingWriter struct {
+	http.ResponseWriter
+	done bool
+}
+
+func (w *corruptingWriter) Write(p []byte) (int, error) {
+	if !w.done && len(p) > 0 {
+		w.done = true
+		p = append([]byte{p[0] ^ 0xff}, p[1:]...)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func TestResponseChecksum(t *testing.T) {
+	body := strings.Repeat("0123456789", 10000)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body)) //nolint
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+
+	tests := []struct {
+		name    string
+		corrupt bool
+		want    bool
+	}{
+		{"match", false, true},
+		{"mismatch", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checksum := newResponseChecksum("test", upstream.URL)
+			proxy := httputil.NewSingleHostReverseProxy(u)
+			proxy.Transport = checksum.RoundTripper(http.DefaultTransport)
+
+			recorder := httptest.NewRecorder()
+			var w http.ResponseWriter = checksum.Wrap(recorder)
+			if tt.corrupt {
+				w = &corruptingWriter{ResponseWriter: w}
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+			proxy.ServeHTTP(w, req)
+			checksum.Verify(req)
+
+			if !checksum.upstreamEOF {
+				t.Fatalf("upstream body is not read to EOF")
+			}
+			if checksum.upstreamBytes != int64(len(body)) || checksum.clientBytes != int64(len(body)) {
+				t.Errorf("checksum counted %d bytes from upstream and %d bytes to client, want %d", checksum.upstreamBytes, checksum.clientBytes, len(body))
+			}
+			if got := checksum.upstream.Sum32() == checksum.client.Sum32(); got != tt.want {
+				t.Errorf("checksums matched = %v, want %v", got, tt.want)
+			}
+			if !tt.corrupt && !bytes.Equal(recorder.Body.Bytes(), []byte(body)) {
+				t.Errorf("proxied body is changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// costSizeWeight is the weight of the latest response size in the moving average of list response sizes
+	costSizeWeight = 0.2
+)
+
+// CostEstimationPolicy estimates the cost of a request in flow control seats from its verb, resource,
+// limit parameter and the historical response sizes of lists of the same resource, so one huge LIST is
+// charged more than one GET. Requests other than lists always cost one seat.
+type CostEstimationPolicy struct {
+	// ObjectsPerSeat is the number of objects a paged list can return per seat
+	ObjectsPerSeat int64
+	// BytesPerSeat is the average list response size per seat
+	BytesPerSeat int64
+	// MaxSeats bounds the seats of one request
+	MaxSeats int
+
+	mu    sync.Mutex
+	sizes map[costKey]float64
+}
+
+type costKey struct {
+	cluster  string
+	group    string
+	resource string
+}
+
+// NewCostEstimationPolicy creates a cost estimation policy
+func NewCostEstimationPolicy(objectsPerSeat, bytesPerSeat int64, maxSeats int) *CostEstimationPolicy {
+	return &CostEstimationPolicy{
+		ObjectsPerSeat: objectsPerSeat,
+		BytesPerSeat:   bytesPerSeat,
+		MaxSeats:       maxSeats,
+		sizes:          map[costKey]float64{},
+	}
+}
+
+func isListRequest(info *genericapirequest.RequestInfo) bool {
+	return info.IsResourceRequest && info.Verb == "list"
+}
+
+// Seats returns the estimated seats of req to cluster. A paged list costs by its limit, but no more than
+// the lists of the resource returned recently. An unpaged list costs by the recent response sizes, or
+// MaxSeats before any response of the resource is observed, so the first full list of a huge resource
+// is not admitted as cheap.
+func (p *CostEstimationPolicy) Seats(cluster string, req *http.Request, info *genericapirequest.RequestInfo) int {
+	if p == nil || !isListRequest(info) {
+		return 1
+	}
+	size, observed := p.size(cluster, info)
+	bySize := p.MaxSeats
+	if observed {
+		bySize = int(math.Ceil(size / float64(p.BytesPerSeat)))
+	}
+	seats := bySize
+	if limit, err := strconv.ParseInt(req.URL.Query().Get("limit"), 10, 64); err == nil && limit > 0 {
+		byLimit := int((limit + p.ObjectsPerSeat - 1) / p.ObjectsPerSeat)
+		if !observed || byLimit < bySize {
+			seats = byLimit
+		}
+	}
+	if seats < 1 {
+		seats = 1
+	}
+	if seats > p.MaxSeats {
+		seats = p.MaxSeats
+	}
+	return seats
+}
+
+// Observe records the response size of a successful list to cluster
+func (p *CostEstimationPolicy) Observe(cluster string, info *genericapirequest.RequestInfo, status int, bytes int64) {
+	if p == nil || !isListRequest(info) || status < 200 || status >= 300 {
+		return
+	}
+	key := costKey{cluster: cluster, group: info.APIGroup, resource: info.Resource}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if size, ok := p.sizes[key]; ok {
+		p.sizes[key] = size + costSizeWeight*(float64(bytes)-size)
+		return
+	}
+	p.sizes[key] = float64(bytes)
+}
+
+func (p *CostEstimationPolicy) size(cluster string, info *genericapirequest.RequestInfo) (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	size, ok := p.sizes[costKey{cluster: cluster, group: info.APIGroup, resource: info.Resource}]
+	return size, ok
+}
+
+// Forget releases response sizes of cluster, it is called after the cluster is deleted
+func (p *CostEstimationPolicy) Forget(cluster string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key := range p.sizes {
+		if key.cluster == cluster {
+			delete(p.sizes, key)
+		}
+	}
+}
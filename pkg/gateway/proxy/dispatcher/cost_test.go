@@ -0,0 +1,80 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestCostEstimationPolicy_Seats(t *testing.T) {
+	list := &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"}
+	get := &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods"}
+
+	tests := []struct {
+		name string
+		info *genericapirequest.RequestInfo
+		url  string
+		// observed is the list response size observed before, zero means never observed
+		observed int64
+		want     int
+	}{
+		{name: "get", info: get, url: "/api/v1/namespaces/default/pods/a", want: 1},
+		{name: "unpaged list never observed", info: list, url: "/api/v1/pods", want: 10},
+		{name: "unpaged list of small resource", info: list, url: "/api/v1/pods", observed: 100 << 10, want: 1},
+		{name: "unpaged list of huge resource", info: list, url: "/api/v1/pods", observed: 3<<20 + 1, want: 4},
+		{name: "unpaged list beyond max seats", info: list, url: "/api/v1/pods", observed: 100 << 20, want: 10},
+		{name: "paged list never observed", info: list, url: "/api/v1/pods?limit=500", want: 5},
+		{name: "paged list of small resource", info: list, url: "/api/v1/pods?limit=500", observed: 100 << 10, want: 1},
+		{name: "paged list smaller than history", info: list, url: "/api/v1/pods?limit=50", observed: 8 << 20, want: 1},
+		{name: "invalid limit", info: list, url: "/api/v1/pods?limit=abc", observed: 2 << 20, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCostEstimationPolicy(100, 1<<20, 10)
+			if tt.observed > 0 {
+				p.Observe("test", tt.info, 200, tt.observed)
+			}
+			if got := p.Seats("test", httptest.NewRequest("GET", tt.url, nil), tt.info); got != tt.want {
+				t.Errorf("Seats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var nilPolicy *CostEstimationPolicy
+	if got := nilPolicy.Seats("test", httptest.NewRequest("GET", "/api/v1/pods", nil), list); got != 1 {
+		t.Errorf("Seats() of nil policy = %v, want 1", got)
+	}
+}
+
+func TestCostEstimationPolicy_Observe(t *testing.T) {
+	list := &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"}
+	p := NewCostEstimationPolicy(100, 1<<20, 10)
+	p.Observe("test", list, 500, 100<<20)
+	if _, ok := p.size("test", list); ok {
+		t.Errorf("failed list is observed")
+	}
+	p.Observe("test", list, 200, 10<<20)
+	p.Observe("test", list, 200, 0)
+	if size, _ := p.size("test", list); size != 8<<20 {
+		t.Errorf("size = %v, want moving average %v", size, 8<<20)
+	}
+	p.Forget("test")
+	if _, ok := p.size("test", list); ok {
+		t.Errorf("size is kept after cluster is forgotten")
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/clock"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// staleDiscoveryWarning is the Warning header of responses served from an expired cache entry, see RFC 7234
+	staleDiscoveryWarning = `110 - "Response is Stale"`
+
+	discoveryCacheSize         = 4096
+	discoveryRevalidateTimeout = 30 * time.Second
+
+	discoveryCacheHit              = "hit"
+	discoveryCacheMiss             = "miss"
+	discoveryCacheStale            = "stale"
+	discoveryCacheStaleIfError     = "stale_if_error"
+	discoveryCacheRevalidated      = "revalidated"
+	discoveryCacheRevalidateFailed = "revalidate_failed"
+)
+
+// DiscoveryCachePolicy caches discovery and openapi documents of upstream clusters, they are large, rarely
+// change and fetched by every client-go initialization. Documents are assumed identical for all users, as
+// kube-apiserver serves them to every authenticated user by system:discovery role, so documents are keyed
+// by cluster rather than by user on purpose, one fetch serves every client of the cluster. Only requests
+// of authenticated users are served from cache, anonymous requests always reach upstream to be authorized
+// there. Clusters revoking system:discovery from some authenticated users should not enable the cache.
+//
+// A document younger than TTL is served from cache. Within StaleWhileRevalidate after TTL, the stale document
+// is served at once and refreshed in background. Within StaleIfError after TTL, the stale document is served
+// if upstream fails or has no ready endpoint, so clients survive brief upstream outages.
+type DiscoveryCachePolicy struct {
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	// MaxBodyBytes is the maximum size of a cached document, larger documents are never cached
+	MaxBodyBytes int64
+
+	clock   clock.Clock
+	entries *cache.LRUExpireCache
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+}
+
+// NewDiscoveryCachePolicy creates a discovery cache policy
+func NewDiscoveryCachePolicy(ttl, staleWhileRevalidate, staleIfError time.Duration, maxBodyBytes int64) *DiscoveryCachePolicy {
+	return newDiscoveryCachePolicyWithClock(ttl, staleWhileRevalidate, staleIfError, maxBodyBytes, clock.RealClock{})
+}
+
+func newDiscoveryCachePolicyWithClock(ttl, staleWhileRevalidate, staleIfError time.Duration, maxBodyBytes int64, clock clock.Clock) *DiscoveryCachePolicy {
+	return &DiscoveryCachePolicy{
+		TTL:                  ttl,
+		StaleWhileRevalidate: staleWhileRevalidate,
+		StaleIfError:         staleIfError,
+		MaxBodyBytes:         maxBodyBytes,
+		clock:                clock,
+		entries:              cache.NewLRUExpireCacheWithClock(discoveryCacheSize, clock),
+		revalidating:         map[string]bool{},
+	}
+}
+
+// Matches returns true if req of an authenticated user fetches a discovery or openapi document which can be cached
+func (p *DiscoveryCachePolicy) Matches(req *http.Request) bool {
+	if p == nil || req.Method != http.MethodGet {
+		return false
+	}
+	// conditional requests are validated by upstream itself
+	if len(req.Header.Get("If-None-Match")) > 0 || len(req.Header.Get("If-Modified-Since")) > 0 {
+		return false
+	}
+	if !isDiscoveryPath(req.URL.Path) {
+		return false
+	}
+	u, ok := genericapirequest.UserFrom(req.Context())
+	return ok && sets.NewString(u.GetGroups()...).Has(user.AllAuthenticated)
+}
+
+// isDiscoveryPath returns true for /api, /api/v1, /apis, /apis/<group>, /apis/<group>/<version>, /version and /openapi/*
+func isDiscoveryPath(path string) bool {
+	if path == "/version" || strings.HasPrefix(path, "/openapi/") {
+		return true
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch parts[0] {
+	case "api":
+		return len(parts) <= 2
+	case "apis":
+		return len(parts) <= 3
+	}
+	return false
+}
+
+// ServeStale serves the stale document of req if it is within StaleIfError, it is used when no upstream
+// endpoint is ready and returns false if there is no such document.
+func (p *DiscoveryCachePolicy) ServeStale(w http.ResponseWriter, req *http.Request, cluster string) bool {
+	if !p.Matches(req) {
+		return false
+	}
+	entry, age, ok := p.get(discoveryCacheKey(cluster, req))
+	if !ok || age >= p.TTL+p.StaleIfError {
+		return false
+	}
+	metrics.RecordDiscoveryCache(cluster, discoveryCacheStaleIfError)
+	klog.V(2).Infof("[discovery cache] cluster=%q serve stale %v of age %v because no endpoint is ready", cluster, req.URL.Path, age)
+	resp := entry.response(req, age, true)
+	defer resp.Body.Close()
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body) //nolint
+	return true
+}
+
+// Forget removes all documents of a deleted cluster
+func (p *DiscoveryCachePolicy) Forget(cluster string) {
+	prefix := cluster + "\x00"
+	for _, key := range p.entries.Keys() {
+		if s, ok := key.(string); ok && strings.HasPrefix(s, prefix) {
+			p.entries.Remove(key)
+		}
+	}
+}
+
+func (p *DiscoveryCachePolicy) get(key string) (*discoveryEntry, time.Duration, bool) {
+	obj, ok := p.entries.Get(key)
+	if !ok {
+		return nil, 0, false
+	}
+	entry := obj.(*discoveryEntry)
+	return entry, p.clock.Now().Sub(entry.storedAt), true
+}
+
+func (p *DiscoveryCachePolicy) add(key string, entry *discoveryEntry) {
+	window := p.StaleWhileRevalidate
+	if p.StaleIfError > window {
+		window = p.StaleIfError
+	}
+	p.entries.Add(key, entry, p.TTL+window)
+}
+
+// startRevalidating returns false if the document of key is being revalidated by another request
+func (p *DiscoveryCachePolicy) startRevalidating(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.revalidating[key] {
+		return false
+	}
+	p.revalidating[key] = true
+	return true
+}
+
+func (p *DiscoveryCachePolicy) finishRevalidating(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.revalidating, key)
+}
+
+// discoveryCacheKey identifies a document by cluster, path, query and content negotiation,
+// timeout in query is dropped because client-go always sets it. User is not part of the key,
+// see DiscoveryCachePolicy.
+func discoveryCacheKey(cluster string, req *http.Request) string {
+	query := req.URL.Query()
+	query.Del("timeout")
+	return strings.Join([]string{cluster, req.URL.Path, query.Encode(), req.Header.Get("Accept"), req.Header.Get("Accept-Encoding")}, "\x00")
+}
+
+type discoveryEntry struct {
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+func (e *discoveryEntry) response(req *http.Request, age time.Duration, stale bool) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	if stale {
+		header.Add("Warning", staleDiscoveryWarning)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// discoveryCacheRoundTripper serves discovery documents of one cluster from cache
+type discoveryCacheRoundTripper struct {
+	rt      http.RoundTripper
+	policy  *DiscoveryCachePolicy
+	cluster string
+}
+
+var _ utilnet.RoundTripperWrapper = &discoveryCacheRoundTripper{}
+
+func newDiscoveryCacheRoundTripper(rt http.RoundTripper, policy *DiscoveryCachePolicy, cluster string) http.RoundTripper {
+	return &discoveryCacheRoundTripper{
+		rt:      rt,
+		policy:  policy,
+		cluster: cluster,
+	}
+}
+
+func (rt *discoveryCacheRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := discoveryCacheKey(rt.cluster, req)
+	entry, age, cached := rt.policy.get(key)
+	switch {
+	case cached && age < rt.policy.TTL:
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheHit)
+		return entry.response(req, age, false), nil
+	case cached && age < rt.policy.TTL+rt.policy.StaleWhileRevalidate:
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheStale)
+		if rt.policy.startRevalidating(key) {
+			go rt.revalidate(key, req)
+		}
+		return entry.response(req, age, true), nil
+	}
+
+	resp, err := rt.fetch(key, req)
+	if err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheMiss)
+		return resp, nil
+	}
+	if cached && age < rt.policy.TTL+rt.policy.StaleIfError {
+		if err != nil {
+			klog.V(2).Infof("[discovery cache] cluster=%q serve stale %v of age %v, err: %v", rt.cluster, req.URL.Path, age, redact.Error(err))
+		} else {
+			klog.V(2).Infof("[discovery cache] cluster=%q serve stale %v of age %v, upstream responded %v", rt.cluster, req.URL.Path, age, resp.StatusCode)
+			drainAndClose(resp)
+		}
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheStaleIfError)
+		return entry.response(req, age, true), nil
+	}
+	metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheMiss)
+	return resp, err
+}
+
+func (rt *discoveryCacheRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+// fetch requests the document from upstream and stores successful responses within MaxBodyBytes
+func (rt *discoveryCacheRoundTripper) fetch(key string, req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.ContentLength > rt.policy.MaxBodyBytes || len(resp.Header.Values("Set-Cookie")) > 0 {
+		return resp, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, rt.policy.MaxBodyBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(data)) > rt.policy.MaxBodyBytes {
+		// too large to cache, return the rest of body untouched
+		resp.Body = &partiallyReadBody{Reader: io.MultiReader(bytes.NewReader(data), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	rt.policy.add(key, &discoveryEntry{header: resp.Header.Clone(), body: data, storedAt: rt.policy.clock.Now()})
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	return resp, nil
+}
+
+// revalidate refreshes the stale document in background, the client request may be finished already
+func (rt *discoveryCacheRoundTripper) revalidate(key string, req *http.Request) {
+	defer rt.policy.finishRevalidating(key)
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryRevalidateTimeout)
+	defer cancel()
+
+	next := req.Clone(ctx)
+	next.Body = http.NoBody
+	u := *req.URL
+	next.URL = &u
+	resp, err := rt.fetch(key, next)
+	if err != nil {
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheRevalidateFailed)
+		klog.V(2).Infof("[discovery cache] cluster=%q failed to revalidate %v, err: %v", rt.cluster, req.URL.Path, redact.Error(err))
+		return
+	}
+	drainAndClose(resp)
+	if resp.StatusCode != http.StatusOK {
+		metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheRevalidateFailed)
+		klog.V(2).Infof("[discovery cache] cluster=%q failed to revalidate %v, upstream responded %v", rt.cluster, req.URL.Path, resp.StatusCode)
+		return
+	}
+	metrics.RecordDiscoveryCache(rt.cluster, discoveryCacheRevalidated)
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// discoveryUpstream serves a versioned discovery document, or fails if down
+type discoveryUpstream struct {
+	mu      sync.Mutex
+	version int
+	calls   int
+	down    bool
+}
+
+func (u *discoveryUpstream) RoundTrip(req *http.Request) (*http.Response, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.calls++
+	if u.down {
+		return nil, fmt.Errorf("dial tcp 10.0.0.1:6443: connect: connection refused")
+	}
+	body := fmt.Sprintf(`{"kind":"APIVersions","version":%d}`, u.version)
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(strings.NewReader(body)), ContentLength: int64(len(body)), Request: req}, nil
+}
+
+func (u *discoveryUpstream) set(version int, down bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.version, u.down = version, down
+}
+
+func (u *discoveryUpstream) callCount() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.calls
+}
+
+func readDiscovery(t *testing.T, rt http.RoundTripper) (string, string) {
+	req := httptest.NewRequest(http.MethodGet, "https://10.0.0.1:6443/api?timeout=32s", nil)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	return string(data), resp.Header.Get("Warning")
+}
+
+func Test_discoveryCacheRoundTripper(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	policy := newDiscoveryCachePolicyWithClock(10*time.Second, 20*time.Second, time.Minute, 1<<20, fakeClock)
+	upstream := &discoveryUpstream{version: 1}
+	rt := newDiscoveryCacheRoundTripper(upstream, policy, "test")
+
+	if body, warning := readDiscovery(t, rt); !strings.Contains(body, `"version":1`) || len(warning) > 0 {
+		t.Fatalf("miss: body = %v, warning = %v", body, warning)
+	}
+	upstream.set(2, false)
+	if body, _ := readDiscovery(t, rt); !strings.Contains(body, `"version":1`) || upstream.callCount() != 1 {
+		t.Fatalf("hit: body = %v, upstream calls = %v", body, upstream.callCount())
+	}
+
+	// stale while revalidate
+	fakeClock.Step(15 * time.Second)
+	if body, warning := readDiscovery(t, rt); !strings.Contains(body, `"version":1`) || warning != staleDiscoveryWarning {
+		t.Fatalf("stale: body = %v, warning = %v", body, warning)
+	}
+	for i := 0; i < 100 && upstream.callCount() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i := 0; i < 100; i++ {
+		if body, _ := readDiscovery(t, rt); strings.Contains(body, `"version":2`) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if body, warning := readDiscovery(t, rt); !strings.Contains(body, `"version":2`) || len(warning) > 0 {
+		t.Fatalf("revalidated: body = %v, warning = %v", body, warning)
+	}
+
+	// stale if error
+	upstream.set(3, true)
+	fakeClock.Step(40 * time.Second)
+	if body, warning := readDiscovery(t, rt); !strings.Contains(body, `"version":2`) || warning != staleDiscoveryWarning {
+		t.Fatalf("stale if error: body = %v, warning = %v", body, warning)
+	}
+
+	// too stale to serve
+	fakeClock.Step(time.Minute)
+	req := httptest.NewRequest(http.MethodGet, "https://10.0.0.1:6443/api", nil)
+	if _, err := rt.RoundTrip(req); err == nil {
+		t.Errorf("RoundTrip() expected error of upstream after stale-if-error window")
+	}
+}
+
+func TestDiscoveryCachePolicy_ServeStale(t *testing.T) {
+	fakeClock := clock.NewFakeClock(time.Now())
+	policy := newDiscoveryCachePolicyWithClock(10*time.Second, 0, time.Minute, 1<<20, fakeClock)
+	rt := newDiscoveryCacheRoundTripper(&discoveryUpstream{version: 1}, policy, "test")
+	readDiscovery(t, rt)
+
+	req := httptest.NewRequest(http.MethodGet, "https://test/api", nil)
+	anonymous := &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}
+	if policy.ServeStale(httptest.NewRecorder(), req.WithContext(genericapirequest.WithUser(req.Context(), anonymous)), "test") {
+		t.Errorf("ServeStale() of anonymous user = true, want false")
+	}
+	authenticated := &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}}
+	req = req.WithContext(genericapirequest.WithUser(req.Context(), authenticated))
+	w := httptest.NewRecorder()
+	if !policy.ServeStale(w, req, "test") {
+		t.Fatalf("ServeStale() = false, want true")
+	}
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"version":1`) {
+		t.Errorf("ServeStale() code = %v, body = %v", w.Code, w.Body.String())
+	}
+	if policy.ServeStale(httptest.NewRecorder(), req, "other") {
+		t.Errorf("ServeStale() of another cluster = true, want false")
+	}
+
+	policy.Forget("test")
+	if policy.ServeStale(httptest.NewRecorder(), req, "test") {
+		t.Errorf("ServeStale() after Forget = true, want false")
+	}
+}
+
+func Test_isDiscoveryPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/api", true},
+		{"/api/v1", true},
+		{"/apis", true},
+		{"/apis/apps", true},
+		{"/apis/apps/v1", true},
+		{"/openapi/v2", true},
+		{"/openapi/v3/apis/apps/v1", true},
+		{"/version", true},
+		{"/api/v1/pods", false},
+		{"/apis/apps/v1/deployments", false},
+		{"/healthz", false},
+	}
+	for _, tt := range tests {
+		if got := isDiscoveryPath(tt.path); got != tt.want {
+			t.Errorf("isDiscoveryPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
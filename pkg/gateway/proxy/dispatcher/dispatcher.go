@@ -16,6 +16,7 @@ package dispatcher
 
 import (
 	"context"
+	goerrors "errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -25,7 +26,9 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/httpstream"
 	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apiserver/pkg/endpoints/filters"
 	"k8s.io/apiserver/pkg/endpoints/handlers/responsewriters"
@@ -36,8 +39,16 @@ import (
 
 	"github.com/kubewharf/kubegateway/pkg/clusters"
 	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
 	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
 	"github.com/kubewharf/kubegateway/pkg/gateway/net"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/reverseproxy"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/transform"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
 )
 
 var (
@@ -46,15 +57,104 @@ var (
 
 type dispatcher struct {
 	clusters.Manager
-	codecs          serializer.CodecFactory
-	enableAccessLog bool
+	codecs           serializer.CodecFactory
+	enableAccessLog  bool
+	fleet            *FleetRoute
+	retry            *RetryPolicy
+	exemption        *RateLimitExemption
+	rewrite          *URLRewritePolicy
+	priority         *PriorityPolicy
+	shedding         *LoadSheddingPolicy
+	rateLimitHeaders bool
+	bandwidth        *BandwidthPolicy
+	disableSplice    bool
+	drain            *DrainPolicy
+	expired          *ExpiredResourceVersionPolicy
+	adaptive         *AdaptiveTimeoutPolicy
+	abuse            *AbuseReporter
+	discovery        *DiscoveryCachePolicy
+	cost             *CostEstimationPolicy
+	transformers     *transform.Chain
+	noRoute          *NoRoutePolicy
 }
 
-func NewDispatcher(clusterManager clusters.Manager, enableAccessLog bool) http.Handler {
+// Config contains policies of dispatcher, nil policies are disabled.
+type Config struct {
+	EnableAccessLog bool
+	// Fleet fans out read requests of fleet hostname to member clusters, nil means fleet route is disabled
+	Fleet *FleetRoute
+	// Retry retries failed upstream attempts on other endpoints, nil means requests are never retried
+	Retry *RetryPolicy
+	// Exemption exempts clients from rate limiting, nil means no client is exempted
+	Exemption *RateLimitExemption
+	// Rewrite rewrites upstream urls in response bodies, nil means response bodies are never rewritten
+	Rewrite *URLRewritePolicy
+	// Priority classifies requests into priorities, nil means all requests have the same priority
+	Priority *PriorityPolicy
+	// Shedding rejects low priority requests under pressure, nil means requests are only rejected
+	// by exhausted budget
+	Shedding *LoadSheddingPolicy
+	// RateLimitHeaders enables RateLimit-* response headers computed from the flow control of requests
+	RateLimitHeaders bool
+	// Bandwidth throttles streaming sessions, nil means they are not throttled
+	Bandwidth *BandwidthPolicy
+	// DisableSplice copies upgraded streams in user space instead of splicing them in kernel between
+	// plaintext tcp connections
+	DisableSplice bool
+	// Drain drains sessions in priority order, nil means sessions are not drained
+	Drain *DrainPolicy
+	// ExpiredResourceVersion guides clients listing with expired resourceVersion, nil means 410 Gone
+	// responses of lists are passed through as is
+	ExpiredResourceVersion *ExpiredResourceVersionPolicy
+	// AdaptiveTimeout derives timeouts of short requests from recent latencies, nil means they are
+	// bounded by the static response header timeout
+	AdaptiveTimeout *AdaptiveTimeoutPolicy
+	// Abuse reports users repeatedly rejected by rate limiting, nil means they are not reported
+	Abuse *AbuseReporter
+	// DiscoveryCache serves discovery documents from cache during brief upstream outages, nil means disabled
+	DiscoveryCache *DiscoveryCachePolicy
+	// CostEstimation charges expensive lists more flow control seats, nil means every request costs one seat
+	CostEstimation *CostEstimationPolicy
+	// ResponseTransformers transform proxied responses by hooks of extensions, nil means responses are not transformed
+	ResponseTransformers *transform.Chain
+	// NoRoute handles unknown hostnames and requests matching no dispatch policy, nil means they are
+	// responded 503 and 500 respectively
+	NoRoute *NoRoutePolicy
+}
+
+// NewDispatcher creates a dispatcher to proxy requests to upstream clusters with policies of config
+func NewDispatcher(clusterManager clusters.Manager, config Config) http.Handler {
+	if config.AdaptiveTimeout != nil {
+		// latency windows of deleted clusters are never used again
+		clusters.OnClusterDeleted(config.AdaptiveTimeout.Forget)
+	}
+	if config.DiscoveryCache != nil {
+		clusters.OnClusterDeleted(config.DiscoveryCache.Forget)
+	}
+	if config.CostEstimation != nil {
+		clusters.OnClusterDeleted(config.CostEstimation.Forget)
+	}
 	return &dispatcher{
-		Manager:         clusterManager,
-		codecs:          scheme.Codecs,
-		enableAccessLog: enableAccessLog,
+		Manager:          clusterManager,
+		codecs:           scheme.Codecs,
+		enableAccessLog:  config.EnableAccessLog,
+		fleet:            config.Fleet,
+		retry:            config.Retry,
+		exemption:        config.Exemption,
+		rewrite:          config.Rewrite,
+		priority:         config.Priority,
+		shedding:         config.Shedding,
+		rateLimitHeaders: config.RateLimitHeaders,
+		bandwidth:        config.Bandwidth,
+		disableSplice:    config.DisableSplice,
+		drain:            config.Drain,
+		expired:          config.ExpiredResourceVersion,
+		adaptive:         config.AdaptiveTimeout,
+		abuse:            config.Abuse,
+		discovery:        config.DiscoveryCache,
+		cost:             config.CostEstimation,
+		transformers:     config.ResponseTransformers,
+		noRoute:          config.NoRoute,
 	}
 }
 
@@ -76,44 +176,179 @@ func (d *dispatcher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		d.responseError(errors.NewInternalError(fmt.Errorf("no request info found in request context")), w, req, statusReasonInvalidRequestContext)
 		return
 	}
-	cluster, ok := d.Get(extraInfo.Hostname)
+	runtime.Must(request.MarkProxyPhase(ctx, request.ProxyPhaseAuthentication))
+	if d.isFleetRequest(extraInfo.Hostname) {
+		d.serveFleet(w, req, requestInfo, extraInfo)
+		return
+	}
+	cluster, ok := d.Resolve(extraInfo.Hostname)
 	if !ok {
-		d.responseError(errors.NewServiceUnavailable(fmt.Sprintf("the request cluster(%s) is not being proxied", extraInfo.Hostname)), w, req, statusReasonClusterNotBeingProxied)
+		if cluster, ok = d.noRoute.defaultCluster(d.Manager); ok {
+			// the request is handled as if it requested the default cluster from here on
+			extraInfo.Hostname = cluster.Cluster
+		}
+	}
+	if !ok {
+		d.responseNoRoute(w, req,
+			fmt.Sprintf("hostname %q is not an upstream cluster being proxied by gateway, check the server address of your kubeconfig", extraInfo.Hostname),
+			errors.NewServiceUnavailable(fmt.Sprintf("the request cluster(%s) is not being proxied", extraInfo.Hostname)), statusReasonClusterNotBeingProxied)
+		return
+	}
+	if err := request.SetUpstreamCluster(ctx, cluster.Cluster); err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
 		return
 	}
 
+	requestAttributes, err := filters.GetAuthorizerAttributes(ctx)
+	if err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
+		return
+	}
+	query := req.URL.Query()
+	// tenants of virtual cluster must not escape their namespaces and selectors of the shared cluster
+	if vc, ok := clusters.LookupVirtualCluster(extraInfo.Hostname); ok {
+		if err := vc.Authorize(requestAttributes); err != nil {
+			gr := schema.GroupResource{Group: requestInfo.APIGroup, Resource: requestInfo.Resource}
+			d.responseError(errors.NewForbidden(gr, requestInfo.Name, err), w, req, statusReasonVirtualClusterForbidden)
+			return
+		}
+		if err := vc.InjectSelectors(requestInfo.Verb, query); err != nil {
+			d.responseError(errors.NewBadRequest(err.Error()), w, req, statusReasonInvalidSelector)
+			return
+		}
+	}
+
 	if cluster.FeatureEnabled(features.CloseConnectionWhenIdle) {
 		// Send a GOAWAY and tear down the TCP connection when idle.
 		w.Header().Set("Connection", "close")
 	}
 
 	if cluster.FeatureEnabled(features.DenyAllRequests) {
-		d.responseError(errors.NewServiceUnavailable(fmt.Sprintf("request for %v denied by featureGate(DenyAllRequests)", extraInfo.Hostname)), w, req, statusReasonCircuitBreaker)
+		message := fmt.Sprintf("request for %v denied by featureGate(DenyAllRequests)", extraInfo.Hostname)
+		if !cluster.IsAuditOnly(clusters.AuditOnlyDenyAllRequests) {
+			d.responseError(errors.NewServiceUnavailable(message), w, req, statusReasonCircuitBreaker)
+			return
+		}
+		recordAuditOnlyRejection(req, extraInfo.Hostname, clusters.AuditOnlyDenyAllRequests, message)
+	}
+
+	if fault := cluster.PickFault(); fault != nil {
+		if !d.injectFault(w, req, extraInfo.Hostname, fault) {
+			return
+		}
+	}
+
+	// upstream authenticates clients itself in passthrough mode, client certificates can not be forwarded
+	if cluster.AuthMode() == clusters.AuthModePassthrough {
+		if _, ok := request.RawAuthorizationFrom(ctx); !ok {
+			d.responseError(errors.NewUnauthorized(fmt.Sprintf("cluster(%s) only accepts bearer token credentials which are passed through to upstream", extraInfo.Hostname)), w, req, statusReasonPassthroughNoCredential)
+			return
+		}
+	}
+
+	runtime.Must(request.MarkProxyPhase(ctx, request.ProxyPhaseRouting))
+
+	// ceilings are the ultimate backstop of a cluster during client storms, nobody bypasses them
+	if !cluster.TryAcceptQPSCeiling() {
+		d.responseError(errors.NewTooManyRequests(fmt.Sprintf("too many requests for cluster(%s), limited by ceiling(maxQPS=%d)", extraInfo.Hostname, cluster.ClusterCeilings().MaxQPS), retryAfter), w, req, statusReasonClusterCeilingExceeded)
 		return
 	}
 
-	requestAttributes, err := filters.GetAuthorizerAttributes(ctx)
-	if err != nil {
-		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
+	// system-critical clients bypass resource budget and flow control
+	exempt := d.exemption.Exempt(user)
+	if exempt {
+		metrics.RecordExemptedRequest(extraInfo.Hostname)
+	}
+
+	// long running requests are bounded by upstream, only short requests can pile up
+	// when upstream is extremely slow
+	longRunning := IsLongRunning(req, requestInfo)
+	if !longRunning && !exempt {
+		priority := d.priority.Classify(user, req)
+		if class := d.shedding.Shed(user, requestInfo, priority, cluster.RequestBudgetUtilization()); len(class) > 0 {
+			d.responseError(errors.NewTooManyRequests(fmt.Sprintf("%s requests for cluster(%s) are shed under pressure", class, extraInfo.Hostname), retryAfter), w, req, statusReasonLoadShed)
+			return
+		}
+		// critical requests are admitted before batch requests when the budget is exhausted
+		if !cluster.AcquireRequestBudget(ctx, priority, d.priority.Timeout()) {
+			d.responseError(errors.NewTooManyRequests(fmt.Sprintf("too many inflight requests for cluster(%s), limited by resource budget(maxInflightRequests=%d)", extraInfo.Hostname, cluster.ResourceBudget().MaxInflightRequests), retryAfter), w, req, statusReasonClusterBudgetExhausted)
+			return
+		}
+		defer cluster.ReleaseRequestBudget()
+	}
+
+	// a buggy client opening thousands of watches exhausts upstream memory
+	if requestInfo.Verb == "watch" && !exempt {
+		if max, ok := cluster.TryAcquireWatchBudget(user.GetName()); !ok {
+			d.responseError(errors.NewTooManyRequests(fmt.Sprintf("too many concurrent watches of user(%s) for cluster(%s), limited by resource budget(maxWatchesPerUser=%d)", user.GetName(), extraInfo.Hostname, max), retryAfter), w, req, statusReasonWatchLimitExceeded)
+			return
+		}
+		defer cluster.ReleaseWatchBudget(user.GetName())
+	}
+
+	endpointPicker, err := cluster.MatchRequest(requestAttributes, req.UserAgent())
+	var denied *clusters.ExpressionDeniedError
+	if goerrors.As(err, &denied) {
+		gr := schema.GroupResource{Group: requestInfo.APIGroup, Resource: requestInfo.Resource}
+		d.responseError(errors.NewForbidden(gr, requestInfo.Name, denied), w, req, statusReasonExpressionDenied)
+		return
+	}
+	if goerrors.Is(err, clusters.ErrNoRouterRuleMatches) {
+		d.responseNoRoute(w, req,
+			fmt.Sprintf("%s %s matches no dispatch policy of upstream cluster %q", req.Method, req.URL.Path, extraInfo.Hostname),
+			errors.NewInternalError(err), normalizeErrToReason(err))
 		return
 	}
-	endpointPicker, err := cluster.MatchAttributes(requestAttributes)
 	if err != nil {
 		d.responseError(errors.NewInternalError(err), w, req, normalizeErrToReason(err))
 		return
 	}
 
-	flowcontrol := endpointPicker.FlowControl()
-	if !flowcontrol.TryAcquire() {
-		//TODO: exempt master request and long running request
-		// add metrics
-		d.responseError(errors.NewTooManyRequests(fmt.Sprintf("too many requests for cluster(%s), limited by flowControl(%v)", extraInfo.Hostname, flowcontrol.String()), retryAfter), w, req, statusReasonRateLimited)
+	if err := request.SetFlowControl(ctx, gatewayflowcontrol.NameOf(endpointPicker.FlowControl())); err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
 		return
 	}
-	defer flowcontrol.Release()
+
+	if !exempt {
+		flowcontrol := endpointPicker.FlowControl()
+		// expensive lists are charged more seats than cheap gets
+		flow := gatewayflowcontrol.Flow{Schema: endpointPicker.FlowSchema(), Distinguisher: user.GetName()}
+		requested := d.cost.Seats(extraInfo.Hostname, req, requestInfo)
+		seats, acquired := gatewayflowcontrol.TryAcquireFlow(flowcontrol, flow, requested)
+		if d.cost != nil {
+			metrics.RecordRequestSeats(extraInfo.Hostname, requestInfo.Verb, requestInfo.Resource, seats)
+		}
+		if d.rateLimitHeaders {
+			setRateLimitHeaders(w.Header(), flowcontrol)
+		}
+		if !acquired {
+			message := fmt.Sprintf("too many requests for cluster(%s), limited by flowControl(%v)", extraInfo.Hostname, flowcontrol.String())
+			//TODO: exempt long running request
+			d.responseError(errors.NewTooManyRequests(message, retryAfter), w, req, statusReasonRateLimited)
+			return
+		}
+		defer gatewayflowcontrol.ReleaseFlow(flowcontrol, flow, seats)
+
+		// draft flow control schemas must not be visible to clients
+		for _, shadow := range endpointPicker.AuditOnlyFlowControls() {
+			shadowSeats, ok := gatewayflowcontrol.TryAcquireFlow(shadow, flow, requested)
+			if ok {
+				defer gatewayflowcontrol.ReleaseFlow(shadow, flow, shadowSeats)
+				continue
+			}
+			message := fmt.Sprintf("too many requests for cluster(%s), limited by flowControl(%v)", extraInfo.Hostname, shadow.String())
+			recordAuditOnlyRejection(req, extraInfo.Hostname, gatewayflowcontrol.NameOf(shadow), message)
+		}
+	}
+
+	runtime.Must(request.MarkProxyPhase(ctx, request.ProxyPhaseQueueing))
 
 	endpoint, err := endpointPicker.Pop()
 	if err != nil {
+		// client-go initialization survives brief upstream outages by stale discovery documents
+		if d.discovery.ServeStale(w, req, extraInfo.Hostname) {
+			return
+		}
 		d.responseError(errors.NewServiceUnavailable(err.Error()), w, req, statusReasonNoReadyEndpoints)
 		return
 	}
@@ -134,9 +369,58 @@ func (d *dispatcher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	location.Scheme = ep.Scheme
 	location.Host = ep.Host
 	location.Path = req.URL.Path
-	location.RawQuery = req.URL.Query().Encode()
+	location.RawQuery = query.Encode()
 
 	newReq, cancel := newRequestForProxy(location, req, extraInfo.Hostname)
+
+	// long running requests use a separate connection pool to avoid starving short requests
+	transport := endpoint.ProxyTransportFor(longRunning)
+	if d.retry.Enabled() {
+		replayable, err := spoolRequestBody(newReq, d.retry.MaxBodyBytes)
+		if err != nil {
+			cancel()
+			d.responseError(errors.NewBadRequest(fmt.Sprintf("failed to read request body: %v", err)), w, req, statusReasonReadRequestBodyFailed)
+			return
+		}
+		if replayable {
+			transport = newRetryRoundTripper(d.retry, extraInfo.Hostname, endpointPicker, endpoint, longRunning)
+		}
+	}
+	if d.adaptive != nil && !longRunning {
+		// every attempt of retries is bounded by the adaptive timeout
+		transport = newAdaptiveTimeoutRoundTripper(transport, d.adaptive, extraInfo.Hostname, requestInfo.Verb, requestInfo.Resource)
+	}
+	if policy := cluster.RedirectPolicy(); policy != clusters.RedirectPolicyPassThrough {
+		transport = newRedirectRoundTripper(transport, cluster, policy, extraInfo.Scheme, req.Host)
+	}
+	if !longRunning && d.rewrite.Matches(req.URL.Path) {
+		transport = newURLRewritingRoundTripper(transport, cluster, d.rewrite.MaxBodyBytes, extraInfo.Scheme, req.Host)
+	}
+	if d.expired != nil && requestInfo.Verb == "list" {
+		transport = newExpiredResourceVersionRoundTripper(transport, d.expired, extraInfo.Hostname, requestInfo.Resource, user.GetName(), req.UserAgent())
+	}
+	if d.discovery.Matches(req) {
+		transport = newDiscoveryCacheRoundTripper(transport, d.discovery, extraInfo.Hostname)
+	}
+	// transformers of extensions are closest to client, so checksums verify the proxy copy path only
+	if d.transformers != nil && !httpstream.IsUpgradeRequest(req) {
+		var complete func()
+		w, complete = d.transformers.Wrap(w, req, extraInfo.Hostname, requestInfo.Verb)
+		defer complete()
+	}
+	// checksums are compared between bytes from upstream and bytes to client on critical read paths
+	var checksum *responseChecksum
+	if req.Method == http.MethodGet && !httpstream.IsUpgradeRequest(req) && cluster.FeatureEnabled(features.ResponseChecksum) {
+		checksum = newResponseChecksum(extraInfo.Hostname, endpoint.Endpoint)
+		transport = checksum.RoundTripper(transport)
+		w = checksum.Wrap(w)
+	}
+	if newReq.Body != nil {
+		newReq.Body = cluster.ThrottleRequestBody(newReq.Context(), newReq.Body)
+	}
+	// cancel upstream request if client fails to upload the whole body
+	withClientBody(newReq, cancel)
+
 	// close this request if endpoint is stoped
 	go func() {
 		select {
@@ -153,6 +437,44 @@ func (d *dispatcher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}()
 
+	// sessions are drained in priority order when gateway is terminating
+	if d.drain != nil {
+		var release func()
+		var ok bool
+		w, release, ok = d.drain.Track(w, drainClassOf(req, requestInfo, longRunning), isInteractiveSession(requestInfo), cancel)
+		if !ok {
+			cancel()
+			d.responseError(errors.NewServiceUnavailable("gateway is shutting down"), w, req, statusReasonShuttingDown)
+			return
+		}
+		defer release()
+	}
+
+	// a single user streaming gigabytes of logs must not saturate network of gateway
+	if !exempt && isStreamingSession(req, requestInfo) {
+		var release func()
+		w, release = d.bandwidth.Limit(w, req.Context(), extraInfo.Hostname, user.GetName())
+		defer release()
+	}
+
+	// watches closed by gateway tell reflectors where to resume
+	var resume *watchResumeWriter
+	if requestInfo.Verb == "watch" && cluster.FeatureEnabled(features.WatchResumeHint) {
+		resume = newWatchResumeWriter(w, req)
+		w = resume
+	}
+
+	// failed handshakes, upstream resets and client disconnects of upgraded streams are told apart
+	var responder proxy.ErrorResponder = d
+	proxyReq := newReq
+	if httpstream.IsUpgradeRequest(req) {
+		stream := newUpgradeStream(req, d, extraInfo.Hostname, endpoint.Endpoint)
+		defer stream.Finish()
+		w = stream.Wrap(w)
+		proxyReq = stream.WithContext(newReq)
+		responder = stream
+	}
+
 	logging := d.enableAccessLog && endpointPicker.EnableLog()
 	delegate := decorateResponseWriter(req, w, logging, requestInfo, extraInfo.Hostname, endpoint.Endpoint, user, extraInfo.Impersonator)
 	delegate.MonitorBeforeProxy()
@@ -160,8 +482,40 @@ func (d *dispatcher) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	rw := responsewriter.WrapForHTTP1Or2(delegate)
 
-	proxyHandler := NewUpgradeAwareHandler(location, endpoint.ProxyTransport, endpoint.PorxyUpgradeTransport, false, false, d, endpoint)
-	proxyHandler.ServeHTTP(rw, newReq)
+	runtime.Must(request.MarkProxyPhase(ctx, request.ProxyPhaseRequestProcessing))
+	runtime.Must(request.StartProxyAttempt(req.Context()))
+	if len(request.ProxyAttemptsFrom(req.Context())) == 0 {
+		if latency, ok := request.ProxySelfLatencyFrom(req.Context()); ok {
+			gatewaydebug.DefaultAutoProfiler.Observe(req, latency)
+		}
+	}
+	// newer websocket clients of exec, attach and port-forward are translated to SPDY for older upstreams
+	if protocol, ok := translatedStreamProtocol(req, requestInfo); ok && cluster.FeatureEnabled(features.WebSocketStreamTranslation) {
+		newStreamTranslator(location, endpoint.PorxyUpgradeTransport, responder, extraInfo.Hostname, protocol).ServeHTTP(rw, proxyReq)
+		return
+	}
+	proxyHandler := newEndpointProxyHandler(location, transport, endpoint.PorxyUpgradeTransport, responder, endpoint)
+	flush := cluster.FlushIntervals()
+	proxyHandler.FlushInterval = flush.Standard
+	if isStreamingResponse(req, requestInfo) {
+		proxyHandler.FlushInterval = flush.Streaming
+		proxyHandler.Streaming = true
+	}
+	proxyHandler.PreserveUpstreamCORS = cluster.UpstreamCORSMode(req.URL.Path) != clusters.UpstreamCORSStrip
+	proxyHandler.DisableSplice = d.disableSplice
+	proxyHandler.ServeHTTP(rw, proxyReq)
+	d.cost.Observe(extraInfo.Hostname, requestInfo, delegate.Status(), int64(delegate.ContentLength()))
+	if checksum != nil {
+		checksum.Verify(req)
+	}
+
+	// the upstream watch is canceled by gateway while client is still waiting for events
+	if resume != nil && newReq.Context().Err() != nil && req.Context().Err() == nil {
+		// the stream ends cleanly after the bookmark, clients re-watch from its resourceVersion
+		if resume.WriteBookmark() {
+			metrics.RecordWatchResumeHint(extraInfo.Hostname)
+		}
+	}
 }
 
 func (d *dispatcher) responseError(err *errors.StatusError, w http.ResponseWriter, req *http.Request, reason string) {
@@ -174,22 +528,51 @@ func (d *dispatcher) responseError(err *errors.StatusError, w http.ResponseWrite
 		w.Header().Set("Retry-After", strconv.Itoa(retryAfter*30))
 	}
 
+	attempts := request.ProxyAttemptsFrom(req.Context())
+	withAttemptCauses(err, attempts)
+	rejection.WithStatusDetails(err, reason)
+
 	code := int(err.Status().Code)
 	if captureErrorReason(reason) {
-		var urlHost string
-		if req.URL != nil {
-			// url.Host is different from req.Host when caller is reverse proxy.
-			// we need this host to determine which endpoint it is if possible.
-			urlHost = req.URL.Host
-		}
-		klog.Errorf("[proxy termination] method=%q host=%q uri=%q url.host=%v resp=%v reason=%q message=[%v]", req.Method, net.HostWithoutPort(req.Host), req.RequestURI, urlHost, code, reason, err.Error())
+		d.terminationLogf(req, reason, attempts, code, err)
 	}
 
 	runtime.Must(request.SetProxyTerminated(req.Context(), reason))
+	d.abuse.ObserveRejection(req, reason)
 
 	responsewriters.ErrorNegotiated(err, d.codecs, gv, w, req)
 }
 
+// terminationLogf writes the error log of a request terminated by upstream errors, it is sampled by the
+// upstream cluster and reason like endpointProxyHooks.errorLogf, so a connection refused storm produces
+// bounded log volume. Requests not routed to a cluster are always logged.
+func (d *dispatcher) terminationLogf(req *http.Request, reason string, attempts []request.UpstreamAttempt, code int, err *errors.StatusError) {
+	var suppressed int64
+	if name, ok := request.UpstreamClusterFrom(req.Context()); ok {
+		if cluster, ok := d.Get(name); ok {
+			var logged bool
+			if logged, suppressed = cluster.SampleErrorLog(reason); !logged {
+				return
+			}
+		}
+	}
+	var urlHost string
+	if req.URL != nil {
+		// url.Host is different from req.Host when caller is reverse proxy.
+		// we need this host to determine which endpoint it is if possible.
+		urlHost = req.URL.Host
+	}
+	requestID, _ := request.RequestIDFrom(req.Context())
+	traceID, _ := request.TraceIDFrom(req.Context())
+	format := "[proxy termination] method=%q host=%q uri=%q url.host=%v resp=%v reason=%q code=%v requestID=%q traceID=%q message=[%v] attempts=%v"
+	args := []interface{}{req.Method, net.HostWithoutPort(req.Host), redact.URI(req.RequestURI), urlHost, code, reason, rejection.CodeOf(reason), requestID, traceID, redact.Error(err), redact.Text(attemptsToString(attempts))}
+	if suppressed > 0 {
+		format += ", %d similar errors suppressed"
+		args = append(args, suppressed)
+	}
+	klog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
+
 // newRequestForProxy returns a shallow copy of the original request with a context that may include a timeout for discovery requests
 func newRequestForProxy(location *url.URL, req *http.Request, _ string) (*http.Request, context.CancelFunc) {
 	ctx := req.Context()
@@ -205,6 +588,24 @@ func newRequestForProxy(location *url.URL, req *http.Request, _ string) (*http.R
 
 // implements k8s.io/apimachinery/pkg/util/proxy.ErrorResponder interface
 func (d *dispatcher) Error(w http.ResponseWriter, req *http.Request, err error) {
+	if goerrors.Is(err, reverseproxy.ErrPanicked) {
+		// it is a bug of gateway instead of a failed attempt to upstream
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonProxyPanicked)
+		return
+	}
+	recordFailedAttempt(req, err)
+	switch errclass.ClassifyProxyError(err) {
+	case errclass.DialBudget:
+		d.responseError(errors.NewTooManyRequests(err.Error(), retryAfter), w, req, statusReasonClusterBudgetExhausted)
+		return
+	case errclass.ResponseHeaderTimeout:
+		d.responseError(errors.NewTimeoutError(err.Error(), retryAfter), w, req, statusReasonUpstreamHeaderTimeout)
+		return
+	case errclass.ResponseHeaderTooLarge, errclass.MalformedHeader:
+		// respond 502 with the cluster and endpoint in message, they are not retriable
+		d.responseError(&errors.StatusError{ErrStatus: *errorToProxyStatus(err)}, w, req, statusReasonUpstreamInvalidHeaders)
+		return
+	}
 	status := errorToProxyStatus(err)
 	reason := statusReasonUpgradeAwareHandlerError
 	if status.Code == http.StatusBadGateway {
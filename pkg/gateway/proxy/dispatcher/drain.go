@@ -0,0 +1,302 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// classes of sessions drained in order when gateway is terminating
+const (
+	// DrainClassShort are non-long-running requests except lists, they are waited to finish
+	DrainClassShort = "short"
+	// DrainClassList are list requests, they are waited to finish
+	DrainClassList = "list"
+	// DrainClassWatch are watch requests, they are closed gradually so clients re-watch on other replicas
+	DrainClassWatch = "watch"
+	// DrainClassStream are streaming sessions, e.g. exec and port-forward, they are cut with a warning
+	// after waiting for them to finish
+	DrainClassStream = "stream"
+)
+
+// DrainClasses are all known drain classes in the default order
+var DrainClasses = []string{DrainClassShort, DrainClassList, DrainClassWatch, DrainClassStream}
+
+const (
+	drainPollInterval = 100 * time.Millisecond
+	// drainWarningTimeout is the maximum duration to wait for a frame boundary of streams to warn clients
+	drainWarningTimeout = time.Second
+)
+
+// drainClassOf returns the drain class of request
+func drainClassOf(req *http.Request, requestInfo *genericapirequest.RequestInfo, longRunning bool) string {
+	switch {
+	case requestInfo.Verb == "watch":
+		return DrainClassWatch
+	case isStreamingSession(req, requestInfo) || longRunning:
+		return DrainClassStream
+	case requestInfo.Verb == "list":
+		return DrainClassList
+	}
+	return DrainClassShort
+}
+
+// DrainPhase drains sessions of one class in at most Timeout
+type DrainPhase struct {
+	Class   string
+	Timeout time.Duration
+}
+
+// DrainPolicy drains sessions phase by phase in priority order when gateway is terminating,
+// instead of cutting all sessions at the end of one flat grace period.
+type DrainPolicy struct {
+	Phases []DrainPhase
+
+	mu sync.Mutex
+	// draining classes, new watches and streams of them are rejected
+	draining map[string]bool
+	sessions map[string]map[*drainSession]struct{}
+}
+
+// drainSession is a tracked session which can be closed by drain policy
+type drainSession struct {
+	mu     sync.Mutex
+	cancel func()
+	conn   net.Conn
+	// warnable sessions are exec and attach sessions, whose clients are warned before they are cut
+	warnable bool
+}
+
+// warn tells the client of session that it will be cut, it does not block draining
+func (s *drainSession) warn(message string) {
+	s.mu.Lock()
+	conn, ok := s.conn.(*warningConn)
+	s.mu.Unlock()
+	if ok {
+		go conn.Warn(message, drainWarningTimeout)
+	}
+}
+
+func (s *drainSession) close() {
+	s.closeWithReason("")
+}
+
+// closeWithReason closes the session, clients of websocket sessions are told reason by a close frame
+func (s *drainSession) closeWithReason(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// hijacked connections are not closed by canceling request context
+	if conn, ok := s.conn.(*warningConn); ok && len(reason) > 0 {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn.CloseWithReason(reason, drainWarningTimeout) //nolint
+		}()
+		select {
+		case <-done:
+		case <-time.After(drainWarningTimeout):
+			// client does not read the stream, closing the connection unblocks the writer
+			conn.Conn.Close() //nolint
+		}
+	} else if s.conn != nil {
+		s.conn.Close() //nolint
+	}
+	s.cancel()
+}
+
+// NewDrainPolicy creates a drain policy which drains classes in order of phases
+func NewDrainPolicy(phases []DrainPhase) *DrainPolicy {
+	return &DrainPolicy{
+		Phases:   phases,
+		draining: map[string]bool{},
+		sessions: map[string]map[*drainSession]struct{}{},
+	}
+}
+
+// Track tracks a session of class until release is called, cancel is called if the session is closed
+// by draining. Clients of warnable streaming sessions are warned before they are cut. It returns false
+// if the session should be rejected because its class is being drained.
+func (p *DrainPolicy) Track(w http.ResponseWriter, class string, warnable bool, cancel func()) (http.ResponseWriter, func(), bool) {
+	if p == nil {
+		return w, func() {}, true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining[class] && (class == DrainClassWatch || class == DrainClassStream) {
+		return w, func() {}, false
+	}
+	session := &drainSession{cancel: cancel, warnable: warnable}
+	if p.sessions[class] == nil {
+		p.sessions[class] = map[*drainSession]struct{}{}
+	}
+	p.sessions[class][session] = struct{}{}
+	release := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.sessions[class], session)
+	}
+	if class == DrainClassStream {
+		w = &drainTrackingWriter{ResponseWriter: w, session: session}
+	}
+	return w, release, true
+}
+
+func (p *DrainPolicy) count(class string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions[class])
+}
+
+func (p *DrainPolicy) snapshot(class string) []*drainSession {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessions := make([]*drainSession, 0, len(p.sessions[class]))
+	for s := range p.sessions[class] {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+// Drain runs all phases in order and blocks until they are finished
+func (p *DrainPolicy) Drain() {
+	if p == nil {
+		return
+	}
+	for _, phase := range p.Phases {
+		p.mu.Lock()
+		p.draining[phase.Class] = true
+		p.mu.Unlock()
+
+		start := time.Now()
+		klog.Infof("[drain] start draining %d %s sessions in %v", p.count(phase.Class), phase.Class, phase.Timeout)
+		closed := 0
+		switch phase.Class {
+		case DrainClassWatch:
+			closed = p.closeGradually(phase)
+		case DrainClassStream:
+			closed = p.waitThenClose(phase)
+		default:
+			p.wait(phase.Class, phase.Timeout)
+		}
+		metrics.RecordDrainedSessions(phase.Class, closed)
+		klog.Infof("[drain] finished draining %s sessions in %v, closed=%d, remaining=%d", phase.Class, time.Since(start), closed, p.count(phase.Class))
+	}
+}
+
+// wait waits until all sessions of class finished or timeout
+func (p *DrainPolicy) wait(class string, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for p.count(class) > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+}
+
+// closeGradually spreads closing of sessions over the phase, so that clients do not re-watch
+// on other replicas all at once
+func (p *DrainPolicy) closeGradually(phase DrainPhase) int {
+	sessions := p.snapshot(phase.Class)
+	if len(sessions) == 0 {
+		return 0
+	}
+	interval := phase.Timeout / time.Duration(len(sessions))
+	for i, s := range sessions {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+		s.close()
+	}
+	return len(sessions)
+}
+
+// waitThenClose warns clients, waits for sessions to finish by themselves and cuts the remaining
+// ones at timeout
+func (p *DrainPolicy) waitThenClose(phase DrainPhase) int {
+	if n := p.count(phase.Class); n > 0 {
+		klog.Warningf("[drain] gateway is terminating, %d %s sessions will be cut after %v", n, phase.Class, phase.Timeout)
+		warning := fmt.Sprintf("kube-gateway is terminating, this session will be closed in %v, please reconnect to continue\r\n", phase.Timeout)
+		for _, s := range p.snapshot(phase.Class) {
+			s.warn(warning)
+		}
+	}
+	p.wait(phase.Class, phase.Timeout)
+	sessions := p.snapshot(phase.Class)
+	for _, s := range sessions {
+		s.closeWithReason("kube-gateway is terminating")
+	}
+	if len(sessions) > 0 {
+		klog.Warningf("[drain] cut %d %s sessions which did not finish in %v", len(sessions), phase.Class, phase.Timeout)
+	}
+	return len(sessions)
+}
+
+// drainTrackingWriter records the hijacked connection of a streaming session, so it can be
+// closed by draining. It always implements http.Flusher, http.CloseNotifier and http.Hijacker,
+// so responsewriter.WrapForHTTP1Or2 hijacks connections through it.
+type drainTrackingWriter struct {
+	http.ResponseWriter
+	session *drainSession
+}
+
+func (w *drainTrackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *drainTrackingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *drainTrackingWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *drainTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	w.session.mu.Lock()
+	defer w.session.mu.Unlock()
+	if w.session.warnable {
+		warning := newWarningConn(conn)
+		// the 101 response is written to brw by some proxies, it must be followed as well
+		if err := brw.Writer.Flush(); err != nil {
+			return nil, nil, err
+		}
+		brw.Writer.Reset(warning)
+		conn = warning
+	}
+	w.session.conn = conn
+	return conn, brw, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func Test_drainClassOf(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		requestInfo *genericapirequest.RequestInfo
+		longRunning bool
+		want        string
+	}{
+		{"get", "/api/v1/namespaces/default/pods/a", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods"}, false, DrainClassShort},
+		{"list", "/api/v1/pods", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"}, false, DrainClassList},
+		{"watch", "/api/v1/pods?watch=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "watch", Resource: "pods"}, true, DrainClassWatch},
+		{"exec", "/api/v1/namespaces/default/pods/a/exec", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "create", Resource: "pods", Subresource: "exec"}, true, DrainClassStream},
+		{"logs", "/api/v1/namespaces/default/pods/a/log?follow=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods", Subresource: "log"}, true, DrainClassStream},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if got := drainClassOf(req, tt.requestInfo, tt.longRunning); got != tt.want {
+				t.Errorf("drainClassOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDrainPolicy(t *testing.T) {
+	p := NewDrainPolicy([]DrainPhase{
+		{Class: DrainClassShort, Timeout: time.Second},
+		{Class: DrainClassWatch, Timeout: 50 * time.Millisecond},
+		{Class: DrainClassStream, Timeout: 50 * time.Millisecond},
+	})
+
+	var shortCanceled, watchCanceled, streamCanceled int32
+	_, releaseShort, ok := p.Track(httptest.NewRecorder(), DrainClassShort, false, func() { atomic.AddInt32(&shortCanceled, 1) })
+	if !ok {
+		t.Fatalf("Track() short session rejected before draining")
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, ok := p.Track(httptest.NewRecorder(), DrainClassWatch, false, func() { atomic.AddInt32(&watchCanceled, 1) }); !ok {
+			t.Fatalf("Track() watch session rejected before draining")
+		}
+	}
+	w, _, ok := p.Track(httptest.NewRecorder(), DrainClassStream, true, func() { atomic.AddInt32(&streamCanceled, 1) })
+	if !ok {
+		t.Fatalf("Track() stream session rejected before draining")
+	}
+	if _, isTracking := w.(*drainTrackingWriter); !isTracking {
+		t.Errorf("Track() stream session writer is not wrapped")
+	}
+
+	// short session finishes by itself during the first phase
+	time.AfterFunc(200*time.Millisecond, releaseShort)
+
+	done := make(chan struct{})
+	go func() {
+		p.Drain()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Drain() did not finish")
+	}
+
+	if got := atomic.LoadInt32(&shortCanceled); got != 0 {
+		t.Errorf("short sessions canceled = %v, want 0", got)
+	}
+	if got := atomic.LoadInt32(&watchCanceled); got != 2 {
+		t.Errorf("watch sessions canceled = %v, want 2", got)
+	}
+	if got := atomic.LoadInt32(&streamCanceled); got != 1 {
+		t.Errorf("stream sessions canceled = %v, want 1", got)
+	}
+	if _, _, ok := p.Track(httptest.NewRecorder(), DrainClassWatch, false, func() {}); ok {
+		t.Errorf("Track() new watch session is accepted after draining")
+	}
+	if _, _, ok := p.Track(httptest.NewRecorder(), DrainClassShort, false, func() {}); !ok {
+		t.Errorf("Track() new short session is rejected after draining")
+	}
+}
+
+func TestDrainPolicy_Nil(t *testing.T) {
+	var p *DrainPolicy
+	if _, release, ok := p.Track(httptest.NewRecorder(), DrainClassWatch, false, func() {}); !ok {
+		t.Errorf("Track() of nil policy rejected session")
+	} else {
+		release()
+	}
+	p.Drain()
+}
@@ -0,0 +1,231 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	// maxUpgradeResponseHeaderBytes limits the 101 response header buffered to find the stream protocol
+	maxUpgradeResponseHeaderBytes = 64 * 1024
+	// websocket opcodes
+	websocketText   = 0x1
+	websocketBinary = 0x2
+	websocketClose  = 0x8
+	// websocketGoingAway is the close code of an endpoint going away, e.g. a server going down
+	websocketGoingAway = 1001
+	// maxWebsocketCloseReason is the maximum reason of close frames whose payload is at most 125 bytes
+	maxWebsocketCloseReason = 123
+)
+
+// warningConn is the hijacked client connection of an exec or attach session. It follows frames of
+// the websocket stream written to client, so that drain warnings are written between frames in the
+// channel.k8s.io protocol negotiated by the session, where they are shown as stderr of the command.
+// SPDY sessions are not warned, their stream ids are only in zlib-compressed headers.
+type warningConn struct {
+	net.Conn
+
+	// mu serializes writes of proxied frames and warnings
+	mu sync.Mutex
+	// header buffers the 101 response until it is complete
+	header []byte
+	// protocol is the negotiated channel protocol, empty means warnings can not be written
+	protocol string
+	// parsing is false if the stream is not a websocket stream or can not be followed
+	parsing bool
+	started bool
+	// frameHeader buffers a partially written frame header, remaining is the payload bytes of
+	// the current frame not written yet
+	frameHeader []byte
+	remaining   uint64
+}
+
+// isInteractiveSession returns true for exec and attach sessions, whose stderr is shown to users
+func isInteractiveSession(requestInfo *genericapirequest.RequestInfo) bool {
+	return requestInfo.IsResourceRequest && (requestInfo.Subresource == "exec" || requestInfo.Subresource == "attach")
+}
+
+func newWarningConn(conn net.Conn) *warningConn {
+	return &warningConn{Conn: conn, parsing: true}
+}
+
+func (c *warningConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, err := c.Conn.Write(p)
+	c.follow(p[:n])
+	return n, err
+}
+
+// follow updates frame boundaries by data written to client
+func (c *warningConn) follow(data []byte) {
+	for c.parsing && len(data) > 0 {
+		if !c.started {
+			data = c.followHeader(data)
+			continue
+		}
+		if c.remaining > 0 {
+			if uint64(len(data)) <= c.remaining {
+				c.remaining -= uint64(len(data))
+				return
+			}
+			data = data[c.remaining:]
+			c.remaining = 0
+			continue
+		}
+		c.frameHeader = append(c.frameHeader, data[0])
+		data = data[1:]
+		if size, ok := websocketFrameHeaderSize(c.frameHeader); ok && len(c.frameHeader) == size {
+			c.remaining = websocketPayloadLength(c.frameHeader)
+			c.frameHeader = c.frameHeader[:0]
+		}
+	}
+}
+
+// followHeader buffers the 101 response and returns data after it
+func (c *warningConn) followHeader(data []byte) []byte {
+	previous := len(c.header)
+	c.header = append(c.header, data...)
+	end := bytes.Index(c.header, []byte("\r\n\r\n"))
+	if end < 0 {
+		if len(c.header) > maxUpgradeResponseHeaderBytes {
+			c.parsing = false
+		}
+		return nil
+	}
+	end += 4
+	c.started = true
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(c.header[:end])), nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols || !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") {
+		c.parsing = false
+	} else {
+		c.protocol = resp.Header.Get("Sec-Websocket-Protocol")
+	}
+	rest := data[end-previous:]
+	c.header = nil
+	return rest
+}
+
+// Warn writes message to stderr of the session, it returns false if the session is not a websocket
+// session of a channel protocol or the stream is not between frames in timeout
+func (c *warningConn) Warn(message string, timeout time.Duration) bool {
+	payload := append([]byte{stderrChannel}, message...)
+	opcode := byte(websocketBinary)
+	if strings.Contains(c.protocolOf(), "base64.channel.k8s.io") {
+		payload = append([]byte{'0' + stderrChannel}, base64.StdEncoding.EncodeToString([]byte(message))...)
+		opcode = websocketText
+	} else if !strings.Contains(c.protocolOf(), "channel.k8s.io") {
+		return false
+	}
+	return c.writeFrame(opcode, payload, timeout)
+}
+
+// CloseWithReason writes a going away close frame with reason before closing the connection
+func (c *warningConn) CloseWithReason(reason string, timeout time.Duration) error {
+	if len(c.protocolOf()) > 0 {
+		if len(reason) > maxWebsocketCloseReason {
+			reason = reason[:maxWebsocketCloseReason]
+		}
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, websocketGoingAway)
+		c.writeFrame(websocketClose, append(payload, reason...), timeout)
+	}
+	return c.Conn.Close()
+}
+
+func (c *warningConn) protocolOf() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protocol
+}
+
+// writeFrame writes an unmasked final frame when the stream is between frames
+func (c *warningConn) writeFrame(opcode byte, payload []byte, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		if !c.parsing {
+			c.mu.Unlock()
+			return false
+		}
+		if c.started && c.remaining == 0 && len(c.frameHeader) == 0 {
+			break
+		}
+		c.mu.Unlock()
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer c.mu.Unlock()
+
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 127)
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	c.Conn.SetWriteDeadline(deadline) //nolint
+	_, err := c.Conn.Write(append(frame, payload...))
+	c.Conn.SetWriteDeadline(time.Time{}) //nolint
+	return err == nil
+}
+
+// websocketFrameHeaderSize returns the size of frame header by its first bytes, false if they are
+// not enough to tell it
+func websocketFrameHeaderSize(header []byte) (int, bool) {
+	if len(header) < 2 {
+		return 0, false
+	}
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 {
+		// masking key
+		size += 4
+	}
+	return size, true
+}
+
+func websocketPayloadLength(header []byte) uint64 {
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		return uint64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return binary.BigEndian.Uint64(header[2:10])
+	default:
+		return uint64(length)
+	}
+}
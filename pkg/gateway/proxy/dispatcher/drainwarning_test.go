@@ -0,0 +1,128 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingConn records bytes written to client
+type recordingConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written bytes.Buffer
+	closed  bool
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written.Write(p)
+}
+
+func (c *recordingConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *recordingConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+func (c *recordingConn) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.written.Bytes()...)
+}
+
+func upgradeResponse(protocol string) string {
+	return "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Protocol: " + protocol + "\r\n\r\n"
+}
+
+func TestWarningConn_Warn(t *testing.T) {
+	stdout := []byte{0x82, 0x03, stdoutChannel, 'h', 'i'}
+	tests := []struct {
+		name     string
+		response string
+		want     []byte
+		wantOK   bool
+	}{
+		{
+			name:     "binary channel protocol",
+			response: upgradeResponse(remoteCommandProtocolV5),
+			want:     []byte{0x82, 0x06, stderrChannel, 'b', 'y', 'e', '\r', '\n'},
+			wantOK:   true,
+		},
+		{
+			name:     "base64 channel protocol",
+			response: upgradeResponse("v4.base64.channel.k8s.io"),
+			want:     append([]byte{0x81, 0x09, '2'}, base64.StdEncoding.EncodeToString([]byte("bye\r\n"))...),
+			wantOK:   true,
+		},
+		{
+			name:     "spdy",
+			response: "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n",
+			wantOK:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &recordingConn{}
+			conn := newWarningConn(client)
+			// the response header and frames are written in arbitrary chunks
+			data := append([]byte(tt.response), stdout...)
+			for _, chunk := range [][]byte{data[:10], data[10 : len(data)-4], data[len(data)-4 : len(data)-2]} {
+				conn.Write(chunk) //nolint
+			}
+
+			// the stream is in the middle of a frame
+			if conn.Warn("bye\r\n", 50*time.Millisecond) {
+				t.Fatalf("Warn() in the middle of a frame")
+			}
+			conn.Write(data[len(data)-2:]) //nolint
+			if got := conn.Warn("bye\r\n", 50*time.Millisecond); got != tt.wantOK {
+				t.Fatalf("Warn() = %v, want %v", got, tt.wantOK)
+			}
+			if got := client.bytes()[len(data):]; !bytes.Equal(got, tt.want) {
+				t.Errorf("warning = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarningConn_CloseWithReason(t *testing.T) {
+	client := &recordingConn{}
+	conn := newWarningConn(client)
+	conn.Write([]byte(upgradeResponse(remoteCommandProtocolV5))) //nolint
+	written := len(client.bytes())
+
+	if err := conn.CloseWithReason("terminating", 50*time.Millisecond); err != nil {
+		t.Fatalf("CloseWithReason() error = %v", err)
+	}
+	want := append([]byte{0x88, 0x0d, 0x03, 0xe9}, "terminating"...)
+	if got := client.bytes()[written:]; !bytes.Equal(got, want) {
+		t.Errorf("close frame = %v, want %v", got, want)
+	}
+	if !client.closed {
+		t.Errorf("connection is not closed")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/reverseproxy"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+// newEndpointProxyHandler creates a proxy handler to endpoint, errors of proxied requests are recorded
+// by metrics of the endpoint's cluster and trigger health checks of the endpoint if they look fatal.
+func newEndpointProxyHandler(location *url.URL, transport http.RoundTripper, upgradeTransport proxy.UpgradeRequestRoundTripper, responder reverseproxy.ErrorResponder, endpoint *clusters.EndpointInfo) *reverseproxy.Handler {
+	handler := reverseproxy.New(location, transport, upgradeTransport, responder)
+	hooks := endpointProxyHooks{endpoint: endpoint, host: handler.Location.Host}
+	handler.Hooks = reverseproxy.Hooks{
+		OnError:           hooks.onError,
+		OnAbortedResponse: hooks.onAbortedResponse,
+		ErrorLogf:         hooks.errorLogf,
+	}
+	return handler
+}
+
+type endpointProxyHooks struct {
+	endpoint *clusters.EndpointInfo
+	host     string
+}
+
+func (e endpointProxyHooks) onError(req *http.Request, err error, class errclass.Class) {
+	reason := abortReason(req, err, e.endpoint)
+	metrics.RecordUpstreamAborted(e.endpoint.Cluster, reason)
+	recordStreamReset(req, err, reason, e.endpoint.Cluster, e.host)
+
+	if class.TriggersHealthCheck() {
+		e.errorLogf(class, "%v err: %v, trigger healthcheck", class, redact.Error(err))
+		e.endpoint.TriggerHealthCheck()
+	}
+}
+
+func (e endpointProxyHooks) onAbortedResponse(_ *http.Request, class errclass.Class) {
+	metrics.RecordAbortedResponse(e.endpoint.Cluster, string(class))
+}
+
+// errorLogf writes an error log sampled by cluster and error class, so a flapping endpoint
+// produces bounded log volume instead of flooding logs with identical errors.
+func (e endpointProxyHooks) errorLogf(class errclass.Class, format string, args ...interface{}) {
+	logged, suppressed := e.endpoint.SampleErrorLog(string(class))
+	if !logged {
+		return
+	}
+	if suppressed > 0 {
+		format += ", %d similar errors suppressed"
+		args = append(args, suppressed)
+	}
+	klog.ErrorDepth(2, fmt.Sprintf(format, args...))
+}
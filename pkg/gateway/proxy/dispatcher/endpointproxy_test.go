@@ -0,0 +1,96 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type countingResponder struct {
+	calls int32
+}
+
+func (r *countingResponder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	atomic.AddInt32(&r.calls, 1)
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func TestEndpointProxyHandler_upstreamFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		// upstream writes the response header and some bytes before failing
+		partial       bool
+		wantResponder bool
+		wantCode      int
+		wantBodyErr   bool
+	}{
+		{name: "fails before response header", partial: false, wantResponder: true, wantCode: http.StatusBadGateway},
+		{name: "fails mid-stream", partial: true, wantResponder: false, wantCode: http.StatusOK, wantBodyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.partial {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"kind":"PodList","items":[`)) // nolint
+					w.(http.Flusher).Flush()
+				}
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("failed to hijack: %v", err)
+					return
+				}
+				conn.Close()
+			}))
+			defer upstream.Close()
+
+			location, _ := url.Parse(upstream.URL)
+			responder := &countingResponder{}
+			endpoint := &clusters.EndpointInfo{Cluster: "test", Endpoint: upstream.URL}
+			gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handler := newEndpointProxyHandler(location, http.DefaultTransport, nil, responder, endpoint)
+				// streams are flushed immediately, so the response header reaches client before the failure
+				handler.Streaming, handler.FlushInterval = true, 0
+				handler.ServeHTTP(w, r)
+			}))
+			defer gateway.Close()
+
+			resp, err := http.Get(gateway.URL + "/api/v1/pods")
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			_, bodyErr := ioutil.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if (bodyErr != nil) != tt.wantBodyErr {
+				t.Errorf("read body error = %v, want error %v", bodyErr, tt.wantBodyErr)
+			}
+			if got := atomic.LoadInt32(&responder.calls) > 0; got != tt.wantResponder {
+				t.Errorf("responder called = %v, want %v", got, tt.wantResponder)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// RateLimitExemption lists system-critical users and groups whose requests bypass
+// gateway flow control and cluster resource budget.
+type RateLimitExemption struct {
+	Users  sets.String
+	Groups sets.String
+}
+
+// Exempt returns true if requests from u are exempted from rate limiting
+func (e *RateLimitExemption) Exempt(u user.Info) bool {
+	if e == nil {
+		return false
+	}
+	return matchUser(u, e.Users, e.Groups)
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestRateLimitExemption_Exempt(t *testing.T) {
+	exemption := &RateLimitExemption{
+		Users:  sets.NewString("system:serviceaccount:kube-system:cluster-autoscaler"),
+		Groups: sets.NewString(user.SystemPrivilegedGroup),
+	}
+	tests := []struct {
+		name      string
+		exemption *RateLimitExemption
+		user      user.Info
+		want      bool
+	}{
+		{"nil exemption", nil, &user.DefaultInfo{Name: "admin", Groups: []string{user.SystemPrivilegedGroup}}, false},
+		{"nil user", exemption, nil, false},
+		{"exempted user", exemption, &user.DefaultInfo{Name: "system:serviceaccount:kube-system:cluster-autoscaler"}, true},
+		{"exempted group", exemption, &user.DefaultInfo{Name: "admin", Groups: []string{user.AllAuthenticated, user.SystemPrivilegedGroup}}, true},
+		{"not exempted", exemption, &user.DefaultInfo{Name: "bob", Groups: []string{user.AllAuthenticated}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.exemption.Exempt(tt.user); got != tt.want {
+				t.Errorf("Exempt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
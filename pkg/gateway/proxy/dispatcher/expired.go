@@ -0,0 +1,139 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"strings"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	expiredResourceVersionWarning   = `299 - "the requested resourceVersion is too old, clients should relist with resourceVersion=\"\" or \"0\" instead of retrying it"`
+	expiredContinueWarning          = `299 - "the continue token is expired, clients should restart the list without continue"`
+	rewrittenResourceVersionWarning = `299 - "the requested resourceVersion is too old, the list is served from the latest state by kube-gateway"`
+)
+
+// ExpiredResourceVersionPolicy guides clients whose lists are rejected by upstream with 410 Gone because the
+// requested resourceVersion or continue token is compacted, which causes relist storms if clients keep
+// retrying it. Responses are annotated with Warning headers and counted per client.
+type ExpiredResourceVersionPolicy struct {
+	// Rewrite retries retry-safe lists once without resourceVersion, so they are served from the
+	// latest state which is never older than the requested one.
+	Rewrite bool
+}
+
+// expiredResourceVersionRoundTripper intercepts 410 Gone responses of list requests
+type expiredResourceVersionRoundTripper struct {
+	rt        http.RoundTripper
+	policy    *ExpiredResourceVersionPolicy
+	cluster   string
+	resource  string
+	user      string
+	userAgent string
+}
+
+var _ utilnet.RoundTripperWrapper = &expiredResourceVersionRoundTripper{}
+
+func newExpiredResourceVersionRoundTripper(rt http.RoundTripper, policy *ExpiredResourceVersionPolicy, cluster, resource, user, userAgent string) http.RoundTripper {
+	return &expiredResourceVersionRoundTripper{
+		rt:        rt,
+		policy:    policy,
+		cluster:   cluster,
+		resource:  resource,
+		user:      user,
+		userAgent: userAgent,
+	}
+}
+
+func (rt *expiredResourceVersionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusGone {
+		return resp, err
+	}
+
+	query := req.URL.Query()
+	client := userAgentComponent(rt.userAgent)
+	continued := len(query.Get("continue")) > 0
+	metrics.RecordExpiredResourceVersion(rt.cluster, rt.resource, client)
+	klog.V(2).Infof("[expired resource version] cluster=%q resource=%v user=%q userAgent=%q resourceVersion=%q continue=%v",
+		rt.cluster, rt.resource, rt.user, rt.userAgent, query.Get("resourceVersion"), continued)
+
+	if continued {
+		resp.Header.Add("Warning", expiredContinueWarning)
+		return resp, nil
+	}
+	if rt.policy.Rewrite {
+		if next, ok := withoutResourceVersion(req); ok {
+			retried, err := rt.rt.RoundTrip(next)
+			if err == nil {
+				drainAndClose(resp)
+				metrics.RecordRewrittenResourceVersion(rt.cluster, rt.resource, client)
+				retried.Header.Add("Warning", rewrittenResourceVersionWarning)
+				return retried, nil
+			}
+			klog.V(2).Infof("[expired resource version] cluster=%q failed to list %v without resourceVersion: %v", rt.cluster, rt.resource, redact.Error(err))
+		}
+	}
+	resp.Header.Add("Warning", expiredResourceVersionWarning)
+	return resp, nil
+}
+
+func (rt *expiredResourceVersionRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+// withoutResourceVersion returns a copy of list request without resourceVersion if it is retry-safe,
+// lists which require the exact resourceVersion are not.
+func withoutResourceVersion(req *http.Request) (*http.Request, bool) {
+	if req.Method != http.MethodGet {
+		return nil, false
+	}
+	query := req.URL.Query()
+	switch query.Get("resourceVersionMatch") {
+	case "", "NotOlderThan":
+	default:
+		return nil, false
+	}
+	if len(query.Get("resourceVersion")) == 0 {
+		return nil, false
+	}
+	query.Del("resourceVersion")
+	query.Del("resourceVersionMatch")
+
+	// WithContext creates a shallow clone of the request with the same context.
+	next := req.WithContext(req.Context())
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	next.URL = &u
+	next.Body = http.NoBody
+	return next, true
+}
+
+// userAgentComponent returns the component name of normalized User-Agent, e.g. kubectl, version is
+// dropped to bound cardinality of metrics.
+func userAgentComponent(userAgent string) string {
+	component := strings.SplitN(clusters.NormalizeUserAgent(userAgent), "/", 2)[0]
+	if len(component) == 0 {
+		return "unknown"
+	}
+	return component
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compactedRoundTripper responds 410 Gone to lists with resourceVersion or continue
+type compactedRoundTripper struct {
+	queries []string
+}
+
+func (rt *compactedRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.queries = append(rt.queries, req.URL.RawQuery)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+	query := req.URL.Query()
+	if len(query.Get("resourceVersion")) > 0 || len(query.Get("continue")) > 0 {
+		resp.StatusCode = http.StatusGone
+	}
+	return resp, nil
+}
+
+func Test_expiredResourceVersionRoundTripper(t *testing.T) {
+	tests := []struct {
+		name        string
+		rewrite     bool
+		query       string
+		wantCode    int
+		wantWarning string
+		wantCalls   int
+	}{
+		{"not expired", false, "limit=500", http.StatusOK, "", 1},
+		{"warning", false, "resourceVersion=100", http.StatusGone, expiredResourceVersionWarning, 1},
+		{"expired continue", true, "limit=500&continue=abc", http.StatusGone, expiredContinueWarning, 1},
+		{"rewrite", true, "resourceVersion=100&limit=500", http.StatusOK, rewrittenResourceVersionWarning, 2},
+		{"rewrite not older than", true, "resourceVersion=100&resourceVersionMatch=NotOlderThan", http.StatusOK, rewrittenResourceVersionWarning, 2},
+		{"exact is not rewritten", true, "resourceVersion=100&resourceVersionMatch=Exact", http.StatusGone, expiredResourceVersionWarning, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &compactedRoundTripper{}
+			rt := newExpiredResourceVersionRoundTripper(upstream, &ExpiredResourceVersionPolicy{Rewrite: tt.rewrite}, "test", "pods", "alice", "my-operator/v0.18.3 (linux/amd64)")
+			req := httptest.NewRequest(http.MethodGet, "https://10.0.0.1:6443/api/v1/pods?"+tt.query, nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("RoundTrip() code = %v, want %v", resp.StatusCode, tt.wantCode)
+			}
+			if got := resp.Header.Get("Warning"); got != tt.wantWarning {
+				t.Errorf("RoundTrip() warning = %v, want %v", got, tt.wantWarning)
+			}
+			if len(upstream.queries) != tt.wantCalls {
+				t.Errorf("RoundTrip() upstream calls = %v, want %v", upstream.queries, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_userAgentComponent(t *testing.T) {
+	if got := userAgentComponent("kubectl/v1.18.19 (linux/amd64) kubernetes/f0d9e4c"); got != "kubectl" {
+		t.Errorf("userAgentComponent() = %v, want kubectl", got)
+	}
+	if got := userAgentComponent(""); got != "unknown" {
+		t.Errorf("userAgentComponent() = %v, want unknown", got)
+	}
+}
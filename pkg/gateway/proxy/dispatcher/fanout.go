@@ -0,0 +1,498 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apiserver/pkg/authorization/authorizer"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+)
+
+const (
+	// FleetClusterAnnotationKey is added to every item in a fleet response to
+	// indicate which upstream cluster the item comes from
+	FleetClusterAnnotationKey = "proxy.kubegateway.io/cluster"
+
+	// fleetEndpoint is used as endpoint label in logs and metrics for fleet requests
+	fleetEndpoint = "fleet"
+)
+
+// FleetRoute describes a virtual host whose read requests are fanned out to
+// a group of upstream clusters concurrently and merged into one response.
+type FleetRoute struct {
+	// Hostname is the virtual hostname clients use to reach the fleet
+	Hostname string
+	// Clusters are the names of member upstream clusters
+	Clusters []string
+	// Timeout bounds the time waiting for all member clusters
+	Timeout time.Duration
+}
+
+// FleetFailure reports a member cluster which failed to serve a fleet request
+type FleetFailure struct {
+	Cluster string              `json:"cluster"`
+	Code    int32               `json:"code"`
+	Reason  metav1.StatusReason `json:"reason,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// fleetList is the merged response of a fleet request, it is a normal list object
+// with an extra failures field.
+type fleetList struct {
+	Kind       string                   `json:"kind"`
+	APIVersion string                   `json:"apiVersion"`
+	Metadata   metav1.ListMeta          `json:"metadata"`
+	Items      []map[string]interface{} `json:"items"`
+	Failures   []FleetFailure           `json:"failures,omitempty"`
+}
+
+type fleetMemberResult struct {
+	cluster string
+	object  map[string]interface{}
+	status  *metav1.Status
+	// enableLog is true if the dispatch policy matched in member cluster enables access log
+	enableLog bool
+}
+
+func (d *dispatcher) isFleetRequest(hostname string) bool {
+	return d.fleet != nil && strings.EqualFold(hostname, d.fleet.Hostname)
+}
+
+// serveFleet fans out the read request to all member clusters and merges their responses.
+// Items are annotated by FleetClusterAnnotationKey and partial failures are reported in
+// the failures field and Warning headers. A get request is responded with the object itself,
+// see mergeFleetObject.
+func (d *dispatcher) serveFleet(w http.ResponseWriter, req *http.Request, requestInfo *genericapirequest.RequestInfo, extraInfo *request.ExtraRequestInfo) {
+	if !requestInfo.IsResourceRequest || (requestInfo.Verb != "get" && requestInfo.Verb != "list") {
+		gr := schema.GroupResource{Group: requestInfo.APIGroup, Resource: requestInfo.Resource}
+		d.responseError(errors.NewMethodNotSupported(gr, requestInfo.Verb), w, req, statusReasonFleetUnsupportedRequest)
+		return
+	}
+	if len(req.URL.Query().Get("continue")) > 0 {
+		// lists of members are drained and merged, so fleet never responds a continue token
+		d.responseError(errors.NewBadRequest("continue is not supported by fleet, lists of all members are complete"), w, req, statusReasonFleetUnsupportedRequest)
+		return
+	}
+	requestAttributes, err := filters.GetAuthorizerAttributes(req.Context())
+	if err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
+		return
+	}
+
+	if err := request.SetUpstreamCluster(req.Context(), extraInfo.Hostname); err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
+		return
+	}
+
+	// mark this proxy request forwarded
+	if err := request.SetProxyForwarded(req.Context(), fleetEndpoint); err != nil {
+		d.responseError(errors.NewInternalError(err), w, req, statusReasonInvalidRequestContext)
+		return
+	}
+
+	user, _ := genericapirequest.UserFrom(req.Context())
+	delegate := decorateResponseWriter(req, w, d.enableAccessLog, requestInfo, extraInfo.Hostname, fleetEndpoint, user, extraInfo.Impersonator)
+	delegate.MonitorBeforeProxy()
+	defer delegate.MonitorAfterProxy()
+
+	ctx, cancel := context.WithTimeout(req.Context(), d.fleet.Timeout)
+	defer cancel()
+
+	results := make([]fleetMemberResult, len(d.fleet.Clusters))
+	var wg sync.WaitGroup
+	for i := range d.fleet.Clusters {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = d.fetchFleetMember(ctx, d.fleet.Clusters[i], req, requestAttributes)
+		}(i)
+	}
+	wg.Wait()
+	// the request is logged if any member cluster logs it, like a normal dispatch to that cluster
+	for _, r := range results {
+		if r.enableLog {
+			delegate.logging = d.enableAccessLog
+			break
+		}
+	}
+
+	var merged interface{}
+	var warnings []FleetFailure
+	var statusErr *errors.StatusError
+	if requestInfo.Verb == "get" {
+		merged, warnings, statusErr = mergeFleetObject(d.fleet.Hostname, results)
+	} else {
+		var list *fleetList
+		if list, statusErr = mergeFleetResults(d.fleet.Hostname, results); list != nil {
+			merged, warnings = list, list.Failures
+		}
+	}
+	if statusErr != nil {
+		d.responseError(statusErr, delegate, req, statusReasonFleetAllMembersFailed)
+		return
+	}
+	for _, f := range warnings {
+		delegate.Header().Add("Warning", "299 - "+strconv.Quote(fmt.Sprintf("cluster %s: %s", f.Cluster, f.Message)))
+	}
+
+	data, err := json.Marshal(merged)
+	if err != nil {
+		d.responseError(errors.NewInternalError(err), delegate, req, statusReasonInvalidRequestContext)
+		return
+	}
+	delegate.Header().Set("Content-Type", "application/json")
+	delegate.WriteHeader(http.StatusOK)
+	delegate.Write(data) //nolint
+}
+
+// fetchFleetMember sends the request to one ready endpoint of member cluster, the endpoint is picked
+// by the cluster's dispatch policies and the request is limited by related flow control.
+func (d *dispatcher) fetchFleetMember(ctx context.Context, clusterName string, req *http.Request, requestAttributes authorizer.Attributes) fleetMemberResult {
+	result := fleetMemberResult{cluster: clusterName}
+	failed := func(err *errors.StatusError) fleetMemberResult {
+		status := err.Status()
+		result.status = &status
+		return result
+	}
+
+	// members are admitted by the same checks as requests sent to them directly, a rejection only
+	// fails the member
+	cluster, ok := d.Resolve(clusterName)
+	if !ok {
+		return failed(errors.NewServiceUnavailable(fmt.Sprintf("the request cluster(%s) is not being proxied", clusterName)))
+	}
+	query := req.URL.Query()
+	if vc, ok := clusters.LookupVirtualCluster(clusterName); ok {
+		if err := vc.Authorize(requestAttributes); err != nil {
+			gr := schema.GroupResource{Group: requestAttributes.GetAPIGroup(), Resource: requestAttributes.GetResource()}
+			return failed(errors.NewForbidden(gr, requestAttributes.GetName(), err))
+		}
+		if err := vc.InjectSelectors(requestAttributes.GetVerb(), query); err != nil {
+			return failed(errors.NewBadRequest(err.Error()))
+		}
+	}
+	if cluster.FeatureEnabled(features.DenyAllRequests) {
+		message := fmt.Sprintf("request for %v denied by featureGate(DenyAllRequests)", clusterName)
+		if !cluster.IsAuditOnly(clusters.AuditOnlyDenyAllRequests) {
+			return failed(errors.NewServiceUnavailable(message))
+		}
+		recordAuditOnlyRejection(req, clusterName, clusters.AuditOnlyDenyAllRequests, message)
+	}
+	if fault := cluster.PickFault(); fault != nil {
+		if err := injectFleetFault(ctx, clusterName, fault); err != nil {
+			return failed(err)
+		}
+	}
+	if cluster.AuthMode() == clusters.AuthModePassthrough {
+		if _, ok := request.RawAuthorizationFrom(ctx); !ok {
+			return failed(errors.NewUnauthorized(fmt.Sprintf("cluster(%s) only accepts bearer token credentials which are passed through to upstream", clusterName)))
+		}
+	}
+	if !cluster.TryAcceptQPSCeiling() {
+		return failed(errors.NewTooManyRequests(fmt.Sprintf("too many requests for cluster(%s), limited by ceiling(maxQPS=%d)", clusterName, cluster.ClusterCeilings().MaxQPS), retryAfter))
+	}
+	exempt := d.exemption.Exempt(requestAttributes.GetUser())
+	if exempt {
+		metrics.RecordExemptedRequest(clusterName)
+	} else {
+		if !cluster.TryAcquireRequestBudget() {
+			return failed(errors.NewTooManyRequests(fmt.Sprintf("too many inflight requests for cluster(%s), limited by resource budget(maxInflightRequests=%d)", clusterName, cluster.ResourceBudget().MaxInflightRequests), retryAfter))
+		}
+		defer cluster.ReleaseRequestBudget()
+	}
+
+	endpointPicker, err := cluster.MatchRequest(requestAttributes, req.UserAgent())
+	var denied *clusters.ExpressionDeniedError
+	if goerrors.As(err, &denied) {
+		return failed(errors.NewForbidden(schema.GroupResource{Group: requestAttributes.GetAPIGroup(), Resource: requestAttributes.GetResource()}, requestAttributes.GetName(), denied))
+	}
+	if err != nil {
+		return failed(errors.NewInternalError(err))
+	}
+	result.enableLog = endpointPicker.EnableLog()
+	if !exempt {
+		flowcontrol := endpointPicker.FlowControl()
+		seats := 1
+		if info, ok := genericapirequest.RequestInfoFrom(req.Context()); ok {
+			seats = d.cost.Seats(clusterName, req, info)
+		}
+		flow := gatewayflowcontrol.Flow{Schema: endpointPicker.FlowSchema(), Distinguisher: requestAttributes.GetUser().GetName()}
+		seats, acquired := gatewayflowcontrol.TryAcquireFlow(flowcontrol, flow, seats)
+		if !acquired {
+			return failed(errors.NewTooManyRequests(fmt.Sprintf("too many requests for cluster(%s), limited by flowControl(%v)", clusterName, flowcontrol.String()), retryAfter))
+		}
+		defer gatewayflowcontrol.ReleaseFlow(flowcontrol, flow, seats)
+	}
+
+	endpoint, err := endpointPicker.Pop()
+	if err != nil {
+		return failed(errors.NewServiceUnavailable(err.Error()))
+	}
+	ep, err := url.Parse(endpoint.Endpoint)
+	if err != nil {
+		return failed(errors.NewInternalError(err))
+	}
+
+	location := &url.URL{
+		Scheme:   ep.Scheme,
+		Host:     ep.Host,
+		Path:     req.URL.Path,
+		RawQuery: query.Encode(),
+	}
+	object, status := fetchFleetPage(ctx, cluster, endpoint, req, location)
+	// limit is the page size of every member, all pages are fetched because the merged list can not carry
+	// continue tokens of several members
+	for status == nil && requestAttributes.GetVerb() == "list" {
+		metadata, _ := object["metadata"].(map[string]interface{})
+		token, _ := metadata["continue"].(string)
+		if len(token) == 0 {
+			break
+		}
+		// resourceVersion is not allowed with continue, the token carries it
+		query.Del("resourceVersion")
+		query.Del("resourceVersionMatch")
+		query.Set("continue", token)
+		location.RawQuery = query.Encode()
+		var page map[string]interface{}
+		if page, status = fetchFleetPage(ctx, cluster, endpoint, req, location); status != nil {
+			break
+		}
+		items, _ := object["items"].([]interface{})
+		pageItems, _ := page["items"].([]interface{})
+		object["items"] = append(items, pageItems...)
+		object["metadata"] = page["metadata"]
+	}
+	if status != nil {
+		result.status = status
+		return result
+	}
+	result.object = object
+	return result
+}
+
+// fetchFleetPage sends a get request of location to endpoint of member cluster, it returns the decoded
+// object or the failure status.
+func fetchFleetPage(ctx context.Context, cluster *clusters.ClusterInfo, endpoint *clusters.EndpointInfo, req *http.Request, location *url.URL) (map[string]interface{}, *metav1.Status) {
+	failed := func(err *errors.StatusError) (map[string]interface{}, *metav1.Status) {
+		status := err.Status()
+		return nil, &status
+	}
+	newReq, err := http.NewRequest(http.MethodGet, location.String(), nil)
+	if err != nil {
+		return failed(errors.NewInternalError(err))
+	}
+	// fleet requests are reads without body today, throttle it anyway so the bandwidth ceiling
+	// keeps applying if members ever receive bodies
+	newReq.Body = cluster.ThrottleRequestBody(ctx, newReq.Body)
+	// the context carries user info, it will be used by impersonating transport
+	newReq = newReq.WithContext(ctx)
+	newReq.Header = utilnet.CloneHeader(req.Header)
+	// we need to decode and merge responses, so force json and let transport
+	// handle compression itself
+	newReq.Header.Set("Accept", "application/json")
+	newReq.Header.Del("Accept-Encoding")
+
+	resp, err := endpoint.ProxyTransport.RoundTrip(newReq)
+	if err != nil {
+		if errclass.ClassifyProxyError(err).TriggersHealthCheck() {
+			endpoint.TriggerHealthCheck()
+		}
+		return failed(&errors.StatusError{ErrStatus: *errorToProxyStatus(err)})
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return failed(&errors.StatusError{ErrStatus: *errorToProxyStatus(err)})
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		status := metav1.Status{}
+		if err := json.Unmarshal(body, &status); err != nil || status.Kind != "Status" {
+			status = metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    int32(resp.StatusCode),
+				Message: strings.TrimSpace(string(body)),
+			}
+		}
+		if status.Code == 0 {
+			status.Code = int32(resp.StatusCode)
+		}
+		return nil, &status
+	}
+
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return failed(errors.NewInternalError(fmt.Errorf("failed to decode response from endpoint %s: %v", endpoint.Endpoint, err)))
+	}
+	return object, nil
+}
+
+// mergeFleetResults merges member responses into one list. It returns error only if all members failed.
+func mergeFleetResults(hostname string, results []fleetMemberResult) (*fleetList, *errors.StatusError) {
+	merged := &fleetList{
+		Items: []map[string]interface{}{},
+	}
+	var kind, apiVersion string
+	consistent := true
+
+	for _, r := range results {
+		if r.status != nil {
+			merged.Failures = append(merged.Failures, FleetFailure{
+				Cluster: r.cluster,
+				Code:    r.status.Code,
+				Reason:  r.status.Reason,
+				Message: r.status.Message,
+			})
+			continue
+		}
+
+		objKind, _ := r.object["kind"].(string)
+		objAPIVersion, _ := r.object["apiVersion"].(string)
+
+		var items []interface{}
+		if rawItems, isList := r.object["items"]; isList && strings.HasSuffix(objKind, "List") {
+			items, _ = rawItems.([]interface{})
+		} else {
+			// single object
+			items = []interface{}{r.object}
+			objKind += "List"
+		}
+
+		if len(kind) == 0 && len(apiVersion) == 0 {
+			kind, apiVersion = objKind, objAPIVersion
+		} else if kind != objKind || apiVersion != objAPIVersion {
+			consistent = false
+		}
+
+		for _, i := range items {
+			item, ok := i.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			annotateFleetItem(item, r.cluster)
+			merged.Items = append(merged.Items, item)
+		}
+	}
+
+	if len(results) > 0 && len(merged.Failures) == len(results) {
+		return nil, fleetFailuresToStatusError(hostname, merged.Failures)
+	}
+
+	merged.Kind, merged.APIVersion = kind, apiVersion
+	if !consistent || len(kind) == 0 {
+		merged.Kind, merged.APIVersion = "List", "v1"
+	}
+	return merged, nil
+}
+
+// mergeFleetObject returns the object of a get request. A named object usually exists in only one member,
+// so members which respond NotFound are not reported. If more than one member has the object, the one of
+// the first member in FleetRoute.Clusters is returned and others are reported as warnings. It returns error
+// only if no member has the object.
+func mergeFleetObject(hostname string, results []fleetMemberResult) (map[string]interface{}, []FleetFailure, *errors.StatusError) {
+	var object map[string]interface{}
+	var owner string
+	var failures, warnings []FleetFailure
+	for _, r := range results {
+		if r.status != nil {
+			failure := FleetFailure{
+				Cluster: r.cluster,
+				Code:    r.status.Code,
+				Reason:  r.status.Reason,
+				Message: r.status.Message,
+			}
+			failures = append(failures, failure)
+			if r.status.Reason != metav1.StatusReasonNotFound {
+				warnings = append(warnings, failure)
+			}
+			continue
+		}
+		if object != nil {
+			warnings = append(warnings, FleetFailure{
+				Cluster: r.cluster,
+				Code:    http.StatusConflict,
+				Reason:  metav1.StatusReasonConflict,
+				Message: fmt.Sprintf("the object also exists in this cluster, the one in cluster %s is returned", owner),
+			})
+			continue
+		}
+		object, owner = r.object, r.cluster
+		annotateFleetItem(object, r.cluster)
+	}
+	if object == nil {
+		if len(failures) == 0 {
+			return nil, nil, errors.NewServiceUnavailable(fmt.Sprintf("fleet(%s) has no member cluster", hostname))
+		}
+		return nil, nil, fleetFailuresToStatusError(hostname, failures)
+	}
+	return object, warnings, nil
+}
+
+func annotateFleetItem(item map[string]interface{}, cluster string) {
+	metadata, ok := item["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		item["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = map[string]interface{}{}
+		metadata["annotations"] = annotations
+	}
+	annotations[FleetClusterAnnotationKey] = cluster
+}
+
+func fleetFailuresToStatusError(hostname string, failures []FleetFailure) *errors.StatusError {
+	code := failures[0].Code
+	reason := failures[0].Reason
+	messages := make([]string, 0, len(failures))
+	for _, f := range failures {
+		if f.Code != code {
+			code = http.StatusServiceUnavailable
+			reason = metav1.StatusReasonServiceUnavailable
+		}
+		messages = append(messages, fmt.Sprintf("cluster %s: %s", f.Cluster, f.Message))
+	}
+	return &errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    code,
+		Reason:  reason,
+		Message: fmt.Sprintf("all member clusters of fleet(%s) failed: [%s]", hostname, strings.Join(messages, ", ")),
+	}}
+}
@@ -0,0 +1,349 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/testing/fakeupstream"
+)
+
+func newTestPodList(names ...string) map[string]interface{} {
+	items := []interface{}{}
+	for _, name := range names {
+		items = append(items, map[string]interface{}{
+			"metadata": map[string]interface{}{"name": name},
+		})
+	}
+	return map[string]interface{}{
+		"kind":       "PodList",
+		"apiVersion": "v1",
+		"items":      items,
+	}
+}
+
+func Test_mergeFleetResults(t *testing.T) {
+	notFound := &metav1.Status{Code: http.StatusNotFound, Reason: metav1.StatusReasonNotFound, Message: "not found"}
+	unavailable := &metav1.Status{Code: http.StatusServiceUnavailable, Message: "no ready endpoints"}
+
+	tests := []struct {
+		name         string
+		results      []fleetMemberResult
+		wantKind     string
+		wantItems    int
+		wantFailures int
+		wantErrCode  int32
+	}{
+		{
+			"merge lists",
+			[]fleetMemberResult{
+				{cluster: "a", object: newTestPodList("a1", "a2")},
+				{cluster: "b", object: newTestPodList("b1")},
+			},
+			"PodList",
+			3,
+			0,
+			0,
+		},
+		{
+			"single objects",
+			[]fleetMemberResult{
+				{cluster: "a", object: map[string]interface{}{"kind": "Pod", "apiVersion": "v1"}},
+				{cluster: "b", object: map[string]interface{}{"kind": "Pod", "apiVersion": "v1"}},
+			},
+			"PodList",
+			2,
+			0,
+			0,
+		},
+		{
+			"inconsistent kinds",
+			[]fleetMemberResult{
+				{cluster: "a", object: newTestPodList("a1")},
+				{cluster: "b", object: map[string]interface{}{"kind": "Pod", "apiVersion": "v2"}},
+			},
+			"List",
+			2,
+			0,
+			0,
+		},
+		{
+			"partial failure",
+			[]fleetMemberResult{
+				{cluster: "a", object: newTestPodList("a1")},
+				{cluster: "b", status: unavailable},
+			},
+			"PodList",
+			1,
+			1,
+			0,
+		},
+		{
+			"all failed with same code",
+			[]fleetMemberResult{
+				{cluster: "a", status: notFound},
+				{cluster: "b", status: notFound},
+			},
+			"",
+			0,
+			0,
+			http.StatusNotFound,
+		},
+		{
+			"all failed with different codes",
+			[]fleetMemberResult{
+				{cluster: "a", status: notFound},
+				{cluster: "b", status: unavailable},
+			},
+			"",
+			0,
+			0,
+			http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeFleetResults("fleet.gateway", tt.results)
+			if tt.wantErrCode != 0 {
+				if err == nil || err.Status().Code != tt.wantErrCode {
+					t.Fatalf("mergeFleetResults() error = %v, want code %v", err, tt.wantErrCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeFleetResults() unexpected error = %v", err)
+			}
+			if got.Kind != tt.wantKind {
+				t.Errorf("mergeFleetResults() kind = %v, want %v", got.Kind, tt.wantKind)
+			}
+			if len(got.Items) != tt.wantItems {
+				t.Errorf("mergeFleetResults() items = %v, want %v", len(got.Items), tt.wantItems)
+			}
+			if len(got.Failures) != tt.wantFailures {
+				t.Errorf("mergeFleetResults() failures = %v, want %v", len(got.Failures), tt.wantFailures)
+			}
+			for _, item := range got.Items {
+				annotations := item["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+				if _, ok := annotations[FleetClusterAnnotationKey]; !ok {
+					t.Errorf("mergeFleetResults() item %v is not annotated with cluster", item)
+				}
+			}
+		})
+	}
+}
+
+func Test_mergeFleetObject(t *testing.T) {
+	notFound := &metav1.Status{Code: http.StatusNotFound, Reason: metav1.StatusReasonNotFound, Message: "not found"}
+	unavailable := &metav1.Status{Code: http.StatusServiceUnavailable, Message: "no ready endpoints"}
+	newPod := func() map[string]interface{} {
+		return map[string]interface{}{"kind": "Pod", "apiVersion": "v1", "metadata": map[string]interface{}{"name": "a"}}
+	}
+
+	tests := []struct {
+		name         string
+		results      []fleetMemberResult
+		wantCluster  string
+		wantWarnings int
+		wantErrCode  int32
+	}{
+		{
+			"found in one member",
+			[]fleetMemberResult{{cluster: "a", status: notFound}, {cluster: "b", object: newPod()}},
+			"b",
+			0,
+			0,
+		},
+		{
+			"found in several members",
+			[]fleetMemberResult{{cluster: "a", object: newPod()}, {cluster: "b", object: newPod()}},
+			"a",
+			1,
+			0,
+		},
+		{
+			"partial failure",
+			[]fleetMemberResult{{cluster: "a", object: newPod()}, {cluster: "b", status: unavailable}},
+			"a",
+			1,
+			0,
+		},
+		{
+			"not found",
+			[]fleetMemberResult{{cluster: "a", status: notFound}, {cluster: "b", status: notFound}},
+			"",
+			0,
+			http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings, err := mergeFleetObject("fleet.gateway", tt.results)
+			if tt.wantErrCode != 0 {
+				if err == nil || err.Status().Code != tt.wantErrCode {
+					t.Fatalf("mergeFleetObject() error = %v, want code %v", err, tt.wantErrCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeFleetObject() unexpected error = %v", err)
+			}
+			if got["kind"] != "Pod" {
+				t.Errorf("mergeFleetObject() kind = %v, want Pod", got["kind"])
+			}
+			annotations := got["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+			if annotations[FleetClusterAnnotationKey] != tt.wantCluster {
+				t.Errorf("mergeFleetObject() cluster = %v, want %v", annotations[FleetClusterAnnotationKey], tt.wantCluster)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("mergeFleetObject() warnings = %v, want %v", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestDispatcher_serveFleet(t *testing.T) {
+	upstream := fakeupstream.NewServer()
+	defer upstream.Close()
+	upstream.AddObject(fakePods, fakeupstream.Object{
+		"kind":       "Pod",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{"name": "a", "namespace": "default"},
+	})
+	manager := newFakeUpstreamManager(t, "example.com", upstream)
+	defer manager.DeleteAll()
+	d := NewDispatcher(manager, Config{Fleet: &FleetRoute{Hostname: "fleet.gateway", Clusters: []string{"example.com"}, Timeout: 10 * time.Second}})
+
+	tests := []struct {
+		name        string
+		requestInfo *genericapirequest.RequestInfo
+		wantKind    string
+	}{
+		{
+			name:        "list",
+			requestInfo: &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods", Path: fakePods},
+			wantKind:    "PodList",
+		},
+		{
+			name:        "get",
+			requestInfo: &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "a", Path: fakePods + "/a"},
+			wantKind:    "Pod",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			d.ServeHTTP(recorder, newFakeUpstreamRequest("fleet.gateway", tt.requestInfo))
+
+			obj := fakeupstream.Object{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &obj); err != nil {
+				t.Fatalf("failed to decode response %q: %v", recorder.Body.String(), err)
+			}
+			if recorder.Code != http.StatusOK || obj["kind"] != tt.wantKind {
+				t.Errorf("response = %d %v, want %d %v", recorder.Code, obj["kind"], http.StatusOK, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestDispatcher_serveFleet_memberRejected(t *testing.T) {
+	upstream := fakeupstream.NewServer()
+	defer upstream.Close()
+	upstream.AddObject(fakePods, fakeupstream.Object{
+		"kind":       "Pod",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{"name": "a", "namespace": "default"},
+	})
+	manager := newFakeUpstreamManager(t, "example.com", upstream)
+	defer manager.DeleteAll()
+	// requests without bearer token are rejected by passthrough member before they reach upstream
+	manager.Add(newFakeUpstreamCluster(t, "passthrough.example.com", map[string]string{clusters.AuthModeAnnotationKey: "passthrough"}, upstream))
+	d := NewDispatcher(manager, Config{Fleet: &FleetRoute{Hostname: "fleet.gateway", Clusters: []string{"example.com", "passthrough.example.com"}, Timeout: 10 * time.Second}})
+
+	recorder := httptest.NewRecorder()
+	d.ServeHTTP(recorder, newFakeUpstreamRequest("fleet.gateway", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods", Path: fakePods}))
+
+	list := fleetList{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response %q: %v", recorder.Body.String(), err)
+	}
+	if recorder.Code != http.StatusOK || len(list.Items) != 1 {
+		t.Fatalf("response = %d with %d items, want %d with 1 item", recorder.Code, len(list.Items), http.StatusOK)
+	}
+	if len(list.Failures) != 1 || list.Failures[0].Cluster != "passthrough.example.com" || list.Failures[0].Code != http.StatusUnauthorized {
+		t.Errorf("failures = %+v, want passthrough.example.com rejected by %d", list.Failures, http.StatusUnauthorized)
+	}
+}
+
+func TestDispatcher_serveFleet_pagesMembers(t *testing.T) {
+	upstream := fakeupstream.NewServer()
+	defer upstream.Close()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		upstream.AddObject(fakePods, fakeupstream.Object{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		})
+	}
+	manager := newFakeUpstreamManager(t, "example.com", upstream)
+	defer manager.DeleteAll()
+	d := NewDispatcher(manager, Config{Fleet: &FleetRoute{Hostname: "fleet.gateway", Clusters: []string{"example.com"}, Timeout: 10 * time.Second}})
+	requestInfo := &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods", Path: fakePods}
+
+	// member has more items than limit, all of its pages are merged
+	recorder := httptest.NewRecorder()
+	req := newFakeUpstreamRequest("fleet.gateway", requestInfo)
+	req.URL.RawQuery = "limit=2"
+	d.ServeHTTP(recorder, req)
+	list := fleetList{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode response %q: %v", recorder.Body.String(), err)
+	}
+	if recorder.Code != http.StatusOK || len(list.Items) != 5 || len(list.Failures) != 0 {
+		t.Errorf("response = %d with %d items and failures %+v, want %d with 5 items", recorder.Code, len(list.Items), list.Failures, http.StatusOK)
+	}
+
+	// fleet never responds continue tokens, so they are rejected
+	recorder = httptest.NewRecorder()
+	req = newFakeUpstreamRequest("fleet.gateway", requestInfo)
+	req.URL.RawQuery = "limit=2&continue=b"
+	d.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("response of continue = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
+
+func Test_isFleetRequest(t *testing.T) {
+	d := &dispatcher{fleet: &FleetRoute{Hostname: "fleet.gateway"}}
+	for hostname, want := range map[string]bool{
+		"fleet.gateway": true,
+		"Fleet.Gateway": true,
+		"foo.gateway":   false,
+	} {
+		if got := d.isFleetRequest(hostname); got != want {
+			t.Errorf("isFleetRequest(%q) = %v, want %v", hostname, got, want)
+		}
+	}
+	if (&dispatcher{}).isFleetRequest("fleet.gateway") {
+		t.Errorf("isFleetRequest() without fleet route = true, want false")
+	}
+}
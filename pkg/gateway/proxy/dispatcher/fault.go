@@ -0,0 +1,92 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+// injectFault injects fault into req, it returns false if the request is terminated by the fault.
+func (d *dispatcher) injectFault(w http.ResponseWriter, req *http.Request, cluster string, fault *clusters.FaultInjection) bool {
+	if !delayFault(req.Context(), cluster, fault) {
+		return false
+	}
+	switch {
+	case fault.ResetConnection:
+		metrics.RecordInjectedFault(cluster, "reset")
+		klog.V(4).Infof("[fault injection] reset connection of request, cluster=%q uri=%q", cluster, redact.URI(req.RequestURI))
+		// closes HTTP/1.x connection or resets HTTP/2 stream without a response
+		panic(http.ErrAbortHandler)
+	case fault.StatusCode != 0:
+		metrics.RecordInjectedFault(cluster, "error")
+		d.responseError(faultStatusError(cluster, fault), w, req, statusReasonFaultInjected)
+		return false
+	}
+	return true
+}
+
+// injectFleetFault injects fault into the sub-request to a member cluster of fleet, the fault terminating
+// the sub-request is returned as the failure of the member instead of aborting the whole fleet request.
+func injectFleetFault(ctx context.Context, cluster string, fault *clusters.FaultInjection) *errors.StatusError {
+	if !delayFault(ctx, cluster, fault) {
+		return errors.NewTimeoutError(fmt.Sprintf("request to cluster(%s) is delayed by fault injected by gateway until timeout", cluster), retryAfter)
+	}
+	switch {
+	case fault.ResetConnection:
+		metrics.RecordInjectedFault(cluster, "reset")
+		return errors.NewServiceUnavailable(fmt.Sprintf("connection reset by fault injected by gateway for cluster(%s)", cluster))
+	case fault.StatusCode != 0:
+		metrics.RecordInjectedFault(cluster, "error")
+		return faultStatusError(cluster, fault)
+	}
+	return nil
+}
+
+// delayFault waits for the latency of fault, it returns false if ctx is done first
+func delayFault(ctx context.Context, cluster string, fault *clusters.FaultInjection) bool {
+	latency := fault.Latency()
+	if latency <= 0 {
+		return true
+	}
+	metrics.RecordInjectedFault(cluster, "latency")
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func faultStatusError(cluster string, fault *clusters.FaultInjection) *errors.StatusError {
+	return &errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    int32(fault.StatusCode),
+		Reason:  metav1.StatusReasonUnknown,
+		Message: fmt.Sprintf("fault injected by gateway for cluster(%s)", cluster),
+	}}
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/kubewharf/apiserver-runtime/pkg/server"
+	"k8s.io/apimachinery/pkg/util/sets"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// DefaultLongRunningPolicy decides which proxied requests are long-running, nil treats only
+// the kubernetes defaults (watch, exec, attach, log, portforward, proxy) as long-running.
+// Timeout and max-inflight filters of generic apiserver consult it through IsLongRunning, so
+// LongRunningOptions sets it while building the server config.
+var DefaultLongRunningPolicy *LongRunningPolicy
+
+// LongRunningRule matches requests which are long-running in addition to the kubernetes defaults,
+// e.g. custom streaming endpoints served by extension apiservers. All set fields must match.
+type LongRunningRule struct {
+	// Verbs are kubernetes verbs of request, e.g. get, create, empty means any verb
+	Verbs sets.String
+	// Path matches the url path of request, nil means any path
+	Path *regexp.Regexp
+	// Query is the name of a query parameter the request must have, empty means not required
+	Query string
+	// QueryValue is the value of query parameter Query, empty means any value
+	QueryValue string
+}
+
+// LongRunningPolicy extends the kubernetes long-running request predicate with rules, long-running
+// requests are not bounded by timeouts of short requests and are drained as streams.
+type LongRunningPolicy struct {
+	Rules []LongRunningRule
+}
+
+// ParseLongRunningRules parses rules, each of them is a list of key=value separated by semicolons,
+// keys are verbs (comma separated), path (a regular expression) and query (name or name=value),
+// e.g. verbs=get;path=^/apis/metrics.example.io/v1/namespaces/[^/]+/streams/;query=follow=true
+func ParseLongRunningRules(values []string) (*LongRunningPolicy, error) {
+	policy := &LongRunningPolicy{}
+	for _, value := range values {
+		rule := LongRunningRule{}
+		for _, field := range strings.Split(value, ";") {
+			if len(strings.TrimSpace(field)) == 0 {
+				continue
+			}
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 || len(strings.TrimSpace(kv[1])) == 0 {
+				return nil, fmt.Errorf("missing value of %q in long-running rule %q", field, value)
+			}
+			v := strings.TrimSpace(kv[1])
+			switch strings.TrimSpace(kv[0]) {
+			case "verbs":
+				rule.Verbs = sets.NewString()
+				for _, verb := range strings.Split(v, ",") {
+					if verb = strings.TrimSpace(verb); len(verb) > 0 {
+						rule.Verbs.Insert(strings.ToLower(verb))
+					}
+				}
+			case "path":
+				re, err := regexp.Compile(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid path of long-running rule %q: %v", value, err)
+				}
+				rule.Path = re
+			case "query":
+				q := strings.SplitN(v, "=", 2)
+				rule.Query = q[0]
+				if len(q) == 2 {
+					rule.QueryValue = q[1]
+				}
+			default:
+				return nil, fmt.Errorf("unrecognized key %q in long-running rule %q, must be one of verbs, path and query", kv[0], value)
+			}
+		}
+		if len(rule.Verbs) == 0 && rule.Path == nil && len(rule.Query) == 0 {
+			// an empty rule makes every request long-running
+			return nil, fmt.Errorf("long-running rule %q must set at least one of verbs, path and query", value)
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+// Match returns true if req matches the rule
+func (r *LongRunningRule) Match(req *http.Request, requestInfo *genericapirequest.RequestInfo) bool {
+	if len(r.Verbs) > 0 && (requestInfo == nil || !r.Verbs.Has(requestInfo.Verb)) {
+		return false
+	}
+	if r.Path != nil && !r.Path.MatchString(req.URL.Path) {
+		return false
+	}
+	if len(r.Query) > 0 {
+		values, ok := req.URL.Query()[r.Query]
+		if !ok {
+			return false
+		}
+		if len(r.QueryValue) > 0 && !sets.NewString(values...).Has(r.QueryValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLongRunning returns true if req is long-running by kubernetes defaults or any rule
+func (p *LongRunningPolicy) IsLongRunning(req *http.Request, requestInfo *genericapirequest.RequestInfo) bool {
+	if server.DefaultLongRunningFunc(req, requestInfo) {
+		return true
+	}
+	if p == nil {
+		return false
+	}
+	for i := range p.Rules {
+		if p.Rules[i].Match(req, requestInfo) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLongRunning returns true if req is long-running by DefaultLongRunningPolicy, it can be
+// used as the LongRunningFunc of generic apiserver config.
+func IsLongRunning(req *http.Request, requestInfo *genericapirequest.RequestInfo) bool {
+	return DefaultLongRunningPolicy.IsLongRunning(req, requestInfo)
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestParseLongRunningRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"valid", []string{"verbs=get,list;path=^/apis/metrics.example.io/;query=follow=true", "query=stream"}, false},
+		{"empty rule", []string{";"}, true},
+		{"unknown key", []string{"method=GET"}, true},
+		{"missing value", []string{"path="}, true},
+		{"invalid regexp", []string{"path=^/apis/(foo"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseLongRunningRules(tt.values); (err != nil) != tt.wantErr {
+				t.Errorf("ParseLongRunningRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLongRunningPolicy_IsLongRunning(t *testing.T) {
+	policy, err := ParseLongRunningRules([]string{"verbs=get;path=^/apis/metrics.example.io/v1/streams/;query=follow=true"})
+	if err != nil {
+		t.Fatalf("ParseLongRunningRules() error = %v", err)
+	}
+	tests := []struct {
+		name   string
+		url    string
+		info   *genericapirequest.RequestInfo
+		policy *LongRunningPolicy
+		want   bool
+	}{
+		{"kubernetes watch", "/api/v1/pods?watch=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "watch"}, nil, true},
+		{"matched", "/apis/metrics.example.io/v1/streams/a?follow=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get"}, policy, true},
+		{"nil policy", "/apis/metrics.example.io/v1/streams/a?follow=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get"}, nil, false},
+		{"query value mismatched", "/apis/metrics.example.io/v1/streams/a?follow=false", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get"}, policy, false},
+		{"verb mismatched", "/apis/metrics.example.io/v1/streams/a?follow=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list"}, policy, false},
+		{"path mismatched", "/apis/apps/v1/deployments?follow=true", &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get"}, policy, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if got := tt.policy.IsLongRunning(req, tt.info); got != tt.want {
+				t.Errorf("IsLongRunning() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+// NoRouteAction is what gateway does with requests matching no route
+type NoRouteAction string
+
+const (
+	// NoRouteNotFound responds 404 Status telling clients what is not matched
+	NoRouteNotFound NoRouteAction = "NotFound"
+	// NoRouteDefaultCluster routes requests of unknown hostnames to the default cluster,
+	// requests matching no dispatch policy of a known cluster are responded like NoRouteNotFound
+	NoRouteDefaultCluster NoRouteAction = "DefaultCluster"
+	// NoRouteRedirect redirects requests to the documentation url
+	NoRouteRedirect NoRouteAction = "Redirect"
+)
+
+// NoRoutePolicy handles requests whose hostname is not an upstream cluster being proxied, and
+// requests matching no dispatch policy of their cluster, in the same way.
+type NoRoutePolicy struct {
+	Action NoRouteAction
+	// DefaultCluster is the cluster requests of unknown hostnames are routed to by NoRouteDefaultCluster
+	DefaultCluster string
+	// DocumentationURL is where NoRouteRedirect redirects to, it is also linked in 404 messages
+	DocumentationURL string
+}
+
+// defaultCluster returns the cluster requests of unknown hostnames are routed to
+func (p *NoRoutePolicy) defaultCluster(manager clusters.Manager) (*clusters.ClusterInfo, bool) {
+	if p == nil || p.Action != NoRouteDefaultCluster {
+		return nil, false
+	}
+	return manager.Get(p.DefaultCluster)
+}
+
+// responseNoRoute responds the request matching no route, message tells what is not matched.
+// legacy is responded if no policy is configured.
+func (d *dispatcher) responseNoRoute(w http.ResponseWriter, req *http.Request, message string, legacy *errors.StatusError, legacyReason string) {
+	if d.noRoute == nil {
+		d.responseError(legacy, w, req, legacyReason)
+		return
+	}
+	if d.noRoute.Action == NoRouteRedirect {
+		runtime.Must(request.SetProxyTerminated(req.Context(), statusReasonNoRoute))
+		http.Redirect(w, req, d.noRoute.DocumentationURL, http.StatusFound)
+		return
+	}
+	if len(d.noRoute.DocumentationURL) > 0 {
+		message = fmt.Sprintf("%s, see %s", message, d.noRoute.DocumentationURL)
+	}
+	d.responseError(&errors.StatusError{ErrStatus: metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusNotFound,
+		Reason:  metav1.StatusReasonNotFound,
+		Message: message,
+	}}, w, req, statusReasonNoRoute)
+}
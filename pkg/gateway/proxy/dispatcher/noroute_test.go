@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+func TestNoRoutePolicy(t *testing.T) {
+	manager := clusters.NewManager()
+	// the cluster has no dispatch policy, so no request matches
+	manager.Add(clusters.NewEmptyClusterInfo("default", nil, nil, nil))
+
+	tests := []struct {
+		name         string
+		policy       *NoRoutePolicy
+		hostname     string
+		wantCode     int
+		wantMessage  string
+		wantLocation string
+	}{
+		{"legacy unknown hostname", nil, "unknown", http.StatusServiceUnavailable, "is not being proxied", ""},
+		{"legacy no dispatch policy", nil, "default", http.StatusInternalServerError, "no router rule matches", ""},
+		{"unknown hostname", &NoRoutePolicy{Action: NoRouteNotFound, DocumentationURL: "https://docs.example.com"}, "unknown", http.StatusNotFound, `hostname \"unknown\" is not an upstream cluster being proxied by gateway, check the server address of your kubeconfig, see https://docs.example.com`, ""},
+		{"no dispatch policy", &NoRoutePolicy{Action: NoRouteNotFound}, "default", http.StatusNotFound, `GET /api/v1/pods matches no dispatch policy of upstream cluster \"default\"`, ""},
+		{"default cluster", &NoRoutePolicy{Action: NoRouteDefaultCluster, DefaultCluster: "default"}, "unknown", http.StatusNotFound, `upstream cluster \"default\"`, ""},
+		{"missing default cluster", &NoRoutePolicy{Action: NoRouteDefaultCluster, DefaultCluster: "missing"}, "unknown", http.StatusNotFound, `hostname \"unknown\"`, ""},
+		{"redirect", &NoRoutePolicy{Action: NoRouteRedirect, DocumentationURL: "https://docs.example.com"}, "unknown", http.StatusFound, "", "https://docs.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher(manager, Config{NoRoute: tt.policy})
+
+			req := httptest.NewRequest(http.MethodGet, "https://"+tt.hostname+"/api/v1/pods", nil)
+			ctx := genericapirequest.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"})
+			ctx = genericapirequest.WithRequestInfo(ctx, &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Resource: "pods", Path: "/api/v1/pods"})
+			ctx = request.WithExtraReqeustInfo(ctx, &request.ExtraRequestInfo{Hostname: tt.hostname})
+			ctx = request.WithProxyInfo(ctx, request.NewProxyInfo())
+			recorder := httptest.NewRecorder()
+			d.ServeHTTP(recorder, req.WithContext(ctx))
+
+			if recorder.Code != tt.wantCode {
+				t.Errorf("code = %d, want %d, body: %s", recorder.Code, tt.wantCode, recorder.Body.String())
+			}
+			if !strings.Contains(recorder.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want message %q", recorder.Body.String(), tt.wantMessage)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", location, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+// RequestPriorityHeader is set by clients to hint the gateway priority of their requests,
+// e.g. batch reporting jobs can set it to low. Clients can only lower their priority by it.
+const RequestPriorityHeader = "X-Kube-Gateway-Priority"
+
+// PriorityPolicy classifies requests into priorities which order requests queued for
+// cluster resource budget, so critical controllers are never queued behind batch jobs.
+// Priorities are raised only by authenticated users and groups, User-Agent is set by clients
+// and can only lower priority.
+type PriorityPolicy struct {
+	// HighUsers and HighGroups are authenticated users and groups whose requests have high priority,
+	// e.g. system:kube-scheduler or system:nodes
+	HighUsers  sets.String
+	HighGroups sets.String
+	// LowUsers and LowGroups are authenticated users and groups whose requests have low priority
+	LowUsers  sets.String
+	LowGroups sets.String
+	// LowUserAgents are patterns of normalized User-Agent whose requests have low priority, e.g. reporter,
+	// they are matched like UserAgentRule of upstream clusters, see clusters.MatchUserAgent
+	LowUserAgents []string
+	// QueueTimeout is the maximum duration requests wait for exhausted cluster resource budget,
+	// zero means requests are rejected immediately regardless of priority.
+	QueueTimeout time.Duration
+}
+
+// Timeout returns the maximum duration requests wait in queues
+func (p *PriorityPolicy) Timeout() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.QueueTimeout
+}
+
+// Classify returns the priority of req from authenticated user u, lowered by User-Agent and
+// priority hint header of req
+func (p *PriorityPolicy) Classify(u user.Info, req *http.Request) clusters.RequestPriority {
+	priority := clusters.RequestPriorityNormal
+	if p == nil {
+		return priority
+	}
+	if matchUser(u, p.HighUsers, p.HighGroups) {
+		priority = clusters.RequestPriorityHigh
+	} else if matchUser(u, p.LowUsers, p.LowGroups) {
+		priority = clusters.RequestPriorityLow
+	}
+	if clusters.MatchUserAgent(p.LowUserAgents, clusters.NormalizeUserAgent(req.UserAgent())) {
+		priority = clusters.RequestPriorityLow
+	}
+	if hint := req.Header.Get(RequestPriorityHeader); len(hint) > 0 {
+		// a hint can not raise priority, otherwise any client can jump the queue
+		if hinted, err := clusters.ParseRequestPriority(hint); err == nil && hinted < priority {
+			priority = hinted
+		}
+	}
+	return priority
+}
+
+// matchUser returns true if u is one of users or in one of groups
+func matchUser(u user.Info, users, groups sets.String) bool {
+	if u == nil {
+		return false
+	}
+	if users.Has(u.GetName()) {
+		return true
+	}
+	for _, g := range u.GetGroups() {
+		if groups.Has(g) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+func TestPriorityPolicy_Classify(t *testing.T) {
+	policy := &PriorityPolicy{
+		HighUsers:     sets.NewString("system:kube-scheduler"),
+		HighGroups:    sets.NewString("system:nodes"),
+		LowGroups:     sets.NewString("reporters"),
+		LowUserAgents: []string{"reporter"},
+	}
+	scheduler := &user.DefaultInfo{Name: "system:kube-scheduler"}
+	kubelet := &user.DefaultInfo{Name: "system:node:node-1", Groups: []string{"system:nodes", "system:authenticated"}}
+	reporter := &user.DefaultInfo{Name: "alice", Groups: []string{"reporters"}}
+	alice := &user.DefaultInfo{Name: "alice"}
+	tests := []struct {
+		name      string
+		policy    *PriorityPolicy
+		user      user.Info
+		userAgent string
+		hint      string
+		want      clusters.RequestPriority
+	}{
+		{"nil policy", nil, scheduler, "kube-scheduler/v1.18.0", "", clusters.RequestPriorityNormal},
+		{"high user", policy, scheduler, "kube-scheduler/v1.18.0", "", clusters.RequestPriorityHigh},
+		{"high group", policy, kubelet, "kubelet/v1.18.0", "", clusters.RequestPriorityHigh},
+		{"user agent can not raise", policy, alice, "kube-scheduler/v1.18.0", "", clusters.RequestPriorityNormal},
+		{"low group", policy, reporter, "kubectl/v1.18.0", "", clusters.RequestPriorityLow},
+		{"lower by user agent", policy, scheduler, "Reporter/v1", "", clusters.RequestPriorityLow},
+		{"default", policy, alice, "kubectl/v1.18.0", "", clusters.RequestPriorityNormal},
+		{"no user", policy, nil, "kubectl/v1.18.0", "", clusters.RequestPriorityNormal},
+		{"lower by hint", policy, kubelet, "kubelet/v1.18.0", "low", clusters.RequestPriorityLow},
+		{"can not raise by hint", policy, reporter, "reporter/v1", "high", clusters.RequestPriorityLow},
+		{"invalid hint", policy, alice, "kubectl/v1.18.0", "urgent", clusters.RequestPriorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "https://test/api/v1/pods", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if len(tt.hint) > 0 {
+				req.Header.Set(RequestPriorityHeader, tt.hint)
+			}
+			if got := tt.policy.Classify(tt.user, req); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -26,9 +26,9 @@ import (
 	"k8s.io/apiserver/pkg/endpoints/responsewriter"
 	"k8s.io/klog"
 
-	"github.com/kubewharf/apiserver-runtime/pkg/server"
 	gatewayrequest "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
 	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
 )
 
 var _ http.ResponseWriter = &responseWriterDelegator{}
@@ -149,14 +149,31 @@ func (rw *responseWriterDelegator) MonitorAfterProxy() {
 		rw.ContentLength(),
 		rw.Elapsed(),
 	)
+	metrics.RecordUserRequest(rw.host, rw.user.GetName())
+	rw.recordPhases()
 	rw.Log()
 }
 
+// recordPhases records gateway overhead and upstream latency of the forwarded request separately,
+// so claims and regressions of gateway latency can be told apart from slow upstreams.
+func (rw *responseWriterDelegator) recordPhases() {
+	var overhead time.Duration
+	for _, phase := range gatewayrequest.ProxyPhasesFrom(rw.req.Context()) {
+		if phase.Phase == gatewayrequest.ProxyPhaseUpstream {
+			metrics.RecordUpstreamHeaderLatency(rw.host, rw.requestInfo.Verb, phase.Duration)
+			continue
+		}
+		overhead += phase.Duration
+		metrics.RecordOverheadLatency(rw.host, string(phase.Phase), phase.Duration)
+	}
+	metrics.RecordOverheadLatency(rw.host, "total", overhead)
+}
+
 // Log is intended to be called once at the end of your request handler, via defer
 func (rw *responseWriterDelegator) Log() {
 	latency := rw.Elapsed()
 	logging := rw.logging
-	if latency.Minutes() > 10 && !server.DefaultLongRunningFunc(rw.req, rw.requestInfo) {
+	if latency.Minutes() > 10 && !IsLongRunning(rw.req, rw.requestInfo) {
 		logging = true
 	}
 	if !logging {
@@ -169,7 +186,7 @@ func (rw *responseWriterDelegator) Log() {
 			verb,
 			rw.host,
 			rw.endpoint,
-			rw.req.RequestURI,
+			redact.URI(rw.req.RequestURI),
 			latency,
 			rw.status,
 			rw.user.GetName(),
@@ -178,26 +195,31 @@ func (rw *responseWriterDelegator) Log() {
 			rw.impersonator.GetName(),
 			rw.impersonator.GetGroups(),
 			sourceIPs,
-			rw.addedInfo,
+			redact.Text(rw.addedInfo),
 		)
 	} else {
 		klog.Infof("verb=%q host=%q endpoint=%q URI=%q latency=%v resp=%v user=%q userGroup=%v userAgent=%q srcIP=%v: %v",
 			verb,
 			rw.host,
 			rw.endpoint,
-			rw.req.RequestURI,
+			redact.URI(rw.req.RequestURI),
 			latency,
 			rw.status,
 			rw.user.GetName(),
 			rw.user.GetGroups(),
 			rw.req.UserAgent(),
 			sourceIPs,
-			rw.addedInfo,
+			redact.Text(rw.addedInfo),
 		)
 	}
 }
 
 func (rw *responseWriterDelegator) recordStatus(status int) {
+	if !rw.statusRecorded {
+		// time until the first response header is spent by upstream, errors responded by
+		// gateway after the request is forwarded come from failed tries to upstream too
+		gatewayrequest.MarkProxyPhase(rw.req.Context(), gatewayrequest.ProxyPhaseUpstream) //nolint
+	}
 	rw.status = status
 	rw.statusRecorded = true
 	rw.captureErrorOutput = captureErrorOutput(status)
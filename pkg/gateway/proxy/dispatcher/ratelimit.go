@@ -0,0 +1,46 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+)
+
+// RateLimit-* response headers tell clients the state of the flow control bucket which their
+// requests are charged to, so well-behaved clients can adapt pacing before being rejected by 429.
+// See https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// setRateLimitHeaders sets RateLimit-* headers from flow control status, nothing is set
+// if the flow control does not limit requests.
+func setRateLimitHeaders(h http.Header, flowcontrol gatewayflowcontrol.FlowControl) {
+	status, ok := gatewayflowcontrol.StatusOf(flowcontrol)
+	if !ok {
+		return
+	}
+	// reset is in delta seconds, round up so that clients never retry too early
+	reset := int64((status.Reset + time.Second - 1) / time.Second)
+	h.Set(RateLimitLimitHeader, strconv.Itoa(status.Limit))
+	h.Set(RateLimitRemainingHeader, strconv.Itoa(status.Remaining))
+	h.Set(RateLimitResetHeader, strconv.FormatInt(reset, 10))
+}
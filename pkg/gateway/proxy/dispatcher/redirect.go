@@ -0,0 +1,144 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// maxFollowedRedirects is the same limit as net/http client
+	maxFollowedRedirects = 10
+)
+
+// redirectRoundTripper handles upstream 3xx responses according to cluster redirect policy,
+// externalScheme and externalHost are the gateway address requested by client.
+type redirectRoundTripper struct {
+	rt             http.RoundTripper
+	cluster        *clusters.ClusterInfo
+	policy         clusters.RedirectPolicy
+	externalScheme string
+	externalHost   string
+}
+
+var _ utilnet.RoundTripperWrapper = &redirectRoundTripper{}
+
+func newRedirectRoundTripper(rt http.RoundTripper, cluster *clusters.ClusterInfo, policy clusters.RedirectPolicy, externalScheme, externalHost string) http.RoundTripper {
+	if len(externalScheme) == 0 {
+		externalScheme = "https"
+	}
+	return &redirectRoundTripper{
+		rt:             rt,
+		cluster:        cluster,
+		policy:         policy,
+		externalScheme: externalScheme,
+		externalHost:   externalHost,
+	}
+}
+
+func (rt *redirectRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if rt.policy == clusters.RedirectPolicyFollow {
+		for i := 0; i < maxFollowedRedirects && isRedirect(resp.StatusCode); i++ {
+			next, ok := followRedirect(req, resp)
+			if !ok {
+				break
+			}
+			klog.V(4).Infof("[redirect] follow redirect of cluster=%q from %q to %q", rt.cluster.Cluster, redact.URL(req.URL), redact.URL(next.URL))
+			drainAndClose(resp)
+			req = next
+			resp, err = rt.rt.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rt.policy != clusters.RedirectPolicyPassThrough {
+		rt.rewriteLocation(resp)
+	}
+	return resp, nil
+}
+
+func (rt *redirectRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+// rewriteLocation replaces endpoint address in Location header with the external gateway address
+func (rt *redirectRoundTripper) rewriteLocation(resp *http.Response) {
+	value := resp.Header.Get("Location")
+	if len(value) == 0 {
+		return
+	}
+	location, err := url.Parse(value)
+	if err != nil || !location.IsAbs() || !rt.cluster.IsEndpointURL(location) {
+		return
+	}
+	location.Scheme = rt.externalScheme
+	location.Host = rt.externalHost
+	resp.Header.Set("Location", location.String())
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// followRedirect returns the request to redirect location if it is on the same endpoint
+// and the request can be replayed. Only 307 and 308 keep method and body of non-GET requests,
+// others are not followed because clients expect modifications to be applied exactly once.
+func followRedirect(req *http.Request, resp *http.Response) (*http.Request, bool) {
+	location, err := resp.Location()
+	if err != nil || !strings.EqualFold(location.Host, req.URL.Host) || location.Scheme != req.URL.Scheme {
+		return nil, false
+	}
+
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+	keepBody := resp.StatusCode == http.StatusTemporaryRedirect || resp.StatusCode == http.StatusPermanentRedirect
+	if !safe && !keepBody {
+		return nil, false
+	}
+
+	// WithContext creates a shallow clone of the request with the same context.
+	next := req.WithContext(req.Context())
+	next.URL = location
+	if safe && req.ContentLength == 0 {
+		next.Body = http.NoBody
+	} else {
+		if req.GetBody == nil {
+			return nil, false
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	}
+	return next, true
+}
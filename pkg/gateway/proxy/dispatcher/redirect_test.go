@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+// redirectingRoundTripper redirects requests to /old to location
+type redirectingRoundTripper struct {
+	code     int
+	location string
+	calls    int
+}
+
+func (rt *redirectingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+	if req.URL.Path == "/old" {
+		resp.StatusCode = rt.code
+		resp.Header.Set("Location", rt.location)
+	}
+	return resp, nil
+}
+
+func Test_redirectRoundTripper(t *testing.T) {
+	cluster := &clusters.ClusterInfo{Cluster: "test", Endpoints: &clusters.EndpointInfoMap{}}
+	cluster.Endpoints.Store("https://10.0.0.1:6443", &clusters.EndpointInfo{Endpoint: "https://10.0.0.1:6443"})
+	cluster.Endpoints.Store("https://10.0.0.2:6443", &clusters.EndpointInfo{Endpoint: "https://10.0.0.2:6443"})
+
+	tests := []struct {
+		name         string
+		policy       clusters.RedirectPolicy
+		method       string
+		code         int
+		location     string
+		wantCode     int
+		wantLocation string
+		wantCalls    int
+	}{
+		{"pass through", clusters.RedirectPolicyPassThrough, http.MethodGet, http.StatusFound, "https://10.0.0.1:6443/new", http.StatusFound, "https://10.0.0.1:6443/new", 1},
+		{"rewrite endpoint", clusters.RedirectPolicyRewrite, http.MethodGet, http.StatusFound, "https://10.0.0.2:6443/new?a=b", http.StatusFound, "https://gateway.example.com:9443/new?a=b", 1},
+		{"rewrite keeps external location", clusters.RedirectPolicyRewrite, http.MethodGet, http.StatusFound, "https://login.example.com/", http.StatusFound, "https://login.example.com/", 1},
+		{"rewrite keeps relative location", clusters.RedirectPolicyRewrite, http.MethodGet, http.StatusFound, "/new", http.StatusFound, "/new", 1},
+		{"follow same endpoint", clusters.RedirectPolicyFollow, http.MethodGet, http.StatusFound, "/new", http.StatusOK, "", 2},
+		{"follow absolute same endpoint", clusters.RedirectPolicyFollow, http.MethodGet, http.StatusMovedPermanently, "https://10.0.0.1:6443/new", http.StatusOK, "", 2},
+		{"follow rewrites other endpoint", clusters.RedirectPolicyFollow, http.MethodGet, http.StatusFound, "https://10.0.0.2:6443/new", http.StatusFound, "https://gateway.example.com:9443/new", 1},
+		{"follow does not replay unsafe method", clusters.RedirectPolicyFollow, http.MethodPost, http.StatusFound, "/new", http.StatusFound, "/new", 1},
+		{"follow loop", clusters.RedirectPolicyFollow, http.MethodGet, http.StatusFound, "/old", http.StatusFound, "/old", maxFollowedRedirects + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &redirectingRoundTripper{code: tt.code, location: tt.location}
+			rt := newRedirectRoundTripper(fake, cluster, tt.policy, "", "gateway.example.com:9443")
+			req, _ := http.NewRequest(tt.method, "https://10.0.0.1:6443/old", strings.NewReader("body"))
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("RoundTrip() code = %v, want %v", resp.StatusCode, tt.wantCode)
+			}
+			if got := resp.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("RoundTrip() location = %v, want %v", got, tt.wantLocation)
+			}
+			if fake.calls != tt.wantCalls {
+				t.Errorf("RoundTrip() calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// maxDrainBytes is the maximum bytes of a discarded upstream response read for connection reuse
+	maxDrainBytes = 4 << 10
+)
+
+// RetryPolicy describes which failed upstream attempts are retried by gateway before they are
+// surfaced to clients. Requests failed at connection setup are retried if their body is replayable,
+// upstream 5xx responses and response header timeouts are retried only for safe methods.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries after the first attempt
+	MaxRetries int
+	// StatusCodes are the retriable upstream response codes of safe methods
+	StatusCodes sets.Int
+	// Backoff is the duration to wait before each retry
+	Backoff time.Duration
+	// MaxBodyBytes is the maximum size of request body spooled in memory for replay,
+	// requests with larger body are not retried, zero means requests with body are never retried.
+	MaxBodyBytes int64
+}
+
+// Enabled returns true if any failed upstream attempt should be retried
+func (p *RetryPolicy) Enabled() bool {
+	return p != nil && p.MaxRetries > 0
+}
+
+// isSafeMethod returns true if the request can be sent to upstream more than once
+// even if upstream has received it. Retrying a mutating request may apply it twice.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// spoolRequestBody reads request body into memory so that the request can be replayed on
+// another endpoint. It returns false if the body is larger than maxBytes, the body read so far
+// is still sent to upstream in this case.
+func spoolRequestBody(req *http.Request, maxBytes int64) (bool, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return true, nil
+	}
+	if maxBytes <= 0 || req.ContentLength > maxBytes {
+		return false, nil
+	}
+	body := req.Body
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxBytes+1))
+	if err != nil {
+		return false, err
+	}
+	if int64(len(data)) > maxBytes {
+		req.Body = &partiallyReadBody{Reader: io.MultiReader(bytes.NewReader(data), body), Closer: body}
+		return false, nil
+	}
+	body.Close()
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return true, nil
+}
+
+type partiallyReadBody struct {
+	io.Reader
+	io.Closer
+}
+
+// retryRoundTripper retries failed upstream attempts on endpoints popped from picker
+type retryRoundTripper struct {
+	policy      *RetryPolicy
+	cluster     string
+	picker      clusters.EndpointPicker
+	endpoint    *clusters.EndpointInfo
+	longRunning bool
+}
+
+func newRetryRoundTripper(policy *RetryPolicy, cluster string, picker clusters.EndpointPicker, endpoint *clusters.EndpointInfo, longRunning bool) http.RoundTripper {
+	return &retryRoundTripper{
+		policy:      policy,
+		cluster:     cluster,
+		picker:      picker,
+		endpoint:    endpoint,
+		longRunning: longRunning,
+	}
+}
+
+func (rt *retryRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := rt.endpoint
+	safe := isSafeMethod(req.Method)
+	for retries := 0; ; retries++ {
+		resp, err := endpoint.ProxyTransportFor(rt.longRunning).RoundTrip(req)
+		if retries >= rt.policy.MaxRetries {
+			return resp, err
+		}
+
+		// reason is the response code or the error class of connection setup error
+		var errorClass, message, reason string
+		switch {
+		case err != nil && errclass.IsConnectionSetupError(err):
+			errorClass, message = string(errclass.ClassifyProxyError(err)), redact.Error(err)
+			reason = errorClass
+		case err != nil && safe && errclass.ClassifyProxyError(err) == errclass.ResponseHeaderTimeout:
+			// upstream may have received the request, only safe methods can be retried
+			errorClass, message = string(errclass.ResponseHeaderTimeout), redact.Error(err)
+			reason = errorClass
+		case err == nil && safe && rt.policy.StatusCodes.Has(resp.StatusCode):
+			errorClass = string(errclass.UpstreamStatus)
+			message = fmt.Sprintf("upstream responded with status %d", resp.StatusCode)
+			reason = strconv.Itoa(resp.StatusCode)
+		default:
+			return resp, err
+		}
+
+		// pick next endpoint before discarding the response, so the original response
+		// can still be returned if there is no endpoint to retry
+		next, perr := rt.picker.Pop()
+		if perr != nil {
+			return resp, err
+		}
+		location, perr := url.Parse(next.Endpoint)
+		if perr != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			drainAndClose(resp)
+		}
+		request.FailProxyAttempt(req.Context(), endpoint.Endpoint, errorClass, message) //nolint
+		metrics.RecordProxyRetry(rt.cluster, reason)
+		klog.V(4).Infof("[proxy retry] method=%q uri=%q endpoint=%v class=%v retries=%d next=%v", req.Method, redact.URI(req.RequestURI), endpoint.Endpoint, errorClass, retries+1, next.Endpoint)
+
+		if rt.policy.Backoff > 0 {
+			t := time.NewTimer(rt.policy.Backoff)
+			select {
+			case <-req.Context().Done():
+				t.Stop()
+				return nil, req.Context().Err()
+			case <-t.C:
+			}
+		}
+
+		if err := request.SetProxyForwarded(req.Context(), next.Endpoint); err != nil {
+			return nil, err
+		}
+		if err := request.StartProxyAttempt(req.Context()); err != nil {
+			return nil, err
+		}
+
+		// WithContext creates a shallow clone of the request with the same context.
+		retryReq := req.WithContext(req.Context())
+		u := *req.URL
+		u.Scheme = location.Scheme
+		u.Host = location.Host
+		retryReq.URL = &u
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retryReq.Body = body
+		}
+		req = retryReq
+		endpoint = next
+	}
+}
+
+func drainAndClose(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainBytes)) //nolint
+	resp.Body.Close()
+}
@@ -0,0 +1,254 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	gatewayflowcontrol "github.com/kubewharf/kubegateway/pkg/flowcontrol"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+type fakeRoundTripper struct {
+	code   int
+	err    error
+	calls  int
+	bodies []string
+}
+
+func (rt *fakeRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.calls++
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		rt.bodies = append(rt.bodies, string(data))
+	}
+	if rt.err != nil {
+		return nil, rt.err
+	}
+	return &http.Response{StatusCode: rt.code, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+type fakePicker struct {
+	endpoints []*clusters.EndpointInfo
+	index     int
+}
+
+func (p *fakePicker) FlowControl() gatewayflowcontrol.FlowControl {
+	return nil
+}
+
+func (p *fakePicker) FlowSchema() string {
+	return ""
+}
+
+func (p *fakePicker) AuditOnlyFlowControls() []gatewayflowcontrol.FlowControl {
+	return nil
+}
+
+func (p *fakePicker) Pop() (*clusters.EndpointInfo, error) {
+	if len(p.endpoints) == 0 {
+		return nil, clusters.ErrNoReadyEndpoints
+	}
+	ep := p.endpoints[p.index%len(p.endpoints)]
+	p.index++
+	return ep, nil
+}
+
+func (p *fakePicker) EnableLog() bool {
+	return false
+}
+
+func Test_retryRoundTripper(t *testing.T) {
+	policy := &RetryPolicy{MaxRetries: 2, StatusCodes: sets.NewInt(http.StatusBadGateway, http.StatusServiceUnavailable)}
+
+	tests := []struct {
+		name      string
+		codes     []int
+		wantCode  int
+		wantCalls []int
+	}{
+		{"success", []int{http.StatusOK, http.StatusOK}, http.StatusOK, []int{1, 0}},
+		{"retry on another endpoint", []int{http.StatusServiceUnavailable, http.StatusOK}, http.StatusOK, []int{1, 1}},
+		{"never retry 500", []int{http.StatusInternalServerError, http.StatusOK}, http.StatusInternalServerError, []int{1, 0}},
+		{"bounded retries", []int{http.StatusBadGateway, http.StatusBadGateway}, http.StatusBadGateway, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transports := []*fakeRoundTripper{}
+			endpoints := []*clusters.EndpointInfo{}
+			for i, code := range tt.codes {
+				rt := &fakeRoundTripper{code: code}
+				transports = append(transports, rt)
+				endpoints = append(endpoints, &clusters.EndpointInfo{Endpoint: "https://" + string(rune('a'+i)) + ":6443", ProxyTransport: rt})
+			}
+			picker := &fakePicker{endpoints: endpoints, index: 1}
+
+			req := httptest.NewRequest(http.MethodGet, "https://a:6443/api/v1/pods", nil)
+			req = req.WithContext(request.WithProxyInfo(req.Context(), request.NewProxyInfo()))
+
+			resp, err := newRetryRoundTripper(policy, "test", picker, endpoints[0], false).RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() unexpected error = %v", err)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("RoundTrip() code = %v, want %v", resp.StatusCode, tt.wantCode)
+			}
+			for i, rt := range transports {
+				if rt.calls != tt.wantCalls[i] {
+					t.Errorf("RoundTrip() endpoint %d calls = %v, want %v", i, rt.calls, tt.wantCalls[i])
+				}
+			}
+			if got, want := len(request.ProxyAttemptsFrom(req.Context())), sum(tt.wantCalls)-1; got != want {
+				t.Errorf("RoundTrip() failed attempts = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_retryRoundTripper_replayBody(t *testing.T) {
+	policy := &RetryPolicy{MaxRetries: 1, StatusCodes: sets.NewInt(http.StatusServiceUnavailable), MaxBodyBytes: 1024}
+	refused := &fakeRoundTripper{err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}
+	unavailable := &fakeRoundTripper{code: http.StatusServiceUnavailable}
+	ok := &fakeRoundTripper{code: http.StatusCreated}
+
+	tests := []struct {
+		name     string
+		first    *fakeRoundTripper
+		wantCode int
+		wantErr  bool
+	}{
+		{"replay on connection setup error", refused, http.StatusCreated, false},
+		{"never retry mutating request on 5xx", unavailable, http.StatusServiceUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok.bodies = nil
+			endpoints := []*clusters.EndpointInfo{
+				{Endpoint: "https://a:6443", ProxyTransport: tt.first},
+				{Endpoint: "https://b:6443", ProxyTransport: ok},
+			}
+			req := httptest.NewRequest(http.MethodPost, "https://a:6443/api/v1/namespaces/default/configmaps", strings.NewReader("{}"))
+			req = req.WithContext(request.WithProxyInfo(req.Context(), request.NewProxyInfo()))
+			replayable, err := spoolRequestBody(req, policy.MaxBodyBytes)
+			if err != nil || !replayable {
+				t.Fatalf("spoolRequestBody() = %v, %v", replayable, err)
+			}
+
+			resp, err := newRetryRoundTripper(policy, "test", &fakePicker{endpoints: endpoints, index: 1}, endpoints[0], false).RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("RoundTrip() code = %v, want %v", resp.StatusCode, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusCreated && (len(ok.bodies) != 1 || ok.bodies[0] != "{}") {
+				t.Errorf("RoundTrip() replayed bodies = %v, want [{}]", ok.bodies)
+			}
+		})
+	}
+}
+
+func Test_retryRoundTripper_responseHeaderTimeout(t *testing.T) {
+	policy := &RetryPolicy{MaxRetries: 1, MaxBodyBytes: 1024}
+	tests := []struct {
+		name      string
+		method    string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"retry safe request", http.MethodGet, false, 1},
+		{"never retry mutating request", http.MethodPost, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hung := &fakeRoundTripper{err: fmt.Errorf("%w after 1s", clusters.ErrResponseHeaderTimeout)}
+			ok := &fakeRoundTripper{code: http.StatusOK}
+			endpoints := []*clusters.EndpointInfo{
+				{Endpoint: "https://a:6443", ProxyTransport: hung},
+				{Endpoint: "https://b:6443", ProxyTransport: ok},
+			}
+			req := httptest.NewRequest(tt.method, "https://a:6443/api/v1/namespaces/default/configmaps", nil)
+			req = req.WithContext(request.WithProxyInfo(req.Context(), request.NewProxyInfo()))
+
+			_, err := newRetryRoundTripper(policy, "test", &fakePicker{endpoints: endpoints, index: 1}, endpoints[0], false).RoundTrip(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RoundTrip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if ok.calls != tt.wantCalls {
+				t.Errorf("RoundTrip() retried calls = %v, want %v", ok.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func Test_spoolRequestBody(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		maxBytes int64
+		want     bool
+	}{
+		{"no body", http.MethodGet, "", 0, true},
+		{"spooling disabled", http.MethodPost, "{}", 0, false},
+		{"small body", http.MethodPost, "{}", 2, true},
+		{"large body", http.MethodPut, "{\"kind\":\"Pod\"}", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req *http.Request
+			if len(tt.body) > 0 {
+				req = httptest.NewRequest(tt.method, "/api", strings.NewReader(tt.body))
+				// chunked upload
+				req.ContentLength = -1
+			} else {
+				req = httptest.NewRequest(tt.method, "/api", nil)
+			}
+			got, err := spoolRequestBody(req, tt.maxBytes)
+			if err != nil {
+				t.Fatalf("spoolRequestBody() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("spoolRequestBody() = %v, want %v", got, tt.want)
+			}
+			if req.Body == nil {
+				return
+			}
+			// the whole body must be sent to upstream whether it is spooled or not
+			data, _ := ioutil.ReadAll(req.Body)
+			if string(data) != tt.body {
+				t.Errorf("spoolRequestBody() body = %q, want %q", data, tt.body)
+			}
+		})
+	}
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,234 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+// URLRewritePolicy describes which responses have absolute upstream endpoint URLs in their body,
+// e.g. OpenAPI server urls and absolute self links of aggregated APIs, which are rewritten to the
+// external gateway address requested by client. Location headers are handled by redirect policy.
+type URLRewritePolicy struct {
+	// PathPrefixes are the request path prefixes whose JSON responses are rewritten
+	PathPrefixes []string
+	// MaxBodyBytes is the maximum size of response body buffered for rewriting, larger responses
+	// are returned untouched
+	MaxBodyBytes int64
+}
+
+// Enabled returns true if responses of any path should be rewritten
+func (p *URLRewritePolicy) Enabled() bool {
+	return p != nil && len(p.PathPrefixes) > 0 && p.MaxBodyBytes > 0
+}
+
+// Matches returns true if responses of path should be rewritten
+func (p *URLRewritePolicy) Matches(path string) bool {
+	if !p.Enabled() {
+		return false
+	}
+	for _, prefix := range p.PathPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlRewritingRoundTripper replaces endpoint base urls of cluster in JSON response bodies
+type urlRewritingRoundTripper struct {
+	rt           http.RoundTripper
+	cluster      *clusters.ClusterInfo
+	maxBodyBytes int64
+	externalBase []byte
+}
+
+var _ utilnet.RoundTripperWrapper = &urlRewritingRoundTripper{}
+
+func newURLRewritingRoundTripper(rt http.RoundTripper, cluster *clusters.ClusterInfo, maxBodyBytes int64, externalScheme, externalHost string) http.RoundTripper {
+	if len(externalScheme) == 0 {
+		externalScheme = "https"
+	}
+	return &urlRewritingRoundTripper{
+		rt:           rt,
+		cluster:      cluster,
+		maxBodyBytes: maxBodyBytes,
+		externalBase: []byte(externalScheme + "://" + externalHost),
+	}
+}
+
+func (rt *urlRewritingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.rt.RoundTrip(req)
+	if err != nil || !rewritableResponse(resp, rt.maxBodyBytes) {
+		return resp, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, rt.maxBodyBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(data)) > rt.maxBodyBytes {
+		// too large to rewrite, return the rest of body untouched
+		resp.Body = &partiallyReadBody{Reader: io.MultiReader(bytes.NewReader(data), resp.Body), Closer: resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	rewritten, changed, err := rt.rewriteBody(data, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		klog.V(5).Infof("[url rewrite] failed to rewrite response of cluster=%q: %v", rt.cluster.Cluster, err)
+	}
+	if !changed {
+		rewritten = data
+	} else if len(resp.Header.Get("ETag")) > 0 {
+		// etag of upstream identifies the original body, clients caching the rewritten body must not
+		// get 304 responses with it, nor reuse it for another gateway address
+		sum := sha256.Sum256(rewritten)
+		resp.Header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rewritten))
+	resp.ContentLength = int64(len(rewritten))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(rewritten)))
+	return resp, nil
+}
+
+// rewriteBody rewrites data encoded by encoding, and returns false if nothing is rewritten
+func (rt *urlRewritingRoundTripper) rewriteBody(data []byte, encoding string) ([]byte, bool, error) {
+	if len(encoding) == 0 {
+		rewritten := rt.rewrite(data)
+		return rewritten, !bytes.Equal(rewritten, data), nil
+	}
+
+	// only gzip passes rewritableResponse
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+	decoded, err := ioutil.ReadAll(io.LimitReader(reader, rt.maxBodyBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(decoded)) > rt.maxBodyBytes {
+		// too large to rewrite after decompression
+		return nil, false, nil
+	}
+	rewritten := rt.rewrite(decoded)
+	if bytes.Equal(rewritten, decoded) {
+		return nil, false, nil
+	}
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	if _, err := writer.Write(rewritten); err != nil {
+		return nil, false, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+func (rt *urlRewritingRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.rt
+}
+
+func (rt *urlRewritingRoundTripper) rewrite(data []byte) []byte {
+	bases := [][]byte{}
+	for _, name := range rt.cluster.AllEndpoints() {
+		ep, err := url.Parse(name)
+		if err != nil || len(ep.Host) == 0 {
+			continue
+		}
+		bases = append(bases, []byte(ep.Scheme+"://"+ep.Host))
+	}
+	// longer bases first, so that https://host:6443 is never rewritten as https://host
+	sort.Slice(bases, func(i, j int) bool {
+		return len(bases[i]) > len(bases[j])
+	})
+	for _, base := range bases {
+		if rewritten, ok := replaceURLBase(data, base, rt.externalBase); ok {
+			klog.V(5).Infof("[url rewrite] rewrite %s to %s in response of cluster=%q", base, rt.externalBase, rt.cluster.Cluster)
+			data = rewritten
+		}
+	}
+	return data
+}
+
+// replaceURLBase replaces base in data with replacement where base is followed by the end of url
+// authority, e.g. https://10.0.0.1:6443 in https://10.0.0.1:64430 or https://a.example.com in
+// https://a.example.com.cn is not replaced, and returns false if nothing is replaced
+func replaceURLBase(data, base, replacement []byte) ([]byte, bool) {
+	var out []byte
+	last, replaced := 0, false
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], base)
+		if i < 0 {
+			break
+		}
+		start, end := offset+i, offset+i+len(base)
+		offset = end
+		if end < len(data) && isAuthorityByte(data[end]) {
+			continue
+		}
+		out = append(out, data[last:start]...)
+		out = append(out, replacement...)
+		last, replaced = end, true
+	}
+	if !replaced {
+		return data, false
+	}
+	return append(out, data[last:]...), true
+}
+
+// isAuthorityByte returns true if c may continue the host and port of url
+func isAuthorityByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~%:@[]", c) >= 0
+}
+
+// rewritableResponse returns true if resp is an uncompressed or gzip encoded JSON response within maxBodyBytes
+func rewritableResponse(resp *http.Response, maxBodyBytes int64) bool {
+	if resp.Body == nil || resp.StatusCode == http.StatusSwitchingProtocols || resp.ContentLength > maxBodyBytes {
+		return false
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); len(encoding) > 0 && encoding != "gzip" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type staticRoundTripper struct {
+	contentType     string
+	contentEncoding string
+	etag            string
+	body            string
+}
+
+func (rt *staticRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	header.Set("Content-Type", rt.contentType)
+	if len(rt.contentEncoding) > 0 {
+		header.Set("Content-Encoding", rt.contentEncoding)
+	}
+	if len(rt.etag) > 0 {
+		header.Set("ETag", rt.etag)
+	}
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        header,
+		ContentLength: int64(len(rt.body)),
+		Body:          ioutil.NopCloser(strings.NewReader(rt.body)),
+		Request:       req,
+	}, nil
+}
+
+func Test_urlRewritingRoundTripper(t *testing.T) {
+	cluster := &clusters.ClusterInfo{Cluster: "test", Endpoints: &clusters.EndpointInfoMap{}}
+	cluster.Endpoints.Store("https://10.0.0.1:6443", &clusters.EndpointInfo{Endpoint: "https://10.0.0.1:6443"})
+
+	openapi := `{"servers":[{"url":"https://10.0.0.1:6443/apis/apps/v1"}]}`
+	tests := []struct {
+		name     string
+		rt       *staticRoundTripper
+		maxBytes int64
+		want     string
+	}{
+		{"rewrite json", &staticRoundTripper{contentType: "application/json", body: openapi}, 1024, `{"servers":[{"url":"https://gateway.example.com/apis/apps/v1"}]}`},
+		{"rewrite json with charset", &staticRoundTripper{contentType: "application/json; charset=utf-8", body: openapi}, 1024, `{"servers":[{"url":"https://gateway.example.com/apis/apps/v1"}]}`},
+		{"skip protobuf", &staticRoundTripper{contentType: "application/com.github.proto-openapi.spec.v2@v1.0+protobuf", body: openapi}, 1024, openapi},
+		{"skip deflate", &staticRoundTripper{contentType: "application/json", contentEncoding: "deflate", body: openapi}, 1024, openapi},
+		{"skip large body", &staticRoundTripper{contentType: "application/json", body: openapi}, 10, openapi},
+		{"no upstream url", &staticRoundTripper{contentType: "application/json", body: `{"kind":"Status"}`}, 1024, `{"kind":"Status"}`},
+		{"skip longer port", &staticRoundTripper{contentType: "application/json", body: `{"url":"https://10.0.0.1:64430/api"}`}, 1024, `{"url":"https://10.0.0.1:64430/api"}`},
+		{"skip longer host", &staticRoundTripper{contentType: "application/json", body: `{"url":"https://10.0.0.1:6443.example.com"}`}, 1024, `{"url":"https://10.0.0.1:6443.example.com"}`},
+		{"rewrite at end of string", &staticRoundTripper{contentType: "application/json", body: `{"url":"https://10.0.0.1:6443"}`}, 1024, `{"url":"https://gateway.example.com"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newURLRewritingRoundTripper(tt.rt, cluster, tt.maxBytes, "", "gateway.example.com")
+			req, _ := http.NewRequest(http.MethodGet, "https://10.0.0.1:6443/openapi/v3/apis/apps/v1", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			data, _ := ioutil.ReadAll(resp.Body)
+			if string(data) != tt.want {
+				t.Errorf("RoundTrip() body = %v, want %v", string(data), tt.want)
+			}
+			if resp.ContentLength != int64(len(tt.want)) {
+				t.Errorf("RoundTrip() content length = %v, want %v", resp.ContentLength, len(tt.want))
+			}
+		})
+	}
+}
+
+func Test_URLRewritePolicy_Matches(t *testing.T) {
+	var disabled *URLRewritePolicy
+	if disabled.Matches("/openapi/v2") {
+		t.Errorf("nil policy matches")
+	}
+	policy := &URLRewritePolicy{PathPrefixes: []string{"/openapi/"}, MaxBodyBytes: 1024}
+	if !policy.Matches("/openapi/v3") || policy.Matches("/api/v1/pods") {
+		t.Errorf("Matches() returns unexpected result")
+	}
+}
+
+func gzipped(t *testing.T, data string) string {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func Test_urlRewritingRoundTripper_gzip(t *testing.T) {
+	cluster := &clusters.ClusterInfo{Cluster: "test", Endpoints: &clusters.EndpointInfoMap{}}
+	cluster.Endpoints.Store("https://10.0.0.1:6443", &clusters.EndpointInfo{Endpoint: "https://10.0.0.1:6443"})
+
+	openapi := `{"servers":[{"url":"https://10.0.0.1:6443/apis/apps/v1"}]}`
+	tests := []struct {
+		name     string
+		body     string
+		maxBytes int64
+		want     string
+		wantETag string
+	}{
+		{"rewrite gzip", openapi, 1024, `{"servers":[{"url":"https://gateway.example.com/apis/apps/v1"}]}`, ""},
+		{"skip large decoded body", openapi + strings.Repeat(" ", 1024), 512, openapi + strings.Repeat(" ", 1024), `"upstream"`},
+		{"no upstream url", `{"kind":"Status"}`, 1024, `{"kind":"Status"}`, `"upstream"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &staticRoundTripper{contentType: "application/json", contentEncoding: "gzip", etag: `"upstream"`, body: gzipped(t, tt.body)}
+			rt := newURLRewritingRoundTripper(upstream, cluster, tt.maxBytes, "", "gateway.example.com")
+			req, _ := http.NewRequest(http.MethodGet, "https://10.0.0.1:6443/openapi/v2", nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			reader, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatalf("RoundTrip() body is not gzip encoded: %v", err)
+			}
+			data, _ := ioutil.ReadAll(reader)
+			if string(data) != tt.want {
+				t.Errorf("RoundTrip() body = %v, want %v", string(data), tt.want)
+			}
+			etag := resp.Header.Get("ETag")
+			if len(tt.wantETag) > 0 && etag != tt.wantETag {
+				t.Errorf("RoundTrip() etag = %v, want %v", etag, tt.wantETag)
+			}
+			if len(tt.wantETag) == 0 && (etag == `"upstream"` || len(etag) == 0) {
+				t.Errorf("RoundTrip() etag = %v, want etag of rewritten body", etag)
+			}
+		})
+	}
+}
+
+func Test_replaceURLBase(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+		ok   bool
+	}{
+		{`"https://a.example.com/api" "https://a.example.com"`, `"https://gw/api" "https://gw"`, true},
+		{`"https://a.example.com.cn/api"`, `"https://a.example.com.cn/api"`, false},
+		{`"https://a.example.com:443/api"`, `"https://a.example.com:443/api"`, false},
+		{`https://a.example.com?x https://a.example.comx`, `https://gw?x https://a.example.comx`, true},
+	}
+	for _, tt := range tests {
+		got, ok := replaceURLBase([]byte(tt.data), []byte("https://a.example.com"), []byte("https://gw"))
+		if string(got) != tt.want || ok != tt.ok {
+			t.Errorf("replaceURLBase(%v) = %v, %v, want %v, %v", tt.data, string(got), ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+// ShedClass is a class of requests which are shed together under pressure
+type ShedClass string
+
+const (
+	// ShedClassAnonymous includes requests from anonymous or unauthenticated users
+	ShedClassAnonymous ShedClass = "anonymous"
+	// ShedClassLowPriority includes requests classified into low priority, see PriorityPolicy
+	ShedClassLowPriority ShedClass = "low-priority"
+	// ShedClassRead includes get and list requests
+	ShedClassRead ShedClass = "read"
+	// ShedClassWrite includes mutating requests from users which are not system components
+	ShedClassWrite ShedClass = "write"
+)
+
+var knownShedClasses = []ShedClass{ShedClassAnonymous, ShedClassLowPriority, ShedClassRead, ShedClassWrite}
+
+// LoadSheddingPolicy rejects classes of requests when the inflight requests of a cluster reach
+// a ratio of its maxInflightRequests budget, so that mutating requests from system components
+// are preserved for as long as possible. Classes with lower thresholds are shed first.
+type LoadSheddingPolicy struct {
+	Thresholds map[ShedClass]float64
+	// SystemUsers and SystemGroups are system components whose mutating requests are not in the write
+	// class, e.g. system:kube-controller-manager or system:nodes
+	SystemUsers  sets.String
+	SystemGroups sets.String
+}
+
+// ParseLoadSheddingPolicy parses policy from a list of class=threshold, e.g. anonymous=0.5,read=0.9,
+// threshold is a ratio of maxInflightRequests in (0, 1].
+func ParseLoadSheddingPolicy(values []string) (*LoadSheddingPolicy, error) {
+	policy := &LoadSheddingPolicy{Thresholds: map[ShedClass]float64{}}
+	for _, value := range values {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("missing threshold for load shedding class %q", value)
+		}
+		class := ShedClass(strings.TrimSpace(kv[0]))
+		known := false
+		for _, c := range knownShedClasses {
+			known = known || c == class
+		}
+		if !known {
+			return nil, fmt.Errorf("unrecognized load shedding class %q, must be one of %v", class, knownShedClasses)
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return nil, fmt.Errorf("invalid threshold of %s=%s, must be in (0, 1]", class, kv[1])
+		}
+		policy.Thresholds[class] = threshold
+	}
+	return policy, nil
+}
+
+// Order returns classes in the order they are shed
+func (p *LoadSheddingPolicy) Order() []ShedClass {
+	if p == nil {
+		return nil
+	}
+	classes := []ShedClass{}
+	for c := range p.Thresholds {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if p.Thresholds[classes[i]] == p.Thresholds[classes[j]] {
+			return classes[i] < classes[j]
+		}
+		return p.Thresholds[classes[i]] < p.Thresholds[classes[j]]
+	})
+	return classes
+}
+
+// Shed returns the class that request is shed by at current budget utilization, empty
+// means the request should not be shed.
+func (p *LoadSheddingPolicy) Shed(u user.Info, requestInfo *request.RequestInfo, priority clusters.RequestPriority, utilization float64) ShedClass {
+	if p == nil || len(p.Thresholds) == 0 || utilization <= 0 {
+		return ""
+	}
+	for _, class := range p.Order() {
+		if utilization < p.Thresholds[class] {
+			return ""
+		}
+		if p.matches(class, u, requestInfo, priority) {
+			return class
+		}
+	}
+	return ""
+}
+
+func (p *LoadSheddingPolicy) matches(class ShedClass, u user.Info, requestInfo *request.RequestInfo, priority clusters.RequestPriority) bool {
+	switch class {
+	case ShedClassAnonymous:
+		return isAnonymous(u)
+	case ShedClassLowPriority:
+		return priority == clusters.RequestPriorityLow
+	case ShedClassRead:
+		return isRead(requestInfo)
+	case ShedClassWrite:
+		return !isRead(requestInfo) && !p.isSystemComponent(u)
+	}
+	return false
+}
+
+func isRead(requestInfo *request.RequestInfo) bool {
+	return requestInfo.Verb == "get" || requestInfo.Verb == "list"
+}
+
+func isAnonymous(u user.Info) bool {
+	if u.GetName() == user.Anonymous {
+		return true
+	}
+	for _, g := range u.GetGroups() {
+		if g == user.AllUnauthenticated {
+			return true
+		}
+	}
+	return false
+}
+
+// isSystemComponent returns true if u is one of the configured system components, service accounts
+// are not system components unless they are listed explicitly
+func (p *LoadSheddingPolicy) isSystemComponent(u user.Info) bool {
+	if isAnonymous(u) {
+		return false
+	}
+	return matchUser(u, p.SystemUsers, p.SystemGroups)
+}
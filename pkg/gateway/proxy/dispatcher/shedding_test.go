@@ -0,0 +1,97 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+func TestParseLoadSheddingPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []string
+		wantOrder []ShedClass
+		wantErr   bool
+	}{
+		{"empty", nil, []ShedClass{}, false},
+		{"ordered by threshold", []string{"write=0.95", "anonymous=0.5", "read=0.85"}, []ShedClass{ShedClassAnonymous, ShedClassRead, ShedClassWrite}, false},
+		{"unknown class", []string{"batch=0.5"}, nil, true},
+		{"missing threshold", []string{"read"}, nil, true},
+		{"threshold out of range", []string{"read=1.5"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLoadSheddingPolicy(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLoadSheddingPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.Order(), tt.wantOrder) {
+				t.Errorf("Order() = %v, want %v", got.Order(), tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestLoadSheddingPolicy_Shed(t *testing.T) {
+	policy, err := ParseLoadSheddingPolicy([]string{"anonymous=0.5", "low-priority=0.7", "read=0.8", "write=0.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.SystemUsers = sets.NewString(user.KubeControllerManager)
+	policy.SystemGroups = sets.NewString(user.NodesGroup)
+	anonymous := &user.DefaultInfo{Name: user.Anonymous, Groups: []string{user.AllUnauthenticated}}
+	someone := &user.DefaultInfo{Name: "someone", Groups: []string{user.AllAuthenticated}}
+	kubelet := &user.DefaultInfo{Name: "system:node:n1", Groups: []string{user.NodesGroup}}
+	controllerManager := &user.DefaultInfo{Name: user.KubeControllerManager, Groups: []string{user.AllAuthenticated}}
+	serviceAccount := &user.DefaultInfo{Name: "system:serviceaccount:default:app", Groups: []string{"system:serviceaccounts", user.AllAuthenticated}}
+	get := &request.RequestInfo{IsResourceRequest: true, Verb: "get"}
+	update := &request.RequestInfo{IsResourceRequest: true, Verb: "update"}
+
+	tests := []struct {
+		name        string
+		policy      *LoadSheddingPolicy
+		user        user.Info
+		requestInfo *request.RequestInfo
+		priority    clusters.RequestPriority
+		utilization float64
+		want        ShedClass
+	}{
+		{"nil policy", nil, anonymous, get, clusters.RequestPriorityNormal, 1, ""},
+		{"no pressure", policy, anonymous, get, clusters.RequestPriorityNormal, 0.4, ""},
+		{"anonymous first", policy, anonymous, update, clusters.RequestPriorityNormal, 0.5, ShedClassAnonymous},
+		{"reads preserved", policy, someone, get, clusters.RequestPriorityNormal, 0.6, ""},
+		{"low priority", policy, someone, update, clusters.RequestPriorityLow, 0.7, ShedClassLowPriority},
+		{"reads", policy, kubelet, get, clusters.RequestPriorityNormal, 0.8, ShedClassRead},
+		{"writes preserved", policy, someone, update, clusters.RequestPriorityNormal, 0.85, ""},
+		{"writes", policy, someone, update, clusters.RequestPriorityNormal, 0.9, ShedClassWrite},
+		{"system writes never shed", policy, kubelet, update, clusters.RequestPriorityNormal, 1, ""},
+		{"system user writes never shed", policy, controllerManager, update, clusters.RequestPriorityNormal, 1, ""},
+		{"service account writes", policy, serviceAccount, update, clusters.RequestPriorityNormal, 0.9, ShedClassWrite},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Shed(tt.user, tt.requestInfo, tt.priority, tt.utilization); got != tt.want {
+				t.Errorf("Shed() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -18,22 +18,44 @@ import (
 	"net/http"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/rejection"
 )
 
+const rejectionSubsystem = "dispatcher"
+
+// rejection reasons of dispatcher, see package rejection for their codes
 var (
-	statusReasonNoReadyEndpoints         = "no_ready_endpoints"
-	statusReasonClusterNotBeingProxied   = "cluster_not_being_proxied"
-	statusReasonInvalidRequestContext    = "invalid_request_context"
-	statusReasonCircuitBreaker           = "circuit_breaker"
-	statusReasonRateLimited              = "rate_limited"
-	statusReasonInvalidEndpoint          = "invalid_endpoint"
-	statusReasonUpgradeAwareHandlerError = "upgrade_aware_handler_error"
-	statusReasonReverseProxyError        = "reverse_proxy_error"
+	statusReasonNoReadyEndpoints         = rejection.Register(rejectionSubsystem, "no_ready_endpoints", "NoHealthyEndpoint", "no endpoint of the upstream cluster is ready")
+	statusReasonClusterNotBeingProxied   = rejection.Register(rejectionSubsystem, "cluster_not_being_proxied", "UnknownCluster", "the requested hostname is not an upstream cluster being proxied")
+	statusReasonInvalidRequestContext    = rejection.Register(rejectionSubsystem, "invalid_request_context", "InvalidRequestContext", "request context is missing information set by gateway filters, it is a bug of gateway")
+	statusReasonCircuitBreaker           = rejection.Register(rejectionSubsystem, "circuit_breaker", "ClusterDenied", "all requests to the cluster are denied by feature gate DenyAllRequests")
+	statusReasonRateLimited              = rejection.Register(rejectionSubsystem, "rate_limited", "RateLimited", "the request exceeds the flow control of the upstream cluster")
+	statusReasonInvalidEndpoint          = rejection.Register(rejectionSubsystem, "invalid_endpoint", "InvalidEndpoint", "the picked endpoint is not a valid url")
+	statusReasonUpgradeAwareHandlerError = rejection.Register(rejectionSubsystem, "upgrade_aware_handler_error", "UpstreamError", "proxying the request to upstream failed")
+	statusReasonReverseProxyError        = rejection.Register(rejectionSubsystem, "reverse_proxy_error", "UpstreamUnreachable", "upstream endpoint can not be reached")
+	statusReasonFleetUnsupportedRequest  = rejection.Register(rejectionSubsystem, "fleet_unsupported_request", "FleetUnsupportedRequest", "the request can not be fanned out to fleet members")
+	statusReasonFleetAllMembersFailed    = rejection.Register(rejectionSubsystem, "fleet_all_members_failed", "FleetAllMembersFailed", "the request failed on all fleet members")
+	statusReasonClusterBudgetExhausted   = rejection.Register(rejectionSubsystem, "cluster_budget_exhausted", "ClusterBudgetExhausted", "the resource budget of the upstream cluster is exhausted")
+	statusReasonClusterCeilingExceeded   = rejection.Register(rejectionSubsystem, "cluster_ceiling_exceeded", "ClusterCeilingExceeded", "the aggregate qps ceiling of the upstream cluster is saturated")
+	statusReasonReadRequestBodyFailed    = rejection.Register(rejectionSubsystem, "read_request_body_failed", "RequestBodyUnreadable", "reading request body for retries failed")
+	statusReasonUpstreamHeaderTimeout    = rejection.Register(rejectionSubsystem, "upstream_response_header_timeout", "UpstreamHeaderTimeout", "upstream does not respond headers in time")
+	statusReasonUpstreamInvalidHeaders   = rejection.Register(rejectionSubsystem, "upstream_invalid_response_headers", "UpstreamInvalidHeaders", "upstream responds too large or malformed headers")
+	statusReasonPassthroughNoCredential  = rejection.Register(rejectionSubsystem, "passthrough_no_credential", "CredentialRequired", "the cluster passes through bearer tokens but the request has none")
+	statusReasonLoadShed                 = rejection.Register(rejectionSubsystem, "load_shed", "LoadShed", "the request is shed under pressure of the upstream cluster")
+	statusReasonFaultInjected            = rejection.Register(rejectionSubsystem, "fault_injected", "FaultInjected", "an error is injected into the request by fault injection")
+	statusReasonWatchLimitExceeded       = rejection.Register(rejectionSubsystem, "watch_limit_exceeded", "WatchLimitExceeded", "the user has too many concurrent watches to the upstream cluster")
+	statusReasonShuttingDown             = rejection.Register(rejectionSubsystem, "shutting_down", "ShuttingDown", "gateway is shutting down")
+	statusReasonVirtualClusterForbidden  = rejection.Register(rejectionSubsystem, "virtual_cluster_forbidden", "VirtualClusterForbidden", "the request escapes namespaces of the virtual cluster")
+	statusReasonInvalidSelector          = rejection.Register(rejectionSubsystem, "invalid_selector", "InvalidSelector", "selectors of the request conflict with the virtual cluster")
+	statusReasonProxyPanicked            = rejection.Register(rejectionSubsystem, "proxy_panicked", "ProxyPanicked", "gateway panicked when proxying the request")
+	statusReasonExpressionDenied         = rejection.Register(rejectionSubsystem, "expression_denied", "ExpressionDenied", "the request is denied by an expression rule of the upstream cluster")
+	statusReasonNoRoute                  = rejection.Register(rejectionSubsystem, "no_route", "NoRoute", "the hostname is not an upstream cluster or the request matches no dispatch policy of the cluster")
 )
 
 func captureErrorReason(reason string) bool {
 	switch reason {
-	case statusReasonUpgradeAwareHandlerError, statusReasonReverseProxyError:
+	case statusReasonUpgradeAwareHandlerError, statusReasonReverseProxyError, statusReasonUpstreamInvalidHeaders:
 		return true
 	}
 	return false
@@ -0,0 +1,103 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"golang.org/x/net/http2"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// sides of connection on which a stream is reset
+	streamResetInbound  = "inbound"
+	streamResetUpstream = "upstream"
+
+	// http2 frames that reset streams
+	streamResetFrameRSTStream = "rst_stream"
+	streamResetFrameGoAway    = "goaway"
+
+	// streamResetCodeUnknown is used when the stream is canceled by client but the error code is not visible to handler
+	streamResetCodeUnknown = "unknown"
+)
+
+var (
+	// net/http bundles its own copy of http2 whose error types are not exported, match their messages instead.
+	// e.g. "stream error: stream ID 3; CANCEL" and
+	// "http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug="
+	bundledStreamErrorRegexp = regexp.MustCompile(`stream error: stream ID (\d+); ([A-Z_0-9]+)`)
+	bundledGoAwayErrorRegexp = regexp.MustCompile(`server sent GOAWAY and closed the connection; LastStreamID=(\d+), ErrCode=([A-Z_0-9]+)`)
+)
+
+// streamReset describes a http2 stream reset by RST_STREAM or GOAWAY frame
+type streamReset struct {
+	frame string
+	// streamID is the reset stream for RST_STREAM and the last processed stream for GOAWAY
+	streamID uint32
+	code     string
+}
+
+// parseStreamReset extracts the http2 stream reset from err, it returns false if err is not caused by a reset stream
+func parseStreamReset(err error) (streamReset, bool) {
+	if err == nil {
+		return streamReset{}, false
+	}
+	var streamErr http2.StreamError
+	if errors.As(err, &streamErr) {
+		return streamReset{frame: streamResetFrameRSTStream, streamID: streamErr.StreamID, code: streamErr.Code.String()}, true
+	}
+	var goAwayErr http2.GoAwayError
+	if errors.As(err, &goAwayErr) {
+		return streamReset{frame: streamResetFrameGoAway, streamID: goAwayErr.LastStreamID, code: goAwayErr.ErrCode.String()}, true
+	}
+	msg := err.Error()
+	if m := bundledStreamErrorRegexp.FindStringSubmatch(msg); m != nil {
+		id, _ := strconv.ParseUint(m[1], 10, 32)
+		return streamReset{frame: streamResetFrameRSTStream, streamID: uint32(id), code: m[2]}, true
+	}
+	if m := bundledGoAwayErrorRegexp.FindStringSubmatch(msg); m != nil {
+		id, _ := strconv.ParseUint(m[1], 10, 32)
+		return streamReset{frame: streamResetFrameGoAway, streamID: uint32(id), code: m[2]}, true
+	}
+	return streamReset{}, false
+}
+
+// recordStreamReset records the http2 stream reset which causes the upstream request aborted, it must
+// be called with the request, error and abort reason of ErrorHandler.
+func recordStreamReset(req *http.Request, err error, reason, cluster, host string) {
+	side := streamResetUpstream
+	if reason == abortReasonClientBodyError || reason == abortReasonClientCanceled {
+		side = streamResetInbound
+	}
+	reset, ok := parseStreamReset(err)
+	if !ok {
+		if side != streamResetInbound || req.ProtoMajor != 2 || !errors.Is(req.Context().Err(), context.Canceled) {
+			return
+		}
+		// http2 client canceled the request, it resets the stream but the error code is swallowed by http server
+		reset = streamReset{frame: streamResetFrameRSTStream, code: streamResetCodeUnknown}
+	}
+	metrics.RecordStreamReset(side, cluster, reset.frame, reset.code)
+	klog.V(4).Infof("[stream reset] side=%v frame=%v code=%v streamID=%v method=%v uri=%q remoteAddr=%v endpoint=%v, err: %v",
+		side, reset.frame, reset.code, reset.streamID, req.Method, redact.URI(req.RequestURI), req.RemoteAddr, host, redact.Error(err))
+}
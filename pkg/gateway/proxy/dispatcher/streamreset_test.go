@@ -0,0 +1,68 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/http2"
+)
+
+func Test_parseStreamReset(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   streamReset
+		wantOk bool
+	}{
+		{
+			"x/net stream error",
+			fmt.Errorf("copy response: %w", http2.StreamError{StreamID: 5, Code: http2.ErrCodeCancel}),
+			streamReset{frame: streamResetFrameRSTStream, streamID: 5, code: "CANCEL"},
+			true,
+		},
+		{
+			"x/net goaway error",
+			http2.GoAwayError{LastStreamID: 7, ErrCode: http2.ErrCodeNo},
+			streamReset{frame: streamResetFrameGoAway, streamID: 7, code: "NO_ERROR"},
+			true,
+		},
+		{
+			"bundled stream error",
+			errors.New("stream error: stream ID 3; INTERNAL_ERROR"),
+			streamReset{frame: streamResetFrameRSTStream, streamID: 3, code: "INTERNAL_ERROR"},
+			true,
+		},
+		{
+			"bundled goaway error",
+			errors.New("http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=ENHANCE_YOUR_CALM, debug=\"\""),
+			streamReset{frame: streamResetFrameGoAway, streamID: 1, code: "ENHANCE_YOUR_CALM"},
+			true,
+		},
+		{"other error", http.ErrHandlerTimeout, streamReset{}, false},
+		{"nil", nil, streamReset{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseStreamReset(tt.err)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("parseStreamReset() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
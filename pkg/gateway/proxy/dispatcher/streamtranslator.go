@@ -0,0 +1,363 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	gonet "net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+const (
+	// websocket subprotocols of newer clients, e.g. kubectl v1.30+
+	remoteCommandProtocolV5   = "v5.channel.k8s.io"
+	portForwardTunnelProtocol = "SPDY/3.1+portforward.k8s.io"
+
+	// spdy protocols understood by older upstream apiservers
+	remoteCommandProtocolV4 = "v4.channel.k8s.io"
+	portForwardProtocolV1   = "portforward.k8s.io"
+
+	// channels of websocket channel protocol
+	stdinChannel  byte = 0
+	stdoutChannel byte = 1
+	stderrChannel byte = 2
+	errorChannel  byte = 3
+	resizeChannel byte = 4
+	// closeChannel is added by v5, its message [255, channel] half-closes the channel
+	closeChannel byte = 255
+
+	streamTranslationBufferSize = 32 * 1024
+)
+
+// translatedStreamProtocol returns the websocket subprotocol of req which should be translated to SPDY,
+// i.e. v5 channel protocol of exec and attach, or the SPDY tunnel of port-forward.
+func translatedStreamProtocol(req *http.Request, requestInfo *genericapirequest.RequestInfo) (string, bool) {
+	if !requestInfo.IsResourceRequest || requestInfo.Resource != "pods" || upgradeProtocolOf(req) != "websocket" {
+		return "", false
+	}
+	want := remoteCommandProtocolV5
+	switch requestInfo.Subresource {
+	case "exec", "attach":
+	case "portforward":
+		want = portForwardTunnelProtocol
+	default:
+		return "", false
+	}
+	for _, offered := range req.Header[http.CanonicalHeaderKey("Sec-WebSocket-Protocol")] {
+		for _, p := range strings.Split(offered, ",") {
+			if strings.TrimSpace(p) == want {
+				return want, true
+			}
+		}
+	}
+	return "", false
+}
+
+// streamTranslator serves websocket sessions of exec, attach and port-forward by SPDY sessions to upstream,
+// so newer clients work with upstream apiservers which only understand SPDY for them.
+//
+// Exec and attach are translated channel by channel between websocket messages and SPDY streams, port-forward
+// tunnels SPDY frames in websocket messages, so they are bridged as bytes after upgrading upstream to SPDY.
+type streamTranslator struct {
+	location         *url.URL
+	upgradeTransport proxy.UpgradeRequestRoundTripper
+	responder        proxy.ErrorResponder
+	cluster          string
+	protocol         string
+}
+
+func newStreamTranslator(location *url.URL, upgradeTransport proxy.UpgradeRequestRoundTripper, responder proxy.ErrorResponder, cluster, protocol string) *streamTranslator {
+	return &streamTranslator{
+		location:         location,
+		upgradeTransport: upgradeTransport,
+		responder:        responder,
+		cluster:          cluster,
+		protocol:         protocol,
+	}
+}
+
+func (t *streamTranslator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	upstreamProtocol := remoteCommandProtocolV4
+	if t.protocol == portForwardTunnelProtocol {
+		upstreamProtocol = portForwardProtocolV1
+	}
+	backend, err := t.dialSPDY(w, req, upstreamProtocol)
+	if backend == nil {
+		if err != nil {
+			t.responder.Error(w, req, err)
+		}
+		return
+	}
+	defer backend.Close()
+	metrics.RecordStreamTranslation(t.cluster, t.protocol)
+
+	if t.protocol == portForwardTunnelProtocol {
+		t.serveWebSocket(w, req, func(ws *websocket.Conn) {
+			tunnel(ws, backend)
+		})
+		return
+	}
+
+	conn, err := spdy.NewClientConnection(backend)
+	if err != nil {
+		t.responder.Error(w, req, fmt.Errorf("failed to create spdy connection to upstream: %v", err))
+		return
+	}
+	defer conn.Close()
+	streams, err := createRemoteCommandStreams(conn, req.URL.Query())
+	if err != nil {
+		t.responder.Error(w, req, err)
+		return
+	}
+	t.serveWebSocket(w, req, func(ws *websocket.Conn) {
+		streams.bridge(ws, conn)
+	})
+}
+
+// dialSPDY upgrades a connection to upstream with spdy protocol, it returns nil connection if upstream does
+// not switch protocols, whose response is copied to w.
+func (t *streamTranslator) dialSPDY(w http.ResponseWriter, req *http.Request, protocol string) (gonet.Conn, error) {
+	clone := utilnet.CloneRequest(req)
+	clone.URL = t.location
+	utilnet.AppendForwardedForHeader(clone)
+	for _, h := range []string{"Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions"} {
+		clone.Header.Del(h)
+	}
+	clone.Header.Set(httpstream.HeaderConnection, httpstream.HeaderUpgrade)
+	clone.Header.Set(httpstream.HeaderUpgrade, "SPDY/3.1")
+	clone.Header.Set(httpstream.HeaderProtocolVersion, protocol)
+
+	dialer := proxy.NewUpgradeAwareHandler(t.location, nil, false, true, t.responder)
+	dialer.UpgradeTransport = t.upgradeTransport
+	conn, err := dialer.DialForUpgrade(clone)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, clone)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read upgrade response of upstream: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer conn.Close()
+		defer resp.Body.Close()
+		if resp.StatusCode < 400 {
+			return nil, fmt.Errorf("invalid upgrade response: status code %d", resp.StatusCode)
+		}
+		// echo errors of upstream, e.g. forbidden or not found, before switching protocols with client
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		return nil, nil
+	}
+	if got := resp.Header.Get(httpstream.HeaderProtocolVersion); got != protocol {
+		conn.Close()
+		return nil, fmt.Errorf("upstream switched to protocol %q instead of %q", got, protocol)
+	}
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+func (t *streamTranslator) serveWebSocket(w http.ResponseWriter, req *http.Request, handler func(ws *websocket.Conn)) {
+	websocket.Server{
+		Handshake: func(config *websocket.Config, _ *http.Request) error {
+			// origin is not checked like apiservers, since clients are authenticated
+			config.Protocol = []string{t.protocol}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+			handler(ws)
+		},
+	}.ServeHTTP(w, req)
+}
+
+// bufferedConn reads bytes buffered when reading upgrade response first
+type bufferedConn struct {
+	gonet.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// tunnel copies bytes between websocket messages and backend until one side is closed
+func tunnel(ws *websocket.Conn, backend gonet.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(backend, ws)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(ws, backend)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// remoteCommandStreams are the spdy streams of an exec or attach session, nil if not requested
+type remoteCommandStreams struct {
+	errorStream httpstream.Stream
+	stdin       httpstream.Stream
+	stdout      httpstream.Stream
+	stderr      httpstream.Stream
+	resize      httpstream.Stream
+}
+
+func isTrue(query url.Values, key string) bool {
+	v := query.Get(key)
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+// createRemoteCommandStreams creates streams in the same order as client-go does for v4 protocol
+func createRemoteCommandStreams(conn httpstream.Connection, query url.Values) (*remoteCommandStreams, error) {
+	tty := isTrue(query, "tty")
+	s := &remoteCommandStreams{}
+	streams := []struct {
+		stream    *httpstream.Stream
+		typ       string
+		requested bool
+	}{
+		{&s.errorStream, corev1.StreamTypeError, true},
+		{&s.stdin, corev1.StreamTypeStdin, isTrue(query, "stdin")},
+		{&s.stdout, corev1.StreamTypeStdout, isTrue(query, "stdout")},
+		{&s.stderr, corev1.StreamTypeStderr, isTrue(query, "stderr") && !tty},
+		{&s.resize, corev1.StreamTypeResize, tty},
+	}
+	for _, stream := range streams {
+		if !stream.requested {
+			continue
+		}
+		headers := http.Header{}
+		headers.Set(corev1.StreamType, stream.typ)
+		created, err := conn.CreateStream(headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s stream to upstream: %v", stream.typ, err)
+		}
+		*stream.stream = created
+	}
+	return s, nil
+}
+
+// bridge copies data between websocket channels and spdy streams until the session ends, i.e. upstream
+// closes all output streams, either side closes the connection.
+func (s *remoteCommandStreams) bridge(ws *websocket.Conn, conn httpstream.Connection) {
+	var outputs sync.WaitGroup
+	for _, out := range []struct {
+		channel byte
+		stream  httpstream.Stream
+	}{
+		{stdoutChannel, s.stdout},
+		{stderrChannel, s.stderr},
+		{errorChannel, s.errorStream},
+	} {
+		if out.stream == nil {
+			continue
+		}
+		outputs.Add(1)
+		go func(channel byte, stream httpstream.Stream) {
+			defer outputs.Done()
+			copyToChannel(ws, channel, stream)
+		}(out.channel, out.stream)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		outputs.Wait()
+		close(finished)
+	}()
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		s.receive(ws)
+	}()
+
+	select {
+	case <-finished:
+	case <-received:
+	case <-conn.CloseChan():
+	}
+}
+
+// copyToChannel sends data read from stream as messages of channel
+func copyToChannel(ws *websocket.Conn, channel byte, stream io.Reader) {
+	buf := make([]byte, streamTranslationBufferSize+1)
+	buf[0] = channel
+	for {
+		n, err := stream.Read(buf[1:])
+		if n > 0 {
+			if sendErr := websocket.Message.Send(ws, buf[:n+1]); sendErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				klog.V(2).Infof("[stream translator] failed to read channel %d from upstream: %v", channel, err)
+			}
+			return
+		}
+	}
+}
+
+// receive writes messages from client to stdin and resize streams until client closes the connection
+func (s *remoteCommandStreams) receive(ws *websocket.Conn) {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var err error
+		switch data[0] {
+		case stdinChannel:
+			if s.stdin != nil {
+				_, err = s.stdin.Write(data[1:])
+			}
+		case resizeChannel:
+			if s.resize != nil {
+				_, err = s.resize.Write(data[1:])
+			}
+		case closeChannel:
+			if len(data) > 1 && data[1] == stdinChannel && s.stdin != nil {
+				err = s.stdin.Close()
+			}
+		}
+		if err != nil {
+			klog.V(2).Infof("[stream translator] failed to write channel %d to upstream: %v", data[0], err)
+			return
+		}
+	}
+}
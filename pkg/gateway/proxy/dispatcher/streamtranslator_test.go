@@ -0,0 +1,181 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestTranslatedStreamProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		upgrade     string
+		protocols   string
+		subresource string
+		want        string
+	}{
+		{"exec v5", "websocket", "v5.channel.k8s.io, v4.channel.k8s.io", "exec", remoteCommandProtocolV5},
+		{"attach v5", "websocket", "v5.channel.k8s.io", "attach", remoteCommandProtocolV5},
+		{"port-forward tunnel", "websocket", "SPDY/3.1+portforward.k8s.io", "portforward", portForwardTunnelProtocol},
+		{"exec v4 passes through", "websocket", "v4.channel.k8s.io", "exec", ""},
+		{"spdy passes through", "SPDY/3.1", "", "exec", ""},
+		{"log", "websocket", "v5.channel.k8s.io", "log", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/default/pods/p/"+tt.subresource, nil)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", tt.upgrade)
+			req.Header.Set("Sec-WebSocket-Protocol", tt.protocols)
+			info := &genericapirequest.RequestInfo{IsResourceRequest: true, Resource: "pods", Subresource: tt.subresource}
+			if got, _ := translatedStreamProtocol(req, info); got != tt.want {
+				t.Errorf("translatedStreamProtocol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newSPDYExecServer returns an upstream which echoes stdin of exec to stdout over spdy v4 protocol
+func newSPDYExecServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(httpstream.HeaderProtocolVersion) != remoteCommandProtocolV4 {
+			http.Error(w, "unsupported protocol", http.StatusForbidden)
+			return
+		}
+		streams := make(chan httpstream.Stream, 3)
+		w.Header().Set(httpstream.HeaderProtocolVersion, remoteCommandProtocolV4)
+		conn := spdy.NewResponseUpgrader().UpgradeResponse(w, r, func(stream httpstream.Stream, replySent <-chan struct{}) error {
+			streams <- stream
+			return nil
+		})
+		if conn == nil {
+			return
+		}
+		defer conn.Close()
+
+		byType := map[string]httpstream.Stream{}
+		for len(byType) < 3 {
+			select {
+			case s := <-streams:
+				byType[s.Headers().Get(corev1.StreamType)] = s
+			case <-time.After(5 * time.Second):
+				t.Errorf("timeout waiting for streams, got %v", len(byType))
+				return
+			}
+		}
+		io.Copy(byType[corev1.StreamTypeStdout], byType[corev1.StreamTypeStdin]) // nolint
+		byType[corev1.StreamTypeError].Write([]byte(`{"status":"Success"}`))     // nolint
+		byType[corev1.StreamTypeStdout].Close()                                  // nolint
+		byType[corev1.StreamTypeError].Close()                                   // nolint
+		<-conn.CloseChan()
+	}))
+}
+
+func TestStreamTranslator_exec(t *testing.T) {
+	upstream := newSPDYExecServer(t)
+	defer upstream.Close()
+
+	location, _ := url.Parse(upstream.URL + "/api/v1/namespaces/default/pods/p/exec")
+	gateway := httptest.NewServer(newStreamTranslator(location, nil, &countingResponder{}, "test", remoteCommandProtocolV5))
+	defer gateway.Close()
+
+	config, err := websocket.NewConfig(strings.Replace(gateway.URL, "http", "ws", 1)+"/api/v1/namespaces/default/pods/p/exec?stdin=true&stdout=true", "http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Protocol = []string{remoteCommandProtocolV5}
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer ws.Close()
+	if got := ws.Config().Protocol; len(got) != 1 || got[0] != remoteCommandProtocolV5 {
+		t.Errorf("negotiated protocol = %v, want %v", got, remoteCommandProtocolV5)
+	}
+
+	if err := websocket.Message.Send(ws, append([]byte{stdinChannel}, "hello"...)); err != nil {
+		t.Fatal(err)
+	}
+	// half-close stdin by v5 close signal, so upstream finishes the command
+	if err := websocket.Message.Send(ws, []byte{closeChannel, stdinChannel}); err != nil {
+		t.Fatal(err)
+	}
+
+	ws.SetDeadline(time.Now().Add(5 * time.Second)) // nolint
+	var stdout, status bytes.Buffer
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			break
+		}
+		switch data[0] {
+		case stdoutChannel:
+			stdout.Write(data[1:])
+		case errorChannel:
+			status.Write(data[1:])
+		}
+	}
+	if stdout.String() != "hello" {
+		t.Errorf("stdout = %q, want hello", stdout.String())
+	}
+	if status.String() != `{"status":"Success"}` {
+		t.Errorf("status = %q, want success", status.String())
+	}
+}
+
+func TestStreamTranslator_upstreamRejected(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer upstream.Close()
+
+	location, _ := url.Parse(upstream.URL + "/api/v1/namespaces/default/pods/p/exec")
+	responder := &countingResponder{}
+	gateway := httptest.NewServer(newStreamTranslator(location, nil, responder, "test", remoteCommandProtocolV5))
+	defer gateway.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/api/v1/namespaces/default/pods/p/exec?stdout=true", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Protocol", remoteCommandProtocolV5)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "forbidden") {
+		t.Errorf("response = %v %q, want upstream 403", resp.StatusCode, body)
+	}
+	if responder.calls != 0 {
+		t.Errorf("responder is called %d times, want upstream response echoed", responder.calls)
+	}
+}
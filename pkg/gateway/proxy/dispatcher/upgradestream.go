@@ -0,0 +1,156 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"fmt"
+	gonet "net"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+	"github.com/kubewharf/kubegateway/pkg/gateway/net"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+)
+
+const (
+	// upstream responded the upgrade request with an error instead of switching protocols
+	upgradeHandshakeUpstreamRejected = "upstream_rejected"
+)
+
+// upgradeProtocolOf returns the upgrade protocol of request used in metrics, e.g. spdy, websocket
+func upgradeProtocolOf(req *http.Request) string {
+	upgrade := strings.ToLower(req.Header.Get("Upgrade"))
+	switch {
+	case strings.HasPrefix(upgrade, "spdy"):
+		return "spdy"
+	case strings.HasPrefix(upgrade, "websocket"):
+		return "websocket"
+	}
+	return "other"
+}
+
+// upgradeStream classifies failures of an upgrade request, e.g. exec, attach and port-forward, into
+// handshake failures before switching protocols and terminations of the upgraded stream, which are
+// upstream resets, client disconnects or normal closes.
+type upgradeStream struct {
+	responder proxy.ErrorResponder
+	observer  *net.StreamObserver
+	cluster   string
+	endpoint  string
+	protocol  string
+	start     time.Time
+	// the error responded before switching protocols
+	handshakeErr error
+}
+
+func newUpgradeStream(req *http.Request, responder proxy.ErrorResponder, cluster, endpoint string) *upgradeStream {
+	return &upgradeStream{
+		responder: responder,
+		observer:  net.NewStreamObserver(),
+		cluster:   cluster,
+		endpoint:  endpoint,
+		protocol:  upgradeProtocolOf(req),
+		start:     time.Now(),
+	}
+}
+
+// WithContext returns a shallow copy of req whose upstream connection is observed by the stream
+func (s *upgradeStream) WithContext(req *http.Request) *http.Request {
+	return req.WithContext(net.WithStreamObserver(req.Context(), s.observer))
+}
+
+// Wrap returns a response writer whose hijacked client connection is observed by the stream
+func (s *upgradeStream) Wrap(w http.ResponseWriter) http.ResponseWriter {
+	return &upgradeStreamWriter{ResponseWriter: w, observer: s.observer}
+}
+
+// implements k8s.io/apimachinery/pkg/util/proxy.ErrorResponder interface
+func (s *upgradeStream) Error(w http.ResponseWriter, req *http.Request, err error) {
+	if s.handshakeErr == nil {
+		s.handshakeErr = err
+	}
+	s.responder.Error(w, req, err)
+}
+
+// Finish records how the upgrade request ended, it must be called after proxy handler returned
+func (s *upgradeStream) Finish() {
+	status := s.observer.Status()
+	switch {
+	case s.handshakeErr != nil:
+		reason := string(errclass.ClassifyProxyError(s.handshakeErr))
+		metrics.RecordUpgradeHandshakeFailure(s.cluster, s.protocol, reason)
+		klog.V(2).Infof("[upgrade stream] handshake failed: cluster=%q endpoint=%v protocol=%v reason=%v err: %v", s.cluster, s.endpoint, s.protocol, reason, redact.Error(s.handshakeErr))
+	case status != http.StatusSwitchingProtocols:
+		metrics.RecordUpgradeHandshakeFailure(s.cluster, s.protocol, upgradeHandshakeUpstreamRejected)
+		klog.V(2).Infof("[upgrade stream] handshake rejected by upstream: cluster=%q endpoint=%v protocol=%v status=%v", s.cluster, s.endpoint, s.protocol, status)
+	default:
+		reason, err := s.observer.End()
+		metrics.RecordUpgradeStreamTermination(s.cluster, s.protocol, reason)
+		message := fmt.Sprintf("[upgrade stream] terminated: cluster=%q endpoint=%v protocol=%v reason=%v duration=%v err: %v", s.cluster, s.endpoint, s.protocol, reason, time.Since(s.start), redact.Error(err))
+		switch reason {
+		case net.StreamEndUpstreamReset:
+			klog.Error(message)
+		case net.StreamEndClientDisconnect, net.StreamEndGatewayClosed:
+			klog.V(2).Info(message)
+		default:
+			klog.V(4).Info(message)
+		}
+	}
+}
+
+// upgradeStreamWriter observes the hijacked client connection of an upgrade request. It always
+// implements http.Flusher, http.CloseNotifier and http.Hijacker, so responsewriter.WrapForHTTP1Or2
+// hijacks connections through it.
+type upgradeStreamWriter struct {
+	http.ResponseWriter
+	observer *net.StreamObserver
+}
+
+func (w *upgradeStreamWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *upgradeStreamWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *upgradeStreamWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *upgradeStreamWriter) Hijack() (gonet.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.observer.WrapClient(conn), brw, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apiserver/pkg/authentication/user"
+	genericapirequest "k8s.io/apiserver/pkg/endpoints/request"
+
+	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+	"github.com/kubewharf/kubegateway/pkg/gateway/testing/fakeupstream"
+)
+
+const fakePods = "/api/v1/namespaces/default/pods"
+
+// newFakeUpstreamManager returns a manager with cluster proxying all requests to upstream
+func newFakeUpstreamManager(t *testing.T, cluster string, upstream *fakeupstream.Server) clusters.Manager {
+	manager := clusters.NewManager()
+	manager.Add(newFakeUpstreamCluster(t, cluster, nil, upstream))
+	return manager
+}
+
+// newFakeUpstreamCluster returns a ready cluster with annotations proxying all requests to upstream
+func newFakeUpstreamCluster(t *testing.T, cluster string, annotations map[string]string, upstream *fakeupstream.Server) *clusters.ClusterInfo {
+	config := upstream.RESTConfig()
+	info, err := clusters.CreateClusterInfo(&proxyv1alpha1.UpstreamCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: cluster, Annotations: annotations},
+		Spec: proxyv1alpha1.UpstreamClusterSpec{
+			Servers: []proxyv1alpha1.UpstreamClusterServer{{Endpoint: config.Host}},
+			ClientConfig: proxyv1alpha1.ClientConfig{
+				CAData:      config.CAData,
+				BearerToken: []byte("token"),
+			},
+			DispatchPolicies: []proxyv1alpha1.DispatchPolicy{{
+				Rules: []proxyv1alpha1.DispatchPolicyRule{{
+					Verbs:           []string{"*"},
+					APIGroups:       []string{"*"},
+					Resources:       []string{"*"},
+					NonResourceURLs: []string{"*"},
+				}},
+			}},
+		},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("CreateClusterInfo(, nil) error = %v", err)
+	}
+	endpoint, _ := info.Endpoints.Load(config.Host)
+	endpoint.UpdateStatus(true, "", "")
+	return info
+}
+
+func newFakeUpstreamRequest(hostname string, requestInfo *genericapirequest.RequestInfo) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "https://"+hostname+requestInfo.Path, nil)
+	ctx := genericapirequest.WithUser(req.Context(), &user.DefaultInfo{Name: "alice", Groups: []string{user.AllAuthenticated}})
+	ctx = genericapirequest.WithRequestInfo(ctx, requestInfo)
+	ctx = request.WithExtraReqeustInfo(ctx, &request.ExtraRequestInfo{Hostname: hostname})
+	ctx = request.WithProxyInfo(ctx, request.NewProxyInfo())
+	return req.WithContext(ctx)
+}
+
+func TestDispatcher_FakeUpstream(t *testing.T) {
+	upstream := fakeupstream.NewServer()
+	defer upstream.Close()
+	upstream.AddObject(fakePods, fakeupstream.Object{
+		"kind":       "Pod",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{"name": "a", "namespace": "default"},
+	})
+	upstream.InjectFault(fakeupstream.Fault{
+		Match:      func(req *http.Request) bool { return strings.HasSuffix(req.URL.Path, "/pods/broken") },
+		StatusCode: http.StatusServiceUnavailable,
+	})
+	// upstream clusters are verified by their names, which must match the serving certificate of httptest
+	manager := newFakeUpstreamManager(t, "example.com", upstream)
+	defer manager.DeleteAll()
+	d := NewDispatcher(manager, Config{})
+
+	tests := []struct {
+		name        string
+		requestInfo *genericapirequest.RequestInfo
+		wantCode    int
+		wantKind    string
+	}{
+		{
+			name:        "list",
+			requestInfo: &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "list", APIVersion: "v1", Namespace: "default", Resource: "pods", Path: fakePods},
+			wantCode:    http.StatusOK,
+			wantKind:    "PodList",
+		},
+		{
+			name:        "get",
+			requestInfo: &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "a", Path: fakePods + "/a"},
+			wantCode:    http.StatusOK,
+			wantKind:    "Pod",
+		},
+		{
+			name:        "upstream error",
+			requestInfo: &genericapirequest.RequestInfo{IsResourceRequest: true, Verb: "get", APIVersion: "v1", Namespace: "default", Resource: "pods", Name: "broken", Path: fakePods + "/broken"},
+			wantCode:    http.StatusServiceUnavailable,
+			wantKind:    "Status",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			d.ServeHTTP(recorder, newFakeUpstreamRequest("example.com", tt.requestInfo))
+
+			obj := fakeupstream.Object{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &obj); err != nil {
+				t.Fatalf("failed to decode response %q: %v", recorder.Body.String(), err)
+			}
+			if recorder.Code != tt.wantCode || obj["kind"] != tt.wantKind {
+				t.Errorf("response = %d %v, want %d %v", recorder.Code, obj["kind"], tt.wantCode, tt.wantKind)
+			}
+		})
+	}
+
+	found := false
+	for _, r := range upstream.Requests() {
+		found = found || r == http.MethodGet+" "+fakePods
+	}
+	if !found {
+		t.Errorf("list is not proxied to fake upstream, requests: %v", upstream.Requests())
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxWatchEventBytes is the maximum size of a watch event buffered to find its resourceVersion,
+// resourceVersion of larger events split across writes is not tracked
+const maxWatchEventBytes = 1 << 20
+
+// watchResumeWriter tracks resourceVersion of JSON watch events sent to client, so that a watch
+// closed by gateway, e.g. because its upstream endpoint is stopped, can be ended with a BOOKMARK event
+// carrying the last known resourceVersion before the end of stream. Client reflectors re-watch from
+// the resourceVersion of the last bookmark when a watch ends, while a 410 Expired event would make
+// them relist.
+//
+// It always implements http.Flusher, http.CloseNotifier and http.Hijacker, so responsewriter.WrapForHTTP1Or2
+// keeps these capabilities of the wrapped writer.
+type watchResumeWriter struct {
+	http.ResponseWriter
+
+	status int
+	// false if the watch is not encoded in JSON, e.g. protobuf
+	json bool
+	// bookmarks is true if client allows bookmark events
+	bookmarks bool
+	// the last known resourceVersion, initialized with the resourceVersion requested by client
+	resourceVersion string
+	// kind and apiVersion of watched objects, bookmark objects must have them to be decoded by clients
+	kind       string
+	apiVersion string
+	// an incomplete event sent to client, no more events can be appended after it
+	partial  []byte
+	overflow bool
+}
+
+func newWatchResumeWriter(w http.ResponseWriter, req *http.Request) *watchResumeWriter {
+	return &watchResumeWriter{
+		ResponseWriter:  w,
+		json:            !strings.Contains(req.Header.Get("Accept"), "protobuf"),
+		bookmarks:       req.URL.Query().Get("allowWatchBookmarks") == "true",
+		resourceVersion: req.URL.Query().Get("resourceVersion"),
+	}
+}
+
+func (w *watchResumeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *watchResumeWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.json = mediaType == runtime.ContentTypeJSON
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *watchResumeWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	if w.json && w.status == http.StatusOK {
+		w.observe(p[:n])
+	}
+	return n, err
+}
+
+// observe finds resourceVersion of newline delimited JSON watch events
+func (w *watchResumeWriter) observe(p []byte) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.appendPartial(p)
+			return
+		}
+		line := p[:i]
+		if len(w.partial) > 0 || w.overflow {
+			w.appendPartial(line)
+			line = w.partial
+		}
+		if !w.overflow {
+			w.parse(line)
+		}
+		w.partial = w.partial[:0]
+		w.overflow = false
+		p = p[i+1:]
+	}
+}
+
+func (w *watchResumeWriter) appendPartial(p []byte) {
+	if w.overflow {
+		return
+	}
+	if len(w.partial)+len(p) > maxWatchEventBytes {
+		// keep a non-empty partial to remember that an incomplete event is sent
+		w.partial = append(w.partial[:0], '{')
+		w.overflow = true
+		return
+	}
+	w.partial = append(w.partial, p...)
+}
+
+func (w *watchResumeWriter) parse(line []byte) {
+	var event struct {
+		Type   watch.EventType `json:"type"`
+		Object struct {
+			Kind       string `json:"kind"`
+			APIVersion string `json:"apiVersion"`
+			Metadata   struct {
+				ResourceVersion string `json:"resourceVersion"`
+			} `json:"metadata"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil || event.Type == watch.Error {
+		return
+	}
+	if rv := event.Object.Metadata.ResourceVersion; len(rv) > 0 {
+		w.resourceVersion = rv
+	}
+	if len(event.Object.Kind) > 0 {
+		w.kind, w.apiVersion = event.Object.Kind, event.Object.APIVersion
+	}
+}
+
+// WriteBookmark writes a BOOKMARK event of the last known resourceVersion before the watch ends,
+// it returns false if the event can not be sent, e.g. client does not allow bookmarks, the watch is
+// not encoded in JSON, no event is sent yet or an incomplete event has been sent.
+func (w *watchResumeWriter) WriteBookmark() bool {
+	if w.status != http.StatusOK || !w.json || len(w.partial) > 0 {
+		return false
+	}
+	if !w.bookmarks || len(w.kind) == 0 || len(w.resourceVersion) == 0 {
+		return false
+	}
+	object, err := json.Marshal(&metav1.PartialObjectMetadata{
+		TypeMeta:   metav1.TypeMeta{Kind: w.kind, APIVersion: w.apiVersion},
+		ObjectMeta: metav1.ObjectMeta{ResourceVersion: w.resourceVersion},
+	})
+	if err != nil {
+		return false
+	}
+	data, err := json.Marshal(&metav1.WatchEvent{Type: string(watch.Bookmark), Object: runtime.RawExtension{Raw: object}})
+	if err != nil {
+		return false
+	}
+	if _, err := w.ResponseWriter.Write(append(data, '\n')); err != nil {
+		return false
+	}
+	w.Flush()
+	return true
+}
+
+func (w *watchResumeWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *watchResumeWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *watchResumeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dispatcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func lastWatchEvent(t *testing.T, body []byte) (string, *metav1.PartialObjectMetadata) {
+	lines := bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+	var event struct {
+		Type   string          `json:"type"`
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(lines[len(lines)-1], &event); err != nil {
+		t.Fatalf("failed to decode watch event: %v", err)
+	}
+	object := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(event.Object, object); err != nil {
+		t.Fatalf("failed to decode object: %v", err)
+	}
+	return event.Type, object
+}
+
+func Test_watchResumeWriter(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		accept      string
+		contentType string
+		writes      []string
+		wantOK      bool
+		wantRV      string
+	}{
+		{
+			name:        "events in one write",
+			contentType: "application/json",
+			writes:      []string{`{"type":"ADDED","object":{"kind":"Pod","apiVersion":"v1","metadata":{"resourceVersion":"10"}}}` + "\n" + `{"type":"MODIFIED","object":{"kind":"Pod","apiVersion":"v1","metadata":{"resourceVersion":"12"}}}` + "\n"},
+			wantOK:      true,
+			wantRV:      "12",
+		},
+		{
+			name:        "event split across writes",
+			contentType: "application/json",
+			writes:      []string{`{"type":"ADDED","object":{"kind":"Pod","apiVersion":"v1","meta`, `data":{"resourceVersion":"15"}}}` + "\n"},
+			wantOK:      true,
+			wantRV:      "15",
+		},
+		{
+			name:        "bookmark",
+			contentType: "application/json",
+			writes:      []string{`{"type":"BOOKMARK","object":{"kind":"Pod","apiVersion":"v1","metadata":{"resourceVersion":"20"}}}` + "\n"},
+			wantOK:      true,
+			wantRV:      "20",
+		},
+		{
+			name:        "bookmarks not allowed",
+			query:       "watch=true&resourceVersion=5",
+			contentType: "application/json",
+			writes:      []string{`{"type":"ADDED","object":{"kind":"Pod","apiVersion":"v1","metadata":{"resourceVersion":"10"}}}` + "\n"},
+			wantOK:      false,
+		},
+		{
+			name:        "kind unknown without events",
+			contentType: "application/json",
+			wantOK:      false,
+		},
+		{
+			name:   "nothing written",
+			wantOK: false,
+		},
+		{
+			name:        "incomplete event",
+			contentType: "application/json",
+			writes:      []string{`{"type":"ADDED","object":{"meta`},
+			wantOK:      false,
+		},
+		{
+			name:        "protobuf",
+			accept:      "application/vnd.kubernetes.protobuf;stream=watch",
+			contentType: "application/vnd.kubernetes.protobuf;stream=watch",
+			writes:      []string{"k8s\x00"},
+			wantOK:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			if len(query) == 0 {
+				query = "watch=true&resourceVersion=5&allowWatchBookmarks=true"
+			}
+			req := httptest.NewRequest("GET", "/api/v1/pods?"+query, nil)
+			if len(tt.accept) > 0 {
+				req.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+			w := newWatchResumeWriter(recorder, req)
+			if len(tt.contentType) > 0 {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(http.StatusOK)
+			}
+			for _, data := range tt.writes {
+				w.Write([]byte(data)) //nolint
+			}
+			if got := w.WriteBookmark(); got != tt.wantOK {
+				t.Fatalf("WriteBookmark() = %v, want %v", got, tt.wantOK)
+			}
+			if !tt.wantOK {
+				return
+			}
+			eventType, object := lastWatchEvent(t, recorder.Body.Bytes())
+			if eventType != "BOOKMARK" {
+				t.Errorf("WriteBookmark() event type = %v, want BOOKMARK", eventType)
+			}
+			if object.Kind != "Pod" || object.APIVersion != "v1" {
+				t.Errorf("WriteBookmark() object = %v %v, want v1 Pod", object.APIVersion, object.Kind)
+			}
+			if object.ResourceVersion != tt.wantRV {
+				t.Errorf("WriteBookmark() resourceVersion = %v, want %v", object.ResourceVersion, tt.wantRV)
+			}
+		})
+	}
+}
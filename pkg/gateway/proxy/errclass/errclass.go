@@ -0,0 +1,115 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errclass classifies errors of proxying requests to upstream clusters, so that retries,
+// metrics, logs and error responses of dispatcher tell failures apart consistently instead of
+// matching error messages in each place.
+package errclass
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+// Class is a coarse-grained class of proxy errors, it is used in metric labels, logs and status causes
+type Class string
+
+const (
+	None                   Class = ""
+	ConnectionRefused      Class = "connection_refused"
+	Timeout                Class = "timeout"
+	ResponseHeaderTimeout  Class = "response_header_timeout"
+	ResponseHeaderTooLarge Class = "response_header_too_large"
+	MalformedHeader        Class = "malformed_response_header"
+	// Canceled means the request is canceled or the client is disconnected
+	Canceled       Class = "canceled"
+	EOF            Class = "eof"
+	Goaway         Class = "goaway"
+	DialBudget     Class = "dial_budget_exhausted"
+	UpstreamStatus Class = "upstream_status"
+	Unknown        Class = "unknown"
+)
+
+// net/http bundles its own copy of http2 whose error values are not exported, match their messages instead.
+const (
+	// http2 server returns it from reading request body after client resets the stream or closes the connection
+	clientDisconnectedMessage = "client disconnected"
+	// http2 transport returns it after upstream sent GOAWAY, e.g. when upstream is shutting down
+	goawayMessage = "http2: server sent GOAWAY"
+)
+
+// ClassifyProxyError returns the class of err from proxying a request to upstream, None if err is nil
+func ClassifyProxyError(err error) Class {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return None
+	case errors.Is(err, clusters.ErrTooManyPendingDials):
+		return DialBudget
+	case errors.Is(err, clusters.ErrResponseHeaderTimeout):
+		return ResponseHeaderTimeout
+	case errors.Is(err, clusters.ErrResponseHeaderTooLarge):
+		return ResponseHeaderTooLarge
+	case errors.Is(err, clusters.ErrMalformedResponseHeader):
+		return MalformedHeader
+	case errors.Is(err, context.Canceled), strings.Contains(err.Error(), clientDisconnectedMessage):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded), os.IsTimeout(err), errors.As(err, &netErr) && netErr.Timeout():
+		return Timeout
+	case utilnet.IsConnectionRefused(err):
+		return ConnectionRefused
+	case strings.Contains(err.Error(), goawayMessage):
+		// it is also a probable EOF
+		return Goaway
+	case utilnet.IsProbableEOF(err):
+		return EOF
+	}
+	if _, ok := err.(apierrors.APIStatus); ok {
+		return UpstreamStatus
+	}
+	return Unknown
+}
+
+// IsConnectionSetupError returns true if the request is never sent to upstream because of err,
+// so it can be sent to another endpoint even if it is not idempotent.
+func IsConnectionSetupError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch ClassifyProxyError(err) {
+	case DialBudget, ConnectionRefused:
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// ClientGone returns true if the error is caused by the downstream client instead of upstream
+func (c Class) ClientGone() bool {
+	return c == Canceled
+}
+
+// TriggersHealthCheck returns true if the endpoint should be health checked immediately
+// instead of waiting for the next period
+func (c Class) TriggersHealthCheck() bool {
+	return c == ConnectionRefused || c == ResponseHeaderTimeout
+}
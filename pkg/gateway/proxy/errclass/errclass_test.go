@@ -0,0 +1,78 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errclass
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+func TestClassifyProxyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"nil", nil, None},
+		{"dial budget", errors.WithMessage(clusters.ErrTooManyPendingDials, "cluster(a)"), DialBudget},
+		{"header timeout", fmt.Errorf("proxy: %w", clusters.ErrResponseHeaderTimeout), ResponseHeaderTimeout},
+		{"canceled", fmt.Errorf("proxy: %w", context.Canceled), Canceled},
+		{"client disconnected", fmt.Errorf("client disconnected"), Canceled},
+		{"deadline", context.DeadlineExceeded, Timeout},
+		{"connection refused", syscall.ECONNREFUSED, ConnectionRefused},
+		{"eof", io.EOF, EOF},
+		{"goaway", fmt.Errorf("http2: server sent GOAWAY and closed the connection"), Goaway},
+		{"status", apierrors.NewServiceUnavailable("unavailable"), UpstreamStatus},
+		{"unknown", fmt.Errorf("unknown"), Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyProxyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyProxyError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsConnectionSetupError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"dial budget", errors.WithMessage(clusters.ErrTooManyPendingDials, "cluster(a)"), true},
+		{"connection refused", syscall.ECONNREFUSED, true},
+		{"dial", &net.OpError{Op: "dial", Err: fmt.Errorf("no route to host")}, true},
+		{"read", &net.OpError{Op: "read", Err: io.ErrUnexpectedEOF}, false},
+		{"header timeout", clusters.ErrResponseHeaderTimeout, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConnectionSetupError(tt.err); got != tt.want {
+				t.Errorf("IsConnectionSetupError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+// serveH2C serves handler by cleartext HTTP/2 on address until stopCh is closed. HTTP/1.x requests
+// without h2c upgrade are rejected, so clients inside a trusted network can not silently fall back
+// to HTTP/1.1 and open a connection per request.
+func serveH2C(address string, handler http.Handler, stopCh <-chan struct{}) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		ln.Close() //nolint
+		return err
+	}
+	server := &http.Server{
+		Handler: h2c.NewHandler(requireHTTP2(handler), &http2.Server{}),
+		ConnState: func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				metrics.RecordListenerConnection(port, "h2c")
+			}
+		},
+	}
+	go func() {
+		<-stopCh
+		server.Close() //nolint
+	}()
+	go func() {
+		klog.Infof("serving proxy by h2c on %s", ln.Addr().String())
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("failed to serve proxy by h2c on %s: %v", ln.Addr().String(), err)
+		}
+	}()
+	return nil
+}
+
+func requireHTTP2(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ProtoMajor < 2 {
+			http.Error(w, "this listener only serves HTTP/2 with prior knowledge or h2c upgrade", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+
+	genericapiserver "k8s.io/apiserver/pkg/server"
+	"k8s.io/apiserver/pkg/server/dynamiccertificates"
+	"k8s.io/klog"
+)
+
+// HTTP3ServeFunc serves handler by HTTP/3 over QUIC on conn with tlsConfig until stopCh is closed,
+// it must not block.
+type HTTP3ServeFunc func(conn net.PacketConn, tlsConfig *tls.Config, handler http.Handler, stopCh <-chan struct{}) error
+
+// ServeHTTP3 is the QUIC implementation serving ExtraConfig.HTTP3BindAddress. kube-gateway links no
+// QUIC stack because the available ones require a newer Go toolchain than this module, so the binary
+// has no HTTP/3 listener. Servers embedding the proxy with one set it before the server is started,
+// e.g. by quic-go's http3.Server{Handler: handler, TLSConfig: tlsConfig}.Serve(conn).
+var ServeHTTP3 HTTP3ServeFunc
+
+// serveHTTP3 serves handler by HTTP/3 on the UDP address until stopCh is closed. It serves the
+// same certificates as the secure listeners, including SNI certificates of upstream clusters.
+func serveHTTP3(address string, handler http.Handler, secureServing *genericapiserver.SecureServingInfo, stopCh <-chan struct{}) error {
+	if ServeHTTP3 == nil {
+		return fmt.Errorf("HTTP/3 listener %s is configured but no QUIC implementation is linked into this binary", address)
+	}
+	tlsConfig, err := http3TLSConfig(secureServing, stopCh)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	klog.Infof("serving proxy by HTTP/3 on %s", conn.LocalAddr().String())
+	if err := ServeHTTP3(conn, tlsConfig, handler, stopCh); err != nil {
+		conn.Close() //nolint
+		return fmt.Errorf("failed to serve proxy by HTTP/3 on %s: %v", conn.LocalAddr().String(), err)
+	}
+	return nil
+}
+
+// http3TLSConfig mirrors the tls config of secure serving, restricted to TLS 1.3 and h3 as QUIC requires.
+// Certificate providers are already run by secure serving, only a serving controller is run for this config.
+func http3TLSConfig(s *genericapiserver.SecureServingInfo, stopCh <-chan struct{}) (*tls.Config, error) {
+	if s == nil || s.Cert == nil {
+		return nil, fmt.Errorf("HTTP/3 listener requires serving certificates of secure serving")
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		NextProtos: []string{"h3"},
+	}
+	if len(s.CipherSuites) > 0 {
+		tlsConfig.CipherSuites = s.CipherSuites
+	}
+	if s.ClientCA != nil {
+		// Populate PeerCertificates in requests, but don't reject connections without certificates
+		tlsConfig.ClientAuth = tls.RequestClientCert
+	}
+
+	controller := dynamiccertificates.NewDynamicServingCertificateController(tlsConfig, s.ClientCA, s.Cert, s.SNICerts, nil)
+	if notifier, ok := s.ClientCA.(dynamiccertificates.Notifier); ok {
+		notifier.AddListener(controller)
+	}
+	if notifier, ok := s.Cert.(dynamiccertificates.Notifier); ok {
+		notifier.AddListener(controller)
+	}
+	for _, sniCert := range s.SNICerts {
+		if notifier, ok := sniCert.(dynamiccertificates.Notifier); ok {
+			notifier.AddListener(controller)
+		}
+	}
+	if err := controller.RunOnce(); err != nil {
+		klog.Warningf("Initial population of HTTP/3 serving certificates failed: %v", err)
+	}
+	go controller.Run(1, stopCh)
+
+	tlsConfig.GetConfigForClient = controller.GetConfigForClient
+	if s.DynamicClientConfig != nil {
+		tlsConfig.GetConfigForClient = s.DynamicClientConfig.WrapGetConfigForClient(controller.GetConfigForClient)
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type AbuseReportOptions struct {
+	Threshold  int
+	Window     time.Duration
+	WebhookURL string
+}
+
+func NewAbuseReportOptions() *AbuseReportOptions {
+	return &AbuseReportOptions{
+		Window: time.Minute,
+	}
+}
+
+func (o *AbuseReportOptions) Validate() []error {
+	if o == nil || o.Threshold == 0 {
+		return nil
+	}
+	errs := []error{}
+	if o.Threshold < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-abuse-rejection-threshold must not be negative"))
+	}
+	if o.Window <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-abuse-window must be greater than 0"))
+	}
+	if len(o.WebhookURL) > 0 {
+		if u, err := url.Parse(o.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-abuse-webhook-url must be an absolute http or https url"))
+		}
+	}
+	return errs
+}
+
+func (o *AbuseReportOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.IntVar(&o.Threshold, "proxy-abuse-rejection-threshold", o.Threshold, ""+
+		"If requests of a user to an upstream cluster are rejected by rate limiting, load shedding or watch limits "+
+		"this many times within --proxy-abuse-window, a Warning Event is emitted on the UpstreamCluster in control plane. "+
+		"Zero means disabled.")
+	fs.DurationVar(&o.Window, "proxy-abuse-window", o.Window,
+		"The window in which rejections of a user are aggregated, a user is reported at most once per window.")
+	fs.StringVar(&o.WebhookURL, "proxy-abuse-webhook-url", o.WebhookURL, ""+
+		"If set, aggregated details of reported users are also posted to the url in JSON.")
+}
+
+// ToAbuseReporter returns the abuse reporter for dispatcher emitting events by client,
+// nil means abusive clients are not reported
+func (o *AbuseReportOptions) ToAbuseReporter(client kubernetes.Interface) *dispatcher.AbuseReporter {
+	if o == nil || o.Threshold <= 0 {
+		return nil
+	}
+	var recorder record.EventRecorder
+	if client != nil {
+		recorder = newEventRecorder(client)
+	}
+	return dispatcher.NewAbuseReporter(o.Threshold, o.Window, recorder, o.WebhookURL)
+}
+
+var (
+	eventRecorderOnce sync.Once
+	eventRecorder     record.EventRecorder
+)
+
+// newEventRecorder returns the recorder emitting events of proxy to control plane by client,
+// all options share one broadcaster.
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	eventRecorderOnce.Do(func() {
+		hostname, _ := os.Hostname()
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+		eventRecorder = broadcaster.NewRecorder(clientgoscheme.Scheme, corev1.EventSource{Component: "kube-gateway-proxy", Host: hostname})
+	})
+	return eventRecorder
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type AdaptiveTimeoutOptions struct {
+	Enabled    bool
+	Percentile float64
+	Multiplier float64
+	Floor      time.Duration
+	Ceiling    time.Duration
+}
+
+func NewAdaptiveTimeoutOptions() *AdaptiveTimeoutOptions {
+	return &AdaptiveTimeoutOptions{
+		Percentile: 0.99,
+		Multiplier: 3,
+		Floor:      5 * time.Second,
+		Ceiling:    70 * time.Second,
+	}
+}
+
+func (o *AdaptiveTimeoutOptions) Validate() []error {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	errs := []error{}
+	if o.Percentile <= 0 || o.Percentile > 1 {
+		errs = append(errs, fmt.Errorf("--proxy-adaptive-timeout-percentile must be in (0, 1]"))
+	}
+	if o.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("--proxy-adaptive-timeout-multiplier must not be less than 1"))
+	}
+	if o.Floor <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-adaptive-timeout-floor must be greater than 0"))
+	}
+	if o.Ceiling < o.Floor {
+		errs = append(errs, fmt.Errorf("--proxy-adaptive-timeout-ceiling must not be less than --proxy-adaptive-timeout-floor"))
+	}
+	return errs
+}
+
+func (o *AdaptiveTimeoutOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Enabled, "proxy-adaptive-timeout", o.Enabled, ""+
+		"If true, the response header timeout of non-long-running requests is derived from recent latencies "+
+		"of each cluster, verb and resource instead of one static number, so requests to a hung endpoint are "+
+		"canceled and retried early while slow lists are not canceled prematurely.")
+	fs.Float64Var(&o.Percentile, "proxy-adaptive-timeout-percentile", o.Percentile,
+		"The percentile of recent latencies the adaptive timeout is derived from.")
+	fs.Float64Var(&o.Multiplier, "proxy-adaptive-timeout-multiplier", o.Multiplier,
+		"The multiplier applied to the latency percentile to leave headroom for jitter.")
+	fs.DurationVar(&o.Floor, "proxy-adaptive-timeout-floor", o.Floor,
+		"The minimum adaptive timeout.")
+	fs.DurationVar(&o.Ceiling, "proxy-adaptive-timeout-ceiling", o.Ceiling, ""+
+		"The maximum adaptive timeout, it is also used before enough latencies are observed. It never extends "+
+		"--proxy-upstream-response-header-timeout or the timeout of non-long-running requests, --request-timeout plus 10s.")
+}
+
+// ToAdaptiveTimeoutPolicy returns the adaptive timeout policy for dispatcher, nil means static timeouts are used
+func (o *AdaptiveTimeoutOptions) ToAdaptiveTimeoutPolicy() *dispatcher.AdaptiveTimeoutPolicy {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	return dispatcher.NewAdaptiveTimeoutPolicy(o.Percentile, o.Multiplier, o.Floor, o.Ceiling)
+}
@@ -32,18 +32,26 @@ import (
 type AuthenticationOptions struct {
 	TokenSuccessCacheTTL time.Duration
 	TokenFailureCacheTTL time.Duration
+	OIDC                 *OIDCAuthenticationOptions
+	Revocation           *ClientCertRevocationOptions
 }
 
 func NewAuthenticationOptions() *AuthenticationOptions {
 	o := &AuthenticationOptions{
 		TokenSuccessCacheTTL: 600 * time.Second, // 10 minutes
 		TokenFailureCacheTTL: 10 * time.Second,
+		OIDC:                 NewOIDCAuthenticationOptions(),
+		Revocation:           NewClientCertRevocationOptions(),
 	}
 	return o
 }
 
 func (o *AuthenticationOptions) Validate() []error {
-	return nil
+	if o == nil {
+		return nil
+	}
+	errs := o.OIDC.Validate()
+	return append(errs, o.Revocation.Validate()...)
 }
 
 func (o *AuthenticationOptions) AddFlags(fs *pflag.FlagSet) {
@@ -51,6 +59,8 @@ func (o *AuthenticationOptions) AddFlags(fs *pflag.FlagSet) {
 		"The duration to cache seccess responses from the upstream token request authenticator.")
 	fs.DurationVar(&o.TokenFailureCacheTTL, "proxy-authentication-token-failure-cache-ttl", o.TokenFailureCacheTTL,
 		"The duration to cache failure responses from the upstream token request authenticator.")
+	o.OIDC.AddFlags(fs)
+	o.Revocation.AddFlags(fs)
 }
 
 func (o *AuthenticationOptions) ToAuthenticationConfig(
@@ -93,6 +103,16 @@ func (o *AuthenticationOptions) ToAuthenticationConfig(
 		}
 	}
 
+	cfg.OIDC = o.OIDC.ToOIDCOptions()
+
+	if cfg.ClientCert != nil {
+		checker, err := o.Revocation.ToChecker()
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCert.RevocationChecker = checker
+	}
+
 	return &cfg, nil
 }
 
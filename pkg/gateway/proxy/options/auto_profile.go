@@ -0,0 +1,86 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+)
+
+type AutoProfileOptions struct {
+	LatencyThreshold time.Duration
+	Duration         time.Duration
+	Cooldown         time.Duration
+	MaxProfiles      int
+}
+
+func NewAutoProfileOptions() *AutoProfileOptions {
+	return &AutoProfileOptions{
+		Duration:    gatewaydebug.DefaultAutoProfileDuration,
+		Cooldown:    gatewaydebug.DefaultAutoProfileCooldown,
+		MaxProfiles: gatewaydebug.DefaultMaxAutoProfiles,
+	}
+}
+
+func (o *AutoProfileOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.LatencyThreshold < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-auto-profile-latency-threshold must not be negative"))
+	}
+	if o.LatencyThreshold == 0 {
+		return errs
+	}
+	if o.Duration <= 0 || o.Duration > time.Minute {
+		errs = append(errs, fmt.Errorf("--proxy-auto-profile-duration must be in (0, 1m]"))
+	}
+	if o.Cooldown < o.Duration {
+		errs = append(errs, fmt.Errorf("--proxy-auto-profile-cooldown must not be less than --proxy-auto-profile-duration"))
+	}
+	if o.MaxProfiles <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-auto-profile-max-profiles must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *AutoProfileOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.DurationVar(&o.LatencyThreshold, "proxy-auto-profile-latency-threshold", o.LatencyThreshold, ""+
+		"If the time spent by gateway itself before trying upstream endpoint exceeds it, a short CPU profile "+
+		"is captured automatically in background. Zero means disabled.")
+	fs.DurationVar(&o.Duration, "proxy-auto-profile-duration", o.Duration,
+		"The duration of each automatically captured CPU profile.")
+	fs.DurationVar(&o.Cooldown, "proxy-auto-profile-cooldown", o.Cooldown,
+		"The minimum interval between the starts of two automatically captured CPU profiles.")
+	fs.IntVar(&o.MaxProfiles, "proxy-auto-profile-max-profiles", o.MaxProfiles, ""+
+		"The number of the most recent automatically captured CPU profiles kept in memory, they can be retrieved by "+
+		gatewaydebug.AutoProfilesPath+" of control plane server.")
+}
+
+// ApplyTo sets the profiler of self latency regressions, it must be called before serving.
+func (o *AutoProfileOptions) ApplyTo() {
+	if o == nil {
+		return
+	}
+	gatewaydebug.DefaultAutoProfiler = gatewaydebug.NewAutoProfiler(o.LatencyThreshold, o.Duration, o.Cooldown, o.MaxProfiles)
+}
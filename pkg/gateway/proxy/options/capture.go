@@ -0,0 +1,84 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	genericserver "k8s.io/apiserver/pkg/server"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/capture"
+)
+
+type TrafficCaptureOptions struct {
+	CaptureFile      string
+	SampleRate       float64
+	MaxSizeMegabytes int64
+}
+
+func NewTrafficCaptureOptions() *TrafficCaptureOptions {
+	return &TrafficCaptureOptions{
+		SampleRate:       1,
+		MaxSizeMegabytes: 1024,
+	}
+}
+
+func (o *TrafficCaptureOptions) Validate() []error {
+	if o == nil || len(o.CaptureFile) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if !filepath.IsAbs(o.CaptureFile) {
+		errs = append(errs, fmt.Errorf("--proxy-capture-file must be an absolute path"))
+	}
+	if o.SampleRate <= 0 || o.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("--proxy-capture-sample-rate must be in (0, 1]"))
+	}
+	if o.MaxSizeMegabytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-capture-max-size must not be negative"))
+	}
+	return errs
+}
+
+func (o *TrafficCaptureOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.CaptureFile, "proxy-capture-file", o.CaptureFile, ""+
+		"The file to capture sanitized metadata of proxied requests in NDJSON, compressed with gzip if "+
+		"the name ends with .gz. Bodies, headers, credentials and query parameters are never captured. "+
+		"Captures can be analyzed offline by the analyze-capture subcommand. Empty means disabled.")
+	fs.Float64Var(&o.SampleRate, "proxy-capture-sample-rate", o.SampleRate,
+		"The ratio of requests to capture, in (0, 1].")
+	fs.Int64Var(&o.MaxSizeMegabytes, "proxy-capture-max-size", o.MaxSizeMegabytes,
+		"The maximum uncompressed megabytes of records written by one gateway process, capturing stops "+
+			"after it is reached. Zero means unlimited.")
+}
+
+// ApplyTo creates the traffic recorder and starts it after server started, nil is
+// returned if capturing is disabled.
+func (o *TrafficCaptureOptions) ApplyTo(genericConfig *genericserver.Config) (*capture.Recorder, error) {
+	if o == nil || len(o.CaptureFile) == 0 {
+		return nil, nil
+	}
+	recorder := capture.NewRecorder(o.CaptureFile, o.SampleRate, o.MaxSizeMegabytes*1024*1024)
+	err := genericConfig.AddPostStartHook("kube-gateway-start-traffic-capture", func(context genericserver.PostStartHookContext) error {
+		go recorder.Run(context.StopCh)
+		return nil
+	})
+	return recorder, err
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type ClusterCeilingOptions struct {
+	MaxQPSPerCluster            int32
+	MaxBytesPerSecondPerCluster int64
+}
+
+func NewClusterCeilingOptions() *ClusterCeilingOptions {
+	return &ClusterCeilingOptions{}
+}
+
+func (o *ClusterCeilingOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxQPSPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-qps-per-cluster must not be negative"))
+	}
+	if o.MaxBytesPerSecondPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-bytes-per-second-per-cluster must not be negative"))
+	}
+	return errs
+}
+
+func (o *ClusterCeilingOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Int32Var(&o.MaxQPSPerCluster, "proxy-max-qps-per-cluster", o.MaxQPSPerCluster, ""+
+		"The maximum aggregate number of requests per second proxied to one upstream cluster regardless of users, "+
+		"requests exceeding it are rejected with 429. It is the ultimate backstop during client storms and applies "+
+		"to exempt requests too. It can be overridden by annotation "+clusters.CeilingsAnnotationKey+" of each cluster. "+
+		"With --proxy-coordination-lease, it is split among live replicas. Zero means no limit.")
+	fs.Int64Var(&o.MaxBytesPerSecondPerCluster, "proxy-max-bytes-per-second-per-cluster", o.MaxBytesPerSecondPerCluster, ""+
+		"The maximum aggregate bytes per second of request bodies sent to one upstream cluster, bodies exceeding it "+
+		"are throttled. It can be overridden by annotation "+clusters.CeilingsAnnotationKey+" of each cluster. "+
+		"With --proxy-coordination-lease, it is split among live replicas. Zero means no limit.")
+}
+
+// ApplyTo sets the default ceilings of all upstream clusters and the recorder of saturation events in defaults,
+// it must be called before upstream cluster controller starts.
+func (o *ClusterCeilingOptions) ApplyTo(defaults *clusters.ClusterDefaults, client kubernetes.Interface) {
+	if o == nil {
+		return
+	}
+	defaults.Ceilings = clusters.ClusterCeilings{
+		MaxQPS:            o.MaxQPSPerCluster,
+		MaxBytesPerSecond: o.MaxBytesPerSecondPerCluster,
+	}
+	if client != nil {
+		defaults.EventRecorder = newEventRecorder(client)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/coordination"
+)
+
+type CoordinationOptions struct {
+	LeaseName      string
+	LeaseNamespace string
+	Interval       time.Duration
+	TTL            time.Duration
+}
+
+func NewCoordinationOptions() *CoordinationOptions {
+	return &CoordinationOptions{
+		LeaseNamespace: "kube-system",
+		Interval:       10 * time.Second,
+		TTL:            30 * time.Second,
+	}
+}
+
+// ValidateWith validates options with the name of this replica, see --proxy-replica-name
+func (o *CoordinationOptions) ValidateWith(replica string) []error {
+	if o == nil || len(o.LeaseName) == 0 {
+		return nil
+	}
+	errs := []error{}
+	for _, msg := range validation.IsDNS1123Subdomain(o.LeaseName) {
+		errs = append(errs, fmt.Errorf("invalid --proxy-coordination-lease %q: %s", o.LeaseName, msg))
+	}
+	for _, msg := range validation.IsDNS1123Label(o.LeaseNamespace) {
+		errs = append(errs, fmt.Errorf("invalid --proxy-coordination-lease-namespace %q: %s", o.LeaseNamespace, msg))
+	}
+	for _, msg := range validation.IsQualifiedName(coordination.ReplicaAnnotationPrefix + replica) {
+		errs = append(errs, fmt.Errorf("--proxy-replica-name %q is invalid in annotation key: %s", replica, msg))
+	}
+	if o.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-coordination-interval must be greater than 0"))
+	}
+	if o.TTL <= o.Interval {
+		errs = append(errs, fmt.Errorf("--proxy-coordination-ttl must be greater than --proxy-coordination-interval"))
+	}
+	return errs
+}
+
+func (o *CoordinationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.LeaseName, "proxy-coordination-lease", o.LeaseName, ""+
+		"The name of the Lease in gateway control plane shared by all replicas to coordinate, e.g. to split "+
+		"ceilings of upstream clusters or agree on canary steps. Each replica publishes its state to annotation "+
+		coordination.ReplicaAnnotationPrefix+"<replica> of it. Empty means disabled.")
+	fs.StringVar(&o.LeaseNamespace, "proxy-coordination-lease-namespace", o.LeaseNamespace,
+		"The namespace of the coordination Lease, it is created if not exists.")
+	fs.DurationVar(&o.Interval, "proxy-coordination-interval", o.Interval,
+		"The interval to publish the state of this replica and observe other replicas.")
+	fs.DurationVar(&o.TTL, "proxy-coordination-ttl", o.TTL,
+		"The duration after which a replica not renewing its state is considered gone.")
+}
+
+// ToCoordinator returns the coordinator of this replica, nil means coordination is disabled
+func (o *CoordinationOptions) ToCoordinator(client kubernetes.Interface, replica string) *coordination.Coordinator {
+	if o == nil || len(o.LeaseName) == 0 {
+		return nil
+	}
+	return coordination.NewCoordinator(coordination.Config{
+		Client:    client,
+		Namespace: o.LeaseNamespace,
+		Name:      o.LeaseName,
+		Replica:   replica,
+		Interval:  o.Interval,
+		TTL:       o.TTL,
+	})
+}
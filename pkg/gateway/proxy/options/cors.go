@@ -0,0 +1,97 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type CORSOptions struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
+
+func NewCORSOptions() *CORSOptions {
+	return &CORSOptions{}
+}
+
+func (o *CORSOptions) Validate() []error {
+	if o == nil || len(o.AllowedOrigins) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-cors-max-age must not be negative"))
+	}
+	if _, err := clusters.NewCORSPolicy(o.spec()); err != nil {
+		errs = append(errs, fmt.Errorf("invalid gateway cors policy: %v", err))
+	}
+	return errs
+}
+
+func (o *CORSOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.AllowedOrigins, "proxy-cors-allowed-origins", o.AllowedOrigins, ""+
+		"List of allowed origins for CORS of all upstream clusters, comma separated. An allowed origin can be a regular "+
+		"expression to support subdomain matching. CORS headers sent from upstream are replaced by the gateway policy. "+
+		"It can be overridden by annotation "+clusters.CORSPolicyAnnotationKey+" of each cluster. "+
+		"If empty, --cors-allowed-origins is used.")
+	fs.StringSliceVar(&o.AllowedMethods, "proxy-cors-allowed-methods", o.AllowedMethods,
+		"List of allowed methods for CORS preflight requests, defaults to POST,GET,OPTIONS,PUT,DELETE,PATCH.")
+	fs.StringSliceVar(&o.AllowedHeaders, "proxy-cors-allowed-headers", o.AllowedHeaders,
+		"List of allowed request headers for CORS preflight requests, defaults to the headers allowed by kube-apiserver.")
+	fs.StringSliceVar(&o.ExposedHeaders, "proxy-cors-exposed-headers", o.ExposedHeaders,
+		"List of response headers exposed to browsers, defaults to Date.")
+	fs.DurationVar(&o.MaxAge, "proxy-cors-max-age", o.MaxAge,
+		"How long the results of a CORS preflight request can be cached by browsers, zero means not set.")
+	fs.BoolVar(&o.AllowCredentials, "proxy-cors-allow-credentials", o.AllowCredentials,
+		"If true, browsers are allowed to send credentials in cross-origin requests.")
+}
+
+func (o *CORSOptions) spec() clusters.CORSPolicySpec {
+	return clusters.CORSPolicySpec{
+		AllowedOrigins:   o.AllowedOrigins,
+		AllowedMethods:   o.AllowedMethods,
+		AllowedHeaders:   o.AllowedHeaders,
+		ExposedHeaders:   o.ExposedHeaders,
+		MaxAgeSeconds:    int32(o.MaxAge / time.Second),
+		AllowCredentials: o.AllowCredentials,
+	}
+}
+
+// ApplyTo sets the default CORS policy of all upstream clusters, it must be called before
+// upstream cluster controller starts.
+func (o *CORSOptions) ApplyTo() error {
+	if o == nil || len(o.AllowedOrigins) == 0 {
+		return nil
+	}
+	policy, err := clusters.NewCORSPolicy(o.spec())
+	if err != nil {
+		return err
+	}
+	clusters.DefaultCORSPolicy = policy
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type CostEstimationOptions struct {
+	Enabled        bool
+	ObjectsPerSeat int64
+	BytesPerSeat   int64
+	MaxSeats       int
+}
+
+func NewCostEstimationOptions() *CostEstimationOptions {
+	return &CostEstimationOptions{
+		ObjectsPerSeat: 100,
+		BytesPerSeat:   1 << 20,
+		MaxSeats:       10,
+	}
+}
+
+func (o *CostEstimationOptions) Validate() []error {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	errs := []error{}
+	if o.ObjectsPerSeat <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-cost-estimation-objects-per-seat must be greater than 0"))
+	}
+	if o.BytesPerSeat <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-cost-estimation-bytes-per-seat must be greater than 0"))
+	}
+	if o.MaxSeats < 1 {
+		errs = append(errs, fmt.Errorf("--proxy-cost-estimation-max-seats must not be less than 1"))
+	}
+	return errs
+}
+
+func (o *CostEstimationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Enabled, "proxy-cost-estimation", o.Enabled, ""+
+		"If true, list requests are charged more than one seat of their flow control, estimated from the limit "+
+		"parameter and recent response sizes of lists of the same resource, so one huge LIST is not treated "+
+		"the same as one GET. Token buckets are charged one token per seat, max requests inflight one slot per seat.")
+	fs.Int64Var(&o.ObjectsPerSeat, "proxy-cost-estimation-objects-per-seat", o.ObjectsPerSeat,
+		"The number of objects a paged list can return per seat.")
+	fs.Int64Var(&o.BytesPerSeat, "proxy-cost-estimation-bytes-per-seat", o.BytesPerSeat,
+		"The average list response size in bytes per seat.")
+	fs.IntVar(&o.MaxSeats, "proxy-cost-estimation-max-seats", o.MaxSeats, ""+
+		"The maximum seats of one request, it is also the cost of unpaged lists before any response of the "+
+		"resource is observed. Seats are also bounded by the capacity of the flow control.")
+}
+
+// ToCostEstimationPolicy returns the cost estimation policy for dispatcher, nil means every request costs one seat
+func (o *CostEstimationOptions) ToCostEstimationPolicy() *dispatcher.CostEstimationPolicy {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	return dispatcher.NewCostEstimationPolicy(o.ObjectsPerSeat, o.BytesPerSeat, o.MaxSeats)
+}
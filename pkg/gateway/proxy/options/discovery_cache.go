@@ -0,0 +1,89 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type DiscoveryCacheOptions struct {
+	Enabled              bool
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+	MaxBodyBytes         int64
+}
+
+func NewDiscoveryCacheOptions() *DiscoveryCacheOptions {
+	return &DiscoveryCacheOptions{
+		TTL:                  10 * time.Second,
+		StaleWhileRevalidate: time.Minute,
+		StaleIfError:         10 * time.Minute,
+		MaxBodyBytes:         32 << 20,
+	}
+}
+
+func (o *DiscoveryCacheOptions) Validate() []error {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	errs := []error{}
+	if o.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-discovery-cache-ttl must be greater than 0"))
+	}
+	if o.StaleWhileRevalidate < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-discovery-cache-stale-while-revalidate must not be negative"))
+	}
+	if o.StaleIfError < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-discovery-cache-stale-if-error must not be negative"))
+	}
+	if o.MaxBodyBytes <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-discovery-cache-max-body-bytes must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *DiscoveryCacheOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Enabled, "proxy-discovery-cache", o.Enabled, ""+
+		"If true, discovery and openapi documents of upstream clusters are cached by gateway, so client-go "+
+		"initialization still gets stale documents during brief upstream outages instead of failing. Documents are "+
+		"shared by all authenticated users of a cluster as granted by the system:discovery role, do not enable it "+
+		"for clusters restricting discovery to some users.")
+	fs.DurationVar(&o.TTL, "proxy-discovery-cache-ttl", o.TTL,
+		"The duration a cached discovery document is fresh and served without asking upstream.")
+	fs.DurationVar(&o.StaleWhileRevalidate, "proxy-discovery-cache-stale-while-revalidate", o.StaleWhileRevalidate, ""+
+		"The duration after ttl a stale discovery document is served at once while it is refreshed in background.")
+	fs.DurationVar(&o.StaleIfError, "proxy-discovery-cache-stale-if-error", o.StaleIfError, ""+
+		"The duration after ttl a stale discovery document is served if upstream fails, responds 5xx or 429, "+
+		"or has no ready endpoint.")
+	fs.Int64Var(&o.MaxBodyBytes, "proxy-discovery-cache-max-body-bytes", o.MaxBodyBytes,
+		"The maximum size of a cached discovery document, larger documents are never cached.")
+}
+
+// ToDiscoveryCachePolicy returns the discovery cache policy for dispatcher, nil means discovery documents are never cached
+func (o *DiscoveryCacheOptions) ToDiscoveryCachePolicy() *dispatcher.DiscoveryCachePolicy {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	return dispatcher.NewDiscoveryCachePolicy(o.TTL, o.StaleWhileRevalidate, o.StaleIfError, o.MaxBodyBytes)
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type DrainOptions struct {
+	Phases []string
+}
+
+func NewDrainOptions() *DrainOptions {
+	return &DrainOptions{}
+}
+
+func (o *DrainOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	if _, err := o.phases(); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+func (o *DrainOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.Phases, "proxy-drain-phases", o.Phases, ""+
+		"Drain sessions in the given order when gateway is terminating, each phase is CLASS=TIMEOUT. "+
+		"Classes are short (waited to finish), list (waited to finish), watch (closed gradually over the timeout "+
+		"so clients re-watch on other replicas) and stream (exec, attach, port-forward and logs, cut with a warning "+
+		"after the timeout), e.g. short=10s,list=30s,watch=20s,stream=10s. New watches and streams are rejected once "+
+		"their phase starts. The total timeout should be shorter than the termination grace period. "+
+		"Empty means all sessions are cut at the end of one flat grace period.")
+}
+
+func (o *DrainOptions) phases() ([]dispatcher.DrainPhase, error) {
+	known := sets.NewString(dispatcher.DrainClasses...)
+	seen := sets.NewString()
+	phases := []dispatcher.DrainPhase{}
+	for _, value := range o.Phases {
+		kv := strings.SplitN(value, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid --proxy-drain-phases %q, must be in format CLASS=TIMEOUT", value)
+		}
+		class := strings.TrimSpace(kv[0])
+		if !known.Has(class) {
+			return nil, fmt.Errorf("unknown class of --proxy-drain-phases %q, must be one of %v", value, dispatcher.DrainClasses)
+		}
+		if seen.Has(class) {
+			return nil, fmt.Errorf("duplicate class of --proxy-drain-phases %q", value)
+		}
+		seen.Insert(class)
+		timeout, err := time.ParseDuration(strings.TrimSpace(kv[1]))
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout of --proxy-drain-phases %q, must be a positive duration", value)
+		}
+		phases = append(phases, dispatcher.DrainPhase{Class: class, Timeout: timeout})
+	}
+	return phases, nil
+}
+
+// ToDrainPolicy returns the drain policy for dispatcher, nil means sessions are not drained in order
+func (o *DrainOptions) ToDrainPolicy() *dispatcher.DrainPolicy {
+	if o == nil || len(o.Phases) == 0 {
+		return nil
+	}
+	phases, err := o.phases()
+	if err != nil {
+		return nil
+	}
+	return dispatcher.NewDrainPolicy(phases)
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+)
+
+type EndpointStateOptions struct {
+	StateFile    string
+	SaveInterval time.Duration
+	MaxAge       time.Duration
+}
+
+func NewEndpointStateOptions() *EndpointStateOptions {
+	return &EndpointStateOptions{
+		SaveInterval: 10 * time.Second,
+		MaxAge:       10 * time.Minute,
+	}
+}
+
+func (o *EndpointStateOptions) Validate() []error {
+	if o == nil || len(o.StateFile) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if !filepath.IsAbs(o.StateFile) {
+		errs = append(errs, fmt.Errorf("--proxy-endpoint-state-file must be an absolute path"))
+	}
+	if o.SaveInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-endpoint-state-save-interval must be greater than 0"))
+	}
+	if o.MaxAge <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-endpoint-state-max-age must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *EndpointStateOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.StateFile, "proxy-endpoint-state-file", o.StateFile, ""+
+		"The local file to persist learned endpoint state, including health, latency and error rate, "+
+		"across restarts. Endpoints which were unhealthy before restart must pass several health checks "+
+		"before receiving traffic again. Empty means disabled.")
+	fs.DurationVar(&o.SaveInterval, "proxy-endpoint-state-save-interval", o.SaveInterval,
+		"The interval to save endpoint state to --proxy-endpoint-state-file.")
+	fs.DurationVar(&o.MaxAge, "proxy-endpoint-state-max-age", o.MaxAge,
+		"Endpoint states older than this are ignored when restoring.")
+}
+
+// ApplyTo restores endpoint state from state file and enables persistence of upstream controller,
+// it must be called before upstream controller starts.
+func (o *EndpointStateOptions) ApplyTo(controller *controllers.UpstreamClusterController) error {
+	if o == nil || len(o.StateFile) == 0 {
+		return nil
+	}
+	store := clusters.NewEndpointStateStore(o.StateFile, o.MaxAge)
+	if err := store.Load(); err != nil {
+		return err
+	}
+	clusters.DefaultEndpointStateStore = store
+	controller.EnableEndpointStatePersistence(store, o.SaveInterval)
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type ExpiredResourceVersionOptions struct {
+	Warnings bool
+	Rewrite  bool
+}
+
+func NewExpiredResourceVersionOptions() *ExpiredResourceVersionOptions {
+	return &ExpiredResourceVersionOptions{
+		Warnings: true,
+	}
+}
+
+func (o *ExpiredResourceVersionOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.Rewrite && !o.Warnings {
+		errs = append(errs, fmt.Errorf("--proxy-expired-resource-version-rewrite requires --proxy-expired-resource-version-warnings=true"))
+	}
+	return errs
+}
+
+func (o *ExpiredResourceVersionOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Warnings, "proxy-expired-resource-version-warnings", o.Warnings, ""+
+		"If true, lists rejected by upstream with 410 Gone because of expired resourceVersion or continue token "+
+		"are answered with a Warning header telling clients how to relist, and counted per client User-Agent.")
+	fs.BoolVar(&o.Rewrite, "proxy-expired-resource-version-rewrite", o.Rewrite, ""+
+		"If true, lists rejected because of expired resourceVersion are retried once without resourceVersion "+
+		"unless they require the exact resourceVersion or carry a continue token, so they are served from the "+
+		"latest state instead of failing.")
+}
+
+// ToExpiredResourceVersionPolicy returns the policy for dispatcher, nil means 410 Gone responses are passed through as is
+func (o *ExpiredResourceVersionOptions) ToExpiredResourceVersionPolicy() *dispatcher.ExpiredResourceVersionPolicy {
+	if o == nil || !o.Warnings {
+		return nil
+	}
+	return &dispatcher.ExpiredResourceVersionPolicy{
+		Rewrite: o.Rewrite,
+	}
+}
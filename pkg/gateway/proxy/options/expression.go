@@ -0,0 +1,63 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/expression"
+)
+
+type ExpressionOptions struct {
+	CostLimit int64
+}
+
+func NewExpressionOptions() *ExpressionOptions {
+	return &ExpressionOptions{
+		CostLimit: expression.DefaultCostLimit,
+	}
+}
+
+func (o *ExpressionOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.CostLimit <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-expression-cost-limit must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *ExpressionOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Int64Var(&o.CostLimit, "proxy-expression-cost-limit", o.CostLimit, ""+
+		"The maximum cost of evaluating an expression of annotation "+clusters.ExpressionRulesAnnotationKey+" for one request, "+
+		"every operation costs 1 and scanning strings or lists costs more by their sizes. Expressions exceeding it "+
+		"are rejected when compiled if possible, otherwise do not match the request.")
+}
+
+// ApplyTo sets the cost limit of expression rules in defaults, it must be called before upstream cluster controller starts.
+func (o *ExpressionOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	defaults.ExpressionCostLimit = o.CostLimit
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type FleetOptions struct {
+	Hostname string
+	Clusters []string
+	Timeout  time.Duration
+}
+
+func NewFleetOptions() *FleetOptions {
+	return &FleetOptions{
+		Timeout: 30 * time.Second,
+	}
+}
+
+func (o *FleetOptions) Validate() []error {
+	if o == nil || len(o.Hostname) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if !features.Enabled(features.FleetFanout) {
+		errs = append(errs, fmt.Errorf("--proxy-fleet-hostname requires feature gate %s=true", features.FleetFanout))
+	}
+	if len(o.Clusters) == 0 {
+		errs = append(errs, fmt.Errorf("--proxy-fleet-clusters must be set when --proxy-fleet-hostname is set"))
+	}
+	for _, c := range o.Clusters {
+		if strings.EqualFold(c, o.Hostname) {
+			errs = append(errs, fmt.Errorf("--proxy-fleet-clusters must not contain the fleet hostname %q", o.Hostname))
+		}
+	}
+	if o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-fleet-timeout must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *FleetOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Hostname, "proxy-fleet-hostname", o.Hostname, ""+
+		"The virtual hostname (e.g. fleet.gateway) whose GET and LIST requests are fanned out to all clusters "+
+		"in --proxy-fleet-clusters and merged into one response, a LIST is responded with one list of all items and "+
+		"a GET with the object of the first cluster having it. Empty means disabled. "+
+		"It requires feature gate FleetFanout=true.")
+	fs.StringSliceVar(&o.Clusters, "proxy-fleet-clusters", o.Clusters,
+		"A list of upstream cluster names which the fleet hostname fans out to.")
+	fs.DurationVar(&o.Timeout, "proxy-fleet-timeout", o.Timeout,
+		"The maximum duration to wait for all member clusters of a fleet request.")
+}
+
+// ToFleetRoute returns the fleet route config for dispatcher, nil means fleet route is disabled
+func (o *FleetOptions) ToFleetRoute() *dispatcher.FleetRoute {
+	if o == nil || len(o.Hostname) == 0 {
+		return nil
+	}
+	members := make([]string, 0, len(o.Clusters))
+	for _, c := range o.Clusters {
+		members = append(members, strings.ToLower(c))
+	}
+	return &dispatcher.FleetRoute{
+		Hostname: strings.ToLower(o.Hostname),
+		Clusters: members,
+		Timeout:  o.Timeout,
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type HealthCheckOptions struct {
+	Jitter         float64
+	QPS            float32
+	Burst          int
+	MaxConcurrency int
+}
+
+func NewHealthCheckOptions() *HealthCheckOptions {
+	return &HealthCheckOptions{
+		Jitter:         0.2,
+		QPS:            200,
+		Burst:          50,
+		MaxConcurrency: 100,
+	}
+}
+
+func (o *HealthCheckOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.Jitter < 0 || o.Jitter > 1 {
+		errs = append(errs, fmt.Errorf("--proxy-health-check-jitter must be between 0 and 1, inclusive"))
+	}
+	if o.QPS < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-health-check-qps must not be negative"))
+	}
+	if o.QPS > 0 && o.Burst <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-health-check-burst must be greater than 0 when --proxy-health-check-qps is set"))
+	}
+	if o.MaxConcurrency < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-health-check-max-concurrency must not be negative"))
+	}
+	return errs
+}
+
+func (o *HealthCheckOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Float64Var(&o.Jitter, "proxy-health-check-jitter", o.Jitter, ""+
+		"The jitter factor of endpoint health check intervals, the interval is randomized up to interval*(1+jitter), "+
+		"so checks of endpoints added at the same time do not stay synchronized. Zero means no jitter.")
+	fs.Float32Var(&o.QPS, "proxy-health-check-qps", o.QPS, ""+
+		"The maximum number of endpoint health checks started per second by this gateway replica across all "+
+		"clusters. Zero means unlimited.")
+	fs.IntVar(&o.Burst, "proxy-health-check-burst", o.Burst,
+		"The maximum burst of endpoint health checks started above --proxy-health-check-qps.")
+	fs.IntVar(&o.MaxConcurrency, "proxy-health-check-max-concurrency", o.MaxConcurrency, ""+
+		"The maximum number of endpoint health checks running at the same time across all clusters. "+
+		"Zero means unlimited.")
+}
+
+// ApplyTo sets the scheduler of all endpoint health checks in defaults, it must be called before upstream cluster controller starts.
+func (o *HealthCheckOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	defaults.HealthCheckScheduler = clusters.NewHealthCheckScheduler(o.Jitter, o.QPS, o.Burst, o.MaxConcurrency)
+}
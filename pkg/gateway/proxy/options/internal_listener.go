@@ -0,0 +1,55 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+type InternalListenerOptions struct {
+	H2CBindAddress string
+}
+
+func NewInternalListenerOptions() *InternalListenerOptions {
+	return &InternalListenerOptions{}
+}
+
+func (o *InternalListenerOptions) Validate() []error {
+	if o == nil || len(o.H2CBindAddress) == 0 {
+		return nil
+	}
+	errs := []error{}
+	_, port, err := net.SplitHostPort(o.H2CBindAddress)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid --proxy-internal-h2c-bind-address %q: %v", o.H2CBindAddress, err))
+	} else if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		errs = append(errs, fmt.Errorf("port of --proxy-internal-h2c-bind-address %q must be between 1 and 65535, inclusive", o.H2CBindAddress))
+	}
+	return errs
+}
+
+func (o *InternalListenerOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.H2CBindAddress, "proxy-internal-h2c-bind-address", o.H2CBindAddress, ""+
+		"The plaintext address serving proxy by HTTP/2 without TLS (h2c), e.g. 127.0.0.1:9080, for clients "+
+		"inside a trusted network such as sidecars. HTTP/1.x requests without h2c upgrade are rejected. "+
+		"Client certificates are not available on it, so clients must authenticate by tokens. Empty means disabled.")
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type LoadSheddingOptions struct {
+	Thresholds   []string
+	SystemUsers  []string
+	SystemGroups []string
+}
+
+func NewLoadSheddingOptions() *LoadSheddingOptions {
+	return &LoadSheddingOptions{
+		SystemUsers: []string{
+			user.KubeControllerManager,
+			user.KubeScheduler,
+			user.KubeProxy,
+			user.APIServerUser,
+		},
+		SystemGroups: []string{user.SystemPrivilegedGroup, user.NodesGroup},
+	}
+}
+
+func (o *LoadSheddingOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	if _, err := dispatcher.ParseLoadSheddingPolicy(o.Thresholds); err != nil {
+		return []error{fmt.Errorf("--proxy-load-shedding-thresholds: %v", err)}
+	}
+	return nil
+}
+
+func (o *LoadSheddingOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.Thresholds, "proxy-load-shedding-thresholds", o.Thresholds, ""+
+		"A list of class=threshold, requests of the class are rejected when inflight requests of an upstream cluster "+
+		"reach the threshold ratio of its maxInflightRequests budget, classes with lower thresholds are shed first. "+
+		"Classes are anonymous, low-priority, read and write (mutating requests not from system components), "+
+		"e.g. anonymous=0.5,low-priority=0.7,read=0.85,write=0.95. Empty means requests are only rejected by exhausted budget.")
+	fs.StringSliceVar(&o.SystemUsers, "proxy-load-shedding-system-users", o.SystemUsers, ""+
+		"A list of users which are system components, their mutating requests are not shed by the write class. "+
+		"Service accounts are not system components unless they are listed.")
+	fs.StringSliceVar(&o.SystemGroups, "proxy-load-shedding-system-groups", o.SystemGroups, ""+
+		"A list of groups which are system components, their mutating requests are not shed by the write class.")
+}
+
+// ToLoadSheddingPolicy returns the load shedding policy for dispatcher, nil means load shedding is disabled
+func (o *LoadSheddingOptions) ToLoadSheddingPolicy() *dispatcher.LoadSheddingPolicy {
+	if o == nil || len(o.Thresholds) == 0 {
+		return nil
+	}
+	policy, err := dispatcher.ParseLoadSheddingPolicy(o.Thresholds)
+	if err != nil {
+		return nil
+	}
+	policy.SystemUsers = sets.NewString(o.SystemUsers...)
+	policy.SystemGroups = sets.NewString(o.SystemGroups...)
+	return policy
+}
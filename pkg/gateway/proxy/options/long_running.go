@@ -0,0 +1,68 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	genericapiserver "k8s.io/apiserver/pkg/server"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type LongRunningOptions struct {
+	Rules []string
+}
+
+func NewLongRunningOptions() *LongRunningOptions {
+	return &LongRunningOptions{}
+}
+
+func (o *LongRunningOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	if _, err := dispatcher.ParseLongRunningRules(o.Rules); err != nil {
+		return []error{fmt.Errorf("--proxy-long-running-rule: %v", err)}
+	}
+	return nil
+}
+
+func (o *LongRunningOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringArrayVar(&o.Rules, "proxy-long-running-rule", o.Rules, ""+
+		"A rule matching requests which are long-running in addition to watch, exec, attach, log, portforward and proxy, "+
+		"e.g. custom streaming endpoints of extension apiservers. Long-running requests are not bounded by timeouts of "+
+		"short requests and are drained as streams. A rule is a list of key=value separated by semicolons, keys are "+
+		"verbs (comma separated), path (a regular expression of url path) and query (name or name=value of a query parameter), "+
+		"all set keys must match, e.g. verbs=get;path=^/apis/metrics.example.io/;query=follow=true. It can be repeated.")
+}
+
+// ApplyTo sets the long-running predicate of dispatcher and generic apiserver config, it must be called before serving.
+func (o *LongRunningOptions) ApplyTo(config *genericapiserver.Config) error {
+	if o == nil || len(o.Rules) == 0 {
+		return nil
+	}
+	policy, err := dispatcher.ParseLongRunningRules(o.Rules)
+	if err != nil {
+		return err
+	}
+	dispatcher.DefaultLongRunningPolicy = policy
+	config.LongRunningFunc = dispatcher.IsLongRunning
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+)
+
+type MaintenanceOptions struct {
+	Enabled            bool
+	Kubeconfig         string
+	NodeSelector       string
+	HonorUnschedulable bool
+	ResyncInterval     time.Duration
+}
+
+func NewMaintenanceOptions() *MaintenanceOptions {
+	return &MaintenanceOptions{
+		ResyncInterval: 30 * time.Second,
+	}
+}
+
+func (o *MaintenanceOptions) Validate() []error {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	errs := []error{}
+	if _, err := labels.Parse(o.NodeSelector); err != nil {
+		errs = append(errs, fmt.Errorf("invalid --proxy-maintenance-node-selector %q: %v", o.NodeSelector, err))
+	}
+	if o.ResyncInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-maintenance-resync-interval must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *MaintenanceOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Enabled, "proxy-maintenance-cordon", o.Enabled, ""+
+		"If true, nodes of the management cluster representing upstream control plane machines are watched, "+
+		"and endpoints of a node annotated with "+controllers.MaintenanceAnnotationKey+"=true are cordoned "+
+		"until the annotation is removed. Endpoints of a node are listed by annotation "+
+		controllers.MaintenanceEndpointsAnnotationKey+", or matched by addresses of the node.")
+	fs.StringVar(&o.Kubeconfig, "proxy-maintenance-kubeconfig", o.Kubeconfig, ""+
+		"The kubeconfig file of the management cluster where nodes are watched, empty means in-cluster config.")
+	fs.StringVar(&o.NodeSelector, "proxy-maintenance-node-selector", o.NodeSelector, ""+
+		"The label selector of nodes representing upstream control plane machines, empty means all nodes.")
+	fs.BoolVar(&o.HonorUnschedulable, "proxy-maintenance-honor-unschedulable", o.HonorUnschedulable, ""+
+		"If true, unschedulable nodes, e.g. cordoned by kubectl drain, are treated as under maintenance as well.")
+	fs.DurationVar(&o.ResyncInterval, "proxy-maintenance-resync-interval", o.ResyncInterval,
+		"The interval to reconcile all endpoints, so endpoints added to clusters later are cordoned as well.")
+}
+
+// ApplyTo enables maintenance cordon of upstream controller if configured, it must be called
+// before upstream controller starts.
+func (o *MaintenanceOptions) ApplyTo(controller *controllers.UpstreamClusterController) error {
+	if o == nil || !o.Enabled {
+		return nil
+	}
+	config, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to load --proxy-maintenance-kubeconfig: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	controller.EnableMaintenanceCordon(controllers.MaintenanceConfig{
+		Client:             client,
+		NodeSelector:       o.NodeSelector,
+		HonorUnschedulable: o.HonorUnschedulable,
+		ResyncInterval:     o.ResyncInterval,
+	})
+	return nil
+}
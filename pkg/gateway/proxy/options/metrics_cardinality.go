@@ -0,0 +1,88 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+type MetricsCardinalityOptions struct {
+	MaxUsersPerCluster    int
+	MaxClientsPerCluster  int
+	UserWindow            time.Duration
+	DisableEndpointLabels bool
+}
+
+func NewMetricsCardinalityOptions() *MetricsCardinalityOptions {
+	return &MetricsCardinalityOptions{
+		MaxUsersPerCluster:    metrics.DefaultCardinalityLimits.MaxUsersPerCluster,
+		MaxClientsPerCluster:  metrics.DefaultCardinalityLimits.MaxClientsPerCluster,
+		UserWindow:            metrics.DefaultCardinalityLimits.UserWindow,
+		DisableEndpointLabels: metrics.DefaultCardinalityLimits.DisableEndpointLabels,
+	}
+}
+
+func (o *MetricsCardinalityOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxUsersPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-metrics-max-users-per-cluster must not be negative"))
+	}
+	if o.MaxClientsPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-metrics-max-clients-per-cluster must not be negative"))
+	}
+	if o.UserWindow <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-metrics-user-window must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *MetricsCardinalityOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.IntVar(&o.MaxUsersPerCluster, "proxy-metrics-max-users-per-cluster", o.MaxUsersPerCluster, ""+
+		"The number of users with the most requests to each cluster which have their own user label values in "+
+		"metrics, other users are aggregated as user \"other\". Zero aggregates all users.")
+	fs.IntVar(&o.MaxClientsPerCluster, "proxy-metrics-max-clients-per-cluster", o.MaxClientsPerCluster, ""+
+		"The number of clients, i.e. components of User-Agent, with the most requests to each cluster which have "+
+		"their own client label values in metrics, other clients are aggregated as client \"other\". Zero aggregates all clients.")
+	fs.DurationVar(&o.UserWindow, "proxy-metrics-user-window", o.UserWindow, ""+
+		"How often the top users and clients of each cluster are recomputed, series of users and clients no longer in "+
+		"them are deleted.")
+	fs.BoolVar(&o.DisableEndpointLabels, "proxy-metrics-disable-endpoint-labels", o.DisableEndpointLabels, ""+
+		"If true, request metrics of all endpoints of a cluster are aggregated as endpoint \"all\". "+
+		"Health and probe metrics of endpoints keep their endpoint labels.")
+}
+
+// ApplyTo sets the cardinality limits of all metrics, it must be called before serving.
+func (o *MetricsCardinalityOptions) ApplyTo() {
+	if o == nil {
+		return
+	}
+	metrics.DefaultCardinalityLimits = metrics.CardinalityLimits{
+		MaxUsersPerCluster:    o.MaxUsersPerCluster,
+		MaxClientsPerCluster:  o.MaxClientsPerCluster,
+		UserWindow:            o.UserWindow,
+		DisableEndpointLabels: o.DisableEndpointLabels,
+	}
+}
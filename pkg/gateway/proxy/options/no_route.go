@@ -0,0 +1,92 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type NoRouteOptions struct {
+	Action           string
+	DefaultCluster   string
+	DocumentationURL string
+}
+
+func NewNoRouteOptions() *NoRouteOptions {
+	return &NoRouteOptions{}
+}
+
+func (o *NoRouteOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	switch dispatcher.NoRouteAction(o.Action) {
+	case "", dispatcher.NoRouteNotFound:
+	case dispatcher.NoRouteDefaultCluster:
+		if len(o.DefaultCluster) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-no-route-default-cluster must be set when --proxy-no-route-action=%s", o.Action))
+		}
+	case dispatcher.NoRouteRedirect:
+		if len(o.DocumentationURL) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-no-route-documentation-url must be set when --proxy-no-route-action=%s", o.Action))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid --proxy-no-route-action %q, must be one of %s, %s and %s",
+			o.Action, dispatcher.NoRouteNotFound, dispatcher.NoRouteDefaultCluster, dispatcher.NoRouteRedirect))
+	}
+	if len(o.DocumentationURL) > 0 {
+		if u, err := url.Parse(o.DocumentationURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-no-route-documentation-url must be an absolute http or https url"))
+		}
+	}
+	return errs
+}
+
+func (o *NoRouteOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Action, "proxy-no-route-action", o.Action, ""+
+		"What to do with requests whose hostname is not an upstream cluster being proxied, or which match no dispatch "+
+		"policy of their cluster. NotFound responds 404 Status telling what is not matched, DefaultCluster routes "+
+		"requests of unknown hostnames to --proxy-no-route-default-cluster and responds 404 to requests matching no "+
+		"dispatch policy, Redirect redirects both to --proxy-no-route-documentation-url. Empty keeps the legacy "+
+		"responses, 503 to unknown hostnames and 500 to requests matching no dispatch policy, which clients retry. "+
+		"Other actions also apply before upstream clusters are synced after gateway starts, when every hostname is unknown.")
+	fs.StringVar(&o.DefaultCluster, "proxy-no-route-default-cluster", o.DefaultCluster,
+		"The upstream cluster requests of unknown hostnames are routed to when --proxy-no-route-action=DefaultCluster.")
+	fs.StringVar(&o.DocumentationURL, "proxy-no-route-documentation-url", o.DocumentationURL, ""+
+		"The documentation url requests are redirected to when --proxy-no-route-action=Redirect, it is also "+
+		"linked in messages of 404 responses.")
+}
+
+// ToNoRoutePolicy returns the policy of requests matching no route for dispatcher, nil means the legacy responses
+func (o *NoRouteOptions) ToNoRoutePolicy() *dispatcher.NoRoutePolicy {
+	if o == nil || len(o.Action) == 0 {
+		return nil
+	}
+	return &dispatcher.NoRoutePolicy{
+		Action:           dispatcher.NoRouteAction(o.Action),
+		DefaultCluster:   strings.ToLower(o.DefaultCluster),
+		DocumentationURL: o.DocumentationURL,
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/notification"
+)
+
+type NotificationOptions struct {
+	WebhookURL    string
+	SecretFile    string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	RetryBackoff  time.Duration
+	QueueSize     int
+}
+
+func NewNotificationOptions() *NotificationOptions {
+	return &NotificationOptions{
+		BatchSize:     50,
+		FlushInterval: 5 * time.Second,
+		MaxRetries:    5,
+		RetryBackoff:  time.Second,
+		QueueSize:     1000,
+	}
+}
+
+func (o *NotificationOptions) Validate() []error {
+	if o == nil || len(o.WebhookURL) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if u, err := url.Parse(o.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		errs = append(errs, fmt.Errorf("--proxy-notification-webhook-url must be an absolute http or https url"))
+	}
+	if o.BatchSize <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-notification-batch-size must be greater than 0"))
+	}
+	if o.FlushInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-notification-flush-interval must be greater than 0"))
+	}
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-notification-max-retries must not be negative"))
+	}
+	if o.MaxRetries > 0 && o.RetryBackoff <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-notification-retry-backoff must be greater than 0"))
+	}
+	if o.QueueSize < o.BatchSize {
+		errs = append(errs, fmt.Errorf("--proxy-notification-queue-size must not be less than --proxy-notification-batch-size"))
+	}
+	return errs
+}
+
+func (o *NotificationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.WebhookURL, "proxy-notification-webhook-url", o.WebhookURL, ""+
+		"If set, significant decisions of gateway, i.e. circuit breaker of a cluster opened or closed by DenyAllRequests, "+
+		"a cluster losing all ready endpoints or recovering, and an endpoint becoming unhealthy or recovering, "+
+		"are posted to the url in JSON batches for incident automation.")
+	fs.StringVar(&o.SecretFile, "proxy-notification-secret-file", o.SecretFile, ""+
+		"The file containing the secret to sign batches by HMAC-SHA256, the signature of \"<timestamp>.<body>\" is sent in header "+
+		notification.SignatureHeader+" and the timestamp in header "+notification.TimestampHeader+". Empty means batches are not signed.")
+	fs.IntVar(&o.BatchSize, "proxy-notification-batch-size", o.BatchSize,
+		"The maximum number of events posted in one batch.")
+	fs.DurationVar(&o.FlushInterval, "proxy-notification-flush-interval", o.FlushInterval,
+		"The maximum duration an event waits before it is posted in a batch which is not full.")
+	fs.IntVar(&o.MaxRetries, "proxy-notification-max-retries", o.MaxRetries, ""+
+		"The number of retries of a batch after connection errors, 429 or 5xx responses, the batch is dropped after them.")
+	fs.DurationVar(&o.RetryBackoff, "proxy-notification-retry-backoff", o.RetryBackoff,
+		"The wait before the first retry of a batch, it doubles for each following retry.")
+	fs.IntVar(&o.QueueSize, "proxy-notification-queue-size", o.QueueSize,
+		"The maximum number of events waiting to be posted, events beyond it are dropped.")
+}
+
+// ApplyTo sets and starts the webhook sink of notifications if configured, it must be called
+// before upstream cluster controller starts.
+func (o *NotificationOptions) ApplyTo() error {
+	if o == nil || len(o.WebhookURL) == 0 {
+		return nil
+	}
+	var secret []byte
+	if len(o.SecretFile) > 0 {
+		data, err := ioutil.ReadFile(o.SecretFile)
+		if err != nil {
+			return fmt.Errorf("failed to read --proxy-notification-secret-file: %v", err)
+		}
+		secret = bytes.TrimSpace(data)
+		if len(secret) == 0 {
+			return fmt.Errorf("--proxy-notification-secret-file %q is empty", o.SecretFile)
+		}
+	}
+	sink := notification.NewWebhookSink(notification.WebhookConfig{
+		URL:           o.WebhookURL,
+		Secret:        secret,
+		BatchSize:     o.BatchSize,
+		FlushInterval: o.FlushInterval,
+		MaxRetries:    o.MaxRetries,
+		RetryBackoff:  o.RetryBackoff,
+		QueueSize:     o.QueueSize,
+	})
+	go sink.Run(wait.NeverStop)
+	notification.Default = sink
+	return nil
+}
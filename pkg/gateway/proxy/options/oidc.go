@@ -0,0 +1,152 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apiserver/plugin/pkg/authenticator/token/oidc"
+	cliflag "k8s.io/component-base/cli/flag"
+)
+
+// OIDCIssuerOptions configures an OpenID Connect issuer whose ID tokens are validated by gateway locally
+type OIDCIssuerOptions struct {
+	IssuerURL      string
+	ClientID       string
+	CAFile         string
+	UsernameClaim  string
+	UsernamePrefix string
+	GroupsClaim    string
+	GroupsPrefix   string
+	SigningAlgs    []string
+	RequiredClaims map[string]string
+}
+
+// OIDCAuthenticationOptions validates bearer tokens issued by OIDC issuers at gateway, so
+// they are not sent to upstream TokenReview.
+type OIDCAuthenticationOptions struct {
+	// OIDCIssuerOptions is the issuer configured by flags
+	OIDCIssuerOptions
+	// AdditionalIssuers are configured by config file
+	AdditionalIssuers []OIDCIssuerOptions
+}
+
+func NewOIDCAuthenticationOptions() *OIDCAuthenticationOptions {
+	return &OIDCAuthenticationOptions{
+		OIDCIssuerOptions: OIDCIssuerOptions{
+			UsernameClaim: "sub",
+			SigningAlgs:   []string{"RS256"},
+		},
+	}
+}
+
+// Issuers returns all configured issuers
+func (o *OIDCAuthenticationOptions) Issuers() []OIDCIssuerOptions {
+	if o == nil {
+		return nil
+	}
+	issuers := []OIDCIssuerOptions{}
+	if len(o.IssuerURL) > 0 {
+		issuers = append(issuers, o.OIDCIssuerOptions)
+	}
+	return append(issuers, o.AdditionalIssuers...)
+}
+
+func (o *OIDCAuthenticationOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if len(o.IssuerURL) == 0 && len(o.ClientID) > 0 {
+		errs = append(errs, fmt.Errorf("--proxy-oidc-issuer-url must be set when --proxy-oidc-client-id is set"))
+	}
+	seen := map[string]bool{}
+	for _, issuer := range o.Issuers() {
+		if seen[issuer.IssuerURL] {
+			errs = append(errs, fmt.Errorf("oidc issuer %q is configured more than once", issuer.IssuerURL))
+		}
+		seen[issuer.IssuerURL] = true
+		errs = append(errs, issuer.Validate()...)
+	}
+	return errs
+}
+
+func (o *OIDCIssuerOptions) Validate() []error {
+	errs := []error{}
+	u, err := url.Parse(o.IssuerURL)
+	if err != nil || u.Scheme != "https" {
+		errs = append(errs, fmt.Errorf("oidc issuer url %q must be a valid https url", o.IssuerURL))
+	}
+	if len(o.ClientID) == 0 {
+		errs = append(errs, fmt.Errorf("oidc client id of issuer %q must be set", o.IssuerURL))
+	}
+	if len(o.UsernameClaim) == 0 {
+		errs = append(errs, fmt.Errorf("oidc username claim of issuer %q must be set", o.IssuerURL))
+	}
+	return errs
+}
+
+func (o *OIDCAuthenticationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.IssuerURL, "proxy-oidc-issuer-url", o.IssuerURL, ""+
+		"The URL of the OpenID issuer, only HTTPS scheme will be accepted. If set, ID tokens issued by it "+
+		"are validated by gateway with cached JWKS instead of upstream TokenReview. More issuers can be "+
+		"configured by authentication.oidcIssuers of --config.")
+	fs.StringVar(&o.ClientID, "proxy-oidc-client-id", o.ClientID,
+		"The client ID for the OpenID Connect client, must be set if --proxy-oidc-issuer-url is set.")
+	fs.StringVar(&o.CAFile, "proxy-oidc-ca-file", o.CAFile, ""+
+		"If set, the OpenID server's certificate will be verified by one of the authorities in the file, "+
+		"otherwise the host's root CA set will be used.")
+	fs.StringVar(&o.UsernameClaim, "proxy-oidc-username-claim", o.UsernameClaim,
+		"The OpenID claim to use as the user name.")
+	fs.StringVar(&o.UsernamePrefix, "proxy-oidc-username-prefix", o.UsernamePrefix,
+		"If provided, all usernames will be prefixed with this value.")
+	fs.StringVar(&o.GroupsClaim, "proxy-oidc-groups-claim", o.GroupsClaim,
+		"If provided, the name of a custom OpenID Connect claim for specifying user groups.")
+	fs.StringVar(&o.GroupsPrefix, "proxy-oidc-groups-prefix", o.GroupsPrefix,
+		"If provided, all groups will be prefixed with this value.")
+	fs.StringSliceVar(&o.SigningAlgs, "proxy-oidc-signing-algs", o.SigningAlgs,
+		"Comma-separated list of allowed JOSE asymmetric signing algorithms.")
+	fs.Var(cliflag.NewMapStringStringNoSplit(&o.RequiredClaims), "proxy-oidc-required-claim", ""+
+		"A key=value pair that describes a required claim in the ID Token. "+
+		"Repeat this flag to specify multiple claims.")
+}
+
+// ToOIDCOptions returns options to create oidc token authenticators
+func (o *OIDCAuthenticationOptions) ToOIDCOptions() []oidc.Options {
+	issuers := o.Issuers()
+	if len(issuers) == 0 {
+		return nil
+	}
+	opts := make([]oidc.Options, 0, len(issuers))
+	for _, issuer := range issuers {
+		opts = append(opts, oidc.Options{
+			IssuerURL:            issuer.IssuerURL,
+			ClientID:             issuer.ClientID,
+			CAFile:               issuer.CAFile,
+			UsernameClaim:        issuer.UsernameClaim,
+			UsernamePrefix:       issuer.UsernamePrefix,
+			GroupsClaim:          issuer.GroupsClaim,
+			GroupsPrefix:         issuer.GroupsPrefix,
+			SupportedSigningAlgs: issuer.SigningAlgs,
+			RequiredClaims:       issuer.RequiredClaims,
+		})
+	}
+	return opts
+}
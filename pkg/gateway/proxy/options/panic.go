@@ -0,0 +1,75 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+)
+
+type PanicOptions struct {
+	Response      string
+	MaxRecords    int
+	CaptureStacks bool
+}
+
+func NewPanicOptions() *PanicOptions {
+	return &PanicOptions{
+		Response:      gatewaydebug.DefaultPanicPolicy.Response,
+		MaxRecords:    gatewaydebug.DefaultMaxPanicRecords,
+		CaptureStacks: gatewaydebug.DefaultPanicPolicy.CaptureStacks,
+	}
+}
+
+func (o *PanicOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if !sets.NewString(gatewaydebug.PanicResponses...).Has(o.Response) {
+		errs = append(errs, fmt.Errorf("--proxy-panic-response must be one of %v", gatewaydebug.PanicResponses))
+	}
+	if o.MaxRecords < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-panic-max-records must not be negative"))
+	}
+	return errs
+}
+
+func (o *PanicOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Response, "proxy-panic-response", o.Response, ""+
+		"How a proxied request is responded if the reverse proxy panics, one of close-connection and status. "+
+		"close-connection aborts the response and closes the connection (or sends GOAWAY for http2), "+
+		"status returns 500 Status if the response header is not written yet.")
+	fs.IntVar(&o.MaxRecords, "proxy-panic-max-records", o.MaxRecords, ""+
+		"The number of the most recent recovered panics kept in memory, they can be retrieved by "+
+		gatewaydebug.PanicsPath+" of control plane server. Zero means no panic is kept.")
+	fs.BoolVar(&o.CaptureStacks, "proxy-panic-capture-stacks", o.CaptureStacks, ""+
+		"If true, stacks of panicking goroutines are captured into the kept panics.")
+}
+
+// ApplyTo sets the policy of recovered panics, it must be called before serving.
+func (o *PanicOptions) ApplyTo() {
+	if o == nil {
+		return
+	}
+	gatewaydebug.DefaultPanicPolicy = gatewaydebug.NewPanicPolicy(o.Response, o.MaxRecords, o.CaptureStacks)
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type RateLimitExemptionOptions struct {
+	Users  []string
+	Groups []string
+}
+
+func NewRateLimitExemptionOptions() *RateLimitExemptionOptions {
+	return &RateLimitExemptionOptions{}
+}
+
+func (o *RateLimitExemptionOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	for _, u := range o.Users {
+		if len(u) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-rate-limit-exempt-users must not contain empty user"))
+		}
+	}
+	for _, g := range o.Groups {
+		if len(g) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-rate-limit-exempt-groups must not contain empty group"))
+		}
+	}
+	return errs
+}
+
+func (o *RateLimitExemptionOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.Users, "proxy-rate-limit-exempt-users", o.Users, ""+
+		"A list of users (e.g. system:serviceaccount:kube-system:cluster-autoscaler) whose requests bypass "+
+		"flow control and resource budget of all upstream clusters.")
+	fs.StringSliceVar(&o.Groups, "proxy-rate-limit-exempt-groups", o.Groups, ""+
+		"A list of groups (e.g. system:masters) whose requests bypass flow control and resource budget "+
+		"of all upstream clusters.")
+}
+
+// ToRateLimitExemption returns the rate limit exemption for dispatcher, nil means no client is exempted
+func (o *RateLimitExemptionOptions) ToRateLimitExemption() *dispatcher.RateLimitExemption {
+	if o == nil || (len(o.Users) == 0 && len(o.Groups) == 0) {
+		return nil
+	}
+	return &dispatcher.RateLimitExemption{
+		Users:  sets.NewString(o.Users...),
+		Groups: sets.NewString(o.Groups...),
+	}
+}
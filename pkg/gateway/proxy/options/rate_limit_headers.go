@@ -0,0 +1,46 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/pflag"
+)
+
+type RateLimitHeadersOptions struct {
+	Enabled bool
+}
+
+func NewRateLimitHeadersOptions() *RateLimitHeadersOptions {
+	return &RateLimitHeadersOptions{}
+}
+
+func (o *RateLimitHeadersOptions) Validate() []error {
+	return nil
+}
+
+func (o *RateLimitHeadersOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Enabled, "proxy-enable-rate-limit-headers", o.Enabled, ""+
+		"If true, responses of requests limited by flow control carry RateLimit-Limit, RateLimit-Remaining "+
+		"and RateLimit-Reset headers computed from the client's flow control bucket, so that clients can "+
+		"adapt their pacing before being rejected by 429.")
+}
+
+// ToRateLimitHeaders returns true if dispatcher sets RateLimit-* response headers
+func (o *RateLimitHeadersOptions) ToRateLimitHeaders() bool {
+	return o != nil && o.Enabled
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type RequestPriorityOptions struct {
+	HighUsers     []string
+	HighGroups    []string
+	LowUsers      []string
+	LowGroups     []string
+	LowUserAgents []string
+	QueueTimeout  time.Duration
+}
+
+func NewRequestPriorityOptions() *RequestPriorityOptions {
+	return &RequestPriorityOptions{}
+}
+
+func (o *RequestPriorityOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	for flag, values := range map[string][]string{
+		"--proxy-high-priority-users":      o.HighUsers,
+		"--proxy-high-priority-groups":     o.HighGroups,
+		"--proxy-low-priority-users":       o.LowUsers,
+		"--proxy-low-priority-groups":      o.LowGroups,
+		"--proxy-low-priority-user-agents": o.LowUserAgents,
+	} {
+		for _, v := range values {
+			if len(v) == 0 {
+				errs = append(errs, fmt.Errorf("%s must not contain empty value", flag))
+				break
+			}
+		}
+	}
+	if o.QueueTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-priority-queue-timeout must not be negative"))
+	}
+	return errs
+}
+
+func (o *RequestPriorityOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.HighUsers, "proxy-high-priority-users", o.HighUsers, ""+
+		"A list of authenticated users (e.g. system:kube-scheduler) whose requests are admitted before "+
+		"other requests waiting for resource budget of upstream clusters.")
+	fs.StringSliceVar(&o.HighGroups, "proxy-high-priority-groups", o.HighGroups, ""+
+		"A list of authenticated groups (e.g. system:nodes) whose requests are admitted before "+
+		"other requests waiting for resource budget of upstream clusters.")
+	fs.StringSliceVar(&o.LowUsers, "proxy-low-priority-users", o.LowUsers, ""+
+		"A list of authenticated users whose requests are admitted after other requests waiting for resource "+
+		"budget of upstream clusters.")
+	fs.StringSliceVar(&o.LowGroups, "proxy-low-priority-groups", o.LowGroups, ""+
+		"A list of authenticated groups whose requests are admitted after other requests waiting for resource "+
+		"budget of upstream clusters.")
+	fs.StringSliceVar(&o.LowUserAgents, "proxy-low-priority-user-agents", o.LowUserAgents, ""+
+		"A list of User-Agent patterns whose requests have low priority even if their users have high priority, "+
+		"matched like userAgents of annotation "+clusters.UserAgentRulesAnnotationKey+", e.g. reporter or reporter/v1.*. "+
+		"User-Agent never raises priority. Clients can also lower their priority by header "+dispatcher.RequestPriorityHeader+": low.")
+	fs.DurationVar(&o.QueueTimeout, "proxy-priority-queue-timeout", o.QueueTimeout, ""+
+		"The maximum duration requests wait in priority queues when maxInflightRequests of upstream cluster's "+
+		"resource budget is exhausted. 0 means requests are rejected immediately regardless of priority.")
+}
+
+// ToPriorityPolicy returns the priority policy for dispatcher, nil means all requests have the same priority
+func (o *RequestPriorityOptions) ToPriorityPolicy() *dispatcher.PriorityPolicy {
+	if o == nil || (len(o.HighUsers) == 0 && len(o.HighGroups) == 0 && len(o.LowUsers) == 0 &&
+		len(o.LowGroups) == 0 && len(o.LowUserAgents) == 0 && o.QueueTimeout == 0) {
+		return nil
+	}
+	return &dispatcher.PriorityPolicy{
+		HighUsers:     sets.NewString(o.HighUsers...),
+		HighGroups:    sets.NewString(o.HighGroups...),
+		LowUsers:      sets.NewString(o.LowUsers...),
+		LowGroups:     sets.NewString(o.LowGroups...),
+		LowUserAgents: o.LowUserAgents,
+		QueueTimeout:  o.QueueTimeout,
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/endpoints/filters"
+)
+
+type RequestValidationOptions struct {
+	Strict         bool
+	MaxURIBytes    int
+	MaxHeaderBytes int
+}
+
+func NewRequestValidationOptions() *RequestValidationOptions {
+	return &RequestValidationOptions{
+		MaxURIBytes:    16 * 1024,
+		MaxHeaderBytes: 64 * 1024,
+	}
+}
+
+func (o *RequestValidationOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxURIBytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-request-uri-bytes must not be negative"))
+	}
+	if o.MaxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-request-header-bytes must not be negative"))
+	}
+	return errs
+}
+
+func (o *RequestValidationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.BoolVar(&o.Strict, "proxy-strict-request-validation", o.Strict, ""+
+		"If true, requests with ambiguous Content-Length and Transfer-Encoding, illegal header characters, "+
+		"or URI and headers exceeding the limits below are rejected before proxying, and the rejections "+
+		"are logged with client address and user agent.")
+	fs.IntVar(&o.MaxURIBytes, "proxy-max-request-uri-bytes", o.MaxURIBytes,
+		"The maximum length of request URI accepted by strict request validation. Zero means unlimited.")
+	fs.IntVar(&o.MaxHeaderBytes, "proxy-max-request-header-bytes", o.MaxHeaderBytes,
+		"The maximum total size of request headers accepted by strict request validation. Zero means unlimited.")
+}
+
+// ToRequestValidation returns the request validation of handler chain, nil means strict validation is disabled
+func (o *RequestValidationOptions) ToRequestValidation() *filters.RequestValidation {
+	if o == nil || !o.Strict {
+		return nil
+	}
+	return &filters.RequestValidation{
+		MaxURIBytes:    o.MaxURIBytes,
+		MaxHeaderBytes: o.MaxHeaderBytes,
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type ResourceBudgetOptions struct {
+	MaxInflightRequestsPerCluster int32
+	MaxPendingDialsPerCluster     int32
+	MaxWatchesPerUserPerCluster   int32
+	WatchLimitOverrides           map[string]int
+}
+
+func NewResourceBudgetOptions() *ResourceBudgetOptions {
+	return &ResourceBudgetOptions{
+		MaxInflightRequestsPerCluster: 0,
+		MaxPendingDialsPerCluster:     0,
+		MaxWatchesPerUserPerCluster:   0,
+		WatchLimitOverrides:           map[string]int{},
+	}
+}
+
+func (o *ResourceBudgetOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxInflightRequestsPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-inflight-requests-per-cluster must not be negative"))
+	}
+	if o.MaxPendingDialsPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-pending-dials-per-cluster must not be negative"))
+	}
+	if o.MaxWatchesPerUserPerCluster < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-watches-per-user-per-cluster must not be negative"))
+	}
+	for user, max := range o.WatchLimitOverrides {
+		if len(user) == 0 {
+			errs = append(errs, fmt.Errorf("--proxy-watch-limit-overrides must not contain empty user"))
+		}
+		if max < 0 || max > math.MaxInt32 {
+			errs = append(errs, fmt.Errorf("--proxy-watch-limit-overrides has invalid limit %d of user %q", max, user))
+		}
+	}
+	return errs
+}
+
+func (o *ResourceBudgetOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Int32Var(&o.MaxInflightRequestsPerCluster, "proxy-max-inflight-requests-per-cluster", o.MaxInflightRequestsPerCluster, ""+
+		"The maximum number of non-long-running requests being proxied to one upstream cluster at the same time. "+
+		"It prevents a slow cluster from exhausting resources shared with other clusters. "+
+		"It can be overridden by annotation "+clusters.ResourceBudgetAnnotationKey+" of each cluster. Zero means no limit. "+
+		"It takes effect only if feature gate ClusterResourceBudget is enabled.")
+	fs.Int32Var(&o.MaxPendingDialsPerCluster, "proxy-max-pending-dials-per-cluster", o.MaxPendingDialsPerCluster, ""+
+		"The maximum number of pending connection dials to one upstream cluster, dials exceeding it fail fast. "+
+		"It can be overridden by annotation "+clusters.ResourceBudgetAnnotationKey+" of each cluster. Zero means no limit.")
+	fs.Int32Var(&o.MaxWatchesPerUserPerCluster, "proxy-max-watches-per-user-per-cluster", o.MaxWatchesPerUserPerCluster, ""+
+		"The maximum number of concurrent watch streams of one user to one upstream cluster, watches exceeding it "+
+		"are rejected with 429. It can be overridden by annotation "+clusters.ResourceBudgetAnnotationKey+" of each cluster "+
+		"and --proxy-watch-limit-overrides of each user. Zero means no limit. "+
+		"It takes effect only if feature gate ClusterResourceBudget is enabled.")
+	fs.StringToIntVar(&o.WatchLimitOverrides, "proxy-watch-limit-overrides", o.WatchLimitOverrides, ""+
+		"A list of user=limit pairs which override the maximum number of concurrent watch streams to each upstream "+
+		"cluster for specific users, e.g. system:serviceaccount:kube-system:kube-controller-manager=5000. Zero means no limit.")
+}
+
+// ApplyTo sets the default resource budget of all upstream clusters in defaults, it must be called before
+// upstream cluster controller starts.
+func (o *ResourceBudgetOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	defaults.ResourceBudget = clusters.ResourceBudget{
+		MaxInflightRequests: o.MaxInflightRequestsPerCluster,
+		MaxPendingDials:     o.MaxPendingDialsPerCluster,
+		MaxWatchesPerUser:   o.MaxWatchesPerUserPerCluster,
+	}
+	overrides := make(map[string]int32, len(o.WatchLimitOverrides))
+	for user, max := range o.WatchLimitOverrides {
+		overrides[user] = int32(max)
+	}
+	defaults.WatchLimitOverrides = overrides
+}
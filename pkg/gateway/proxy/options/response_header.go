@@ -0,0 +1,81 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type ResponseHeaderOptions struct {
+	MaxHeaderBytes int64
+	Trailers       []string
+}
+
+func NewResponseHeaderOptions() *ResponseHeaderOptions {
+	return &ResponseHeaderOptions{
+		MaxHeaderBytes: 0,
+		Trailers:       []string{"*"},
+	}
+}
+
+func (o *ResponseHeaderOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxHeaderBytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-max-response-header-bytes must not be negative"))
+	}
+	for _, t := range o.Trailers {
+		if t == "*" && len(o.Trailers) > 1 {
+			errs = append(errs, fmt.Errorf("--proxy-upstream-response-trailers must not contain other names with *"))
+		}
+	}
+	return errs
+}
+
+func (o *ResponseHeaderOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Int64Var(&o.MaxHeaderBytes, "proxy-upstream-max-response-header-bytes", o.MaxHeaderBytes, ""+
+		"The maximum size of upstream response headers, responses with larger headers are rejected with 502 "+
+		"and attributed to the cluster and endpoint. Zero means the default limit of transports (10MB).")
+	fs.StringSliceVar(&o.Trailers, "proxy-upstream-response-trailers", o.Trailers, ""+
+		"A list of upstream response trailer names propagated to clients, other trailers are dropped. "+
+		"* means all trailers are propagated and an empty list drops all trailers.")
+}
+
+// ApplyTo sets the response header policy of all upstream endpoints in defaults, it must be called before
+// upstream cluster controller starts.
+func (o *ResponseHeaderOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	policy := clusters.ResponseHeaderPolicy{MaxHeaderBytes: o.MaxHeaderBytes}
+	if !sets.NewString(o.Trailers...).Has("*") {
+		policy.Trailers = sets.NewString()
+		for _, t := range o.Trailers {
+			policy.Trailers.Insert(http.CanonicalHeaderKey(t))
+		}
+	}
+	defaults.ResponseHeaderPolicy = policy
+}
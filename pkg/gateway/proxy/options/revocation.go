@@ -0,0 +1,103 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/authentication/revocation"
+)
+
+// ClientCertRevocationOptions rejects revoked client certificates by CRL files or OCSP
+type ClientCertRevocationOptions struct {
+	CRLFiles          []string
+	CRLReloadInterval time.Duration
+	OCSP              bool
+	OCSPTimeout       time.Duration
+	OCSPCacheTTL      time.Duration
+	OCSPFailClosed    bool
+}
+
+func NewClientCertRevocationOptions() *ClientCertRevocationOptions {
+	return &ClientCertRevocationOptions{
+		CRLReloadInterval: 10 * time.Second,
+		OCSPTimeout:       2 * time.Second,
+		OCSPCacheTTL:      5 * time.Minute,
+	}
+}
+
+func (o *ClientCertRevocationOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if len(o.CRLFiles) > 0 && o.CRLReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-client-cert-crl-reload-interval must be greater than 0"))
+	}
+	if o.OCSP && o.OCSPTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-client-cert-ocsp-timeout must be greater than 0"))
+	}
+	if o.OCSP && o.OCSPCacheTTL <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-client-cert-ocsp-cache-ttl must be greater than 0"))
+	}
+	return errs
+}
+
+func (o *ClientCertRevocationOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.CRLFiles, "proxy-client-cert-crl-files", o.CRLFiles, ""+
+		"A list of CRL files in PEM or DER format, client certificates revoked by them are rejected. "+
+		"Files are reloaded when they are modified.")
+	fs.DurationVar(&o.CRLReloadInterval, "proxy-client-cert-crl-reload-interval", o.CRLReloadInterval,
+		"The interval to check whether CRL files are modified.")
+	fs.BoolVar(&o.OCSP, "proxy-client-cert-ocsp", o.OCSP, ""+
+		"If true, client certificates with OCSP responders in their AuthorityInfoAccess extension are checked by OCSP.")
+	fs.DurationVar(&o.OCSPTimeout, "proxy-client-cert-ocsp-timeout", o.OCSPTimeout,
+		"The timeout of requests to OCSP responders.")
+	fs.DurationVar(&o.OCSPCacheTTL, "proxy-client-cert-ocsp-cache-ttl", o.OCSPCacheTTL,
+		"The maximum duration to cache OCSP responses, responses are never cached after their next update.")
+	fs.BoolVar(&o.OCSPFailClosed, "proxy-client-cert-ocsp-fail-closed", o.OCSPFailClosed, ""+
+		"If true, client certificates are rejected when their OCSP status can not be fetched or is unknown, "+
+		"otherwise they are accepted.")
+}
+
+// ToChecker returns the revocation checker of client certificates, nil means revocation is not checked
+func (o *ClientCertRevocationOptions) ToChecker() (revocation.Checker, error) {
+	if o == nil {
+		return nil, nil
+	}
+	checkers := []revocation.Checker{}
+	if len(o.CRLFiles) > 0 {
+		crl, err := revocation.NewCRLChecker(o.CRLFiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --proxy-client-cert-crl-files: %v", err)
+		}
+		go crl.Run(o.CRLReloadInterval, wait.NeverStop)
+		checkers = append(checkers, crl)
+	}
+	if o.OCSP {
+		checkers = append(checkers, revocation.NewOCSPChecker(o.OCSPTimeout, o.OCSPCacheTTL, o.OCSPFailClosed))
+	}
+	if len(checkers) == 0 {
+		return nil, nil
+	}
+	return revocation.NewUnion(checkers...), nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+	"github.com/kubewharf/kubegateway/pkg/gateway/tunables"
+)
+
+type RuntimeOptions struct {
+	GOMAXPROCS     int
+	GCPercent      int
+	MemoryLimit    string
+	CopyBufferSize int
+}
+
+// NewRuntimeOptions creates options defaulted to the tunables of process, which respect
+// GOMAXPROCS, GOGC and GOMEMLIMIT environments.
+func NewRuntimeOptions() *RuntimeOptions {
+	current := tunables.Current()
+	return &RuntimeOptions{
+		GOMAXPROCS:     current.GOMAXPROCS,
+		GCPercent:      current.GCPercent,
+		MemoryLimit:    resource.NewQuantity(current.MemoryLimit, resource.BinarySI).String(),
+		CopyBufferSize: current.CopyBufferSize,
+	}
+}
+
+func (o *RuntimeOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	t, err := o.toTunables()
+	if err != nil {
+		return []error{err}
+	}
+	if err := t.Validate(); err != nil {
+		return []error{fmt.Errorf("invalid runtime tunables: %v", err)}
+	}
+	return nil
+}
+
+func (o *RuntimeOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.IntVar(&o.GOMAXPROCS, "proxy-gomaxprocs", o.GOMAXPROCS,
+		"The maximum number of CPUs executing simultaneously, it defaults to GOMAXPROCS environment or the number of CPUs.")
+	fs.IntVar(&o.GCPercent, "proxy-gc-percent", o.GCPercent, ""+
+		"The GC target percentage, it defaults to GOGC environment or 100. -1 disables GC until --proxy-memory-limit is reached.")
+	fs.StringVar(&o.MemoryLimit, "proxy-memory-limit", o.MemoryLimit, ""+
+		"The soft memory limit of gateway process, e.g. 4Gi, it defaults to GOMEMLIMIT environment. 0 means unlimited. "+
+		"Non-zero values require gateway built with go1.19 or later.")
+	fs.IntVar(&o.CopyBufferSize, "proxy-copy-buffer-size", o.CopyBufferSize, ""+
+		"The size in bytes of buffers copying proxied response bodies. "+
+		"All runtime tunables can be changed live by "+gatewaydebug.TunablesPath+" of control plane server.")
+}
+
+// ApplyTo applies the runtime tunables, it must be called before serving.
+func (o *RuntimeOptions) ApplyTo() error {
+	if o == nil {
+		return nil
+	}
+	t, err := o.toTunables()
+	if err != nil {
+		return err
+	}
+	return tunables.Apply(t)
+}
+
+func (o *RuntimeOptions) toTunables() (tunables.Tunables, error) {
+	limit, err := resource.ParseQuantity(o.MemoryLimit)
+	if err != nil {
+		return tunables.Tunables{}, fmt.Errorf("invalid --proxy-memory-limit %q: %v", o.MemoryLimit, err)
+	}
+	return tunables.Tunables{
+		GOMAXPROCS:     o.GOMAXPROCS,
+		GCPercent:      o.GCPercent,
+		MemoryLimit:    limit.Value(),
+		CopyBufferSize: o.CopyBufferSize,
+	}, nil
+}
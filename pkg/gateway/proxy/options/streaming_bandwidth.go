@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type StreamingBandwidthOptions struct {
+	MaxBytesPerSession int64
+	MaxBytesPerUser    int64
+	DisableSplice      bool
+}
+
+func NewStreamingBandwidthOptions() *StreamingBandwidthOptions {
+	return &StreamingBandwidthOptions{}
+}
+
+func (o *StreamingBandwidthOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxBytesPerSession < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-bandwidth-per-streaming-session must not be negative"))
+	}
+	if o.MaxBytesPerUser < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-max-bandwidth-per-user must not be negative"))
+	}
+	return errs
+}
+
+func (o *StreamingBandwidthOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.Int64Var(&o.MaxBytesPerSession, "proxy-max-bandwidth-per-streaming-session", o.MaxBytesPerSession, ""+
+		"The maximum bytes per second of each streaming session, e.g. logs -f, exec, attach and port-forward, "+
+		"in both directions. Zero means unlimited.")
+	fs.Int64Var(&o.MaxBytesPerUser, "proxy-max-bandwidth-per-user", o.MaxBytesPerUser, ""+
+		"The maximum bytes per second of all streaming sessions of one user together. Zero means unlimited.")
+	fs.BoolVar(&o.DisableSplice, "proxy-disable-stream-splice", o.DisableSplice, ""+
+		"If true, upgraded streams, e.g. exec, attach and port-forward, are always copied in user space. "+
+		"Otherwise streams between plaintext tcp connections are spliced in kernel on linux.")
+}
+
+// SpliceDisabled returns true if upgraded streams must not be spliced in kernel
+func (o *StreamingBandwidthOptions) SpliceDisabled() bool {
+	return o != nil && o.DisableSplice
+}
+
+// ToBandwidthPolicy returns the bandwidth policy for dispatcher, nil means streaming sessions are not throttled
+func (o *StreamingBandwidthOptions) ToBandwidthPolicy() *dispatcher.BandwidthPolicy {
+	if o == nil || (o.MaxBytesPerSession <= 0 && o.MaxBytesPerUser <= 0) {
+		return nil
+	}
+	return dispatcher.NewBandwidthPolicy(o.MaxBytesPerSession, o.MaxBytesPerUser)
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+	gatewaynet "github.com/kubewharf/kubegateway/pkg/gateway/net"
+)
+
+// allListeners is the port of listener tls policy which applies to all proxy listeners
+const allListeners = "*"
+
+type TLSPolicyOptions struct {
+	ListenerPolicies []string
+	UpstreamPolicy   string
+}
+
+func NewTLSPolicyOptions() *TLSPolicyOptions {
+	return &TLSPolicyOptions{}
+}
+
+func (o *TLSPolicyOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if _, err := o.listenerPolicies(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := gatewaynet.ParseTLSPolicy(o.UpstreamPolicy); err != nil {
+		errs = append(errs, fmt.Errorf("invalid --proxy-upstream-tls-policy: %v", err))
+	}
+	return errs
+}
+
+func (o *TLSPolicyOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringArrayVar(&o.ListenerPolicies, "proxy-listener-tls-policy", o.ListenerPolicies, ""+
+		"TLS policy of proxy listeners in format PORT:policy, PORT * applies to all ports in --proxy-secure-ports "+
+		"and policies of specific ports override it. Policy is a comma separated list of key=value, keys are "+
+		"minVersion, maxVersion, cipherSuites, curves, alpn and http2, lists are separated by |, e.g. "+
+		"*:minVersion=VersionTLS12,curves=X25519|P256. alpn sets the preference order of protocols and http2=false "+
+		"serves HTTP/1.1 only for legacy clients misbehaving with HTTP/2 GOAWAY. "+
+		"Unset keys inherit from --tls-min-version and --tls-cipher-suites. "+
+		"This flag can be repeated.")
+	fs.StringVar(&o.UpstreamPolicy, "proxy-upstream-tls-policy", o.UpstreamPolicy, ""+
+		"TLS policy of connections to all upstream endpoints in the same format as --proxy-listener-tls-policy "+
+		"without PORT, e.g. minVersion=VersionTLS12. alpn is ignored by transports which require http/1.1.")
+}
+
+// listenerPolicies parses listener policies by port, port * means all listeners
+func (o *TLSPolicyOptions) listenerPolicies() (map[string]*gatewaynet.TLSPolicy, error) {
+	policies := map[string]*gatewaynet.TLSPolicy{}
+	for _, value := range o.ListenerPolicies {
+		parts := strings.SplitN(value, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --proxy-listener-tls-policy %q, must be in format PORT:policy", value)
+		}
+		port := strings.TrimSpace(parts[0])
+		if port != allListeners {
+			if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+				return nil, fmt.Errorf("invalid port of --proxy-listener-tls-policy %q", value)
+			}
+		}
+		if _, ok := policies[port]; ok {
+			return nil, fmt.Errorf("duplicate port of --proxy-listener-tls-policy %q", value)
+		}
+		policy, err := gatewaynet.ParseTLSPolicy(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid --proxy-listener-tls-policy %q: %v", value, err)
+		}
+		policies[port] = policy
+	}
+	return policies, nil
+}
+
+// ApplyTo sets tls policies of proxy listeners on ports and upstream transports, it must be called
+// before the controller runs.
+func (o *TLSPolicyOptions) ApplyTo(controller *controllers.UpstreamClusterController, ports []int) error {
+	if o == nil {
+		return nil
+	}
+	policies, err := o.listenerPolicies()
+	if err != nil {
+		return err
+	}
+	listenerPolicies := map[int]*gatewaynet.TLSPolicy{}
+	for _, port := range ports {
+		if policy, ok := policies[strconv.Itoa(port)]; ok {
+			listenerPolicies[port] = policy
+		} else if policy, ok := policies[allListeners]; ok {
+			listenerPolicies[port] = policy
+		}
+	}
+	controller.SetListenerTLSPolicies(listenerPolicies)
+
+	upstream, err := gatewaynet.ParseTLSPolicy(o.UpstreamPolicy)
+	if err != nil {
+		return err
+	}
+	if !upstream.Empty() {
+		clusters.DefaultUpstreamTLSPolicy = upstream
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UpstreamAuthOptions struct {
+	Mode string
+}
+
+func NewUpstreamAuthOptions() *UpstreamAuthOptions {
+	return &UpstreamAuthOptions{
+		Mode: string(clusters.AuthModeImpersonate),
+	}
+}
+
+func (o *UpstreamAuthOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if _, err := clusters.ParseAuthMode(o.Mode); err != nil {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-auth-mode: %v", err))
+	}
+	return errs
+}
+
+func (o *UpstreamAuthOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Mode, "proxy-upstream-auth-mode", o.Mode, ""+
+		"How proxied requests are authenticated to upstream clusters, one of Impersonate, Passthrough and RequestHeader. "+
+		"Impersonate authenticates with gateway credential and impersonates the client, Passthrough forwards the "+
+		"client bearer token without gateway credential, RequestHeader authenticates with gateway client certificate "+
+		"and propagates the client in X-Remote-* headers. It can be overridden by annotation "+
+		clusters.AuthModeAnnotationKey+" of each upstream cluster.")
+}
+
+// ApplyTo sets the default auth mode of all upstream clusters in defaults, it must be called before
+// upstream cluster controller starts.
+func (o *UpstreamAuthOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	if mode, err := clusters.ParseAuthMode(o.Mode); err == nil {
+		defaults.AuthMode = mode
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/util/cert"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+)
+
+type UpstreamCanaryOptions struct {
+	Interval  time.Duration
+	Timeout   time.Duration
+	Paths     []string
+	Address   string
+	TokenFile string
+	CAFile    string
+}
+
+func NewUpstreamCanaryOptions() *UpstreamCanaryOptions {
+	return &UpstreamCanaryOptions{
+		Timeout: 5 * time.Second,
+		Paths:   []string{"/version"},
+	}
+}
+
+func (o *UpstreamCanaryOptions) Validate() []error {
+	if o == nil || o.Interval == 0 {
+		return nil
+	}
+	errs := []error{}
+	if o.Interval < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-canary-interval must not be negative"))
+	}
+	if o.Timeout <= 0 || o.Timeout > o.Interval {
+		errs = append(errs, fmt.Errorf("--proxy-canary-timeout must be greater than 0 and not greater than --proxy-canary-interval"))
+	}
+	if len(o.Paths) == 0 {
+		errs = append(errs, fmt.Errorf("--proxy-canary-paths must not be empty"))
+	}
+	for _, path := range o.Paths {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("--proxy-canary-paths %q must start with /", path))
+		}
+	}
+	if len(o.CAFile) > 0 {
+		if _, err := cert.NewPool(o.CAFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --proxy-canary-ca-file %q: %v", o.CAFile, err))
+		}
+	}
+	if len(o.Address) > 0 {
+		if _, _, err := net.SplitHostPort(o.Address); err != nil {
+			errs = append(errs, fmt.Errorf("invalid --proxy-canary-address %q: %v", o.Address, err))
+		}
+	}
+	return errs
+}
+
+func (o *UpstreamCanaryOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.DurationVar(&o.Interval, "proxy-canary-interval", o.Interval, ""+
+		"The interval to send canary requests to every upstream cluster through the full proxy path of this "+
+		"replica, their success and latency are the end-to-end availability of each cluster, distinct from "+
+		"endpoint health checks. Zero means disabled.")
+	fs.DurationVar(&o.Timeout, "proxy-canary-timeout", o.Timeout,
+		"The timeout of each canary request.")
+	fs.StringSliceVar(&o.Paths, "proxy-canary-paths", o.Paths, ""+
+		"The cheap read requests sent to every cluster each round, e.g. /version,/api/v1/namespaces/kube-system.")
+	fs.StringVar(&o.Address, "proxy-canary-address", o.Address, ""+
+		"The proxy listener address canary requests are sent to, defaults to 127.0.0.1 with the first port "+
+		"of --proxy-secure-ports.")
+	fs.StringVar(&o.TokenFile, "proxy-canary-token-file", o.TokenFile, ""+
+		"The file containing the bearer token which authenticates canary requests, it is reread every round. "+
+		"The token must be authorized to read canary paths in every cluster. Empty means canary requests are anonymous.")
+	fs.StringVar(&o.CAFile, "proxy-canary-ca-file", o.CAFile, ""+
+		"The CA bundle verifying the default serving certificate of gateway for cluster hostnames. Clusters with "+
+		"their own serving certificates are pinned to them, canary requests to the other clusters fail before "+
+		"sending the token if it is empty.")
+}
+
+// ApplyTo enables canary probing of upstream controller if configured, it must be called
+// before upstream controller starts.
+func (o *UpstreamCanaryOptions) ApplyTo(controller *controllers.UpstreamClusterController, ports []int) {
+	if o == nil || o.Interval <= 0 || (len(o.Address) == 0 && len(ports) == 0) {
+		return
+	}
+	address := o.Address
+	if len(address) == 0 {
+		address = net.JoinHostPort("127.0.0.1", strconv.Itoa(ports[0]))
+	}
+	controller.EnableCanaryProbing(controllers.CanaryConfig{
+		Address:   address,
+		Paths:     o.Paths,
+		Interval:  o.Interval,
+		Timeout:   o.Timeout,
+		TokenFile: o.TokenFile,
+		CAFile:    o.CAFile,
+	})
+}
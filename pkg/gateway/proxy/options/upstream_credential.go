@@ -0,0 +1,65 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UpstreamCredentialOptions struct {
+	File string
+}
+
+func NewUpstreamCredentialOptions() *UpstreamCredentialOptions {
+	return &UpstreamCredentialOptions{}
+}
+
+func (o *UpstreamCredentialOptions) Validate() []error {
+	if o == nil || len(o.File) == 0 {
+		return nil
+	}
+	if _, err := clusters.LoadUpstreamCredentials(o.File); err != nil {
+		return []error{fmt.Errorf("--proxy-upstream-credentials-file: %v", err)}
+	}
+	return nil
+}
+
+func (o *UpstreamCredentialOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.File, "proxy-upstream-credentials-file", o.File, ""+
+		"A yaml file mapping names to a tokenFile or a client-go exec credential plugin on gateway host. "+
+		"UpstreamClusters reference them by annotation "+clusters.UpstreamCredentialAnnotationKey+" and "+
+		"names which are not in the file are rejected. It requires feature gate UpstreamCredentialPlugins.")
+}
+
+// ApplyTo sets the allowed upstream credentials in defaults, it must be called before any cluster is created
+// and before UpstreamClusters are admitted.
+func (o *UpstreamCredentialOptions) ApplyTo(defaults *clusters.ClusterDefaults) error {
+	if o == nil || len(o.File) == 0 {
+		return nil
+	}
+	credentials, err := clusters.LoadUpstreamCredentials(o.File)
+	if err != nil {
+		return err
+	}
+	defaults.UpstreamCredentials = credentials
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UpstreamPrewarmOptions struct {
+	Connections int
+}
+
+func NewUpstreamPrewarmOptions() *UpstreamPrewarmOptions {
+	return &UpstreamPrewarmOptions{}
+}
+
+func (o *UpstreamPrewarmOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.Connections < 0 || o.Connections > clusters.MaxPrewarmConnections {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-prewarm-connections must be between 0 and %d, inclusive", clusters.MaxPrewarmConnections))
+	}
+	return errs
+}
+
+func (o *UpstreamPrewarmOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.IntVar(&o.Connections, "proxy-upstream-prewarm-connections", o.Connections, ""+
+		"The number of connections established and TLS handshaked in advance after an upstream endpoint "+
+		"becomes healthy, so the first client requests do not pay cold-start latency. HTTP/2 transports "+
+		"are warmed with one multiplexed connection each and this number of HTTP/1.1 connections are kept "+
+		"for upgrade requests, e.g. exec and port-forward. Zero means disabled.")
+}
+
+// ApplyTo sets the pre-warmed connections of all upstream endpoints in defaults, it must be called before
+// upstream cluster controller starts.
+func (o *UpstreamPrewarmOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	defaults.PrewarmConnections = o.Connections
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	gatewayclientset "github.com/kubewharf/kubegateway/pkg/client/kubernetes"
+	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+)
+
+const (
+	HealthCheckModeHealthz  = "healthz"
+	HealthCheckModeBlackbox = "blackbox"
+)
+
+type UpstreamProbeOptions struct {
+	HealthCheckMode string
+	Timeout         time.Duration
+	ReplicaName     string
+	ReportInterval  time.Duration
+}
+
+func NewUpstreamProbeOptions() *UpstreamProbeOptions {
+	hostname, _ := os.Hostname()
+	return &UpstreamProbeOptions{
+		HealthCheckMode: HealthCheckModeHealthz,
+		Timeout:         5 * time.Second,
+		ReplicaName:     hostname,
+		ReportInterval:  30 * time.Second,
+	}
+}
+
+func (o *UpstreamProbeOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	switch o.HealthCheckMode {
+	case HealthCheckModeHealthz:
+		return errs
+	case HealthCheckModeBlackbox:
+	default:
+		return append(errs, fmt.Errorf("--proxy-upstream-health-check-mode must be one of %s and %s", HealthCheckModeHealthz, HealthCheckModeBlackbox))
+	}
+	if o.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-probe-timeout must be greater than 0"))
+	}
+	if o.ReportInterval < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-reachability-report-interval must not be negative"))
+	}
+	if o.ReportInterval > 0 && len(o.ReplicaName) == 0 {
+		errs = append(errs, fmt.Errorf("--proxy-replica-name must be specified to report reachability"))
+	}
+	return errs
+}
+
+func (o *UpstreamProbeOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.HealthCheckMode, "proxy-upstream-health-check-mode", o.HealthCheckMode, ""+
+		"How each gateway replica checks upstream endpoints, one of healthz and blackbox. "+
+		"In blackbox mode, TCP, TLS and HTTP reachability are verified stage by stage with fresh connections, "+
+		"so an endpoint unreachable from one replica is only removed from that replica's routing.")
+	fs.DurationVar(&o.Timeout, "proxy-upstream-probe-timeout", o.Timeout,
+		"The timeout of all stages of one blackbox probe.")
+	fs.StringVar(&o.ReplicaName, "proxy-replica-name", o.ReplicaName,
+		"The name of this gateway replica in the reachability status, defaults to hostname.")
+	fs.DurationVar(&o.ReportInterval, "proxy-upstream-reachability-report-interval", o.ReportInterval, ""+
+		"The interval to report blackbox probe results of this replica to status.reachability of each "+
+		"upstream cluster, results are written when changed and refreshed every 3 intervals. Results of replicas "+
+		"not reported in 6 intervals, e.g. replaced by a rollout, are removed. Zero means not reporting.")
+}
+
+// ApplyTo enables blackbox probing of upstream controller if configured, it must be called
+// before upstream controller starts.
+func (o *UpstreamProbeOptions) ApplyTo(controller *controllers.UpstreamClusterController, client gatewayclientset.Interface) {
+	if o == nil || o.HealthCheckMode != HealthCheckModeBlackbox {
+		return
+	}
+	controller.EnableBlackboxProbing(controllers.BlackboxProbeConfig{
+		Timeout:        o.Timeout,
+		Replica:        o.ReplicaName,
+		ReportInterval: o.ReportInterval,
+		Client:         client,
+	})
+}
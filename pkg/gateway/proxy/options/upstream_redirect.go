@@ -0,0 +1,67 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UpstreamRedirectOptions struct {
+	Policy string
+}
+
+func NewUpstreamRedirectOptions() *UpstreamRedirectOptions {
+	return &UpstreamRedirectOptions{
+		Policy: string(clusters.RedirectPolicyPassThrough),
+	}
+}
+
+func (o *UpstreamRedirectOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if _, err := clusters.ParseRedirectPolicy(o.Policy); err != nil {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-redirect-policy: %v", err))
+	}
+	return errs
+}
+
+func (o *UpstreamRedirectOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Policy, "proxy-upstream-redirect-policy", o.Policy, ""+
+		"How 3xx responses from upstream endpoints are handled, one of PassThrough, Rewrite and Follow. "+
+		"PassThrough returns redirects untouched. Rewrite replaces endpoint addresses in Location headers with the gateway host requested by client, "+
+		"Follow follows redirects within the same endpoint for replayable requests and rewrites the others. "+
+		"It can be overridden by annotation "+
+		clusters.RedirectPolicyAnnotationKey+" of each upstream cluster.")
+}
+
+// ApplyTo sets the default redirect policy of all upstream clusters in defaults, it must be called before
+// upstream cluster controller starts.
+func (o *UpstreamRedirectOptions) ApplyTo(defaults *clusters.ClusterDefaults) {
+	if o == nil {
+		return
+	}
+	if policy, err := clusters.ParseRedirectPolicy(o.Policy); err == nil {
+		defaults.RedirectPolicy = policy
+	}
+}
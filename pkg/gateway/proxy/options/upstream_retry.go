@@ -0,0 +1,98 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type UpstreamRetryOptions struct {
+	MaxRetries   int
+	StatusCodes  []int
+	Backoff      time.Duration
+	MaxBodyBytes int64
+}
+
+func NewUpstreamRetryOptions() *UpstreamRetryOptions {
+	return &UpstreamRetryOptions{
+		MaxRetries:   0,
+		StatusCodes:  []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+		Backoff:      100 * time.Millisecond,
+		MaxBodyBytes: 0,
+	}
+}
+
+func (o *UpstreamRetryOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-max-retries must not be negative"))
+	}
+	if o.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-retry-max-body-bytes must not be negative"))
+	}
+	if o.Backoff < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-retry-backoff must not be negative"))
+	}
+	for _, code := range o.StatusCodes {
+		switch {
+		case code == http.StatusInternalServerError:
+			// 500 means the request may have been processed by upstream apiserver
+			errs = append(errs, fmt.Errorf("--proxy-upstream-retry-status-codes must not contain %d, it is never retried", code))
+		case code < 500 || code > 599:
+			errs = append(errs, fmt.Errorf("--proxy-upstream-retry-status-codes contains invalid code %d, only 5xx codes can be retried", code))
+		}
+	}
+	return errs
+}
+
+func (o *UpstreamRetryOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.IntVar(&o.MaxRetries, "proxy-upstream-max-retries", o.MaxRetries, ""+
+		"The maximum number of times gateway retries a request on another ready endpoint. Requests failed at "+
+		"connection setup are retried if their body is replayable, and safe requests (GET, HEAD and OPTIONS) are also "+
+		"retried when upstream responds with one of --proxy-upstream-retry-status-codes. Zero means disabled.")
+	fs.IntSliceVar(&o.StatusCodes, "proxy-upstream-retry-status-codes", o.StatusCodes, ""+
+		"A list of upstream 5xx response codes which are retried by gateway. 500 is never retried.")
+	fs.DurationVar(&o.Backoff, "proxy-upstream-retry-backoff", o.Backoff,
+		"The duration to wait before each upstream retry.")
+	fs.Int64Var(&o.MaxBodyBytes, "proxy-upstream-retry-max-body-bytes", o.MaxBodyBytes, ""+
+		"The maximum size of request body spooled in memory so that the request can be replayed on another endpoint. "+
+		"Requests with larger body are never retried. Zero means requests with body are never retried.")
+}
+
+// ToRetryPolicy returns the upstream retry policy for dispatcher, nil means upstream retry is disabled
+func (o *UpstreamRetryOptions) ToRetryPolicy() *dispatcher.RetryPolicy {
+	if o == nil || o.MaxRetries == 0 {
+		return nil
+	}
+	return &dispatcher.RetryPolicy{
+		MaxRetries:   o.MaxRetries,
+		StatusCodes:  sets.NewInt(o.StatusCodes...),
+		Backoff:      o.Backoff,
+		MaxBodyBytes: o.MaxBodyBytes,
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UpstreamTimeoutOptions struct {
+	ResponseHeaderTimeout time.Duration
+	PropagateDeadline     bool
+	DeadlineMargin        time.Duration
+}
+
+func NewUpstreamTimeoutOptions() *UpstreamTimeoutOptions {
+	return &UpstreamTimeoutOptions{
+		ResponseHeaderTimeout: 0,
+		DeadlineMargin:        time.Second,
+	}
+}
+
+func (o *UpstreamTimeoutOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if o.ResponseHeaderTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-response-header-timeout must not be negative"))
+	}
+	if o.DeadlineMargin < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-upstream-deadline-margin must not be negative"))
+	}
+	return errs
+}
+
+func (o *UpstreamTimeoutOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.DurationVar(&o.ResponseHeaderTimeout, "proxy-upstream-response-header-timeout", o.ResponseHeaderTimeout, ""+
+		"The maximum time to wait for upstream response headers of every request including watches, "+
+		"so endpoints which accept connections but never answer are detected quickly. Response bodies, "+
+		"e.g. watch events, are streamed without limit after headers arrive. Timed out safe requests are "+
+		"retried on other endpoints if --proxy-upstream-max-retries is set. It must be larger than the "+
+		"slowest expected list request. Zero means only non-long-running requests are bounded by --request-timeout plus 10s.")
+	fs.BoolVar(&o.PropagateDeadline, "proxy-upstream-deadline-propagation", o.PropagateDeadline, ""+
+		"If true, the time left before gateway gives up a non-long-running request, i.e. its response header timeout "+
+		"or the deadline of client request if earlier, is sent to upstream in header "+clusters.DeadlineHeader+
+		" and by shortening the timeout query parameter, so upstream apiservers stop working on requests whose "+
+		"clients gateway has already timed out.")
+	fs.DurationVar(&o.DeadlineMargin, "proxy-upstream-deadline-margin", o.DeadlineMargin, ""+
+		"The duration subtracted from the propagated deadline, so upstream times out and responds a Status "+
+		"before gateway cancels the request.")
+}
+
+// ApplyTo sets the response header timeout of all upstream endpoints in defaults, requestTimeout is --request-timeout
+// which non-long-running requests are bounded by. It must be called before upstream cluster controller starts.
+func (o *UpstreamTimeoutOptions) ApplyTo(defaults *clusters.ClusterDefaults, requestTimeout time.Duration) {
+	if o == nil {
+		return
+	}
+	if requestTimeout > 0 {
+		defaults.RequestTimeout = requestTimeout
+	}
+	defaults.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+	defaults.DeadlinePropagation = clusters.DeadlinePropagation{
+		Enabled: o.PropagateDeadline,
+		Margin:  o.DeadlineMargin,
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
+)
+
+type URLRewriteOptions struct {
+	PathPrefixes []string
+	MaxBodyBytes int64
+}
+
+func NewURLRewriteOptions() *URLRewriteOptions {
+	return &URLRewriteOptions{
+		MaxBodyBytes: 16 * 1024 * 1024,
+	}
+}
+
+func (o *URLRewriteOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	for _, prefix := range o.PathPrefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			errs = append(errs, fmt.Errorf("--proxy-rewrite-url-paths %q must start with /", prefix))
+		}
+	}
+	if o.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("--proxy-rewrite-url-max-body-bytes must not be negative"))
+	}
+	return errs
+}
+
+func (o *URLRewriteOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.PathPrefixes, "proxy-rewrite-url-paths", o.PathPrefixes, ""+
+		"A list of request path prefixes whose JSON responses are scanned for absolute URLs of upstream "+
+		"endpoints, e.g. OpenAPI server urls, which are rewritten to the gateway address requested by client. "+
+		"Long running requests are never rewritten. Empty means disabled, e.g. /openapi/ enables rewriting of OpenAPI documents.")
+	fs.Int64Var(&o.MaxBodyBytes, "proxy-rewrite-url-max-body-bytes", o.MaxBodyBytes,
+		"The maximum size of response body buffered for URL rewriting, larger responses are returned untouched.")
+}
+
+// ToURLRewritePolicy returns the url rewrite policy for dispatcher, nil means disabled
+func (o *URLRewriteOptions) ToURLRewritePolicy() *dispatcher.URLRewritePolicy {
+	if o == nil || len(o.PathPrefixes) == 0 || o.MaxBodyBytes == 0 {
+		return nil
+	}
+	return &dispatcher.URLRewritePolicy{
+		PathPrefixes: o.PathPrefixes,
+		MaxBodyBytes: o.MaxBodyBytes,
+	}
+}
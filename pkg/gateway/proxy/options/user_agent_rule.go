@@ -0,0 +1,68 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type UserAgentRuleOptions struct {
+	Rules string
+}
+
+func NewUserAgentRuleOptions() *UserAgentRuleOptions {
+	return &UserAgentRuleOptions{}
+}
+
+func (o *UserAgentRuleOptions) Validate() []error {
+	if o == nil || len(o.Rules) == 0 {
+		return nil
+	}
+	errs := []error{}
+	if _, err := clusters.ParseDefaultUserAgentRules(o.Rules); err != nil {
+		errs = append(errs, fmt.Errorf("--proxy-user-agent-rules: %v", err))
+	}
+	return errs
+}
+
+func (o *UserAgentRuleOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringVar(&o.Rules, "proxy-user-agent-rules", o.Rules, ""+
+		"A json encoded list of user agent rules applied to all upstream clusters after the rules of their "+
+		clusters.UserAgentRulesAnnotationKey+" annotations, e.g. "+
+		`[{"userAgents":["my-operator/v0.18.*"],"flowControlSchemaName":"throttle"}]. `+
+		"Each rule must name a flow control schema and is skipped for clusters which do not define it, "+
+		"upstreamSubset is not supported.")
+}
+
+// ApplyTo sets the fleet-wide user agent rules of all upstream clusters, it must be called before
+// upstream cluster controller starts.
+func (o *UserAgentRuleOptions) ApplyTo() error {
+	if o == nil || len(o.Rules) == 0 {
+		return nil
+	}
+	rules, err := clusters.ParseDefaultUserAgentRules(o.Rules)
+	if err != nil {
+		return err
+	}
+	clusters.DefaultUserAgentRules = rules
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type VirtualClusterOptions struct {
+	VirtualClusters []string
+	Selectors       []string
+}
+
+func NewVirtualClusterOptions() *VirtualClusterOptions {
+	return &VirtualClusterOptions{}
+}
+
+func (o *VirtualClusterOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	if _, err := o.toVirtualClusters(); err != nil {
+		errs = append(errs, fmt.Errorf("--proxy-virtual-clusters: %v", err))
+	}
+	return errs
+}
+
+func (o *VirtualClusterOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.VirtualClusters, "proxy-virtual-clusters", o.VirtualClusters, ""+
+		"A list of hostname=cluster:namespaces mapping tenant-facing hostnames onto shared upstream clusters, "+
+		"namespaces are separated by | and a trailing * matches a prefix, e.g. "+
+		"tenant-a.gateway.example.com=shared-1:tenant-a|tenant-a-*. Requests of a virtual cluster to other "+
+		"namespaces, cluster scoped resources or all namespaces are rejected by gateway.")
+	fs.StringArrayVar(&o.Selectors, "proxy-virtual-cluster-selectors", o.Selectors, ""+
+		"A hostname:label|field:selector injected into LIST, WATCH and DELETECOLLECTION requests of a virtual "+
+		"cluster, e.g. tenant-a.gateway.example.com:label:tenant=foo. It is intersected with selectors sent by "+
+		"clients, so tenants only see objects matched by both. This flag can be repeated.")
+}
+
+// ApplyTo sets the virtual clusters of cluster manager, it must be called before proxy server starts.
+func (o *VirtualClusterOptions) ApplyTo() error {
+	if o == nil {
+		return nil
+	}
+	virtualClusters, err := o.toVirtualClusters()
+	if err != nil {
+		return err
+	}
+	clusters.DefaultVirtualClusters = virtualClusters
+	return nil
+}
+
+func (o *VirtualClusterOptions) toVirtualClusters() (map[string]*clusters.VirtualCluster, error) {
+	virtualClusters := map[string]*clusters.VirtualCluster{}
+	for _, v := range o.VirtualClusters {
+		vc, err := clusters.ParseVirtualCluster(v)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := virtualClusters[vc.Hostname]; ok {
+			return nil, fmt.Errorf("duplicate virtual cluster hostname %q", vc.Hostname)
+		}
+		virtualClusters[vc.Hostname] = vc
+	}
+	for _, s := range o.Selectors {
+		parts := strings.SplitN(s, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid virtual cluster selector %q, must be in format hostname:label|field:selector", s)
+		}
+		vc, ok := virtualClusters[strings.ToLower(parts[0])]
+		if !ok {
+			return nil, fmt.Errorf("virtual cluster %q of selector %q is not defined", parts[0], s)
+		}
+		if err := vc.AddSelector(parts[1], parts[2]); err != nil {
+			return nil, err
+		}
+	}
+	return virtualClusters, nil
+}
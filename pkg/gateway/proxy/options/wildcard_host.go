@@ -0,0 +1,72 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+)
+
+type WildcardHostOptions struct {
+	Templates []string
+}
+
+func NewWildcardHostOptions() *WildcardHostOptions {
+	return &WildcardHostOptions{}
+}
+
+func (o *WildcardHostOptions) Validate() []error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{}
+	for _, t := range o.Templates {
+		if _, err := clusters.ParseHostTemplate(t); err != nil {
+			errs = append(errs, fmt.Errorf("--proxy-wildcard-hosts: %v", err))
+		}
+	}
+	return errs
+}
+
+func (o *WildcardHostOptions) AddFlags(fs *pflag.FlagSet) {
+	if o == nil {
+		return
+	}
+	fs.StringSliceVar(&o.Templates, "proxy-wildcard-hosts", o.Templates, ""+
+		"A list of hostPattern[=clusterTemplate] resolving hostnames served by a wildcard certificate to "+
+		"upstream cluster names, e.g. {name}.region-a.gateway.example.com={name}-region-a. Each variable matches "+
+		"one DNS label, a leading *. is the same as {name}., and cluster template defaults to the first variable. "+
+		"Templates are tried in order only if no cluster is named after the hostname.")
+}
+
+// ApplyTo sets the host templates of cluster manager, it must be called before proxy server starts.
+func (o *WildcardHostOptions) ApplyTo() error {
+	if o == nil {
+		return nil
+	}
+	templates := []*clusters.HostTemplate{}
+	for _, t := range o.Templates {
+		template, err := clusters.ParseHostTemplate(t)
+		if err != nil {
+			return err
+		}
+		templates = append(templates, template)
+	}
+	clusters.DefaultHostTemplates = templates
+	return nil
+}
@@ -0,0 +1,355 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reverseproxy proxies requests to one upstream location. Upgrade requests are
+// proxied by apimachinery's UpgradeAwareHandler, the others by gateway's own httputil.ReverseProxy,
+// so that errors, transports and metrics of the proxied requests can be hooked by callers.
+package reverseproxy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apiserver/pkg/endpoints/responsewriter"
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/clusters"
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+	"github.com/kubewharf/kubegateway/pkg/gateway/httputil"
+	"github.com/kubewharf/kubegateway/pkg/gateway/net"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
+	"github.com/kubewharf/kubegateway/pkg/gateway/tunables"
+)
+
+// DefaultFlushInterval is the flush interval of responses with content length, it is the same as apimachinery's
+const DefaultFlushInterval = 200 * time.Millisecond
+
+// ErrPanicked is responded as 500 Status if the reverse proxy panics before the response header is written
+var ErrPanicked = errors.New("reverse proxy panicked")
+
+// ErrorResponder responds errors of proxying requests to the caller
+type ErrorResponder = proxy.ErrorResponder
+
+// Hooks are optional callbacks of a Handler, nil hooks are skipped.
+type Hooks struct {
+	// OnError is called with every error of proxying a non-upgrade request before it is responded
+	// or the partially written response is aborted.
+	OnError func(req *http.Request, err error, class errclass.Class)
+	// OnAbortedResponse is called when a partially written response is aborted because of an error.
+	OnAbortedResponse func(req *http.Request, class errclass.Class)
+	// ErrorLogf writes error logs of aborted requests, it defaults to klog. Callers can sample logs by class.
+	ErrorLogf func(class errclass.Class, format string, args ...interface{})
+}
+
+// Handler is a handler for proxy requests that may require an upgrade
+type Handler struct {
+	// Location is the location of the upstream
+	Location *url.URL
+	// Transport is used to proxy non-upgrade requests, the default proxy transport is used if it is nil
+	Transport http.RoundTripper
+	// UpgradeTransport is used to proxy upgrade requests if it is not nil
+	UpgradeTransport proxy.UpgradeRequestRoundTripper
+	// WrapTransport wraps Transport with default proxy transport behavior, e.g. URL rewriting and CORS removing
+	WrapTransport bool
+	// TransportHook wraps the transport of each non-upgrade request if it is not nil, e.g. for retries or metrics
+	TransportHook func(http.RoundTripper) http.RoundTripper
+	// UpgradeRequired rejects non-upgrade requests if true
+	UpgradeRequired bool
+	// UseRequestLocation uses the incoming request URL when talking to the upstream
+	UseRequestLocation bool
+	// FlushInterval is the flush interval of responses, zero disables periodic flushing unless Streaming is true
+	FlushInterval time.Duration
+	// Streaming marks the response as a stream, e.g. watches and followed logs, zero FlushInterval flushes
+	// every write of a stream immediately
+	Streaming bool
+	// PreserveUpstreamCORS passes CORS headers sent from upstream to the CORS filter instead of stripping them
+	PreserveUpstreamCORS bool
+	// DisableSplice copies upgraded streams in user space even if they could be spliced in kernel, it only
+	// takes effect on requests carrying a net.StreamObserver
+	DisableSplice bool
+	// Responder is required for returning errors to the caller
+	Responder ErrorResponder
+	// PanicPolicy records recovered panics of the reverse proxy, gatewaydebug.DefaultPanicPolicy is used if it is nil
+	PanicPolicy *gatewaydebug.PanicPolicy
+	Hooks       Hooks
+}
+
+// New creates a new proxy handler with a default flush interval. Responder is required for returning
+// errors to the caller.
+func New(location *url.URL, transport http.RoundTripper, upgradeTransport proxy.UpgradeRequestRoundTripper, responder ErrorResponder) *Handler {
+	return &Handler{
+		Location:         normalizeLocation(location),
+		Transport:        transport,
+		UpgradeTransport: upgradeTransport,
+		FlushInterval:    DefaultFlushInterval,
+		Responder:        responder,
+	}
+}
+
+func normalizeLocation(location *url.URL) *url.URL {
+	normalized, _ := url.Parse(location.String())
+	if len(normalized.Scheme) == 0 {
+		normalized.Scheme = "http"
+	}
+	return normalized
+}
+
+// ServeHTTP handles the proxy request
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if httpstream.IsUpgradeRequest(req) {
+		if observer, ok := net.StreamObserverFrom(req.Context()); ok && h.DisableSplice {
+			observer.DisableSplice()
+		}
+		h.upgradeHandler().ServeHTTP(w, req)
+		return
+	}
+
+	if h.UpgradeRequired {
+		h.Responder.Error(w, req, apierrors.NewBadRequest("Upgrade request required"))
+		return
+	}
+
+	loc := *h.Location
+	loc.RawQuery = req.URL.RawQuery
+
+	// If original request URL ended in '/', append a '/' at the end of the
+	// of the proxy URL
+	if !strings.HasSuffix(loc.Path, "/") && strings.HasSuffix(req.URL.Path, "/") {
+		loc.Path += "/"
+	}
+
+	// Redirect requests with an empty path to a location that ends with a '/'
+	// This is essentially a hack for http://issue.k8s.io/4958.
+	if len(loc.Path) == 0 {
+		var queryPart string
+		if len(req.URL.RawQuery) > 0 {
+			queryPart = "?" + req.URL.RawQuery
+		}
+		w.Header().Set("Location", req.URL.Path+"/"+queryPart)
+		w.WriteHeader(http.StatusMovedPermanently)
+		return
+	}
+
+	transport := h.Transport
+	if transport == nil || h.WrapTransport {
+		transport = h.defaultProxyTransport(req.URL, transport)
+	}
+	if h.TransportHook != nil {
+		transport = h.TransportHook(transport)
+	}
+
+	// WithContext creates a shallow clone of the request with the same context.
+	newReq := req.WithContext(req.Context())
+	newReq.Header = utilnet.CloneHeader(req.Header)
+	if !h.UseRequestLocation {
+		newReq.URL = &loc
+	}
+
+	panics := h.PanicPolicy
+	if panics == nil {
+		panics = gatewaydebug.DefaultPanicPolicy
+	}
+	// errors and statuses can only be responded if the response header is not written yet
+	tracker := &headerTrackingWriter{ResponseWriter: w}
+	w = responsewriter.WrapForHTTP1Or2(tracker)
+	defer func() {
+		if r := recover(); r != nil {
+			if tracker.aborted {
+				// the partially written response is aborted deliberately by ErrorHandler
+				panic(r)
+			}
+			class := panics.Record(gatewaydebug.PanicSiteReverseProxy, req, r)
+			klog.Errorf("reverseproxy panic'd on %v %v, endpoint: %v, class: %v, err: %v", req.Method, redact.URI(req.RequestURI), h.Location.Host, class, redact.Text(fmt.Sprint(r)))
+			if !tracker.written && panics.RespondStatus(class) {
+				h.Responder.Error(w, req, fmt.Errorf("%w on %v", ErrPanicked, h.Location.Host))
+				return
+			}
+			// Send a GOAWAY and tear down the TCP connection when idle.
+			w.Header().Set("Connection", "close")
+		}
+	}()
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: h.Location.Scheme, Host: h.Location.Host})
+	proxy.Transport = transport
+	proxy.BufferPool = tunables.CopyBufferPool
+	proxy.FlushInterval = h.FlushInterval
+	proxy.Streaming = h.Streaming
+	proxy.ErrorLog = log.New(noSuppressPanicError{}, "", log.LstdFlags)
+	if h.Responder != nil {
+		// if an optional error interceptor/responder was provided wire it
+		// the custom responder might be used for providing a unified error reporting
+		// or supporting retry mechanisms by not sending non-fatal errors to the clients
+		proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+			h.handleError(w, req, err, tracker)
+		}
+	}
+	proxy.ServeHTTP(w, newReq)
+}
+
+// upgradeHandler returns the apimachinery handler which proxies upgrade requests with the same settings.
+// Upgraded streams are copied by io.Copy, which is spliced in kernel by the stream observer of request
+// if both the client and upstream connections are plaintext tcp connections and DisableSplice is false,
+// see net.StreamObserver. Streams over TLS are copied in user space.
+func (h *Handler) upgradeHandler() *proxy.UpgradeAwareHandler {
+	return &proxy.UpgradeAwareHandler{
+		UpgradeRequired:    h.UpgradeRequired,
+		Location:           h.Location,
+		Transport:          h.Transport,
+		UpgradeTransport:   h.UpgradeTransport,
+		WrapTransport:      h.WrapTransport,
+		UseRequestLocation: h.UseRequestLocation,
+		FlushInterval:      h.FlushInterval,
+		Responder:          h.Responder,
+	}
+}
+
+// ErrorHandler handles errors of proxying req, the response header must not be written yet
+func (h *Handler) ErrorHandler(w http.ResponseWriter, req *http.Request, err error) {
+	h.handleError(w, req, err, nil)
+}
+
+// handleError handles errors of proxying req, the error is responded by Responder only if tracker
+// shows the response header is not written yet. Otherwise the partially written response is aborted,
+// because writing an error status now is a superfluous WriteHeader and corrupts the body, and a
+// client reading a truncated chunked response might take it as complete.
+func (h *Handler) handleError(w http.ResponseWriter, req *http.Request, err error, tracker *headerTrackingWriter) {
+	class := errclass.ClassifyProxyError(err)
+	if h.Hooks.OnError != nil {
+		h.Hooks.OnError(req, err, class)
+	}
+
+	if errors.Is(err, http.ErrAbortHandler) {
+		err = errors.Unwrap(err)
+		switch class = errclass.ClassifyProxyError(err); {
+		case class.ClientGone():
+			// ignore request canceled or client disconnected
+			klog.V(5).Infof("connection closed: remoteAddr=%v, endpoint=%v, err: %v", req.RemoteAddr, h.Location.Host, err)
+			return
+		case class == errclass.Goaway:
+			klog.V(4).Infof("connection closed: remoteAddr=%v, endpoint=%v, err: %v", req.RemoteAddr, h.Location.Host, err)
+			w.Header().Set("Connection", "close")
+		default:
+			h.errorLogf(class, "request abort: method=%v host=%v uri=%q endpoint=%v, err: %v", req.Method, net.HostWithoutPort(req.Host), redact.URI(req.RequestURI), h.Location.Host, redact.Error(err))
+		}
+	}
+
+	if tracker != nil && tracker.written {
+		if h.Hooks.OnAbortedResponse != nil {
+			h.Hooks.OnAbortedResponse(req, class)
+		}
+		klog.V(4).Infof("abort partially written response: method=%v uri=%q endpoint=%v, class=%v", req.Method, redact.URI(req.RequestURI), h.Location.Host, class)
+		tracker.aborted = true
+		// http server suppresses the panic, it closes the connection (or resets the stream for http2)
+		panic(http.ErrAbortHandler)
+	}
+	h.Responder.Error(w, req, err)
+}
+
+func (h *Handler) errorLogf(class errclass.Class, format string, args ...interface{}) {
+	if h.Hooks.ErrorLogf != nil {
+		h.Hooks.ErrorLogf(class, format, args...)
+		return
+	}
+	klog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
+
+// headerTrackingWriter tracks whether the response header is written
+type headerTrackingWriter struct {
+	http.ResponseWriter
+	written bool
+	// aborted is true if the partially written response is aborted by panicking http.ErrAbortHandler
+	aborted bool
+}
+
+func (w *headerTrackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headerTrackingWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerTrackingWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+type noSuppressPanicError struct{}
+
+func (noSuppressPanicError) Write(p []byte) (n int, err error) {
+	// skip "suppressing panic for copyResponse error in test; copy error" error message
+	// that ends up in CI tests on each kube-apiserver termination as noise and
+	// everybody thinks this is fatal.
+	if strings.Contains(string(p), "suppressing panic") {
+		return len(p), nil
+	}
+	klog.ErrorDepth(4, string(p))
+	return len(p), nil
+}
+
+func (h *Handler) defaultProxyTransport(url *url.URL, internalTransport http.RoundTripper) http.RoundTripper {
+	scheme := url.Scheme
+	host := url.Host
+	suffix := h.Location.Path
+	if strings.HasSuffix(url.Path, "/") && !strings.HasSuffix(suffix, "/") {
+		suffix += "/"
+	}
+	pathPrepend := strings.TrimSuffix(url.Path, suffix)
+	rewritingTransport := &proxy.Transport{
+		Scheme:       scheme,
+		Host:         host,
+		PathPrepend:  pathPrepend,
+		RoundTripper: internalTransport,
+	}
+	if h.PreserveUpstreamCORS {
+		return rewritingTransport
+	}
+	return &corsRemovingTransport{
+		RoundTripper: rewritingTransport,
+	}
+}
+
+// corsRemovingTransport is a wrapper for an internal transport. It removes CORS headers
+// from the internal response.
+// Implements pkg/util/net.RoundTripperWrapper
+type corsRemovingTransport struct {
+	http.RoundTripper
+}
+
+var _ = utilnet.RoundTripperWrapper(&corsRemovingTransport{})
+
+func (rt *corsRemovingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	// gateway CORS filter sets its own headers on the ResponseWriter if the cluster has a CORS policy
+	clusters.RemoveCORSHeaders(resp.Header)
+	return resp, nil
+}
+
+func (rt *corsRemovingTransport) WrappedRoundTripper() http.RoundTripper {
+	return rt.RoundTripper
+}
@@ -0,0 +1,278 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reverseproxy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	gatewaydebug "github.com/kubewharf/kubegateway/pkg/gateway/debug"
+	"github.com/kubewharf/kubegateway/pkg/gateway/proxy/errclass"
+)
+
+type recordingResponder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *recordingResponder) Error(w http.ResponseWriter, req *http.Request, err error) {
+	r.mu.Lock()
+	r.errs = append(r.errs, err)
+	r.mu.Unlock()
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+func (r *recordingResponder) calls() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error(nil), r.errs...)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// serveThroughGateway serves a request to path by handler created by newHandler in a gateway test server
+func serveThroughGateway(t *testing.T, newHandler func() *Handler, path string) (*http.Response, []byte, error) {
+	t.Helper()
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		newHandler().ServeHTTP(w, r)
+	}))
+	defer gateway.Close()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(gateway.URL + path)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, bodyErr := ioutil.ReadAll(resp.Body)
+	return resp, body, bodyErr
+}
+
+func TestHandler_upstreamFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		// upstream writes the response header and some bytes before failing
+		partial       bool
+		wantResponder bool
+		wantAborted   bool
+		wantCode      int
+		wantBodyErr   bool
+	}{
+		{name: "fails before response header", partial: false, wantResponder: true, wantCode: http.StatusBadGateway},
+		{name: "fails mid-stream", partial: true, wantAborted: true, wantCode: http.StatusOK, wantBodyErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.partial {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"kind":"PodList","items":[`)) // nolint
+					w.(http.Flusher).Flush()
+				}
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err != nil {
+					t.Errorf("failed to hijack: %v", err)
+					return
+				}
+				conn.Close()
+			}))
+			defer upstream.Close()
+
+			location, _ := url.Parse(upstream.URL + "/api/v1/pods")
+			responder := &recordingResponder{}
+			var errorHooks, abortedHooks int32
+			resp, _, bodyErr := serveThroughGateway(t, func() *Handler {
+				h := New(location, http.DefaultTransport, nil, responder)
+				// streams are flushed immediately, so the response header reaches client before the failure
+				h.Streaming, h.FlushInterval = true, 0
+				h.Hooks.OnError = func(*http.Request, error, errclass.Class) { atomic.AddInt32(&errorHooks, 1) }
+				h.Hooks.OnAbortedResponse = func(*http.Request, errclass.Class) { atomic.AddInt32(&abortedHooks, 1) }
+				return h
+			}, "/api/v1/pods")
+
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if (bodyErr != nil) != tt.wantBodyErr {
+				t.Errorf("read body error = %v, want error %v", bodyErr, tt.wantBodyErr)
+			}
+			if got := len(responder.calls()) > 0; got != tt.wantResponder {
+				t.Errorf("responder called = %v, want %v", got, tt.wantResponder)
+			}
+			if got := atomic.LoadInt32(&errorHooks); got != 1 {
+				t.Errorf("OnError called %d times, want 1", got)
+			}
+			if got := atomic.LoadInt32(&abortedHooks) > 0; got != tt.wantAborted {
+				t.Errorf("OnAbortedResponse called = %v, want %v", got, tt.wantAborted)
+			}
+		})
+	}
+}
+
+func TestHandler_location(t *testing.T) {
+	tests := []struct {
+		name      string
+		location  string
+		path      string
+		wantPath  string
+		wantQuery string
+	}{
+		{name: "proxy path", location: "/api/v1/pods", path: "/foo?limit=1", wantPath: "/api/v1/pods", wantQuery: "limit=1"},
+		{name: "keep trailing slash", location: "/api", path: "/api/", wantPath: "/api/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotQuery string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotQuery = r.URL.Path, r.URL.RawQuery
+			}))
+			defer upstream.Close()
+
+			location, _ := url.Parse(upstream.URL + tt.location)
+			resp, _, _ := serveThroughGateway(t, func() *Handler {
+				return New(location, http.DefaultTransport, nil, &recordingResponder{})
+			}, tt.path)
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+			}
+			if gotPath != tt.wantPath || gotQuery != tt.wantQuery {
+				t.Errorf("upstream got path=%q query=%q, want path=%q query=%q", gotPath, gotQuery, tt.wantPath, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestHandler_emptyPathRedirect(t *testing.T) {
+	location, _ := url.Parse("http://127.0.0.1:1")
+	resp, _, _ := serveThroughGateway(t, func() *Handler {
+		return New(location, http.DefaultTransport, nil, &recordingResponder{})
+	}, "/proxy?watch=1")
+	if resp.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusMovedPermanently)
+	}
+	if got, want := resp.Header.Get("Location"), "/proxy/?watch=1"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_upgradeRequired(t *testing.T) {
+	location, _ := url.Parse("http://127.0.0.1:1/api")
+	responder := &recordingResponder{}
+	resp, _, _ := serveThroughGateway(t, func() *Handler {
+		h := New(location, http.DefaultTransport, nil, responder)
+		h.UpgradeRequired = true
+		return h
+	}, "/api")
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+	if len(responder.calls()) != 1 {
+		t.Errorf("responder called %d times, want 1", len(responder.calls()))
+	}
+}
+
+func TestHandler_transportHook(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Hooked", r.Header.Get("X-Hooked"))
+	}))
+	defer upstream.Close()
+
+	location, _ := url.Parse(upstream.URL + "/api")
+	resp, _, _ := serveThroughGateway(t, func() *Handler {
+		h := New(location, http.DefaultTransport, nil, &recordingResponder{})
+		h.TransportHook = func(rt http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Hooked", "true")
+				return rt.RoundTrip(req)
+			})
+		}
+		return h
+	}, "/api")
+	if got := resp.Header.Get("X-Hooked"); got != "true" {
+		t.Errorf("X-Hooked = %q, want the transport hook to be applied", got)
+	}
+}
+
+func TestHandler_upstreamCORS(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}))
+	defer upstream.Close()
+
+	for _, preserve := range []bool{false, true} {
+		location, _ := url.Parse(upstream.URL + "/api")
+		resp, _, _ := serveThroughGateway(t, func() *Handler {
+			h := New(location, http.DefaultTransport, nil, &recordingResponder{})
+			h.WrapTransport = true
+			h.PreserveUpstreamCORS = preserve
+			return h
+		}, "/api")
+		if got := resp.Header.Get("Access-Control-Allow-Origin") != ""; got != preserve {
+			t.Errorf("PreserveUpstreamCORS=%v, upstream CORS header kept = %v", preserve, got)
+		}
+	}
+}
+
+func TestHandler_panic(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantCode   int
+		wantClose  bool
+		wantErrors int
+	}{
+		{name: "respond status", response: gatewaydebug.PanicResponseStatus, wantCode: http.StatusBadGateway, wantErrors: 1},
+		{name: "close connection", response: gatewaydebug.PanicResponseCloseConnection, wantCode: http.StatusOK, wantClose: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, _ := url.Parse("http://127.0.0.1:1/api")
+			responder := &recordingResponder{}
+			resp, _, _ := serveThroughGateway(t, func() *Handler {
+				h := New(location, roundTripperFunc(func(*http.Request) (*http.Response, error) {
+					panic("boom")
+				}), nil, responder)
+				h.PanicPolicy = gatewaydebug.NewPanicPolicy(tt.response, 10, false)
+				return h
+			}, "/api")
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if resp.Close != tt.wantClose {
+				t.Errorf("connection close = %v, want %v", resp.Close, tt.wantClose)
+			}
+			errs := responder.calls()
+			if len(errs) != tt.wantErrors {
+				t.Fatalf("responder called %d times, want %d", len(errs), tt.wantErrors)
+			}
+			for _, err := range errs {
+				if !errors.Is(err, ErrPanicked) {
+					t.Errorf("responded error = %v, want ErrPanicked", err)
+				}
+			}
+		})
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"k8s.io/kubernetes/pkg/master"
 
 	"github.com/kubewharf/kubegateway/pkg/gateway/controllers"
+	"github.com/kubewharf/kubegateway/pkg/gateway/coordination"
+	proxydispatcher "github.com/kubewharf/kubegateway/pkg/gateway/proxy/dispatcher"
 	// RESTStorage installers
 )
 
@@ -33,6 +35,14 @@ type Config struct {
 
 type ExtraConfig struct {
 	UpstreamClusterController *controllers.UpstreamClusterController
+	// InternalH2CBindAddress is the plaintext address serving proxy by h2c only, empty means disabled
+	InternalH2CBindAddress string
+	// HTTP3BindAddress is the UDP address serving proxy by HTTP/3 with ServeHTTP3, empty means disabled
+	HTTP3BindAddress string
+	// Coordinator shares state of this replica with other replicas, nil means disabled
+	Coordinator *coordination.Coordinator
+	// Drain drains sessions in priority order before listeners are closed, nil means disabled
+	Drain *proxydispatcher.DrainPolicy
 }
 
 // Complete fills in any fields not set that are required to have valid data. It's mutating the receiver.
@@ -61,7 +71,8 @@ func (c *CompletedConfig) New(delegationTarget genericapiserver.DelegationTarget
 		return nil, err
 	}
 
-	s.Handler.NonGoRestfulMux.Handle("/metrics", promhttp.HandlerFor(metricsregistry.DefaultGatherer, promhttp.HandlerOpts{}))
+	// exemplars are only exposed in OpenMetrics format, native histograms only in protobuf format
+	s.Handler.NonGoRestfulMux.Handle("/metrics", promhttp.HandlerFor(metricsregistry.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 
 	if c.ExtraConfig.UpstreamClusterController != nil {
 		// start upstream controller
@@ -75,6 +86,62 @@ func (c *CompletedConfig) New(delegationTarget genericapiserver.DelegationTarget
 		}
 	}
 
+	if c.ExtraConfig.Coordinator != nil {
+		if c.ExtraConfig.UpstreamClusterController != nil {
+			// split ceilings of upstream clusters among live replicas, clusters added between
+			// syncs enforce their whole ceilings until the next sync
+			coordinator, clusters := c.ExtraConfig.Coordinator, c.ExtraConfig.UpstreamClusterController
+			coordinator.AddSyncHandler(func() {
+				for _, cluster := range clusters.List() {
+					cluster.ShareCeilings(coordinator)
+				}
+			})
+		}
+		startCoordinatorHookName := "kube-gateway-start-replica-coordination"
+		err := s.AddPostStartHook(startCoordinatorHookName, func(context genericapiserver.PostStartHookContext) error {
+			go c.ExtraConfig.Coordinator.Run(context.StopCh)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.ExtraConfig.InternalH2CBindAddress) > 0 {
+		handler := s.Handler
+		startH2CHookName := "kube-gateway-start-internal-h2c-listener"
+		err := s.AddPostStartHook(startH2CHookName, func(context genericapiserver.PostStartHookContext) error {
+			return serveH2C(c.ExtraConfig.InternalH2CBindAddress, handler, context.StopCh)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(c.ExtraConfig.HTTP3BindAddress) > 0 {
+		handler := s.Handler
+		secureServing := c.GenericConfig.SecureServing
+		startHTTP3HookName := "kube-gateway-start-http3-listener"
+		err := s.AddPostStartHook(startHTTP3HookName, func(context genericapiserver.PostStartHookContext) error {
+			return serveHTTP3(c.ExtraConfig.HTTP3BindAddress, handler, secureServing, context.StopCh)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.ExtraConfig.Drain != nil {
+		// pre-shutdown hooks block termination until they return, listeners are still serving
+		drainHookName := "kube-gateway-drain-sessions"
+		err := s.AddPreShutdownHook(drainHookName, func() error {
+			c.ExtraConfig.Drain.Drain()
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return apiserver.New(name, s), nil
 }
 
@@ -0,0 +1,161 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transform lets extension authors embedding kube-gateway transform proxied responses,
+// e.g. redact fields or rewrite urls, without modifying the dispatcher. Transformers are
+// registered to a Chain with the route whose responses they transform, and the chain is passed
+// to dispatcher by embedded.DispatchConfig.ResponseTransformers.
+//
+// Transformers are streaming safe: each chunk written by the proxy passes through transformers
+// in registration order and is written to client as soon as it is returned, so watches keep
+// streaming. A transformer which needs more bytes, e.g. a whole JSON object, must buffer them
+// itself and return them later, bytes it holds are not flushed to client until it returns them.
+// Chunk boundaries are arbitrary, they are not aligned to watch events or JSON objects.
+//
+// Responses with Content-Encoding, e.g. gzip, and upgraded streams are never transformed.
+package transform
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Transformer transforms one response, it is created by Factory for each response and never
+// called concurrently.
+type Transformer interface {
+	// OnHeaders is called once before the status code and header are written to client, it
+	// returns the status code to write and can modify header in place. Content-Length is
+	// removed before it is called because transformers may change the body length.
+	OnHeaders(status int, header http.Header) (int, error)
+	// OnBodyChunk returns the transformed chunk, an empty result means nothing is written for
+	// now, e.g. the chunk is buffered. chunk must not be retained after it returns.
+	OnBodyChunk(chunk []byte) ([]byte, error)
+	// OnComplete is called after the last chunk when upstream response is completely proxied,
+	// it returns the bytes still held by the transformer.
+	OnComplete() ([]byte, error)
+}
+
+// Factory returns the transformer of the response to req, nil means the response is not
+// transformed by it.
+type Factory func(req *http.Request) Transformer
+
+// Route selects the responses transformed by a transformer, empty fields match everything.
+type Route struct {
+	// Clusters are the upstream cluster names, i.e. hostnames requested by clients
+	Clusters []string
+	// PathPrefixes are the prefixes of request paths
+	PathPrefixes []string
+	// Verbs are the kubernetes request verbs, e.g. get, list and watch
+	Verbs []string
+}
+
+// Matches returns true if the response to path of cluster requested with verb is selected
+func (r Route) Matches(cluster, path, verb string) bool {
+	return matchesAny(r.Clusters, func(c string) bool { return strings.EqualFold(c, cluster) }) &&
+		matchesAny(r.PathPrefixes, func(prefix string) bool { return strings.HasPrefix(path, prefix) }) &&
+		matchesAny(r.Verbs, func(v string) bool { return v == verb })
+}
+
+func matchesAny(values []string, match func(string) bool) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+type hook struct {
+	name    string
+	route   Route
+	factory Factory
+}
+
+// Chain is the ordered list of registered transformers, it is safe for concurrent use.
+type Chain struct {
+	lock  sync.RWMutex
+	hooks []hook
+}
+
+// NewChain creates an empty chain
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// Register appends the transformer created by factory for responses selected by route, so it
+// receives the output of transformers registered before it. name is used in logs and metrics,
+// it must be unique in the chain.
+func (c *Chain) Register(name string, route Route, factory Factory) error {
+	if len(name) == 0 {
+		return fmt.Errorf("name of response transformer must not be empty")
+	}
+	if factory == nil {
+		return fmt.Errorf("factory of response transformer %q must not be nil", name)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, h := range c.hooks {
+		if h.name == name {
+			return fmt.Errorf("response transformer %q is registered twice", name)
+		}
+	}
+	c.hooks = append(c.hooks, hook{name: name, route: route, factory: factory})
+	return nil
+}
+
+// Names returns names of registered transformers in order
+func (c *Chain) Names() []string {
+	if c == nil {
+		return nil
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	names := make([]string, 0, len(c.hooks))
+	for _, h := range c.hooks {
+		names = append(names, h.name)
+	}
+	return names
+}
+
+// Wrap returns w transforming the response to req by transformers whose routes select it, and
+// the func that must be called after the response is proxied to write bytes held by them.
+// w is returned as is if no transformer selects the response.
+func (c *Chain) Wrap(w http.ResponseWriter, req *http.Request, cluster, verb string) (http.ResponseWriter, func()) {
+	if c == nil {
+		return w, func() {}
+	}
+	c.lock.RLock()
+	hooks := c.hooks
+	c.lock.RUnlock()
+
+	var stages []stage
+	for _, h := range hooks {
+		if !h.route.Matches(cluster, req.URL.Path, verb) {
+			continue
+		}
+		if t := h.factory(req); t != nil {
+			stages = append(stages, stage{name: h.name, transformer: t})
+		}
+	}
+	if len(stages) == 0 {
+		return w, func() {}
+	}
+	tw := &transformWriter{ResponseWriter: w, cluster: cluster, stages: stages}
+	return tw, tw.complete
+}
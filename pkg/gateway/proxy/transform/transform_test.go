@@ -0,0 +1,191 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// replacer replaces old with new in the body, it holds the last len(old)-1 bytes of each chunk
+// so an occurrence split across chunks is still replaced.
+type replacer struct {
+	old, new []byte
+	held     []byte
+}
+
+func (r *replacer) OnHeaders(status int, header http.Header) (int, error) {
+	header.Set("X-Replaced", string(r.old))
+	return status, nil
+}
+
+func (r *replacer) OnBodyChunk(chunk []byte) ([]byte, error) {
+	data := bytes.ReplaceAll(append(r.held, chunk...), r.old, r.new)
+	keep := len(r.old) - 1
+	if keep > len(data) {
+		keep = len(data)
+	}
+	r.held = append([]byte(nil), data[len(data)-keep:]...)
+	return data[:len(data)-keep], nil
+}
+
+func (r *replacer) OnComplete() ([]byte, error) {
+	return r.held, nil
+}
+
+func replace(old, new string) Factory {
+	return func(req *http.Request) Transformer {
+		return &replacer{old: []byte(old), new: []byte(new)}
+	}
+}
+
+type failing struct{}
+
+func (failing) OnHeaders(status int, header http.Header) (int, error) { return status, nil }
+func (failing) OnBodyChunk(chunk []byte) ([]byte, error)              { return nil, errors.New("boom") }
+func (failing) OnComplete() ([]byte, error)                           { return nil, nil }
+
+func TestChain(t *testing.T) {
+	chain := NewChain()
+	if err := chain.Register("secret", Route{PathPrefixes: []string{"/api/v1/secrets"}}, replace("password", "********")); err != nil {
+		t.Fatal(err)
+	}
+	// transformers receive output of transformers registered before them
+	if err := chain.Register("stars", Route{Clusters: []string{"a"}}, replace("****", "#")); err != nil {
+		t.Fatal(err)
+	}
+	if err := chain.Register("secret", Route{}, replace("a", "b")); err == nil {
+		t.Errorf("Register() of duplicated name should fail")
+	}
+
+	tests := []struct {
+		name       string
+		cluster    string
+		path       string
+		chunks     []string
+		want       string
+		wantHeader string
+	}{
+		{"chained", "a", "/api/v1/secrets", []string{"user=admin password=pass", "word"}, "user=admin ##=##", "****"},
+		{"split across chunks", "b", "/api/v1/secrets/s1", []string{"pass", "wo", "rd"}, "********", "password"},
+		{"not selected", "b", "/api/v1/pods", []string{"password ****"}, "password ****", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w, complete := chain.Wrap(recorder, req, tt.cluster, "get")
+			w.Header().Set("Content-Length", "100")
+			for _, chunk := range tt.chunks {
+				if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			complete()
+			if got := recorder.Body.String(); got != tt.want {
+				t.Errorf("body = %q, want %q", got, tt.want)
+			}
+			if got := recorder.Header().Get("X-Replaced"); got != tt.wantHeader {
+				t.Errorf("X-Replaced = %q, want %q set by the last transformer", got, tt.wantHeader)
+			}
+			if transformed := len(tt.wantHeader) > 0; transformed == (recorder.Header().Get("Content-Length") != "") {
+				t.Errorf("Content-Length = %q, it should be removed only from transformed responses", recorder.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestChain_passThroughCompressed(t *testing.T) {
+	chain := NewChain()
+	chain.Register("secret", Route{}, replace("password", "********")) //nolint
+
+	recorder := httptest.NewRecorder()
+	w, complete := chain.Wrap(recorder, httptest.NewRequest(http.MethodGet, "/api", nil), "a", "get")
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Write([]byte("password")) //nolint
+	complete()
+	if got := recorder.Body.String(); got != "password" {
+		t.Errorf("compressed body = %q, want untouched", got)
+	}
+}
+
+func TestChain_failure(t *testing.T) {
+	chain := NewChain()
+	chain.Register("failing", Route{}, func(req *http.Request) Transformer { return failing{} }) //nolint
+
+	recorder := httptest.NewRecorder()
+	w, complete := chain.Wrap(recorder, httptest.NewRequest(http.MethodGet, "/api", nil), "a", "get")
+	if _, err := w.Write([]byte("data")); err == nil {
+		t.Errorf("Write() should fail when a transformer fails")
+	}
+	if _, err := w.Write([]byte("more")); err == nil {
+		t.Errorf("Write() after failure should fail")
+	}
+	complete()
+	if recorder.Body.Len() != 0 {
+		t.Errorf("body = %q, want nothing written", recorder.Body.String())
+	}
+}
+
+// nopWriter discards everything so benchmarks measure transform overhead only
+type nopWriter struct {
+	header http.Header
+}
+
+func (w *nopWriter) Header() http.Header         { return w.header }
+func (w *nopWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *nopWriter) WriteHeader(int)             {}
+
+type identity struct{}
+
+func (identity) OnHeaders(status int, header http.Header) (int, error) { return status, nil }
+func (identity) OnBodyChunk(chunk []byte) ([]byte, error)              { return chunk, nil }
+func (identity) OnComplete() ([]byte, error)                           { return nil, nil }
+
+func benchmarkChain(b *testing.B, chain *Chain) {
+	chunk := bytes.Repeat([]byte(`{"type":"MODIFIED","object":{"kind":"Pod"}}`+"\n"), 100)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	b.SetBytes(int64(len(chunk)) * 10)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w, complete := chain.Wrap(&nopWriter{header: http.Header{}}, req, "a", "watch")
+		for j := 0; j < 10; j++ {
+			w.Write(chunk) //nolint
+		}
+		complete()
+	}
+}
+
+func BenchmarkChain_notSelected(b *testing.B) {
+	chain := NewChain()
+	chain.Register("identity", Route{Verbs: []string{"list"}}, func(req *http.Request) Transformer { return identity{} }) //nolint
+	benchmarkChain(b, chain)
+}
+
+func BenchmarkChain_identity(b *testing.B) {
+	chain := NewChain()
+	chain.Register("identity", Route{}, func(req *http.Request) Transformer { return identity{} }) //nolint
+	benchmarkChain(b, chain)
+}
+
+func BenchmarkChain_replace(b *testing.B) {
+	chain := NewChain()
+	chain.Register("replace", Route{}, replace("MODIFIED", "CHANGED")) //nolint
+	benchmarkChain(b, chain)
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transform
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+
+	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/metrics"
+)
+
+type stage struct {
+	name        string
+	transformer Transformer
+}
+
+// transformWriter passes status code, header and body written by the proxy through stages.
+// Once a stage fails, the rest of the response is discarded and writes fail, so the proxy
+// aborts the response instead of sending a body which is transformed partially.
+type transformWriter struct {
+	http.ResponseWriter
+	cluster string
+	stages  []stage
+
+	wroteHeader bool
+	// passThrough is true if the response is not transformed, e.g. it is compressed
+	passThrough bool
+	err         error
+}
+
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *transformWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.ResponseWriter.Header()
+	if encoding := header.Get("Content-Encoding"); len(encoding) > 0 && encoding != "identity" {
+		w.passThrough = true
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	header.Del("Content-Length")
+	for _, s := range w.stages {
+		var err error
+		if status, err = s.transformer.OnHeaders(status, header); err != nil {
+			w.fail(s.name, err)
+			http.Error(w.ResponseWriter, fmt.Sprintf("response transformer %q failed", s.name), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *transformWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.passThrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if err := w.transform(0, p); err != nil {
+		return 0, err
+	}
+	// the whole chunk is consumed even if less or more bytes are written
+	return len(p), nil
+}
+
+// transform passes chunk through stages starting from the stage of index and writes the result
+func (w *transformWriter) transform(index int, chunk []byte) error {
+	for _, s := range w.stages[index:] {
+		if len(chunk) == 0 {
+			return nil
+		}
+		var err error
+		if chunk, err = s.transformer.OnBodyChunk(chunk); err != nil {
+			return w.fail(s.name, err)
+		}
+	}
+	if len(chunk) == 0 {
+		return nil
+	}
+	if _, err := w.ResponseWriter.Write(chunk); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// complete completes stages in order, bytes held by a stage pass through the stages after it
+// before they are completed.
+func (w *transformWriter) complete() {
+	if !w.wroteHeader || w.passThrough || w.err != nil {
+		return
+	}
+	for i, s := range w.stages {
+		tail, err := s.transformer.OnComplete()
+		if err != nil {
+			w.fail(s.name, err)
+			return
+		}
+		if err := w.transform(i+1, tail); err != nil {
+			return
+		}
+	}
+}
+
+func (w *transformWriter) fail(name string, err error) error {
+	metrics.RecordResponseTransformError(w.cluster, name)
+	klog.Errorf("[response transform] cluster=%q transformer=%q failed: %v", w.cluster, name, err)
+	w.err = fmt.Errorf("response transformer %q failed: %v", name, err)
+	return w.err
+}
+
+func (w *transformWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *transformWriter) CloseNotify() <-chan bool {
+	//nolint:staticcheck
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *transformWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("can not hijack connection of response writer type %T", w.ResponseWriter)
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	auditinternal "k8s.io/apiserver/pkg/apis/audit"
+	"k8s.io/apiserver/pkg/audit"
+)
+
+type auditBackend struct {
+	audit.Backend
+}
+
+// NewAuditBackend wraps backend to redact request URIs of audit events before they are sent
+func NewAuditBackend(backend audit.Backend) audit.Backend {
+	if backend == nil {
+		return nil
+	}
+	return &auditBackend{Backend: backend}
+}
+
+func (b *auditBackend) ProcessEvents(events ...*auditinternal.Event) bool {
+	for _, e := range events {
+		e.RequestURI = URI(e.RequestURI)
+	}
+	return b.Backend.ProcessEvents(events...)
+}
+
+func (b *auditBackend) String() string {
+	return "redact<" + b.Backend.String() + ">"
+}
@@ -0,0 +1,140 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact scrubs credentials from everything the gateway logs, including access logs,
+// error logs, audit events and traffic captures. Every call site which logs request URIs,
+// headers or upstream errors must pass them through this package.
+package redact
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Redacted replaces redacted values
+const Redacted = "[REDACTED]"
+
+var (
+	// sensitiveQueryParams are query parameters which may carry credentials
+	sensitiveQueryParams = sets.NewString("access_token", "token", "id_token", "refresh_token", "client_secret", "password")
+
+	// sensitiveHeaders are redacted entirely, headers with sensitiveHeaderPrefixes are redacted too
+	sensitiveHeaders = sets.NewString(
+		"Authorization",
+		"Proxy-Authorization",
+		"Cookie",
+		"Set-Cookie",
+		"Sec-Websocket-Protocol",
+	)
+	sensitiveHeaderPrefixes = []string{
+		// impersonation headers, they may be used to escalate privileges if replayed
+		"Impersonate-",
+		// request header authentication
+		"X-Remote-",
+	}
+
+	textPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(bearer\s+)[^\s"',;]+`),
+		regexp.MustCompile(`(?i)(basic\s+)[a-z0-9+/=]+`),
+		// bearer token in websocket subprotocol
+		regexp.MustCompile(`(base64url\.bearer\.authorization\.k8s\.io\.)[^\s"',;]+`),
+		regexp.MustCompile(`(?i)([?&](?:access_token|token|id_token|refresh_token|client_secret|password)=)[^&\s"']+`),
+	}
+)
+
+// URI redacts values of sensitive query parameters in request URI or URL string, the order
+// of parameters is untouched.
+func URI(uri string) string {
+	i := strings.IndexByte(uri, '?')
+	if i < 0 {
+		return uri
+	}
+	params := strings.Split(uri[i+1:], "&")
+	changed := false
+	for j, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			key = kv[0]
+		}
+		if len(kv) == 2 && sensitiveQueryParams.Has(strings.ToLower(key)) {
+			params[j] = kv[0] + "=" + Redacted
+			changed = true
+		}
+	}
+	if !changed {
+		return uri
+	}
+	return uri[:i+1] + strings.Join(params, "&")
+}
+
+// URL redacts sensitive query parameters and user password of u, u is not modified
+func URL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if _, ok := c.User.Password(); ok {
+		c.User = url.UserPassword(c.User.Username(), Redacted)
+	}
+	return URI(c.String())
+}
+
+// Header returns a copy of h with credentials redacted
+func Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for k, v := range h {
+		if IsSensitiveHeader(k) {
+			out[k] = []string{Redacted}
+			continue
+		}
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// IsSensitiveHeader returns true if values of header may carry credentials
+func IsSensitiveHeader(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	if sensitiveHeaders.Has(key) {
+		return true
+	}
+	for _, prefix := range sensitiveHeaderPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text redacts credentials in free-form text such as error messages, which may embed
+// request URLs or authorization headers.
+func Text(s string) string {
+	for _, p := range textPatterns {
+		s = p.ReplaceAllString(s, "${1}"+Redacted)
+	}
+	return s
+}
+
+// Error returns the redacted error message of err
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return Text(err.Error())
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rejection is the registry of machine-readable codes of requests rejected by gateway
+// itself. Each subsystem registers its rejection reasons with a code, the code is surfaced in
+// Status details, logs, metrics and admin API /debug/gateway/rejections, so clients and
+// operators can tell gateway rejections apart from upstream errors consistently.
+package rejection
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CauseType is the type of status cause carrying the rejection code in its message
+	CauseType metav1.CauseType = "GatewayRejection"
+
+	// Unknown is the code of reasons not registered
+	Unknown Code = "Unknown"
+)
+
+// Code is a stable machine-readable rejection code in CamelCase, e.g. RateLimited
+type Code string
+
+// Info describes a registered rejection
+type Info struct {
+	// Code is the machine-readable code of the rejection
+	Code Code `json:"code"`
+	// Reason is the snake_case reason used by the subsystem in logs and metrics, it is unique
+	Reason string `json:"reason"`
+	// Subsystem is the part of gateway rejecting requests, e.g. dispatcher
+	Subsystem string `json:"subsystem"`
+	// Description tells when requests are rejected for the reason
+	Description string `json:"description"`
+}
+
+var (
+	lock     sync.RWMutex
+	registry = map[string]Info{}
+)
+
+// Register registers the rejection reason of subsystem with code and returns the reason, so it
+// can be used to declare reasons, e.g.
+//
+//	var reasonRateLimited = rejection.Register("dispatcher", "rate_limited", "RateLimited", "...")
+//
+// It panics if reason is registered twice.
+func Register(subsystem, reason string, code Code, description string) string {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, ok := registry[reason]; ok {
+		panic(fmt.Sprintf("rejection reason %q is registered twice", reason))
+	}
+	registry[reason] = Info{Code: code, Reason: reason, Subsystem: subsystem, Description: description}
+	return reason
+}
+
+// Lookup returns the registered rejection of reason
+func Lookup(reason string) (Info, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	info, ok := registry[reason]
+	return info, ok
+}
+
+// CodeOf returns the code of reason, Unknown if reason is not registered
+func CodeOf(reason string) Code {
+	if info, ok := Lookup(reason); ok {
+		return info.Code
+	}
+	return Unknown
+}
+
+// Known returns all registered rejections sorted by subsystem and code
+func Known() []Info {
+	lock.RLock()
+	infos := make([]Info, 0, len(registry))
+	for _, info := range registry {
+		infos = append(infos, info)
+	}
+	lock.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Subsystem != infos[j].Subsystem {
+			return infos[i].Subsystem < infos[j].Subsystem
+		}
+		if infos[i].Code != infos[j].Code {
+			return infos[i].Code < infos[j].Code
+		}
+		return infos[i].Reason < infos[j].Reason
+	})
+	return infos
+}
+
+// WithStatusDetails appends the code of reason to causes of err and returns err, so clients
+// can tell what rejects the request without parsing messages.
+func WithStatusDetails(err *apierrors.StatusError, reason string) *apierrors.StatusError {
+	if err.ErrStatus.Details == nil {
+		err.ErrStatus.Details = &metav1.StatusDetails{}
+	}
+	err.ErrStatus.Details.Causes = append(err.ErrStatus.Details.Causes, metav1.StatusCause{
+		Type:    CauseType,
+		Field:   reason,
+		Message: string(CodeOf(reason)),
+	})
+	return err
+}
@@ -0,0 +1,58 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rejection
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestRegistry(t *testing.T) {
+	reason := Register("test", "test_rejected", "TestRejected", "rejected by test")
+	if reason != "test_rejected" {
+		t.Errorf("Register() = %q, want the reason", reason)
+	}
+	if code := CodeOf(reason); code != "TestRejected" {
+		t.Errorf("CodeOf() = %q, want TestRejected", code)
+	}
+	if code := CodeOf("not_registered"); code != Unknown {
+		t.Errorf("CodeOf() of unregistered reason = %q, want %q", code, Unknown)
+	}
+	found := false
+	for _, info := range Known() {
+		if info.Reason == reason {
+			found = info.Subsystem == "test" && info.Code == "TestRejected"
+		}
+	}
+	if !found {
+		t.Errorf("Known() does not contain registered reason")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Register() of duplicated reason should panic")
+			}
+		}()
+		Register("test", reason, "Other", "")
+	}()
+
+	err := WithStatusDetails(apierrors.NewTooManyRequests("too many requests", 1), reason)
+	causes := err.ErrStatus.Details.Causes
+	if len(causes) != 1 || causes[0].Type != CauseType || causes[0].Message != "TestRejected" || causes[0].Field != reason {
+		t.Errorf("WithStatusDetails() causes = %+v", causes)
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakeupstream provides an in-process fake upstream apiserver, so that dispatcher
+// features can be tested without a real kubernetes cluster. It serves objects added by
+// tests in JSON, streams watch events, echoes upgraded connections (e.g. exec) and
+// injects latency or errors into matched requests.
+package fakeupstream
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// EventType is the type of watch events
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+// Object is a kubernetes object in unstructured format, it must have metadata.name
+type Object map[string]interface{}
+
+// Fault is injected into requests it matches
+type Fault struct {
+	// Match selects requests, nil means all requests
+	Match func(req *http.Request) bool
+	// Probability in [0, 1] that a matched request is faulted, zero means always
+	Probability float64
+	// Latency is added before the request is handled
+	Latency time.Duration
+	// StatusCode responds the request with a Status of the code instead of handling it, 0 means no error
+	StatusCode int
+}
+
+type watchEvent struct {
+	Type   EventType `json:"type"`
+	Object Object    `json:"object"`
+}
+
+// Server is a fake upstream apiserver
+type Server struct {
+	*httptest.Server
+
+	lock            sync.Mutex
+	resourceVersion int
+	// collection path (e.g. /api/v1/namespaces/default/pods) -> name -> object
+	collections map[string]map[string]Object
+	watchers    map[string]map[chan watchEvent]struct{}
+	faults      []Fault
+	requests    []string
+}
+
+// NewServer starts a TLS fake upstream apiserver, Close must be called after use
+func NewServer() *Server {
+	s := &Server{
+		collections: map[string]map[string]Object{},
+		watchers:    map[string]map[chan watchEvent]struct{}{},
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	s.Server.EnableHTTP2 = true
+	s.Server.StartTLS()
+	return s
+}
+
+// RESTConfig returns a config to connect to the server as an upstream endpoint, it trusts the
+// self-signed serving certificate of the server.
+func (s *Server) RESTConfig() *rest.Config {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate().Raw})
+	return &rest.Config{
+		Host: s.URL,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: ca,
+		},
+	}
+}
+
+// Close closes all watches and shuts down the server
+func (s *Server) Close() {
+	s.CloseWatches()
+	s.Server.Close()
+}
+
+// InjectFault adds a fault, faults are checked in the order they are added
+func (s *Server) InjectFault(f Fault) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = append(s.faults, f)
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.faults = nil
+}
+
+// Requests returns "METHOD path" of all received requests
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Emit stores obj in collection and sends the event to watchers of collection, e.g.
+// Emit("/api/v1/namespaces/default/pods", Added, pod)
+func (s *Server) Emit(collection string, eventType EventType, obj Object) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.store(collection, eventType, obj)
+	for ch := range s.watchers[collection] {
+		select {
+		case ch <- watchEvent{Type: eventType, Object: obj}:
+		default:
+			// slow watcher, drop it like apiserver does
+			delete(s.watchers[collection], ch)
+			close(ch)
+		}
+	}
+}
+
+// AddObject adds obj to collection without sending events to watchers
+func (s *Server) AddObject(collection string, obj Object) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.store(collection, Added, obj)
+}
+
+// store applies the event to collection with a new resourceVersion, s.lock must be held
+func (s *Server) store(collection string, eventType EventType, obj Object) {
+	s.resourceVersion++
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = strconv.Itoa(s.resourceVersion)
+	name, _ := metadata["name"].(string)
+
+	objects := s.collections[collection]
+	if objects == nil {
+		objects = map[string]Object{}
+		s.collections[collection] = objects
+	}
+	if eventType == Deleted {
+		delete(objects, name)
+	} else {
+		objects[name] = obj
+	}
+}
+
+// CloseWatches ends all watch streams
+func (s *Server) CloseWatches() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for collection, watchers := range s.watchers {
+		for ch := range watchers {
+			close(ch)
+		}
+		delete(s.watchers, collection)
+	}
+}
+
+func (s *Server) fault(req *http.Request) *Fault {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+	for i := range s.faults {
+		f := s.faults[i]
+		if f.Match != nil && !f.Match(req) {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		return &f
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if f := s.fault(req); f != nil {
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		if f.StatusCode != 0 {
+			writeStatus(w, f.StatusCode, "injected fault")
+			return
+		}
+	}
+
+	switch {
+	case req.URL.Path == "/healthz" || req.URL.Path == "/readyz" || req.URL.Path == "/livez":
+		w.Write([]byte("ok"))
+	case req.URL.Path == "/version":
+		writeJSON(w, http.StatusOK, map[string]string{"major": "1", "minor": "18", "gitVersion": "v1.18.0-fake"})
+	case strings.EqualFold(req.Header.Get("Connection"), "upgrade") || len(req.Header.Get("Upgrade")) > 0:
+		s.serveUpgrade(w, req)
+	case req.Method == http.MethodGet:
+		s.serveGet(w, req)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not supported by fake upstream", req.Method))
+	}
+}
+
+func (s *Server) serveGet(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if req.URL.Query().Get("watch") == "true" || req.URL.Query().Get("watch") == "1" {
+		s.serveWatch(w, req, path)
+		return
+	}
+
+	s.lock.Lock()
+	objects, isCollection := s.collections[path]
+	var obj Object
+	if !isCollection {
+		i := strings.LastIndex(path, "/")
+		if i > 0 {
+			obj = s.collections[path[:i]][path[i+1:]]
+		}
+	}
+	// lists are paged by limit in the order of names, the continue token is the last name of previous page
+	names := []string{}
+	kind := "List"
+	for name, o := range objects {
+		if name > req.URL.Query().Get("continue") {
+			names = append(names, name)
+		}
+		if k, ok := o["kind"].(string); ok {
+			kind = k + "List"
+		}
+	}
+	sort.Strings(names)
+	metadata := map[string]interface{}{"resourceVersion": strconv.Itoa(s.resourceVersion)}
+	if limit, err := strconv.Atoi(req.URL.Query().Get("limit")); err == nil && limit > 0 && limit < len(names) {
+		metadata["continue"] = names[limit-1]
+		metadata["remainingItemCount"] = len(names) - limit
+		names = names[:limit]
+	}
+	items := []interface{}{}
+	for _, name := range names {
+		items = append(items, objects[name])
+	}
+	s.lock.Unlock()
+
+	switch {
+	case isCollection:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"kind":       kind,
+			"apiVersion": "v1",
+			"metadata":   metadata,
+			"items":      items,
+		})
+	case obj != nil:
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeStatus(w, http.StatusNotFound, fmt.Sprintf("%s not found", path))
+	}
+}
+
+func (s *Server) serveWatch(w http.ResponseWriter, req *http.Request, collection string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeStatus(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	ch := make(chan watchEvent, 100)
+	s.lock.Lock()
+	if s.watchers[collection] == nil {
+		s.watchers[collection] = map[chan watchEvent]struct{}{}
+	}
+	s.watchers[collection][ch] = struct{}{}
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		if _, ok := s.watchers[collection][ch]; ok {
+			delete(s.watchers[collection], ch)
+			close(ch)
+		}
+		s.lock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// serveUpgrade switches protocol as requested and echoes everything back, which is
+// enough to test that upgraded streams are proxied verbatim.
+func (s *Server) serveUpgrade(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		writeStatus(w, http.StatusInternalServerError, "upgrade is not supported over http2")
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n", req.Header.Get("Upgrade"))
+	if err := rw.Flush(); err != nil {
+		return
+	}
+	echo(rw.Reader, conn)
+}
+
+func echo(r *bufio.Reader, w io.Writer) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func writeStatus(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Failure",
+		"message":    message,
+		"reason":     http.StatusText(code),
+		"code":       code,
+	})
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakeupstream
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pods = "/api/v1/namespaces/default/pods"
+
+func newPod(name string) Object {
+	return Object{"kind": "Pod", "apiVersion": "v1", "metadata": map[string]interface{}{"name": name}}
+}
+
+func TestServer_Get(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddObject(pods, newPod("a"))
+	s.AddObject(pods, newPod("b"))
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantKind string
+	}{
+		{pods, http.StatusOK, "PodList"},
+		{pods + "/a", http.StatusOK, "Pod"},
+		{pods + "/c", http.StatusNotFound, "Status"},
+	}
+	for _, tt := range tests {
+		resp, err := s.Client().Get(s.URL + tt.path)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", tt.path, err)
+		}
+		obj := Object{}
+		json.NewDecoder(resp.Body).Decode(&obj)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantCode || obj["kind"] != tt.wantKind {
+			t.Errorf("Get(%s) = %v %v, want %v %v", tt.path, resp.StatusCode, obj["kind"], tt.wantCode, tt.wantKind)
+		}
+	}
+}
+
+func TestServer_GetPages(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	for _, name := range []string{"c", "a", "b"} {
+		s.AddObject(pods, newPod(name))
+	}
+
+	var names []string
+	pages := 0
+	for next := ""; pages == 0 || len(next) > 0; pages++ {
+		resp, err := s.Client().Get(s.URL + pods + "?limit=2&continue=" + next)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		list := struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}{}
+		json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		for _, item := range list.Items {
+			names = append(names, item.Metadata.Name)
+		}
+		next = list.Metadata.Continue
+	}
+	if pages != 2 || strings.Join(names, ",") != "a,b,c" {
+		t.Errorf("Get() pages = %v names = %v, want 2 pages of a,b,c", pages, names)
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp, err := s.Client().Get(s.URL + pods + "?watch=true")
+	if err != nil {
+		t.Fatalf("watch error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	// the watch is registered before response header is sent
+	s.Emit(pods, Added, newPod("a"))
+	s.Emit(pods, Deleted, newPod("a"))
+
+	decoder := json.NewDecoder(resp.Body)
+	for _, want := range []EventType{Added, Deleted} {
+		e := watchEvent{}
+		if err := decoder.Decode(&e); err != nil {
+			t.Fatalf("decode event error = %v", err)
+		}
+		if e.Type != want {
+			t.Errorf("event type = %v, want %v", e.Type, want)
+		}
+	}
+
+	s.CloseWatches()
+	if err := decoder.Decode(&watchEvent{}); err == nil {
+		t.Errorf("watch is not closed by CloseWatches()")
+	}
+}
+
+func TestServer_Upgrade(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	conn, err := tls.Dial("tcp", s.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial error = %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("POST " + pods + "/a/exec HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: SPDY/3.1\r\n\r\n"))
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatalf("read response error = %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status code = %v, want 101", resp.StatusCode)
+	}
+	conn.Write([]byte("ping\n"))
+	line, err := r.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("echo = %q, %v, want ping", line, err)
+	}
+}
+
+func TestServer_InjectFault(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddObject(pods, newPod("a"))
+	s.InjectFault(Fault{
+		Match:      func(req *http.Request) bool { return strings.HasPrefix(req.URL.Path, pods) },
+		Latency:    50 * time.Millisecond,
+		StatusCode: http.StatusServiceUnavailable,
+	})
+
+	start := time.Now()
+	resp, err := s.Client().Get(s.URL + pods)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status code = %v, want 503", resp.StatusCode)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("latency is not injected")
+	}
+
+	resp, err = s.Client().Get(s.URL + "/healthz")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("unmatched request is faulted, err = %v", err)
+	}
+	resp.Body.Close()
+
+	s.ClearFaults()
+	resp, err = s.Client().Get(s.URL + pods)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("request is faulted after ClearFaults(), err = %v", err)
+	}
+	resp.Body.Close()
+	if got := len(s.Requests()); got != 3 {
+		t.Errorf("Requests() = %v, want 3", got)
+	}
+}
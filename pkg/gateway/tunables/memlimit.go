@@ -0,0 +1,43 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.19
+// +build go1.19
+
+package tunables
+
+import (
+	"math"
+	"runtime/debug"
+)
+
+// memoryLimitSupported reports whether runtime has a soft memory limit, it is added in go1.19
+const memoryLimitSupported = true
+
+// memoryLimit returns the soft memory limit of runtime, 0 means unlimited
+func memoryLimit() int64 {
+	limit := debug.SetMemoryLimit(-1)
+	if limit == math.MaxInt64 {
+		return 0
+	}
+	return limit
+}
+
+// setMemoryLimit sets the soft memory limit of runtime, 0 means unlimited
+func setMemoryLimit(limit int64) {
+	if limit <= 0 {
+		limit = math.MaxInt64
+	}
+	debug.SetMemoryLimit(limit)
+}
@@ -0,0 +1,28 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.19
+// +build !go1.19
+
+package tunables
+
+// memoryLimitSupported is false before go1.19, runtime has no soft memory limit and
+// GOMEMLIMIT is ignored, so only 0 memory limit is valid.
+const memoryLimitSupported = false
+
+func memoryLimit() int64 {
+	return 0
+}
+
+func setMemoryLimit(limit int64) {}
@@ -0,0 +1,154 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tunables holds runtime knobs of gateway which can be changed while serving, e.g. by
+// admin API /debug/gateway/tunables, since memory and latency properties of gateway vary
+// enormously across deployments.
+package tunables
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"k8s.io/klog"
+)
+
+const (
+	// DefaultCopyBufferSize is the default size of buffers copying proxied bodies
+	DefaultCopyBufferSize = 32 * 1024
+
+	MinCopyBufferSize = 4 * 1024
+	MaxCopyBufferSize = 1024 * 1024
+
+	// MinMemoryLimit is the minimum soft memory limit, a lower limit makes GC run continuously
+	MinMemoryLimit = 64 * 1024 * 1024
+
+	MinGCPercent = 10
+	MaxGCPercent = 10000
+	// GCPercentOff disables GC until the memory limit is reached
+	GCPercentOff = -1
+
+	maxGOMAXPROCS = 1024
+)
+
+// Tunables are runtime knobs of gateway
+type Tunables struct {
+	// GOMAXPROCS is the maximum number of CPUs executing simultaneously
+	GOMAXPROCS int `json:"gomaxprocs"`
+	// GCPercent is the GC target percentage, aka GOGC, GCPercentOff disables GC until the memory limit is reached
+	GCPercent int `json:"gcPercent"`
+	// MemoryLimit is the soft memory limit in bytes, aka GOMEMLIMIT, 0 means unlimited.
+	// It must be 0 if gateway is built with go older than 1.19.
+	MemoryLimit int64 `json:"memoryLimit"`
+	// CopyBufferSize is the size in bytes of buffers copying proxied bodies
+	CopyBufferSize int `json:"copyBufferSize"`
+}
+
+// Validate returns an error if tunables are not safe to apply
+func (t Tunables) Validate() error {
+	if t.GOMAXPROCS < 1 || t.GOMAXPROCS > maxGOMAXPROCS {
+		return fmt.Errorf("gomaxprocs must be in [1, %d]", maxGOMAXPROCS)
+	}
+	if t.GCPercent != GCPercentOff && (t.GCPercent < MinGCPercent || t.GCPercent > MaxGCPercent) {
+		return fmt.Errorf("gcPercent must be %d or in [%d, %d]", GCPercentOff, MinGCPercent, MaxGCPercent)
+	}
+	if t.MemoryLimit != 0 && !memoryLimitSupported {
+		return fmt.Errorf("memoryLimit requires gateway built with go1.19 or later")
+	}
+	if t.MemoryLimit < 0 || (t.MemoryLimit > 0 && t.MemoryLimit < MinMemoryLimit) {
+		return fmt.Errorf("memoryLimit must be 0 or at least %d bytes", MinMemoryLimit)
+	}
+	if t.GCPercent == GCPercentOff && t.MemoryLimit == 0 {
+		return fmt.Errorf("memoryLimit must be set if gcPercent is %d, otherwise memory grows without bound", GCPercentOff)
+	}
+	if t.CopyBufferSize < MinCopyBufferSize || t.CopyBufferSize > MaxCopyBufferSize {
+		return fmt.Errorf("copyBufferSize must be in [%d, %d]", MinCopyBufferSize, MaxCopyBufferSize)
+	}
+	return nil
+}
+
+var (
+	lock    sync.Mutex
+	current Tunables
+
+	// CopyBufferPool provides buffers of CopyBufferSize for copying proxied bodies
+	CopyBufferPool = &bufferPool{size: DefaultCopyBufferSize}
+)
+
+func init() {
+	// there is no getter of GC percent, set it back immediately
+	gcPercent := debug.SetGCPercent(100)
+	debug.SetGCPercent(gcPercent)
+	current = Tunables{
+		GOMAXPROCS:     runtime.GOMAXPROCS(0),
+		GCPercent:      gcPercent,
+		MemoryLimit:    memoryLimit(),
+		CopyBufferSize: DefaultCopyBufferSize,
+	}
+}
+
+// Current returns the tunables in effect, the process defaults respect GOMAXPROCS, GOGC
+// and GOMEMLIMIT environments.
+func Current() Tunables {
+	lock.Lock()
+	defer lock.Unlock()
+	return current
+}
+
+// Apply validates and applies tunables live
+func Apply(t Tunables) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if t == current {
+		return nil
+	}
+	runtime.GOMAXPROCS(t.GOMAXPROCS)
+	setMemoryLimit(t.MemoryLimit)
+	debug.SetGCPercent(t.GCPercent)
+	CopyBufferPool.setSize(t.CopyBufferSize)
+	klog.Infof("[tunables] apply runtime tunables, gomaxprocs=%d gcPercent=%d memoryLimit=%d copyBufferSize=%d (was %+v)",
+		t.GOMAXPROCS, t.GCPercent, t.MemoryLimit, t.CopyBufferSize, current)
+	current = t
+	return nil
+}
+
+// bufferPool is a pool of buffers of the same size, buffers of the old size are dropped after the size changed
+type bufferPool struct {
+	size int64
+	pool sync.Pool
+}
+
+func (p *bufferPool) Get() []byte {
+	size := int(atomic.LoadInt64(&p.size))
+	if buf, ok := p.pool.Get().([]byte); ok && len(buf) == size {
+		return buf
+	}
+	return make([]byte, size)
+}
+
+func (p *bufferPool) Put(buf []byte) {
+	if len(buf) == int(atomic.LoadInt64(&p.size)) {
+		p.pool.Put(buf) // nolint
+	}
+}
+
+func (p *bufferPool) setSize(size int) {
+	atomic.StoreInt64(&p.size, int64(size))
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tunables
+
+import (
+	"testing"
+)
+
+func TestTunables_Validate(t *testing.T) {
+	valid := Tunables{GOMAXPROCS: 4, GCPercent: 100, CopyBufferSize: DefaultCopyBufferSize}
+	tests := []struct {
+		name    string
+		modify  func(t *Tunables)
+		wantErr bool
+	}{
+		{"valid", func(t *Tunables) {}, false},
+		{"zero gomaxprocs", func(t *Tunables) { t.GOMAXPROCS = 0 }, true},
+		{"tiny gc percent", func(t *Tunables) { t.GCPercent = 1 }, true},
+		{"gc off without memory limit", func(t *Tunables) { t.GCPercent = GCPercentOff }, true},
+		{"gc off with memory limit", func(t *Tunables) { t.GCPercent = GCPercentOff; t.MemoryLimit = 1 << 30 }, !memoryLimitSupported},
+		{"tiny memory limit", func(t *Tunables) { t.MemoryLimit = 1024 }, true},
+		{"huge copy buffer", func(t *Tunables) { t.CopyBufferSize = 16 * MaxCopyBufferSize }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tunables := valid
+			tt.modify(&tunables)
+			if err := tunables.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	origin := Current()
+	defer func() {
+		if err := Apply(origin); err != nil {
+			t.Fatalf("failed to restore tunables: %v", err)
+		}
+	}()
+
+	buf := CopyBufferPool.Get()
+	if len(buf) != origin.CopyBufferSize {
+		t.Fatalf("len(Get()) = %d, want %d", len(buf), origin.CopyBufferSize)
+	}
+	CopyBufferPool.Put(buf)
+
+	want := Tunables{GOMAXPROCS: 2, GCPercent: 50, MemoryLimit: 1 << 30, CopyBufferSize: 64 * 1024}
+	if !memoryLimitSupported {
+		want.MemoryLimit = 0
+	}
+	if err := Apply(want); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := Current(); got != want {
+		t.Errorf("Current() = %+v, want %+v", got, want)
+	}
+	if got := memoryLimit(); got != want.MemoryLimit {
+		t.Errorf("memory limit = %d, want %d", got, want.MemoryLimit)
+	}
+	if got := len(CopyBufferPool.Get()); got != want.CopyBufferSize {
+		t.Errorf("len(Get()) = %d after size changed, want %d", got, want.CopyBufferSize)
+	}
+
+	if err := Apply(Tunables{GOMAXPROCS: 2}); err == nil {
+		t.Errorf("Apply() invalid tunables succeeded")
+	}
+	if got := Current(); got != want {
+		t.Errorf("Current() = %+v after invalid tunables, want %+v", got, want)
+	}
+}
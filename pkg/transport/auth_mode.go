@@ -0,0 +1,154 @@
+// Copyright 2022 ByteDance and its affiliates.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/proxy"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/transport"
+
+	gatewayrequest "github.com/kubewharf/kubegateway/pkg/gateway/endpoints/request"
+)
+
+const (
+	// default request header names of kube-apiserver --requestheader-* flags
+	RemoteUserHeader        = "X-Remote-User"
+	RemoteGroupHeader       = "X-Remote-Group"
+	RemoteExtraHeaderPrefix = "X-Remote-Extra-"
+)
+
+var _ net.RoundTripperWrapper = &passthroughRoundTripper{}
+var _ proxy.UpgradeRequestRoundTripper = &passthroughRoundTripper{}
+var _ net.RoundTripperWrapper = &requestHeaderRoundTripper{}
+var _ proxy.UpgradeRequestRoundTripper = &requestHeaderRoundTripper{}
+
+type passthroughRoundTripper struct {
+	delegate http.RoundTripper
+}
+
+// NewPassthroughRoundTripper forwards the raw client Authorization header to upstream, so upstream
+// authenticates and authorizes clients itself. Impersonation requested by client is forwarded too,
+// upstream checks whether the client is allowed to impersonate.
+func NewPassthroughRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &passthroughRoundTripper{
+		delegate: rt,
+	}
+}
+
+// WrapRequest implements k8s.io/apimachinery/pkg/util/proxy.UpgradeRequestRoundTripper interface.
+func (rt *passthroughRoundTripper) WrapRequest(req *http.Request) (*http.Request, error) {
+	authorization, ok := gatewayrequest.RawAuthorizationFrom(req.Context())
+	if !ok {
+		return req, nil
+	}
+	req = net.CloneRequest(req)
+	req.Header.Set("Authorization", authorization)
+
+	extraInfo, ok := gatewayrequest.ExtraReqeustInfoFrom(req.Context())
+	if !ok || !extraInfo.IsImpersonateRequest {
+		return req, nil
+	}
+	requestor, exists := request.UserFrom(req.Context())
+	if !exists {
+		return req, nil
+	}
+	setUserHeaders(req.Header, requestor.GetName(), requestor.GetGroups(), requestor.GetExtra(),
+		transport.ImpersonateUserHeader, transport.ImpersonateGroupHeader, transport.ImpersonateUserExtraHeaderPrefix)
+	return req, nil
+}
+
+func (rt *passthroughRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	newReq, err := rt.WrapRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return rt.delegate.RoundTrip(newReq)
+}
+
+func (rt *passthroughRoundTripper) CancelRequest(req *http.Request) {
+	if canceler, ok := rt.delegate.(requestCanceler); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (rt *passthroughRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.delegate
+}
+
+type requestHeaderRoundTripper struct {
+	delegate http.RoundTripper
+}
+
+// NewRequestHeaderRoundTripper propagates the authenticated user in X-Remote-* headers, upstream
+// trusts them if gateway client certificate is signed by its --requestheader-client-ca-file.
+// The same headers sent by clients are always removed.
+func NewRequestHeaderRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &requestHeaderRoundTripper{
+		delegate: rt,
+	}
+}
+
+// WrapRequest implements k8s.io/apimachinery/pkg/util/proxy.UpgradeRequestRoundTripper interface.
+func (rt *requestHeaderRoundTripper) WrapRequest(req *http.Request) (*http.Request, error) {
+	req = net.CloneRequest(req)
+	for name := range req.Header {
+		if strings.EqualFold(name, RemoteUserHeader) || strings.EqualFold(name, RemoteGroupHeader) ||
+			strings.HasPrefix(strings.ToLower(name), strings.ToLower(RemoteExtraHeaderPrefix)) {
+			req.Header.Del(name)
+		}
+	}
+	requestor, exists := request.UserFrom(req.Context())
+	if !exists {
+		return req, nil
+	}
+	setUserHeaders(req.Header, requestor.GetName(), requestor.GetGroups(), requestor.GetExtra(),
+		RemoteUserHeader, RemoteGroupHeader, RemoteExtraHeaderPrefix)
+	return req, nil
+}
+
+func (rt *requestHeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	newReq, err := rt.WrapRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return rt.delegate.RoundTrip(newReq)
+}
+
+func (rt *requestHeaderRoundTripper) CancelRequest(req *http.Request) {
+	if canceler, ok := rt.delegate.(requestCanceler); ok {
+		canceler.CancelRequest(req)
+	}
+}
+
+func (rt *requestHeaderRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return rt.delegate
+}
+
+func setUserHeaders(header http.Header, name string, groups []string, extra map[string][]string, userHeader, groupHeader, extraPrefix string) {
+	header.Set(userHeader, name)
+	header.Del(groupHeader)
+	for _, group := range groups {
+		header.Add(groupHeader, group)
+	}
+	for k, vv := range extra {
+		for _, v := range vv {
+			header.Add(extraPrefix+headerKeyEscape(k), v)
+		}
+	}
+}
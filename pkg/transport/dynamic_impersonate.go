@@ -25,6 +25,8 @@ import (
 	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/transport"
 	"k8s.io/klog"
+
+	"github.com/kubewharf/kubegateway/pkg/gateway/redact"
 )
 
 type requestCanceler interface {
@@ -63,7 +65,7 @@ func (rt *dynamicImpersonatingRoundTripper) WrapRequest(req *http.Request) (*htt
 		klog.Infof("Add impersonator headers:")
 		klog.Infof("     Name: %s", requestor.GetName())
 		klog.Infof("    Group: %s", groupsToString(requestor.GetGroups()))
-		klog.Infof("    Extra: %s", extraToString(requestor.GetExtra()))
+		klog.Infof("    Extra: %s", redact.Text(extraToString(requestor.GetExtra())))
 	}
 
 	req = net.CloneRequest(req)
@@ -82,7 +84,7 @@ func (rt *dynamicImpersonatingRoundTripper) WrapRequest(req *http.Request) (*htt
 }
 
 func (rt *dynamicImpersonatingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	klog.V(5).Infof("%s %s Host:%s", req.Method, req.URL.String(), req.Host)
+	klog.V(5).Infof("%s %s Host:%s", req.Method, redact.URL(req.URL), req.Host)
 	newReq, err := rt.WrapRequest(req)
 	if err != nil {
 		return nil, err
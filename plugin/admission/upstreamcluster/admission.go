@@ -17,6 +17,7 @@ package upstreamclusteradmission
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apiserver/pkg/admission"
 	genericadmissioninitializer "k8s.io/apiserver/pkg/admission/initializer"
@@ -25,7 +26,9 @@ import (
 
 	proxyv1alpha1 "github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1"
 	"github.com/kubewharf/kubegateway/pkg/apis/proxy/v1alpha1/validation"
+	"github.com/kubewharf/kubegateway/pkg/clusters"
 	"github.com/kubewharf/kubegateway/pkg/clusters/features"
+	"github.com/kubewharf/kubegateway/pkg/gateway/controlplane/admission/initializer"
 )
 
 var _ admission.Interface = &upstreamclusterPlugin{}
@@ -33,15 +36,19 @@ var _ admission.MutationInterface = &upstreamclusterPlugin{}
 var _ admission.ValidationInterface = &upstreamclusterPlugin{}
 var _ genericadmissioninitializer.WantsExternalKubeInformerFactory = &upstreamclusterPlugin{}
 var _ genericadmissioninitializer.WantsExternalKubeClientSet = &upstreamclusterPlugin{}
+var _ initializer.WantsClusterDefaults = &upstreamclusterPlugin{}
 
 func NewUpstreamClusterPlugin() admission.Interface {
 	return &upstreamclusterPlugin{
-		Handler: admission.NewHandler(admission.Create, admission.Update),
+		Handler:  admission.NewHandler(admission.Create, admission.Update),
+		defaults: clusters.NewClusterDefaults(),
 	}
 }
 
 type upstreamclusterPlugin struct {
 	*admission.Handler
+	// defaults of upstream clusters which annotations are validated against
+	defaults *clusters.ClusterDefaults
 }
 
 func (p *upstreamclusterPlugin) ValidateInitialization() error {
@@ -82,6 +89,107 @@ func (p *upstreamclusterPlugin) Validate(ctx context.Context, a admission.Attrib
 				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(features.FeatureGateAnnotationKey), featuregate, err.Error()))
 			}
 		}
+		budget := cluster.Annotations[clusters.ResourceBudgetAnnotationKey]
+		if len(budget) > 0 {
+			if _, err := clusters.ParseResourceBudget(budget, p.defaults.ResourceBudget); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.ResourceBudgetAnnotationKey), budget, err.Error()))
+			}
+		}
+		if ceilings := cluster.Annotations[clusters.CeilingsAnnotationKey]; len(ceilings) > 0 {
+			if _, err := clusters.ParseClusterCeilings(ceilings, p.defaults.Ceilings); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.CeilingsAnnotationKey), ceilings, err.Error()))
+			}
+		}
+		if policy := cluster.Annotations[clusters.CORSPolicyAnnotationKey]; len(policy) > 0 {
+			if _, err := clusters.ParseCORSPolicy(policy); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.CORSPolicyAnnotationKey), policy, err.Error()))
+			}
+		}
+		if policy := cluster.Annotations[clusters.RedirectPolicyAnnotationKey]; len(policy) > 0 {
+			if _, err := clusters.ParseRedirectPolicy(policy); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.RedirectPolicyAnnotationKey), policy, err.Error()))
+			}
+		}
+		if endpoints := cluster.Annotations[clusters.APIGroupEndpointsAnnotationKey]; len(endpoints) > 0 {
+			if _, err := clusters.ParseAPIGroupEndpoints(endpoints); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.APIGroupEndpointsAnnotationKey), endpoints, err.Error()))
+			}
+		}
+		if rules := cluster.Annotations[clusters.AuditOnlyRulesAnnotationKey]; len(rules) > 0 {
+			schemas := sets.NewString()
+			for _, schema := range cluster.Spec.FlowControl.Schemas {
+				schemas.Insert(schema.Name)
+			}
+			if _, err := clusters.ParseAuditOnlyRules(rules, schemas); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.AuditOnlyRulesAnnotationKey), rules, err.Error()))
+			}
+		}
+		if rules := cluster.Annotations[clusters.UserAgentRulesAnnotationKey]; len(rules) > 0 {
+			servers, schemas := sets.NewString(), sets.NewString()
+			for _, server := range cluster.Spec.Servers {
+				servers.Insert(server.Endpoint)
+			}
+			for _, schema := range cluster.Spec.FlowControl.Schemas {
+				schemas.Insert(schema.Name)
+			}
+			if _, err := clusters.ParseUserAgentRules(rules, servers, schemas); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.UserAgentRulesAnnotationKey), rules, err.Error()))
+			}
+		}
+		if rules := cluster.Annotations[clusters.ExpressionRulesAnnotationKey]; len(rules) > 0 {
+			servers, schemas := sets.NewString(), sets.NewString()
+			for _, server := range cluster.Spec.Servers {
+				servers.Insert(server.Endpoint)
+			}
+			for _, schema := range cluster.Spec.FlowControl.Schemas {
+				schemas.Insert(schema.Name)
+			}
+			if _, err := clusters.ParseExpressionRules(rules, servers, schemas, p.defaults.ExpressionCostLimit); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.ExpressionRulesAnnotationKey), rules, err.Error()))
+			}
+		}
+		if replicas := cluster.Annotations[clusters.ReadReplicasAnnotationKey]; len(replicas) > 0 {
+			servers := sets.NewString()
+			for _, server := range cluster.Spec.Servers {
+				servers.Insert(server.Endpoint)
+			}
+			if _, err := clusters.ParseReadReplicas(replicas, servers); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.ReadReplicasAnnotationKey), replicas, err.Error()))
+			}
+		}
+		if identities := cluster.Annotations[clusters.EndpointIdentitiesAnnotationKey]; len(identities) > 0 {
+			servers := sets.NewString()
+			for _, server := range cluster.Spec.Servers {
+				servers.Insert(server.Endpoint)
+			}
+			if _, err := clusters.ParseEndpointIdentities(identities, servers); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.EndpointIdentitiesAnnotationKey), identities, err.Error()))
+			}
+		}
+		if intervals := cluster.Annotations[clusters.FlushIntervalAnnotationKey]; len(intervals) > 0 {
+			if _, err := clusters.ParseFlushIntervals(intervals); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.FlushIntervalAnnotationKey), intervals, err.Error()))
+			}
+		}
+		if rules := cluster.Annotations[clusters.UpstreamCORSAnnotationKey]; len(rules) > 0 {
+			if _, err := clusters.ParseUpstreamCORSRules(rules); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.UpstreamCORSAnnotationKey), rules, err.Error()))
+			}
+		}
+		if sampling := cluster.Annotations[clusters.ErrorLogSamplingAnnotationKey]; len(sampling) > 0 {
+			if _, err := clusters.ParseErrorLogSampling(sampling); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.ErrorLogSamplingAnnotationKey), sampling, err.Error()))
+			}
+		}
+		if mode := cluster.Annotations[clusters.AuthModeAnnotationKey]; len(mode) > 0 {
+			if _, err := clusters.ParseAuthMode(mode); err != nil {
+				allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(clusters.AuthModeAnnotationKey), mode, err.Error()))
+			}
+		}
+		if err := clusters.ValidateUpstreamCredential(cluster.Annotations, p.defaults.UpstreamCredentials); err != nil {
+			key := clusters.UpstreamCredentialAnnotationKey
+			allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("annotations").Key(key), cluster.Annotations[key], err.Error()))
+		}
 	}
 
 	return allErrs.ToAggregate()
@@ -91,6 +199,14 @@ func (p *upstreamclusterPlugin) SetExternalKubeInformerFactory(informers.SharedI
 
 func (p *upstreamclusterPlugin) SetExternalKubeClientSet(kubernetes.Interface) {}
 
+// SetClusterDefaults sets the defaults of upstream clusters, they are filled by proxy options after
+// admission plugins are initialized, so only the pointer is kept
+func (p *upstreamclusterPlugin) SetClusterDefaults(defaults *clusters.ClusterDefaults) {
+	if defaults != nil {
+		p.defaults = defaults
+	}
+}
+
 func shouldIgnore(a admission.Attributes) bool {
 	if a.GetResource().GroupResource() != proxyv1alpha1.Resource("upstreamclusters") {
 		return true